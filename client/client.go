@@ -0,0 +1,219 @@
+// Package client is a Go client for the illuminate HTTP API. Its models
+// mirror the JSON the server sends, so integrators call typed methods rather
+// than building requests and decoding maps themselves:
+//
+//	c := client.New("http://localhost:8080")
+//	page, err := c.List(ctx, client.ListOptions{Filter: "LED, cct=3000"})
+//
+// Errors the server answers with come back as *Error.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client sends requests to one illuminate server.
+type Client struct {
+	// BaseURL is the server's address, such as http://localhost:8080.
+	BaseURL string
+	// HTTPClient sends the requests; nil means http.DefaultClient.
+	HTTPClient *http.Client
+	// Language, when set, is sent as Accept-Language so error messages
+	// and validation issues come back translated.
+	Language string
+}
+
+// New returns a client for the server at baseURL.
+func New(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimRight(baseURL, "/")}
+}
+
+// Error is an error response of the server. Code is set for the errors the
+// server localizes, such as luminaire_not_found; RetryAfter is how long the
+// server asked to wait, as it does with 429 Too Many Requests.
+type Error struct {
+	StatusCode int
+	Message    string
+	Code       string
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("illuminate: %d: %s", e.StatusCode, e.Message)
+}
+
+// File is a file the server hands out, with the name it suggests.
+type File struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// do sends a request for path, with query when it is not empty, and returns
+// the response when its status is 200. Any other status is returned as
+// *Error.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body io.Reader, contentType string) (*http.Response, error) {
+	target := c.BaseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.Language != "" {
+		req.Header.Set("Accept-Language", c.Language)
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		apiErr := &Error{StatusCode: resp.StatusCode}
+		if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && s > 0 {
+			apiErr.RetryAfter = time.Duration(s) * time.Second
+		}
+		var body struct {
+			Error string `json:"error"`
+			Code  string `json:"code"`
+		}
+		if json.Unmarshal(data, &body) == nil && body.Error != "" {
+			apiErr.Message, apiErr.Code = body.Error, body.Code
+		} else {
+			apiErr.Message = strings.TrimSpace(string(data))
+		}
+		return nil, apiErr
+	}
+	return resp, nil
+}
+
+// decode sends a request and decodes its JSON answer into out.
+func (c *Client) decode(ctx context.Context, method, path string, query url.Values, body io.Reader, contentType string, out interface{}) error {
+	resp, err := c.do(ctx, method, path, query, body, contentType)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// file sends a GET request and returns the file it answers with.
+func (c *Client) file(ctx context.Context, path string, query url.Values) (*File, error) {
+	resp, err := c.do(ctx, http.MethodGet, path, query, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	f := &File{ContentType: resp.Header.Get("Content-Type"), Data: data}
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+		f.Name = params["filename"]
+	}
+	return f, nil
+}
+
+// List returns one page of luminaires, newest first.
+func (c *Client) List(ctx context.Context, opts ListOptions) (*Page, error) {
+	var page Page
+	if err := c.decode(ctx, http.MethodGet, "/api/v1/luminaires", opts.query(), nil, "", &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// Get returns a luminaire with its photometric data.
+func (c *Client) Get(ctx context.Context, id int64) (*Detail, error) {
+	var detail Detail
+	if err := c.decode(ctx, http.MethodGet, luminairePath(id, ""), nil, nil, "", &detail); err != nil {
+		return nil, err
+	}
+	return &detail, nil
+}
+
+// Upload sends a photometric file called name. A file that lacks its
+// manufacturer or model is parked: the result's Status is
+// "metadata_required" and Missing lists what to supply.
+func (c *Client) Upload(ctx context.Context, name string, file io.Reader) (*UploadResult, error) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, err := w.CreateFormFile("file", name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	var result UploadResult
+	if err := c.decode(ctx, http.MethodPost, "/api/v1/luminaires", nil, &body, w.FormDataContentType(), &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Delete moves a luminaire to the trash, from where Restore brings it back.
+func (c *Client) Delete(ctx context.Context, id int64) error {
+	resp, err := c.do(ctx, http.MethodDelete, luminairePath(id, ""), nil, nil, "")
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Restore takes a deleted luminaire out of the trash.
+func (c *Client) Restore(ctx context.Context, id int64) error {
+	resp, err := c.do(ctx, http.MethodPost, luminairePath(id, "/restore"), nil, nil, "")
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Export renders a luminaire as a photometric file.
+func (c *Client) Export(ctx context.Context, id int64, opts ExportOptions) (*File, error) {
+	return c.file(ctx, luminairePath(id, "/export"), opts.query())
+}
+
+// Download renders a luminaire for a lighting design application, such as
+// dialux, relux or revit; format may be empty for the application's own.
+func (c *Client) Download(ctx context.Context, id int64, app, format string) (*File, error) {
+	query := url.Values{}
+	if format != "" {
+		query.Set("format", format)
+	}
+	return c.file(ctx, luminairePath(id, "/download/"+url.PathEscape(app)), query)
+}
+
+// ExportCollection returns every luminaire of a smart collection as a ZIP.
+func (c *Client) ExportCollection(ctx context.Context, name string, opts ExportOptions) (*File, error) {
+	return c.file(ctx, "/api/v1/collections/"+url.PathEscape(name)+"/export", opts.query())
+}
+
+func luminairePath(id int64, suffix string) string {
+	return "/api/v1/luminaires/" + strconv.FormatInt(id, 10) + suffix
+}
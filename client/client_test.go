@@ -0,0 +1,174 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"illuminate/internal/database"
+	"illuminate/internal/parser"
+	"illuminate/internal/server"
+	"illuminate/internal/synth"
+)
+
+// start runs the full server on a fresh database and returns a client for
+// it.
+func start(t *testing.T) *Client {
+	t.Helper()
+	db, err := database.Open(filepath.Join(t.TempDir(), "client.db"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := server.NewServerWithDatabase(db)
+	ts := httptest.NewServer(srv.Handler)
+	t.Cleanup(func() {
+		ts.Close()
+		srv.Shutdown(context.Background())
+		db.Close()
+	})
+	return New(ts.URL + "/")
+}
+
+// TestClient drives the full server through the client, so the models stay
+// in step with what the server sends.
+func TestClient(t *testing.T) {
+	c := start(t)
+	ctx := context.Background()
+
+	lum, err := synth.Generate(synth.Options{Distribution: synth.Street, Manufacturer: "Acme", Model: "SL-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lum.Metadata.ColorTemp = 4000
+	data, err := parser.Encode(parser.NewIESParser(), lum, parser.WriteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	uploaded, err := c.Upload(ctx, "sl1.ies", bytes.NewReader(data))
+	if err != nil || uploaded.Status != "uploaded" || uploaded.LuminaireID == 0 {
+		t.Fatalf("upload = %+v, %v", uploaded, err)
+	}
+	id := uploaded.LuminaireID
+
+	page, err := c.List(ctx, ListOptions{Filter: "cct=4000", Limit: 10})
+	if err != nil || len(page.Luminaires) != 1 || page.Luminaires[0].ID != id || page.Luminaires[0].Model != "SL-1" {
+		t.Fatalf("list = %+v, %v", page, err)
+	}
+	if page, err := c.List(ctx, ListOptions{Filter: "cct=3000"}); err != nil || len(page.Luminaires) != 0 {
+		t.Errorf("filtered list = %+v, %v", page, err)
+	}
+
+	detail, err := c.Get(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if detail.Luminaire.Manufacturer != "Acme" || detail.Luminaire.ColorTemp != 4000 ||
+		detail.PhotometricData.NumHorizontalAngles != len(lum.HorizontalAngles) || detail.Goniometer.System != "C-gamma" {
+		t.Errorf("get = %+v", detail)
+	}
+
+	file, err := c.Export(ctx, id, ExportOptions{Format: "ldt", LineEnding: "crlf"})
+	if err != nil || file.Name != "Acme_SL-1.ldt" || !bytes.Contains(file.Data, []byte("\r\n")) {
+		t.Errorf("export = %v, %v", file, err)
+	}
+	if file, err := c.Download(ctx, id, "revit", ""); err != nil || file.Name != "Acme_SL-1.zip" || file.ContentType != "application/zip" {
+		t.Errorf("download = %v, %v", file, err)
+	}
+
+	c.Language = "de"
+	_, err = c.Get(ctx, id+1)
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || apiErr.Code != "luminaire_not_found" || apiErr.Message == "luminaire_not_found" {
+		t.Errorf("missing luminaire: %v", err)
+	}
+	if _, err := c.Export(ctx, id, ExportOptions{Format: "pdf"}); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("export as pdf: %v", err)
+	}
+
+	if err := c.Delete(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if page, err := c.List(ctx, ListOptions{}); err != nil || len(page.Luminaires) != 0 {
+		t.Errorf("after delete = %+v, %v", page, err)
+	}
+	if err := c.Restore(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if page, err := c.List(ctx, ListOptions{}); err != nil || len(page.Luminaires) != 1 {
+		t.Errorf("after restore = %+v, %v", page, err)
+	}
+}
+
+// TestFeed feeds the server the URLs a crawler found on a manufacturer's
+// site, in batches and each once.
+func TestFeed(t *testing.T) {
+	files := map[string][]byte{}
+	var urls URLs
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(files[r.URL.Path])
+	}))
+	defer site.Close()
+	for i := 0; i < MaxFetchURLs+5; i++ {
+		lum, err := synth.Generate(synth.Options{Distribution: synth.Lambertian, Manufacturer: "Acme", Model: fmt.Sprintf("DL-%d", i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := parser.Encode(parser.NewIESParser(), lum, parser.WriteOptions{})
+		name := fmt.Sprintf("/ies/dl%d.ies", i)
+		files[name] = data
+		urls = append(urls, site.URL+name)
+	}
+	urls = append(urls, urls[0])
+
+	t.Setenv("FETCH_ALLOWED_HOSTS", "127.0.0.1")
+	t.Setenv("FETCH_HOST_INTERVAL", "0s")
+	c := start(t)
+	var batches []int
+	counts := map[string]int{}
+	err := c.Feed(context.Background(), urls, func(results []FetchResult) {
+		batches = append(batches, len(results))
+		for _, r := range results {
+			counts[r.Status]++
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(batches) != fmt.Sprint([]int{MaxFetchURLs, 5}) || counts["uploaded"] != MaxFetchURLs+5 {
+		t.Errorf("batches %v, results %v", batches, counts)
+	}
+	if err := c.Feed(context.Background(), urls[:3], func(results []FetchResult) {
+		for _, r := range results {
+			if r.Status != "seen" || r.LuminaireID == 0 {
+				t.Errorf("second feed: %+v", r)
+			}
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestFeedRateLimited sends a batch the server turned away again.
+func TestFeedRateLimited(t *testing.T) {
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls++; calls == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error": "rate limit exceeded"}`))
+			return
+		}
+		w.Write([]byte(`{"results": [{"url": "https://example.com/a.ies", "status": "uploaded", "luminaire_id": 7}]}`))
+	}))
+	defer ts.Close()
+	var got []FetchResult
+	err := New(ts.URL).Feed(context.Background(), URLs{"https://example.com/a.ies"}, func(r []FetchResult) { got = r })
+	if err != nil || calls != 2 || len(got) != 1 || got[0].LuminaireID != 7 {
+		t.Errorf("feed: %v after %d calls: %+v", err, calls, got)
+	}
+}
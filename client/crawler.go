@@ -0,0 +1,113 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// MaxFetchURLs is how many URLs the server takes in one FetchURLs call.
+const MaxFetchURLs = 20
+
+// FetchResult is what the server did with one URL given to FetchURLs.
+// Status is uploaded, duplicate (the file is on record already), seen (the
+// URL was imported before and not fetched again) or failed; LuminaireID is
+// the record for all but failed.
+type FetchResult struct {
+	URL         string `json:"url"`
+	Status      string `json:"status"`
+	LuminaireID int64  `json:"luminaire_id,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// FetchURLs asks the server to download and import the photometric files
+// at urls, at most MaxFetchURLs of them. The server only fetches from the
+// hosts it allows and paces its requests to each, so the call can take a
+// while.
+func (c *Client) FetchURLs(ctx context.Context, urls []string) ([]FetchResult, error) {
+	body, err := json.Marshal(map[string][]string{"urls": urls})
+	if err != nil {
+		return nil, err
+	}
+	var out struct {
+		Results []FetchResult `json:"results"`
+	}
+	if err := c.decode(ctx, http.MethodPost, "/api/v1/luminaires/fetch", nil, bytes.NewReader(body), "application/json", &out); err != nil {
+		return nil, err
+	}
+	return out.Results, nil
+}
+
+// Crawler discovers photometric file URLs, such as a crawler of a
+// manufacturer's download pages. Crawl calls found with each URL as it is
+// discovered and stops with found's error when found returns one.
+type Crawler interface {
+	Crawl(ctx context.Context, found func(url string) error) error
+}
+
+// URLs is a Crawler that finds a fixed list of URLs.
+type URLs []string
+
+// Crawl calls found with each URL in turn.
+func (u URLs) Crawl(ctx context.Context, found func(string) error) error {
+	for _, url := range u {
+		if err := found(url); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Feed runs crawler and hands the URLs it finds to the server in batches of
+// MaxFetchURLs, skipping any it found before. Each batch is sent as soon as
+// it is full, so the crawler waits while the server fetches, and report is
+// called with its results. When the server's rate limit turns a batch away,
+// Feed waits as long as the server asks, at least a second, and sends it
+// again.
+func (c *Client) Feed(ctx context.Context, crawler Crawler, report func([]FetchResult)) error {
+	found := map[string]bool{}
+	var batch []string
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		for {
+			results, err := c.FetchURLs(ctx, batch)
+			var apiErr *Error
+			if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(max(apiErr.RetryAfter, time.Second)):
+				}
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			batch = batch[:0]
+			if report != nil {
+				report(results)
+			}
+			return nil
+		}
+	}
+	err := crawler.Crawl(ctx, func(url string) error {
+		if found[url] {
+			return nil
+		}
+		found[url] = true
+		batch = append(batch, url)
+		if len(batch) < MaxFetchURLs {
+			return nil
+		}
+		return flush()
+	})
+	if err != nil {
+		return err
+	}
+	return flush()
+}
@@ -0,0 +1,66 @@
+package client_test
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+
+	"illuminate/client"
+)
+
+// sitemapCrawler finds the photometric files a manufacturer lists in its
+// sitemap.xml.
+type sitemapCrawler struct {
+	sitemap string
+}
+
+func (s sitemapCrawler) Crawl(ctx context.Context, found func(string) error) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.sitemap, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var urlset struct {
+		URLs []struct {
+			Loc string `xml:"loc"`
+		} `xml:"url"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&urlset); err != nil {
+		return err
+	}
+	for _, u := range urlset.URLs {
+		switch strings.ToLower(path.Ext(u.Loc)) {
+		case ".ies", ".ldt":
+			if err := found(u.Loc); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// A crawler hands what it finds to the catalog, which fetches, dedupes and
+// imports the files; the crawler never touches the database. The server
+// must allow the manufacturer's host in FETCH_ALLOWED_HOSTS.
+func ExampleClient_Feed() {
+	c := client.New("http://localhost:8080")
+	crawler := sitemapCrawler{sitemap: "https://lighting.example.com/sitemap.xml"}
+	err := c.Feed(context.Background(), crawler, func(results []client.FetchResult) {
+		for _, r := range results {
+			if r.Status == "failed" {
+				fmt.Printf("%s: %s\n", r.URL, r.Error)
+			}
+		}
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
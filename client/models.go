@@ -0,0 +1,180 @@
+package client
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Luminaire is the stored metadata of a luminaire. Lengths are in metres,
+// zero when unknown.
+type Luminaire struct {
+	ID                      int64     `json:"id"`
+	Manufacturer            string    `json:"manufacturer"`
+	Model                   string    `json:"model"`
+	CatalogNumber           string    `json:"catalog_number"`
+	Description             string    `json:"luminaire_description"`
+	LampType                string    `json:"lamp_type"`
+	LampCatalog             string    `json:"lamp_catalog"`
+	Ballast                 string    `json:"ballast"`
+	TestLab                 string    `json:"test_lab"`
+	TestNumber              string    `json:"test_number"`
+	IssueDate               string    `json:"issue_date"`
+	TestDate                string    `json:"test_date"`
+	PhotometricType         int       `json:"photometric_type"` // 1 type C, 2 type B, 3 type A
+	InputWatts              float64   `json:"input_watts"`
+	LuminousFlux            float64   `json:"luminous_flux"`
+	ColorTemp               int       `json:"color_temp"`
+	CRI                     int       `json:"cri"`
+	FormatType              string    `json:"format_type"`
+	FormatVersion           string    `json:"format_version"`
+	FormatConfidence        float64   `json:"format_confidence"`
+	LuminousLength          float64   `json:"luminous_length"`
+	LuminousWidth           float64   `json:"luminous_width"`
+	LuminousHeightC0        float64   `json:"luminous_height_c0"`
+	LuminousHeightC90       float64   `json:"luminous_height_c90"`
+	LuminousHeightC180      float64   `json:"luminous_height_c180"`
+	LuminousHeightC270      float64   `json:"luminous_height_c270"`
+	AimTilt                 float64   `json:"aim_tilt"`
+	AimRotation             float64   `json:"aim_rotation"`
+	LampLumenDepreciation   float64   `json:"lamp_lumen_depreciation"`
+	DriverMaintenanceFactor float64   `json:"driver_maintenance_factor"`
+	RatedLife               int       `json:"rated_life"`
+	FileHash                string    `json:"file_hash"`
+	OriginalFilename        string    `json:"original_filename"`
+	State                   string    `json:"state,omitempty"`
+	CreatedAt               time.Time `json:"created_at"`
+}
+
+// PhotometricData is the stored distribution of a luminaire. The candela
+// values are kept as the server stores them.
+type PhotometricData struct {
+	VerticalAngles      []float64 `json:"vertical_angles"`
+	HorizontalAngles    []float64 `json:"horizontal_angles"`
+	CandelaValues       string    `json:"candela_values"`
+	NumVerticalAngles   int       `json:"num_vertical_angles"`
+	NumHorizontalAngles int       `json:"num_horizontal_angles"`
+}
+
+// Detail is a luminaire as Get returns it.
+type Detail struct {
+	Luminaire       Luminaire       `json:"luminaire"`
+	PhotometricData PhotometricData `json:"photometric_data"`
+	Goniometer      Goniometer      `json:"goniometer"`
+	// Provenance says where each set metadata field got its value, by
+	// JSON name: "file", "user" or "heuristic" (the import profile).
+	Provenance map[string]string `json:"provenance"`
+}
+
+// Goniometer is the measuring frame of a luminaire's photometric type.
+type Goniometer struct {
+	// System is "C-gamma", "B-beta" or "A-alpha".
+	System     string `json:"system"`
+	PolarAxis  string `json:"polar_axis"`
+	PlaneAngle string `json:"plane_angle"`
+	Zero       string `json:"zero"`
+}
+
+// Summary is one luminaire of a List page.
+type Summary struct {
+	ID               int64   `json:"id"`
+	Manufacturer     string  `json:"manufacturer"`
+	Model            string  `json:"model"`
+	CatalogNumber    string  `json:"catalog_number"`
+	Description      string  `json:"description"`
+	LampType         string  `json:"lamp_type"`
+	TestLab          string  `json:"test_lab"`
+	TestNumber       string  `json:"test_number"`
+	InputWatts       float64 `json:"input_watts"`
+	LuminousFlux     float64 `json:"luminous_flux"`
+	FormatType       string  `json:"format_type"`
+	FormatVersion    string  `json:"format_version"`
+	OriginalFilename string  `json:"original_filename"`
+	State            string  `json:"state"`
+	CreatedAt        string  `json:"created_at"`
+	// QualityScore and QualityGrade are zero until the record is
+	// validated.
+	QualityScore int    `json:"quality_score,omitempty"`
+	QualityGrade string `json:"quality_grade,omitempty"`
+}
+
+// Page is one page of List. NextCursor is set when another page follows;
+// pass it as ListOptions.Cursor to fetch it.
+type Page struct {
+	Luminaires []Summary `json:"luminaires"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+// ListOptions narrows List; the zero value lists every luminaire.
+type ListOptions struct {
+	// Filter is a filter expression such as "LED, cct=3000, flux > 5000 lm".
+	Filter string
+	// State keeps luminaires in one workflow state, such as published.
+	State string
+	// Limit is the page size; zero means no limit, or the server's default
+	// page size with a Cursor.
+	Limit  int
+	Cursor string
+}
+
+func (o ListOptions) query() url.Values {
+	q := url.Values{}
+	set(q, "filter", o.Filter)
+	set(q, "state", o.State)
+	set(q, "cursor", o.Cursor)
+	if o.Limit > 0 {
+		q.Set("limit", strconv.Itoa(o.Limit))
+	}
+	return q
+}
+
+// UploadResult is the answer to Upload. Status is "uploaded" with
+// LuminaireID set, or "metadata_required" with Missing and FileHash set.
+type UploadResult struct {
+	Status      string   `json:"status"`
+	LuminaireID int64    `json:"luminaire_id,omitempty"`
+	Missing     []string `json:"missing,omitempty"`
+	FileHash    string   `json:"file_hash,omitempty"`
+}
+
+// ExportOptions are the options of Export and ExportCollection; empty
+// fields leave the server's defaults.
+type ExportOptions struct {
+	// Format is ies, ldt or cie; ExportCollection also takes revit.
+	Format string
+	// Profile applies a saved export profile.
+	Profile  string
+	Encoding string
+	// LineEnding is lf or crlf.
+	LineEnding string
+	// Condition, Component and Orientation export a stored variant of the
+	// luminaire instead of its measured distribution; Orientation also
+	// takes aimed.
+	Condition   string
+	Component   string
+	Orientation string
+	// Anonymize strips the manufacturer and other identifying fields.
+	Anonymize bool
+}
+
+func (o ExportOptions) query() url.Values {
+	q := url.Values{}
+	set(q, "format", o.Format)
+	set(q, "profile", o.Profile)
+	set(q, "encoding", o.Encoding)
+	set(q, "eol", o.LineEnding)
+	set(q, "condition", o.Condition)
+	set(q, "component", o.Component)
+	set(q, "orientation", o.Orientation)
+	if o.Anonymize {
+		q.Set("anonymize", "true")
+	}
+	return q
+}
+
+// set sets key to v unless v is empty.
+func set(q url.Values, key, v string) {
+	if v != "" {
+		q.Set(key, v)
+	}
+}
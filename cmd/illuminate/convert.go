@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"illuminate/internal/batch"
+	"illuminate/internal/logger"
+	"illuminate/internal/parser"
+)
+
+// runConvert converts photometric files, or every readable file under the
+// directories given, into one format under -o, keeping their paths relative
+// to the directory they were found in. Files are converted on -workers
+// workers; -sequential converts them one at a time instead. The run ends
+// with the throughput and fails when any file did.
+func runConvert(args []string) error {
+	flags := flag.NewFlagSet("convert", flag.ExitOnError)
+	format := flags.String("to", "ldt", "output format: ies, ldt or cie")
+	out := flags.String("o", "converted", "output directory")
+	workers := flags.Int("workers", 0, "files converted at once; 0 for one per CPU")
+	sequential := flags.Bool("sequential", false, "convert one file at a time")
+	forced := flags.String("parser", "", "read every file as this format (ies, ldt, cie, oxl or tm14)")
+	downgrade := flags.String("downgrade", "warn", "fields the format cannot carry: warn, fail or embed")
+	deterministic := flags.Bool("deterministic", false, "leave the conversion time out, so the same input gives the same bytes")
+	quiet := flags.Bool("q", false, "only report failures and the summary")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: illuminate convert [flags] file|dir ...")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return exitCode(2)
+	}
+	opts := batch.Options{
+		Format:       strings.ToLower(*format),
+		SourceFormat: strings.ToLower(*forced),
+		Workers:      *workers,
+		Write:        parser.DefaultWriteOptions(),
+	}
+	var err error
+	if opts.Write.Downgrade, err = parser.ParseDowngrade(*downgrade); err != nil {
+		return err
+	}
+	opts.Write.Provenance = parser.NewProvenance("")
+	if *deterministic {
+		opts.Write.Provenance = parser.Reproducible("", time.Time{})
+	}
+
+	files, err := convertFiles(flags.Args(), *out, opts.Format)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	report := func(r batch.Result) {
+		if r.Err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", r.File.Source, r.Err)
+			return
+		}
+		if !*quiet {
+			for _, issue := range r.Issues {
+				logger.Default.Warnf("%s: %s %s: %s", r.File.Source, issue.Field, issue.Effect, issue.Detail)
+			}
+		}
+	}
+	var stats batch.Stats
+	if *sequential {
+		stats, err = batch.ConvertSequential(files, opts, report)
+	} else {
+		stats, err = batch.Convert(ctx, files, opts, report)
+	}
+	fmt.Println(stats)
+	if err != nil {
+		return err
+	}
+	if stats.Failed > 0 {
+		return exitCode(1)
+	}
+	return nil
+}
+
+// convertFiles expands the arguments into conversions to format under out.
+// A file given directly lands at the top of out; files found under a
+// directory keep their path below it.
+func convertFiles(args []string, out, format string) ([]batch.File, error) {
+	var files []batch.File
+	target := func(rel string) string {
+		return filepath.Join(out, strings.TrimSuffix(rel, filepath.Ext(rel))+"."+format)
+	}
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, batch.File{Source: arg, Target: target(filepath.Base(arg))})
+			continue
+		}
+		err = filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if _, rerr := parser.GetReader(path); d.IsDir() || rerr != nil {
+				return nil
+			}
+			rel, err := filepath.Rel(arg, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, batch.File{Source: path, Target: target(rel)})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"illuminate/internal/logger"
+	"illuminate/internal/parser"
+	"illuminate/internal/synth"
+)
+
+func runGenerate(args []string) error {
+	def := synth.DefaultOptions()
+
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	dist := fs.String("dist", string(def.Distribution), "distribution: lambertian, narrow, batwing, street or all")
+	vstep := fs.Float64("vstep", def.VerticalStep, "vertical angle increment in degrees")
+	hstep := fs.Float64("hstep", def.HorizontalStep, "horizontal angle increment in degrees")
+	flux := fs.Float64("flux", def.Flux, "total luminous flux in lumens")
+	watts := fs.Float64("watts", def.InputWatts, "input power in watts")
+	beam := fs.Float64("beam", def.BeamAngle, "beam angle in degrees for the narrow distribution")
+	manufacturer := fs.String("manufacturer", def.Manufacturer, "manufacturer name")
+	out := fs.String("o", "", "output file; the extension selects the format")
+	dir := fs.String("dir", ".", "output directory when -dist=all")
+	format := fs.String("format", "ies", "output format when -dist=all: ies, ldt or cie")
+	eol := fs.String("eol", "lf", "line ending: lf or crlf")
+	enc := fs.String("encoding", "utf-8", "output encoding: utf-8, windows-1252 or iso-8859-1")
+	comma := fs.Bool("comma", false, "write LDT numbers with a decimal comma")
+	lineLength := fs.Int("line-length", 0, "maximum IES line length; 80 for legacy tools, 0 for the LM-63 limit")
+	downgrade := fs.String("downgrade", "warn", "fields the format cannot carry: warn, fail or embed")
+	lampCount := fs.Int("lamp-count", 0, "LDT lamp count; 0 for 1")
+	lampType := fs.String("lamp-type", "", "LDT lamp type when the source has none")
+	lampCCT := fs.String("lamp-cct", "", "LDT colour appearance when the source has no CCT, e.g. 4000K")
+	lampCRI := fs.String("lamp-cri", "", "LDT colour rendering group when the source has no CRI, e.g. 1B")
+	numbers := fs.String("numbers", "fixed", "angle and candela values: fixed or shortest")
+	decimals := fs.Int("decimals", 0, "decimals of fixed values; 0 for the format's own")
+	trimZeros := fs.Bool("trim-zeros", false, "drop trailing zeros of fixed values")
+	width := fs.Int("column-width", 0, "right-align angle and candela values in columns this wide")
+	deterministic := fs.Bool("deterministic", false, "leave the generation time out, so the same flags give the same bytes")
+	fs.Parse(args)
+
+	writeOpts := parser.WriteOptions{MaxLineLength: *lineLength, UseCommaDecimal: *comma}
+	writeOpts.LampSet = parser.LampSet{Count: *lampCount, Type: *lampType, ColorTemp: *lampCCT, CRIGroup: *lampCRI}
+	// Synthetic distributions have no source file to point back to.
+	writeOpts.Provenance = parser.NewProvenance("")
+	if *deterministic {
+		writeOpts.Provenance = parser.Reproducible("", time.Time{})
+	}
+	var err error
+	if writeOpts.LineEnding, err = parser.ParseLineEnding(*eol); err != nil {
+		return err
+	}
+	if writeOpts.Encoding, err = parser.ParseEncoding(*enc); err != nil {
+		return err
+	}
+	if writeOpts.Numbers.Style, err = parser.ParseNumberStyle(*numbers); err != nil {
+		return err
+	}
+	writeOpts.Numbers.Decimals, writeOpts.Numbers.TrimZeros, writeOpts.Numbers.Width = *decimals, *trimZeros, *width
+	if writeOpts.Downgrade, err = parser.ParseDowngrade(*downgrade); err != nil {
+		return err
+	}
+
+	opts := synth.Options{
+		VerticalStep:   *vstep,
+		HorizontalStep: *hstep,
+		Flux:           *flux,
+		InputWatts:     *watts,
+		BeamAngle:      *beam,
+		Manufacturer:   *manufacturer,
+	}
+
+	if *dist == "all" {
+		if err := os.MkdirAll(*dir, 0o755); err != nil {
+			return err
+		}
+		for _, d := range synth.Distributions() {
+			opts.Distribution = d
+			path := filepath.Join(*dir, fmt.Sprintf("synth_%s.%s", d, *format))
+			if err := generateFile(opts, writeOpts, path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if *out == "" {
+		return fmt.Errorf("-o is required unless -dist=all")
+	}
+	opts.Distribution = synth.Distribution(*dist)
+	return generateFile(opts, writeOpts, *out)
+}
+
+func generateFile(opts synth.Options, writeOpts parser.WriteOptions, path string) error {
+	p, err := parser.GetParser(path)
+	if err != nil {
+		return err
+	}
+
+	lum, err := synth.Generate(opts)
+	if err != nil {
+		return err
+	}
+
+	if err := parser.WriteFile(p, lum, path, writeOpts); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+
+	logger.Default.Infof("generated %s distribution: %s", opts.Distribution, path)
+	return nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"illuminate/internal/database"
+	"illuminate/internal/parser"
+	"illuminate/internal/server"
+)
+
+// importSniffSize is how much of a file without a photometric extension is
+// read to tell whether it is one anyway.
+const importSniffSize = 64 << 10
+
+// runImport migrates a folder archive into the catalog in BLUEPRINT_DB_URL:
+// every photometric file under -dir, or also below it with -recursive, is
+// imported as a catalog archive entry would be, so the stored import
+// profiles apply. Files are recognized by their extension or, lacking one,
+// by their content. A file already in the catalog is reported as a duplicate
+// rather than stored twice. Each file gets a row in the -report CSV; the run
+// fails when any file did.
+func runImport(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	dir := flags.String("dir", "", "directory to import")
+	recursive := flags.Bool("recursive", false, "also import the directories below -dir")
+	reportPath := flags.String("report", "import-report.csv", "CSV report of every file imported")
+	quiet := flags.Bool("q", false, "only report failures and the summary")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: illuminate import -dir /archive [-recursive] [flags]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if *dir == "" || flags.NArg() > 0 {
+		flags.Usage()
+		return exitCode(2)
+	}
+	files, err := importFiles(*dir, *recursive)
+	if err != nil {
+		return err
+	}
+
+	out, err := os.Create(*reportPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	report := csv.NewWriter(out)
+	report.Write([]string{"path", "status", "luminaire_id", "source_format", "error"})
+
+	db := database.New()
+	defer db.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	counts := map[string]int{}
+	for _, file := range files {
+		if ctx.Err() != nil {
+			break
+		}
+		res := importFile(ctx, db, file)
+		counts[res.Status]++
+		row := []string{file.path, res.Status, "", file.sourceFormat, ""}
+		if res.LuminaireID != 0 {
+			row[2] = strconv.FormatInt(res.LuminaireID, 10)
+		}
+		if res.Err != nil {
+			row[4] = res.Err.Error()
+			fmt.Fprintf(os.Stderr, "%s: %v\n", file.path, res.Err)
+		} else if !*quiet {
+			fmt.Printf("%s: %s %d\n", file.path, res.Status, res.LuminaireID)
+		}
+		report.Write(row)
+	}
+	report.Flush()
+	if err := report.Error(); err != nil {
+		return err
+	}
+
+	fmt.Printf("%d files: %d uploaded, %d duplicates, %d in the trash, %d failed; report in %s\n",
+		len(files), counts["uploaded"], counts["duplicate"], counts["trashed"], counts["failed"], *reportPath)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if counts["failed"] > 0 {
+		return exitCode(1)
+	}
+	return nil
+}
+
+// importCandidate is a photometric file found by importFiles, with the
+// format its content showed when its name does not say.
+type importCandidate struct {
+	path         string
+	sourceFormat string
+}
+
+// importFiles lists the photometric files in dir, and below it when
+// recursive is set, skipping hidden files and directories.
+func importFiles(dir string, recursive bool) ([]importCandidate, error) {
+	var files []importCandidate
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && (!recursive || strings.HasPrefix(d.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		if _, err := parser.GetReader(path); err == nil {
+			files = append(files, importCandidate{path: path})
+			return nil
+		}
+		format, err := sniffFile(path)
+		if err != nil {
+			return err
+		}
+		if format != "" {
+			files = append(files, importCandidate{path: path, sourceFormat: format})
+		}
+		return nil
+	})
+	return files, err
+}
+
+// sniffFile returns the format the start of the file at path shows, or ""
+// when it does not clearly look like a photometric file.
+func sniffFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	head := make([]byte, importSniffSize)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	// Bare numbers fit CIE, so a guess is not enough to import a file
+	// whose name does not say what it is.
+	guesses := parser.SniffFormat(head[:n])
+	if len(guesses) == 0 || guesses[0].Confidence < parser.ConfidenceInferred {
+		return "", nil
+	}
+	return guesses[0].Format, nil
+}
+
+// importFile reads and imports one file.
+func importFile(ctx context.Context, db database.Service, file importCandidate) server.ImportResult {
+	data, err := os.ReadFile(file.path)
+	if err != nil {
+		return server.ImportResult{Status: "failed", Err: err}
+	}
+	return server.ImportFile(ctx, db.GetDB(), filepath.Base(file.path), file.sourceFormat, data)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"illuminate/internal/parser"
+	"illuminate/internal/validate"
+)
+
+// runLint validates photometric files, or every supported file under the
+// directories given, and fails when any has an error, or with
+// -fail-on=warning any warning. A -profile import profile adjusts the
+// metadata first, as it would on upload; -parser reads every file as one
+// format, whatever its extension.
+func runLint(args []string) error {
+	return lint(os.Stdout, args)
+}
+
+// lint runs the lint command with args, writing the report to w.
+func lint(w io.Writer, args []string) error {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	format := fs.String("format", "text", "output format: text, json, sarif or junit")
+	failOn := fs.String("fail-on", "error", "lowest severity that fails the run: error or warning")
+	profilePath := fs.String("profile", "", "import profile JSON to apply before validating")
+	forced := fs.String("parser", "", "read every file as this format (ies, ldt, cie, oxl or tm14)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: illuminate lint [flags] file|dir ...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitCode(2)
+	}
+	if *failOn != validate.SeverityError && *failOn != validate.SeverityWarning {
+		return fmt.Errorf("-fail-on must be error or warning")
+	}
+	write, ok := lintWriters[*format]
+	if !ok {
+		return fmt.Errorf("unknown format %q: use text, json, sarif or junit", *format)
+	}
+	sourceFormat := strings.ToLower(*forced)
+	if sourceFormat != "" {
+		if _, err := parser.GetReader("source." + sourceFormat); err != nil {
+			return err
+		}
+	}
+	var profile *parser.ImportProfile
+	if *profilePath != "" {
+		data, err := os.ReadFile(*profilePath)
+		if err != nil {
+			return err
+		}
+		profile = &parser.ImportProfile{}
+		if err := json.Unmarshal(data, profile); err != nil {
+			return fmt.Errorf("%s: %w", *profilePath, err)
+		}
+		if err := profile.Validate(); err != nil {
+			return fmt.Errorf("%s: %w", *profilePath, err)
+		}
+	}
+
+	files, err := lintFiles(fs.Args())
+	if err != nil {
+		return err
+	}
+	results := make([]validate.FileResult, len(files))
+	failed := false
+	for i, file := range files {
+		results[i] = lintFile(file, sourceFormat, profile)
+		for _, issue := range results[i].Issues {
+			if issue.Severity == validate.SeverityError || *failOn == validate.SeverityWarning {
+				failed = true
+			}
+		}
+	}
+
+	if err := write(w, results); err != nil {
+		return err
+	}
+	if failed {
+		return exitCode(1)
+	}
+	return nil
+}
+
+// lintFiles expands directories into the readable files below them.
+func lintFiles(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		err = filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if _, rerr := parser.GetReader(path); !d.IsDir() && rerr == nil {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func lintFile(file, sourceFormat string, profile *parser.ImportProfile) validate.FileResult {
+	res := validate.FileResult{File: filepath.ToSlash(file)}
+	sourceName := file
+	if sourceFormat != "" {
+		sourceName = strings.TrimSuffix(file, filepath.Ext(file)) + "." + sourceFormat
+	}
+	p, err := parser.GetReader(sourceName)
+	if err == nil {
+		lum, perr := p.Parse(file)
+		if perr == nil {
+			lum.Metadata.OriginalFilename = filepath.Base(file)
+			if profile != nil && strings.EqualFold(strings.TrimSpace(lum.Metadata.Manufacturer), strings.TrimSpace(profile.Manufacturer)) {
+				profile.Apply(&lum.Metadata)
+			}
+			res.Result = validate.Check(lum, nil)
+			return res
+		}
+		err = perr
+	}
+	res.Result = validate.ParseFailure(err, nil)
+	return res
+}
+
+var lintWriters = map[string]func(io.Writer, []validate.FileResult) error{
+	"text":  writeLintText,
+	"json":  writeLintJSON,
+	"sarif": validate.WriteSARIF,
+	"junit": validate.WriteJUnit,
+}
+
+func writeLintText(w io.Writer, results []validate.FileResult) error {
+	counts := map[string]int{}
+	for _, r := range results {
+		for _, issue := range r.Issues {
+			fmt.Fprintf(w, "%s: %s: %s [%s]\n", r.File, issue.Severity, issue.Message, issue.Rule)
+			counts[issue.Severity]++
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d files, %d errors, %d warnings\n",
+		len(results), counts[validate.SeverityError], counts[validate.SeverityWarning])
+	return err
+}
+
+func writeLintJSON(w io.Writer, results []validate.FileResult) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(results)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+// TestLintExitStatus lints a clean, a warning and a broken file: only errors
+// fail the run, unless -fail-on=warning.
+func TestLintExitStatus(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		args   []string
+		status int
+		report string
+	}{
+		{"clean", []string{"-profile", "testdata/lint/profile.json", "testdata/lint/downlight.ldt"}, 0,
+			"1 files, 0 errors, 0 warnings"},
+		{"warning", []string{"testdata/lint/downlight.ldt"}, 0,
+			"testdata/lint/downlight.ldt: warning: catalog number is missing [identity]"},
+		{"warning fails", []string{"-fail-on", "warning", "testdata/lint/downlight.ldt"}, 1,
+			"1 files, 0 errors, 1 warnings"},
+		{"error", []string{"testdata/lint/broken.ies"}, 1,
+			"testdata/lint/broken.ies: error: invalid IES file"},
+		{"directory", []string{"testdata/lint"}, 1,
+			"2 files, 1 errors, 1 warnings"},
+		{"no files", nil, 2, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := lint(&out, tc.args)
+			status := 0
+			var code exitCode
+			if errors.As(err, &code) {
+				status = int(code)
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if status != tc.status {
+				t.Errorf("exit status %d, want %d", status, tc.status)
+			}
+			if !strings.Contains(out.String(), tc.report) {
+				t.Errorf("report does not contain %q:\n%s", tc.report, out.String())
+			}
+		})
+	}
+}
+
+// TestLintSARIF compares the SARIF report of the lint testdata with
+// testdata/lint.sarif; run with -update to accept a change.
+func TestLintSARIF(t *testing.T) {
+	var out bytes.Buffer
+	if err := lint(&out, []string{"-format", "sarif", "testdata/lint/downlight.ldt", "testdata/lint/broken.ies"}); err == nil {
+		t.Error("a file with an error passed")
+	}
+	const golden = "testdata/lint.sarif"
+	if *update {
+		if err := os.WriteFile(golden, out.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("missing golden file (run with -update): %v", err)
+	}
+	if !bytes.Equal(out.Bytes(), want) {
+		t.Errorf("report differs from %s (run with -update to accept):\n%s", golden, out.String())
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"illuminate/internal/logger"
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands = []command{
+	{"generate", "generate synthetic photometric files", runGenerate},
+	{"publish", "render the catalog into a static site", runPublish},
+	{"lint", "validate photometric files, for CI", runLint},
+	{"convert", "convert many photometric files at once", runConvert},
+	{"seed", "load sample luminaires into an empty catalog", runSeed},
+	{"import", "import a folder archive of photometric files", runImport},
+}
+
+// exitCode ends the program with that status without logging an error, for
+// commands whose output already says what went wrong.
+type exitCode int
+
+func (c exitCode) Error() string { return fmt.Sprintf("exit status %d", int(c)) }
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: illuminate <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "commands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.usage)
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	for _, c := range commands {
+		if c.name == os.Args[1] {
+			err := c.run(os.Args[2:])
+			var code exitCode
+			if errors.As(err, &code) {
+				os.Exit(int(code))
+			}
+			if err != nil {
+				logger.Default.Errorf("%s: %v", c.name, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	usage()
+	os.Exit(2)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+
+	"illuminate/internal/database"
+	"illuminate/internal/publish"
+)
+
+// runPublish renders the catalog in BLUEPRINT_DB_URL into a static site.
+func runPublish(args []string) error {
+	def := publish.DefaultOptions()
+
+	fs := flag.NewFlagSet("publish", flag.ExitOnError)
+	out := fs.String("o", "site", "output directory")
+	title := fs.String("title", def.Title, "catalog title")
+	formats := fs.String("formats", strings.Join(def.Formats, ","), "comma-separated download formats")
+	manufacturer := fs.String("manufacturer", "", "only publish this manufacturer")
+	state := fs.String("state", os.Getenv("EXPORT_REQUIRE_STATE"), "only publish luminaires at least this far along the review workflow (approved, published)")
+	fs.Parse(args)
+
+	if err := os.MkdirAll(*out, 0o755); err != nil {
+		return err
+	}
+
+	var minState database.WorkflowState
+	if *state != "" {
+		var err error
+		if minState, err = database.ParseWorkflowState(*state); err != nil {
+			return err
+		}
+	}
+
+	db := database.New()
+	defer db.Close()
+
+	_, err := publish.Build(db.GetDB(), *out, publish.Options{
+		Title:        *title,
+		Formats:      strings.Split(*formats, ","),
+		Manufacturer: *manufacturer,
+		MinState:     minState,
+	})
+	return err
+}
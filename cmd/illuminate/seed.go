@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"illuminate/internal/database"
+	"illuminate/internal/server"
+)
+
+// runSeed loads the bundled sample luminaires into the empty catalog in
+// BLUEPRINT_DB_URL.
+func runSeed(args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ExitOnError)
+	fs.Parse(args)
+
+	db := database.New()
+	defer db.Close()
+
+	n, err := server.Seed(context.Background(), db.GetDB())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("seeded %d sample luminaires\n", n)
+	return nil
+}
@@ -0,0 +1,263 @@
+// Package batch converts large sets of photometric files offline, for bulk
+// jobs of tens of thousands of files where converting them one at a time
+// leaves most of the machine idle.
+package batch
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"illuminate/internal/database"
+	"illuminate/internal/parser"
+	"illuminate/internal/worker"
+)
+
+// File is one conversion of a batch: the file to read and the path to
+// write.
+type File struct {
+	Source string
+	Target string
+}
+
+// Options configures a batch.
+type Options struct {
+	// Format is the extension of the format written: ies, ldt or cie.
+	Format string
+	// SourceFormat reads every file as this format, whatever its
+	// extension, as the parser= upload option does; empty goes by the
+	// extension.
+	SourceFormat string
+	// Write is applied to every file. A provenance records each file's
+	// own source hash.
+	Write parser.WriteOptions
+	// Workers is the number of files converted at once; 0 means one per
+	// CPU.
+	Workers int
+}
+
+// Result is the outcome of one file.
+type Result struct {
+	File File
+	// Issues is what the target format dropped, approximated or embedded.
+	Issues []parser.CompatibilityIssue
+	Err    error
+}
+
+// Stats summarizes a batch.
+type Stats struct {
+	Files   int           `json:"files"`
+	Failed  int           `json:"failed"`
+	Bytes   int64         `json:"bytes"` // read from the sources
+	Elapsed time.Duration `json:"elapsed"`
+}
+
+// FilesPerSecond is the throughput of the batch.
+func (s Stats) FilesPerSecond() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Files) / s.Elapsed.Seconds()
+}
+
+func (s Stats) String() string {
+	return fmt.Sprintf("%d files, %d failed, %.1f MB in %s (%.0f files/s)",
+		s.Files, s.Failed, float64(s.Bytes)/1e6, s.Elapsed.Round(time.Millisecond), s.FilesPerSecond())
+}
+
+// maxPooledBuffer bounds the output buffers kept for reuse, so one huge
+// file does not pin its buffer for the rest of the batch.
+const maxPooledBuffer = 4 << 20
+
+var buffers = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// converter holds what every file of a batch shares.
+type converter struct {
+	opts   Options
+	target parser.Parser
+}
+
+func newConverter(opts Options) (*converter, error) {
+	target, err := parser.GetParser("out." + strings.TrimPrefix(opts.Format, "."))
+	if err != nil {
+		return nil, err
+	}
+	if err := opts.Write.Validate(); err != nil {
+		return nil, err
+	}
+	if opts.SourceFormat != "" {
+		if _, err := parser.GetReader("source." + opts.SourceFormat); err != nil {
+			return nil, err
+		}
+	}
+	return &converter{opts: opts, target: target}, nil
+}
+
+// reader returns the reader for source.
+func (c *converter) reader(source string) (parser.Reader, error) {
+	if c.opts.SourceFormat != "" {
+		return parser.GetReader("source." + c.opts.SourceFormat)
+	}
+	return parser.GetReader(source)
+}
+
+// writeOptions are the options for a file with sourceHash.
+func (c *converter) writeOptions(sourceHash string) parser.WriteOptions {
+	opts := c.opts.Write
+	if opts.Provenance != nil {
+		p := *opts.Provenance
+		p.SourceHash = sourceHash
+		opts.Provenance = &p
+	}
+	return opts
+}
+
+// convert converts one file through a mapped source and a pooled output
+// buffer, and returns the size of the source.
+func (c *converter) convert(f File) (int64, []parser.CompatibilityIssue, error) {
+	r, err := c.reader(f.Source)
+	if err != nil {
+		return 0, nil, err
+	}
+	data, release, err := mapFile(f.Source)
+	if err != nil {
+		return 0, nil, err
+	}
+	lum, err := r.ParseReader(bytes.NewReader(data), f.Source)
+	size := int64(len(data))
+	// Readers copy what they keep, so the mapping can go before writing.
+	release()
+	if err != nil {
+		return size, nil, err
+	}
+
+	buf := buffers.Get().(*bytes.Buffer)
+	defer func() {
+		if buf.Cap() <= maxPooledBuffer {
+			buf.Reset()
+			buffers.Put(buf)
+		}
+	}()
+	buf.Reset()
+	issues, err := parser.ConvertTo(buf, c.target, lum, c.writeOptions(lum.Metadata.FileHash))
+	if err != nil {
+		return size, issues, err
+	}
+	return size, issues, os.WriteFile(f.Target, buf.Bytes(), 0o644)
+}
+
+// makeDirs creates the directories of every target once, rather than per
+// file.
+func makeDirs(files []File) error {
+	made := map[string]bool{}
+	for _, f := range files {
+		dir := filepath.Dir(f.Target)
+		if made[dir] {
+			continue
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+		made[dir] = true
+	}
+	return nil
+}
+
+// Convert converts files on a pool of opts.Workers workers. Each source is
+// memory-mapped where the platform allows and parsed from the mapping, and output
+// is rendered into reused buffers. report, when not nil, is called with
+// every result as it completes, one call at a time. A failed file does not
+// stop the batch; Convert only fails on bad options or when ctx is done.
+func Convert(ctx context.Context, files []File, opts Options, report func(Result)) (Stats, error) {
+	c, err := newConverter(opts)
+	if err != nil {
+		return Stats{}, err
+	}
+	if err := makeDirs(files); err != nil {
+		return Stats{}, err
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	pool := worker.NewPool(workers, workers*4)
+	defer pool.Close()
+	group := pool.Group(0)
+
+	var mu sync.Mutex
+	var stats Stats
+	start := time.Now()
+	for _, f := range files {
+		err := group.Go(ctx, func() {
+			size, issues, err := c.convert(f)
+			mu.Lock()
+			defer mu.Unlock()
+			stats.Files++
+			stats.Bytes += size
+			if err != nil {
+				stats.Failed++
+			}
+			if report != nil {
+				report(Result{File: f, Issues: issues, Err: err})
+			}
+		})
+		if err != nil {
+			group.Wait()
+			stats.Elapsed = time.Since(start)
+			return stats, err
+		}
+	}
+	group.Wait()
+	stats.Elapsed = time.Since(start)
+	return stats, nil
+}
+
+// ConvertSequential converts files one at a time the way a single upload
+// or lint run reads and writes them: open, parse, render and write each in
+// turn. It is the baseline Convert is measured against, and the path to
+// use when ordering matters more than speed.
+func ConvertSequential(files []File, opts Options, report func(Result)) (Stats, error) {
+	c, err := newConverter(opts)
+	if err != nil {
+		return Stats{}, err
+	}
+	if err := makeDirs(files); err != nil {
+		return Stats{}, err
+	}
+
+	var stats Stats
+	start := time.Now()
+	for _, f := range files {
+		stats.Files++
+		if info, err := os.Stat(f.Source); err == nil {
+			stats.Bytes += info.Size()
+		}
+		var issues []parser.CompatibilityIssue
+		r, err := c.reader(f.Source)
+		if err == nil {
+			var lum *database.ParsedLuminaire
+			if lum, err = r.Parse(f.Source); err == nil {
+				var data []byte
+				data, issues, err = parser.Convert(c.target, lum, c.writeOptions(lum.Metadata.FileHash))
+				if err == nil {
+					err = os.WriteFile(f.Target, data, 0o644)
+				}
+			}
+		}
+		if err != nil {
+			stats.Failed++
+		}
+		if report != nil {
+			report(Result{File: f, Issues: issues, Err: err})
+		}
+	}
+	stats.Elapsed = time.Since(start)
+	return stats, nil
+}
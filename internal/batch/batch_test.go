@@ -0,0 +1,140 @@
+package batch
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"illuminate/internal/logger"
+	"illuminate/internal/parser"
+	"illuminate/internal/synth"
+)
+
+// sources writes n synthetic IES files into dir and returns them as
+// conversions to EULUMDAT files in out.
+func sources(t testing.TB, dir, out string, n int) []File {
+	t.Helper()
+	p := parser.NewIESParser()
+	files := make([]File, n)
+	for i := range n {
+		opts := synth.DefaultOptions()
+		opts.Distribution = synth.Distributions()[i%len(synth.Distributions())]
+		opts.Flux = float64(500 + i)
+		opts.VerticalStep, opts.HorizontalStep = 2.5, 15
+		lum, err := synth.Generate(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := fmt.Sprintf("lum%04d", i)
+		files[i] = File{Source: filepath.Join(dir, name+".ies"), Target: filepath.Join(out, name+".ldt")}
+		if err := p.Write(lum, files[i].Source); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return files
+}
+
+// quietLogs drops the per-file logging of the parsers and writers.
+func quietLogs(t testing.TB) {
+	level := logger.Default.GetLevel()
+	logger.Default.SetLevel(log.ErrorLevel)
+	t.Cleanup(func() { logger.Default.SetLevel(level) })
+}
+
+// TestConvert converts the same files with both paths and expects the same
+// bytes, and a broken file reported without stopping the batch.
+func TestConvert(t *testing.T) {
+	quietLogs(t)
+	dir := t.TempDir()
+	files := sources(t, dir, filepath.Join(dir, "pipeline"), 20)
+	broken := filepath.Join(dir, "broken.ies")
+	os.WriteFile(broken, []byte("IESNA:LM-63-2002\nTILT=NONE\n1 1000"), 0o644)
+	empty := filepath.Join(dir, "empty.ies")
+	os.WriteFile(empty, nil, 0o644)
+	files = append(files,
+		File{Source: broken, Target: filepath.Join(dir, "pipeline", "broken.ldt")},
+		File{Source: empty, Target: filepath.Join(dir, "pipeline", "empty.ldt")})
+
+	opts := Options{Format: "ldt", Workers: 4, Write: parser.DefaultWriteOptions()}
+	opts.Write.Provenance = parser.Reproducible("", time.Time{})
+	var mu sync.Mutex
+	failed := map[string]bool{}
+	stats, err := Convert(context.Background(), files, opts, func(r Result) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Err != nil {
+			failed[filepath.Base(r.File.Source)] = true
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Files != 22 || stats.Failed != 2 || !failed["broken.ies"] || !failed["empty.ies"] {
+		t.Errorf("stats %+v, failed %v", stats, failed)
+	}
+
+	sequential := make([]File, len(files))
+	for i, f := range files {
+		sequential[i] = File{Source: f.Source, Target: strings.Replace(f.Target, "pipeline", "sequential", 1)}
+	}
+	if stats, err := ConvertSequential(sequential, opts, nil); err != nil || stats.Failed != 2 {
+		t.Fatalf("sequential: %+v, %v", stats, err)
+	}
+	for i := range 20 {
+		a, err := os.ReadFile(files[i].Target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := os.ReadFile(sequential[i].Target)
+		if !bytes.Equal(a, b) {
+			t.Errorf("%s differs between the paths", filepath.Base(files[i].Target))
+		}
+		if !bytes.Contains(a, []byte("src:")) {
+			t.Errorf("%s has no source hash in its provenance", filepath.Base(files[i].Target))
+		}
+	}
+
+	if _, err := Convert(context.Background(), files, Options{Format: "pdf"}, nil); err == nil {
+		t.Error("converted to an unknown format")
+	}
+}
+
+// BenchmarkBatch converts a directory of medium-resolution files one at a
+// time and through the pipeline, reporting files per second:
+//
+//	go test ./internal/batch -bench Batch -benchtime 3x
+func BenchmarkBatch(b *testing.B) {
+	quietLogs(b)
+	dir := b.TempDir()
+	files := sources(b, dir, filepath.Join(dir, "out"), 500)
+	opts := Options{Format: "ldt", Write: parser.DefaultWriteOptions()}
+	for _, path := range []struct {
+		name string
+		run  func() (Stats, error)
+	}{
+		{"sequential", func() (Stats, error) { return ConvertSequential(files, opts, nil) }},
+		{"pipeline", func() (Stats, error) { return Convert(context.Background(), files, opts, nil) }},
+	} {
+		b.Run(path.name, func(b *testing.B) {
+			var total Stats
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				stats, err := path.run()
+				if err != nil || stats.Failed > 0 {
+					b.Fatalf("%+v, %v", stats, err)
+				}
+				total.Files += stats.Files
+				total.Elapsed += stats.Elapsed
+			}
+			b.ReportMetric(total.FilesPerSecond(), "files/s")
+		})
+	}
+}
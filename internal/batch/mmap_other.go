@@ -0,0 +1,11 @@
+//go:build !unix
+
+package batch
+
+import "os"
+
+// mapFile reads path into memory where mapping it is not supported.
+func mapFile(path string) (data []byte, release func(), err error) {
+	data, err = os.ReadFile(path)
+	return data, func() {}, err
+}
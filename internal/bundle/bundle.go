@@ -0,0 +1,168 @@
+// Package bundle writes ZIP exports that recipients can verify: every file is
+// listed with its SHA-256 checksum in SHA256SUMS, in the format sha256sum -c
+// reads, and SHA256SUMS is optionally signed with an Ed25519 key.
+package bundle
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	// SumsName is the checksum manifest inside every bundle.
+	SumsName = "SHA256SUMS"
+	// SignatureName holds the raw 64-byte Ed25519 signature of SumsName,
+	// as openssl pkeyutl -sign -rawin writes it.
+	SignatureName = SumsName + ".sig"
+)
+
+// Writer is a ZIP writer that records a checksum for every file added.
+type Writer struct {
+	zw    *zip.Writer
+	sums  bytes.Buffer
+	names map[string]bool
+	key   ed25519.PrivateKey
+}
+
+// NewWriter starts a bundle on w. A nil key leaves the bundle unsigned.
+func NewWriter(w io.Writer, key ed25519.PrivateKey) *Writer {
+	return &Writer{zw: zip.NewWriter(w), names: map[string]bool{}, key: key}
+}
+
+// Add writes one file to the bundle.
+func (b *Writer) Add(name string, data []byte) error {
+	if name == SumsName || name == SignatureName || b.names[name] {
+		return fmt.Errorf("bundle: duplicate file name %q", name)
+	}
+	if strings.ContainsAny(name, "\r\n") {
+		return fmt.Errorf("bundle: file name %q spans lines", name)
+	}
+	w, err := b.zw.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	b.names[name] = true
+	sum := sha256.Sum256(data)
+	fmt.Fprintf(&b.sums, "%s  %s\n", hex.EncodeToString(sum[:]), name)
+	return nil
+}
+
+// Close writes SHA256SUMS, its signature when there is a key, and the ZIP
+// directory.
+func (b *Writer) Close() error {
+	w, err := b.zw.Create(SumsName)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(b.sums.Bytes()); err != nil {
+		return err
+	}
+	if b.key != nil {
+		w, err := b.zw.Create(SignatureName)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(ed25519.Sign(b.key, b.sums.Bytes())); err != nil {
+			return err
+		}
+	}
+	return b.zw.Close()
+}
+
+// Verify checks every checksum of a bundle and, when pub is not nil, the
+// signature of SHA256SUMS. It returns the names of the verified files.
+func Verify(r io.ReaderAt, size int64, pub ed25519.PublicKey) ([]string, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	files := map[string][]byte{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		files[f.Name] = data
+	}
+
+	sums, ok := files[SumsName]
+	if !ok {
+		return nil, errors.New("bundle has no " + SumsName)
+	}
+	if pub != nil {
+		sig, ok := files[SignatureName]
+		if !ok {
+			return nil, errors.New("bundle is not signed")
+		}
+		if !ed25519.Verify(pub, sums, sig) {
+			return nil, errors.New("signature does not match " + SumsName)
+		}
+	}
+
+	var names []string
+	for _, line := range strings.Split(strings.TrimSuffix(string(sums), "\n"), "\n") {
+		want, name, ok := strings.Cut(line, "  ")
+		if !ok {
+			return nil, fmt.Errorf("malformed %s line %q", SumsName, line)
+		}
+		data, ok := files[name]
+		if !ok {
+			return nil, fmt.Errorf("%s is listed but missing", name)
+		}
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != want {
+			return nil, fmt.Errorf("%s: checksum mismatch", name)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// LoadKey reads an Ed25519 private key from a PKCS#8 PEM file, as written by
+// openssl genpkey -algorithm ed25519.
+func LoadKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM block", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	ed, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an Ed25519 key", path)
+	}
+	return ed, nil
+}
+
+// PublicKeyPEM encodes the public half of key for recipients.
+func PublicKeyPEM(key ed25519.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
@@ -0,0 +1,94 @@
+package bundle
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func build(t *testing.T, key ed25519.PrivateKey, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	b := NewWriter(&buf, key)
+	for name, content := range files {
+		if err := b.Add(name, []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestBundle(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := build(t, key, map[string]string{"1_a.ies": "IESNA:LM-63-2002\n", "2_b.ies": "IESNA:LM-63-2002\n"})
+
+	names, err := Verify(bytes.NewReader(data), int64(len(data)), pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 {
+		t.Errorf("verified %v", names)
+	}
+
+	other, _, _ := ed25519.GenerateKey(nil)
+	if _, err := Verify(bytes.NewReader(data), int64(len(data)), other); err == nil || !strings.Contains(err.Error(), "signature") {
+		t.Errorf("foreign key: %v", err)
+	}
+
+	unsigned := build(t, nil, map[string]string{"a.ies": "x"})
+	if _, err := Verify(bytes.NewReader(unsigned), int64(len(unsigned)), nil); err != nil {
+		t.Errorf("unsigned bundle: %v", err)
+	}
+	if _, err := Verify(bytes.NewReader(unsigned), int64(len(unsigned)), pub); err == nil {
+		t.Error("unsigned bundle passed a signature check")
+	}
+
+	// A recorded checksum that no longer matches stands for a file altered
+	// after export.
+	var buf bytes.Buffer
+	b := NewWriter(&buf, nil)
+	b.Add("a.ies", []byte("x"))
+	b.sums.Reset()
+	b.sums.WriteString("0000000000000000000000000000000000000000000000000000000000000000  a.ies\n")
+	b.Close()
+	if _, err := Verify(bytes.NewReader(buf.Bytes()), int64(buf.Len()), nil); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("tampered bundle: %v", err)
+	}
+
+	if err := NewWriter(&bytes.Buffer{}, nil).Add(SumsName, nil); err == nil {
+		t.Error("reserved name accepted")
+	}
+}
+
+func TestLoadKey(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(nil)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "signing.pem")
+	os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600)
+
+	loaded, err := LoadKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Equal(key) {
+		t.Error("loaded a different key")
+	}
+	pub, err := PublicKeyPEM(loaded)
+	if err != nil || !bytes.HasPrefix(pub, []byte("-----BEGIN PUBLIC KEY-----")) {
+		t.Errorf("public key: %v %s", err, pub)
+	}
+}
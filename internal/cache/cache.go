@@ -0,0 +1,193 @@
+package cache
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Backend stores opaque values by key. Implementations must be safe for
+// concurrent use.
+type Backend interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// Store is a Backend that can also claim keys and count, for state several
+// instances of a deployment share: idempotency keys, rate limit windows and
+// job locks.
+type Store interface {
+	Backend
+	// Add sets key only when it holds no value, and reports whether it did.
+	Add(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	// Incr adds one to the counter at key and returns the new count. A new
+	// counter starts at 1 and expires after ttl.
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error)
+	Delete(ctx context.Context, key string) error
+	// DeleteIf deletes key only while it holds value, as the owner of a
+	// lock releases it, and reports whether it did.
+	DeleteIf(ctx context.Context, key string, value []byte) (bool, error)
+	// ExpireIf makes key expire ttl from now only while it holds value, as
+	// the owner of a lock keeps it, and reports whether it did.
+	ExpireIf(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+}
+
+// LRU is an in-process Backend that evicts the least recently used entry once
+// it holds maxEntries values.
+type LRU struct {
+	mu         sync.Mutex
+	maxEntries int
+	ll         *list.List
+	items      map[string]*list.Element
+}
+
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+func NewLRU(maxEntries int) *LRU {
+	return &LRU{
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+func (c *LRU) Get(_ context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := c.lookup(key)
+	if entry == nil {
+		return nil, false, nil
+	}
+	return entry.value, true, nil
+}
+
+func (c *LRU) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var expires time.Time
+	if ttl > 0 {
+		expires = time.Now().Add(ttl)
+	}
+	c.set(key, value, expires)
+	return nil
+}
+
+func (c *LRU) Add(_ context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.lookup(key) != nil {
+		return false, nil
+	}
+	var expires time.Time
+	if ttl > 0 {
+		expires = time.Now().Add(ttl)
+	}
+	c.set(key, value, expires)
+	return true, nil
+}
+
+func (c *LRU) Incr(_ context.Context, key string, ttl time.Duration) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := int64(1)
+	var expires time.Time
+	if entry := c.lookup(key); entry != nil {
+		count, err := strconv.ParseInt(string(entry.value), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		n, expires = count+1, entry.expires
+	} else if ttl > 0 {
+		expires = time.Now().Add(ttl)
+	}
+	c.set(key, []byte(strconv.FormatInt(n, 10)), expires)
+	return n, nil
+}
+
+func (c *LRU) Delete(_ context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+	return nil
+}
+
+func (c *LRU) DeleteIf(_ context.Context, key string, value []byte) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := c.lookup(key)
+	if entry == nil || !bytes.Equal(entry.value, value) {
+		return false, nil
+	}
+	c.remove(c.items[key])
+	return true, nil
+}
+
+func (c *LRU) ExpireIf(_ context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := c.lookup(key)
+	if entry == nil || !bytes.Equal(entry.value, value) {
+		return false, nil
+	}
+	entry.expires = time.Now().Add(ttl)
+	return true, nil
+}
+
+// lookup returns the live entry at key, marking it used, or nil.
+func (c *LRU) lookup(key string) *lruEntry {
+	el, ok := c.items[key]
+	if !ok {
+		return nil
+	}
+	entry := el.Value.(*lruEntry)
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		c.remove(el)
+		return nil
+	}
+	c.ll.MoveToFront(el)
+	return entry
+}
+
+func (c *LRU) set(key string, value []byte, expires time.Time) {
+	if el, ok := c.items[key]; ok {
+		c.ll.MoveToFront(el)
+		entry := el.Value.(*lruEntry)
+		entry.value = value
+		entry.expires = expires
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&lruEntry{key: key, value: value, expires: expires})
+	for c.maxEntries > 0 && c.ll.Len() > c.maxEntries {
+		c.remove(c.ll.Back())
+	}
+}
+
+// Len reports the number of cached entries, including expired ones not yet
+// evicted.
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+func (c *LRU) remove(el *list.Element) {
+	c.ll.Remove(el)
+	delete(c.items, el.Value.(*lruEntry).key)
+}
@@ -0,0 +1,217 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"illuminate/internal/cache/redistest"
+	"illuminate/internal/parser"
+	"illuminate/internal/synth"
+)
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU(2)
+	c.Set(ctx, "a", []byte("1"), 0)
+	c.Set(ctx, "b", []byte("2"), 0)
+	c.Get(ctx, "a")
+	c.Set(ctx, "c", []byte("3"), 0)
+
+	if _, ok, _ := c.Get(ctx, "b"); ok {
+		t.Error("b should have been evicted")
+	}
+	for _, k := range []string{"a", "c"} {
+		if _, ok, _ := c.Get(ctx, k); !ok {
+			t.Errorf("%s should still be cached", k)
+		}
+	}
+}
+
+func TestParseCacheServesRepeatedContent(t *testing.T) {
+	ctx := context.Background()
+	lum, err := synth.Generate(synth.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := t.TempDir() + "/a.ies"
+	if err := parser.NewIESParser().Write(lum, path); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewParseCache(NewLRU(8), 0)
+	first, err := c.ParseFile(ctx, parser.NewIESParser(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := c.ParseFile(ctx, parser.NewIESParser(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hits, misses := c.Stats(); hits != 1 || misses != 1 {
+		t.Fatalf("hits=%d misses=%d, want 1 and 1", hits, misses)
+	}
+	if second.Metadata.FileHash != first.Metadata.FileHash || len(second.CandelaMatrix) != len(first.CandelaMatrix) {
+		t.Error("cached result differs from the parsed one")
+	}
+}
+
+// TestParseCacheKeyedByLimits parses the same content under other parser
+// limits and expects it parsed again, not served from the cache.
+func TestParseCacheKeyedByLimits(t *testing.T) {
+	ctx := context.Background()
+	lum, err := synth.Generate(synth.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := parser.Encode(parser.NewIESParser(), lum, parser.WriteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.SetLimits(parser.CurrentLimits())
+
+	c := NewParseCache(NewLRU(8), 0)
+	if _, err := c.Parse(ctx, parser.NewIESParser(), data, "a.ies"); err != nil {
+		t.Fatal(err)
+	}
+	tight := parser.CurrentLimits()
+	tight.MaxAngles = 2
+	parser.SetLimits(tight)
+	if _, err := c.Parse(ctx, parser.NewIESParser(), data, "a.ies"); !errors.Is(err, parser.ErrLimitExceeded) {
+		t.Errorf("parse under tighter limits = %v, want ErrLimitExceeded", err)
+	}
+	if hits, misses := c.Stats(); hits != 0 || misses != 2 {
+		t.Errorf("hits=%d misses=%d, want 0 and 2", hits, misses)
+	}
+}
+
+// testStore checks the claim, lock and counter semantics every Store
+// shares.
+func testStore(t *testing.T, s Store) {
+	ctx := context.Background()
+	if ok, err := s.Add(ctx, "claim", []byte("first"), time.Minute); err != nil || !ok {
+		t.Fatalf("first Add = %v, %v; want true", ok, err)
+	}
+	if ok, err := s.Add(ctx, "claim", []byte("second"), time.Minute); err != nil || ok {
+		t.Fatalf("second Add = %v, %v; want false", ok, err)
+	}
+	if v, _, _ := s.Get(ctx, "claim"); string(v) != "first" {
+		t.Errorf("claim holds %q, want the first value", v)
+	}
+	if err := s.Delete(ctx, "claim"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := s.Add(ctx, "claim", []byte("third"), time.Minute); !ok {
+		t.Error("a deleted key should be claimable again")
+	}
+
+	if ok, err := s.ExpireIf(ctx, "claim", []byte("other"), time.Millisecond); err != nil || ok {
+		t.Errorf("ExpireIf by another owner = %v, %v; want false", ok, err)
+	}
+	if ok, err := s.DeleteIf(ctx, "claim", []byte("other")); err != nil || ok {
+		t.Errorf("DeleteIf by another owner = %v, %v; want false", ok, err)
+	}
+	if ok, err := s.ExpireIf(ctx, "claim", []byte("third"), 50*time.Millisecond); err != nil || !ok {
+		t.Errorf("ExpireIf by the owner = %v, %v; want true", ok, err)
+	}
+	if ok, err := s.DeleteIf(ctx, "claim", []byte("third")); err != nil || !ok {
+		t.Errorf("DeleteIf by the owner = %v, %v; want true", ok, err)
+	}
+	if _, ok, _ := s.Get(ctx, "claim"); ok {
+		t.Error("the owner's DeleteIf left the key")
+	}
+
+	for want := int64(1); want <= 3; want++ {
+		if n, err := s.Incr(ctx, "count", 50*time.Millisecond); err != nil || n != want {
+			t.Fatalf("Incr = %d, %v; want %d", n, err, want)
+		}
+	}
+	time.Sleep(80 * time.Millisecond)
+	if n, _ := s.Incr(ctx, "count", time.Minute); n != 1 {
+		t.Errorf("an expired counter restarted at %d, want 1", n)
+	}
+}
+
+func TestLRUStore(t *testing.T) {
+	testStore(t, NewLRU(0))
+}
+
+func TestRedisStoreAndQueue(t *testing.T) {
+	srv, err := redistest.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	r, err := NewRedis(srv.URL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, r)
+
+	ctx := context.Background()
+	for _, job := range []string{"a", "b"} {
+		if err := r.Push(ctx, "jobs", []byte(job)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, want := range []string{"a", "b"} {
+		job, err := r.Pop(ctx, "jobs")
+		if err != nil || string(job) != want {
+			t.Fatalf("Pop = %q, %v; want %q", job, err, want)
+		}
+	}
+	if jobs, err := r.Processing(ctx, "jobs"); err != nil || len(jobs) != 2 || string(jobs[0]) != "b" {
+		t.Fatalf("Processing = %q, %v; want both jobs", jobs, err)
+	}
+	if ok, err := r.Done(ctx, "jobs", []byte("a")); err != nil || !ok {
+		t.Fatalf("Done = %v, %v", ok, err)
+	}
+	if ok, _ := r.Done(ctx, "jobs", []byte("a")); ok {
+		t.Error("a job was done twice")
+	}
+	if jobs, _ := r.Processing(ctx, "jobs"); len(jobs) != 1 || string(jobs[0]) != "b" {
+		t.Errorf("Processing after Done = %q", jobs)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if _, err := r.Pop(ctx, "jobs"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Pop on an empty queue = %v, want the context error", err)
+	}
+}
+
+func TestRedisPubSub(t *testing.T) {
+	srv, err := redistest.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	r, err := NewRedis(srv.URL())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	got := make(chan string, 2)
+	if err := r.Subscribe(ctx, "events", func(message []byte) { got <- string(message) }); err != nil {
+		t.Fatal(err)
+	}
+	for _, message := range []string{"a", "b"} {
+		if err := r.Publish(ctx, "events", []byte(message)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, want := range []string{"a", "b"} {
+		select {
+		case message := <-got:
+			if message != want {
+				t.Errorf("received %q, want %q", message, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%q was not received", want)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"fmt"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"illuminate/internal/database"
+	"illuminate/internal/logger"
+	"illuminate/internal/parser"
+)
+
+// parseKeyVersion is bumped whenever parser output or the cache key changes
+// shape, so results cached by an older build are never served.
+const parseKeyVersion = "v3"
+
+// ParseCache memoises parser output by the SHA-256 of the input bytes and the
+// parser that read them, so the same file uploaded, retried with metadata or
+// validated again is only parsed once. A nil *ParseCache parses every time.
+type ParseCache struct {
+	backend Backend
+	ttl     time.Duration
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+func NewParseCache(backend Backend, ttl time.Duration) *ParseCache {
+	return &ParseCache{backend: backend, ttl: ttl}
+}
+
+// NewParseCacheFromEnv uses Redis when PARSE_CACHE_REDIS_URL, or else the
+// shared REDIS_URL, is set and an in-memory LRU of PARSE_CACHE_SIZE entries
+// (default 256) otherwise. Entries live for PARSE_CACHE_TTL (default 1h). A
+// size of 0 disables the in-memory cache.
+func NewParseCacheFromEnv() *ParseCache {
+	ttl, err := time.ParseDuration(os.Getenv("PARSE_CACHE_TTL"))
+	if err != nil {
+		ttl = time.Hour
+	}
+
+	url := os.Getenv("PARSE_CACHE_REDIS_URL")
+	if url == "" {
+		url = os.Getenv("REDIS_URL")
+	}
+	if url != "" {
+		r, err := NewRedis(url)
+		if err == nil {
+			return NewParseCache(r, ttl)
+		}
+		logger.Default.Errorf("parse cache: redis unavailable, falling back to memory: %v", err)
+	}
+
+	size, err := strconv.Atoi(os.Getenv("PARSE_CACHE_SIZE"))
+	if err != nil || size < 0 {
+		size = 256
+	}
+	if size == 0 {
+		return nil
+	}
+	return NewParseCache(NewLRU(size), ttl)
+}
+
+// ParseFile parses path with p, serving a cached result when the file content
+// has been seen before. OriginalFilename is always set from path.
+func (c *ParseCache) ParseFile(ctx context.Context, p parser.Reader, path string) (*database.ParsedLuminaire, error) {
+	if c == nil {
+		return p.Parse(path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("open file: %w", err)
+	}
+	return c.Parse(ctx, p, data, path)
+}
+
+// Parse is ParseFile for content already in memory.
+func (c *ParseCache) Parse(ctx context.Context, p parser.Reader, data []byte, name string) (*database.ParsedLuminaire, error) {
+	if c == nil {
+		return p.ParseReader(bytes.NewReader(data), name)
+	}
+
+	key := fmt.Sprintf("parse:%s:%s:%T:%x", parseKeyVersion, limitsDigest(parser.CurrentLimits()), p, sha256.Sum256(data))
+
+	if b, ok, err := c.backend.Get(ctx, key); err != nil {
+		logger.Default.Warnf("parse cache get: %v", err)
+	} else if ok {
+		var lum database.ParsedLuminaire
+		if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&lum); err == nil {
+			c.hits.Add(1)
+			lum.Metadata.OriginalFilename = name
+			return &lum, nil
+		}
+	}
+	c.misses.Add(1)
+
+	lum, err := p.ParseReader(bytes.NewReader(data), name)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(lum); err != nil {
+		logger.Default.Warnf("parse cache encode: %v", err)
+		return lum, nil
+	}
+	if err := c.backend.Set(ctx, key, buf.Bytes(), c.ttl); err != nil {
+		logger.Default.Warnf("parse cache set: %v", err)
+	}
+	return lum, nil
+}
+
+// limitsDigest identifies the parser limits in effect, which decide whether
+// a file parses at all: a file rejected under tighter limits must not be
+// served as parsed from an entry made under looser ones, or the reverse.
+func limitsDigest(l parser.Limits) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%+v", l)))
+	return fmt.Sprintf("%x", sum[:8])
+}
+
+// Stats reports cache hits and misses since start-up.
+func (c *ParseCache) Stats() (hits, misses int64) {
+	if c == nil {
+		return 0, 0
+	}
+	return c.hits.Load(), c.misses.Load()
+}
@@ -0,0 +1,456 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"illuminate/internal/logger"
+)
+
+// Redis is a minimal RESP client implementing Store and Queue. It keeps a
+// small pool of idle connections and opens new ones on demand.
+type Redis struct {
+	addr     string
+	password string
+	db       int
+	timeout  time.Duration
+	idle     chan *redisConn
+}
+
+type redisConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// Queue hands jobs between instances: any of them may push a job, and each
+// job is popped by exactly one. A popped job stays on the processing list
+// of its queue until it is done, so that a job whose instance died running
+// it can be found and pushed again.
+type Queue interface {
+	Push(ctx context.Context, name string, job []byte) error
+	// Pop waits for the oldest job until ctx is done and moves it to the
+	// processing list.
+	Pop(ctx context.Context, name string) ([]byte, error)
+	// Done takes job off the processing list, and reports whether it was
+	// there.
+	Done(ctx context.Context, name string, job []byte) (bool, error)
+	// Processing returns the jobs popped from name and not done yet.
+	Processing(ctx context.Context, name string) ([][]byte, error)
+}
+
+// Scripts that compare the value of a key before changing it, in one step.
+const (
+	deleteIfScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) end return 0`
+	expireIfScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("PEXPIRE", KEYS[1], ARGV[2]) end return 0`
+)
+
+// NewSharedFromEnv connects to REDIS_URL, the Redis every instance of a
+// deployment shares. It returns nil when REDIS_URL is unset or the server
+// cannot be reached, and callers then keep their state in-process.
+func NewSharedFromEnv() *Redis {
+	url := os.Getenv("REDIS_URL")
+	if url == "" {
+		return nil
+	}
+	r, err := NewRedis(url)
+	if err != nil {
+		logger.Default.Errorf("redis unavailable, keeping state in memory: %v", err)
+		return nil
+	}
+	return r
+}
+
+// NewRedis connects to a redis://[:password@]host:port[/db] URL.
+func NewRedis(rawURL string) (*Redis, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse redis url: %w", err)
+	}
+	if u.Scheme != "redis" {
+		return nil, fmt.Errorf("unsupported redis url scheme: %s", u.Scheme)
+	}
+
+	r := &Redis{
+		addr:    u.Host,
+		timeout: 2 * time.Second,
+		idle:    make(chan *redisConn, 8),
+	}
+	if !strings.Contains(r.addr, ":") {
+		r.addr += ":6379"
+	}
+	if u.User != nil {
+		r.password, _ = u.User.Password()
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if r.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("invalid redis db: %s", db)
+		}
+	}
+
+	// Fail fast on a bad address instead of on the first request.
+	if _, err := r.Do(context.Background(), "PING"); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	reply, err := r.Do(ctx, "GET", key)
+	if err != nil {
+		return nil, false, err
+	}
+	if reply == nil {
+		return nil, false, nil
+	}
+	b, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("redis GET: unexpected reply %T", reply)
+	}
+	return b, true, nil
+}
+
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	args := []interface{}{"SET", key, value}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	}
+	_, err := r.Do(ctx, args...)
+	return err
+}
+
+func (r *Redis) Add(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	args := []interface{}{"SET", key, value, "NX"}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	}
+	reply, err := r.Do(ctx, args...)
+	return reply != nil, err
+}
+
+// Incr counts with INCR and sets the expiry when the counter is new. A
+// counter whose PEXPIRE was lost with the connection never expires, so a
+// failed PEXPIRE deletes it rather than leave it behind.
+func (r *Redis) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	reply, err := r.Do(ctx, "INCR", key)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("redis INCR: unexpected reply %T", reply)
+	}
+	if n == 1 && ttl > 0 {
+		if _, err := r.Do(ctx, "PEXPIRE", key, strconv.FormatInt(ttl.Milliseconds(), 10)); err != nil {
+			r.Do(ctx, "DEL", key)
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+func (r *Redis) Delete(ctx context.Context, key string) error {
+	_, err := r.Do(ctx, "DEL", key)
+	return err
+}
+
+func (r *Redis) DeleteIf(ctx context.Context, key string, value []byte) (bool, error) {
+	reply, err := r.Do(ctx, "EVAL", deleteIfScript, "1", key, value)
+	return reply == int64(1), err
+}
+
+func (r *Redis) ExpireIf(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	reply, err := r.Do(ctx, "EVAL", expireIfScript, "1", key, value, strconv.FormatInt(ttl.Milliseconds(), 10))
+	return reply == int64(1), err
+}
+
+// processingList is where the jobs popped from the list name wait until
+// they are done.
+func processingList(name string) string {
+	return name + ":processing"
+}
+
+// Push appends job to the list name.
+func (r *Redis) Push(ctx context.Context, name string, job []byte) error {
+	_, err := r.Do(ctx, "LPUSH", name, job)
+	return err
+}
+
+// Pop moves the oldest job of the list name to its processing list, waiting
+// for one until ctx is done. It polls with a BLMOVE shorter than the
+// connection timeout.
+func (r *Redis) Pop(ctx context.Context, name string) ([]byte, error) {
+	for {
+		reply, err := r.Do(ctx, "BLMOVE", name, processingList(name), "RIGHT", "LEFT", "1")
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if reply == nil {
+			continue
+		}
+		job, ok := reply.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("redis BLMOVE: unexpected reply %T", reply)
+		}
+		return job, nil
+	}
+}
+
+// Done removes job from the processing list of name.
+func (r *Redis) Done(ctx context.Context, name string, job []byte) (bool, error) {
+	reply, err := r.Do(ctx, "LREM", processingList(name), "1", job)
+	return reply == int64(1), err
+}
+
+// Processing lists the processing list of name, newest first.
+func (r *Redis) Processing(ctx context.Context, name string) ([][]byte, error) {
+	reply, err := r.Do(ctx, "LRANGE", processingList(name), "0", "-1")
+	if err != nil {
+		return nil, err
+	}
+	items, ok := reply.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("redis LRANGE: unexpected reply %T", reply)
+	}
+	jobs := make([][]byte, 0, len(items))
+	for _, item := range items {
+		job, ok := item.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("redis LRANGE: unexpected reply %T", item)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// Publish sends message to the subscribers of channel.
+func (r *Redis) Publish(ctx context.Context, channel string, message []byte) error {
+	_, err := r.Do(ctx, "PUBLISH", channel, message)
+	return err
+}
+
+// Subscribe calls handle with each message published on channel until ctx
+// is done, on a connection of its own. It returns once subscribed. A
+// dropped connection is subscribed again, and what was published meanwhile
+// is lost.
+func (r *Redis) Subscribe(ctx context.Context, channel string, handle func(message []byte)) error {
+	c, err := r.subscribe(ctx, channel)
+	if err != nil {
+		return err
+	}
+	go func() {
+		for {
+			c.receive(ctx, handle)
+			for c = nil; c == nil; {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(time.Second):
+				}
+				if c, err = r.subscribe(ctx, channel); err != nil {
+					logger.Default.Warnf("redis subscribe %s: %v", channel, err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func (r *Redis) subscribe(ctx context.Context, channel string) (*redisConn, error) {
+	c, err := r.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.conn.SetDeadline(time.Now().Add(r.timeout))
+	if _, err := c.do("SUBSCRIBE", channel); err != nil {
+		c.conn.Close()
+		return nil, err
+	}
+	c.conn.SetDeadline(time.Time{})
+	return c, nil
+}
+
+// receive hands the messages of a subscribed connection to handle until
+// the connection fails or ctx is done, and closes it.
+func (c *redisConn) receive(ctx context.Context, handle func([]byte)) {
+	stop := context.AfterFunc(ctx, func() { c.conn.Close() })
+	defer stop()
+	defer c.conn.Close()
+	for {
+		reply, err := c.read()
+		if err != nil {
+			return
+		}
+		msg, ok := reply.([]interface{})
+		if !ok || len(msg) != 3 {
+			continue
+		}
+		if kind, _ := msg[0].([]byte); string(kind) != "message" {
+			continue
+		}
+		if b, ok := msg[2].([]byte); ok {
+			handle(b)
+		}
+	}
+}
+
+// Do sends one command and returns its reply: nil, int64, string, []byte or
+// []interface{}. Server error replies are returned as errors.
+func (r *Redis) Do(ctx context.Context, args ...interface{}) (interface{}, error) {
+	c, err := r.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(r.timeout)
+	ctxDeadline := false
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline, ctxDeadline = d, true
+	}
+	c.conn.SetDeadline(deadline)
+
+	reply, err := c.do(args...)
+	var redisErr redisError
+	if err != nil && !errors.As(err, &redisErr) {
+		c.conn.Close()
+		var netErr net.Error
+		if ctxDeadline && errors.As(err, &netErr) && netErr.Timeout() {
+			// The socket ran into the deadline of ctx, which may not have
+			// marked itself done yet; report it the way ctx will.
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	r.put(c)
+	return reply, err
+}
+
+func (r *Redis) get(ctx context.Context) (*redisConn, error) {
+	select {
+	case c := <-r.idle:
+		return c, nil
+	default:
+	}
+	return r.dial(ctx)
+}
+
+// dial opens a new connection, logged in and on the configured database.
+func (r *Redis) dial(ctx context.Context) (*redisConn, error) {
+	d := net.Dialer{Timeout: r.timeout}
+	conn, err := d.DialContext(ctx, "tcp", r.addr)
+	if err != nil {
+		return nil, fmt.Errorf("redis dial: %w", err)
+	}
+	c := &redisConn{conn: conn, r: bufio.NewReader(conn)}
+	conn.SetDeadline(time.Now().Add(r.timeout))
+	if r.password != "" {
+		if _, err := c.do("AUTH", r.password); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("redis auth: %w", err)
+		}
+	}
+	if r.db != 0 {
+		if _, err := c.do("SELECT", strconv.Itoa(r.db)); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("redis select: %w", err)
+		}
+	}
+	return c, nil
+}
+
+func (r *Redis) put(c *redisConn) {
+	select {
+	case r.idle <- c:
+	default:
+		c.conn.Close()
+	}
+}
+
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+func (c *redisConn) do(args ...interface{}) (interface{}, error) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "*%d\r\n", len(args))
+	for _, a := range args {
+		var s string
+		switch v := a.(type) {
+		case string:
+			s = v
+		case []byte:
+			s = string(v)
+		default:
+			s = fmt.Sprint(v)
+		}
+		fmt.Fprintf(&sb, "$%d\r\n%s\r\n", len(s), s)
+	}
+	if _, err := io.WriteString(c.conn, sb.String()); err != nil {
+		return nil, err
+	}
+	return c.read()
+}
+
+func (c *redisConn) read() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = c.read(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply %q", line)
+	}
+}
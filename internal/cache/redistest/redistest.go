@@ -0,0 +1,336 @@
+// Package redistest runs an in-process stand-in for Redis that speaks
+// enough RESP for the cache package: strings with expiry, counters, lists,
+// the compare-and-set scripts and pub/sub. It is for tests of code that
+// shares state through REDIS_URL.
+package redistest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server is a Redis stand-in listening on the loopback interface.
+type Server struct {
+	ln net.Listener
+
+	mu     sync.Mutex
+	values map[string]entry
+	lists  map[string][][]byte
+	pushed *sync.Cond
+	closed bool
+	// subscribers are the connections subscribed to each channel.
+	subscribers map[string]map[*client]bool
+}
+
+// client is one connection, written to by its own commands and by
+// PUBLISH on other connections.
+type client struct {
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func (c *client) write(reply string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := io.WriteString(c.conn, reply)
+	return err
+}
+
+type entry struct {
+	value   []byte
+	expires time.Time
+}
+
+// Start listens on a free loopback port.
+func Start() (*Server, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		ln:          ln,
+		values:      map[string]entry{},
+		lists:       map[string][][]byte{},
+		subscribers: map[string]map[*client]bool{},
+	}
+	s.pushed = sync.NewCond(&s.mu)
+	go s.serve()
+	return s, nil
+}
+
+// URL is the redis:// URL of the server.
+func (s *Server) URL() string {
+	return "redis://" + s.ln.Addr().String()
+}
+
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.pushed.Broadcast()
+	s.mu.Unlock()
+	return s.ln.Close()
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	c := &client{conn: conn}
+	defer s.unsubscribe(c)
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		reply := ""
+		if len(args) == 2 && strings.EqualFold(args[0], "SUBSCRIBE") {
+			reply = s.subscribe(c, args[1])
+		} else {
+			reply = s.do(args)
+		}
+		if err := c.write(reply); err != nil {
+			return
+		}
+	}
+}
+
+// subscribe adds c to the subscribers of channel.
+func (s *Server) subscribe(c *client, channel string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.subscribers[channel] == nil {
+		s.subscribers[channel] = map[*client]bool{}
+	}
+	s.subscribers[channel][c] = true
+	return fmt.Sprintf("*3\r\n%s%s%s", bulk([]byte("subscribe")), bulk([]byte(channel)), integer(1))
+}
+
+// unsubscribe removes c from every channel.
+func (s *Server) unsubscribe(c *client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, subs := range s.subscribers {
+		delete(subs, c)
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(line, "*"), "\r\n"))
+	if err != nil {
+		return nil, fmt.Errorf("bad command %q", line)
+	}
+	args := make([]string, n)
+	for i := range args {
+		if line, err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(line, "$"), "\r\n"))
+		if err != nil {
+			return nil, fmt.Errorf("bad argument %q", line)
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func bulk(b []byte) string {
+	if b == nil {
+		return "$-1\r\n"
+	}
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(b), b)
+}
+
+func integer(n int64) string {
+	return fmt.Sprintf(":%d\r\n", n)
+}
+
+// get returns the live string at key; s.mu must be held.
+func (s *Server) get(key string) ([]byte, bool) {
+	e, ok := s.values[key]
+	if ok && !e.expires.IsZero() && time.Now().After(e.expires) {
+		delete(s.values, key)
+		return nil, false
+	}
+	return e.value, ok
+}
+
+func (s *Server) do(args []string) string {
+	if len(args) == 0 {
+		return "-ERR empty command\r\n"
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "AUTH", "SELECT":
+		return "+OK\r\n"
+	case "GET":
+		v, _ := s.get(args[1])
+		return bulk(v)
+	case "SET":
+		e := entry{value: []byte(args[2])}
+		nx := false
+		for i := 3; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "NX":
+				nx = true
+			case "PX":
+				i++
+				ms, err := strconv.ParseInt(args[i], 10, 64)
+				if err != nil {
+					return "-ERR value is not an integer\r\n"
+				}
+				e.expires = time.Now().Add(time.Duration(ms) * time.Millisecond)
+			}
+		}
+		if _, exists := s.get(args[1]); nx && exists {
+			return bulk(nil)
+		}
+		s.values[args[1]] = e
+		return "+OK\r\n"
+	case "INCR":
+		v, ok := s.get(args[1])
+		n := int64(0)
+		if ok {
+			var err error
+			if n, err = strconv.ParseInt(string(v), 10, 64); err != nil {
+				return "-ERR value is not an integer\r\n"
+			}
+		}
+		n++
+		e := s.values[args[1]]
+		e.value = []byte(strconv.FormatInt(n, 10))
+		s.values[args[1]] = e
+		return integer(n)
+	case "PEXPIRE":
+		e, ok := s.values[args[1]]
+		if !ok {
+			return integer(0)
+		}
+		ms, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil {
+			return "-ERR value is not an integer\r\n"
+		}
+		e.expires = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		s.values[args[1]] = e
+		return integer(1)
+	case "DEL":
+		var n int64
+		for _, key := range args[1:] {
+			if _, ok := s.values[key]; ok {
+				delete(s.values, key)
+				n++
+			}
+			if _, ok := s.lists[key]; ok {
+				delete(s.lists, key)
+				n++
+			}
+		}
+		return integer(n)
+	case "LPUSH":
+		for _, v := range args[2:] {
+			s.lists[args[1]] = append([][]byte{[]byte(v)}, s.lists[args[1]]...)
+		}
+		s.pushed.Broadcast()
+		return integer(int64(len(s.lists[args[1]])))
+	case "PUBLISH":
+		message := fmt.Sprintf("*3\r\n%s%s%s", bulk([]byte("message")), bulk([]byte(args[1])), bulk([]byte(args[2])))
+		var n int64
+		for c := range s.subscribers[args[1]] {
+			if c.write(message) == nil {
+				n++
+			}
+		}
+		return integer(n)
+	case "LREM":
+		// Only the count of 1 the cache package sends.
+		l := s.lists[args[1]]
+		for i, v := range l {
+			if string(v) == args[3] {
+				s.lists[args[1]] = append(l[:i:i], l[i+1:]...)
+				return integer(1)
+			}
+		}
+		return integer(0)
+	case "LRANGE":
+		// Only the whole list, as the cache package asks for it.
+		l := s.lists[args[1]]
+		reply := fmt.Sprintf("*%d\r\n", len(l))
+		for _, v := range l {
+			reply += bulk(v)
+		}
+		return reply
+	case "EVAL":
+		// The scripts of the cache package compare the key with ARGV[1]
+		// and then delete it or set its expiry; which one is told by the
+		// command the script ends in.
+		v, ok := s.get(args[3])
+		if !ok || string(v) != args[4] {
+			return integer(0)
+		}
+		if strings.Contains(args[1], `"PEXPIRE"`) {
+			ms, err := strconv.ParseInt(args[5], 10, 64)
+			if err != nil {
+				return "-ERR value is not an integer\r\n"
+			}
+			e := s.values[args[3]]
+			e.expires = time.Now().Add(time.Duration(ms) * time.Millisecond)
+			s.values[args[3]] = e
+		} else {
+			delete(s.values, args[3])
+		}
+		return integer(1)
+	case "BLMOVE":
+		// Only RIGHT LEFT, the direction of a queue.
+		seconds, err := strconv.ParseFloat(args[5], 64)
+		if err != nil {
+			return "-ERR timeout is not a float\r\n"
+		}
+		deadline := time.Now().Add(time.Duration(seconds * float64(time.Second)))
+		timer := time.AfterFunc(time.Until(deadline), func() {
+			s.mu.Lock()
+			s.pushed.Broadcast()
+			s.mu.Unlock()
+		})
+		defer timer.Stop()
+		for !s.closed {
+			if l := s.lists[args[1]]; len(l) > 0 {
+				v := l[len(l)-1]
+				s.lists[args[1]] = l[:len(l)-1]
+				s.lists[args[2]] = append([][]byte{v}, s.lists[args[2]]...)
+				return bulk(v)
+			}
+			if !time.Now().Before(deadline) {
+				break
+			}
+			s.pushed.Wait()
+		}
+		return bulk(nil)
+	}
+	return fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+}
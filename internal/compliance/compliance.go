@@ -0,0 +1,201 @@
+// Package compliance checks luminaire efficacy against energy regulations and
+// rebate programme thresholds.
+package compliance
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Scheme names accepted by Check.
+const (
+	ErP    = "erp"
+	DLC    = "dlc"
+	Custom = "custom"
+)
+
+// Status of one check.
+const (
+	Pass    = "pass"
+	Fail    = "fail"
+	Unknown = "unknown"
+)
+
+// Input is what the checks need to know about a luminaire.
+type Input struct {
+	// Flux is the luminaire flux in lm.
+	Flux float64
+	// Watts is the input power in W; 0 when unknown.
+	Watts float64
+	// CRI is the colour rendering index; 0 when unknown.
+	CRI int
+	// Directional is true when at least 80% of the flux falls within a 120°
+	// cone, per Regulation (EU) 2019/2020.
+	Directional bool
+}
+
+// Efficacy is Flux/Watts, or 0 when the power is unknown.
+func (in Input) Efficacy() float64 {
+	if in.Watts <= 0 {
+		return 0
+	}
+	return in.Flux / in.Watts
+}
+
+// Thresholds configures the programme checks.
+type Thresholds struct {
+	// DLCMinEfficacy and DLCMinCRI are the DesignLights Consortium minimums;
+	// they vary by product category, so they are configurable.
+	DLCMinEfficacy float64
+	DLCMinCRI      int
+	// MinEfficacy adds a custom minimum efficacy check when above zero.
+	MinEfficacy float64
+}
+
+// DefaultThresholds uses the DLC 5.1 Standard minimums for indoor troffers.
+func DefaultThresholds() Thresholds {
+	return Thresholds{DLCMinEfficacy: 110, DLCMinCRI: 80}
+}
+
+// ThresholdsFromEnv reads DLC_MIN_EFFICACY and DLC_MIN_CRI over the defaults.
+func ThresholdsFromEnv() Thresholds {
+	t := DefaultThresholds()
+	if v, err := strconv.ParseFloat(os.Getenv("DLC_MIN_EFFICACY"), 64); err == nil && v > 0 {
+		t.DLCMinEfficacy = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("DLC_MIN_CRI")); err == nil && v >= 0 {
+		t.DLCMinCRI = v
+	}
+	return t
+}
+
+// Result is the outcome of one scheme for one luminaire.
+type Result struct {
+	Scheme string `json:"scheme"`
+	Status string `json:"status"`
+	// Limit describes the threshold applied, e.g. "≤ 9.8 W" or "≥ 110 lm/W".
+	Limit  string `json:"limit"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// ParseSchemes reads a comma-separated scheme list; empty means ErP and DLC.
+func ParseSchemes(s string) ([]string, error) {
+	if strings.TrimSpace(s) == "" {
+		return []string{ErP, DLC}, nil
+	}
+	var schemes []string
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case ErP, DLC, Custom:
+			schemes = append(schemes, name)
+		default:
+			return nil, fmt.Errorf("unknown compliance scheme: %s", name)
+		}
+	}
+	return schemes, nil
+}
+
+// Check runs the schemes against in. The custom scheme is added whenever
+// t.MinEfficacy is set.
+func Check(in Input, t Thresholds, schemes []string) []Result {
+	var results []Result
+	custom := false
+	for _, s := range schemes {
+		switch s {
+		case ErP:
+			results = append(results, checkErP(in))
+		case DLC:
+			results = append(results, checkDLC(in, t))
+		case Custom:
+			custom = true
+		}
+	}
+	if custom || t.MinEfficacy > 0 {
+		results = append(results, checkMinEfficacy(in, t.MinEfficacy))
+	}
+	return results
+}
+
+// checkErP applies the maximum on-mode power of Regulation (EU) 2019/2020,
+// Annex II, for LED light sources:
+//
+//	Pon,max = C × (L + Φ/(F × η)) × R
+//
+// with η = 120 lm/W, L = 1.5 W, C = 1 (basic light source), F = 1 for
+// non-directional and 0.85 for directional sources, and R = 0.65 for
+// CRI ≤ 25 or (CRI + 160)/240 above. An unknown CRI is taken as 80 (R = 1).
+// The regulation is written for light sources; here it is applied to the
+// luminaire as a whole.
+func checkErP(in Input) Result {
+	const (
+		eta = 120.0
+		L   = 1.5
+		C   = 1.0
+	)
+	F := 1.0
+	if in.Directional {
+		F = 0.85
+	}
+	R := 1.0
+	switch {
+	case in.CRI > 25:
+		R = float64(in.CRI+160) / 240
+	case in.CRI > 0:
+		R = 0.65
+	}
+	pmax := C * (L + in.Flux/(F*eta)) * R
+
+	r := Result{Scheme: ErP, Limit: fmt.Sprintf("≤ %.1f W", pmax)}
+	switch {
+	case in.Watts <= 0:
+		r.Status, r.Detail = Unknown, "input power unknown"
+	case in.Watts <= pmax:
+		r.Status = Pass
+	default:
+		r.Status, r.Detail = Fail, fmt.Sprintf("draws %.1f W", in.Watts)
+	}
+	return r
+}
+
+func checkDLC(in Input, t Thresholds) Result {
+	r := Result{Scheme: DLC, Limit: fmt.Sprintf("≥ %g lm/W, CRI ≥ %d", t.DLCMinEfficacy, t.DLCMinCRI)}
+	var problems []string
+	switch {
+	case in.Watts <= 0:
+		r.Status, r.Detail = Unknown, "input power unknown"
+		return r
+	case in.Efficacy() < t.DLCMinEfficacy:
+		problems = append(problems, fmt.Sprintf("efficacy %.1f lm/W", in.Efficacy()))
+	}
+	switch {
+	case in.CRI == 0 && t.DLCMinCRI > 0:
+		if len(problems) == 0 {
+			r.Status, r.Detail = Unknown, "CRI unknown"
+			return r
+		}
+	case in.CRI < t.DLCMinCRI:
+		problems = append(problems, fmt.Sprintf("CRI %d", in.CRI))
+	}
+	if len(problems) > 0 {
+		r.Status, r.Detail = Fail, strings.Join(problems, ", ")
+	} else {
+		r.Status = Pass
+	}
+	return r
+}
+
+func checkMinEfficacy(in Input, min float64) Result {
+	r := Result{Scheme: Custom, Limit: fmt.Sprintf("≥ %g lm/W", min)}
+	switch {
+	case in.Watts <= 0:
+		r.Status, r.Detail = Unknown, "input power unknown"
+	case in.Efficacy() >= min:
+		r.Status = Pass
+	default:
+		r.Status, r.Detail = Fail, fmt.Sprintf("efficacy %.1f lm/W", in.Efficacy())
+	}
+	return r
+}
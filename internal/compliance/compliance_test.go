@@ -0,0 +1,40 @@
+package compliance
+
+import "testing"
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name string
+		in   Input
+		want map[string]string
+	}{
+		// Pon,max = 1.5 + 1000/120 = 9.83 W at CRI 80.
+		{"erp pass", Input{Flux: 1000, Watts: 9.5, CRI: 80}, map[string]string{ErP: Pass, DLC: Fail}},
+		{"erp fail", Input{Flux: 1000, Watts: 10, CRI: 80}, map[string]string{ErP: Fail, DLC: Fail}},
+		// Directional sources get F = 0.85: 1.5 + 1000/102 = 11.3 W.
+		{"erp directional", Input{Flux: 1000, Watts: 10, CRI: 80, Directional: true}, map[string]string{ErP: Pass}},
+		// High CRI relaxes the limit: R = (95+160)/240 = 1.0625.
+		{"erp high cri", Input{Flux: 1000, Watts: 10.3, CRI: 95}, map[string]string{ErP: Pass}},
+		{"dlc pass", Input{Flux: 5500, Watts: 40, CRI: 82}, map[string]string{DLC: Pass, Custom: Fail}},
+		{"dlc low cri", Input{Flux: 5500, Watts: 40, CRI: 70}, map[string]string{DLC: Fail}},
+		{"dlc cri unknown", Input{Flux: 5500, Watts: 40}, map[string]string{DLC: Unknown}},
+		{"no power", Input{Flux: 5500}, map[string]string{ErP: Unknown, DLC: Unknown, Custom: Unknown}},
+	}
+	thresholds := DefaultThresholds()
+	thresholds.MinEfficacy = 140
+	for _, tc := range tests {
+		got := map[string]string{}
+		for _, r := range Check(tc.in, thresholds, []string{ErP, DLC}) {
+			got[r.Scheme] = r.Status
+		}
+		for scheme, want := range tc.want {
+			if got[scheme] != want {
+				t.Errorf("%s: %s = %s, want %s", tc.name, scheme, got[scheme], want)
+			}
+		}
+	}
+
+	if _, err := ParseSchemes("erp, ENERGYSTAR"); err == nil {
+		t.Error("unknown scheme accepted")
+	}
+}
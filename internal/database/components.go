@@ -0,0 +1,108 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrComponentNotFound is returned by LoadComponent for a component the
+// luminaire does not have.
+var ErrComponentNotFound = errors.New("component not found")
+
+// ErrNoDistribution is returned by LoadComponent for a component stored
+// with its geometry only.
+var ErrNoDistribution = errors.New("component has no distribution")
+
+// Component is one independent emitter of a segmented luminaire, such as the
+// "up" or "down" light of a pendant. Lengths are in metres; the offsets
+// place the centre of its opening relative to the luminaire's photometric
+// centre, x towards C0, y towards C90 and z up.
+type Component struct {
+	Name           string  `json:"name"`
+	OffsetX        float64 `json:"offset_x"`
+	OffsetY        float64 `json:"offset_y"`
+	OffsetZ        float64 `json:"offset_z"`
+	LuminousLength float64 `json:"luminous_length"` // zero width means a disc
+	LuminousWidth  float64 `json:"luminous_width"`
+	LuminousHeight float64 `json:"luminous_height"`
+	InputWatts     float64 `json:"input_watts"`
+	LuminousFlux   float64 `json:"luminous_flux"`
+	// HasDistribution reports a measured distribution of its own, which
+	// LoadComponent returns.
+	HasDistribution  bool      `json:"has_distribution"`
+	FileHash         string    `json:"file_hash,omitempty"`
+	OriginalFilename string    `json:"original_filename,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// ListComponents returns the components stored for luminaire id by name.
+func ListComponents(db *sql.DB, id int64) ([]Component, error) {
+	components := []Component{}
+	err := componentStore.list(db, id, `name, offset_x, offset_y, offset_z, luminous_length, luminous_width,
+			luminous_height, input_watts, luminous_flux, candela_values != '',
+			file_hash, original_filename, created_at`,
+		func(rows *sql.Rows) error {
+			var comp Component
+			if err := rows.Scan(&comp.Name, &comp.OffsetX, &comp.OffsetY, &comp.OffsetZ,
+				&comp.LuminousLength, &comp.LuminousWidth, &comp.LuminousHeight,
+				&comp.InputWatts, &comp.LuminousFlux, &comp.HasDistribution,
+				&comp.FileHash, &comp.OriginalFilename, &comp.CreatedAt); err != nil {
+				return err
+			}
+			components = append(components, comp)
+			return nil
+		})
+	return components, err
+}
+
+// SaveComponent stores comp as a component of luminaire id, replacing any
+// earlier one of the same name, with the distribution of lum, or with its
+// geometry only when lum is nil. Input watts and lumens comp leaves at zero
+// are taken from lum.
+func SaveComponent(db *sql.DB, id int64, comp *Component, lum *ParsedLuminaire) error {
+	if lum != nil {
+		if comp.InputWatts == 0 {
+			comp.InputWatts = lum.Metadata.InputWatts
+		}
+		if comp.LuminousFlux == 0 {
+			comp.LuminousFlux = lum.Metadata.LuminousFlux
+		}
+		comp.HasDistribution, comp.FileHash = true, lum.Metadata.FileHash
+	}
+	return componentStore.save(db, id, comp.Name, lum, comp.OriginalFilename,
+		[]string{"offset_x", "offset_y", "offset_z", "luminous_length", "luminous_width", "luminous_height", "input_watts", "luminous_flux"},
+		comp.OffsetX, comp.OffsetY, comp.OffsetZ, comp.LuminousLength, comp.LuminousWidth, comp.LuminousHeight, comp.InputWatts, comp.LuminousFlux)
+}
+
+// DeleteComponent removes component name of luminaire id. It returns
+// ErrComponentNotFound when there is none.
+func DeleteComponent(db *sql.DB, id int64, name string) error {
+	return componentStore.remove(db, id, name)
+}
+
+// LoadComponent rebuilds component name of luminaire id as a luminaire of
+// its own: the metadata of the luminaire with the distribution, opening,
+// input watts and lumens of the component. It returns sql.ErrNoRows when the
+// luminaire does not exist, ErrComponentNotFound when the component does not
+// and ErrNoDistribution when it has no distribution.
+func LoadComponent(db *sql.DB, id int64, name string) (*ParsedLuminaire, error) {
+	var length, width, height, watts, flux float64
+	lum, err := componentStore.load(db, id, name,
+		[]string{"luminous_length", "luminous_width", "luminous_height", "input_watts", "luminous_flux"},
+		&length, &width, &height, &watts, &flux)
+	if err != nil {
+		return nil, err
+	}
+	if len(lum.CandelaMatrix) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoDistribution, name)
+	}
+
+	m := &lum.Metadata
+	m.LuminousLength, m.LuminousWidth = length, width
+	m.LuminousHeightC0, m.LuminousHeightC90 = height, height
+	m.LuminousHeightC180, m.LuminousHeightC270 = height, height
+	m.InputWatts, m.LuminousFlux = watts, flux
+	return lum, nil
+}
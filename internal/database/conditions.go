@@ -0,0 +1,80 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrConditionNotFound is returned by LoadCondition for a condition the
+// luminaire has no measurement of.
+var ErrConditionNotFound = errors.New("condition not found")
+
+// Condition describes one further measurement of a luminaire, taken under
+// other operating conditions than the main one: "40C" at 40 °C ambient, or
+// "dim50" at half output. The photometry itself is loaded with LoadCondition.
+type Condition struct {
+	Name             string    `json:"name"`
+	AmbientTemp      *float64  `json:"ambient_temp,omitempty"` // °C
+	DimLevel         *float64  `json:"dim_level,omitempty"`    // percent of full output
+	InputWatts       float64   `json:"input_watts"`
+	LuminousFlux     float64   `json:"luminous_flux"`
+	FileHash         string    `json:"file_hash"`
+	OriginalFilename string    `json:"original_filename"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// ListConditions returns the conditions stored for luminaire id by name.
+func ListConditions(db *sql.DB, id int64) ([]Condition, error) {
+	conditions := []Condition{}
+	err := conditionStore.list(db, id, `name, ambient_temp, dim_level, input_watts, luminous_flux, file_hash, original_filename, created_at`,
+		func(rows *sql.Rows) error {
+			var cond Condition
+			var temp, dim sql.NullFloat64
+			if err := rows.Scan(&cond.Name, &temp, &dim, &cond.InputWatts, &cond.LuminousFlux,
+				&cond.FileHash, &cond.OriginalFilename, &cond.CreatedAt); err != nil {
+				return err
+			}
+			if temp.Valid {
+				cond.AmbientTemp = &temp.Float64
+			}
+			if dim.Valid {
+				cond.DimLevel = &dim.Float64
+			}
+			conditions = append(conditions, cond)
+			return nil
+		})
+	return conditions, err
+}
+
+// SaveCondition stores lum as the photometry of cond for luminaire id,
+// replacing any earlier file of the same name, and fills in cond's input
+// watts, lumens and file hash from it.
+func SaveCondition(db *sql.DB, id int64, cond *Condition, lum *ParsedLuminaire) error {
+	cond.InputWatts, cond.LuminousFlux = lum.Metadata.InputWatts, lum.Metadata.LuminousFlux
+	cond.FileHash = lum.Metadata.FileHash
+	return conditionStore.save(db, id, cond.Name, lum, cond.OriginalFilename,
+		[]string{"ambient_temp", "dim_level", "input_watts", "luminous_flux"},
+		cond.AmbientTemp, cond.DimLevel, cond.InputWatts, cond.LuminousFlux)
+}
+
+// DeleteCondition removes condition name of luminaire id. It returns
+// ErrConditionNotFound when there is none.
+func DeleteCondition(db *sql.DB, id int64, name string) error {
+	return conditionStore.remove(db, id, name)
+}
+
+// LoadCondition rebuilds luminaire id as measured under condition name: the
+// metadata of the luminaire with the distribution, input watts and lumens of
+// the condition. It returns sql.ErrNoRows when the luminaire does not exist
+// and ErrConditionNotFound when the condition does not.
+func LoadCondition(db *sql.DB, id int64, name string) (*ParsedLuminaire, error) {
+	var watts, flux float64
+	lum, err := conditionStore.load(db, id, name, []string{"input_watts", "luminous_flux"}, &watts, &flux)
+	if err != nil {
+		return nil, err
+	}
+	lum.Metadata.InputWatts = watts
+	lum.Metadata.LuminousFlux = flux
+	return lum, nil
+}
@@ -3,9 +3,12 @@ package database
 import (
 	"context"
 	"database/sql"
+	"embed"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
-	"path/filepath"
+	"path"
 	"strconv"
 	"time"
 
@@ -20,36 +23,92 @@ type Service interface {
 	Health() map[string]string
 	Close() error
 	GetDB() *sql.DB
+	// ReadDB is the pool for reads that may lag behind the latest writes:
+	// the read-only connection of BLUEPRINT_DB_READ_URL, or GetDB when it
+	// is not set.
+	ReadDB() *sql.DB
 }
 
 type service struct {
-	db *sql.DB
+	url  string
+	db   *sql.DB
+	read *sql.DB
 }
 
 var (
 	dburl      = os.Getenv("BLUEPRINT_DB_URL")
+	readURL    = os.Getenv("BLUEPRINT_DB_READ_URL")
 	dbInstance *service
 )
 
+// migrations are built into the binary, so an instance needs no checkout
+// beside it to bring its schema up to date.
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// New opens BLUEPRINT_DB_URL and BLUEPRINT_DB_READ_URL once per process and
+// exits when that fails.
 func New() Service {
 	if dbInstance != nil {
 		return dbInstance
 	}
-
-	db, err := sql.Open("sqlite3", dburl)
+	s, err := open(dburl, readURL)
 	if err != nil {
 		logger.Default.Fatal(err)
 	}
+	dbInstance = s
+	return dbInstance
+}
+
+// Open connects to the database at url, with reads that may lag served from
+// readURL when it is not empty, and applies pending migrations. Every
+// instance of a deployment opens the same database; there is no implicit
+// default, since a database private to one instance or connection would
+// silently split the catalog.
+func Open(url, readURL string) (Service, error) {
+	return open(url, readURL)
+}
 
-	dbInstance = &service{
-		db: db,
+func open(url, readURL string) (*service, error) {
+	if url == "" {
+		return nil, errors.New("BLUEPRINT_DB_URL is not set")
+	}
+	if url == ":memory:" {
+		return nil, errors.New("an in-memory database is private to each connection; use a file")
 	}
 
-	if err := dbInstance.migrate(); err != nil {
-		logger.Default.Fatalf("migration failed: %v", err)
+	cfg := SQLiteConfigFromEnv()
+	db, err := sql.Open("sqlite3", cfg.DSN(url))
+	if err != nil {
+		return nil, err
 	}
+	cfg.Apply(db)
 
-	return dbInstance
+	s := &service{
+		url:  url,
+		db:   db,
+		read: db,
+	}
+	if readURL != "" {
+		// The read pool never writes, so it leaves the journal mode to the
+		// primary and takes no write lock.
+		readCfg := cfg
+		readCfg.JournalMode, readCfg.TxLock = "", "deferred"
+		read, err := sql.Open("sqlite3", readCfg.DSN(readURL))
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		readCfg.Apply(read)
+		s.read = read
+	}
+
+	if err := s.migrate(); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("migration failed: %w", err)
+	}
+	return s, nil
 }
 
 func (s *service) migrate() error {
@@ -61,13 +120,13 @@ func (s *service) migrate() error {
 		return fmt.Errorf("create migrations table: %w", err)
 	}
 
-	migrations, err := filepath.Glob("internal/database/migrations/*.sql")
+	files, err := fs.Glob(migrations, "migrations/*.sql")
 	if err != nil {
 		return fmt.Errorf("find migrations: %w", err)
 	}
 
-	for _, m := range migrations {
-		name := filepath.Base(m)
+	for _, m := range files {
+		name := path.Base(m)
 		var count int
 		err := s.db.QueryRow("SELECT COUNT(*) FROM schema_migrations WHERE name = ?", name).Scan(&count)
 		if err != nil {
@@ -78,7 +137,7 @@ func (s *service) migrate() error {
 			continue
 		}
 
-		sqlContent, err := os.ReadFile(m)
+		sqlContent, err := migrations.ReadFile(m)
 		if err != nil {
 			return fmt.Errorf("read migration %s: %w", name, err)
 		}
@@ -120,6 +179,20 @@ func (s *service) Health() map[string]string {
 	stats["status"] = "up"
 	stats["message"] = "It's healthy"
 
+	if s.read != s.db {
+		if err := s.read.PingContext(ctx); err != nil {
+			stats["read_replica"] = fmt.Sprintf("down: %v", err)
+			stats["message"] = "The read replica is down; browsing fails until it is back."
+		} else {
+			stats["read_replica"] = "up"
+		}
+	}
+
+	var journal string
+	if err := s.db.QueryRowContext(ctx, "PRAGMA journal_mode").Scan(&journal); err == nil {
+		stats["journal_mode"] = journal
+	}
+
 	// Get database stats (like open connections, in use, idle, etc.)
 	dbStats := s.db.Stats()
 	stats["open_connections"] = strconv.Itoa(dbStats.OpenConnections)
@@ -155,10 +228,17 @@ func (s *service) Health() map[string]string {
 // If the connection is successfully closed, it returns nil.
 // If an error occurs while closing the connection, it returns the error.
 func (s *service) Close() error {
-	logger.Default.Infof("Disconnected from database: %s", dburl)
+	logger.Default.Infof("Disconnected from database: %s", s.url)
+	if s.read != s.db {
+		s.read.Close()
+	}
 	return s.db.Close()
 }
 
 func (s *service) GetDB() *sql.DB {
 	return s.db
 }
+
+func (s *service) ReadDB() *sql.DB {
+	return s.read
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// TestOpen applies the embedded migrations wherever the process runs from,
+// and refuses databases that one instance or connection would keep to
+// itself.
+func TestOpen(t *testing.T) {
+	for _, url := range []string{"", ":memory:"} {
+		if _, err := Open(url, ""); err == nil {
+			t.Errorf("Open(%q) succeeded, want an error", url)
+		}
+	}
+
+	s, err := Open(filepath.Join(t.TempDir(), "app.db"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	var applied int
+	if err := s.GetDB().QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&applied); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := filepath.Glob("migrations/*.sql")
+	if applied == 0 || applied != len(files) {
+		t.Errorf("%d migrations applied, want all %d", applied, len(files))
+	}
+}
+
+// TestAngleMigration rewrites angle lists stored in the fmt "%v" form of
+// earlier builds as JSON arrays, and leaves those already stored as JSON.
+func TestAngleMigration(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "app.db"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	db := s.GetDB()
+	if _, err := db.Exec(`INSERT INTO luminaires (id, model, file_hash) VALUES (1, 'Old', 'old'), (2, 'New', 'new')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO photometric_data (luminaire_id, vertical_angles, horizontal_angles, candela_values)
+		VALUES (1, '[0 2.5 90]', '[0]', '1,2,3'), (2, '[0,45]', '[]', '1,2')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO photometric_conditions (luminaire_id, name, vertical_angles, horizontal_angles, candela_values)
+		VALUES (1, 'hot', '[0 90]', '[0 180]', '1,2;3,4')`); err != nil {
+		t.Fatal(err)
+	}
+
+	migration, err := migrations.ReadFile("migrations/038_store_angles_as_json.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(string(migration)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []struct {
+		id                   int64
+		vertical, horizontal string
+	}{{1, "[0,2.5,90]", "[0]"}, {2, "[0,45]", "[]"}} {
+		var vertical, horizontal string
+		db.QueryRow(`SELECT vertical_angles, horizontal_angles FROM photometric_data WHERE luminaire_id = ?`, want.id).Scan(&vertical, &horizontal)
+		if vertical != want.vertical || horizontal != want.horizontal {
+			t.Errorf("luminaire %d: angles %q, %q, want %q, %q", want.id, vertical, horizontal, want.vertical, want.horizontal)
+		}
+	}
+	var total float64
+	if err := db.QueryRow(`SELECT SUM(value) FROM photometric_conditions, json_each(horizontal_angles)`).Scan(&total); err != nil || total != 180 {
+		t.Errorf("condition horizontal angles sum to %g, %v; want 180", total, err)
+	}
+	lum, err := LoadParsedLuminaire(db, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lum.VerticalAngles) != 3 || lum.VerticalAngles[1] != 2.5 {
+		t.Errorf("LoadParsedLuminaire angles = %v", lum.VerticalAngles)
+	}
+}
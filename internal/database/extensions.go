@@ -0,0 +1,58 @@
+package database
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// Extensions holds the format-specific fields a source file carried that
+// have no slot in the common model, such as the IES [NEARFIELD] keyword or
+// the EULUMDAT light output ratio. Keys are "<format>:<field>", e.g.
+// "ies:NEARFIELD" or "ldt:light_output_ratio". Parsers fill it and writers of
+// the same format restore the fields; writers of other formats ignore them.
+type Extensions map[string]string
+
+// Format returns the fields of format, keyed without the prefix.
+func (e Extensions) Format(format string) map[string]string {
+	prefix := format + ":"
+	fields := map[string]string{}
+	for key, value := range e {
+		if name, ok := strings.CutPrefix(key, prefix); ok {
+			fields[name] = value
+		}
+	}
+	return fields
+}
+
+// Keys returns the keys of e in sorted order.
+func (e Extensions) Keys() []string {
+	keys := make([]string, 0, len(e))
+	for key := range e {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// EncodeExtensions stores e as JSON in photometric_data, or "" when empty.
+func EncodeExtensions(e Extensions) string {
+	if len(e) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(e)
+	return string(data)
+}
+
+// DecodeExtensions reads what EncodeExtensions stored. Anything unreadable
+// yields nil.
+func DecodeExtensions(s string) Extensions {
+	if s == "" {
+		return nil
+	}
+	var e Extensions
+	if err := json.Unmarshal([]byte(s), &e); err != nil || len(e) == 0 {
+		return nil
+	}
+	return e
+}
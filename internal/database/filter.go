@@ -0,0 +1,268 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// filterField is a column a filter term may compare against.
+type filterField struct {
+	column  string
+	numeric bool
+}
+
+// filterFields are the fields a filter expression may name, with short
+// aliases for the common ones.
+var filterFields = map[string]filterField{
+	"id":               {"luminaires.id", true},
+	"manufacturer":     {"manufacturer", false},
+	"model":            {"model", false},
+	"catalog_number":   {"catalog_number", false},
+	"description":      {"luminare_description", false},
+	"lamp_type":        {"lamp_type", false},
+	"lamp_catalog":     {"lamp_catalog", false},
+	"ballast":          {"ballast", false},
+	"test_lab":         {"test_lab", false},
+	"format":           {"format_type", false},
+	"format_type":      {"format_type", false},
+	"photometric_type": {"photometric_type", true},
+	"input_watts":      {"input_watts", true},
+	"watts":            {"input_watts", true},
+	"luminous_flux":    {"luminous_flux", true},
+	"flux":             {"luminous_flux", true},
+	"color_temp":       {"color_temp", true},
+	"cct":              {"color_temp", true},
+	"cri":              {"cri", true},
+	"rated_life":       {"rated_life", true},
+	"state":            {"workflow_state", false},
+	"family":           {"(SELECT f.name FROM family_variants v JOIN families f ON f.id = v.family_id WHERE v.luminaire_id = luminaires.id)", false},
+	"driver":           {"(SELECT d.name FROM luminaire_drivers l JOIN drivers d ON d.id = l.driver_id WHERE l.luminaire_id = luminaires.id)", false},
+	"quality":          {QualityGradeColumn, false},
+	"quality_score":    {"(SELECT quality_score FROM luminaire_validation WHERE luminaire_id = luminaires.id)", true},
+
+	// Cached photometric metrics, see the photometry package.
+	"computed_flux":     {metricColumn("flux"), true},
+	"downward_fraction": {metricColumn("downward_fraction"), true},
+	"beam_angle":        {metricColumn("beam_angle"), true},
+	"beam":              {metricColumn("beam_angle"), true},
+	"field_angle":       {metricColumn("field_angle"), true},
+	"efficacy":          {metricColumn("efficacy"), true},
+	"ugr":               {metricColumn("ugr"), true},
+	"distribution":      {metricColumn("distribution"), false},
+	"symmetry":          {metricColumn("symmetry"), false},
+	"horizontal_spread": {metricColumn("horizontal_spread"), true},
+	"vertical_spread":   {metricColumn("vertical_spread"), true},
+	"nema_type":         {metricColumn("nema_type"), false},
+
+	// Average luminance at 65°–85°, the EN 12464-1 screen glare limit.
+	"high_angle_luminance": {metricColumn("high_angle_luminance"), true},
+}
+
+// QualityGradeColumn is the stored validation grade of a luminaire, A to E.
+// Grades compare as text, so "quality <= B" is B or better.
+const QualityGradeColumn = "(SELECT quality_grade FROM luminaire_validation WHERE luminaire_id = luminaires.id)"
+
+func metricColumn(name string) string {
+	return "(SELECT " + name + " FROM luminaire_metrics WHERE luminaire_id = luminaires.id)"
+}
+
+// searchColumns are matched by bare search terms.
+var searchColumns = []string{"manufacturer", "model", "catalog_number", "luminare_description", "lamp_type"}
+
+// filterOps are the comparison operators of a filter term.
+var filterOps = []string{">=", "<=", "!=", "=", ">", "<", "~"}
+
+// FilterTerm is one condition of a Filter. A term without a field is a
+// free-text search.
+type FilterTerm struct {
+	Field string
+	Op    string
+	Value string
+}
+
+// Filter is a parsed filter expression: comma-separated terms that must all
+// match, e.g. "LED, cct=3000, flux > 5000 lm". A term is either "field op
+// value" with op one of = != < <= > >= ~ (contains), or bare text searched
+// for in manufacturer, model, catalog number, description and lamp type.
+// Numeric fields also take an inclusive range, "beam=20..40". Units after
+// numbers ("5000 lm", "3000K", "120 lm/W") are ignored. Metric fields
+// (beam_angle, field_angle, efficacy, ugr, distribution, symmetry, ...) read
+// the luminaire_metrics cache; records without cached metrics never match
+// them.
+type Filter struct {
+	Terms []FilterTerm
+}
+
+// ParseFilter parses a filter expression. An empty expression matches
+// everything.
+func ParseFilter(expr string) (*Filter, error) {
+	f := &Filter{}
+	for _, raw := range strings.Split(expr, ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		term, err := parseFilterTerm(raw)
+		if err != nil {
+			return nil, err
+		}
+		f.Terms = append(f.Terms, term)
+	}
+	return f, nil
+}
+
+func parseFilterTerm(raw string) (FilterTerm, error) {
+	// The first operator in the term splits it; at the same position the
+	// longer operator wins, so ">=" is not read as ">".
+	at, op := -1, ""
+	for _, candidate := range filterOps {
+		if i := strings.Index(raw, candidate); i >= 0 && (at < 0 || i < at) {
+			at, op = i, candidate
+		}
+	}
+	if at < 0 {
+		return FilterTerm{Value: raw}, nil
+	}
+
+	name := strings.ToLower(strings.TrimSpace(raw[:at]))
+	value := strings.TrimSpace(raw[at+len(op):])
+	field, ok := filterFields[name]
+	if !ok {
+		return FilterTerm{}, fmt.Errorf("unknown filter field %q", name)
+	}
+	if value == "" {
+		return FilterTerm{}, fmt.Errorf("filter %q: missing value", raw)
+	}
+	if field.numeric {
+		if op == "~" {
+			return FilterTerm{}, fmt.Errorf("filter %q: ~ only applies to text fields", raw)
+		}
+		lo, hi, isRange := strings.Cut(value, "..")
+		if isRange && op != "=" {
+			return FilterTerm{}, fmt.Errorf("filter %q: ranges need =", raw)
+		}
+		var err error
+		if lo, err = filterNumber(lo); err != nil {
+			return FilterTerm{}, fmt.Errorf("filter %q: %w", raw, err)
+		}
+		value = lo
+		if isRange {
+			if hi, err = filterNumber(hi); err != nil {
+				return FilterTerm{}, fmt.Errorf("filter %q: %w", raw, err)
+			}
+			value = lo + ".." + hi
+		}
+	}
+	return FilterTerm{Field: name, Op: op, Value: value}, nil
+}
+
+// filterNumber strips a trailing unit such as "lm" or "K" and checks that
+// what remains is a number.
+func filterNumber(s string) (string, error) {
+	s = strings.TrimRightFunc(strings.TrimSpace(s), func(r rune) bool { return unicode.IsLetter(r) || r == '/' || unicode.IsSpace(r) })
+	if _, err := strconv.ParseFloat(s, 64); err != nil {
+		return "", fmt.Errorf("%q is not a number", s)
+	}
+	return s, nil
+}
+
+// String formats the filter back into its canonical expression.
+func (f *Filter) String() string {
+	parts := make([]string, len(f.Terms))
+	for i, t := range f.Terms {
+		parts[i] = t.Value
+		if t.Field != "" {
+			parts[i] = t.Field + t.Op + t.Value
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Where returns the SQL condition for the filter over the luminaires table,
+// or "" when it matches everything.
+func (f *Filter) Where() (string, []any) {
+	if f == nil || len(f.Terms) == 0 {
+		return "", nil
+	}
+	var conds []string
+	var args []any
+	for _, t := range f.Terms {
+		if t.Field == "" {
+			pattern := "%" + likeEscaper.Replace(t.Value) + "%"
+			var or []string
+			for _, col := range searchColumns {
+				or = append(or, col+` LIKE ? ESCAPE '\'`)
+				args = append(args, pattern)
+			}
+			conds = append(conds, "("+strings.Join(or, " OR ")+")")
+			continue
+		}
+
+		field := filterFields[t.Field]
+		switch {
+		case field.numeric && strings.Contains(t.Value, ".."):
+			lo, hi, _ := strings.Cut(t.Value, "..")
+			a, _ := strconv.ParseFloat(lo, 64)
+			b, _ := strconv.ParseFloat(hi, 64)
+			conds = append(conds, field.column+" BETWEEN ? AND ?")
+			args = append(args, math.Min(a, b), math.Max(a, b))
+		case field.numeric:
+			v, _ := strconv.ParseFloat(t.Value, 64)
+			conds = append(conds, field.column+" "+t.Op+" ?")
+			args = append(args, v)
+		case t.Op == "~":
+			conds = append(conds, field.column+` LIKE ? ESCAPE '\'`)
+			args = append(args, "%"+likeEscaper.Replace(t.Value)+"%")
+		case t.Op == "=" || t.Op == "!=":
+			conds = append(conds, field.column+" "+t.Op+" ? COLLATE NOCASE")
+			args = append(args, t.Value)
+		default:
+			conds = append(conds, field.column+" "+t.Op+" ?")
+			args = append(args, t.Value)
+		}
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// FindLuminaires returns the luminaires matching f, ordered by manufacturer
+// and model. A nil filter matches every luminaire.
+func FindLuminaires(db *sql.DB, f *Filter) ([]Luminaire, error) {
+	return FindLuminairesWhere(db, f, "")
+}
+
+// FindLuminairesWhere is FindLuminaires narrowed by a further SQL condition
+// on the luminaires table, with its own placeholder args; "" adds none.
+func FindLuminairesWhere(db *sql.DB, f *Filter, cond string, condArgs ...interface{}) ([]Luminaire, error) {
+	query := `SELECT ` + luminaireColumns + ` FROM luminaires WHERE ` + NotDeleted
+	var args []interface{}
+	if cond != "" {
+		query += ` AND ` + cond
+		args = append(args, condArgs...)
+	}
+	where, whereArgs := f.Where()
+	if where != "" {
+		query += ` AND ` + where
+		args = append(args, whereArgs...)
+	}
+	rows, err := db.Query(query+` ORDER BY manufacturer, model, id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var luminaires []Luminaire
+	for rows.Next() {
+		var lum Luminaire
+		if err := scanLuminaire(rows, &lum); err != nil {
+			return nil, err
+		}
+		luminaires = append(luminaires, lum)
+	}
+	return luminaires, rows.Err()
+}
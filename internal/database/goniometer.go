@@ -0,0 +1,39 @@
+package database
+
+// Goniometer describes the measuring frame of a photometric type. Every type
+// is read with the luminaire's optical axis pointing at nadir, its C0 plane
+// along the length and C90 across it, so the three systems describe the same
+// directions and can be converted into one another.
+type Goniometer struct {
+	// System names the angle pair: "C-gamma", "B-beta" or "A-alpha".
+	System string `json:"system"`
+	// PolarAxis is the axis every measuring half-plane contains:
+	// "vertical" through nadir (C), "transverse" along C90–C270 (B) or
+	// "longitudinal" along C0–C180 (A).
+	PolarAxis string `json:"polar_axis"`
+	// PlaneAngle is the angle list that selects the half-plane, "horizontal"
+	// or "vertical"; the other one is measured within it.
+	PlaneAngle string `json:"plane_angle"`
+	// Zero is the direction both angles are zero in: "nadir" for C, the
+	// "optical axis" for A and B.
+	Zero string `json:"zero"`
+	// HorizontalRange and VerticalRange are the usual extents of the two
+	// angle lists in degrees.
+	HorizontalRange [2]float64 `json:"horizontal_range"`
+	VerticalRange   [2]float64 `json:"vertical_range"`
+}
+
+// Goniometer returns the measuring frame of t; anything but A or B is C.
+func (t PhotometricType) Goniometer() Goniometer {
+	switch t {
+	case PhotometricTypeB:
+		return Goniometer{System: "B-beta", PolarAxis: "transverse", PlaneAngle: "vertical", Zero: "optical axis",
+			HorizontalRange: [2]float64{-90, 90}, VerticalRange: [2]float64{-90, 90}}
+	case PhotometricTypeA:
+		return Goniometer{System: "A-alpha", PolarAxis: "longitudinal", PlaneAngle: "horizontal", Zero: "optical axis",
+			HorizontalRange: [2]float64{-90, 90}, VerticalRange: [2]float64{-90, 90}}
+	default:
+		return Goniometer{System: "C-gamma", PolarAxis: "vertical", PlaneAngle: "horizontal", Zero: "nadir",
+			HorizontalRange: [2]float64{0, 360}, VerticalRange: [2]float64{0, 180}}
+	}
+}
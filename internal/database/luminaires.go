@@ -0,0 +1,182 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// luminaireColumns are the columns scanLuminaire reads. Each is coalesced to
+// its schema default: databases an older build created have luminaires
+// tables without NOT NULL, and a NULL would fail the scan of the whole row.
+const luminaireColumns = `id, COALESCE(manufacturer, ''), COALESCE(model, ''),
+	COALESCE(catalog_number, ''), COALESCE(luminare_description, ''),
+	COALESCE(lamp_type, ''), COALESCE(lamp_catalog, ''), COALESCE(ballast, ''),
+	COALESCE(test_lab, ''), COALESCE(test_number, ''), COALESCE(issue_date, ''),
+	COALESCE(test_date, ''), COALESCE(luminaire_candela, ''),
+	COALESCE(lamp_position, ''), COALESCE(symmetry, 0),
+	COALESCE(photometric_type, 1), COALESCE(units_type, 'Metric'),
+	COALESCE(conversion_factor, 1.0), COALESCE(input_watts, 0),
+	COALESCE(luminous_flux, 0), COALESCE(color_temp, 0), COALESCE(cri, 0),
+	COALESCE(lamp_lumen_depreciation, 0), COALESCE(driver_maintenance_factor, 0),
+	COALESCE(rated_life, 0), COALESCE(format_type, ''),
+	COALESCE(format_version, ''), COALESCE(format_confidence, 0),
+	COALESCE(symmetry_flag, 0), COALESCE(luminous_length, 0),
+	COALESCE(luminous_width, 0), COALESCE(aim_tilt, 0), COALESCE(aim_rotation, 0),
+	file_hash, COALESCE(original_filename, ''),
+	COALESCE(workflow_state, 'published'), created_at, updated_at,
+	COALESCE(luminous_height_c0, 0), COALESCE(luminous_height_c90, 0),
+	COALESCE(luminous_height_c180, 0), COALESCE(luminous_height_c270, 0),
+	COALESCE(thd, 0), COALESCE(inrush_current, 0), COALESCE(frequency, 0),
+	COALESCE(parser_override, '')`
+
+// NotDeleted is the condition that leaves out luminaires merged into another
+// record or moved to the trash; they stay in the table with deleted_at set.
+const NotDeleted = "luminaires.deleted_at IS NULL"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanLuminaire(row rowScanner, lum *Luminaire) error {
+	return row.Scan(
+		&lum.ID, &lum.Manufacturer, &lum.Model, &lum.CatalogNumber, &lum.LuminaireDesc,
+		&lum.LampType, &lum.LampCatalog, &lum.Ballast, &lum.TestLab, &lum.TestNumber,
+		&lum.IssueDate, &lum.TestDate, &lum.LuminaireCandela, &lum.LampPosition,
+		&lum.Symmetry, &lum.PhotometricType, &lum.UnitsType, &lum.ConversionFactor,
+		&lum.InputWatts, &lum.LuminousFlux, &lum.ColorTemp, &lum.CRI,
+		&lum.LampLumenDepreciation, &lum.DriverMaintenanceFactor, &lum.RatedLife, &lum.FormatType,
+		&lum.FormatVersion, &lum.FormatConfidence, &lum.SymmetryFlag,
+		&lum.LuminousLength, &lum.LuminousWidth, &lum.AimTilt, &lum.AimRotation,
+		&lum.FileHash, &lum.OriginalFilename, &lum.State, &lum.CreatedAt, &lum.UpdatedAt,
+		&lum.LuminousHeightC0, &lum.LuminousHeightC90, &lum.LuminousHeightC180,
+		&lum.LuminousHeightC270, &lum.THD, &lum.InrushCurrent, &lum.Frequency,
+		&lum.ParserOverride,
+	)
+}
+
+// ListLuminaires returns the metadata of every stored luminaire, ordered by
+// manufacturer and model.
+func ListLuminaires(db *sql.DB) ([]Luminaire, error) {
+	return FindLuminaires(db, nil)
+}
+
+// ListLuminairesAfter returns up to limit luminaires with an id greater than
+// afterID in id order, for keyset pagination over large catalogs. A
+// non-empty cond narrows the page further, as in FindLuminairesWhere.
+func ListLuminairesAfter(db *sql.DB, afterID int64, limit int, cond string, condArgs ...interface{}) ([]Luminaire, error) {
+	query := `SELECT ` + luminaireColumns + ` FROM luminaires WHERE id > ? AND ` + NotDeleted
+	args := []interface{}{afterID}
+	if cond != "" {
+		query += ` AND ` + cond
+		args = append(args, condArgs...)
+	}
+	rows, err := db.Query(query+` ORDER BY id LIMIT ?`, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var luminaires []Luminaire
+	for rows.Next() {
+		var lum Luminaire
+		if err := scanLuminaire(rows, &lum); err != nil {
+			return nil, err
+		}
+		luminaires = append(luminaires, lum)
+	}
+	return luminaires, rows.Err()
+}
+
+// LoadLuminaire returns the metadata of a stored luminaire. It returns
+// sql.ErrNoRows when the luminaire does not exist or was merged into
+// another.
+func LoadLuminaire(db *sql.DB, id int64) (Luminaire, error) {
+	var lum Luminaire
+	err := scanLuminaire(db.QueryRow(`SELECT `+luminaireColumns+` FROM luminaires WHERE id = ? AND `+NotDeleted, id), &lum)
+	return lum, err
+}
+
+// LoadParsedLuminaire rebuilds the full photometric model of a stored
+// luminaire. It returns sql.ErrNoRows when the luminaire does not exist or
+// was merged into another.
+func LoadParsedLuminaire(db *sql.DB, id int64) (*ParsedLuminaire, error) {
+	meta, err := LoadLuminaire(db, id)
+	if err != nil {
+		return nil, err
+	}
+	lum := ParsedLuminaire{Metadata: meta}
+
+	var vertAngles, horzAngles, candelaVals, extensions string
+	err = db.QueryRow(`
+		SELECT vertical_angles, horizontal_angles, candela_values, extensions
+		FROM photometric_data WHERE luminaire_id = ?`, id,
+	).Scan(&vertAngles, &horzAngles, &candelaVals, &extensions)
+	if err != nil {
+		return nil, fmt.Errorf("photometric data: %w", err)
+	}
+
+	lum.VerticalAngles = DecodeAngles(vertAngles)
+	lum.HorizontalAngles = DecodeAngles(horzAngles)
+	lum.CandelaMatrix = DecodeCandela(candelaVals)
+	lum.Extensions = DecodeExtensions(extensions)
+	return &lum, nil
+}
+
+// EncodeAngles stores an angle list as a JSON array of numbers such as
+// "[0,5,10]", which SQLite's json functions can read.
+func EncodeAngles(angles []float64) string {
+	if len(angles) == 0 {
+		return "[]"
+	}
+	b, _ := json.Marshal(angles)
+	return string(b)
+}
+
+// DecodeAngles reads an angle list stored by EncodeAngles. A list that
+// cannot be read is empty.
+func DecodeAngles(s string) []float64 {
+	angles := []float64{}
+	json.Unmarshal([]byte(s), &angles)
+	return angles
+}
+
+// EncodeCandela stores a candela matrix as ";"-separated rows of
+// ","-separated values, one row per horizontal angle.
+func EncodeCandela(matrix [][]float64) string {
+	var b strings.Builder
+	for i, row := range matrix {
+		if i > 0 {
+			b.WriteByte(';')
+		}
+		for j, v := range row {
+			if j > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(strconv.FormatFloat(v, 'f', 2, 64))
+		}
+	}
+	return b.String()
+}
+
+// DecodeCandela reads a candela matrix stored by EncodeCandela.
+func DecodeCandela(s string) [][]float64 {
+	matrix := [][]float64{}
+	if s == "" {
+		return matrix
+	}
+	for _, rowStr := range strings.Split(s, ";") {
+		row := []float64{}
+		for _, v := range strings.Split(rowStr, ",") {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				row = append(row, f)
+			}
+		}
+		if len(row) > 0 {
+			matrix = append(matrix, row)
+		}
+	}
+	return matrix
+}
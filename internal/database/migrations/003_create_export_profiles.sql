@@ -0,0 +1,9 @@
+-- Create export_profiles table
+-- Stores named metadata mapping profiles applied on export
+CREATE TABLE IF NOT EXISTS export_profiles (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    definition TEXT NOT NULL DEFAULT '{}',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,2 @@
+-- Index the list order so cursor pagination can seek instead of scanning
+CREATE INDEX IF NOT EXISTS idx_luminaires_created_at_id ON luminaires(created_at, id);
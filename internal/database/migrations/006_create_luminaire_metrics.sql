@@ -0,0 +1,22 @@
+-- Add the luminous opening, needed for luminance and glare metrics
+ALTER TABLE luminaires ADD COLUMN luminous_length REAL NOT NULL DEFAULT 0;
+ALTER TABLE luminaires ADD COLUMN luminous_width REAL NOT NULL DEFAULT 0;
+
+-- Create luminaire_metrics table
+-- Caches metrics computed from the candela data so they can be filtered on
+CREATE TABLE IF NOT EXISTS luminaire_metrics (
+    luminaire_id INTEGER PRIMARY KEY,
+    flux REAL NOT NULL DEFAULT 0,
+    downward_fraction REAL NOT NULL DEFAULT 0,
+    beam_angle REAL NOT NULL DEFAULT 0,
+    field_angle REAL NOT NULL DEFAULT 0,
+    efficacy REAL,
+    distribution TEXT NOT NULL DEFAULT '',
+    symmetry TEXT NOT NULL DEFAULT '',
+    ugr REAL,
+    computed_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (luminaire_id) REFERENCES luminaires(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_luminaire_metrics_beam_angle ON luminaire_metrics(beam_angle);
+CREATE INDEX IF NOT EXISTS idx_luminaire_metrics_efficacy ON luminaire_metrics(efficacy);
@@ -0,0 +1,11 @@
+-- Create luminaire_claims table
+-- Stores the values a marketing datasheet claims, to check against the data
+CREATE TABLE IF NOT EXISTS luminaire_claims (
+    luminaire_id INTEGER PRIMARY KEY,
+    flux REAL,
+    watts REAL,
+    cct INTEGER,
+    source TEXT NOT NULL DEFAULT '',
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (luminaire_id) REFERENCES luminaires(id) ON DELETE CASCADE
+);
@@ -0,0 +1,21 @@
+-- Create families table
+-- Groups wattage, CCT or optic variants of the same fixture
+CREATE TABLE IF NOT EXISTS families (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create family_variants table
+-- Links each luminaire to at most one family
+CREATE TABLE IF NOT EXISTS family_variants (
+    luminaire_id INTEGER PRIMARY KEY,
+    family_id INTEGER NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (luminaire_id) REFERENCES luminaires(id) ON DELETE CASCADE,
+    FOREIGN KEY (family_id) REFERENCES families(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_family_variants_family_id ON family_variants(family_id);
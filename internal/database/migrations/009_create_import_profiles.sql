@@ -0,0 +1,12 @@
+-- Create import_profiles table
+-- Stores per-manufacturer field mappings applied to uploads
+CREATE TABLE IF NOT EXISTS import_profiles (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    manufacturer TEXT NOT NULL,
+    definition TEXT NOT NULL DEFAULT '{}',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_import_profiles_manufacturer ON import_profiles(manufacturer COLLATE NOCASE);
@@ -0,0 +1,24 @@
+-- Create luminaire_validation table
+-- Holds the latest validation result of every luminaire
+CREATE TABLE IF NOT EXISTS luminaire_validation (
+    luminaire_id INTEGER PRIMARY KEY,
+    status TEXT NOT NULL,
+    issues TEXT NOT NULL DEFAULT '[]',
+    rules_version TEXT NOT NULL,
+    validated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (luminaire_id) REFERENCES luminaires(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_luminaire_validation_status ON luminaire_validation(status);
+
+-- Create validation_runs table
+-- Records each catalog re-validation and the records whose status changed
+CREATE TABLE IF NOT EXISTS validation_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    rules_version TEXT NOT NULL,
+    started_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at DATETIME,
+    checked INTEGER NOT NULL DEFAULT 0,
+    changed INTEGER NOT NULL DEFAULT 0,
+    report TEXT NOT NULL DEFAULT '[]'
+);
@@ -0,0 +1,11 @@
+-- Create validation_cache table
+-- Reuses validation results for unchanged files under unchanged rules
+CREATE TABLE IF NOT EXISTS validation_cache (
+    file_hash TEXT NOT NULL,
+    rules_version TEXT NOT NULL,
+    input_digest TEXT NOT NULL,
+    status TEXT NOT NULL,
+    issues TEXT NOT NULL DEFAULT '[]',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (file_hash, rules_version, input_digest)
+);
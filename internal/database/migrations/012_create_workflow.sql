@@ -0,0 +1,20 @@
+-- Add the review workflow state
+-- Records stored before the workflow existed were already public
+ALTER TABLE luminaires ADD COLUMN workflow_state TEXT NOT NULL DEFAULT 'published';
+
+CREATE INDEX IF NOT EXISTS idx_luminaires_workflow_state ON luminaires(workflow_state);
+
+-- Create workflow_events table
+-- Records every state change with the role that made it
+CREATE TABLE IF NOT EXISTS workflow_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    luminaire_id INTEGER NOT NULL,
+    from_state TEXT NOT NULL,
+    to_state TEXT NOT NULL,
+    role TEXT NOT NULL,
+    comment TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (luminaire_id) REFERENCES luminaires(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_workflow_events_luminaire_id ON workflow_events(luminaire_id);
@@ -0,0 +1,11 @@
+-- Create luminaire_licenses table
+-- Stores the license or usage terms exports of a luminaire carry
+CREATE TABLE IF NOT EXISTS luminaire_licenses (
+    luminaire_id INTEGER PRIMARY KEY,
+    name TEXT NOT NULL DEFAULT '',
+    text TEXT NOT NULL DEFAULT '',
+    url TEXT NOT NULL DEFAULT '',
+    require_acceptance INTEGER NOT NULL DEFAULT 0,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (luminaire_id) REFERENCES luminaires(id) ON DELETE CASCADE
+);
@@ -0,0 +1,18 @@
+-- Create conversions table
+-- Records every export of a luminaire; kept after the luminaire is deleted
+-- so that files found later can still be traced
+CREATE TABLE IF NOT EXISTS conversions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    luminaire_id INTEGER NOT NULL,
+    format TEXT NOT NULL,
+    options TEXT NOT NULL DEFAULT '',
+    request TEXT NOT NULL DEFAULT '',
+    requested_by TEXT NOT NULL DEFAULT '',
+    client_ip TEXT NOT NULL DEFAULT '',
+    source_hash TEXT NOT NULL DEFAULT '',
+    result_hash TEXT NOT NULL,
+    size INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_conversions_luminaire_id ON conversions(luminaire_id);
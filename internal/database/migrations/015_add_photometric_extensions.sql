@@ -0,0 +1,3 @@
+-- Add format-specific fields to photometric_data
+-- A JSON object of the source file's fields that have no common-model column
+ALTER TABLE photometric_data ADD COLUMN extensions TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,19 @@
+-- Add the data quality score and grade to luminaire_validation
+ALTER TABLE luminaire_validation ADD COLUMN quality_score INTEGER;
+ALTER TABLE luminaire_validation ADD COLUMN quality_grade TEXT;
+
+-- Score the stored results as validate.Quality does: 30 points off per
+-- error, 10 per warning
+UPDATE luminaire_validation SET quality_score = MAX(0, 100
+    - 30 * (SELECT COUNT(*) FROM json_each(issues) WHERE json_extract(value, '$.severity') = 'error')
+    - 10 * (SELECT COUNT(*) FROM json_each(issues) WHERE json_extract(value, '$.severity') != 'error'));
+
+UPDATE luminaire_validation SET quality_grade = CASE
+    WHEN quality_score >= 90 THEN 'A'
+    WHEN quality_score >= 75 THEN 'B'
+    WHEN quality_score >= 60 THEN 'C'
+    WHEN quality_score >= 40 THEN 'D'
+    ELSE 'E'
+END;
+
+CREATE INDEX IF NOT EXISTS idx_luminaire_validation_quality_grade ON luminaire_validation(quality_grade);
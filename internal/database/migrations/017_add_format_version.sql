@@ -0,0 +1,4 @@
+-- Add the detected source format version to luminaires
+-- Confidence runs from 0 to 1; rows stored before detection have neither
+ALTER TABLE luminaires ADD COLUMN format_version TEXT NOT NULL DEFAULT '';
+ALTER TABLE luminaires ADD COLUMN format_confidence REAL NOT NULL DEFAULT 0;
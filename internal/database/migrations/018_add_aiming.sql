@@ -0,0 +1,5 @@
+-- Add the intended aiming of a luminaire as installed
+-- Degrees: tilt of the optical axis from nadir toward C0, then rotation about
+-- the vertical from C0 toward C90
+ALTER TABLE luminaires ADD COLUMN aim_tilt REAL NOT NULL DEFAULT 0;
+ALTER TABLE luminaires ADD COLUMN aim_rotation REAL NOT NULL DEFAULT 0;
@@ -0,0 +1,12 @@
+-- Create recompute_runs table
+-- Records each catalog-wide recompute of the cached metrics and the records
+-- whose flux or efficacy moved by more than the run's threshold
+CREATE TABLE IF NOT EXISTS recompute_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    threshold REAL NOT NULL,
+    started_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at DATETIME,
+    checked INTEGER NOT NULL DEFAULT 0,
+    changed INTEGER NOT NULL DEFAULT 0,
+    report TEXT NOT NULL DEFAULT '[]'
+);
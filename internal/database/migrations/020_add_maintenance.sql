@@ -0,0 +1,5 @@
+-- Add lumen maintenance data for light loss factor calculations
+-- Factors are 0..1 with 0 meaning unknown; rated life is in hours
+ALTER TABLE luminaires ADD COLUMN lamp_lumen_depreciation REAL NOT NULL DEFAULT 0;
+ALTER TABLE luminaires ADD COLUMN driver_maintenance_factor REAL NOT NULL DEFAULT 0;
+ALTER TABLE luminaires ADD COLUMN rated_life INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,21 @@
+-- Create photometric_conditions table
+-- Stores further measurements of a luminaire under a named operating
+-- condition, such as another ambient temperature or dimming level
+CREATE TABLE IF NOT EXISTS photometric_conditions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    luminaire_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    ambient_temp REAL,
+    dim_level REAL,
+    input_watts REAL NOT NULL DEFAULT 0,
+    luminous_flux REAL NOT NULL DEFAULT 0,
+    vertical_angles TEXT NOT NULL DEFAULT '',
+    horizontal_angles TEXT NOT NULL DEFAULT '',
+    candela_values TEXT NOT NULL DEFAULT '',
+    extensions TEXT NOT NULL DEFAULT '',
+    file_hash TEXT NOT NULL DEFAULT '',
+    original_filename TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (luminaire_id, name),
+    FOREIGN KEY (luminaire_id) REFERENCES luminaires(id) ON DELETE CASCADE
+);
@@ -0,0 +1,12 @@
+-- Add the floodlight beam of type B photometry to the cached metrics
+-- NULL for other photometric types
+ALTER TABLE luminaire_metrics ADD COLUMN horizontal_spread REAL;
+ALTER TABLE luminaire_metrics ADD COLUMN vertical_spread REAL;
+ALTER TABLE luminaire_metrics ADD COLUMN peak_horizontal REAL;
+ALTER TABLE luminaire_metrics ADD COLUMN peak_vertical REAL;
+ALTER TABLE luminaire_metrics ADD COLUMN peak_intensity REAL;
+ALTER TABLE luminaire_metrics ADD COLUMN nema_type TEXT;
+
+-- Drop the cached metrics of type B records so they are computed again,
+-- with the floodlight beam, on startup or first use
+DELETE FROM luminaire_metrics WHERE luminaire_id IN (SELECT id FROM luminaires WHERE photometric_type = 2);
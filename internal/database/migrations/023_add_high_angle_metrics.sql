@@ -0,0 +1,9 @@
+-- Add the 65°-90° zone summary to the cached metrics
+-- Luminances are NULL when the luminous area is unknown
+ALTER TABLE luminaire_metrics ADD COLUMN high_angle_mean_intensity REAL NOT NULL DEFAULT 0;
+ALTER TABLE luminaire_metrics ADD COLUMN high_angle_max_intensity REAL NOT NULL DEFAULT 0;
+ALTER TABLE luminaire_metrics ADD COLUMN high_angle_luminance REAL;
+ALTER TABLE luminaire_metrics ADD COLUMN high_angle_max_luminance REAL;
+
+-- Drop every cached row so the zone is computed on startup or first use
+DELETE FROM luminaire_metrics;
@@ -0,0 +1,36 @@
+-- Add the heights of the luminous area facing each main C-plane, in metres
+ALTER TABLE luminaires ADD COLUMN luminous_height_c0 REAL NOT NULL DEFAULT 0;
+ALTER TABLE luminaires ADD COLUMN luminous_height_c90 REAL NOT NULL DEFAULT 0;
+ALTER TABLE luminaires ADD COLUMN luminous_height_c180 REAL NOT NULL DEFAULT 0;
+ALTER TABLE luminaires ADD COLUMN luminous_height_c270 REAL NOT NULL DEFAULT 0;
+
+-- Move the heights stored as EULUMDAT extensions (mm) onto the luminaire
+UPDATE luminaires SET
+    luminous_height_c0 = COALESCE((SELECT json_extract(extensions, '$."ldt:luminous_height_c0"') / 1000.0
+        FROM photometric_data WHERE luminaire_id = luminaires.id AND json_valid(extensions)), 0),
+    luminous_height_c90 = COALESCE((SELECT json_extract(extensions, '$."ldt:luminous_height_c90"') / 1000.0
+        FROM photometric_data WHERE luminaire_id = luminaires.id AND json_valid(extensions)), 0),
+    luminous_height_c180 = COALESCE((SELECT json_extract(extensions, '$."ldt:luminous_height_c180"') / 1000.0
+        FROM photometric_data WHERE luminaire_id = luminaires.id AND json_valid(extensions)), 0),
+    luminous_height_c270 = COALESCE((SELECT json_extract(extensions, '$."ldt:luminous_height_c270"') / 1000.0
+        FROM photometric_data WHERE luminaire_id = luminaires.id AND json_valid(extensions)), 0);
+
+-- and the IES luminous height, in the file's units, onto all four
+UPDATE luminaires SET luminous_height_c0 = h, luminous_height_c90 = h,
+    luminous_height_c180 = h, luminous_height_c270 = h
+FROM (SELECT luminaire_id, ABS(json_extract(extensions, '$."ies:luminous_height"')) AS h
+    FROM photometric_data WHERE json_valid(extensions)
+        AND json_extract(extensions, '$."ies:luminous_height"') IS NOT NULL) AS ies
+WHERE ies.luminaire_id = luminaires.id;
+UPDATE luminaires SET luminous_height_c0 = luminous_height_c0 * 0.3048,
+    luminous_height_c90 = luminous_height_c90 * 0.3048,
+    luminous_height_c180 = luminous_height_c180 * 0.3048,
+    luminous_height_c270 = luminous_height_c270 * 0.3048
+WHERE units_type = 'Imperial';
+
+UPDATE photometric_data SET extensions = json_remove(extensions,
+    '$."ldt:luminous_height_c0"', '$."ldt:luminous_height_c90"',
+    '$."ldt:luminous_height_c180"', '$."ldt:luminous_height_c270"',
+    '$."ies:luminous_height"')
+WHERE json_valid(extensions);
+UPDATE photometric_data SET extensions = '' WHERE extensions = '{}';
@@ -0,0 +1,25 @@
+-- Create luminaire_components table
+-- Stores the independent emitters of a segmented luminaire, such as the up
+-- and down light of a pendant: where each sits relative to the photometric
+-- centre, its luminous opening and, when measured, its own distribution
+CREATE TABLE IF NOT EXISTS luminaire_components (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    luminaire_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    offset_x REAL NOT NULL DEFAULT 0,
+    offset_y REAL NOT NULL DEFAULT 0,
+    offset_z REAL NOT NULL DEFAULT 0,
+    luminous_length REAL NOT NULL DEFAULT 0,
+    luminous_width REAL NOT NULL DEFAULT 0,
+    luminous_height REAL NOT NULL DEFAULT 0,
+    input_watts REAL NOT NULL DEFAULT 0,
+    luminous_flux REAL NOT NULL DEFAULT 0,
+    vertical_angles TEXT NOT NULL DEFAULT '',
+    horizontal_angles TEXT NOT NULL DEFAULT '',
+    candela_values TEXT NOT NULL DEFAULT '',
+    file_hash TEXT NOT NULL DEFAULT '',
+    original_filename TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (luminaire_id, name),
+    FOREIGN KEY (luminaire_id) REFERENCES luminaires(id) ON DELETE CASCADE
+);
@@ -0,0 +1,5 @@
+-- Cache a small polar-curve SVG per luminaire for catalog listings
+ALTER TABLE luminaire_metrics ADD COLUMN thumbnail TEXT NOT NULL DEFAULT '';
+
+-- Drop the cached rows so the backfill draws every thumbnail
+DELETE FROM luminaire_metrics;
@@ -0,0 +1,22 @@
+-- Create luminaire_test_reports table
+-- Stores the electrical, photometric and colour results of an LM-79 test
+-- report (with TM-30 fidelity and gamut when given) to cross-check against
+-- the photometric file
+CREATE TABLE IF NOT EXISTS luminaire_test_reports (
+    luminaire_id INTEGER PRIMARY KEY,
+    report_number TEXT NOT NULL DEFAULT '',
+    lab TEXT NOT NULL DEFAULT '',
+    input_watts REAL,
+    power_factor REAL,
+    thd REAL,
+    flux REAL,
+    cie_x REAL,
+    cie_y REAL,
+    cct INTEGER,
+    cri INTEGER,
+    tm30_rf REAL,
+    tm30_rg REAL,
+    source TEXT NOT NULL DEFAULT '',
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (luminaire_id) REFERENCES luminaires(id) ON DELETE CASCADE
+);
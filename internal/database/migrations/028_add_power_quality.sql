@@ -0,0 +1,6 @@
+-- Add power quality fields to luminaires
+-- Current THD in percent, peak inrush current in amperes and the mains
+-- frequency in hertz; zero when unknown
+ALTER TABLE luminaires ADD COLUMN thd REAL NOT NULL DEFAULT 0;
+ALTER TABLE luminaires ADD COLUMN inrush_current REAL NOT NULL DEFAULT 0;
+ALTER TABLE luminaires ADD COLUMN frequency REAL NOT NULL DEFAULT 0;
@@ -0,0 +1,25 @@
+-- Create drivers table
+-- Drivers and ballasts entered once and shared by the luminaires using them
+CREATE TABLE IF NOT EXISTS drivers (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    manufacturer TEXT NOT NULL DEFAULT '',
+    model TEXT NOT NULL DEFAULT '',
+    watts REAL NOT NULL DEFAULT 0,
+    dimming_protocol TEXT NOT NULL DEFAULT '',
+    efficiency REAL NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create luminaire_drivers table
+-- Links each luminaire to at most one driver
+CREATE TABLE IF NOT EXISTS luminaire_drivers (
+    luminaire_id INTEGER PRIMARY KEY,
+    driver_id INTEGER NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (luminaire_id) REFERENCES luminaires(id) ON DELETE CASCADE,
+    FOREIGN KEY (driver_id) REFERENCES drivers(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_luminaire_drivers_driver_id ON luminaire_drivers(driver_id);
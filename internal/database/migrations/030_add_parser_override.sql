@@ -0,0 +1,3 @@
+-- Record the reader an upload forced with parser= or source_format=
+-- Empty when the file name decided the format
+ALTER TABLE luminaires ADD COLUMN parser_override TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,11 @@
+-- Create luminaire_sources table
+-- The file each luminaire was uploaded from, as read, with the format its
+-- reader took it for
+CREATE TABLE IF NOT EXISTS luminaire_sources (
+    luminaire_id INTEGER PRIMARY KEY,
+    filename TEXT NOT NULL DEFAULT '',
+    source_format TEXT NOT NULL DEFAULT '',
+    data BLOB NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (luminaire_id) REFERENCES luminaires(id) ON DELETE CASCADE
+);
@@ -0,0 +1,6 @@
+-- Mark luminaires merged into another record instead of deleting them
+-- merged_into is the record that took their place
+ALTER TABLE luminaires ADD COLUMN deleted_at DATETIME;
+ALTER TABLE luminaires ADD COLUMN merged_into INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_luminaires_deleted_at ON luminaires(deleted_at);
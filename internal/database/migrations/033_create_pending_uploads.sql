@@ -0,0 +1,11 @@
+-- Create pending_uploads table
+-- Uploads parked until their manufacturer and model are supplied, kept in
+-- the database so the follow-up request may reach any instance
+CREATE TABLE IF NOT EXISTS pending_uploads (
+    file_hash TEXT PRIMARY KEY,
+    filename TEXT NOT NULL DEFAULT '',
+    data BLOB NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_pending_uploads_created_at ON pending_uploads(created_at);
@@ -0,0 +1,9 @@
+-- Create feature_flags table
+-- Overrides of the feature flags; organization '' is the whole deployment
+CREATE TABLE IF NOT EXISTS feature_flags (
+    organization TEXT NOT NULL DEFAULT '',
+    name TEXT NOT NULL,
+    enabled BOOLEAN NOT NULL,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (organization, name)
+);
@@ -0,0 +1,20 @@
+-- Create luminaire_orientations table
+-- Stores the orientation variants a lab delivers with a base file, such as
+-- the luminaire measured tilted or its mirrored distribution, so they stay
+-- one luminaire rather than unrelated records
+CREATE TABLE IF NOT EXISTS luminaire_orientations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    luminaire_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    tilt REAL,
+    mirrored BOOLEAN NOT NULL DEFAULT 0,
+    vertical_angles TEXT NOT NULL DEFAULT '',
+    horizontal_angles TEXT NOT NULL DEFAULT '',
+    candela_values TEXT NOT NULL DEFAULT '',
+    extensions TEXT NOT NULL DEFAULT '',
+    file_hash TEXT NOT NULL DEFAULT '',
+    original_filename TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (luminaire_id, name),
+    FOREIGN KEY (luminaire_id) REFERENCES luminaires(id) ON DELETE CASCADE
+);
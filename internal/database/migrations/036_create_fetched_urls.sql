@@ -0,0 +1,8 @@
+-- Create fetched_urls table
+-- Photometric file URLs FetchURLs has imported, so a crawler that reports
+-- the same link again is answered without fetching it twice
+CREATE TABLE IF NOT EXISTS fetched_urls (
+    url TEXT PRIMARY KEY,
+    luminaire_id INTEGER NOT NULL,
+    fetched_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
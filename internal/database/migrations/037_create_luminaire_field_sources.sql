@@ -0,0 +1,9 @@
+-- Create luminaire_field_sources table
+-- Where each metadata field of a luminaire got its value: the file, the
+-- user, or the import profile's heuristics; fields without a row are unset
+CREATE TABLE IF NOT EXISTS luminaire_field_sources (
+    luminaire_id INTEGER NOT NULL,
+    field TEXT NOT NULL,
+    source TEXT NOT NULL,
+    PRIMARY KEY (luminaire_id, field)
+);
@@ -0,0 +1,30 @@
+-- Store angle lists as JSON arrays of numbers
+-- Earlier builds stored the fmt "%v" form of a []float64, "[0 5 10]";
+-- lists that are already valid JSON, such as "[0]", are left alone
+UPDATE photometric_data SET
+    vertical_angles = '[' || replace(trim(vertical_angles, '[] '), ' ', ',') || ']'
+    WHERE NOT json_valid(vertical_angles);
+UPDATE photometric_data SET
+    horizontal_angles = '[' || replace(trim(horizontal_angles, '[] '), ' ', ',') || ']'
+    WHERE NOT json_valid(horizontal_angles);
+
+UPDATE photometric_conditions SET
+    vertical_angles = '[' || replace(trim(vertical_angles, '[] '), ' ', ',') || ']'
+    WHERE NOT json_valid(vertical_angles);
+UPDATE photometric_conditions SET
+    horizontal_angles = '[' || replace(trim(horizontal_angles, '[] '), ' ', ',') || ']'
+    WHERE NOT json_valid(horizontal_angles);
+
+UPDATE luminaire_components SET
+    vertical_angles = '[' || replace(trim(vertical_angles, '[] '), ' ', ',') || ']'
+    WHERE NOT json_valid(vertical_angles);
+UPDATE luminaire_components SET
+    horizontal_angles = '[' || replace(trim(horizontal_angles, '[] '), ' ', ',') || ']'
+    WHERE NOT json_valid(horizontal_angles);
+
+UPDATE luminaire_orientations SET
+    vertical_angles = '[' || replace(trim(vertical_angles, '[] '), ' ', ',') || ']'
+    WHERE NOT json_valid(vertical_angles);
+UPDATE luminaire_orientations SET
+    horizontal_angles = '[' || replace(trim(horizontal_angles, '[] '), ' ', ',') || ']'
+    WHERE NOT json_valid(horizontal_angles);
@@ -1,6 +1,9 @@
 package database
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 type PhotometricType int
 
@@ -41,27 +44,140 @@ type Luminaire struct {
 	ColorTemp        int             `json:"color_temp"`
 	CRI              int             `json:"cri"`
 	FormatType       string          `json:"format_type"`
+	FormatVersion    string          `json:"format_version"`
+	FormatConfidence float64         `json:"format_confidence"`         // 0..1, see parser.ConfidenceDeclared
+	ParserOverride   string          `json:"parser_override,omitempty"` // reader the upload forced, whatever the file name said
 	SymmetryFlag     int             `json:"symmetry_flag"`
+	LuminousLength   float64         `json:"luminous_length"` // opening in metres; zero width means a disc
+	LuminousWidth    float64         `json:"luminous_width"`
+	AimTilt          float64         `json:"aim_tilt"` // intended aiming in degrees, see photometry.Aim
+	AimRotation      float64         `json:"aim_rotation"`
 	FileHash         string          `json:"file_hash"`
 	OriginalFilename string          `json:"original_filename"`
+	State            WorkflowState   `json:"state,omitempty"`
 	CreatedAt        time.Time       `json:"created_at"`
 	UpdatedAt        time.Time       `json:"updated_at"`
+
+	// Lumen maintenance: the lamp lumen depreciation and driver maintenance
+	// factors are 0..1, zero when unknown; RatedLife is in hours.
+	LampLumenDepreciation   float64 `json:"lamp_lumen_depreciation"`
+	DriverMaintenanceFactor float64 `json:"driver_maintenance_factor"`
+	RatedLife               int     `json:"rated_life"`
+
+	// Heights of the luminous area in metres, zero where it is flat or
+	// unknown: the emitting sides facing the C0, C90, C180 and C270 planes,
+	// as EULUMDAT lists them. An IES luminous height sets all four.
+	LuminousHeightC0   float64 `json:"luminous_height_c0"`
+	LuminousHeightC90  float64 `json:"luminous_height_c90"`
+	LuminousHeightC180 float64 `json:"luminous_height_c180"`
+	LuminousHeightC270 float64 `json:"luminous_height_c270"`
+
+	// Power quality, zero when unknown: the input current THD in percent,
+	// the peak inrush current in amperes and the mains frequency in hertz.
+	THD           float64 `json:"thd"`
+	InrushCurrent float64 `json:"inrush_current"`
+	Frequency     float64 `json:"frequency"`
+}
+
+// Shapes of the luminous area, see Luminaire.LuminousShape.
+const (
+	ShapePoint       = "point"
+	ShapeCircular    = "circular"
+	ShapeRectangular = "rectangular"
+)
+
+// LuminousShape is the shape of the luminous opening: a point when its size
+// is unknown, circular when it has a diameter but no width.
+func (l Luminaire) LuminousShape() string {
+	switch {
+	case l.LuminousLength <= 0:
+		return ShapePoint
+	case l.LuminousWidth <= 0:
+		return ShapeCircular
+	}
+	return ShapeRectangular
+}
+
+// LuminousHeight is the height of the tallest emitting side, the single
+// height formats without per-plane heights carry.
+func (l Luminaire) LuminousHeight() float64 {
+	return max(l.LuminousHeightC0, l.LuminousHeightC90, l.LuminousHeightC180, l.LuminousHeightC270)
+}
+
+// PowerQualityField is a power quality field with the range a luminaire
+// plausibly measures; zero is always allowed and means unknown.
+type PowerQualityField struct {
+	Name     string
+	Unit     string
+	Min, Max float64
+	Value    func(Luminaire) float64
+}
+
+// PowerQualityFields are checked on edit, import and validation. Inrush
+// beyond 500 A would trip any branch circuit; mains is 50 or 60 Hz.
+var PowerQualityFields = []PowerQualityField{
+	{"thd", "%", 0, 150, func(l Luminaire) float64 { return l.THD }},
+	{"inrush_current", "A", 0, 500, func(l Luminaire) float64 { return l.InrushCurrent }},
+	{"frequency", "Hz", 45, 65, func(l Luminaire) float64 { return l.Frequency }},
+}
+
+// Plausible reports whether v is unknown or within the field's range.
+func (f PowerQualityField) Plausible(v float64) bool {
+	return v == 0 || (v >= f.Min && v <= f.Max)
+}
+
+// Range describes the plausible values, e.g. "45 to 65 Hz".
+func (f PowerQualityField) Range() string {
+	return fmt.Sprintf("%g to %g %s", f.Min, f.Max, f.Unit)
+}
+
+// LightLossFactor is the product of the known maintenance factors, or zero
+// when neither is known.
+func (l Luminaire) LightLossFactor() float64 {
+	if l.LampLumenDepreciation <= 0 && l.DriverMaintenanceFactor <= 0 {
+		return 0
+	}
+	llf := 1.0
+	for _, f := range []float64{l.LampLumenDepreciation, l.DriverMaintenanceFactor} {
+		if f > 0 {
+			llf *= f
+		}
+	}
+	return llf
 }
 
 type PhotometricData struct {
-	ID                  int64     `json:"id"`
-	LuminaireID         int64     `json:"luminaire_id"`
-	VerticalAngles      string    `json:"vertical_angles"`
-	HorizontalAngles    string    `json:"horizontal_angles"`
-	CandelaValues       string    `json:"candela_values"`
-	NumVerticalAngles   int       `json:"num_vertical_angles"`
-	NumHorizontalAngles int       `json:"num_horizontal_angles"`
-	CreatedAt           time.Time `json:"created_at"`
+	ID                  int64      `json:"id"`
+	LuminaireID         int64      `json:"luminaire_id"`
+	VerticalAngles      []float64  `json:"vertical_angles"`
+	HorizontalAngles    []float64  `json:"horizontal_angles"`
+	CandelaValues       string     `json:"candela_values"`
+	NumVerticalAngles   int        `json:"num_vertical_angles"`
+	NumHorizontalAngles int        `json:"num_horizontal_angles"`
+	Extensions          Extensions `json:"extensions,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
 }
 
 type ParsedLuminaire struct {
 	Metadata         Luminaire
 	VerticalAngles   []float64
 	HorizontalAngles []float64
-	CandelaMatrix    [][]float64
+	// CandelaMatrix is indexed [horizontal][vertical]: one row per
+	// horizontal angle (C-plane), each holding one value per vertical angle.
+	CandelaMatrix [][]float64
+	Extensions    Extensions
+}
+
+// CheckShape reports a candela matrix that does not match the angle lists in
+// the [horizontal][vertical] orientation.
+func (l *ParsedLuminaire) CheckShape() error {
+	if len(l.CandelaMatrix) != len(l.HorizontalAngles) {
+		return fmt.Errorf("%d candela rows for %d horizontal angles", len(l.CandelaMatrix), len(l.HorizontalAngles))
+	}
+	for i, row := range l.CandelaMatrix {
+		if len(row) != len(l.VerticalAngles) {
+			return fmt.Errorf("candela row %d has %d values for %d vertical angles", i, len(row), len(l.VerticalAngles))
+		}
+	}
+	return nil
 }
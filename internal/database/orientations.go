@@ -0,0 +1,64 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrOrientationNotFound is returned by LoadOrientation for an orientation
+// the luminaire has no file of.
+var ErrOrientationNotFound = errors.New("orientation not found")
+
+// Orientation describes one orientation variant of a luminaire delivered
+// with its base file: "tilt30" measured at 30° tilt, or "mirrored" with the
+// distribution mirrored. The photometry itself is loaded with
+// LoadOrientation.
+type Orientation struct {
+	Name             string    `json:"name"`
+	Tilt             *float64  `json:"tilt,omitempty"` // degrees
+	Mirrored         bool      `json:"mirrored"`
+	FileHash         string    `json:"file_hash"`
+	OriginalFilename string    `json:"original_filename"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// ListOrientations returns the orientations stored for luminaire id by name.
+func ListOrientations(db *sql.DB, id int64) ([]Orientation, error) {
+	orientations := []Orientation{}
+	err := orientationStore.list(db, id, `name, tilt, mirrored, file_hash, original_filename, created_at`,
+		func(rows *sql.Rows) error {
+			var o Orientation
+			var tilt sql.NullFloat64
+			if err := rows.Scan(&o.Name, &tilt, &o.Mirrored, &o.FileHash, &o.OriginalFilename, &o.CreatedAt); err != nil {
+				return err
+			}
+			if tilt.Valid {
+				o.Tilt = &tilt.Float64
+			}
+			orientations = append(orientations, o)
+			return nil
+		})
+	return orientations, err
+}
+
+// SaveOrientation stores lum as orientation o of luminaire id, replacing
+// any earlier file of the same name, and fills in o's file hash.
+func SaveOrientation(db *sql.DB, id int64, o *Orientation, lum *ParsedLuminaire) error {
+	o.FileHash = lum.Metadata.FileHash
+	return orientationStore.save(db, id, o.Name, lum, o.OriginalFilename, []string{"tilt", "mirrored"}, o.Tilt, o.Mirrored)
+}
+
+// DeleteOrientation removes orientation name of luminaire id. It returns
+// ErrOrientationNotFound when there is none.
+func DeleteOrientation(db *sql.DB, id int64, name string) error {
+	return orientationStore.remove(db, id, name)
+}
+
+// LoadOrientation rebuilds luminaire id in orientation name: the metadata
+// of the luminaire with the distribution of the orientation. It returns
+// sql.ErrNoRows when the luminaire does not exist and
+// ErrOrientationNotFound when the orientation does not.
+func LoadOrientation(db *sql.DB, id int64, name string) (*ParsedLuminaire, error) {
+	return orientationStore.load(db, id, name, nil)
+}
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"database/sql"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SQLiteConfig tunes the SQLite connection for concurrent use. The defaults
+// let uploads running at the same time wait for each other instead of
+// failing with "database is locked".
+type SQLiteConfig struct {
+	// JournalMode is the journal mode, "wal" by default: readers no longer
+	// block the writer, nor the writer the readers.
+	JournalMode string
+	// BusyTimeout is how long a connection waits for a lock before giving
+	// up with SQLITE_BUSY.
+	BusyTimeout time.Duration
+	// TxLock is how transactions begin. "immediate" takes the write lock
+	// up front, so that two transactions that read before they write wait
+	// their turn rather than deadlock, which no busy timeout can resolve.
+	TxLock string
+	// MaxOpenConns, MaxIdleConns and ConnMaxLifetime bound the pool as
+	// sql.DB does; zero leaves the database/sql default.
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+// DefaultSQLiteConfig is WAL with a 5 second busy timeout and immediate
+// transactions.
+func DefaultSQLiteConfig() SQLiteConfig {
+	return SQLiteConfig{JournalMode: "wal", BusyTimeout: 5 * time.Second, TxLock: "immediate"}
+}
+
+// SQLiteConfigFromEnv is DefaultSQLiteConfig adjusted by SQLITE_JOURNAL_MODE,
+// SQLITE_BUSY_TIMEOUT (a duration such as 10s), SQLITE_TXLOCK,
+// SQLITE_MAX_OPEN_CONNS, SQLITE_MAX_IDLE_CONNS and SQLITE_CONN_MAX_LIFETIME.
+// Values that do not parse keep the default.
+func SQLiteConfigFromEnv() SQLiteConfig {
+	cfg := DefaultSQLiteConfig()
+	if v := os.Getenv("SQLITE_JOURNAL_MODE"); v != "" {
+		cfg.JournalMode = strings.ToLower(v)
+	}
+	if d, err := time.ParseDuration(os.Getenv("SQLITE_BUSY_TIMEOUT")); err == nil && d >= 0 {
+		cfg.BusyTimeout = d
+	}
+	if v := os.Getenv("SQLITE_TXLOCK"); v != "" {
+		cfg.TxLock = strings.ToLower(v)
+	}
+	if n, err := strconv.Atoi(os.Getenv("SQLITE_MAX_OPEN_CONNS")); err == nil && n >= 0 {
+		cfg.MaxOpenConns = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("SQLITE_MAX_IDLE_CONNS")); err == nil && n >= 0 {
+		cfg.MaxIdleConns = n
+	}
+	if d, err := time.ParseDuration(os.Getenv("SQLITE_CONN_MAX_LIFETIME")); err == nil && d >= 0 {
+		cfg.ConnMaxLifetime = d
+	}
+	return cfg
+}
+
+// DSN adds the connection settings to dsn as go-sqlite3 parameters, so that
+// every connection of the pool gets them. Parameters dsn already has win.
+func (c SQLiteConfig) DSN(dsn string) string {
+	base, query, _ := strings.Cut(dsn, "?")
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return dsn
+	}
+	set := func(key, value string, aliases ...string) {
+		for _, k := range append(aliases, key) {
+			if params.Has(k) {
+				return
+			}
+		}
+		if value != "" {
+			params.Set(key, value)
+		}
+	}
+	set("_journal_mode", c.JournalMode, "_journal")
+	set("_busy_timeout", strconv.FormatInt(c.BusyTimeout.Milliseconds(), 10), "_timeout")
+	set("_txlock", c.TxLock)
+	return base + "?" + params.Encode()
+}
+
+// Apply sets the pool limits on db.
+func (c SQLiteConfig) Apply(db *sql.DB) {
+	if c.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(c.MaxOpenConns)
+	}
+	if c.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(c.MaxIdleConns)
+	}
+	if c.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(c.ConnMaxLifetime)
+	}
+}
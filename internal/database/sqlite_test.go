@@ -0,0 +1,80 @@
+package database
+
+import (
+	"database/sql"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestSQLiteDSN(t *testing.T) {
+	cfg := DefaultSQLiteConfig()
+	tests := []struct{ dsn, want string }{
+		{"./db/test.db", "./db/test.db?_busy_timeout=5000&_journal_mode=wal&_txlock=immediate"},
+		{"file:test.db?cache=shared", "file:test.db?_busy_timeout=5000&_journal_mode=wal&_txlock=immediate&cache=shared"},
+		// Settings in the URL win over the configuration.
+		{"test.db?_journal=DELETE&_timeout=100", "test.db?_journal=DELETE&_timeout=100&_txlock=immediate"},
+	}
+	for _, tc := range tests {
+		if got := cfg.DSN(tc.dsn); got != tc.want {
+			t.Errorf("DSN(%q) = %q, want %q", tc.dsn, got, tc.want)
+		}
+	}
+}
+
+// TestConcurrentWrites runs transactions that read before they write from
+// several goroutines at once, which fails with "database is locked" under
+// the SQLite defaults.
+func TestConcurrentWrites(t *testing.T) {
+	cfg := DefaultSQLiteConfig()
+	db, err := sql.Open("sqlite3", cfg.DSN(filepath.Join(t.TempDir(), "test.db")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	cfg.Apply(db)
+	if _, err := db.Exec(`CREATE TABLE counter (n INTEGER)`); err != nil {
+		t.Fatal(err)
+	}
+	var mode string
+	if db.QueryRow(`PRAGMA journal_mode`).Scan(&mode); mode != "wal" {
+		t.Errorf("journal mode %q", mode)
+	}
+
+	const writers, writes = 8, 20
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*writes)
+	for range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range writes {
+				tx, err := db.Begin()
+				if err != nil {
+					errs <- err
+					return
+				}
+				var n int
+				tx.QueryRow(`SELECT COUNT(*) FROM counter`).Scan(&n)
+				if _, err := tx.Exec(`INSERT INTO counter (n) VALUES (?)`, n+1); err != nil {
+					tx.Rollback()
+					errs <- err
+					continue
+				}
+				if err := tx.Commit(); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	var rows, max int
+	db.QueryRow(`SELECT COUNT(*), MAX(n) FROM counter`).Scan(&rows, &max)
+	if rows != writers*writes || max != rows {
+		t.Errorf("%d rows counting to %d, want %d", rows, max, writers*writes)
+	}
+}
@@ -0,0 +1,118 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// variantStore keeps further photometry of a luminaire next to its main
+// measurement: one row per luminaire and name holding the distribution of
+// the variant file, its hash and original name, and columns of its own.
+// Operating conditions, components and orientations are stored this way.
+type variantStore struct {
+	table string
+	// kind names the variant in errors.
+	kind     string
+	notFound error
+	// extensions reports an extensions column; components have none.
+	extensions bool
+}
+
+var (
+	conditionStore   = variantStore{"photometric_conditions", "condition", ErrConditionNotFound, true}
+	componentStore   = variantStore{"luminaire_components", "component", ErrComponentNotFound, false}
+	orientationStore = variantStore{"luminaire_orientations", "orientation", ErrOrientationNotFound, true}
+)
+
+// save stores variant name of luminaire id, replacing any earlier one of
+// the same name: the distribution of lum, or none when lum is nil, with
+// the hash of the file it came from, and the columns of the variant's own
+// with their values.
+func (s variantStore) save(db *sql.DB, id int64, name string, lum *ParsedLuminaire, filename string, columns []string, values ...interface{}) error {
+	var vertAngles, horzAngles, candelaVals, extensions, fileHash string
+	if lum != nil {
+		vertAngles, horzAngles = EncodeAngles(lum.VerticalAngles), EncodeAngles(lum.HorizontalAngles)
+		candelaVals = EncodeCandela(lum.CandelaMatrix)
+		extensions = EncodeExtensions(lum.Extensions)
+		fileHash = lum.Metadata.FileHash
+	}
+	columns = append([]string{"luminaire_id", "name", "vertical_angles", "horizontal_angles", "candela_values"}, columns...)
+	args := append([]interface{}{id, name, vertAngles, horzAngles, candelaVals}, values...)
+	if s.extensions {
+		columns = append(columns, "extensions")
+		args = append(args, extensions)
+	}
+	columns = append(columns, "file_hash", "original_filename")
+	args = append(args, fileHash, filename)
+
+	marks := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+	_, err := db.Exec(`INSERT OR REPLACE INTO `+s.table+` (`+strings.Join(columns, ", ")+`) VALUES (`+marks+`)`, args...)
+	return err
+}
+
+// list calls scan on the row of every variant of luminaire id by name,
+// selecting columns.
+func (s variantStore) list(db *sql.DB, id int64, columns string, scan func(*sql.Rows) error) error {
+	rows, err := db.Query(`SELECT `+columns+` FROM `+s.table+` WHERE luminaire_id = ? ORDER BY name`, id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// load rebuilds luminaire id with the distribution of variant name. The
+// metadata, file hash included, stays the luminaire's, so exports name the
+// record they belong to; the hash of the variant file is listed with the
+// variant. columns of the variant's own are scanned into dest. It returns
+// sql.ErrNoRows when the luminaire does not exist and s.notFound when the
+// variant does not.
+func (s variantStore) load(db *sql.DB, id int64, name string, columns []string, dest ...interface{}) (*ParsedLuminaire, error) {
+	lum, err := LoadParsedLuminaire(db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var vertAngles, horzAngles, candelaVals, extensions string
+	columns = append([]string{"vertical_angles", "horizontal_angles", "candela_values"}, columns...)
+	dest = append([]interface{}{&vertAngles, &horzAngles, &candelaVals}, dest...)
+	if s.extensions {
+		columns = append(columns, "extensions")
+		dest = append(dest, &extensions)
+	}
+	err = db.QueryRow(`SELECT `+strings.Join(columns, ", ")+` FROM `+s.table+` WHERE luminaire_id = ? AND name = ?`, id, name).Scan(dest...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", s.notFound, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", s.kind, name, err)
+	}
+
+	lum.VerticalAngles = DecodeAngles(vertAngles)
+	lum.HorizontalAngles = DecodeAngles(horzAngles)
+	lum.CandelaMatrix = DecodeCandela(candelaVals)
+	lum.Extensions = DecodeExtensions(extensions)
+	return lum, nil
+}
+
+// remove deletes variant name of luminaire id. It returns s.notFound when
+// there is none.
+func (s variantStore) remove(db *sql.DB, id int64, name string) error {
+	res, err := db.Exec(`DELETE FROM `+s.table+` WHERE luminaire_id = ? AND name = ?`, id, name)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("%w: %s", s.notFound, name)
+	}
+	return nil
+}
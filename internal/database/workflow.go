@@ -0,0 +1,81 @@
+package database
+
+import "fmt"
+
+// WorkflowState is where a luminaire stands in review. Records move
+// draft → in_review → approved → published; only approved and published
+// records count as released.
+type WorkflowState string
+
+const (
+	StateDraft     WorkflowState = "draft"
+	StateInReview  WorkflowState = "in_review"
+	StateApproved  WorkflowState = "approved"
+	StatePublished WorkflowState = "published"
+)
+
+// workflowOrder ranks the states along the workflow.
+var workflowOrder = map[WorkflowState]int{StateDraft: 1, StateInReview: 2, StateApproved: 3, StatePublished: 4}
+
+// ParseWorkflowState validates a state name.
+func ParseWorkflowState(s string) (WorkflowState, error) {
+	switch state := WorkflowState(s); state {
+	case StateDraft, StateInReview, StateApproved, StatePublished:
+		return state, nil
+	}
+	return "", fmt.Errorf("unknown workflow state %q (want draft, in_review, approved or published)", s)
+}
+
+// Released reports whether the photometry has passed review.
+func (s WorkflowState) Released() bool {
+	return s.AtLeast(StateApproved)
+}
+
+// AtLeast reports whether s has come as far along the workflow as min. Every
+// state is at least the empty state.
+func (s WorkflowState) AtLeast(min WorkflowState) bool {
+	return workflowOrder[s] >= workflowOrder[min]
+}
+
+// Role is what a caller may do in the review workflow. Each role may do
+// everything the roles below it may.
+type Role int
+
+const (
+	RoleNone Role = iota
+	RoleEditor
+	RoleReviewer
+	RolePublisher
+)
+
+func (r Role) String() string {
+	switch r {
+	case RoleEditor:
+		return "editor"
+	case RoleReviewer:
+		return "reviewer"
+	case RolePublisher:
+		return "publisher"
+	}
+	return "none"
+}
+
+// workflowTransitions lists the allowed moves and the least role that may
+// make each: editors submit and withdraw, reviewers approve, reject and
+// revoke, publishers publish and unpublish.
+var workflowTransitions = map[WorkflowState]map[WorkflowState]Role{
+	StateDraft:     {StateInReview: RoleEditor},
+	StateInReview:  {StateDraft: RoleEditor, StateApproved: RoleReviewer},
+	StateApproved:  {StateDraft: RoleReviewer, StatePublished: RolePublisher},
+	StatePublished: {StateApproved: RolePublisher},
+}
+
+// TransitionRole returns the least role allowed to move a record from one
+// state to another, or an error when the move is not part of the workflow.
+func TransitionRole(from, to WorkflowState) (Role, error) {
+	role, ok := workflowTransitions[from][to]
+	if !ok {
+		return RoleNone, fmt.Errorf("cannot move from %s to %s", from, to)
+	}
+	return role, nil
+}
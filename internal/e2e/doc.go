@@ -0,0 +1,4 @@
+// Package e2e drives the complete API, as NewServer assembles it, over HTTP
+// against a temporary database. It holds only tests; run them with
+// make itest or go test ./internal/e2e.
+package e2e
@@ -0,0 +1,315 @@
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"illuminate/internal/database"
+	"illuminate/internal/parser"
+	"illuminate/internal/server"
+	"illuminate/internal/synth"
+)
+
+// client talks to one server started by start.
+type client struct {
+	t    *testing.T
+	base string
+}
+
+// start runs the full server on a fresh database in a temporary directory
+// and shuts both down when the test ends.
+func start(t *testing.T) *client {
+	t.Helper()
+	db, err := database.Open(filepath.Join(t.TempDir(), "e2e.db"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := server.NewServerWithDatabase(db)
+	ts := httptest.NewServer(srv.Handler)
+	t.Cleanup(func() {
+		ts.Close()
+		srv.Shutdown(context.Background())
+		db.Close()
+	})
+	return &client{t: t, base: ts.URL}
+}
+
+// do sends req and returns the status, headers and body of the response.
+func (c *client) do(req *http.Request) (int, http.Header, []byte) {
+	c.t.Helper()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return resp.StatusCode, resp.Header, body
+}
+
+// json sends a request and decodes its JSON answer, failing the test when
+// the status is not want or the body is not a JSON object.
+func (c *client) json(method, path string, body io.Reader, contentType string, want int) map[string]interface{} {
+	c.t.Helper()
+	req, err := http.NewRequest(method, c.base+path, body)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	status, header, data := c.do(req)
+	if status != want {
+		c.t.Fatalf("%s %s: status %d, want %d: %s", method, path, status, want, data)
+	}
+	if ct := header.Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		c.t.Fatalf("%s %s: Content-Type %q", method, path, ct)
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		c.t.Fatalf("%s %s: %v: %s", method, path, err, data)
+	}
+	return out
+}
+
+// upload posts one file to /api/v1/luminaires.
+func (c *client) upload(name string, data []byte) map[string]interface{} {
+	c.t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, err := w.CreateFormFile("file", name)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	part.Write(data)
+	w.Close()
+	return c.json(http.MethodPost, "/api/v1/luminaires", &body, w.FormDataContentType(), http.StatusOK)
+}
+
+// kind names the JSON types a schema asks for.
+type kind int
+
+const (
+	str kind = iota
+	number
+	object
+	array
+	boolean
+)
+
+// schema lists the fields a response must have and their JSON types.
+type schema map[string]kind
+
+// check fails the test when v lacks a field of s or has it with another type.
+func (s schema) check(t *testing.T, what string, v map[string]interface{}) {
+	t.Helper()
+	for field, want := range s {
+		value, ok := v[field]
+		if !ok {
+			t.Errorf("%s: no %s in %v", what, field, v)
+			continue
+		}
+		var got bool
+		switch want {
+		case str:
+			_, got = value.(string)
+		case number:
+			_, got = value.(float64)
+		case object:
+			_, got = value.(map[string]interface{})
+		case array:
+			_, got = value.([]interface{})
+		case boolean:
+			_, got = value.(bool)
+		}
+		if !got {
+			t.Errorf("%s: %s is %T", what, field, value)
+		}
+	}
+}
+
+var (
+	metadataRequiredSchema = schema{"status": str, "missing": array, "luminaire": object, "file_hash": str}
+	uploadedSchema         = schema{"status": str, "luminaire_id": number}
+	luminaireSchema        = schema{
+		"luminaire": object, "photometric_data": object, "grid": object,
+		"goniometer": object, "luminous_shape": str,
+	}
+	metadataSchema = schema{
+		"id": number, "manufacturer": str, "model": str, "format_type": str,
+		"file_hash": str, "original_filename": str, "state": str, "created_at": str,
+	}
+	photometricSchema = schema{"vertical_angles": array, "horizontal_angles": array, "candela_values": str}
+	listSchema        = schema{"luminaires": array}
+	listRowSchema     = schema{
+		"id": number, "manufacturer": str, "model": str, "format_type": str,
+		"state": str, "created_at": str,
+	}
+	errorSchema = schema{"error": str, "code": str}
+)
+
+// anonymous renders a synthetic luminaire in format with neither a
+// manufacturer nor a model, so the upload asks for them.
+func anonymous(t *testing.T, format string) []byte {
+	t.Helper()
+	lum, err := synth.Generate(synth.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lum.Metadata.Manufacturer, lum.Metadata.Model, lum.Metadata.LuminaireDesc = "", "", ""
+	p, err := parser.GetParser("x." + format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := parser.Encode(p, lum, parser.WriteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format == "ldt" {
+		// The EULUMDAT writer fills in the company, the luminaire name and
+		// number (lines 1, 9 and 10), which the reader takes as manufacturer
+		// and model.
+		lines := strings.Split(string(data), "\n")
+		for _, i := range []int{0, 8, 9} {
+			if strings.HasSuffix(lines[i], "\r") {
+				lines[i] = "\r"
+			} else {
+				lines[i] = ""
+			}
+		}
+		data = []byte(strings.Join(lines, "\n"))
+	}
+	return data
+}
+
+// TestLuminaireLifecycle takes a file of each format through upload,
+// the metadata handshake, reading, export to every format and deletion.
+// Every export must read back.
+func TestLuminaireLifecycle(t *testing.T) {
+	c := start(t)
+	for _, format := range []string{"ies", "ldt", "cie"} {
+		t.Run(format, func(t *testing.T) {
+			c.t = t
+			name := "anonymous." + format
+
+			parked := c.upload(name, anonymous(t, format))
+			metadataRequiredSchema.check(t, "upload", parked)
+			if parked["status"] != "metadata_required" {
+				t.Fatalf("upload status %v, want metadata_required", parked["status"])
+			}
+			if missing := fmt.Sprint(parked["missing"]); missing != "[manufacturer model]" {
+				t.Errorf("missing = %s", missing)
+			}
+
+			form := url.Values{
+				"file_hash":         {parked["file_hash"].(string)},
+				"original_filename": {name},
+				"manufacturer":      {"Acme"},
+				"model":             {"E2E-" + strings.ToUpper(format)},
+				"catalog_number":    {"E2E-1"},
+			}
+			done := c.json(http.MethodPost, "/api/v1/luminaires/with-metadata", strings.NewReader(form.Encode()),
+				"application/x-www-form-urlencoded", http.StatusOK)
+			uploadedSchema.check(t, "with-metadata", done)
+			if done["status"] != "uploaded" {
+				t.Fatalf("with-metadata status %v", done["status"])
+			}
+			id := int64(done["luminaire_id"].(float64))
+			path := fmt.Sprintf("/api/v1/luminaires/%d", id)
+
+			got := c.json(http.MethodGet, path, nil, "", http.StatusOK)
+			luminaireSchema.check(t, "get", got)
+			meta, _ := got["luminaire"].(map[string]interface{})
+			metadataSchema.check(t, "get luminaire", meta)
+			photometricSchema.check(t, "get photometric_data", got["photometric_data"].(map[string]interface{}))
+			if meta["manufacturer"] != "Acme" || meta["model"] != form.Get("model") || meta["original_filename"] != name {
+				t.Errorf("stored %v %v %v", meta["manufacturer"], meta["model"], meta["original_filename"])
+			}
+			if want := parser.DetectFormat(name); meta["format_type"] != want {
+				t.Errorf("format_type = %v, want %s", meta["format_type"], want)
+			}
+
+			list := c.json(http.MethodGet, "/api/v1/luminaires", nil, "", http.StatusOK)
+			listSchema.check(t, "list", list)
+			listed := false
+			for _, row := range list["luminaires"].([]interface{}) {
+				row := row.(map[string]interface{})
+				listRowSchema.check(t, "list row", row)
+				listed = listed || row["id"] == float64(id)
+			}
+			if !listed {
+				t.Errorf("luminaire %d is not listed", id)
+			}
+
+			for _, to := range []string{"ies", "ldt", "cie"} {
+				req, _ := http.NewRequest(http.MethodGet, c.base+path+"/export?format="+to, nil)
+				status, header, data := c.do(req)
+				if status != http.StatusOK {
+					t.Errorf("export to %s: status %d: %s", to, status, data)
+					continue
+				}
+				if cd := header.Get("Content-Disposition"); !strings.HasSuffix(cd, "."+to+`"`) {
+					t.Errorf("export to %s: Content-Disposition %q", to, cd)
+				}
+				if len(data) == 0 {
+					t.Errorf("export to %s is empty", to)
+				}
+				p, _ := parser.GetParser("x." + to)
+				back, err := p.ParseReader(bytes.NewReader(data), "export."+to)
+				if err != nil {
+					t.Errorf("export to %s does not read back: %v", to, err)
+					continue
+				}
+				if len(back.CandelaMatrix) == 0 {
+					t.Errorf("export to %s has no candela values", to)
+				}
+			}
+
+			deleted := c.json(http.MethodDelete, path, nil, "", http.StatusOK)
+			if deleted["status"] != "deleted" {
+				t.Errorf("delete: %v", deleted)
+			}
+			gone := c.json(http.MethodGet, path, nil, "", http.StatusNotFound)
+			errorSchema.check(t, "get after delete", gone)
+			if gone["code"] != "luminaire_not_found" {
+				t.Errorf("get after delete: %v", gone)
+			}
+		})
+	}
+}
+
+// TestErrors checks the shape of the answers to requests the API refuses.
+func TestErrors(t *testing.T) {
+	c := start(t)
+	errorSchema.check(t, "bad id", c.json(http.MethodGet, "/api/v1/luminaires/abc", nil, "", http.StatusBadRequest))
+	errorSchema.check(t, "unknown id", c.json(http.MethodGet, "/api/v1/luminaires/4711/validation", nil, "", http.StatusNotFound))
+
+	form := url.Values{"file_hash": {"no-such-upload"}, "original_filename": {"x.ies"}, "manufacturer": {"Acme"}, "model": {"X"}}
+	missing := c.json(http.MethodPost, "/api/v1/luminaires/with-metadata", strings.NewReader(form.Encode()),
+		"application/x-www-form-urlencoded", http.StatusBadRequest)
+	if _, ok := missing["error"].(string); !ok {
+		t.Errorf("metadata for an unknown upload: %v", missing)
+	}
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, _ := w.CreateFormFile("file", "notes.txt")
+	part.Write([]byte("not photometry"))
+	w.Close()
+	rejected := c.json(http.MethodPost, "/api/v1/luminaires", &body, w.FormDataContentType(), http.StatusBadRequest)
+	if _, ok := rejected["error"].(string); !ok {
+		t.Errorf("upload of an unknown format: %v", rejected)
+	}
+}
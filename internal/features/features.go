@@ -0,0 +1,251 @@
+// Package features switches experimental behaviour on and off per
+// deployment, and per organization on top of that: the import-only
+// parsers, the newer validation rules and the normalization pass that
+// applies import profiles to uploads.
+package features
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"illuminate/internal/logger"
+)
+
+// Flag is one switch.
+type Flag struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Default     bool   `json:"default"`
+}
+
+const (
+	ParserOXL             = "parser.oxl"
+	ParserTM14            = "parser.tm14"
+	ValidatorConsistency  = "validator.consistency"
+	ValidatorPowerQuality = "validator.power_quality"
+	Normalize             = "normalize"
+)
+
+// Known are the flags there are. Each defaults to the behaviour before it
+// became a flag.
+var Known = []Flag{
+	{Name: ParserOXL, Description: "read LITESTAR 4D OXL files", Default: true},
+	{Name: ParserTM14, Description: "read TM-14 (.cib, .tm14) files", Default: true},
+	{Name: ValidatorConsistency, Description: "compare declared values with those computed from the candela values", Default: true},
+	{Name: ValidatorPowerQuality, Description: "check THD, inrush current and mains frequency", Default: true},
+	{Name: Normalize, Description: "apply the manufacturer's import profile to uploaded metadata", Default: true},
+}
+
+var (
+	ErrUnknown   = errors.New("unknown feature flag")
+	errNotStored = errors.New("feature flag overrides are not stored")
+)
+
+// Lookup returns the known flag called name.
+func Lookup(name string) (Flag, bool) {
+	for _, f := range Known {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Flag{}, false
+}
+
+// Sources of a flag's value, most specific first.
+const (
+	SourceOrganization = "organization"
+	SourceDeployment   = "deployment"
+	SourceEnv          = "env"
+	SourceDefault      = "default"
+)
+
+// State is a flag as it resolves for one organization.
+type State struct {
+	Flag
+	Enabled bool   `json:"enabled"`
+	Source  string `json:"source"`
+}
+
+type override struct{ organization, name string }
+
+// Flags resolves flags from, in order: the organization's override, the
+// deployment's override (both stored in feature_flags, so every instance
+// sees them), FEATURE_FLAGS, and the flag's default. Overrides are re-read
+// at most every ttl. A nil *Flags gives every flag its default.
+type Flags struct {
+	db  *sql.DB
+	env map[string]bool
+	ttl time.Duration
+
+	mu        sync.Mutex
+	overrides map[override]bool
+	loaded    time.Time
+}
+
+func New(db *sql.DB, env map[string]bool, ttl time.Duration) *Flags {
+	return &Flags{db: db, env: env, ttl: ttl}
+}
+
+// NewFromEnv takes the deployment's flags from FEATURE_FLAGS, such as
+// "parser.tm14=false,normalize=false", and re-reads overrides every
+// FEATURE_FLAGS_TTL (default 10s).
+func NewFromEnv(db *sql.DB) *Flags {
+	ttl, err := time.ParseDuration(os.Getenv("FEATURE_FLAGS_TTL"))
+	if err != nil || ttl < 0 {
+		ttl = 10 * time.Second
+	}
+	env, err := ParseList(os.Getenv("FEATURE_FLAGS"))
+	if err != nil {
+		logger.Default.Errorf("FEATURE_FLAGS: %v", err)
+	}
+	return New(db, env, ttl)
+}
+
+// ParseList reads comma separated name=bool pairs; a bare name is enabled.
+// Unknown names are an error, and the pairs before them are returned.
+func ParseList(s string) (map[string]bool, error) {
+	flags := map[string]bool{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, value, found := strings.Cut(item, "=")
+		name = strings.TrimSpace(name)
+		if _, ok := Lookup(name); !ok {
+			return flags, fmt.Errorf("%w: %s", ErrUnknown, name)
+		}
+		enabled := true
+		if found {
+			var err error
+			if enabled, err = strconv.ParseBool(strings.TrimSpace(value)); err != nil {
+				return flags, fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		flags[name] = enabled
+	}
+	return flags, nil
+}
+
+// Enabled reports whether the flag called name is on for organization; ""
+// is the deployment. Unknown flags are off.
+func (f *Flags) Enabled(organization, name string) bool {
+	s, ok := f.resolve(organization, name)
+	return ok && s.Enabled
+}
+
+// States resolves every known flag for organization.
+func (f *Flags) States(organization string) []State {
+	states := make([]State, 0, len(Known))
+	for _, flag := range Known {
+		s, _ := f.resolve(organization, flag.Name)
+		states = append(states, s)
+	}
+	return states
+}
+
+func (f *Flags) resolve(organization, name string) (State, bool) {
+	flag, ok := Lookup(name)
+	if !ok {
+		return State{}, false
+	}
+	s := State{Flag: flag, Enabled: flag.Default, Source: SourceDefault}
+	if f == nil {
+		return s, true
+	}
+	if v, ok := f.env[name]; ok {
+		s.Enabled, s.Source = v, SourceEnv
+	}
+
+	overrides := f.load()
+	if v, ok := overrides[override{"", name}]; ok {
+		s.Enabled, s.Source = v, SourceDeployment
+	}
+	if organization != "" {
+		if v, ok := overrides[override{organization, name}]; ok {
+			s.Enabled, s.Source = v, SourceOrganization
+		}
+	}
+	return s, true
+}
+
+// load returns the stored overrides, re-reading them once ttl has passed.
+// When they cannot be read the last ones read are kept.
+func (f *Flags) load() map[override]bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.db == nil || (f.overrides != nil && time.Since(f.loaded) < f.ttl) {
+		return f.overrides
+	}
+
+	rows, err := f.db.Query(`SELECT organization, name, enabled FROM feature_flags`)
+	if err != nil {
+		logger.Default.Warnf("feature flags: %v", err)
+		return f.overrides
+	}
+	defer rows.Close()
+	overrides := map[override]bool{}
+	for rows.Next() {
+		var o override
+		var enabled bool
+		if err := rows.Scan(&o.organization, &o.name, &enabled); err != nil {
+			logger.Default.Warnf("feature flags: %v", err)
+			return f.overrides
+		}
+		overrides[o] = enabled
+	}
+	f.overrides, f.loaded = overrides, time.Now()
+	return overrides
+}
+
+// check validates an override of name.
+func check(name string) error {
+	if _, ok := Lookup(name); !ok {
+		return fmt.Errorf("%w: %s", ErrUnknown, name)
+	}
+	return nil
+}
+
+// Set overrides the flag called name for organization, or for the
+// deployment when organization is "".
+func (f *Flags) Set(organization, name string, enabled bool) error {
+	if err := check(name); err != nil {
+		return err
+	}
+	if f == nil || f.db == nil {
+		return errNotStored
+	}
+	_, err := f.db.Exec(`
+		INSERT OR REPLACE INTO feature_flags (organization, name, enabled, updated_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP)`, organization, name, enabled)
+	f.invalidate()
+	return err
+}
+
+// Reset removes the override of name for organization, so the next source
+// in line decides.
+func (f *Flags) Reset(organization, name string) error {
+	if err := check(name); err != nil {
+		return err
+	}
+	if f == nil || f.db == nil {
+		return errNotStored
+	}
+	_, err := f.db.Exec(`DELETE FROM feature_flags WHERE organization = ? AND name = ?`, organization, name)
+	f.invalidate()
+	return err
+}
+
+// invalidate makes the next lookup re-read the overrides. Other instances
+// see a change within their ttl.
+func (f *Flags) invalidate() {
+	f.mu.Lock()
+	f.overrides = nil
+	f.mu.Unlock()
+}
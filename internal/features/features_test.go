@@ -0,0 +1,80 @@
+package features
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestParseList(t *testing.T) {
+	flags, err := ParseList("parser.tm14=false, normalize")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flags[ParserTM14] || !flags[Normalize] || len(flags) != 2 {
+		t.Errorf("ParseList = %v", flags)
+	}
+	if _, err := ParseList("parser.gldf=true"); !errors.Is(err, ErrUnknown) {
+		t.Errorf("unknown flag: err = %v", err)
+	}
+}
+
+// TestFlags checks that each source overrides the ones after it: the
+// organization, the deployment, FEATURE_FLAGS and the default.
+func TestFlags(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "flags.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`CREATE TABLE feature_flags (
+		organization TEXT NOT NULL DEFAULT '', name TEXT NOT NULL, enabled BOOLEAN NOT NULL,
+		updated_at DATETIME, PRIMARY KEY (organization, name))`); err != nil {
+		t.Fatal(err)
+	}
+	f := New(db, map[string]bool{ParserOXL: false}, 0)
+
+	state := func(org, name string) State {
+		for _, s := range f.States(org) {
+			if s.Name == name {
+				return s
+			}
+		}
+		t.Fatalf("no state for %s", name)
+		return State{}
+	}
+	if s := state("", ParserTM14); !s.Enabled || s.Source != SourceDefault {
+		t.Errorf("default: %+v", s)
+	}
+	if s := state("acme", ParserOXL); s.Enabled || s.Source != SourceEnv {
+		t.Errorf("env: %+v", s)
+	}
+
+	if err := f.Set("", ParserTM14, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Set("acme", ParserTM14, true); err != nil {
+		t.Fatal(err)
+	}
+	if f.Enabled("", ParserTM14) || f.Enabled("other", ParserTM14) {
+		t.Error("the deployment override should apply to the deployment and other organizations")
+	}
+	if s := state("acme", ParserTM14); !s.Enabled || s.Source != SourceOrganization {
+		t.Errorf("organization: %+v", s)
+	}
+
+	if err := f.Reset("acme", ParserTM14); err != nil {
+		t.Fatal(err)
+	}
+	if s := state("acme", ParserTM14); s.Enabled || s.Source != SourceDeployment {
+		t.Errorf("after reset: %+v", s)
+	}
+
+	var none *Flags
+	if !none.Enabled("acme", Normalize) || none.Enabled("", "unknown") {
+		t.Error("a nil *Flags should give the defaults")
+	}
+}
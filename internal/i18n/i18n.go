@@ -0,0 +1,114 @@
+// Package i18n translates the messages the API returns. Every message has a
+// stable code that programs match on; the catalogs in messages/ map codes to
+// templates whose {name} placeholders take the message's parameters.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed messages/*.json
+var files embed.FS
+
+// Default is the language of a request that asks for none we have, and the
+// one every code is written in first.
+const Default = "en"
+
+// catalogs maps a language to its templates by code.
+var catalogs = map[string]map[string]string{}
+
+func init() {
+	entries, err := files.ReadDir("messages")
+	if err != nil {
+		panic(err)
+	}
+	for _, e := range entries {
+		data, err := files.ReadFile("messages/" + e.Name())
+		if err != nil {
+			panic(err)
+		}
+		catalog := map[string]string{}
+		if err := json.Unmarshal(data, &catalog); err != nil {
+			panic("i18n: " + e.Name() + ": " + err.Error())
+		}
+		catalogs[strings.TrimSuffix(e.Name(), path.Ext(e.Name()))] = catalog
+	}
+}
+
+// Languages returns the languages there are catalogs for.
+func Languages() []string {
+	langs := make([]string, 0, len(catalogs))
+	for lang := range catalogs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Codes returns the codes of the Default catalog.
+func Codes() []string {
+	codes := make([]string, 0, len(catalogs[Default]))
+	for code := range catalogs[Default] {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// Negotiate picks the language with the highest q-value in an
+// Accept-Language header that there is a catalog for, preferring earlier
+// entries on ties. Regional variants such as de-AT match their language;
+// anything else gives Default.
+func Negotiate(acceptLanguage string) string {
+	lang, bestQ := Default, 0.0
+	for _, entry := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(entry, ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		tag, _, _ = strings.Cut(tag, "-")
+		if _, ok := catalogs[tag]; !ok {
+			continue
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if k, v, found := strings.Cut(strings.TrimSpace(param), "="); found && strings.EqualFold(k, "q") {
+				if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q > bestQ {
+			lang, bestQ = tag, q
+		}
+	}
+	return lang
+}
+
+// Message fills in the template of code in lang, falling back to Default
+// when lang has none. ok is false for a code no catalog knows.
+func Message(lang, code string, params map[string]string) (msg string, ok bool) {
+	tmpl, ok := catalogs[lang][code]
+	if !ok {
+		if tmpl, ok = catalogs[Default][code]; !ok {
+			return "", false
+		}
+	}
+	return expand(tmpl, params), true
+}
+
+// expand replaces each {name} in tmpl with params[name]; unknown names are
+// left as they are.
+func expand(tmpl string, params map[string]string) string {
+	if len(params) == 0 {
+		return tmpl
+	}
+	pairs := make([]string, 0, 2*len(params))
+	for k, v := range params {
+		pairs = append(pairs, "{"+k+"}", v)
+	}
+	return strings.NewReplacer(pairs...).Replace(tmpl)
+}
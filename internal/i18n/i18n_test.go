@@ -0,0 +1,68 @@
+package i18n
+
+import (
+	"regexp"
+	"slices"
+	"testing"
+)
+
+// TestCatalogs checks that every language has a message for every code,
+// with the same placeholders as the English one.
+func TestCatalogs(t *testing.T) {
+	if langs := Languages(); !slices.Equal(langs, []string{"de", "en", "fr"}) {
+		t.Errorf("Languages() = %v", langs)
+	}
+	placeholder := regexp.MustCompile(`\{[a-z_]+\}`)
+	for _, lang := range Languages() {
+		for _, code := range Codes() {
+			tmpl, ok := catalogs[lang][code]
+			if !ok {
+				t.Errorf("%s: no message for %s", lang, code)
+				continue
+			}
+			want := placeholder.FindAllString(catalogs[Default][code], -1)
+			got := placeholder.FindAllString(tmpl, -1)
+			slices.Sort(want)
+			slices.Sort(got)
+			if !slices.Equal(got, want) {
+				t.Errorf("%s: %s has placeholders %v, want %v", lang, code, got, want)
+			}
+		}
+		for code := range catalogs[lang] {
+			if _, ok := catalogs[Default][code]; !ok {
+				t.Errorf("%s: %s is not in the %s catalog", lang, code, Default)
+			}
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	for header, want := range map[string]string{
+		"":                        "en",
+		"de":                      "de",
+		"de-AT, en;q=0.8":         "de",
+		"en;q=0.5, fr-CH;q=0.9":   "fr",
+		"ja, fr;q=0.3":            "fr",
+		"ja, *;q=0.5":             "en",
+		"fr;q=0, de;q=0":          "en",
+		"FR, de":                  "fr",
+		"es-ES,es;q=0.9,de;q=0.1": "de",
+	} {
+		if got := Negotiate(header); got != want {
+			t.Errorf("Negotiate(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestMessage(t *testing.T) {
+	params := map[string]string{"cct": "25000"}
+	if msg, _ := Message("de", "color_temperature_out_of_range", params); msg != "Farbtemperatur 25000 K außerhalb von 1000..20000 K" {
+		t.Errorf("de: %q", msg)
+	}
+	if msg, _ := Message("ja", "color_temperature_out_of_range", params); msg != "color temperature 25000 K outside 1000..20000 K" {
+		t.Errorf("unknown language: %q", msg)
+	}
+	if _, ok := Message("de", "no_such_code", nil); ok {
+		t.Error("an unknown code should not be found")
+	}
+}
@@ -0,0 +1,149 @@
+// Package label renders printable tags for sample fixtures: a QR code
+// linking to the luminaire next to its name and key metrics, as PNG for
+// label printers or as a single-page PDF.
+package label
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strings"
+
+	"illuminate/internal/pdf"
+	"illuminate/internal/qr"
+)
+
+// MaxLineLength caps each text line; longer lines are cut with "...".
+const MaxLineLength = 40
+
+// Label is the content of one tag.
+type Label struct {
+	Title string
+	Lines []string
+	// Link is encoded in the QR code.
+	Link string
+}
+
+func (l Label) lines() []string {
+	out := []string{clip(l.Title)}
+	for _, line := range l.Lines {
+		out = append(out, clip(line))
+	}
+	return out
+}
+
+func clip(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > MaxLineLength {
+		return string(r[:MaxLineLength-3]) + "..."
+	}
+	return s
+}
+
+// PNG geometry in pixels: QR modules, font scale and line spacing.
+const (
+	pngModule    = 4
+	pngFontScale = 2
+	pngLineStep  = 9 * pngFontScale
+	pngPadding   = 16
+)
+
+// WritePNG renders the label as a black and white PNG.
+func (l Label) WritePNG(w io.Writer) error {
+	code, err := qr.Encode([]byte(l.Link))
+	if err != nil {
+		return err
+	}
+	qrImg := code.Image(pngModule)
+	qrSide := qrImg.Bounds().Dx()
+
+	lines := l.lines()
+	textWidth := 0
+	for _, line := range lines {
+		textWidth = max(textWidth, len([]rune(line))*6*pngFontScale)
+	}
+	width := qrSide + textWidth + pngPadding
+	height := max(qrSide, len(lines)*pngLineStep+2*pngPadding)
+
+	img := image.NewPaletted(image.Rect(0, 0, width, height), color.Palette{color.White, color.Black})
+	for y := 0; y < qrSide; y++ {
+		for x := 0; x < qrSide; x++ {
+			img.SetColorIndex(x, y, qrImg.ColorIndexAt(x, y))
+		}
+	}
+
+	top := (height - len(lines)*pngLineStep) / 2
+	for i, line := range lines {
+		drawText(img, qrSide, top+i*pngLineStep, pngFontScale, line, i == 0)
+	}
+	return png.Encode(w, img)
+}
+
+// drawText draws s with its top-left corner at (x, y); bold doubles each
+// stroke one pixel to the right.
+func drawText(img *image.Paletted, x, y, scale int, s string, bold bool) {
+	for _, r := range s {
+		g := glyph(r)
+		for col, bits := range g {
+			for row := 0; row < 7; row++ {
+				if bits>>row&1 == 0 {
+					continue
+				}
+				for dy := 0; dy < scale; dy++ {
+					for dx := 0; dx < scale; dx++ {
+						img.SetColorIndex(x+col*scale+dx, y+row*scale+dy, 1)
+						if bold {
+							img.SetColorIndex(x+col*scale+dx+1, y+row*scale+dy, 1)
+						}
+					}
+				}
+			}
+		}
+		x += 6 * scale
+	}
+}
+
+// PDF page size: a 100 x 50 mm label.
+const (
+	pdfWidth  = 283.5
+	pdfHeight = 141.7
+)
+
+// WritePDF renders the label as a one-page PDF sized for a 100 x 50 mm
+// label.
+func (l Label) WritePDF(w io.Writer) error {
+	code, err := qr.Encode([]byte(l.Link))
+	if err != nil {
+		return err
+	}
+
+	doc := pdf.New()
+	doc.Width, doc.Height = pdfWidth, pdfHeight
+	doc.NewPage()
+
+	// The QR code fills the label height, quiet zone included.
+	module := pdfHeight / float64(code.Size+2*qr.QuietZone)
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if code.Dark(x, y) {
+				doc.Rect(float64(x+qr.QuietZone)*module, pdfHeight-float64(y+qr.QuietZone+1)*module, module, module)
+			}
+		}
+	}
+
+	left := pdfHeight
+	lines := l.lines()
+	y := pdfHeight/2 + float64(len(lines)-1)*6
+	for i, line := range lines {
+		size := 8.0
+		if i == 0 {
+			size = 10
+		}
+		doc.Text(left, y, size, i == 0, line)
+		y -= 12
+	}
+
+	_, err = doc.WriteTo(w)
+	return err
+}
@@ -0,0 +1,45 @@
+package label
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+
+	"illuminate/internal/qr"
+)
+
+func TestLabel(t *testing.T) {
+	l := Label{
+		Title: "Example Lighting DL-200",
+		Lines: []string{"Catalog: DL-200-830", "1000 lm, 10 W, 100 lm/W", strings.Repeat("long ", 20)},
+		Link:  "https://lab.example.com/luminaires/42",
+	}
+
+	var buf bytes.Buffer
+	if err := l.WritePNG(&buf); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The QR code sits in the top-left corner, the text to its right.
+	if b := img.Bounds(); b.Dx() <= b.Dy() {
+		t.Errorf("label is %dx%d", b.Dx(), b.Dy())
+	}
+	if r, _, _, _ := img.At(qr.QuietZone*pngModule+1, qr.QuietZone*pngModule+1).RGBA(); r != 0 {
+		t.Error("QR finder pattern missing")
+	}
+
+	buf.Reset()
+	if err := l.WritePDF(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{"/MediaBox [0 0 283.5 141.7]", "(Example Lighting DL-200) Tj", " re f\n", "...) Tj"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("PDF lacks %q", want)
+		}
+	}
+}
@@ -1,7 +1,10 @@
 package logger
 
 import (
+	"io"
 	"os"
+	"strings"
+	"sync"
 
 	"github.com/charmbracelet/log"
 )
@@ -9,12 +12,65 @@ import (
 var (
 	// Default logger instance
 	Default *log.Logger
+
+	mu      sync.Mutex
+	modules = map[string]*log.Logger{}
 )
 
 func init() {
-	Default = log.New(os.Stderr)
-	Default.SetPrefix("illuminate")
-	Default.SetLevel(log.InfoLevel)
+	Default = New(os.Stderr, "illuminate", os.Getenv("LOG_LEVEL"), os.Getenv("LOG_FORMAT"))
+}
+
+// New builds a logger writing to w. level is a charmbracelet/log level name
+// (debug, info, warn, error, fatal) and defaults to info; format is text,
+// json or logfmt and defaults to text.
+func New(w io.Writer, prefix, level, format string) *log.Logger {
+	l := log.NewWithOptions(w, log.Options{
+		Prefix:          prefix,
+		Level:           log.InfoLevel,
+		ReportTimestamp: format == "json" || format == "logfmt",
+		Formatter:       formatter(format),
+	})
+	if level != "" {
+		if lvl, err := log.ParseLevel(level); err == nil {
+			l.SetLevel(lvl)
+		} else {
+			l.Warnf("unknown log level %q, using info", level)
+		}
+	}
+	return l
+}
+
+func formatter(format string) log.Formatter {
+	switch strings.ToLower(format) {
+	case "json":
+		return log.JSONFormatter
+	case "logfmt":
+		return log.LogfmtFormatter
+	default:
+		return log.TextFormatter
+	}
+}
+
+// For returns the logger of one module, such as "server" or "parser". It
+// shares the output and format of Default, and LOG_LEVEL_<MODULE> overrides
+// LOG_LEVEL for it alone.
+func For(module string) *log.Logger {
+	mu.Lock()
+	defer mu.Unlock()
+	if l, ok := modules[module]; ok {
+		return l
+	}
+	l := Default.WithPrefix("illuminate/" + module)
+	if v := os.Getenv("LOG_LEVEL_" + strings.ToUpper(module)); v != "" {
+		if lvl, err := log.ParseLevel(v); err == nil {
+			l.SetLevel(lvl)
+		} else {
+			l.Warnf("unknown log level %q in LOG_LEVEL_%s", v, strings.ToUpper(module))
+		}
+	}
+	modules[module] = l
+	return l
 }
 
 // GetLogger returns the default logger instance
@@ -0,0 +1,54 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/log"
+)
+
+func TestNewLevelAndJSON(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, "test", "warn", "json")
+	if l.GetLevel() != log.WarnLevel {
+		t.Fatalf("level = %v, want warn", l.GetLevel())
+	}
+	l.Info("dropped")
+	l.Warn("kept", "files", 3)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, want 1: %q", len(lines), buf.String())
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("not JSON: %v", err)
+	}
+	if entry["msg"] != "kept" || entry["files"] != float64(3) {
+		t.Errorf("entry = %v", entry)
+	}
+}
+
+func TestNewUnknownLevel(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, "test", "loud", "")
+	if l.GetLevel() != log.InfoLevel {
+		t.Errorf("level = %v, want info", l.GetLevel())
+	}
+	if !strings.Contains(buf.String(), "unknown log level") {
+		t.Errorf("no warning logged: %q", buf.String())
+	}
+}
+
+func TestForModuleLevel(t *testing.T) {
+	t.Setenv("LOG_LEVEL_TESTMOD", "debug")
+	l := For("testmod")
+	if l.GetLevel() != log.DebugLevel {
+		t.Errorf("level = %v, want debug", l.GetLevel())
+	}
+	if For("testmod") != l {
+		t.Error("For returned a new logger for the same module")
+	}
+}
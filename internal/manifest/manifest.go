@@ -0,0 +1,113 @@
+// Package manifest reads the spreadsheets that accompany bulk imports: CSV
+// (comma, semicolon or tab separated) and XLSX workbooks.
+package manifest
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Row is one manifest line keyed by normalised column name.
+type Row struct {
+	// Line is the 1-based spreadsheet line the row came from.
+	Line   int
+	Fields map[string]string
+}
+
+// Get returns the trimmed value of a column, "" when absent.
+func (r Row) Get(column string) string {
+	return strings.TrimSpace(r.Fields[column])
+}
+
+// Read parses a manifest by its file extension. The first non-empty row is
+// the header; column names are lower-cased with spaces and dashes turned
+// into underscores, so "Catalog Number" reads as catalog_number. Rows with
+// no values are skipped.
+func Read(name string, data []byte) ([]Row, error) {
+	var records [][]string
+	var err error
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv", ".txt":
+		records, err = readCSV(data)
+	case ".xlsx":
+		records, err = readXLSX(data)
+	default:
+		return nil, fmt.Errorf("unsupported manifest format %q: use .csv or .xlsx", filepath.Ext(name))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toRows(records)
+}
+
+func readCSV(data []byte) ([][]string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comma = sniffDelimiter(data)
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV manifest: %w", err)
+	}
+	return records, nil
+}
+
+// sniffDelimiter picks the separator that occurs most often in the header
+// line; spreadsheet programs in decimal-comma locales write semicolons.
+func sniffDelimiter(data []byte) rune {
+	header, _, _ := bytes.Cut(data, []byte("\n"))
+	best, count := ',', bytes.Count(header, []byte(","))
+	for _, d := range []rune{';', '\t'} {
+		if n := bytes.Count(header, []byte(string(d))); n > count {
+			best, count = d, n
+		}
+	}
+	return best
+}
+
+func toRows(records [][]string) ([]Row, error) {
+	start := 0
+	for start < len(records) && blank(records[start]) {
+		start++
+	}
+	if start == len(records) {
+		return nil, fmt.Errorf("manifest is empty")
+	}
+
+	header := make([]string, len(records[start]))
+	for i, h := range records[start] {
+		header[i] = normalize(h)
+	}
+
+	var rows []Row
+	for i, record := range records[start+1:] {
+		if blank(record) {
+			continue
+		}
+		row := Row{Line: start + i + 2, Fields: map[string]string{}}
+		for j, v := range record {
+			if j < len(header) && header[j] != "" {
+				row.Fields[header[j]] = v
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func normalize(column string) string {
+	column = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(column)
+}
+
+func blank(record []string) bool {
+	for _, v := range record {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
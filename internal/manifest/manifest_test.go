@@ -0,0 +1,62 @@
+package manifest
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+)
+
+func TestReadCSV(t *testing.T) {
+	data := []byte("\xef\xbb\xbfFilename;Catalog Number;Input-Watts\nDL200.ies;DL-200;12,5\n;;\nDL300.ldt;DL-300;\n")
+	rows, err := Read("catalog.csv", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("%d rows", len(rows))
+	}
+	if got := rows[0].Get("catalog_number"); got != "DL-200" {
+		t.Errorf("catalog_number = %q", got)
+	}
+	if got := rows[0].Get("input_watts"); got != "12,5" {
+		t.Errorf("input_watts = %q", got)
+	}
+	if rows[1].Line != 4 || rows[1].Get("filename") != "DL300.ldt" {
+		t.Errorf("second row = %+v", rows[1])
+	}
+}
+
+func TestReadXLSX(t *testing.T) {
+	parts := map[string]string{
+		"xl/workbook.xml":            `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Catalog" sheetId="1" r:id="rId7"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId7" Target="worksheets/catalog.xml"/></Relationships>`,
+		"xl/sharedStrings.xml":       `<sst><si><t>Filename</t></si><si><t>Model</t></si><si><r><t>DL</t></r><r><t>200.ies</t></r></si></sst>`,
+		"xl/worksheets/catalog.xml": `<worksheet><sheetData>
+			<row r="1"><c r="A1" t="s"><v>0</v></c><c r="C1" t="s"><v>1</v></c><c r="D1" t="inlineStr"><is><t>Watts</t></is></c></row>
+			<row r="3"><c r="A3" t="s"><v>2</v></c><c r="C3" t="str"><v>Downlight 200</v></c><c r="D3"><v>12.5</v></c></row>
+		</sheetData></worksheet>`,
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range parts {
+		w, _ := zw.Create(name)
+		w.Write([]byte(content))
+	}
+	zw.Close()
+
+	rows, err := Read("catalog.xlsx", buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("%d rows", len(rows))
+	}
+	row := rows[0]
+	if row.Line != 3 || row.Get("filename") != "DL200.ies" || row.Get("model") != "Downlight 200" || row.Get("watts") != "12.5" {
+		t.Errorf("row = %+v", row)
+	}
+
+	if _, err := Read("catalog.ods", nil); err == nil {
+		t.Error("unsupported extension accepted")
+	}
+}
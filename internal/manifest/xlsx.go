@@ -0,0 +1,169 @@
+package manifest
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// maxXLSXPart caps how much of any one workbook part is decompressed.
+const maxXLSXPart = 64 << 20
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		RelID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxText `xml:"si"`
+}
+
+// xlsxText is a string item: plain text or rich-text runs.
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.T
+	}
+	var b strings.Builder
+	for _, r := range t.Runs {
+		b.WriteString(r.T)
+	}
+	return b.String()
+}
+
+type xlsxSheet struct {
+	Rows []struct {
+		R     int `xml:"r,attr"`
+		Cells []struct {
+			Ref    string   `xml:"r,attr"`
+			Type   string   `xml:"t,attr"`
+			Value  string   `xml:"v"`
+			Inline xlsxText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// readXLSX returns the cell text of the first worksheet of a workbook.
+func readXLSX(data []byte) ([][]string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid XLSX manifest: %w", err)
+	}
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	sheetPath := "xl/worksheets/sheet1.xml"
+	var wb xlsxWorkbook
+	var rels xlsxRelationships
+	if decodePart(files, "xl/workbook.xml", &wb) == nil && len(wb.Sheets) > 0 &&
+		decodePart(files, "xl/_rels/workbook.xml.rels", &rels) == nil {
+		for _, r := range rels.Relationships {
+			if r.ID == wb.Sheets[0].RelID {
+				if strings.HasPrefix(r.Target, "/") {
+					sheetPath = strings.TrimPrefix(r.Target, "/")
+				} else {
+					sheetPath = path.Join("xl", r.Target)
+				}
+			}
+		}
+	}
+
+	var shared xlsxSharedStrings
+	if _, ok := files["xl/sharedStrings.xml"]; ok {
+		if err := decodePart(files, "xl/sharedStrings.xml", &shared); err != nil {
+			return nil, fmt.Errorf("invalid XLSX manifest: %w", err)
+		}
+	}
+	var sheet xlsxSheet
+	if err := decodePart(files, sheetPath, &sheet); err != nil {
+		return nil, fmt.Errorf("invalid XLSX manifest: %w", err)
+	}
+
+	var records [][]string
+	for i, row := range sheet.Rows {
+		line := row.R
+		if line == 0 {
+			line = i + 1
+		}
+		// Keep line numbers: pad the rows the sheet leaves out.
+		for len(records) < line-1 {
+			records = append(records, nil)
+		}
+		var record []string
+		for j, cell := range row.Cells {
+			col := j
+			if cell.Ref != "" {
+				col = columnIndex(cell.Ref)
+			}
+			var value string
+			switch cell.Type {
+			case "s":
+				n, err := strconv.Atoi(cell.Value)
+				if err != nil || n < 0 || n >= len(shared.Items) {
+					return nil, fmt.Errorf("invalid XLSX manifest: cell %s: bad shared string %q", cell.Ref, cell.Value)
+				}
+				value = shared.Items[n].String()
+			case "inlineStr":
+				value = cell.Inline.String()
+			default:
+				value = cell.Value
+			}
+			for len(record) <= col {
+				record = append(record, "")
+			}
+			record[col] = value
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func decodePart(files map[string]*zip.File, name string, v any) error {
+	f, ok := files[name]
+	if !ok {
+		return fmt.Errorf("missing %s", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if err := xml.NewDecoder(io.LimitReader(rc, maxXLSXPart)).Decode(v); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// columnIndex turns the letters of a cell reference such as "AB12" into a
+// 0-based column index.
+func columnIndex(ref string) int {
+	col := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		col = col*26 + int(r-'A'+1)
+	}
+	return col - 1
+}
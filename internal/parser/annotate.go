@@ -0,0 +1,319 @@
+package parser
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Roles a line of a photometric file can play, see AnnotatedLine.
+const (
+	RoleBlank            = "blank"
+	RoleFormat           = "format"
+	RoleKeyword          = "keyword"
+	RoleLabel            = "label"
+	RoleHeader           = "header"
+	RoleTilt             = "tilt"
+	RoleTiltData         = "tilt_data"
+	RoleLampSet          = "lamp_set"
+	RoleDirectRatios     = "direct_ratios"
+	RoleVerticalAngles   = "vertical_angles"
+	RoleHorizontalAngles = "horizontal_angles"
+	RoleCandela          = "candela"
+	// RoleIgnored marks lines the reader skips: data past what the header
+	// declares, or after a header it could not read.
+	RoleIgnored = "ignored"
+)
+
+// AnnotatedLine is one line of a photometric file with the part the reader
+// takes it to play.
+type AnnotatedLine struct {
+	Number int    `json:"line"` // 1-based
+	Text   string `json:"text"`
+	Role   string `json:"role"`
+	// Detail narrows the role: the keyword, the header field or the
+	// candela plane.
+	Detail string `json:"detail,omitempty"`
+}
+
+// Annotator is implemented by the readers that can explain a file line by
+// line, the way ParseReader reads it.
+type Annotator interface {
+	Annotate(r io.Reader) ([]AnnotatedLine, error)
+}
+
+// readLines splits r into annotated lines without roles, within the
+// configured limits.
+func readLines(r io.Reader) ([]AnnotatedLine, error) {
+	scanner := newLineScanner(r, CurrentLimits())
+	var lines []AnnotatedLine
+	for scanner.Scan() {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		lines = append(lines, AnnotatedLine{Number: len(lines) + 1, Text: text})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scan file: %w", err)
+	}
+	return lines, nil
+}
+
+// dataSection is a run of values of a file body that is read as a stream
+// of numbers, as IES is: the values before end have role and detail.
+type dataSection struct {
+	end          int
+	role, detail string
+}
+
+// placeValues gives each line of a value stream the role of the section
+// its first value falls in. first maps line indexes to the index of their
+// first value; lines past the last section are ignored.
+func placeValues(lines []AnnotatedLine, first map[int]int, sections []dataSection) {
+	for i, v := range first {
+		lines[i].Role = RoleIgnored
+		for _, s := range sections {
+			if v < s.end {
+				lines[i].Role, lines[i].Detail = s.role, s.detail
+				break
+			}
+		}
+	}
+}
+
+// planeDetail names candela plane i (0-based) by its angle, when known.
+func planeDetail(i int, angles []string) string {
+	if i < len(angles) {
+		return fmt.Sprintf("plane %d at %s°", i+1, angles[i])
+	}
+	return fmt.Sprintf("plane %d", i+1)
+}
+
+// Annotate tags the lines of an IES file: the format line, keywords, the
+// label lines of LM-63-1986, TILT and its data, the two photometric header
+// lines, the angle lists and one candela plane per horizontal angle.
+func (p *IESParser) Annotate(r io.Reader) ([]AnnotatedLine, error) {
+	lines, err := readLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var values []string
+	first := map[int]int{}
+	inData, tiltInclude, firstLine := false, false, true
+	lastKeyword := ""
+	for i := range lines {
+		line := strings.TrimSpace(lines[i].Text)
+		if line == "" {
+			lines[i].Role = RoleBlank
+			continue
+		}
+		if firstLine {
+			firstLine = false
+			if strings.HasPrefix(strings.ToUpper(line), "IESNA") {
+				lines[i].Role = RoleFormat
+				continue
+			}
+		}
+		if match := keywordRegex.FindStringSubmatch(line); match != nil {
+			key := strings.ToUpper(match[1])
+			if key == "MORE" && lastKeyword != "" {
+				key = lastKeyword
+			}
+			lines[i].Role, lines[i].Detail = RoleKeyword, key
+			lastKeyword = key
+			continue
+		}
+		if !inData {
+			if match := tiltRegex.FindStringSubmatch(line); match != nil {
+				lines[i].Role = RoleTilt
+				tiltInclude = strings.EqualFold(strings.TrimSpace(match[1]), "INCLUDE")
+				inData = true
+				continue
+			}
+			if !isNumericLine(line) {
+				lines[i].Role = RoleLabel
+				continue
+			}
+			inData = true
+		}
+		first[i] = len(values)
+		values = append(values, strings.Fields(line)...)
+	}
+
+	// count reads the count at value i, or -1.
+	count := func(i int) int {
+		if i >= len(values) {
+			return -1
+		}
+		v, err := strconv.ParseFloat(values[i], 64)
+		if err != nil {
+			return -1
+		}
+		n, err := checkCount("count", v, CurrentLimits().MaxAngles)
+		if err != nil {
+			return -1
+		}
+		return n
+	}
+
+	var sections []dataSection
+	pos := 0
+	add := func(n int, role, detail string) {
+		pos += n
+		sections = append(sections, dataSection{end: pos, role: role, detail: detail})
+	}
+	if tiltInclude {
+		add(1, RoleTiltData, "lamp-to-luminaire geometry")
+		n := count(pos)
+		if n < 0 {
+			placeValues(lines, first, sections)
+			return lines, nil
+		}
+		add(1, RoleTiltData, "tilt angle count")
+		add(n, RoleTiltData, "tilt angles")
+		add(n, RoleTiltData, "tilt multipliers")
+	}
+	nv, nh := count(pos+3), count(pos+4)
+	add(10, RoleHeader, "lamps, lumens, multiplier, angle counts, type, units and dimensions")
+	add(3, RoleHeader, "ballast factor, ballast-lamp factor and input watts")
+	if nv > 0 && nh > 0 {
+		add(nv, RoleVerticalAngles, "")
+		horizontal := values[min(pos, len(values)):min(pos+nh, len(values))]
+		add(nh, RoleHorizontalAngles, "")
+		for i := 0; i < nh && pos < len(values); i++ {
+			add(nv, RoleCandela, planeDetail(i, horizontal))
+		}
+	}
+	placeValues(lines, first, sections)
+	return lines, nil
+}
+
+// ldtFields names the 26 fixed header lines of EULUMDAT.
+var ldtFields = [ldtHeaderLines]string{
+	"company, databank and version", "type indicator", "symmetry indicator",
+	"C-plane count", "C-plane spacing", "intensity count", "intensity spacing",
+	"measurement report number", "luminaire name", "luminaire number",
+	"file name", "date and user", "luminaire length or diameter (mm)",
+	"luminaire width (mm)", "luminaire height (mm)",
+	"luminous area length or diameter (mm)", "luminous area width (mm)",
+	"luminous area height C0 (mm)", "luminous area height C90 (mm)",
+	"luminous area height C180 (mm)", "luminous area height C270 (mm)",
+	"downward flux fraction (%)", "light output ratio (%)",
+	"intensity conversion factor", "tilt during measurement",
+	"lamp set count",
+}
+
+// ldtLampSetNames names the lines of one lamp set.
+var ldtLampSetNames = [ldtLampSetFields]string{
+	"lamp count", "lamp type", "total lamp flux", "colour temperature",
+	"colour rendering", "wattage",
+}
+
+// ldtRoomIndices are the room indices of the ten direct ratios.
+var ldtRoomIndices = [ldtDirectRatios]string{
+	"0.60", "0.80", "1.00", "1.25", "1.50", "2.00", "2.50", "3.00", "4.00", "5.00",
+}
+
+// Annotate tags the lines of an EULUMDAT file by field: the fixed header,
+// the lamp sets, the direct ratios, the C and gamma angles and the
+// intensities of each stored C-plane, one value to a line.
+func (p *LDTParser) Annotate(r io.Reader) ([]AnnotatedLine, error) {
+	lines, err := readLines(r)
+	if err != nil {
+		return nil, err
+	}
+	text := make(ldtLines, len(lines))
+	for i := range lines {
+		text[i] = strings.TrimSpace(lines[i].Text)
+		lines[i].Role = RoleIgnored
+	}
+
+	n := 0
+	tag := func(role, detail string) {
+		if n < len(lines) {
+			lines[n].Role, lines[n].Detail = role, detail
+		}
+		n++
+	}
+	for _, field := range ldtFields {
+		tag(RoleHeader, field)
+	}
+
+	numSets, err1 := text.count(26, "lamp set count", maxLampSets)
+	isym, err2 := text.int(3)
+	mc, err3 := text.count(4, "C-plane count", CurrentLimits().MaxAngles)
+	ng, err4 := text.count(6, "intensity count", CurrentLimits().MaxAngles)
+	if err1 != nil || err2 != nil || err3 != nil || err4 != nil || isym < ldtSymNone || isym > ldtSymQuadrant {
+		return lines, nil
+	}
+	for s := range numSets {
+		for _, field := range ldtLampSetNames {
+			tag(RoleLampSet, fmt.Sprintf("lamp set %d: %s", s+1, field))
+		}
+	}
+	for _, k := range ldtRoomIndices {
+		tag(RoleDirectRatios, "room index "+k)
+	}
+	cAngles := make([]string, mc)
+	for i := range mc {
+		cAngles[i] = text.str(n + 1)
+		tag(RoleHorizontalAngles, fmt.Sprintf("C-plane %d", i+1))
+	}
+	for i := range ng {
+		tag(RoleVerticalAngles, fmt.Sprintf("gamma %d", i+1))
+	}
+	if mc == 1 {
+		isym = ldtSymVertical
+	}
+	start, planes := ldtStoredPlanes(isym, mc)
+	// As in ParseReader, the one plane of a rotationally symmetric file
+	// is read as C0.
+	stored := []string{"0"}
+	if isym != ldtSymVertical {
+		stored = make([]string, planes)
+		for i := range stored {
+			stored[i] = cAngles[(start+i)%mc]
+		}
+	}
+	for i := 0; i < planes && n < len(lines); i++ {
+		detail := planeDetail(i, stored)
+		for range ng {
+			tag(RoleCandela, detail)
+		}
+	}
+	for i := n; i < len(lines); i++ {
+		if text[i] == "" {
+			lines[i].Role = RoleBlank
+		}
+	}
+	return lines, nil
+}
+
+// Annotate tags the lines of a CIE i-table: the header line, then one
+// candela row per line at 10° steps of the vertical angle.
+func (p *CIEParser) Annotate(r io.Reader) ([]AnnotatedLine, error) {
+	lines, err := readLines(r)
+	if err != nil {
+		return nil, err
+	}
+	header, row := true, 0
+	for i := range lines {
+		line := strings.TrimSpace(lines[i].Text)
+		switch {
+		case line == "":
+			lines[i].Role = RoleBlank
+		case header:
+			header = false
+			lines[i].Role = RoleHeader
+			if cieHeaderRegex.MatchString(line) {
+				lines[i].Detail = "symmetry, type, flag and name"
+			}
+		case len(parseFloatLine(line)) > 0:
+			lines[i].Role, lines[i].Detail = RoleCandela, fmt.Sprintf("row %d at %d°", row+1, row*10)
+			row++
+		default:
+			lines[i].Role = RoleIgnored
+		}
+	}
+	return lines, nil
+}
@@ -0,0 +1,77 @@
+package parser
+
+import (
+	"bytes"
+	"testing"
+
+	"illuminate/internal/synth"
+)
+
+// TestAnnotate checks that every line of a written file is given a role,
+// and that the candela lines cover each plane the reader finds.
+func TestAnnotate(t *testing.T) {
+	lum, err := synth.Generate(synth.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lum.Metadata.Manufacturer = "Acme"
+
+	for name, p := range map[string]Parser{"ies": NewIESParser(), "ldt": NewLDTParser(), "cie": NewCIEParser()} {
+		data, err := Encode(p, lum, WriteOptions{})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		parsed, err := p.ParseReader(bytes.NewReader(data), "x."+name)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		lines, err := p.(Annotator).Annotate(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		roles := map[string]int{}
+		planes := map[string]bool{}
+		for i, line := range lines {
+			if line.Number != i+1 || line.Role == "" {
+				t.Errorf("%s: line %d = %+v", name, i+1, line)
+			}
+			roles[line.Role]++
+			if line.Role == RoleCandela {
+				planes[line.Detail] = true
+			}
+		}
+		if roles[RoleIgnored] > 0 {
+			t.Errorf("%s: %d lines ignored", name, roles[RoleIgnored])
+		}
+		if want := len(parsed.CandelaMatrix); len(planes) != want {
+			t.Errorf("%s: candela lines cover %d planes, want %d", name, len(planes), want)
+		}
+		if name != "cie" && (roles[RoleVerticalAngles] == 0 || roles[RoleHeader] == 0) {
+			t.Errorf("%s: roles = %v", name, roles)
+		}
+	}
+}
+
+// TestAnnotateIES checks the roles of a small hand-written IES file.
+func TestAnnotateIES(t *testing.T) {
+	file := "IESNA:LM-63-2002\n[MANUFAC] Acme\n[MORE] Lighting\nTILT=NONE\n" +
+		"1 -1 1 3 2 1 2 0 0 0\n1 1 10\n0 45 90\n0 90\n100 80 10\n100 70 5\n1 2 3\n"
+	lines, err := NewIESParser().Annotate(bytes.NewReader([]byte(file)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct{ role, detail string }{
+		{RoleFormat, ""}, {RoleKeyword, "MANUFAC"}, {RoleKeyword, "MANUFAC"}, {RoleTilt, ""},
+		{RoleHeader, ""}, {RoleHeader, ""}, {RoleVerticalAngles, ""}, {RoleHorizontalAngles, ""},
+		{RoleCandela, "plane 1 at 0°"}, {RoleCandela, "plane 2 at 90°"}, {RoleIgnored, ""},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("%d lines, want %d", len(lines), len(want))
+	}
+	for i, w := range want {
+		if lines[i].Role != w.role || (w.detail != "" && lines[i].Detail != w.detail) {
+			t.Errorf("line %d: %s %q, want %s %q", i+1, lines[i].Role, lines[i].Detail, w.role, w.detail)
+		}
+	}
+}
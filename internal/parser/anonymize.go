@@ -0,0 +1,45 @@
+package parser
+
+import (
+	"maps"
+	"strings"
+
+	"illuminate/internal/database"
+)
+
+// Anonymize returns a copy of lum without what identifies it: the
+// manufacturer, model, catalog number, description, lamp catalog, ballast,
+// test lab and test number, the original file name and hash, and the
+// keywords kept verbatim from its source, such as IES [_SERIAL].
+// Photometry, electrical and colour data and the format data extensions
+// (ies:tilt, ldt:direct_ratios) are kept, so the copy can be shared as
+// benchmark data.
+func Anonymize(lum *database.ParsedLuminaire) *database.ParsedLuminaire {
+	out := *lum
+	m := &out.Metadata
+	m.Manufacturer, m.Model, m.CatalogNumber, m.LuminaireDesc = "", "", "", ""
+	m.LampCatalog, m.Ballast, m.TestLab, m.TestNumber = "", "", "", ""
+	m.OriginalFilename, m.FileHash = "", ""
+
+	out.Extensions = maps.Clone(lum.Extensions)
+	maps.DeleteFunc(out.Extensions, func(key, _ string) bool {
+		_, name, _ := strings.Cut(key, ":")
+		return name != strings.ToLower(name)
+	})
+	out.Extensions = extensionsOrNil(out.Extensions)
+	return &out
+}
+
+// anonymized applies WriteOptions.Anonymize: lum and opts as they are, or
+// lum anonymized and opts with the source hash left out of the provenance.
+func anonymized(lum *database.ParsedLuminaire, opts WriteOptions) (*database.ParsedLuminaire, WriteOptions) {
+	if !opts.Anonymize {
+		return lum, opts
+	}
+	if opts.Provenance != nil {
+		p := *opts.Provenance
+		p.SourceHash = ""
+		opts.Provenance = &p
+	}
+	return Anonymize(lum), opts
+}
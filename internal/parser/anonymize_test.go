@@ -0,0 +1,52 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestAnonymize reads an IES file with a maker, a lab and a serial number,
+// and checks that none of them reach the anonymized output while the
+// photometry and the tilt data do.
+func TestAnonymize(t *testing.T) {
+	src := "IESNA:LM-63-2002\n[TEST] R-1234\n[TESTLAB] Photolab GmbH\n[MANUFAC] Acme\n" +
+		"[LUMCAT] DL-100\n[_SERIAL] SN-0042\n" +
+		"TILT=INCLUDE\n1\n3\n0 45 90\n1 0.985 0.95\n" +
+		"2 500 1 2 1 1 2 0 0 0.1\n0.95 1 20\n0 90\n0\n100 50\n"
+	lum, err := NewIESParser().ParseReader(strings.NewReader(src), "acme_dl100.ies")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	anon := Anonymize(lum)
+	if lum.Metadata.Manufacturer != "Acme" || lum.Extensions["ies:_SERIAL"] == "" {
+		t.Error("Anonymize changed its argument")
+	}
+	m := anon.Metadata
+	for name, v := range map[string]string{
+		"manufacturer": m.Manufacturer, "catalog number": m.CatalogNumber,
+		"test lab": m.TestLab, "test number": m.TestNumber,
+		"original filename": m.OriginalFilename, "file hash": m.FileHash,
+	} {
+		if v != "" {
+			t.Errorf("%s kept: %q", name, v)
+		}
+	}
+	if !reflect.DeepEqual(anon.CandelaMatrix, lum.CandelaMatrix) || anon.Metadata.InputWatts != lum.Metadata.InputWatts {
+		t.Error("photometry changed")
+	}
+	if anon.Extensions["ies:tilt"] != lum.Extensions["ies:tilt"] {
+		t.Errorf("tilt dropped: %v", anon.Extensions)
+	}
+
+	opts := WriteOptions{Anonymize: true, Provenance: &Provenance{SourceHash: "9f86d081884c7d659a2f"}}
+	for _, p := range []Parser{NewIESParser(), NewLDTParser()} {
+		out := string(mustEncode(t, p, lum, opts))
+		for _, leak := range []string{"Acme", "DL-100", "Photolab", "R-1234", "SN-0042", "9f86d081884c"} {
+			if strings.Contains(out, leak) {
+				t.Errorf("%T output contains %q:\n%s", p, leak, out[:min(len(out), 600)])
+			}
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"illuminate/internal/logger"
+	"illuminate/internal/synth"
+)
+
+// benchSizes are the grids the benchmarks run on, from a coarse catalog file
+// to full 1°×1° laboratory data.
+var benchSizes = []struct {
+	name         string
+	vStep, hStep float64
+}{
+	{"small", 10, 45},
+	{"medium", 2.5, 15},
+	{"high", 1, 1},
+}
+
+// benchInput writes a synthetic luminaire at the given grid in the format of
+// ext and returns the file contents.
+func benchInput(b *testing.B, ext string, vStep, hStep float64) []byte {
+	b.Helper()
+	quietLogs(b)
+	opts := synth.DefaultOptions()
+	opts.Distribution = synth.Batwing
+	opts.VerticalStep = vStep
+	opts.HorizontalStep = hStep
+	lum, err := synth.Generate(opts)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	path := filepath.Join(b.TempDir(), "bench"+ext)
+	p, err := GetParser(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := p.Write(lum, path); err != nil {
+		b.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return data
+}
+
+// quietLogs drops per-file info logging, which would otherwise interleave with
+// the benchmark result lines and break benchstat parsing.
+func quietLogs(b *testing.B) {
+	level := logger.Default.GetLevel()
+	logger.Default.SetLevel(log.WarnLevel)
+	b.Cleanup(func() { logger.Default.SetLevel(level) })
+}
+
+func BenchmarkParse(b *testing.B) {
+	for _, ext := range GetSupportedExtensions() {
+		for _, size := range benchSizes {
+			b.Run(fmt.Sprintf("%s/%s", ext[1:], size.name), func(b *testing.B) {
+				data := benchInput(b, ext, size.vStep, size.hStep)
+				p, _ := GetParser("bench" + ext)
+				b.SetBytes(int64(len(data)))
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, err := p.ParseReader(bytes.NewReader(data), "bench"+ext); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkWrite(b *testing.B) {
+	for _, ext := range GetSupportedExtensions() {
+		for _, size := range benchSizes {
+			b.Run(fmt.Sprintf("%s/%s", ext[1:], size.name), func(b *testing.B) {
+				p, _ := GetParser("bench" + ext)
+				lum, err := p.ParseReader(bytes.NewReader(benchInput(b, ext, size.vStep, size.hStep)), "bench"+ext)
+				if err != nil {
+					b.Fatal(err)
+				}
+				out := filepath.Join(b.TempDir(), "out"+ext)
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if err := p.Write(lum, out); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkConvert measures a full conversion: parse the source format and
+// write the target one.
+func BenchmarkConvert(b *testing.B) {
+	pairs := [][2]string{{".ies", ".ldt"}, {".ldt", ".ies"}, {".ies", ".cie"}}
+	for _, pair := range pairs {
+		for _, size := range benchSizes {
+			b.Run(fmt.Sprintf("%s-%s/%s", pair[0][1:], pair[1][1:], size.name), func(b *testing.B) {
+				data := benchInput(b, pair[0], size.vStep, size.hStep)
+				src, _ := GetParser("bench" + pair[0])
+				dst, _ := GetParser("bench" + pair[1])
+				out := filepath.Join(b.TempDir(), "out"+pair[1])
+				b.SetBytes(int64(len(data)))
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					lum, err := src.ParseReader(bytes.NewReader(data), "bench"+pair[0])
+					if err != nil {
+						b.Fatal(err)
+					}
+					if err := dst.Write(lum, out); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
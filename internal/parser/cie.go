@@ -1,10 +1,10 @@
 package parser
 
 import (
-	"bufio"
 	"crypto/sha256"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"regexp"
 	"strconv"
@@ -12,10 +12,15 @@ import (
 
 	"illuminate/internal/database"
 	"illuminate/internal/logger"
+	"illuminate/internal/photometry"
 )
 
 var cieHeaderRegex = regexp.MustCompile(`^\s*(\d+)\s+(\d+)\s+(\d+)\s+(.+)$`)
 
+// cieFluxSuffix matches the "<flux> lms" that ends the description line,
+// optionally set off by a dash.
+var cieFluxSuffix = regexp.MustCompile(`(?i)(?:\s+-)?\s+(\d+(?:\.\d+)?)\s*lms?$`)
+
 type CIEParser struct{}
 
 func NewCIEParser() *CIEParser {
@@ -23,23 +28,30 @@ func NewCIEParser() *CIEParser {
 }
 
 func (p *CIEParser) Parse(filepath string) (*database.ParsedLuminaire, error) {
-	logger.Default.Debugf("parsing CIE file: %s", filepath)
-
 	file, err := os.Open(filepath)
 	if err != nil {
 		return nil, fmt.Errorf("open file: %w", err)
 	}
 	defer file.Close()
 
+	return p.ParseReader(file, filepath)
+}
+
+func (p *CIEParser) ParseReader(r io.Reader, name string) (*database.ParsedLuminaire, error) {
+	logger.Default.Debugf("parsing CIE file: %s", name)
+
 	hash := sha256.New()
-	reader := io.TeeReader(file, hash)
+	reader := io.TeeReader(r, hash)
 
-	scanner := bufio.NewScanner(reader)
-	scanner.Split(bufio.ScanLines)
+	limits := CurrentLimits()
+	scanner := newLineScanner(reader, limits)
 
 	metadata := database.Luminaire{
-		OriginalFilename: filepath,
+		OriginalFilename: name,
 		FormatType:       "CIE",
+		FormatVersion:    VersionCIEITable,
+		PhotometricType:  database.PhotometricTypeC,
+		FormatConfidence: ConfidenceGuessed,
 	}
 
 	var candelaLines []string
@@ -53,23 +65,23 @@ func (p *CIEParser) Parse(filepath string) (*database.ParsedLuminaire, error) {
 
 		if firstLine {
 			if match := cieHeaderRegex.FindStringSubmatch(line); match != nil {
+				metadata.FormatConfidence = ConfidenceInferred
 				metadata.SymmetryFlag, _ = strconv.Atoi(match[1])
 				formatType, _ := strconv.Atoi(match[2])
 				_ = formatType
 				metadata.FormatType = "CIE"
 
 				nameAndFlux := strings.TrimSpace(match[4])
-				metadata.LuminaireDesc = nameAndFlux
-
-				if idx := strings.LastIndex(nameAndFlux, " "); idx > 0 {
-					fluxPart := strings.Trim(nameAndFlux[idx:], "lmsLMS")
-					if flux, err := strconv.ParseFloat(strings.TrimSpace(fluxPart), 64); err == nil {
-						metadata.LuminousFlux = flux
-					}
+				name, fluxSuffix := nameAndFlux, ""
+				if m := cieFluxSuffix.FindStringSubmatchIndex(nameAndFlux); m != nil {
+					metadata.LuminousFlux, _ = strconv.ParseFloat(nameAndFlux[m[2]:m[3]], 64)
+					name, fluxSuffix = nameAndFlux[:m[0]], nameAndFlux[m[0]:]
 				}
+				name = recoverEmbedded(name, &metadata)
+				metadata.LuminaireDesc = name
 
-				if dashIdx := strings.LastIndex(nameAndFlux, "-"); dashIdx > 0 {
-					metadata.Model = strings.TrimSpace(nameAndFlux[:dashIdx])
+				if dashIdx := strings.LastIndex(name+fluxSuffix, "-"); dashIdx > 0 && metadata.Model == "" {
+					metadata.Model = strings.TrimSpace((name + fluxSuffix)[:dashIdx])
 				}
 			}
 			firstLine = false
@@ -77,6 +89,9 @@ func (p *CIEParser) Parse(filepath string) (*database.ParsedLuminaire, error) {
 		}
 
 		candelaLines = append(candelaLines, line)
+		if max := limits.MaxAngles; max > 0 && len(candelaLines) > max {
+			return nil, fmt.Errorf("invalid CIE file: %w", &LimitError{Limit: "candela row count", Value: float64(len(candelaLines)), Max: max})
+		}
 	}
 
 	if err := scanner.Err(); err != nil {
@@ -91,18 +106,17 @@ func (p *CIEParser) Parse(filepath string) (*database.ParsedLuminaire, error) {
 		}
 	}
 
-	verticalAngles := make([]float64, len(candelaMatrix))
-	for i := 0; i < len(candelaMatrix); i++ {
-		verticalAngles[i] = float64(i * 10)
+	var verticalAngles, horizontalAngles []float64
+	if cieGrid(candelaMatrix) {
+		// One C-plane per line, as Render lays the table out, on the 10°
+		// grid readers assume.
+		horizontalAngles = cieAngles(len(candelaMatrix))
+		verticalAngles = cieAngles(len(candelaMatrix[0]))
+	} else {
+		verticalAngles = cieAngles(len(candelaMatrix))
 	}
-
 	if len(candelaMatrix) > 0 {
-		horizontalAngles := make([]float64, len(candelaMatrix[0]))
-		for i := range horizontalAngles {
-			horizontalAngles[i] = float64(i * 10)
-		}
 		metadata.Symmetry = metadata.SymmetryFlag
-		_ = horizontalAngles
 	}
 
 	fileHash := fmt.Sprintf("%x", hash.Sum(nil))
@@ -112,21 +126,57 @@ func (p *CIEParser) Parse(filepath string) (*database.ParsedLuminaire, error) {
 		fileHash, len(verticalAngles), len(candelaMatrix))
 
 	return &database.ParsedLuminaire{
-		Metadata:       metadata,
-		VerticalAngles: verticalAngles,
-		CandelaMatrix:  candelaMatrix,
+		Metadata:         metadata,
+		VerticalAngles:   verticalAngles,
+		HorizontalAngles: horizontalAngles,
+		CandelaMatrix:    candelaMatrix,
 	}, nil
 }
 
+// cieGrid reports whether matrix holds one full C-plane per line: rows of
+// equal length that fit the 10° grid from C0 to C360 and from 0° to 180°.
+// Tables wrapped over lines of a fixed width are not.
+func cieGrid(matrix [][]float64) bool {
+	if len(matrix) < 2 || len(matrix) > 360/ciePlaneStep+1 {
+		return false
+	}
+	n := len(matrix[0])
+	if n < 2 || n > 180/ciePlaneStep+1 {
+		return false
+	}
+	for _, row := range matrix {
+		if len(row) != n {
+			return false
+		}
+	}
+	return true
+}
+
+// cieAngles returns n angles 10° apart from 0°.
+func cieAngles(n int) []float64 {
+	angles := make([]float64, n)
+	for i := range angles {
+		angles[i] = float64(i * ciePlaneStep)
+	}
+	return angles
+}
+
 func (p *CIEParser) Write(lum *database.ParsedLuminaire, filepath string) error {
-	file, err := os.Create(filepath)
+	return WriteFile(p, lum, filepath, DefaultWriteOptions())
+}
+
+// ciePlaneStep is the C-plane spacing i-table readers assume.
+const ciePlaneStep = 10
+
+// cieProvenanceLength keeps the provenance block from crowding the
+// description line.
+const cieProvenanceLength = 64
+
+func (p *CIEParser) Render(w io.Writer, lum *database.ParsedLuminaire, opts WriteOptions) error {
+	writer, err := newOutputWriter(w, opts)
 	if err != nil {
-		return fmt.Errorf("create file: %w", err)
+		return err
 	}
-	defer file.Close()
-
-	writer := bufio.NewWriter(file)
-	defer writer.Flush()
 
 	symmetryFlag := lum.Metadata.SymmetryFlag
 	if symmetryFlag == 0 {
@@ -141,6 +191,10 @@ func (p *CIEParser) Write(lum *database.ParsedLuminaire, filepath string) error
 		name = "Luminaire"
 	}
 
+	if opts.Provenance != nil {
+		name += " [" + provenanceKey + "=" + opts.Provenance.short(opts, cieProvenanceLength) + "]"
+	}
+
 	lumenStr := ""
 	if lum.Metadata.LuminousFlux > 0 {
 		lumenStr = fmt.Sprintf(" %.0f lms", lum.Metadata.LuminousFlux)
@@ -148,7 +202,7 @@ func (p *CIEParser) Write(lum *database.ParsedLuminaire, filepath string) error
 
 	writer.WriteString(fmt.Sprintf("   %d   0   0        %s%s\n", symmetryFlag, name, lumenStr))
 
-	for _, row := range lum.CandelaMatrix {
+	for _, row := range cieTable(lum).CandelaMatrix {
 		for i, v := range row {
 			if i > 0 {
 				writer.WriteString(" ")
@@ -158,5 +212,90 @@ func (p *CIEParser) Write(lum *database.ParsedLuminaire, filepath string) error
 		writer.WriteString("\n")
 	}
 
-	return nil
+	return writer.Close()
+}
+
+// cieTable lays lum out as the i-table is read back: one C-plane per line on
+// the 10° grid. The i-table has no way to say one plane stands for all of
+// them, and values off the grid are interpolated onto it. A table without
+// C-planes, as read from a wrapped i-table, is written as it is.
+func cieTable(lum *database.ParsedLuminaire) *database.ParsedLuminaire {
+	if len(lum.HorizontalAngles) == 0 || len(lum.VerticalAngles) == 0 {
+		return lum
+	}
+	lum = ExpandPlanes(lum, ciePlaneStep)
+	if onCIEGrid(lum.HorizontalAngles, 360) && onCIEGrid(lum.VerticalAngles, 180) {
+		return lum
+	}
+	table := *lum
+	table.HorizontalAngles = cieAngles(360 / ciePlaneStep)
+	table.VerticalAngles = cieAngles(180/ciePlaneStep + 1)
+	table.CandelaMatrix = make([][]float64, len(table.HorizontalAngles))
+	for i, c := range table.HorizontalAngles {
+		table.CandelaMatrix[i] = make([]float64, len(table.VerticalAngles))
+		for j, gamma := range table.VerticalAngles {
+			table.CandelaMatrix[i][j] = photometry.Intensity(lum, c, gamma)
+		}
+	}
+	return &table
+}
+
+// onCIEGrid reports whether angles run in 10° steps from 0° up to at most
+// limit.
+func onCIEGrid(angles []float64, limit float64) bool {
+	if len(angles) == 1 {
+		return angles[0] == 0
+	}
+	return uniformStep(angles) == ciePlaneStep && angles[0] == 0 && angles[len(angles)-1] <= limit
+}
+
+func (p *CIEParser) Compatibility(lum *database.ParsedLuminaire) []CompatibilityIssue {
+	meta := lum.Metadata
+	issues := droppedFields(meta, "the CIE i-table", "manufacturer", "catalog_number",
+		"lamp_type", "lamp_catalog", "ballast", "test_lab", "test_number", "issue_date",
+		"test_date", "lamp_position", "luminaire_candela", "input_watts", "color_temp",
+		"cri", "luminous_length", "luminous_width", "lamp_lumen_depreciation",
+		"driver_maintenance_factor", "rated_life", "luminous_height_c0", "luminous_height_c90",
+		"luminous_height_c180", "luminous_height_c270", "thd", "inrush_current", "frequency")
+	if meta.LuminaireDesc != "" && meta.Model != "" {
+		issues = append(issues, CompatibilityIssue{
+			Field:  "model",
+			Effect: EffectLost,
+			Code:   CodeModelInDescription,
+			Detail: "the description line carries the luminaire description only",
+		})
+	}
+	if meta.LuminousFlux != math.Round(meta.LuminousFlux) {
+		issues = append(issues, CompatibilityIssue{
+			Field:  "luminous_flux",
+			Effect: EffectApproximated,
+			Code:   CodeWholeLumens,
+			Detail: "the flux is written in whole lumens",
+		})
+	}
+	if len(lum.HorizontalAngles) > 0 && !onCIEGrid(ExpandPlanes(lum, ciePlaneStep).HorizontalAngles, 360) {
+		issues = append(issues, CompatibilityIssue{
+			Field:  "horizontal_angles",
+			Effect: EffectApproximated,
+			Code:   CodeFixedAngleGrid,
+			Detail: "the i-table has no angle lists; the C-planes are interpolated onto 10° steps",
+		})
+	}
+	if len(lum.VerticalAngles) > 0 && !onCIEGrid(lum.VerticalAngles, 180) {
+		issues = append(issues, CompatibilityIssue{
+			Field:  "vertical_angles",
+			Effect: EffectApproximated,
+			Code:   CodeFixedAngleGrid,
+			Detail: "the i-table is read back on 10° steps from 0°",
+		})
+	}
+	if hasFraction(lum.CandelaMatrix) {
+		issues = append(issues, CompatibilityIssue{
+			Field:  "candela_values",
+			Effect: EffectApproximated,
+			Code:   CodeWholeCandela,
+			Detail: "intensities are written as whole candela",
+		})
+	}
+	return append(issues, typeCOnly(meta, "the CIE i-table")...)
 }
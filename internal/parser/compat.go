@@ -0,0 +1,88 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+
+	"illuminate/internal/database"
+)
+
+// Effects of a CompatibilityIssue.
+const (
+	EffectLost         = "lost"
+	EffectApproximated = "approximated"
+)
+
+// CompatibilityIssue is one piece of information a writer cannot carry
+// faithfully. Field is a metadata JSON name or a data item such as
+// "horizontal_angles"; Code says why, stable for programs to match on,
+// while Detail is for people.
+type CompatibilityIssue struct {
+	Field  string `json:"field"`
+	Effect string `json:"effect"`
+	Code   string `json:"code"`
+	Detail string `json:"detail"`
+}
+
+// Codes of a CompatibilityIssue, listed in docs/conversion-result.schema.json.
+// New codes may be added; existing ones keep their meaning.
+const (
+	CodeUnsupportedField     = "unsupported_field"      // the format has no field for it
+	CodeTypeCOnly            = "type_c_only"            // type A or B written as C-planes
+	CodeModelInDescription   = "model_in_description"   // model and description share one line
+	CodeNoAngleList          = "no_angle_list"          // angles implied by a fixed grid
+	CodeFixedAngleGrid       = "fixed_angle_grid"       // values resampled onto the grid
+	CodeSingleLuminousHeight = "single_luminous_height" // one height for all sides
+	CodeUnevenCPlanes        = "uneven_c_planes"        // symmetry needs even spacing
+	CodeAbsolutePhotometry   = "absolute_photometry"    // lamp flux dropped for absolute values
+	CodeRelativeIntensity    = "relative_intensity"     // written per 1000 lm
+	CodeWholeCandela         = "whole_candela"          // intensities rounded
+	CodeWholeLumens          = "whole_lumens"           // flux rounded
+	CodeEmbedded             = "embedded"               // moved into the description line
+)
+
+// droppedFields reports each set metadata field in fields as lost.
+func droppedFields(meta database.Luminaire, format string, fields ...string) []CompatibilityIssue {
+	var issues []CompatibilityIssue
+	v := reflect.ValueOf(meta)
+	for _, name := range fields {
+		i, ok := metadataFieldIndex[name]
+		if !ok || v.Field(i).IsZero() {
+			continue
+		}
+		issues = append(issues, CompatibilityIssue{
+			Field:  name,
+			Effect: EffectLost,
+			Code:   CodeUnsupportedField,
+			Detail: fmt.Sprintf("%s has no field for it", format),
+		})
+	}
+	return issues
+}
+
+// typeCOnly reports type A and B photometry, which formats that only know
+// type C write without converting.
+func typeCOnly(meta database.Luminaire, format string) []CompatibilityIssue {
+	if meta.PhotometricType != database.PhotometricTypeA && meta.PhotometricType != database.PhotometricTypeB {
+		return nil
+	}
+	return []CompatibilityIssue{{
+		Field:  "photometric_type",
+		Effect: EffectApproximated,
+		Code:   CodeTypeCOnly,
+		Detail: fmt.Sprintf("%s only describes type C; the angles are written as C-planes unconverted", format),
+	}}
+}
+
+// hasFraction reports whether any value has a fractional part.
+func hasFraction(matrix [][]float64) bool {
+	for _, row := range matrix {
+		for _, v := range row {
+			if v != math.Trunc(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
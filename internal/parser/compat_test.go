@@ -0,0 +1,72 @@
+package parser
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"illuminate/internal/synth"
+)
+
+// TestCompatibilityPredictsRoundTrip writes a fully described luminaire in
+// every format and checks that each metadata field that does not survive the
+// round trip was reported beforehand.
+func TestCompatibilityPredictsRoundTrip(t *testing.T) {
+	lum, err := synth.Generate(synth.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &lum.Metadata
+	m.Model = "DL-200"
+	m.CatalogNumber = "DL-200-840-W"
+	m.LuminaireDesc = "Downlight 200"
+	m.LampType = "LED"
+	m.LampCatalog = "LM-840"
+	m.Ballast = "Driver 350mA"
+	m.TestLab = "Acme Labs"
+	m.TestNumber = "T-1"
+	m.IssueDate = "2024-01-01"
+	m.TestDate = "2023-12-01"
+	m.LampPosition = "0 0"
+	m.LuminaireCandela = "1"
+	m.ColorTemp = 4000
+	m.CRI = 90
+	m.LuminousLength, m.LuminousWidth = 0.6, 0.6
+	m.LampLumenDepreciation, m.DriverMaintenanceFactor, m.RatedLife = 0.85, 0.95, 50000
+	m.LuminousHeightC0, m.LuminousHeightC90, m.LuminousHeightC180, m.LuminousHeightC270 = 0.05, 0.08, 0.05, 0.08
+
+	fields := []string{"manufacturer", "model", "catalog_number", "luminaire_description",
+		"lamp_type", "lamp_catalog", "ballast", "test_lab", "test_number", "issue_date",
+		"test_date", "lamp_position", "luminaire_candela", "input_watts", "luminous_flux",
+		"color_temp", "cri", "luminous_length", "luminous_width", "lamp_lumen_depreciation",
+		"driver_maintenance_factor", "rated_life", "luminous_height_c0", "luminous_height_c90",
+		"luminous_height_c180", "luminous_height_c270"}
+
+	for _, p := range []Parser{NewIESParser(), NewLDTParser(), NewCIEParser()} {
+		t.Run(reflect.TypeOf(p).Elem().Name(), func(t *testing.T) {
+			reported := map[string]bool{}
+			for _, issue := range p.Compatibility(lum) {
+				if issue.Effect != EffectLost && issue.Effect != EffectApproximated {
+					t.Errorf("%s: effect %q", issue.Field, issue.Effect)
+				}
+				reported[issue.Field] = true
+			}
+
+			data, err := Encode(p, lum, DefaultWriteOptions())
+			if err != nil {
+				t.Fatal(err)
+			}
+			back, err := p.ParseReader(bytes.NewReader(data), "compat")
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, got := reflect.ValueOf(lum.Metadata), reflect.ValueOf(back.Metadata)
+			for _, name := range fields {
+				i := metadataFieldIndex[name]
+				if !reflect.DeepEqual(want.Field(i).Interface(), got.Field(i).Interface()) && !reported[name] {
+					t.Errorf("%s changed (%v -> %v) but was not reported", name, want.Field(i), got.Field(i))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,280 @@
+package parser
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"illuminate/internal/database"
+	"illuminate/internal/synth"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+// parseSnapshot is the stable, comparable form of a parse result stored next
+// to each corpus file as <name>.json.
+type parseSnapshot struct {
+	Metadata         database.Luminaire  `json:"metadata"`
+	VerticalAngles   []float64           `json:"vertical_angles"`
+	HorizontalAngles []float64           `json:"horizontal_angles"`
+	Rows             int                 `json:"rows"`
+	Columns          int                 `json:"columns"`
+	MaxCandela       float64             `json:"max_candela"`
+	SumCandela       float64             `json:"sum_candela"`
+	Extensions       database.Extensions `json:"extensions,omitempty"`
+}
+
+func snapshot(lum *database.ParsedLuminaire) parseSnapshot {
+	s := parseSnapshot{
+		Metadata:         lum.Metadata,
+		VerticalAngles:   lum.VerticalAngles,
+		HorizontalAngles: lum.HorizontalAngles,
+		Rows:             len(lum.CandelaMatrix),
+		Extensions:       lum.Extensions,
+	}
+	s.Metadata.OriginalFilename = filepath.Base(s.Metadata.OriginalFilename)
+	for _, row := range lum.CandelaMatrix {
+		if len(row) > s.Columns {
+			s.Columns = len(row)
+		}
+		for _, v := range row {
+			s.MaxCandela = math.Max(s.MaxCandela, v)
+			s.SumCandela += v
+		}
+	}
+	s.MaxCandela = math.Round(s.MaxCandela*1000) / 1000
+	s.SumCandela = math.Round(s.SumCandela*1000) / 1000
+	return s
+}
+
+func corpusFiles(t testing.TB) []string {
+	t.Helper()
+	entries, err := os.ReadDir("testdata/corpus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []string
+	for _, e := range entries {
+		if e.IsDir() || strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		files = append(files, filepath.Join("testdata/corpus", e.Name()))
+	}
+	return files
+}
+
+func compareGolden(t *testing.T, path string, got []byte) {
+	t.Helper()
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("missing golden file (run with -update): %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s (run with -update to accept)", path)
+	}
+}
+
+func hasGrid(lum *database.ParsedLuminaire) bool {
+	return len(lum.HorizontalAngles) > 0
+}
+
+func TestCorpusParse(t *testing.T) {
+	for _, path := range corpusFiles(t) {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			p, err := GetReader(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lum, err := p.Parse(path)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+
+			// CIE i-tables carry no angle headers, so the grid checks only apply
+			// to formats that declare both angle sets.
+			if hasGrid(lum) {
+				if len(lum.CandelaMatrix) != len(lum.HorizontalAngles) {
+					t.Errorf("matrix has %d planes, want %d", len(lum.CandelaMatrix), len(lum.HorizontalAngles))
+				}
+				for i, row := range lum.CandelaMatrix {
+					if len(row) != len(lum.VerticalAngles) {
+						t.Errorf("plane %d has %d values, want %d", i, len(row), len(lum.VerticalAngles))
+					}
+				}
+			}
+
+			got, err := json.MarshalIndent(snapshot(lum), "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			compareGolden(t, path+".json", append(got, '\n'))
+		})
+	}
+}
+
+func TestCorpusRoundTrip(t *testing.T) {
+	for _, path := range corpusFiles(t) {
+		src, _ := GetReader(path)
+		lum, err := src.Parse(path)
+		if err != nil {
+			t.Fatalf("parse %s: %v", path, err)
+		}
+
+		for _, ext := range GetSupportedExtensions() {
+			if !hasGrid(lum) && ext != ".cie" {
+				continue
+			}
+			t.Run(filepath.Base(path)+ext, func(t *testing.T) {
+				out := filepath.Join(t.TempDir(), "out"+ext)
+				p, _ := GetParser(out)
+				if err := p.Write(lum, out); err != nil {
+					t.Fatalf("write: %v", err)
+				}
+				back, err := p.Parse(out)
+				if err != nil {
+					t.Fatalf("re-parse: %v", err)
+				}
+				// Fields without a common-model slot survive a round trip
+				// through their own format.
+				if strings.EqualFold(filepath.Ext(path), ext) && !reflect.DeepEqual(back.Extensions, lum.Extensions) {
+					t.Errorf("extensions = %v, want %v", back.Extensions, lum.Extensions)
+				}
+
+				// IES keeps one decimal, CIE stores whole candela and LDT drops
+				// the duplicated 360° plane. CIE has to spell out every plane of
+				// a rotationally symmetric source, on its 10° grid.
+				tolerance := map[string]float64{".ies": 0.051, ".ldt": 0.01, ".cie": 1}[ext]
+				want := lum.CandelaMatrix
+				if ext == ".ldt" && len(back.CandelaMatrix) == len(want)-1 {
+					want = want[:len(want)-1]
+				}
+				if ext == ".cie" {
+					want = cieTable(lum).CandelaMatrix
+				}
+				if len(back.CandelaMatrix) != len(want) {
+					t.Fatalf("round trip has %d planes, want %d", len(back.CandelaMatrix), len(want))
+				}
+				for i := range want {
+					if len(back.CandelaMatrix[i]) != len(want[i]) {
+						t.Fatalf("plane %d has %d values, want %d", i, len(back.CandelaMatrix[i]), len(want[i]))
+					}
+					for j := range want[i] {
+						if d := math.Abs(back.CandelaMatrix[i][j] - want[i][j]); d > tolerance*math.Max(1, want[i][j]/100) {
+							t.Fatalf("candela[%d][%d] = %g, want %g", i, j, back.CandelaMatrix[i][j], want[i][j])
+						}
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestSyntheticWriterGolden(t *testing.T) {
+	for _, dist := range synth.Distributions() {
+		lum, err := synth.Generate(synth.Options{
+			Distribution:   dist,
+			VerticalStep:   15,
+			HorizontalStep: 45,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, ext := range GetSupportedExtensions() {
+			name := "synth_" + string(dist) + ext
+			t.Run(name, func(t *testing.T) {
+				out := filepath.Join(t.TempDir(), name)
+				p, _ := GetParser(out)
+				if err := p.Write(lum, out); err != nil {
+					t.Fatalf("write: %v", err)
+				}
+				got, err := os.ReadFile(out)
+				if err != nil {
+					t.Fatal(err)
+				}
+				compareGolden(t, filepath.Join("testdata/golden", name), got)
+			})
+		}
+	}
+}
+
+func TestOXLZipped(t *testing.T) {
+	path := "testdata/corpus/oxl_litestar_downlight.oxl"
+	doc, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, _ := zw.Create("luminaire.xml")
+	w.Write(doc)
+	zw.Close()
+
+	want, err := NewOXLParser().Parse(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := NewOXLParser().ParseReader(&buf, "zipped.oxl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Metadata.CatalogNumber != want.Metadata.CatalogNumber || snapshot(got).SumCandela != snapshot(want).SumCandela {
+		t.Errorf("zipped parse differs: %+v", got.Metadata)
+	}
+	if _, err := GetParser("export.oxl"); err == nil {
+		t.Error("OXL offered as an export format")
+	}
+}
+
+func TestLDTSinglePlane(t *testing.T) {
+	path := "testdata/corpus/ldt_rotational_single_plane.ldt"
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(data), "\r\n")
+
+	// The same plane with no C-planes listed, and listed once without a
+	// declared symmetry.
+	noPlanes := append(append(append([]string{}, lines[:3]...), "0", "0"), lines[5:]...)
+	noPlanes = append(noPlanes[:42], noPlanes[42+36:]...)
+	undeclared := append(append(append([]string{}, lines[:2]...), "0", "1", "0"), lines[5:]...)
+	undeclared = append(undeclared[:43], undeclared[42+36:]...)
+
+	want, err := NewLDTParser().Parse(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, variant := range map[string][]string{"no planes": noPlanes, "undeclared": undeclared} {
+		lum, err := NewLDTParser().ParseReader(strings.NewReader(strings.Join(variant, "\r\n")), name)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if lum.Metadata.Symmetry != ldtSymVertical || !IsRotational(lum) || !reflect.DeepEqual(lum.HorizontalAngles, []float64{0}) {
+			t.Errorf("%s: symmetry %d, planes %v", name, lum.Metadata.Symmetry, lum.HorizontalAngles)
+		}
+		if !reflect.DeepEqual(lum.CandelaMatrix, want.CandelaMatrix) {
+			t.Errorf("%s: candela = %v, want %v", name, lum.CandelaMatrix, want.CandelaMatrix)
+		}
+	}
+
+	expanded := ExpandPlanes(want, 15)
+	if len(expanded.HorizontalAngles) != 24 || expanded.HorizontalAngles[23] != 345 || len(expanded.CandelaMatrix) != 24 {
+		t.Fatalf("expanded planes = %v", expanded.HorizontalAngles)
+	}
+	if !reflect.DeepEqual(expanded.CandelaMatrix[7], want.CandelaMatrix[0]) || len(want.CandelaMatrix) != 1 {
+		t.Error("expansion should copy the stored plane and leave the source alone")
+	}
+}
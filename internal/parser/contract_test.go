@@ -0,0 +1,119 @@
+package parser
+
+import (
+	"bytes"
+	"math"
+	"slices"
+	"testing"
+
+	"illuminate/internal/database"
+	"illuminate/internal/photometry"
+	"illuminate/internal/synth"
+)
+
+// contractFixture is one synthetic luminaire of the writer contract matrix.
+type contractFixture struct {
+	name string
+	lum  *database.ParsedLuminaire
+}
+
+func contractFixtures(t *testing.T) []contractFixture {
+	t.Helper()
+	generate := func(opts synth.Options, photometricType database.PhotometricType) *database.ParsedLuminaire {
+		opts.Model = "Contract"
+		lum, err := synth.Generate(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lum.Metadata.PhotometricType = photometricType
+		return lum
+	}
+
+	// A rotationally symmetric source stores its C0 plane only.
+	symmetric := generate(synth.Options{Distribution: synth.Lambertian, VerticalStep: 10, HorizontalStep: 10}, database.PhotometricTypeC)
+	symmetric.HorizontalAngles = []float64{0}
+	symmetric.CandelaMatrix = symmetric.CandelaMatrix[:1]
+
+	return []contractFixture{
+		{"symmetric", symmetric},
+		{"asymmetric", generate(synth.Options{Distribution: synth.Street, VerticalStep: 10, HorizontalStep: 10}, database.PhotometricTypeC)},
+		{"batwing", generate(synth.Options{Distribution: synth.Batwing, VerticalStep: 10, HorizontalStep: 10}, database.PhotometricTypeC)},
+		{"narrow_beam", generate(synth.Options{Distribution: synth.NarrowBeam, VerticalStep: 2.5, HorizontalStep: 15, BeamAngle: 12}, database.PhotometricTypeC)},
+		{"type_a", generate(synth.Options{Distribution: synth.Street, VerticalStep: 10, HorizontalStep: 10}, database.PhotometricTypeA)},
+		{"type_b", generate(synth.Options{Distribution: synth.Batwing, VerticalStep: 10, HorizontalStep: 10}, database.PhotometricTypeB)},
+	}
+}
+
+// contractTolerance is how far a value may move through one format: IES
+// keeps one decimal, LDT rounds relative values and CIE whole candela.
+var contractTolerance = map[string]float64{".ies": 0.051, ".ldt": 0.01, ".cie": 1}
+
+// regridded reports whether issues say the format moved the values onto
+// other angles, so they can no longer be compared angle by angle.
+func regridded(issues []CompatibilityIssue) bool {
+	return slices.ContainsFunc(issues, func(i CompatibilityIssue) bool {
+		return i.Code == CodeNoAngleList || i.Code == CodeFixedAngleGrid || i.Code == CodeUnevenCPlanes
+	})
+}
+
+// writeAndRead encodes lum with the writer for ext and reads the output
+// back with the parser for the same format.
+func writeAndRead(t *testing.T, lum *database.ParsedLuminaire, ext string) (*database.ParsedLuminaire, []CompatibilityIssue) {
+	t.Helper()
+	p, _ := GetParser("contract" + ext)
+	data, issues, err := Convert(p, lum, WriteOptions{})
+	if err != nil {
+		t.Fatalf("write %s: %v", ext, err)
+	}
+	back, err := p.ParseReader(bytes.NewReader(data), "contract"+ext)
+	if err != nil {
+		t.Fatalf("%s writer output does not parse: %v\n%s", ext, err, data)
+	}
+	if len(back.CandelaMatrix) == 0 {
+		t.Fatalf("%s writer output parses without candela values", ext)
+	}
+	return back, issues
+}
+
+// compareIntensities checks got against want every 5° around the luminaire,
+// interpolating each on its own angles.
+func compareIntensities(t *testing.T, got, want *database.ParsedLuminaire, tolerance float64) {
+	t.Helper()
+	for c := 0.0; c < 360; c += 5 {
+		for gamma := 0.0; gamma <= 180; gamma += 5 {
+			w := photometry.Intensity(want, c, gamma)
+			g := photometry.Intensity(got, c, gamma)
+			if d := math.Abs(g - w); d > tolerance*math.Max(1, w/100) {
+				t.Fatalf("I(C%g, γ%g) = %g, want %g", c, gamma, g, w)
+			}
+		}
+	}
+}
+
+// TestWriterContract writes every fixture in each format, reads it back with
+// that format's parser, converts the result into each format again and
+// reads that back too. Values must come through within the formats'
+// rounding, unless a writer reported putting them on other angles, and the
+// photometric type must survive unless a writer reported it.
+func TestWriterContract(t *testing.T) {
+	for _, fx := range contractFixtures(t) {
+		for _, from := range GetSupportedExtensions() {
+			for _, to := range GetSupportedExtensions() {
+				t.Run(fx.name+from+to, func(t *testing.T) {
+					first, issues := writeAndRead(t, fx.lum, from)
+					second, more := writeAndRead(t, first, to)
+					issues = append(issues, more...)
+
+					if !slices.ContainsFunc(issues, func(i CompatibilityIssue) bool { return i.Code == CodeTypeCOnly }) &&
+						second.Metadata.PhotometricType != fx.lum.Metadata.PhotometricType {
+						t.Errorf("photometric type = %d, want %d", second.Metadata.PhotometricType, fx.lum.Metadata.PhotometricType)
+					}
+					if regridded(issues) {
+						return
+					}
+					compareIntensities(t, second, fx.lum, contractTolerance[from]+contractTolerance[to])
+				})
+			}
+		}
+	}
+}
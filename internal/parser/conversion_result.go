@@ -0,0 +1,85 @@
+package parser
+
+import "strings"
+
+// ConversionSchemaVersion is the version of the ConversionResult layout,
+// described by docs/conversion-result.schema.json. It changes only when a
+// field is removed or changes meaning.
+const ConversionSchemaVersion = 1
+
+// ConversionResult sorts the issues of writing a luminaire in a target
+// format so a program can decide whether the conversion is acceptable
+// without parsing messages.
+type ConversionResult struct {
+	SchemaVersion int    `json:"schema_version"`
+	SourceFormat  string `json:"source_format"`
+	TargetFormat  string `json:"target_format"`
+	// Lossless is true when nothing is dropped, approximated or rescaled.
+	Lossless bool `json:"lossless"`
+	// PhotometryChanged is true when the angles, intensities or photometric
+	// type are written differently, as opposed to descriptive metadata.
+	PhotometryChanged bool `json:"photometry_changed"`
+
+	FieldsDropped      []ConversionIssue `json:"fields_dropped"`
+	FieldsApproximated []ConversionIssue `json:"fields_approximated"`
+	// ValueRescaling lists values written on another scale or precision,
+	// such as per 1000 lm or in whole candela.
+	ValueRescaling []ConversionIssue `json:"value_rescaling"`
+	// Warnings list what changed without loss, such as fields embedded in
+	// the description line.
+	Warnings []ConversionIssue `json:"warnings"`
+}
+
+// ConversionIssue is one entry of a ConversionResult.
+type ConversionIssue struct {
+	Code    string `json:"code"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// rescalingCodes are approximations that change the scale or precision of
+// values rather than their meaning.
+var rescalingCodes = map[string]bool{
+	CodeRelativeIntensity: true,
+	CodeWholeCandela:      true,
+	CodeWholeLumens:       true,
+}
+
+// photometryFields are the data items that describe the distribution.
+var photometryFields = map[string]bool{
+	"candela_values": true, "vertical_angles": true, "horizontal_angles": true,
+	"photometric_type": true,
+}
+
+// NewConversionResult sorts the issues of a conversion from source to
+// target, as Convert or Parser.Compatibility return them.
+func NewConversionResult(source, target string, issues []CompatibilityIssue) ConversionResult {
+	r := ConversionResult{
+		SchemaVersion:      ConversionSchemaVersion,
+		SourceFormat:       strings.ToLower(source),
+		TargetFormat:       strings.ToLower(target),
+		FieldsDropped:      []ConversionIssue{},
+		FieldsApproximated: []ConversionIssue{},
+		ValueRescaling:     []ConversionIssue{},
+		Warnings:           []ConversionIssue{},
+	}
+	for _, issue := range issues {
+		entry := ConversionIssue{Code: issue.Code, Field: issue.Field, Message: issue.Detail}
+		switch {
+		case issue.Effect == EffectLost:
+			r.FieldsDropped = append(r.FieldsDropped, entry)
+		case rescalingCodes[issue.Code]:
+			r.ValueRescaling = append(r.ValueRescaling, entry)
+		case issue.Effect == EffectApproximated:
+			r.FieldsApproximated = append(r.FieldsApproximated, entry)
+		default:
+			r.Warnings = append(r.Warnings, entry)
+			continue
+		}
+		if photometryFields[issue.Field] {
+			r.PhotometryChanged = true
+		}
+	}
+	r.Lossless = len(r.FieldsDropped)+len(r.FieldsApproximated)+len(r.ValueRescaling) == 0
+	return r
+}
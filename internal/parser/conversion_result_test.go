@@ -0,0 +1,105 @@
+package parser
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"illuminate/internal/synth"
+)
+
+func TestConversionResult(t *testing.T) {
+	lum, err := synth.Generate(synth.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lum.Metadata.CatalogNumber = "SYN-1"
+	lum.CandelaMatrix[0][0] += 0.5
+
+	r := NewConversionResult("LDT", "cie", NewCIEParser().Compatibility(lum))
+	if r.SchemaVersion != ConversionSchemaVersion || r.SourceFormat != "ldt" || r.Lossless || !r.PhotometryChanged {
+		t.Errorf("result = %+v", r)
+	}
+	codes := func(issues []ConversionIssue) []string {
+		var out []string
+		for _, i := range issues {
+			out = append(out, i.Code)
+		}
+		return out
+	}
+	if !slices.Contains(codes(r.FieldsDropped), CodeUnsupportedField) || !slices.Contains(codes(r.ValueRescaling), CodeWholeCandela) {
+		t.Errorf("dropped %v, rescaled %v", codes(r.FieldsDropped), codes(r.ValueRescaling))
+	}
+	for _, i := range append(r.FieldsDropped, r.FieldsApproximated...) {
+		if i.Code == "" {
+			t.Errorf("%s has no code", i.Field)
+		}
+	}
+
+	// Embedded fields are no longer lost, only reported.
+	_, issues, err := Convert(NewCIEParser(), lum, WriteOptions{Downgrade: DowngradeEmbed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := NewConversionResult("ldt", "cie", issues); !slices.Contains(codes(r.Warnings), CodeEmbedded) {
+		t.Errorf("warnings = %v", r.Warnings)
+	}
+
+	if r := NewConversionResult("ies", "ies", nil); !r.Lossless || r.FieldsDropped == nil {
+		t.Errorf("empty result = %+v", r)
+	}
+}
+
+// TestConversionResultSchema keeps the documented schema and the type in
+// step.
+func TestConversionResultSchema(t *testing.T) {
+	data, err := os.ReadFile("../../docs/conversion-result.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema struct {
+		Required   []string                   `json:"required"`
+		Properties map[string]json.RawMessage `json:"properties"`
+		Defs       struct {
+			Issue struct {
+				Properties map[string]struct {
+					AnyOf []struct {
+						Enum []string `json:"enum"`
+					} `json:"anyOf"`
+				} `json:"properties"`
+			} `json:"issue"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	var fields []string
+	rt := reflect.TypeOf(ConversionResult{})
+	for i := 0; i < rt.NumField(); i++ {
+		name, _, _ := strings.Cut(rt.Field(i).Tag.Get("json"), ",")
+		fields = append(fields, name)
+		if _, ok := schema.Properties[name]; !ok {
+			t.Errorf("%s is not in the schema", name)
+		}
+	}
+	slices.Sort(fields)
+	required := slices.Sorted(slices.Values(schema.Required))
+	if !slices.Equal(fields, required) {
+		t.Errorf("schema requires %v, type has %v", required, fields)
+	}
+
+	documented := schema.Defs.Issue.Properties["code"].AnyOf[0].Enum
+	for _, code := range []string{
+		CodeUnsupportedField, CodeTypeCOnly, CodeModelInDescription, CodeNoAngleList,
+		CodeFixedAngleGrid, CodeSingleLuminousHeight, CodeUnevenCPlanes, CodeAbsolutePhotometry,
+		CodeRelativeIntensity, CodeWholeCandela, CodeWholeLumens, CodeEmbedded,
+	} {
+		if !slices.Contains(documented, code) {
+			t.Errorf("code %s is not documented", code)
+		}
+	}
+}
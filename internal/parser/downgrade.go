@@ -0,0 +1,113 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"illuminate/internal/database"
+)
+
+// Downgrade selects what a writer does with information the target format
+// cannot carry (see Parser.Compatibility).
+type Downgrade string
+
+const (
+	// DowngradeWarn drops what the format cannot carry and reports it.
+	DowngradeWarn Downgrade = "warn"
+	// DowngradeFail refuses to write when any metadata would be lost.
+	DowngradeFail Downgrade = "fail"
+	// DowngradeEmbed appends lost metadata fields to the description line
+	// as "[key=value; key=value]".
+	DowngradeEmbed Downgrade = "embed"
+)
+
+// EffectEmbedded marks a field DowngradeEmbed moved into the description.
+const EffectEmbedded = "embedded"
+
+// ErrDowngrade is wrapped by the DowngradeError of DowngradeFail.
+var ErrDowngrade = errors.New("export would lose information")
+
+// DowngradeError lists the issues that stopped a DowngradeFail export.
+type DowngradeError struct {
+	Issues []CompatibilityIssue
+}
+
+func (e *DowngradeError) Error() string {
+	fields := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		fields[i] = issue.Field
+	}
+	return fmt.Sprintf("%v: %s", ErrDowngrade, strings.Join(fields, ", "))
+}
+
+func (e *DowngradeError) Unwrap() error {
+	return ErrDowngrade
+}
+
+// ParseDowngrade accepts "warn" (the default), "fail" or "embed".
+func ParseDowngrade(s string) (Downgrade, error) {
+	switch d := Downgrade(strings.ToLower(strings.TrimSpace(s))); d {
+	case "":
+		return DowngradeWarn, nil
+	case DowngradeWarn, DowngradeFail, DowngradeEmbed:
+		return d, nil
+	}
+	return "", fmt.Errorf("downgrade must be warn, fail or embed, not %q", s)
+}
+
+// downgrade applies the strategy for writing lum with p. It returns the
+// luminaire to render, which is lum itself unless fields were embedded, and
+// the issues left to report.
+func downgrade(p Parser, lum *database.ParsedLuminaire, strategy Downgrade) (*database.ParsedLuminaire, []CompatibilityIssue, error) {
+	issues := p.Compatibility(lum)
+	var lost []CompatibilityIssue
+	for _, issue := range issues {
+		if _, isField := metadataFieldIndex[issue.Field]; isField && issue.Effect == EffectLost {
+			lost = append(lost, issue)
+		}
+	}
+
+	switch strategy {
+	case "", DowngradeWarn:
+		return lum, issues, nil
+	case DowngradeFail:
+		if len(lost) > 0 {
+			return nil, issues, &DowngradeError{Issues: lost}
+		}
+		return lum, issues, nil
+	case DowngradeEmbed:
+		if len(lost) == 0 {
+			return lum, issues, nil
+		}
+	default:
+		return nil, nil, fmt.Errorf("unsupported downgrade strategy: %s", strategy)
+	}
+
+	out := *lum
+	name := out.Metadata.LuminaireDesc
+	if name == "" {
+		name = out.Metadata.Model
+	}
+	fields := make([]string, len(lost))
+	for i, issue := range lost {
+		fields[i] = issue.Field
+	}
+	name, fields = embedFields(name, lum.Metadata, fields)
+	out.Metadata.LuminaireDesc = name
+	embedded := map[string]bool{}
+	for _, field := range fields {
+		embedded[field] = true
+	}
+
+	// Report against what is now written: embedded fields are no longer
+	// lost, and the description itself carries the extra text.
+	var remaining []CompatibilityIssue
+	for _, issue := range p.Compatibility(&out) {
+		if embedded[issue.Field] {
+			issue.Effect, issue.Code, issue.Detail = EffectEmbedded, CodeEmbedded, "embedded in the description line"
+		}
+		remaining = append(remaining, issue)
+	}
+	return &out, remaining, nil
+}
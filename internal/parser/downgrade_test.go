@@ -0,0 +1,97 @@
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"illuminate/internal/database"
+	"illuminate/internal/synth"
+)
+
+func TestDowngradeStrategies(t *testing.T) {
+	lum, err := synth.Generate(synth.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lum.Metadata.Model = "DL-200"
+	lum.Metadata.LuminaireDesc = ""
+	lum.Metadata.CatalogNumber = "DL-200-840;W"
+	cie := NewCIEParser()
+
+	data, issues, err := Convert(cie, lum, WriteOptions{})
+	if err != nil || len(data) == 0 || len(issues) == 0 {
+		t.Fatalf("warn: %d bytes, %d issues, %v", len(data), len(issues), err)
+	}
+
+	_, _, err = Convert(cie, lum, WriteOptions{Downgrade: DowngradeFail})
+	var dErr *DowngradeError
+	if !errors.Is(err, ErrDowngrade) || !errors.As(err, &dErr) || !strings.Contains(err.Error(), "catalog_number") {
+		t.Fatalf("fail: %v", err)
+	}
+	for _, issue := range dErr.Issues {
+		if issue.Effect != EffectLost {
+			t.Errorf("fail reported %+v", issue)
+		}
+	}
+	if _, _, err := Convert(NewLDTParser(), lum, WriteOptions{Downgrade: DowngradeFail}); !errors.Is(err, ErrDowngrade) {
+		t.Errorf("ldt fail: %v", err)
+	}
+
+	data, issues, err = Convert(cie, lum, WriteOptions{Downgrade: DowngradeEmbed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	line, _, _ := strings.Cut(string(data), "\n")
+	for _, want := range []string{"DL-200 [", "manufacturer=Illuminate", "catalog_number=DL-200-840,W", "input_watts=10"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("description line %q lacks %q", line, want)
+		}
+	}
+	embedded := map[string]bool{}
+	for _, issue := range issues {
+		if issue.Effect == EffectEmbedded {
+			embedded[issue.Field] = true
+		}
+	}
+	if !embedded["manufacturer"] || !embedded["catalog_number"] {
+		t.Errorf("embed issues = %+v", issues)
+	}
+	if lum.Metadata.LuminaireDesc != "" {
+		t.Error("embedding modified the input luminaire")
+	}
+	back, err := cie.ParseReader(bytes.NewReader(data), "embedded.cie")
+	if err != nil {
+		t.Fatalf("embedded output does not parse: %v", err)
+	}
+	got := back.Metadata
+	if got.LuminaireDesc != "DL-200" || got.Manufacturer != "Illuminate" ||
+		got.CatalogNumber != "DL-200-840,W" || got.InputWatts != 10 || got.LuminousFlux != 1000 {
+		t.Errorf("recovered %+v", got)
+	}
+
+	if err := (WriteOptions{Downgrade: "ignore"}).Validate(); err == nil {
+		t.Error("unknown strategy accepted")
+	}
+}
+
+func TestRecoverEmbedded(t *testing.T) {
+	for _, tc := range []struct {
+		desc, want string
+		model      string
+		cct        int
+	}{
+		{"Downlight [model=DL-1; color_temp=3000]", "Downlight", "DL-1", 3000},
+		{"Downlight [IP65]", "Downlight [IP65]", "", 0},
+		{"Downlight [model=DL-1; beam=wide]", "Downlight [model=DL-1; beam=wide]", "", 0},
+		{"Downlight [model=DL-1] spare", "Downlight [model=DL-1] spare", "", 0},
+		{"Downlight [model=DL-1] [provenance=illuminate dev src:9f86d081]", "Downlight", "DL-1", 0},
+		{"Downlight [IP65] [provenance=illuminate dev]", "Downlight [IP65]", "", 0},
+	} {
+		var meta database.Luminaire
+		if got := recoverEmbedded(tc.desc, &meta); got != tc.want || meta.Model != tc.model || meta.ColorTemp != tc.cct {
+			t.Errorf("%q: desc %q, model %q, cct %d", tc.desc, got, meta.Model, meta.ColorTemp)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package parser
+
+import (
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"illuminate/internal/database"
+)
+
+// Formats without metadata fields can carry them in the description line as
+// a trailing "[key=value; key=value]" block, keyed by the JSON field names of
+// database.Luminaire. DowngradeEmbed writes it and readers of such formats
+// recover it with recoverEmbedded.
+
+// embeddedBlock matches the block at the end of a description.
+var embeddedBlock = regexp.MustCompile(`\s*\[([a-z_]+=[^\[\]]*)\]$`)
+
+// embedValueEscaper keeps embedded values from breaking the pair syntax.
+var embedValueEscaper = strings.NewReplacer(";", ",", "[", "(", "]", ")")
+
+// embedFields appends the named metadata fields that have a value to name.
+// It returns the new name and the fields actually embedded.
+func embedFields(name string, meta database.Luminaire, fields []string) (string, []string) {
+	var pairs, embedded []string
+	for _, field := range fields {
+		if value := expandTemplate("{"+field+"}", meta); value != "" {
+			pairs = append(pairs, field+"="+embedValueEscaper.Replace(value))
+			embedded = append(embedded, field)
+		}
+	}
+	if len(pairs) == 0 {
+		return name, nil
+	}
+	return strings.TrimSpace(name + " [" + strings.Join(pairs, "; ") + "]"), embedded
+}
+
+// recoverEmbedded sets the fields of the trailing embedded blocks in desc on
+// meta and returns desc without them. A provenance block is dropped. A
+// bracketed suffix that is not a valid block, such as "[IP65]" or one naming
+// an unknown field, is left alone.
+func recoverEmbedded(desc string, meta *database.Luminaire) string {
+	loc := embeddedBlock.FindStringSubmatchIndex(desc)
+	if loc == nil {
+		return desc
+	}
+
+	values := map[int]string{}
+	for _, pair := range strings.Split(desc[loc[2]:loc[3]], ";") {
+		key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if ok && key == provenanceKey {
+			continue
+		}
+		i, known := metadataFieldIndex[key]
+		if !ok || !known {
+			return desc
+		}
+		values[i] = strings.TrimSpace(value)
+	}
+
+	v := reflect.ValueOf(meta).Elem()
+	for i, value := range values {
+		f := v.Field(i)
+		switch f.Kind() {
+		case reflect.String:
+			f.SetString(value)
+		case reflect.Int, reflect.Int64:
+			if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+				f.SetInt(n)
+			}
+		case reflect.Float64:
+			if x, err := strconv.ParseFloat(value, 64); err == nil {
+				f.SetFloat(x)
+			}
+		}
+	}
+	return recoverEmbedded(desc[:loc[0]], meta)
+}
@@ -0,0 +1,27 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+
+	"illuminate/internal/database"
+)
+
+// extensionFloat reads a numeric extension field, or def when it is missing
+// or not a number.
+func extensionFloat(ext map[string]string, key string, def float64) float64 {
+	v, err := strconv.ParseFloat(strings.ReplaceAll(ext[key], ",", "."), 64)
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+// extensionsOrNil keeps parse results without extensions comparable to
+// those of formats that have none.
+func extensionsOrNil(e database.Extensions) database.Extensions {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
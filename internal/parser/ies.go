@@ -1,21 +1,31 @@
 package parser
 
 import (
-	"bufio"
 	"crypto/sha256"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"regexp"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"illuminate/internal/database"
 	"illuminate/internal/logger"
 )
 
+// IESMaxLineLength is the LM-63-2002 limit on the length of any line.
+const IESMaxLineLength = 256
+
+// LegacyLineLength is the 80-column limit of LM-63-1995 era tools.
+const LegacyLineLength = 80
+
 var keywordRegex = regexp.MustCompile(`^\[(\w+)\]\s*(.*)$`)
 
+// tiltRegex also accepts the lower-case and spaced forms some writers emit.
+var tiltRegex = regexp.MustCompile(`(?i)^TILT\s*=\s*(.*)$`)
+
 type IESParser struct{}
 
 func NewIESParser() *IESParser {
@@ -23,106 +33,241 @@ func NewIESParser() *IESParser {
 }
 
 func (p *IESParser) Parse(filepath string) (*database.ParsedLuminaire, error) {
-	logger.Default.Debugf("parsing IES file: %s", filepath)
-
 	file, err := os.Open(filepath)
 	if err != nil {
 		return nil, fmt.Errorf("open file: %w", err)
 	}
 	defer file.Close()
 
+	return p.ParseReader(file, filepath)
+}
+
+func (p *IESParser) ParseReader(r io.Reader, name string) (*database.ParsedLuminaire, error) {
+	logger.Default.Debugf("parsing IES file: %s", name)
+
 	hash := sha256.New()
-	reader := io.TeeReader(file, hash)
+	reader := io.TeeReader(r, hash)
 
-	scanner := bufio.NewScanner(reader)
-	scanner.Split(bufio.ScanLines)
+	limits := CurrentLimits()
+	scanner := newLineScanner(reader, limits)
 
 	metadata := database.Luminaire{
-		OriginalFilename: filepath,
+		OriginalFilename: name,
 	}
 
 	keywords := make(map[string]string)
+	var lastKeyword string
 	var tiltLine string
-	var mainDataLine string
-	var verticalAnglesLine string
-	var horizontalAnglesLine string
-	var candelaLines []string
+	var dataTokens []string
+	var labels []string
+	var formatLine string
+	firstLine := true
 
-	lineNum := 0
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
-		lineNum++
 
 		if line == "" {
 			continue
 		}
 
-		if strings.HasPrefix(line, "[") {
-			if match := keywordRegex.FindStringSubmatch(line); match != nil {
-				keywords[strings.ToUpper(match[1])] = strings.TrimSpace(match[2])
+		// LM-63-1986 files have no IESNA format line; their header is
+		// free text up to TILT.
+		if firstLine {
+			firstLine = false
+			if strings.HasPrefix(strings.ToUpper(line), "IESNA") {
+				formatLine = line
+				continue
 			}
-			continue
 		}
 
-		if strings.HasPrefix(line, "TILT=") {
-			tiltLine = line
-			continue
+		// Some writers place keywords after the TILT line.
+		if strings.HasPrefix(line, "[") {
+			if match := keywordRegex.FindStringSubmatch(line); match != nil {
+				key := strings.ToUpper(match[1])
+				value := strings.TrimSpace(match[2])
+				if key == "MORE" && lastKeyword != "" {
+					if value != "" {
+						keywords[lastKeyword] = strings.TrimSpace(keywords[lastKeyword] + " " + value)
+					}
+					continue
+				}
+				keywords[key] = value
+				lastKeyword = key
+				continue
+			}
+			if tiltLine == "" {
+				labels = append(labels, line)
+				continue
+			}
 		}
 
-		if tiltLine != "" && mainDataLine == "" {
-			mainDataLine = line
+		if tiltLine != "" {
+			dataTokens = append(dataTokens, strings.Fields(line)...)
+			if max := maxDataValues(limits); max > 0 && len(dataTokens) > max {
+				return nil, fmt.Errorf("invalid IES file: %w", &LimitError{Limit: "value count", Value: float64(len(dataTokens)), Max: max})
+			}
 			continue
 		}
 
-		if mainDataLine != "" && verticalAnglesLine == "" {
-			verticalAnglesLine = line
+		if match := tiltRegex.FindStringSubmatch(line); match != nil {
+			tiltLine = "TILT=" + strings.ToUpper(strings.TrimSpace(match[1]))
 			continue
 		}
 
-		if verticalAnglesLine != "" && horizontalAnglesLine == "" {
-			horizontalAnglesLine = line
+		// A header without a TILT line runs straight into the photometric
+		// data; treat it as TILT=NONE.
+		if isNumericLine(line) {
+			tiltLine = "TILT=NONE"
+			dataTokens = append(dataTokens, strings.Fields(line)...)
 			continue
 		}
 
-		if horizontalAnglesLine != "" {
-			candelaLines = append(candelaLines, line)
-		}
+		labels = append(labels, line)
 	}
 
 	if err := scanner.Err(); err != nil {
 		return nil, fmt.Errorf("scan file: %w", err)
 	}
 
+	metadata.FormatVersion, metadata.FormatConfidence = iesVersion(formatLine, len(keywords) > 0)
 	metadata.TestNumber = keywords["TEST"]
 	metadata.TestLab = keywords["TESTLAB"]
 	metadata.Manufacturer = keywords["MANUFAC"]
 	metadata.IssueDate = keywords["ISSUEDATE"]
+	metadata.TestDate = keywords["TESTDATE"]
 	metadata.Model = keywords["LUMCAT"]
 	metadata.LuminaireDesc = keywords["LUMINAIRE"]
 	metadata.LampCatalog = keywords["LAMPCAT"]
 	metadata.LampType = keywords["LAMP"]
 	metadata.Ballast = keywords["BALLAST"]
 	metadata.LampPosition = keywords["LAMPPOSITION"]
+	metadata.LuminaireCandela = keywords["LUMINAIRE_CANDELA"]
+	metadata.ColorTemp = leadingInt(keywords["_CCT"])
+	metadata.CRI = leadingInt(keywords["_CRI"])
+	metadata.LampLumenDepreciation = maintenanceFactor(keywords["_LLD"])
+	metadata.DriverMaintenanceFactor = maintenanceFactor(keywords["_DMF"])
+	metadata.RatedLife = leadingInt(keywords["_RATEDLIFE"])
+	metadata.THD = leadingNumber(keywords["_THD"])
+	metadata.InrushCurrent = leadingNumber(keywords["_INRUSH"])
+	metadata.Frequency = leadingNumber(keywords["_FREQUENCY"])
+	if metadata.LuminaireDesc == "" && len(keywords) == 0 {
+		metadata.LuminaireDesc = strings.Join(strings.Fields(strings.Join(labels, " ")), " ")
+	}
+
+	extensions := database.Extensions{}
+	for key, value := range keywords {
+		if !iesModelKeywords[key] && value != "" {
+			extensions["ies:"+key] = value
+		}
+	}
+
+	// Past the keywords a failure still returns what was read; see Salvage.
+	var numVert, numHorz int
+	partial := &database.ParsedLuminaire{}
+	incomplete := func(err error) error {
+		partial.Metadata = metadata
+		partial.Metadata.FileHash = fmt.Sprintf("%x", hash.Sum(nil))
+		partial.Extensions = extensionsOrNil(extensions)
+		return &IncompleteError{Partial: partial, Planes: numHorz, Err: err}
+	}
+
+	if tiltLine == "" {
+		return nil, incomplete(fmt.Errorf("invalid IES file: missing TILT line and photometric data"))
+	}
+
+	tokens := &tokenReader{tokens: dataTokens}
 
-	mainData := strings.Fields(mainDataLine)
-	if len(mainData) >= 10 {
-		if n, err := strconv.Atoi(mainData[3]); err == nil {
-			metadata.PhotometricType = database.PhotometricType(n)
+	if strings.TrimSpace(strings.TrimPrefix(tiltLine, "TILT=")) == "INCLUDE" {
+		tilt, err := readTiltData(tokens, limits)
+		if err != nil {
+			return nil, incomplete(err)
 		}
-		if n, err := strconv.Atoi(mainData[7]); err == nil {
-			metadata.InputWatts = float64(n)
+		extensions["ies:tilt"] = strings.Join(tilt, " ")
+	}
+
+	header, err := tokens.floats(13)
+	if err != nil {
+		return nil, incomplete(fmt.Errorf("invalid IES file: photometric header: %w", err))
+	}
+
+	numLamps := header[0]
+	lumensPerLamp := header[1]
+	multiplier := header[2]
+	if numVert, err = checkCount("vertical angle count", header[3], limits.MaxAngles); err != nil {
+		return nil, incomplete(fmt.Errorf("invalid IES file: %w", err))
+	}
+	if numHorz, err = checkCount("horizontal angle count", header[4], limits.MaxAngles); err != nil {
+		return nil, incomplete(fmt.Errorf("invalid IES file: %w", err))
+	}
+
+	if numVert == 0 || numHorz == 0 {
+		return nil, incomplete(fmt.Errorf("invalid IES file: angle counts %d x %d", numVert, numHorz))
+	}
+	if err := checkCandelaValues(numHorz, numVert, limits.MaxCandelaValues); err != nil {
+		return nil, fmt.Errorf("invalid IES file: %w", err)
+	}
+
+	metadata.PhotometricType = database.PhotometricType(int(header[5]))
+	metadata.UnitsType = database.UnitsMetric
+	if int(header[6]) == 1 {
+		metadata.UnitsType = database.UnitsImperial
+	}
+	metadata.ConversionFactor = multiplier
+	if lumensPerLamp > 0 {
+		metadata.LuminousFlux = numLamps * lumensPerLamp
+	}
+	metadata.InputWatts = header[12]
+	metadata.LuminousLength, metadata.LuminousWidth = iesOpening(header[7], header[8], metadata.UnitsType)
+	height := iesLuminousHeight(header[9], metadata.UnitsType)
+	metadata.LuminousHeightC0, metadata.LuminousHeightC90 = height, height
+	metadata.LuminousHeightC180, metadata.LuminousHeightC270 = height, height
+	for _, f := range []struct {
+		key        string
+		value, def float64
+	}{
+		{"lamp_count", numLamps, 1},
+		{"lumens_per_lamp", lumensPerLamp, -1},
+		{"ballast_factor", header[10], 1},
+		{"ballast_lamp_factor", header[11], 1},
+	} {
+		if f.value != f.def {
+			extensions["ies:"+f.key] = strconv.FormatFloat(f.value, 'g', -1, 64)
 		}
 	}
 
-	verticalAngles := parseFloatLine(verticalAnglesLine)
-	horizontalAngles := parseFloatLine(horizontalAnglesLine)
+	// A truncated file keeps its angle lists and complete planes.
+	if need := numVert + numHorz + numVert*numHorz; need > tokens.remaining() {
+		err := fmt.Errorf("invalid IES file: expected %d values, found %d", need, tokens.remaining())
+		if numVert+numHorz <= tokens.remaining() {
+			partial.VerticalAngles, _ = tokens.floats(numVert)
+			partial.HorizontalAngles, _ = tokens.floats(numHorz)
+			partial.CandelaMatrix = partialPlanes(tokens, numHorz, numVert, multiplier)
+		}
+		return nil, incomplete(err)
+	}
 
-	var candelaMatrix [][]float64
-	for _, line := range candelaLines {
-		row := parseFloatLine(line)
-		if len(row) > 0 {
-			candelaMatrix = append(candelaMatrix, row)
+	verticalAngles, err := tokens.floats(numVert)
+	if err != nil {
+		return nil, incomplete(fmt.Errorf("invalid IES file: vertical angles: %w", err))
+	}
+	partial.VerticalAngles = verticalAngles
+	horizontalAngles, err := tokens.floats(numHorz)
+	if err != nil {
+		return nil, incomplete(fmt.Errorf("invalid IES file: horizontal angles: %w", err))
+	}
+	partial.HorizontalAngles = horizontalAngles
+
+	candelaMatrix := make([][]float64, numHorz)
+	for i := range candelaMatrix {
+		row, err := tokens.floats(numVert)
+		if err != nil {
+			partial.CandelaMatrix = candelaMatrix[:i]
+			return nil, incomplete(fmt.Errorf("invalid IES file: candela values for plane %d: %w", i, err))
+		}
+		for j := range row {
+			row[j] *= multiplier
 		}
+		candelaMatrix[i] = row
 	}
 
 	fileHash := fmt.Sprintf("%x", hash.Sum(nil))
@@ -136,9 +281,202 @@ func (p *IESParser) Parse(filepath string) (*database.ParsedLuminaire, error) {
 		VerticalAngles:   verticalAngles,
 		HorizontalAngles: horizontalAngles,
 		CandelaMatrix:    candelaMatrix,
+		Extensions:       extensionsOrNil(extensions),
 	}, nil
 }
 
+// iesModelKeywords are the keywords read into the common model. LM-63 has
+// no keyword for colour or lumen maintenance, so CCT, CRI, the lamp lumen
+// depreciation, driver maintenance factor, rated life in hours and the power
+// quality (THD in percent, inrush in amperes, mains frequency in hertz)
+// travel as user keywords. The others are kept as extensions under their upper-case
+// names; the lower-case extension keys hold the TILT=INCLUDE data and header
+// values the writer would otherwise reset. [_PROVENANCE] is written afresh on
+// every export.
+var iesModelKeywords = map[string]bool{
+	"TEST": true, "TESTLAB": true, "MANUFAC": true, "ISSUEDATE": true,
+	"TESTDATE": true, "LUMCAT": true, "LUMINAIRE": true, "LAMPCAT": true,
+	"LAMP": true, "BALLAST": true, "LAMPPOSITION": true,
+	"LUMINAIRE_CANDELA": true, "_CCT": true, "_CRI": true, "_LLD": true,
+	"_DMF": true, "_RATEDLIFE": true, "_THD": true, "_INRUSH": true,
+	"_FREQUENCY": true, "_PROVENANCE": true,
+}
+
+// maintenanceFactor reads a 0..1 factor, ignoring anything else.
+func maintenanceFactor(s string) float64 {
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || f <= 0 || f > 1 {
+		return 0
+	}
+	return f
+}
+
+// leadingNumber reads the number a keyword starts with, so "8 %" and
+// "50Hz" are 8 and 50; zero when there is none.
+func leadingNumber(s string) float64 {
+	s = strings.TrimSpace(s)
+	end := 0
+	for end < len(s) && (s[end] >= '0' && s[end] <= '9' || s[end] == '.') {
+		end++
+	}
+	f, _ := strconv.ParseFloat(s[:end], 64)
+	return f
+}
+
+// iesOpening converts the luminous opening of the photometric header to
+// metres. A negative width marks a circular opening of that diameter.
+func iesOpening(width, length float64, units database.UnitsType) (float64, float64) {
+	scale := 1.0
+	if units == database.UnitsImperial {
+		scale = feetToMetres
+	}
+	if width < 0 {
+		return -width * scale, 0
+	}
+	return length * scale, width * scale
+}
+
+// iesOpeningDims is the inverse of iesOpening: width and length in the
+// file's units, both negative for a circular opening as in LM-63-2019.
+func iesOpeningDims(meta database.Luminaire) (width, length float64) {
+	scale := 1.0
+	if meta.UnitsType == database.UnitsImperial {
+		scale = 1 / feetToMetres
+	}
+	if meta.LuminousWidth <= 0 && meta.LuminousLength > 0 {
+		d := -round(meta.LuminousLength*scale, 4)
+		return d, d
+	}
+	return round(meta.LuminousWidth*scale, 4), round(meta.LuminousLength*scale, 4)
+}
+
+// iesLuminousHeight converts the luminous height to metres. A negative
+// height marks a spherical or vertical-cylinder opening in LM-63-2019; only
+// its size is kept.
+func iesLuminousHeight(height float64, units database.UnitsType) float64 {
+	if units == database.UnitsImperial {
+		height *= feetToMetres
+	}
+	return math.Abs(height)
+}
+
+// iesHeight is the inverse of iesLuminousHeight. IES has one height, so
+// differing EULUMDAT side heights give the tallest.
+func iesHeight(meta database.Luminaire) float64 {
+	scale := 1.0
+	if meta.UnitsType == database.UnitsImperial {
+		scale = 1 / feetToMetres
+	}
+	return round(meta.LuminousHeight()*scale, 4)
+}
+
+const feetToMetres = 0.3048
+
+func round(v float64, places int) float64 {
+	p := math.Pow(10, float64(places))
+	return math.Round(v*p) / p
+}
+
+// readTiltData consumes the lamp-to-luminaire geometry and the tilt angle and
+// multiplier pairs that follow TILT=INCLUDE and returns them as read.
+func readTiltData(tokens *tokenReader, limits Limits) ([]string, error) {
+	start := tokens.pos
+	if _, err := tokens.float(); err != nil {
+		return nil, fmt.Errorf("invalid IES file: tilt geometry: %w", err)
+	}
+	v, err := tokens.float()
+	if err != nil {
+		return nil, fmt.Errorf("invalid IES file: tilt pair count: %w", err)
+	}
+	n, err := checkCount("tilt pair count", v, limits.MaxAngles)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IES file: %w", err)
+	}
+	if _, err := tokens.floats(n * 2); err != nil {
+		return nil, fmt.Errorf("invalid IES file: tilt data: %w", err)
+	}
+	return tokens.tokens[start:tokens.pos], nil
+}
+
+// iesTilt returns the TILT=INCLUDE data of an extension as geometry, angles
+// and multipliers, or ok false when there is none or it is malformed.
+func iesTilt(ext map[string]string) (geometry string, angles, multipliers []string, ok bool) {
+	fields := strings.Fields(ext["tilt"])
+	if len(fields) < 2 {
+		return "", nil, nil, false
+	}
+	n, err := strconv.Atoi(fields[1])
+	if err != nil || n < 1 || len(fields) != 2+2*n {
+		return "", nil, nil, false
+	}
+	return fields[0], fields[2 : 2+n], fields[2+n:], true
+}
+
+// iesLampRating returns the lamp count and lumens per lamp to write. The
+// rated lumens of the source are restored only while they still add up to
+// the luminous flux; otherwise the file is written as absolute photometry.
+func iesLampRating(ext map[string]string, flux float64) (lamps, lumens float64) {
+	lamps, lumens = extensionFloat(ext, "lamp_count", 1), -1
+	if l := extensionFloat(ext, "lumens_per_lamp", -1); l > 0 && math.Abs(lamps*l-flux) <= 1e-6*flux {
+		lumens = l
+	}
+	return lamps, lumens
+}
+
+// tokenReader walks the whitespace separated numbers that make up the body of
+// an IES file, regardless of how they are wrapped across lines.
+type tokenReader struct {
+	tokens []string
+	pos    int
+}
+
+func (t *tokenReader) float() (float64, error) {
+	if t.pos >= len(t.tokens) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	tok := t.tokens[t.pos]
+	t.pos++
+	v, err := strconv.ParseFloat(tok, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", tok)
+	}
+	return v, nil
+}
+
+func (t *tokenReader) remaining() int {
+	return len(t.tokens) - t.pos
+}
+
+func (t *tokenReader) floats(n int) ([]float64, error) {
+	if n < 0 || n > t.remaining() {
+		return nil, io.ErrUnexpectedEOF
+	}
+	vals := make([]float64, n)
+	for i := range vals {
+		v, err := t.float()
+		if err != nil {
+			return nil, err
+		}
+		vals[i] = v
+	}
+	return vals, nil
+}
+
+// isNumericLine reports whether line holds at least two numbers and nothing
+// else, as the photometric header lines do.
+func isNumericLine(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return false
+	}
+	for _, f := range fields {
+		if _, err := strconv.ParseFloat(f, 64); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
 func parseFloatLine(line string) []float64 {
 	fields := strings.Fields(line)
 	result := make([]float64, 0, len(fields))
@@ -151,49 +489,69 @@ func parseFloatLine(line string) []float64 {
 }
 
 func (p *IESParser) Write(lum *database.ParsedLuminaire, filepath string) error {
-	file, err := os.Create(filepath)
+	return WriteFile(p, lum, filepath, DefaultWriteOptions())
+}
+
+func (p *IESParser) Render(w io.Writer, lum *database.ParsedLuminaire, opts WriteOptions) error {
+	writer, err := newOutputWriter(w, opts)
 	if err != nil {
-		return fmt.Errorf("create file: %w", err)
+		return err
 	}
-	defer file.Close()
-
-	writer := bufio.NewWriter(file)
-	defer writer.Flush()
 
 	writer.WriteString("IESNA:LM-63-2002\n")
 
-	if lum.Metadata.TestNumber != "" {
-		writer.WriteString(fmt.Sprintf("[TEST] %s\n", lum.Metadata.TestNumber))
-	}
-	if lum.Metadata.TestLab != "" {
-		writer.WriteString(fmt.Sprintf("[TESTLAB] %s\n", lum.Metadata.TestLab))
-	}
-	if lum.Metadata.Manufacturer != "" {
-		writer.WriteString(fmt.Sprintf("[MANUFAC] %s\n", lum.Metadata.Manufacturer))
-	}
-	if lum.Metadata.IssueDate != "" {
-		writer.WriteString(fmt.Sprintf("[ISSUEDATE] %s\n", lum.Metadata.IssueDate))
+	limit := opts.MaxLineLength
+	if limit <= 0 {
+		limit = IESMaxLineLength
 	}
-	if lum.Metadata.Model != "" {
-		writer.WriteString(fmt.Sprintf("[LUMCAT] %s\n", lum.Metadata.Model))
-	}
-	if lum.Metadata.LuminaireDesc != "" {
-		writer.WriteString(fmt.Sprintf("[LUMINAIRE] %s\n", lum.Metadata.LuminaireDesc))
-	}
-	if lum.Metadata.LampCatalog != "" {
-		writer.WriteString(fmt.Sprintf("[LAMPCAT] %s\n", lum.Metadata.LampCatalog))
+
+	keywords := []Keyword{
+		{"TEST", lum.Metadata.TestNumber},
+		{"TESTLAB", lum.Metadata.TestLab},
+		{"TESTDATE", lum.Metadata.TestDate},
+		{"MANUFAC", lum.Metadata.Manufacturer},
+		{"ISSUEDATE", lum.Metadata.IssueDate},
+		{"LUMCAT", lum.Metadata.Model},
+		{"LUMINAIRE", lum.Metadata.LuminaireDesc},
+		{"LAMPCAT", lum.Metadata.LampCatalog},
+		{"LAMP", lum.Metadata.LampType},
+		{"BALLAST", lum.Metadata.Ballast},
+		{"LAMPPOSITION", lum.Metadata.LampPosition},
+		{"LUMINAIRE_CANDELA", lum.Metadata.LuminaireCandela},
+		{"_CCT", expandTemplate("{color_temp}", lum.Metadata)},
+		{"_CRI", expandTemplate("{cri}", lum.Metadata)},
+		{"_LLD", expandTemplate("{lamp_lumen_depreciation}", lum.Metadata)},
+		{"_DMF", expandTemplate("{driver_maintenance_factor}", lum.Metadata)},
+		{"_RATEDLIFE", expandTemplate("{rated_life}", lum.Metadata)},
+		{"_THD", expandTemplate("{thd}", lum.Metadata)},
+		{"_INRUSH", expandTemplate("{inrush_current}", lum.Metadata)},
+		{"_FREQUENCY", expandTemplate("{frequency}", lum.Metadata)},
 	}
-	if lum.Metadata.LampType != "" {
-		writer.WriteString(fmt.Sprintf("[LAMP] %s\n", lum.Metadata.LampType))
+	if opts.Mapping != nil {
+		keywords = mapFields(keywords, opts.Mapping.IES, lum.Metadata)
+		keywords = append(keywords, opts.Mapping.Keywords...)
 	}
-	if lum.Metadata.Ballast != "" {
-		writer.WriteString(fmt.Sprintf("[BALLAST] %s\n", lum.Metadata.Ballast))
+	ext := lum.Extensions.Format("ies")
+	keywords = append(keywords, iesExtensionKeywords(ext, keywords, opts.Keywords)...)
+	keywords = append(keywords, opts.Keywords...)
+	if opts.Provenance != nil {
+		keywords = append(keywords, Keyword{"_PROVENANCE", opts.Provenance.text(opts)})
 	}
-	if lum.Metadata.LampPosition != "" {
-		writer.WriteString(fmt.Sprintf("[LAMPPOSITION] %s\n", lum.Metadata.LampPosition))
+	for _, kw := range keywords {
+		if kw.Value != "" {
+			writeIESKeyword(writer, kw.Key, kw.Value, limit)
+		}
 	}
 
-	writer.WriteString("TILT=NONE\n")
+	if geometry, angles, multipliers, ok := iesTilt(ext); ok {
+		writer.WriteString("TILT=INCLUDE\n")
+		writer.WriteString(geometry + "\n")
+		writer.WriteString(fmt.Sprintf("%d\n", len(angles)))
+		writeIESTokens(writer, angles, limit)
+		writeIESTokens(writer, multipliers, limit)
+	} else {
+		writer.WriteString("TILT=NONE\n")
+	}
 
 	numVert := len(lum.VerticalAngles)
 	numHorz := len(lum.HorizontalAngles)
@@ -202,33 +560,152 @@ func (p *IESParser) Write(lum *database.ParsedLuminaire, filepath string) error
 		photometricType = 1
 	}
 
-	writer.WriteString(fmt.Sprintf("1 -1 1 %d %d 1 2 0.2 0.2 %.0f\n",
-		numVert, numHorz, lum.Metadata.InputWatts))
-
-	writer.WriteString(fmt.Sprintf("1 1 %.2f\n", lum.Metadata.InputWatts))
+	unitsType := 2
+	if lum.Metadata.UnitsType == database.UnitsImperial {
+		unitsType = 1
+	}
 
-	writer.WriteString(floatSliceToString(lum.VerticalAngles))
-	writer.WriteString("\n")
+	width, length := iesOpeningDims(lum.Metadata)
+	lamps, lumens := iesLampRating(ext, lum.Metadata.LuminousFlux)
+	writer.WriteString(fmt.Sprintf("%g %g 1 %d %d %d %d %g %g %g\n",
+		lamps, lumens, numVert, numHorz, photometricType, unitsType, width, length,
+		iesHeight(lum.Metadata)))
 
-	writer.WriteString(floatSliceToString(lum.HorizontalAngles))
-	writer.WriteString("\n")
+	writer.WriteString(fmt.Sprintf("%g %g %.2f\n",
+		extensionFloat(ext, "ballast_factor", 1), extensionFloat(ext, "ballast_lamp_factor", 1),
+		lum.Metadata.InputWatts))
 
+	writeIESValues(writer, lum.VerticalAngles, limit, opts.Numbers)
+	writeIESValues(writer, lum.HorizontalAngles, limit, opts.Numbers)
 	for _, row := range lum.CandelaMatrix {
-		writer.WriteString(floatSliceToString(row))
-		writer.WriteString("\n")
+		writeIESValues(writer, row, limit, opts.Numbers)
 	}
 
-	logger.Default.Infof("Wrote IES file to %s", filepath)
-	return nil
+	return writer.Close()
+}
+
+// writeIESKeyword writes "[KEY] value", continuing on [MORE] lines at word
+// boundaries so no line exceeds limit characters. A word longer than a whole
+// line is split.
+func writeIESKeyword(w *outputWriter, key, value string, limit int) {
+	line := "[" + key + "]"
+	hasWord := false
+	for _, word := range strings.Fields(value) {
+		for word != "" {
+			if utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) <= limit {
+				line += " " + word
+				hasWord = true
+				break
+			}
+			if hasWord {
+				w.WriteString(line + "\n")
+				line, hasWord = "[MORE]", false
+				continue
+			}
+			cut := runePrefix(word, limit-utf8.RuneCountInString(line)-1)
+			w.WriteString(line + " " + cut + "\n")
+			line, word = "[MORE]", word[len(cut):]
+		}
+	}
+	if hasWord {
+		w.WriteString(line + "\n")
+	}
+}
+
+// runePrefix returns the first n runes of s, at least one.
+func runePrefix(s string, n int) string {
+	if n < 1 {
+		n = 1
+	}
+	i := 0
+	for j := range s {
+		if i == n {
+			return s[:j]
+		}
+		i++
+	}
+	return s
 }
 
-func floatSliceToString(vals []float64) string {
-	var sb strings.Builder
-	for i, v := range vals {
-		if i > 0 {
-			sb.WriteString(" ")
+// iesExtensionKeywords returns the keywords kept as extensions, in name
+// order, leaving out those the header already carries.
+func iesExtensionKeywords(ext map[string]string, written ...[]Keyword) []Keyword {
+	taken := map[string]bool{}
+	for _, list := range written {
+		for _, kw := range list {
+			if kw.Value != "" {
+				taken[strings.ToUpper(kw.Key)] = true
+			}
+		}
+	}
+	var keywords []Keyword
+	for _, key := range database.Extensions(ext).Keys() {
+		if key == strings.ToUpper(key) && !taken[key] {
+			keywords = append(keywords, Keyword{key, ext[key]})
+		}
+	}
+	return keywords
+}
+
+// writeIESTokens writes numbers as read, wrapped like writeIESValues.
+func writeIESTokens(w *outputWriter, tokens []string, limit int) {
+	lineLen := 0
+	for _, tok := range tokens {
+		if lineLen > 0 && lineLen+1+len(tok) > limit {
+			w.WriteString("\n")
+			lineLen = 0
+		}
+		if lineLen > 0 {
+			w.WriteString(" ")
+			lineLen++
+		}
+		w.WriteString(tok)
+		lineLen += len(tok)
+	}
+	w.WriteString("\n")
+}
+
+// writeIESValues writes a list of numbers in format over as many lines as
+// needed to keep each within limit characters.
+func writeIESValues(w *outputWriter, vals []float64, limit int, format NumberFormat) {
+	lineLen := 0
+	for _, v := range vals {
+		field := format.format(v, 1)
+		if lineLen > 0 && lineLen+1+len(field) > limit {
+			w.WriteString("\n")
+			lineLen = 0
+		}
+		if lineLen > 0 {
+			w.WriteString(" ")
+			lineLen++
+		}
+		w.WriteString(field)
+		lineLen += len(field)
+	}
+	w.WriteString("\n")
+}
+
+func (p *IESParser) Compatibility(lum *database.ParsedLuminaire) []CompatibilityIssue {
+	issues := droppedFields(lum.Metadata, "IES", "catalog_number")
+	if _, lumens := iesLampRating(lum.Extensions.Format("ies"), lum.Metadata.LuminousFlux); lum.Metadata.LuminousFlux > 0 && lumens < 0 {
+		issues = append(issues, CompatibilityIssue{
+			Field:  "luminous_flux",
+			Effect: EffectLost,
+			Code:   CodeAbsolutePhotometry,
+			Detail: "written as absolute photometry (lumens per lamp -1); the stated lamp flux is dropped",
+		})
+	}
+	m := lum.Metadata
+	// In C-plane order, so that the issues come out the same every time.
+	for i, h := range []float64{m.LuminousHeightC0, m.LuminousHeightC90, m.LuminousHeightC180, m.LuminousHeightC270} {
+		if h != m.LuminousHeight() {
+			issues = append(issues, CompatibilityIssue{
+				Field:  fmt.Sprintf("luminous_height_c%d", i*90),
+				Effect: EffectApproximated,
+				Code:   CodeSingleLuminousHeight,
+				Detail: "IES has one luminous height; the tallest side is written",
+			})
 		}
-		sb.WriteString(fmt.Sprintf("%.1f", v))
 	}
-	return sb.String()
+	return issues
 }
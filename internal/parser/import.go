@@ -0,0 +1,89 @@
+package parser
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+
+	"illuminate/internal/database"
+)
+
+// ImportTargetFields are the metadata fields an import profile may set.
+var ImportTargetFields = []string{"manufacturer", "model", "catalog_number"}
+
+// ImportField says where a target field comes from: Source is a template
+// over the parsed metadata like those of MappingProfile, and Pattern an
+// optional regular expression whose first group (or whole match) is kept.
+type ImportField struct {
+	Source  string `json:"source"`
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// ImportProfile adapts a vendor's conventions on import. Some put the model
+// in LUMCAT, others in LUMINAIRE or only in the filename; a profile for
+// {"manufacturer": "Acme"} with
+//
+//	"fields": {"model": {"source": "{original_filename}", "pattern": "^ACME_([^.]+)"}}
+//
+// moves it into place for every file whose detected manufacturer is Acme.
+type ImportProfile struct {
+	// Manufacturer is matched case-insensitively against the manufacturer
+	// the parser detected.
+	Manufacturer string                 `json:"manufacturer"`
+	Fields       map[string]ImportField `json:"fields"`
+}
+
+// Validate checks the target fields, templates and patterns of the profile.
+func (p *ImportProfile) Validate() error {
+	if strings.TrimSpace(p.Manufacturer) == "" {
+		return fmt.Errorf("manufacturer is required")
+	}
+	for target, field := range p.Fields {
+		if !slices.Contains(ImportTargetFields, target) {
+			return fmt.Errorf("unknown target field %q", target)
+		}
+		if err := checkTemplate(field.Source); err != nil {
+			return fmt.Errorf("%s: %w", target, err)
+		}
+		if field.Pattern != "" {
+			if _, err := regexp.Compile(field.Pattern); err != nil {
+				return fmt.Errorf("%s: invalid pattern: %w", target, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Apply sets the target fields of meta. Every source is read from the
+// metadata as parsed, so fields can be swapped; a field whose pattern does
+// not match or whose value comes out empty keeps its parsed value.
+func (p *ImportProfile) Apply(meta *database.Luminaire) {
+	parsed := *meta
+	v := reflect.ValueOf(meta).Elem()
+	for target, field := range p.Fields {
+		value := expandTemplate(field.Source, parsed)
+		if field.Pattern != "" {
+			re, err := regexp.Compile(field.Pattern)
+			if err != nil {
+				continue
+			}
+			m := re.FindStringSubmatch(value)
+			switch {
+			case m == nil:
+				continue
+			case len(m) > 1:
+				value = strings.TrimSpace(m[1])
+			default:
+				value = strings.TrimSpace(m[0])
+			}
+		}
+		if value == "" {
+			continue
+		}
+		if i, ok := metadataFieldIndex[target]; ok {
+			v.Field(i).SetString(value)
+		}
+	}
+}
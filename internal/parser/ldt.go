@@ -1,10 +1,10 @@
 package parser
 
 import (
-	"bufio"
 	"crypto/sha256"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"strconv"
 	"strings"
@@ -13,33 +13,92 @@ import (
 	"illuminate/internal/logger"
 )
 
+// EULUMDAT symmetry indicators (Isym).
+const (
+	ldtSymNone       = 0
+	ldtSymVertical   = 1
+	ldtSymC0C180     = 2
+	ldtSymC90C270    = 3
+	ldtSymQuadrant   = 4
+	ldtHeaderLines   = 26
+	ldtDirectRatios  = 10
+	ldtLampSetFields = 6
+)
+
 type LDTParser struct{}
 
 func NewLDTParser() *LDTParser {
 	return &LDTParser{}
 }
 
-func (p *LDTParser) Parse(filepath string) (*database.ParsedLuminaire, error) {
-	logger.Default.Debugf("parsing LDT file: %s", filepath)
+// ldtLines gives indexed access to the lines of an EULUMDAT file using the
+// 1-based field numbers from the specification.
+type ldtLines []string
+
+func (l ldtLines) str(n int) string {
+	if n < 1 || n > len(l) {
+		return ""
+	}
+	return l[n-1]
+}
+
+func (l ldtLines) float(n int) (float64, error) {
+	s := strings.ReplaceAll(l.str(n), ",", ".")
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid LDT file: line %d: invalid number %q", n, l.str(n))
+	}
+	return v, nil
+}
+
+func (l ldtLines) int(n int) (int, error) {
+	v, err := l.float(n)
+	if err != nil {
+		return 0, err
+	}
+	if !(math.Abs(v) <= math.MaxInt32) {
+		return 0, fmt.Errorf("invalid LDT file: line %d: value out of range %q", n, l.str(n))
+	}
+	return int(v), nil
+}
+
+func (l ldtLines) count(n int, what string, max int) (int, error) {
+	v, err := l.float(n)
+	if err != nil {
+		return 0, err
+	}
+	c, err := checkCount(what, v, max)
+	if err != nil {
+		return 0, fmt.Errorf("invalid LDT file: line %d: %w", n, err)
+	}
+	return c, nil
+}
 
+func (p *LDTParser) Parse(filepath string) (*database.ParsedLuminaire, error) {
 	file, err := os.Open(filepath)
 	if err != nil {
 		return nil, fmt.Errorf("open file: %w", err)
 	}
 	defer file.Close()
 
+	return p.ParseReader(file, filepath)
+}
+
+func (p *LDTParser) ParseReader(r io.Reader, name string) (*database.ParsedLuminaire, error) {
+	logger.Default.Debugf("parsing LDT file: %s", name)
+
 	hash := sha256.New()
-	reader := io.TeeReader(file, hash)
+	reader := io.TeeReader(r, hash)
 
-	scanner := bufio.NewScanner(reader)
-	scanner.Split(bufio.ScanLines)
+	limits := CurrentLimits()
+	scanner := newLineScanner(reader, limits)
 
 	metadata := database.Luminaire{
-		OriginalFilename: filepath,
+		OriginalFilename: name,
 		FormatType:       "LDT",
 	}
 
-	var lines []string
+	var lines ldtLines
 	for scanner.Scan() {
 		lines = append(lines, strings.TrimSpace(scanner.Text()))
 	}
@@ -48,109 +107,193 @@ func (p *LDTParser) Parse(filepath string) (*database.ParsedLuminaire, error) {
 		return nil, fmt.Errorf("scan file: %w", err)
 	}
 
-	if len(lines) < 5 {
-		return nil, fmt.Errorf("invalid LDT file: too few lines")
+	metadata.Manufacturer = strings.TrimSpace(strings.Split(lines.str(1), ";")[0])
+	metadata.TestNumber = strings.TrimSpace(strings.Split(lines.str(8), ";")[0])
+	metadata.LuminaireDesc = lines.str(9)
+	metadata.Model = lines.str(10)
+	metadata.IssueDate = lines.str(12)
+
+	// The text fields are read; a failure from here on still returns what
+	// was read, see Salvage.
+	var count int
+	var extensions database.Extensions
+	partial := &database.ParsedLuminaire{}
+	incomplete := func(err error) error {
+		partial.Metadata = metadata
+		partial.Metadata.FileHash = fmt.Sprintf("%x", hash.Sum(nil))
+		partial.Extensions = extensionsOrNil(extensions)
+		return &IncompleteError{Partial: partial, Planes: count, Err: err}
 	}
 
-	headerParts := strings.Split(lines[0], ";")
-	if len(headerParts) < 2 || headerParts[1] != "Eulumdat2" {
-		return nil, fmt.Errorf("invalid LDT file: not Eulumdat2 format")
+	if len(lines) < ldtHeaderLines {
+		return nil, incomplete(fmt.Errorf("invalid LDT file: too few lines"))
 	}
 
-	metadata.SymmetryFlag, _ = strconv.Atoi(lines[1])
-	formatType, _ := strconv.Atoi(lines[2])
-	metadata.FormatType = fmt.Sprintf("Eulumdat%d", formatType)
-
-	unitTypeLine, _ := strconv.Atoi(lines[3])
-	metadata.UnitsType = "Metric"
-	if unitTypeLine == 2 {
-		metadata.UnitsType = "Imperial"
+	ityp, err := lines.int(2)
+	if err != nil {
+		return nil, incomplete(err)
+	}
+	isym, err := lines.int(3)
+	if err != nil {
+		return nil, incomplete(err)
+	}
+	mc, err := lines.count(4, "C-plane count", limits.MaxAngles)
+	if err != nil {
+		return nil, incomplete(err)
+	}
+	ng, err := lines.count(6, "intensity count", limits.MaxAngles)
+	if err != nil {
+		return nil, incomplete(err)
+	}
+	if isym < ldtSymNone || isym > ldtSymQuadrant {
+		return nil, incomplete(fmt.Errorf("invalid LDT file: symmetry indicator %d", isym))
+	}
+	// Rotationally symmetric files may list no C-planes at all.
+	if (mc == 0 && isym != ldtSymVertical) || ng == 0 {
+		return nil, incomplete(fmt.Errorf("invalid LDT file: angle counts %d x %d", mc, ng))
+	}
+	// A single plane without a declared symmetry can only describe a
+	// rotationally symmetric distribution.
+	if mc == 1 {
+		isym = ldtSymVertical
 	}
 
-	candelaValuesLine, _ := strconv.Atoi(lines[4])
-	_ = candelaValuesLine
+	metadata.SymmetryFlag = ityp
+	metadata.Symmetry = isym
+	// EULUMDAT has never been revised; a header that reads is version 1.0.
+	metadata.FormatVersion, metadata.FormatConfidence = VersionEulumdat, ConfidenceInferred
+	metadata.PhotometricType = database.PhotometricTypeC
+	metadata.UnitsType = database.UnitsMetric
 
-	lightingTechnology, _ := strconv.Atoi(lines[5])
-	_ = lightingTechnology
+	conversionFactor, err := lines.float(24)
+	if err != nil {
+		return nil, incomplete(err)
+	}
+	if conversionFactor <= 0 {
+		conversionFactor = 1
+	}
+	metadata.ConversionFactor = conversionFactor
 
-	if len(lines) >= 8 {
-		lumInfo := strings.Split(lines[7], ";")
-		if len(lumInfo) >= 1 {
-			metadata.LuminaireDesc = lumInfo[0]
-		}
+	numSets, err := lines.count(26, "lamp set count", maxLampSets)
+	if err != nil {
+		return nil, incomplete(err)
+	}
+	if numSets < 1 {
+		return nil, incomplete(fmt.Errorf("invalid LDT file: no lamp sets"))
 	}
 
-	if len(lines) >= 9 {
-		lumCat := strings.Split(lines[8], ";")
-		if len(lumCat) >= 1 {
-			metadata.Model = lumCat[0]
+	// Only the first lamp set is the reference for the relative intensities.
+	lampFlux, err := lines.float(ldtHeaderLines + 3)
+	if err != nil {
+		return nil, incomplete(err)
+	}
+	metadata.LampType = lines.str(ldtHeaderLines + 2)
+	metadata.LuminousFlux = lampFlux
+	metadata.ColorTemp = leadingInt(lines.str(ldtHeaderLines + 4))
+	if cri := leadingInt(lines.str(ldtHeaderLines + 5)); cri >= 10 {
+		metadata.CRI = cri
+	}
+	if watts, err := lines.float(ldtHeaderLines + 6); err == nil {
+		metadata.InputWatts = watts
+	}
+	// Lines 16 and 17 size the luminous area in mm; a zero width is circular.
+	if length, err := lines.float(16); err == nil && length > 0 {
+		metadata.LuminousLength = length / 1000
+		if width, err := lines.float(17); err == nil && width > 0 {
+			metadata.LuminousWidth = width / 1000
 		}
 	}
-
-	if len(lines) >= 13 {
-		if luminousFlux, err := strconv.ParseFloat(lines[12], 64); err == nil {
-			metadata.LuminousFlux = luminousFlux
+	// Lines 18 to 21 are the heights of the luminous area towards C0, C90,
+	// C180 and C270 in mm.
+	for i, h := range []*float64{&metadata.LuminousHeightC0, &metadata.LuminousHeightC90, &metadata.LuminousHeightC180, &metadata.LuminousHeightC270} {
+		if v, err := lines.float(18 + i); err == nil && v > 0 {
+			*h = v / 1000
 		}
 	}
 
-	if len(lines) >= 14 {
-		if inputWatts, err := strconv.ParseFloat(lines[13], 64); err == nil {
-			metadata.InputWatts = inputWatts
+	extensions = ldtExtensions(lines, numSets)
+
+	first, count := ldtStoredPlanes(isym, mc)
+	if err := checkCandelaValues(count, ng, limits.MaxCandelaValues); err != nil {
+		return nil, fmt.Errorf("invalid LDT file: %w", err)
+	}
+
+	cAnglesStart := ldtHeaderLines + numSets*ldtLampSetFields + ldtDirectRatios + 1
+	gAnglesStart := cAnglesStart + mc
+	valuesStart := gAnglesStart + ng
+	// A truncated file keeps its angle lists and complete planes.
+	var truncated error
+	planes := count
+	if need := valuesStart + count*ng - 1; need > len(lines) {
+		truncated = fmt.Errorf("invalid LDT file: expected %d lines, found %d", need, len(lines))
+		if gAnglesStart+ng-1 > len(lines) {
+			return nil, incomplete(truncated)
 		}
+		planes = max(0, len(lines)-valuesStart+1) / ng
 	}
 
-	verticalAngles := make([]float64, 0)
-	horizontalAngles := make([]float64, 0)
-	candelaMatrix := make([][]float64, 0)
-
-	angleStartIdx := -1
-	for i, line := range lines {
-		fields := strings.Split(line, ";")
-		if len(fields) > 0 {
-			if val, err := strconv.ParseFloat(fields[0], 64); err == nil {
-				if val >= 0 && val <= 180 {
-					isAngle := true
-					for _, f := range fields {
-						if _, err := strconv.ParseFloat(f, 64); err != nil {
-							isAngle = false
-							break
-						}
-					}
-					if isAngle && len(fields) > 1 {
-						angleStartIdx = i
-						break
-					}
-				}
-			}
+	cAngles := make([]float64, mc)
+	for i := range cAngles {
+		if cAngles[i], err = lines.float(cAnglesStart + i); err != nil {
+			return nil, incomplete(err)
+		}
+	}
+	verticalAngles := make([]float64, ng)
+	for i := range verticalAngles {
+		if verticalAngles[i], err = lines.float(gAnglesStart + i); err != nil {
+			return nil, incomplete(err)
 		}
 	}
+	partial.VerticalAngles = verticalAngles
 
-	if angleStartIdx >= 0 {
-		for i := angleStartIdx; i < len(lines); i++ {
-			fields := strings.Split(lines[i], ";")
-			vals := make([]float64, 0)
-			for _, f := range fields {
-				if f == "" {
-					continue
-				}
-				if v, err := strconv.ParseFloat(f, 64); err == nil {
-					vals = append(vals, v)
-				}
+	scale := conversionFactor
+	if lampFlux > 0 {
+		scale *= lampFlux / 1000
+	}
+
+	stored := make([][]float64, planes)
+	for i := range stored {
+		row := make([]float64, ng)
+		for j := range row {
+			v, err := lines.float(valuesStart + i*ng + j)
+			if err != nil {
+				partial.CandelaMatrix = stored[:i]
+				return nil, incomplete(err)
 			}
-			if len(vals) > 0 {
-				if verticalAngles == nil || len(verticalAngles) == 0 {
-					verticalAngles = vals
-				} else if len(vals) == len(horizontalAngles) || len(horizontalAngles) == 0 {
-					candelaMatrix = append(candelaMatrix, vals)
-				}
+			row[j] = v * scale
+		}
+		stored[i] = row
+	}
+	if truncated != nil {
+		partial.CandelaMatrix = stored
+		partial.HorizontalAngles = []float64{0}
+		if isym != ldtSymVertical {
+			partial.HorizontalAngles = make([]float64, len(stored))
+			for i := range stored {
+				partial.HorizontalAngles[i] = cAngles[(first+i)%mc]
 			}
 		}
+		return nil, incomplete(truncated)
+	}
 
-		if len(verticalAngles) > 0 && len(candelaMatrix) > 0 {
-			horizontalAngles = make([]float64, len(candelaMatrix[0]))
-			for i := range horizontalAngles {
-				horizontalAngles[i] = float64(i * 10)
-			}
+	// The one plane of a rotationally symmetric file stands for every C
+	// angle, whichever it is listed as.
+	horizontalAngles := []float64{0}
+	if isym != ldtSymVertical {
+		horizontalAngles = make([]float64, count)
+		for i := range horizontalAngles {
+			horizontalAngles[i] = cAngles[(first+i)%mc]
+		}
+	}
+	candelaMatrix := stored
+
+	// Planes 270..90 are mirrored onto 90..270 so horizontal angles stay
+	// ascending, matching the IES convention for this symmetry.
+	if isym == ldtSymC90C270 {
+		candelaMatrix = make([][]float64, count)
+		for i := range stored {
+			candelaMatrix[count-1-i] = stored[i]
+			horizontalAngles[count-1-i] = math.Mod(540-cAngles[(first+i)%mc], 360)
 		}
 	}
 
@@ -165,111 +308,399 @@ func (p *LDTParser) Parse(filepath string) (*database.ParsedLuminaire, error) {
 		VerticalAngles:   verticalAngles,
 		HorizontalAngles: horizontalAngles,
 		CandelaMatrix:    candelaMatrix,
+		Extensions:       extensionsOrNil(extensions),
 	}, nil
 }
 
+// ldtExtensionFields are the numeric header fields the common model has no
+// slot for, by line number, with the value the writer uses without them.
+var ldtExtensionFields = []struct {
+	key  string
+	line int
+	def  float64
+}{
+	{"body_length", 13, 0},
+	{"body_width", 14, 0},
+	{"body_height", 15, 0},
+	{"downward_flux_fraction", 22, 100},
+	{"light_output_ratio", 23, 100},
+	{"tilt", 25, 0},
+	{"lamp_count", ldtHeaderLines + 1, 1},
+}
+
+// ldtExtensions collects the header fields that differ from what the writer
+// would put there, and the direct ratios that follow the lamp sets.
+func ldtExtensions(lines ldtLines, numSets int) database.Extensions {
+	extensions := database.Extensions{}
+	for _, f := range ldtExtensionFields {
+		if v, err := lines.float(f.line); err == nil && v != f.def {
+			extensions["ldt:"+f.key] = strconv.FormatFloat(v, 'g', -1, 64)
+		}
+	}
+
+	ratios := make([]string, ldtDirectRatios)
+	nonZero := false
+	for i := range ratios {
+		v, err := lines.float(ldtHeaderLines + numSets*ldtLampSetFields + 1 + i)
+		if err != nil {
+			return extensions
+		}
+		ratios[i] = strconv.FormatFloat(v, 'g', -1, 64)
+		nonZero = nonZero || v != 0
+	}
+	if nonZero {
+		extensions["ldt:direct_ratios"] = strings.Join(ratios, " ")
+	}
+	return extensions
+}
+
+// ldtStoredPlanes returns the index of the first stored C-plane and the number
+// of planes present in the file for the given symmetry indicator.
+func ldtStoredPlanes(isym, mc int) (int, int) {
+	switch isym {
+	case ldtSymVertical:
+		return 0, 1
+	case ldtSymC0C180:
+		return 0, mc/2 + 1
+	case ldtSymC90C270:
+		return 3 * mc / 4, mc/2 + 1
+	case ldtSymQuadrant:
+		return 0, mc/4 + 1
+	default:
+		return 0, mc
+	}
+}
+
+// ldtSymmetryFor picks the EULUMDAT symmetry indicator matching the range of
+// horizontal angles in the common model.
+func ldtSymmetryFor(horizontal []float64) int {
+	if len(horizontal) <= 1 {
+		return ldtSymVertical
+	}
+	first, last := horizontal[0], horizontal[len(horizontal)-1]
+	switch {
+	case first == 0 && last == 90:
+		return ldtSymQuadrant
+	case first == 0 && last == 180:
+		return ldtSymC0C180
+	case first == 90 && last == 270:
+		return ldtSymC90C270
+	default:
+		return ldtSymNone
+	}
+}
+
+func leadingInt(s string) int {
+	end := 0
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	n, _ := strconv.Atoi(s[:end])
+	return n
+}
+
+func uniformStep(angles []float64) float64 {
+	if len(angles) < 2 {
+		return 0
+	}
+	step := angles[1] - angles[0]
+	for i := 2; i < len(angles); i++ {
+		if math.Abs(angles[i]-angles[i-1]-step) > 1e-6 {
+			return 0
+		}
+	}
+	return step
+}
+
 func (p *LDTParser) Write(lum *database.ParsedLuminaire, filepath string) error {
-	file, err := os.Create(filepath)
+	return WriteFile(p, lum, filepath, DefaultWriteOptions())
+}
+
+// ldtTextFieldLength is the most characters an EULUMDAT text field holds.
+const ldtTextFieldLength = 78
+
+func (p *LDTParser) Render(w io.Writer, lum *database.ParsedLuminaire, opts WriteOptions) error {
+	writer, err := newOutputWriter(w, opts)
 	if err != nil {
-		return fmt.Errorf("create file: %w", err)
+		return err
 	}
-	defer file.Close()
 
-	writer := bufio.NewWriter(file)
-	defer writer.Flush()
+	horizontal := lum.HorizontalAngles
+	planes := lum.CandelaMatrix
+	if n := len(horizontal); n > 1 && horizontal[0] == 0 && horizontal[n-1] == 360 && len(planes) == n {
+		horizontal = horizontal[:n-1]
+		planes = planes[:n-1]
+	}
+
+	isym := ldtSymmetryFor(horizontal)
+	ityp := 3
+	if isym == ldtSymVertical {
+		ityp = 1
+	}
 
-	writer.WriteString("WE-EF;Eulumdat2\n")
-	writer.WriteString(fmt.Sprintf("%d\n", lum.Metadata.SymmetryFlag))
-	writer.WriteString("3\n")
+	dc := uniformStep(horizontal)
+	mc := len(horizontal)
+	switch isym {
+	case ldtSymVertical:
+		mc, dc = 1, 0
+	case ldtSymC0C180, ldtSymC90C270:
+		if dc > 0 {
+			mc = int(math.Round(360 / dc))
+		}
+	case ldtSymQuadrant:
+		if dc > 0 {
+			mc = int(math.Round(360 / dc))
+		}
+	}
 
 	numVert := len(lum.VerticalAngles)
-	numHorz := 0
-	if len(lum.CandelaMatrix) > 0 {
-		numHorz = len(lum.CandelaMatrix[0])
+
+	ext := lum.Extensions.Format("ldt")
+	set := opts.LampSet
+	if set.Count == 0 {
+		set.Count = int(extensionFloat(ext, "lamp_count", 0))
+	}
+	lampSet := set.resolve(lum.Metadata)
+	text := ldtTextFields(lum.Metadata, opts.Mapping, lampSet.Type)
+	var mapping map[string]string
+	if opts.Mapping != nil {
+		mapping = opts.Mapping.LDT
+	}
+	if _, mapped := mapping["filename"]; opts.Provenance != nil && !mapped {
+		text["filename"] = opts.Provenance.short(opts, ldtTextFieldLength)
+	}
+
+	// num formats one numeric field line, honouring the decimal separator.
+	num := func(format string, v float64) string {
+		s := fmt.Sprintf(format, v)
+		if opts.UseCommaDecimal {
+			s = strings.Replace(s, ".", ",", 1)
+		}
+		return s + "\n"
+	}
+	// value formats one angle or intensity as opts.Numbers says.
+	value := func(v float64, decimals int) string {
+		s := opts.Numbers.format(v, decimals)
+		if opts.UseCommaDecimal {
+			s = strings.Replace(s, ".", ",", 1)
+		}
+		return s + "\n"
 	}
+	// field writes an extension field as read, or def in format without it.
+	field := func(key, format string, def float64) string {
+		if v := extensionFloat(ext, key, math.NaN()); !math.IsNaN(v) {
+			return num("%g", v)
+		}
+		return num(format, def)
+	}
+
+	writer.WriteString(fmt.Sprintf("%s\n", text["company"]))
+	writer.WriteString(fmt.Sprintf("%d\n", ityp))
+	writer.WriteString(fmt.Sprintf("%d\n", isym))
+	writer.WriteString(fmt.Sprintf("%d\n", mc))
+	writer.WriteString(num("%g", dc))
 	writer.WriteString(fmt.Sprintf("%d\n", numVert))
-	writer.WriteString(fmt.Sprintf("%d\n", numHorz))
+	writer.WriteString(num("%g", uniformStep(lum.VerticalAngles)))
+	writer.WriteString(fmt.Sprintf("%s\n", text["report_number"]))
+	writer.WriteString(fmt.Sprintf("%s\n", text["luminaire_name"]))
+	writer.WriteString(fmt.Sprintf("%s\n", text["luminaire_number"]))
+	writer.WriteString(fmt.Sprintf("%s\n", text["filename"]))
+	writer.WriteString(fmt.Sprintf("%s\n", text["date_user"]))
+
+	// Luminaire body dimensions are not part of the model; they come back
+	// from the extensions of an EULUMDAT source.
+	for _, key := range []string{"body_length", "body_width", "body_height"} {
+		writer.WriteString(field(key, "%g", 0))
+	}
+	writer.WriteString(num("%g", math.Round(lum.Metadata.LuminousLength*1000)))
+	writer.WriteString(num("%g", math.Round(lum.Metadata.LuminousWidth*1000)))
+	m := lum.Metadata
+	for _, h := range []float64{m.LuminousHeightC0, m.LuminousHeightC90, m.LuminousHeightC180, m.LuminousHeightC270} {
+		writer.WriteString(num("%g", math.Round(h*1000)))
+	}
 
-	symmetry := lum.Metadata.Symmetry
-	if symmetry == 0 {
-		symmetry = 1
+	writer.WriteString(field("downward_flux_fraction", "%.1f", 100))
+	writer.WriteString(field("light_output_ratio", "%.1f", 100))
+	writer.WriteString(num("%.1f", 1))
+	writer.WriteString(field("tilt", "%g", 0))
+
+	flux := lum.Metadata.LuminousFlux
+	if flux <= 0 {
+		flux = 1000
 	}
-	writer.WriteString(fmt.Sprintf("%d\n", symmetry))
 
 	writer.WriteString("1\n")
-
-	lumDesc := lum.Metadata.LuminaireDesc
-	if lumDesc == "" {
-		lumDesc = lum.Metadata.Model
+	writer.WriteString(fmt.Sprintf("%d\n", lampSet.Count))
+	writer.WriteString(fmt.Sprintf("%s\n", text["lamp_type"]))
+	writer.WriteString(num("%.1f", flux))
+	writer.WriteString(fmt.Sprintf("%s\n", lampSet.ColorTemp))
+	writer.WriteString(fmt.Sprintf("%s\n", lampSet.CRIGroup))
+	writer.WriteString(num("%.1f", lum.Metadata.InputWatts))
+
+	ratios := strings.Fields(ext["direct_ratios"])
+	if len(ratios) != ldtDirectRatios {
+		ratios = nil
 	}
-	if lumDesc == "" {
-		lumDesc = "Luminaire"
+	for i := 0; i < ldtDirectRatios; i++ {
+		v := 0.0
+		if ratios != nil {
+			v, _ = strconv.ParseFloat(ratios[i], 64)
+		}
+		writer.WriteString(num("%g", v))
 	}
-	writer.WriteString(fmt.Sprintf("%s\n", lumDesc))
 
-	model := lum.Metadata.Model
-	if model == "" {
-		model = lumDesc
+	for i := 0; i < mc; i++ {
+		writer.WriteString(value(ldtPlaneAngle(isym, horizontal, i, dc), 1))
 	}
-	writer.WriteString(fmt.Sprintf("%s\n", model))
-	writer.WriteString(fmt.Sprintf("%s\n", model))
 
-	writer.WriteString("Generated by illuminate\n")
+	for _, v := range lum.VerticalAngles {
+		writer.WriteString(value(v, 1))
+	}
 
-	flux := int(lum.Metadata.LuminousFlux)
-	if flux == 0 {
-		flux = 1000
+	rows := planes
+	if isym == ldtSymC90C270 {
+		rows = make([][]float64, len(planes))
+		for i := range planes {
+			rows[len(planes)-1-i] = planes[i]
+		}
 	}
-	writer.WriteString(fmt.Sprintf("%d\n", flux))
 
-	watts := int(lum.Metadata.InputWatts)
-	if watts == 0 {
-		watts = 100
+	for _, row := range rows {
+		for _, v := range row {
+			writer.WriteString(value(v*1000/flux, 5))
+		}
 	}
-	writer.WriteString(fmt.Sprintf("%d\n", watts))
 
-	writer.WriteString("0\n")
-	writer.WriteString("0\n")
-	writer.WriteString("0\n")
-	writer.WriteString("0\n")
-	writer.WriteString("100.0\n")
-	writer.WriteString("90.6\n")
-	writer.WriteString("1.0\n")
-	writer.WriteString("0\n")
-	writer.WriteString("3\n")
-	writer.WriteString("24\n")
-	writer.WriteString("LED\n")
+	return writer.Close()
+}
+
+// LampSet is the EULUMDAT lamp set written for sources that do not describe
+// one. The lamp type, CCT and CRI of the metadata take precedence; a zero
+// field falls back to DefaultLampSet.
+type LampSet struct {
+	Count     int    `json:"count,omitempty"`
+	Type      string `json:"type,omitempty"`
+	ColorTemp string `json:"color_temp,omitempty"` // colour appearance, e.g. "3000K" or "830"
+	CRIGroup  string `json:"cri_group,omitempty"`  // e.g. "80" or "1B"
+}
 
-	lampType := lum.Metadata.LampType
-	if lampType == "" {
-		lampType = "LED"
+// DefaultLampSet is what LDT files have always been written with.
+var DefaultLampSet = LampSet{Count: 1, Type: "LED", ColorTemp: "3000K", CRIGroup: "80"}
+
+// Validate rejects lamp sets that would break the line structure.
+func (s LampSet) Validate() error {
+	if s.Count < 0 || s.Count > 999 {
+		return fmt.Errorf("lamp count must be between 0 and 999, not %d", s.Count)
 	}
-	writer.WriteString(fmt.Sprintf("%s\n", lampType))
+	for _, v := range []string{s.Type, s.ColorTemp, s.CRIGroup} {
+		if strings.ContainsAny(v, "\r\n") {
+			return fmt.Errorf("lamp set fields must be single line")
+		}
+	}
+	return nil
+}
 
-	writer.WriteString("5800.0\n")
-	writer.WriteString("4000K\n")
-	writer.WriteString("70\n")
-	writer.WriteString("44.5\n")
-	writer.WriteString("1\n")
+// resolve fills the lamp set from meta and the defaults.
+func (s LampSet) resolve(meta database.Luminaire) LampSet {
+	if s.Count == 0 {
+		s.Count = DefaultLampSet.Count
+	}
+	if s.Type == "" {
+		s.Type = DefaultLampSet.Type
+	}
+	if s.ColorTemp == "" {
+		s.ColorTemp = DefaultLampSet.ColorTemp
+	}
+	if s.CRIGroup == "" {
+		s.CRIGroup = DefaultLampSet.CRIGroup
+	}
+	if meta.LampType != "" {
+		s.Type = meta.LampType
+	}
+	if meta.ColorTemp > 0 {
+		s.ColorTemp = fmt.Sprintf("%dK", meta.ColorTemp)
+	}
+	if meta.CRI > 0 {
+		s.CRIGroup = strconv.Itoa(meta.CRI)
+	}
+	return s
+}
 
-	writer.WriteString("0\n")
-	writer.WriteString("0\n")
-	writer.WriteString("0\n")
-	writer.WriteString("0\n")
+// ldtTextFields returns the EULUMDAT text fields, keyed as in
+// LDTMappingFields, with the profile applied on top of the defaults.
+// lampType is used when the metadata has none.
+func ldtTextFields(meta database.Luminaire, mapping *MappingProfile, lampType string) map[string]string {
+	company := meta.Manufacturer
+	if company == "" {
+		company = "illuminate"
+	}
+	name := meta.LuminaireDesc
+	if name == "" {
+		name = meta.Model
+	}
+	if name == "" {
+		name = "Luminaire"
+	}
+	number := meta.Model
+	if number == "" {
+		number = name
+	}
+	if meta.LampType != "" {
+		lampType = meta.LampType
+	}
 
-	for _, v := range lum.VerticalAngles {
-		writer.WriteString(fmt.Sprintf("%.1f\n", v))
+	fields := []Keyword{
+		{"company", company},
+		{"report_number", meta.TestNumber},
+		{"luminaire_name", name},
+		{"luminaire_number", number},
+		{"filename", "Generated by illuminate"},
+		{"date_user", meta.IssueDate},
+		{"lamp_type", lampType},
+	}
+	if mapping != nil {
+		fields = mapFields(fields, mapping.LDT, meta)
 	}
 
-	for _, row := range lum.CandelaMatrix {
-		for i, v := range row {
-			if i > 0 {
-				writer.WriteString(";")
-			}
-			writer.WriteString(fmt.Sprintf("%.5f", v))
+	text := make(map[string]string, len(fields))
+	for _, f := range fields {
+		text[f.Key] = f.Value
+	}
+	return text
+}
+
+// ldtPlaneAngle returns the i-th of the Mc C-plane angles listed in the header.
+func ldtPlaneAngle(isym int, horizontal []float64, i int, dc float64) float64 {
+	if isym == ldtSymNone || dc == 0 {
+		if i < len(horizontal) {
+			return horizontal[i]
 		}
-		writer.WriteString("\n")
+		return 0
 	}
+	return float64(i) * dc
+}
 
-	return nil
+func (p *LDTParser) Compatibility(lum *database.ParsedLuminaire) []CompatibilityIssue {
+	meta := lum.Metadata
+	issues := droppedFields(meta, "EULUMDAT", "catalog_number", "lamp_catalog",
+		"ballast", "test_lab", "test_date", "lamp_position", "luminaire_candela",
+		"lamp_lumen_depreciation", "driver_maintenance_factor", "rated_life", "thd",
+		"inrush_current", "frequency")
+	if meta.LuminousFlux <= 0 {
+		issues = append(issues, CompatibilityIssue{
+			Field:  "luminous_flux",
+			Effect: EffectApproximated,
+			Code:   CodeRelativeIntensity,
+			Detail: "EULUMDAT stores cd/klm; a lamp flux of 1000 lm is assumed",
+		})
+	}
+	if h := lum.HorizontalAngles; len(h) > 2 && uniformStep(h) == 0 && ldtSymmetryFor(h) != ldtSymNone {
+		issues = append(issues, CompatibilityIssue{
+			Field:  "horizontal_angles",
+			Effect: EffectApproximated,
+			Code:   CodeUnevenCPlanes,
+			Detail: "symmetric EULUMDAT needs evenly spaced C-planes; the planes are listed with no spacing",
+		})
+	}
+	return append(issues, typeCOnly(meta, "EULUMDAT")...)
 }
@@ -0,0 +1,156 @@
+package parser
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"sync/atomic"
+)
+
+// ErrLimitExceeded is wrapped by every error caused by a file going over one
+// of the configured Limits.
+var ErrLimitExceeded = errors.New("parser limit exceeded")
+
+// Limits caps what a parser accepts from a single file. Counts are checked
+// against these before anything is allocated, so a corrupt or hostile header
+// cannot trigger huge allocations. A zero field disables that check.
+type Limits struct {
+	MaxLines         int
+	MaxLineLength    int
+	MaxAngles        int
+	MaxCandelaValues int
+}
+
+// DefaultLimits comfortably fits 0.1° vertical by 1° horizontal data.
+var DefaultLimits = Limits{
+	MaxLines:         500_000,
+	MaxLineLength:    bufio.MaxScanTokenSize,
+	MaxAngles:        3601,
+	MaxCandelaValues: 1_000_000,
+}
+
+const maxLampSets = 64
+
+var limits atomic.Pointer[Limits]
+
+func init() {
+	l := LimitsFromEnv()
+	limits.Store(&l)
+}
+
+// LimitsFromEnv starts from DefaultLimits and applies any PARSER_MAX_*
+// environment overrides.
+func LimitsFromEnv() Limits {
+	l := DefaultLimits
+	envInt("PARSER_MAX_LINES", &l.MaxLines)
+	envInt("PARSER_MAX_LINE_LENGTH", &l.MaxLineLength)
+	envInt("PARSER_MAX_ANGLES", &l.MaxAngles)
+	envInt("PARSER_MAX_CANDELA_VALUES", &l.MaxCandelaValues)
+	return l
+}
+
+func envInt(key string, dst *int) {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil && v >= 0 {
+		*dst = v
+	}
+}
+
+// SetLimits replaces the limits used by all parsers.
+func SetLimits(l Limits) {
+	limits.Store(&l)
+}
+
+// CurrentLimits returns the limits in effect.
+func CurrentLimits() Limits {
+	return *limits.Load()
+}
+
+type LimitError struct {
+	Limit string
+	Value float64
+	Max   int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s of %g exceeds the limit of %d", e.Limit, e.Value, e.Max)
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// checkCount validates a count read from a file header.
+func checkCount(what string, v float64, max int) (int, error) {
+	if math.IsNaN(v) || v < 0 || v != math.Trunc(v) {
+		return 0, fmt.Errorf("%s out of range: %g", what, v)
+	}
+	if max > 0 && v > float64(max) {
+		return 0, &LimitError{Limit: what, Value: v, Max: max}
+	}
+	if v > math.MaxInt32 {
+		return 0, fmt.Errorf("%s out of range: %g", what, v)
+	}
+	return int(v), nil
+}
+
+// checkCandelaValues rejects grids whose total size goes over the limit.
+func checkCandelaValues(planes, values int, max int) error {
+	if max > 0 && planes*values > max {
+		return &LimitError{Limit: "candela value count", Value: float64(planes * values), Max: max}
+	}
+	return nil
+}
+
+// maxDataValues bounds the number of numeric values a file body may hold: a
+// full candela grid, both angle lists and a little room for header fields.
+func maxDataValues(l Limits) int {
+	if l.MaxCandelaValues == 0 || l.MaxAngles == 0 {
+		return 0
+	}
+	return l.MaxCandelaValues + 4*l.MaxAngles + 64
+}
+
+// lineScanner is a bufio.Scanner that stops with a LimitError once the file
+// goes over the configured line count or line length.
+type lineScanner struct {
+	*bufio.Scanner
+	limits Limits
+	lines  int
+	err    error
+}
+
+func newLineScanner(r io.Reader, l Limits) *lineScanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Split(bufio.ScanLines)
+	if l.MaxLineLength > 0 {
+		scanner.Buffer(make([]byte, 0, min(l.MaxLineLength, 4096)), l.MaxLineLength)
+	}
+	return &lineScanner{Scanner: scanner, limits: l}
+}
+
+func (s *lineScanner) Scan() bool {
+	if s.err != nil || !s.Scanner.Scan() {
+		return false
+	}
+	s.lines++
+	if s.limits.MaxLines > 0 && s.lines > s.limits.MaxLines {
+		s.err = &LimitError{Limit: "line count", Value: float64(s.lines), Max: s.limits.MaxLines}
+		return false
+	}
+	return true
+}
+
+func (s *lineScanner) Err() error {
+	if s.err != nil {
+		return s.err
+	}
+	err := s.Scanner.Err()
+	if errors.Is(err, bufio.ErrTooLong) {
+		return &LimitError{Limit: "line length", Value: float64(s.limits.MaxLineLength + 1), Max: s.limits.MaxLineLength}
+	}
+	return err
+}
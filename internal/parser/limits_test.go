@@ -0,0 +1,34 @@
+package parser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLimitsRejectHostileHeaders(t *testing.T) {
+	defer SetLimits(CurrentLimits())
+	SetLimits(Limits{MaxLines: 1000, MaxLineLength: 1024, MaxAngles: 361, MaxCandelaValues: 10_000})
+
+	cases := []struct {
+		name string
+		ext  string
+		data string
+	}{
+		{"ies angle count", ".ies", "TILT=NONE\n1 -1 1 1000000000 1 1 2 0 0 0\n1 1 10\n"},
+		{"ies candela grid", ".ies", "TILT=NONE\n1 -1 1 200 200 1 2 0 0 0\n1 1 10\n"},
+		{"ldt c-planes", ".ldt", "Company\n1\n0\n1000000000\n" + strings.Repeat("0\n", 30)},
+		{"line count", ".cie", strings.Repeat("1\n", 2000)},
+		{"line length", ".ies", strings.Repeat("x", 2048) + "\n"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p, _ := GetParser("limits" + tc.ext)
+			_, err := p.ParseReader(strings.NewReader(tc.data), "limits"+tc.ext)
+			if !errors.Is(err, ErrLimitExceeded) {
+				t.Fatalf("got %v, want ErrLimitExceeded", err)
+			}
+		})
+	}
+}
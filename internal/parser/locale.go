@@ -0,0 +1,33 @@
+package parser
+
+import (
+	"golang.org/x/text/language"
+)
+
+// commaDecimalLanguages write numbers with a decimal comma in everyday use.
+var commaDecimalLanguages = map[string]bool{
+	"bg": true, "ca": true, "cs": true, "da": true, "de": true, "el": true,
+	"es": true, "et": true, "fi": true, "fr": true, "hr": true, "hu": true,
+	"id": true, "it": true, "lt": true, "lv": true, "nb": true, "nl": true,
+	"nn": true, "no": true, "pl": true, "pt": true, "ro": true, "ru": true,
+	"sk": true, "sl": true, "sr": true, "sv": true, "tr": true, "uk": true,
+	"vi": true,
+}
+
+// pointDecimalRegions override their language's comma, e.g. de-CH and it-CH.
+var pointDecimalRegions = map[string]bool{"CH": true, "LI": true}
+
+// CommaDecimalForLocale reports whether the BCP 47 locale (e.g. "de-DE",
+// "fr", "en-GB") expects a decimal comma. Unparseable locales get a point.
+func CommaDecimalForLocale(locale string) bool {
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return false
+	}
+	base, _ := tag.Base()
+	region, conf := tag.Region()
+	if conf == language.Exact && pointDecimalRegions[region.String()] {
+		return false
+	}
+	return commaDecimalLanguages[base.String()]
+}
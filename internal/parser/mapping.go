@@ -0,0 +1,170 @@
+package parser
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"illuminate/internal/database"
+)
+
+// Keyword is an extra IES keyword written after the mapped ones, e.g.
+// [OTHER] internal ids or [SEARCH] tags.
+type Keyword struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+var keywordKeyRegex = regexp.MustCompile(`^_?[A-Z][A-Z0-9_]*$`)
+
+// Validate rejects keys the IES grammar cannot carry and values that would
+// break out of the keyword line.
+func (k Keyword) Validate() error {
+	if !keywordKeyRegex.MatchString(k.Key) {
+		return fmt.Errorf("invalid keyword %q: use upper-case letters, digits and underscores", k.Key)
+	}
+	if k.Key == "MORE" {
+		return fmt.Errorf("keyword MORE is reserved for line continuation")
+	}
+	if strings.ContainsAny(k.Value, "\r\n") {
+		return fmt.Errorf("keyword %s: value must be a single line", k.Key)
+	}
+	return nil
+}
+
+// MappingProfile controls which metadata lands in which output field, so
+// exports can follow a customer's house style. Values are templates in which
+// {field} is replaced by the luminaire metadata field of that JSON name, e.g.
+// "{manufacturer} {catalog_number}". A mapped empty template drops the field.
+type MappingProfile struct {
+	// IES maps keywords (without brackets) to templates. Keywords not in
+	// the standard set are written after it in alphabetical order.
+	IES map[string]string `json:"ies,omitempty"`
+	// LDT maps the EULUMDAT text fields listed in LDTMappingFields.
+	LDT map[string]string `json:"ldt,omitempty"`
+	// Keywords are appended to every IES export made with this profile.
+	Keywords []Keyword `json:"keywords,omitempty"`
+}
+
+// LDTMappingFields are the EULUMDAT text fields a profile may remap.
+var LDTMappingFields = []string{
+	"company", "report_number", "luminaire_name", "luminaire_number",
+	"filename", "date_user", "lamp_type",
+}
+
+// Validate checks every key and template of the profile.
+func (m *MappingProfile) Validate() error {
+	for key, tmpl := range m.IES {
+		if err := (Keyword{Key: key, Value: tmpl}).Validate(); err != nil {
+			return err
+		}
+		if err := checkTemplate(tmpl); err != nil {
+			return fmt.Errorf("ies %s: %w", key, err)
+		}
+	}
+	for key, tmpl := range m.LDT {
+		known := false
+		for _, f := range LDTMappingFields {
+			known = known || f == key
+		}
+		if !known {
+			return fmt.Errorf("unknown ldt field %q", key)
+		}
+		if err := checkTemplate(tmpl); err != nil {
+			return fmt.Errorf("ldt %s: %w", key, err)
+		}
+	}
+	for _, kw := range m.Keywords {
+		if err := kw.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var templateField = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+func checkTemplate(tmpl string) error {
+	if strings.ContainsAny(tmpl, "\r\n") {
+		return fmt.Errorf("template must be a single line")
+	}
+	for _, m := range templateField.FindAllStringSubmatch(tmpl, -1) {
+		if _, ok := metadataFieldIndex[m[1]]; !ok {
+			return fmt.Errorf("unknown field {%s}", m[1])
+		}
+	}
+	return nil
+}
+
+// metadataFieldIndex maps the JSON names of database.Luminaire to field
+// indexes for template expansion.
+var metadataFieldIndex = func() map[string]int {
+	index := map[string]int{}
+	t := reflect.TypeOf(database.Luminaire{})
+	for i := 0; i < t.NumField(); i++ {
+		if name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ","); name != "" && name != "-" {
+			index[name] = i
+		}
+	}
+	return index
+}()
+
+// expandTemplate fills {field} placeholders from the metadata. Unset numbers
+// expand to an empty string.
+func expandTemplate(tmpl string, meta database.Luminaire) string {
+	v := reflect.ValueOf(meta)
+	out := templateField.ReplaceAllStringFunc(tmpl, func(m string) string {
+		i, ok := metadataFieldIndex[m[1:len(m)-1]]
+		if !ok {
+			return ""
+		}
+		f := v.Field(i)
+		switch f.Kind() {
+		case reflect.String:
+			return f.String()
+		case reflect.Int, reflect.Int64:
+			if f.Int() == 0 {
+				return ""
+			}
+			return strconv.FormatInt(f.Int(), 10)
+		case reflect.Float64:
+			if f.Float() == 0 {
+				return ""
+			}
+			return strconv.FormatFloat(f.Float(), 'f', -1, 64)
+		}
+		return fmt.Sprint(f.Interface())
+	})
+	return strings.Join(strings.Fields(out), " ")
+}
+
+// mapFields applies a profile to a writer's default field values, keeping
+// their order and appending fields the defaults do not have in sorted order.
+func mapFields(defaults []Keyword, mapping map[string]string, meta database.Luminaire) []Keyword {
+	if len(mapping) == 0 {
+		return defaults
+	}
+	out := make([]Keyword, 0, len(defaults)+len(mapping))
+	seen := map[string]bool{}
+	for _, kw := range defaults {
+		seen[kw.Key] = true
+		if tmpl, ok := mapping[kw.Key]; ok {
+			kw.Value = expandTemplate(tmpl, meta)
+		}
+		out = append(out, kw)
+	}
+	var extra []string
+	for key := range mapping {
+		if !seen[key] {
+			extra = append(extra, key)
+		}
+	}
+	sort.Strings(extra)
+	for _, key := range extra {
+		out = append(out, Keyword{Key: key, Value: expandTemplate(mapping[key], meta)})
+	}
+	return out
+}
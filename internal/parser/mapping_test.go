@@ -0,0 +1,99 @@
+package parser
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"illuminate/internal/database"
+	"illuminate/internal/synth"
+)
+
+func TestMappingProfileAndKeywords(t *testing.T) {
+	lum, err := synth.Generate(synth.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lum.Metadata.CatalogNumber = "DL-10-840"
+
+	opts := WriteOptions{
+		Mapping: &MappingProfile{
+			IES: map[string]string{
+				"LUMCAT":    "{catalog_number}",
+				"LUMINAIRE": "{manufacturer} {model}",
+				"TESTLAB":   "",
+			},
+			LDT:      map[string]string{"luminaire_number": "{catalog_number}", "filename": "house-style.ldt"},
+			Keywords: []Keyword{{"SEARCH", "downlight recessed"}},
+		},
+		Keywords: []Keyword{{"OTHER", "ERP-4711"}},
+	}
+
+	ies, err := Encode(NewIESParser(), lum, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"[LUMCAT] DL-10-840\n", "[LUMINAIRE] Illuminate SYNTH-lambertian\n", "[SEARCH] downlight recessed\n", "[OTHER] ERP-4711\n"} {
+		if !bytes.Contains(ies, []byte(want)) {
+			t.Errorf("IES output lacks %q", want)
+		}
+	}
+	if bytes.Contains(ies, []byte("[TESTLAB]")) {
+		t.Error("mapping TESTLAB to an empty template should drop it")
+	}
+
+	ldt, err := Encode(NewLDTParser(), lum, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(ldt), "\n")
+	if lines[9] != "DL-10-840" || lines[10] != "house-style.ldt" {
+		t.Errorf("LDT lines 10-11 = %q, %q", lines[9], lines[10])
+	}
+
+	for _, bad := range []WriteOptions{
+		{Keywords: []Keyword{{"lower", "x"}}},
+		{Keywords: []Keyword{{"OTHER", "two\nlines"}}},
+		{Mapping: &MappingProfile{IES: map[string]string{"LUMCAT": "{no_such_field}"}}},
+		{Mapping: &MappingProfile{LDT: map[string]string{"symmetry": "1"}}},
+	} {
+		if _, err := Encode(NewIESParser(), lum, bad); err == nil {
+			t.Errorf("options %+v should be rejected", bad)
+		}
+	}
+}
+
+func TestImportProfile(t *testing.T) {
+	meta := database.Luminaire{
+		Manufacturer:     "Acme",
+		Model:            "Downlight 200",
+		LuminaireDesc:    "DL200-840",
+		OriginalFilename: "ACME_DL200-840-W.ies",
+	}
+	profile := ImportProfile{
+		Manufacturer: "acme",
+		Fields: map[string]ImportField{
+			"catalog_number": {Source: "{model}"},
+			"model":          {Source: "{original_filename}", Pattern: `^ACME_([^.]+)`},
+			"manufacturer":   {Source: "{lamp_type}"},
+		},
+	}
+	if err := profile.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	profile.Apply(&meta)
+	if meta.Model != "DL200-840-W" || meta.CatalogNumber != "Downlight 200" || meta.Manufacturer != "Acme" {
+		t.Errorf("applied = %q / %q / %q", meta.Manufacturer, meta.Model, meta.CatalogNumber)
+	}
+
+	for _, bad := range []ImportProfile{
+		{Fields: map[string]ImportField{"model": {Source: "{model}"}}},
+		{Manufacturer: "Acme", Fields: map[string]ImportField{"test_lab": {Source: "{model}"}}},
+		{Manufacturer: "Acme", Fields: map[string]ImportField{"model": {Source: "{nope}"}}},
+		{Manufacturer: "Acme", Fields: map[string]ImportField{"model": {Source: "{model}", Pattern: "("}}},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("%+v: no error", bad)
+		}
+	}
+}
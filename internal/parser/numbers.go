@@ -0,0 +1,114 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// NumberStyle is how a writer renders the values of angle and candela
+// blocks.
+type NumberStyle string
+
+const (
+	// NumberFixed writes a fixed number of decimals: the format's own
+	// (IES 1, EULUMDAT 1 for angles and 5 for intensities) unless
+	// NumberFormat.Decimals says otherwise.
+	NumberFixed NumberStyle = "fixed"
+	// NumberShortest writes the shortest decimal that reads back as the
+	// same value: 12.5, 100, 0.00125.
+	NumberShortest NumberStyle = "shortest"
+)
+
+// maxNumberDecimals and maxNumberWidth bound NumberFormat to what a line
+// based reader can still take.
+const (
+	maxNumberDecimals = 9
+	maxNumberWidth    = 32
+)
+
+// ParseNumberStyle accepts "fixed" or "shortest"; empty is NumberFixed.
+func ParseNumberStyle(s string) (NumberStyle, error) {
+	switch style := NumberStyle(strings.ToLower(strings.TrimSpace(s))); style {
+	case "":
+		return NumberFixed, nil
+	case NumberFixed, NumberShortest:
+		return style, nil
+	}
+	return "", fmt.Errorf("numbers must be fixed or shortest, not %q", s)
+}
+
+// NumberFormat controls the angle and candela blocks of IES and EULUMDAT
+// output, for consumers that expect a layout other than the default one.
+// The zero value writes what the writers always have. Header fields and
+// the integer i-table of CIE files are not affected.
+type NumberFormat struct {
+	Style NumberStyle `json:"style,omitempty"`
+	// Decimals is the number of decimals of NumberFixed; 0 keeps the
+	// format's own.
+	Decimals int `json:"decimals,omitempty"`
+	// TrimZeros drops the trailing zeros of fixed values, and the point
+	// of whole ones: 12.50 is written 12.5 and 100.0 is written 100.
+	TrimZeros bool `json:"trim_zeros,omitempty"`
+	// Width right-aligns every value in a column this many characters
+	// wide, for readers that split lines by position; 0 writes values
+	// at their own length.
+	Width int `json:"width,omitempty"`
+}
+
+// Validate checks the style and that decimals and width stay within
+// reason.
+func (f NumberFormat) Validate() error {
+	if _, err := ParseNumberStyle(string(f.Style)); err != nil {
+		return err
+	}
+	if f.Decimals < 0 || f.Decimals > maxNumberDecimals {
+		return fmt.Errorf("decimals must be between 0 and %d, not %d", maxNumberDecimals, f.Decimals)
+	}
+	if f.Width < 0 || f.Width > maxNumberWidth {
+		return fmt.Errorf("column width must be between 0 and %d, not %d", maxNumberWidth, f.Width)
+	}
+	return nil
+}
+
+// format renders v with decimals as the format's own precision.
+func (f NumberFormat) format(v float64, decimals int) string {
+	var s string
+	if f.Style == NumberShortest {
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	} else {
+		if f.Decimals > 0 {
+			decimals = f.Decimals
+		}
+		s = strconv.FormatFloat(v, 'f', decimals, 64)
+		if f.TrimZeros && strings.Contains(s, ".") {
+			s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+		}
+	}
+	if pad := f.Width - len(s); pad > 0 {
+		s = strings.Repeat(" ", pad) + s
+	}
+	return s
+}
+
+// summary describes f for WriteOptions.Summary, or "" for the defaults.
+func (f NumberFormat) summary() string {
+	if f == (NumberFormat{}) || f == (NumberFormat{Style: NumberFixed}) {
+		return ""
+	}
+	style := f.Style
+	if style == "" {
+		style = NumberFixed
+	}
+	s := string(style)
+	if f.Decimals > 0 {
+		s += fmt.Sprintf("/%d", f.Decimals)
+	}
+	if f.TrimZeros {
+		s += "/trim"
+	}
+	if f.Width > 0 {
+		s += fmt.Sprintf("/w%d", f.Width)
+	}
+	return s
+}
@@ -0,0 +1,238 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/transform"
+
+	"illuminate/internal/database"
+	"illuminate/internal/logger"
+)
+
+// Encoding is the character set of a written file.
+type Encoding string
+
+const (
+	EncodingUTF8        Encoding = "utf-8"
+	EncodingWindows1252 Encoding = "windows-1252"
+	EncodingLatin1      Encoding = "iso-8859-1"
+)
+
+// LineEnding is the line terminator of a written file.
+type LineEnding string
+
+const (
+	LineEndingLF   LineEnding = "lf"
+	LineEndingCRLF LineEnding = "crlf"
+)
+
+// WriteOptions controls the bytes every writer produces. Writers render UTF-8
+// text with LF line endings; the options are applied on the way out, so all
+// formats honour them the same way.
+type WriteOptions struct {
+	LineEnding LineEnding
+	Encoding   Encoding
+	// MaxLineLength caps the characters per line for formats that wrap
+	// (IES); 0 uses the format's own limit.
+	MaxLineLength int
+	// Mapping remaps metadata onto output fields; nil keeps the defaults.
+	Mapping *MappingProfile
+	// Keywords are extra IES keywords written after the mapped ones.
+	Keywords []Keyword
+	// UseCommaDecimal writes "0,5" instead of "0.5" where the format allows
+	// it (LDT). IES requires a decimal point and ignores it.
+	UseCommaDecimal bool
+	// Downgrade handles information the format cannot carry; empty means
+	// DowngradeWarn. It applies to WriteFile, Encode and Convert.
+	Downgrade Downgrade
+	// LampSet configures the lamp set of LDT output where the metadata
+	// does not say; the zero value is DefaultLampSet.
+	LampSet LampSet
+	// Provenance, when set, is recorded in the file; see Provenance.
+	Provenance *Provenance
+	// Anonymize writes the luminaire as Anonymize returns it, and leaves
+	// the source hash out of the provenance.
+	Anonymize bool
+	// Numbers lays out the angle and candela blocks; the zero value keeps
+	// each format's own.
+	Numbers NumberFormat
+}
+
+// Validate checks the options every writer depends on.
+func (o WriteOptions) Validate() error {
+	if _, err := ParseDowngrade(string(o.Downgrade)); err != nil {
+		return err
+	}
+	if err := o.LampSet.Validate(); err != nil {
+		return err
+	}
+	if err := o.Numbers.Validate(); err != nil {
+		return err
+	}
+	if o.Mapping != nil {
+		if err := o.Mapping.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, kw := range o.Keywords {
+		if err := kw.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func DefaultWriteOptions() WriteOptions {
+	return WriteOptions{LineEnding: LineEndingLF, Encoding: EncodingUTF8}
+}
+
+// ParseEncoding accepts the usual spellings of the supported encodings.
+func ParseEncoding(s string) (Encoding, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "utf-8", "utf8":
+		return EncodingUTF8, nil
+	case "windows-1252", "cp1252", "ansi":
+		return EncodingWindows1252, nil
+	case "iso-8859-1", "latin-1", "latin1":
+		return EncodingLatin1, nil
+	}
+	return "", fmt.Errorf("unsupported encoding: %s", s)
+}
+
+// ParseLineEnding accepts "lf" or "crlf".
+func ParseLineEnding(s string) (LineEnding, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "lf":
+		return LineEndingLF, nil
+	case "crlf":
+		return LineEndingCRLF, nil
+	}
+	return "", fmt.Errorf("unsupported line ending: %s", s)
+}
+
+// WriteFile writes lum to path with p using opts. What the format cannot
+// carry is handled as opts.Downgrade says and logged as warnings.
+func WriteFile(p Parser, lum *database.ParsedLuminaire, path string, opts WriteOptions) error {
+	lum, opts = anonymized(lum, opts)
+	lum, issues, err := downgrade(p, lum, opts.Downgrade)
+	if err != nil {
+		return err
+	}
+	for _, issue := range issues {
+		logger.Default.Warnf("%s: %s %s: %s", path, issue.Field, issue.Effect, issue.Detail)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create file: %w", err)
+	}
+	if err := p.Render(file, lum, opts); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	logger.Default.Debugf("wrote %s", path)
+	return nil
+}
+
+// Encode renders lum with p into memory.
+func Encode(p Parser, lum *database.ParsedLuminaire, opts WriteOptions) ([]byte, error) {
+	data, _, err := Convert(p, lum, opts)
+	return data, err
+}
+
+// Convert is Encode that also returns what the format dropped, approximated
+// or embedded. With DowngradeFail it returns a *DowngradeError instead of
+// losing metadata.
+func Convert(p Parser, lum *database.ParsedLuminaire, opts WriteOptions) ([]byte, []CompatibilityIssue, error) {
+	var buf bytes.Buffer
+	issues, err := ConvertTo(&buf, p, lum, opts)
+	if err != nil {
+		return nil, issues, err
+	}
+	return buf.Bytes(), issues, nil
+}
+
+// ConvertTo is Convert rendering into w, for callers that reuse their
+// buffers. Nothing is written when the downgrade fails.
+func ConvertTo(w io.Writer, p Parser, lum *database.ParsedLuminaire, opts WriteOptions) ([]CompatibilityIssue, error) {
+	lum, opts = anonymized(lum, opts)
+	lum, issues, err := downgrade(p, lum, opts.Downgrade)
+	if err != nil {
+		return issues, err
+	}
+	return issues, p.Render(w, lum, opts)
+}
+
+// outputWriter buffers writer output and converts line endings and encoding
+// as it is flushed. Close must be called to flush it.
+type outputWriter struct {
+	*bufio.Writer
+	encoder io.Closer
+}
+
+func newOutputWriter(w io.Writer, opts WriteOptions) (*outputWriter, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	var cm *charmap.Charmap
+	switch opts.Encoding {
+	case "", EncodingUTF8:
+	case EncodingWindows1252:
+		cm = charmap.Windows1252
+	case EncodingLatin1:
+		cm = charmap.ISO8859_1
+	default:
+		return nil, fmt.Errorf("unsupported encoding: %s", opts.Encoding)
+	}
+
+	switch opts.LineEnding {
+	case "", LineEndingLF:
+	case LineEndingCRLF:
+		w = crlfWriter{w}
+	default:
+		return nil, fmt.Errorf("unsupported line ending: %s", opts.LineEnding)
+	}
+
+	out := &outputWriter{}
+	if cm != nil {
+		// Characters the target encoding lacks are replaced rather than
+		// failing the whole export.
+		tw := transform.NewWriter(w, encoding.ReplaceUnsupported(cm.NewEncoder()))
+		w, out.encoder = tw, tw
+	}
+	out.Writer = bufio.NewWriter(w)
+	return out, nil
+}
+
+func (o *outputWriter) Close() error {
+	if err := o.Flush(); err != nil {
+		return err
+	}
+	if o.encoder != nil {
+		return o.encoder.Close()
+	}
+	return nil
+}
+
+// crlfWriter expands every LF to CRLF.
+type crlfWriter struct {
+	w io.Writer
+}
+
+func (c crlfWriter) Write(p []byte) (int, error) {
+	if _, err := c.w.Write(bytes.ReplaceAll(p, []byte("\n"), []byte("\r\n"))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,456 @@
+package parser
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"illuminate/internal/database"
+	"illuminate/internal/synth"
+)
+
+func TestWriteOptionsApplyToEveryWriter(t *testing.T) {
+	opts := synth.DefaultOptions()
+	opts.Manufacturer = "Lümen"
+	lum, err := synth.Generate(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lum.Metadata.LuminaireDesc = "Lümen downlight"
+
+	for _, ext := range GetSupportedExtensions() {
+		t.Run(ext, func(t *testing.T) {
+			p, _ := GetParser("x" + ext)
+
+			plain, err := Encode(p, lum, DefaultWriteOptions())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Contains(plain, []byte("\r")) || !bytes.Contains(plain, []byte("ü")) {
+				t.Fatal("default output should be UTF-8 with LF line endings")
+			}
+
+			for _, enc := range []Encoding{EncodingWindows1252, EncodingLatin1} {
+				out, err := Encode(p, lum, WriteOptions{LineEnding: LineEndingCRLF, Encoding: enc})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if n := bytes.Count(out, []byte("\n")); n != bytes.Count(out, []byte("\r\n")) || n != bytes.Count(plain, []byte("\n")) {
+					t.Errorf("%s: line endings not converted to CRLF", enc)
+				}
+				if bytes.Contains(out, []byte("ü")) || !bytes.Contains(out, []byte("L\xfcmen")) {
+					t.Errorf("%s: text not transcoded", enc)
+				}
+				if _, err := p.ParseReader(bytes.NewReader(out), "x"+ext); err != nil {
+					t.Errorf("%s: output does not parse back: %v", enc, err)
+				}
+			}
+		})
+	}
+
+	if _, err := Encode(NewIESParser(), lum, WriteOptions{Encoding: "ebcdic"}); err == nil {
+		t.Error("unknown encoding should be rejected")
+	}
+}
+
+func TestIESWriterWrapsLongLines(t *testing.T) {
+	opts := synth.DefaultOptions()
+	opts.VerticalStep, opts.HorizontalStep = 1, 1
+	lum, err := synth.Generate(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lum.Metadata.LuminaireDesc = strings.Repeat("recessed downlight with opal diffuser ", 12) + strings.Repeat("x", 300)
+
+	for _, limit := range []int{0, LegacyLineLength} {
+		out, err := Encode(NewIESParser(), lum, WriteOptions{MaxLineLength: limit})
+		if err != nil {
+			t.Fatal(err)
+		}
+		max := limit
+		if max == 0 {
+			max = IESMaxLineLength
+		}
+		for i, line := range strings.Split(string(out), "\n") {
+			if len(line) > max {
+				t.Fatalf("limit %d: line %d has %d characters", max, i+1, len(line))
+			}
+		}
+
+		back, err := NewIESParser().ParseReader(bytes.NewReader(out), "wrapped.ies")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(back.Metadata.LuminaireDesc, strings.TrimSpace(strings.Repeat("recessed downlight with opal diffuser ", 12))) {
+			t.Errorf("limit %d: description not restored: %q", max, back.Metadata.LuminaireDesc)
+		}
+		if len(back.CandelaMatrix) != len(lum.CandelaMatrix) || len(back.CandelaMatrix[0]) != len(lum.VerticalAngles) {
+			t.Errorf("limit %d: candela grid changed shape", max)
+		}
+	}
+}
+
+func TestLDTCommaDecimal(t *testing.T) {
+	lum, err := synth.Generate(synth.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lum.Metadata.Manufacturer = "Acme Inc."
+
+	out, err := Encode(NewLDTParser(), lum, WriteOptions{UseCommaDecimal: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(out), "\n")
+	if lines[0] != "Acme Inc." {
+		t.Errorf("text field changed: %q", lines[0])
+	}
+	// Everything after the free-text header fields (lines 1-12) is numeric.
+	for i, line := range lines[12:] {
+		if strings.Contains(line, ".") {
+			t.Fatalf("line %d still has a decimal point: %q", i+13, line)
+		}
+	}
+
+	back, err := NewLDTParser().ParseReader(bytes.NewReader(out), "comma.ldt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, _ := NewLDTParser().ParseReader(bytes.NewReader(mustEncode(t, NewLDTParser(), lum, WriteOptions{})), "point.ldt")
+	if back.CandelaMatrix[0][0] != plain.CandelaMatrix[0][0] || back.Metadata.LuminousFlux != plain.Metadata.LuminousFlux {
+		t.Error("comma and point output parse differently")
+	}
+
+	for locale, want := range map[string]bool{"de-DE": true, "fr": true, "de-CH": false, "en-GB": false, "nonsense!": false} {
+		if got := CommaDecimalForLocale(locale); got != want {
+			t.Errorf("CommaDecimalForLocale(%q) = %v, want %v", locale, got, want)
+		}
+	}
+}
+
+func TestLDTLampSet(t *testing.T) {
+	lum, err := synth.Generate(synth.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lum.Metadata.LampType, lum.Metadata.ColorTemp, lum.Metadata.CRI = "", 0, 0
+	ldt := NewLDTParser()
+
+	// The lamp set starts after the 26 header lines and the set count.
+	lampSet := func(opts WriteOptions) []string {
+		return strings.Split(string(mustEncode(t, ldt, lum, opts)), "\n")[26:31]
+	}
+	if got := lampSet(WriteOptions{}); strings.Join(got, "|") != "1|LED|1000.0|3000K|80" {
+		t.Errorf("default lamp set = %q", got)
+	}
+	opts := WriteOptions{LampSet: LampSet{Count: 2, Type: "T5 HO", ColorTemp: "840", CRIGroup: "1B"}}
+	if got := lampSet(opts); strings.Join(got, "|") != "2|T5 HO|1000.0|840|1B" {
+		t.Errorf("configured lamp set = %q", got)
+	}
+
+	lum.Metadata.ColorTemp, lum.Metadata.CRI = 4000, 92
+	back, err := ldt.ParseReader(bytes.NewReader(mustEncode(t, ldt, lum, opts)), "lamps.ldt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if back.Metadata.ColorTemp != 4000 || back.Metadata.CRI != 92 || back.Metadata.LampType != "T5 HO" {
+		t.Errorf("metadata lamp set read back as %+v", back.Metadata)
+	}
+
+	if err := (WriteOptions{LampSet: LampSet{Type: "a\nb"}}).Validate(); err == nil {
+		t.Error("multi-line lamp type accepted")
+	}
+}
+
+func TestLuminousOpeningRoundTrip(t *testing.T) {
+	lum, err := synth.Generate(synth.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, opening := range [][2]float64{{1.2, 0.3}, {0.15, 0}} {
+		lum.Metadata.LuminousLength, lum.Metadata.LuminousWidth = opening[0], opening[1]
+		for _, p := range []Parser{NewIESParser(), NewLDTParser()} {
+			back, err := p.ParseReader(bytes.NewReader(mustEncode(t, p, lum, WriteOptions{})), "opening")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := [2]float64{back.Metadata.LuminousLength, back.Metadata.LuminousWidth}; got != opening {
+				t.Errorf("%T: opening %v came back as %v", p, opening, got)
+			}
+		}
+	}
+}
+
+func TestLuminousHeights(t *testing.T) {
+	lum, err := synth.Generate(synth.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &lum.Metadata
+	m.LuminousLength, m.LuminousWidth = 0.3, 0.2
+	m.LuminousHeightC0, m.LuminousHeightC90, m.LuminousHeightC180, m.LuminousHeightC270 = 0.02, 0.05, 0.02, 0.05
+
+	back, err := NewLDTParser().ParseReader(bytes.NewReader(mustEncode(t, NewLDTParser(), lum, WriteOptions{})), "heights.ldt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := back.Metadata
+	if got := [4]float64{b.LuminousHeightC0, b.LuminousHeightC90, b.LuminousHeightC180, b.LuminousHeightC270}; got != [4]float64{0.02, 0.05, 0.02, 0.05} {
+		t.Errorf("EULUMDAT heights came back as %v", got)
+	}
+	if _, ok := back.Extensions["ldt:luminous_height_c0"]; ok {
+		t.Error("luminous heights kept as extensions")
+	}
+
+	// IES has one height: the tallest side, reported for the others.
+	m.UnitsType = database.UnitsImperial
+	back, err = NewIESParser().ParseReader(bytes.NewReader(mustEncode(t, NewIESParser(), lum, WriteOptions{})), "heights.ies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := back.Metadata.LuminousHeightC0; math.Abs(h-0.05) > 1e-4 || back.Metadata.LuminousHeightC270 != h {
+		t.Errorf("IES heights came back as %+v", back.Metadata)
+	}
+	var approximated []string
+	for _, issue := range NewIESParser().Compatibility(lum) {
+		if strings.HasPrefix(issue.Field, "luminous_height") {
+			approximated = append(approximated, issue.Field)
+		}
+	}
+	if sort.Strings(approximated); !reflect.DeepEqual(approximated, []string{"luminous_height_c0", "luminous_height_c180"}) {
+		t.Errorf("approximated heights %v", approximated)
+	}
+
+	for _, c := range []struct {
+		length, width float64
+		shape         string
+	}{{0, 0, database.ShapePoint}, {0.2, 0, database.ShapeCircular}, {0.3, 0.2, database.ShapeRectangular}} {
+		m.LuminousLength, m.LuminousWidth = c.length, c.width
+		if got := m.LuminousShape(); got != c.shape {
+			t.Errorf("%g x %g: shape %s, want %s", c.length, c.width, got, c.shape)
+		}
+	}
+}
+
+func TestPowerQualityKeywords(t *testing.T) {
+	lum, err := synth.Generate(synth.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lum.Metadata.THD, lum.Metadata.InrushCurrent, lum.Metadata.Frequency = 8.5, 32, 50
+
+	data := mustEncode(t, NewIESParser(), lum, WriteOptions{})
+	if !strings.Contains(string(data), "[_THD] 8.5") || !strings.Contains(string(data), "[_FREQUENCY] 50") {
+		t.Errorf("power quality keywords missing:\n%s", data)
+	}
+	back, err := NewIESParser().ParseReader(bytes.NewReader(data), "power.ies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := back.Metadata; b.THD != 8.5 || b.InrushCurrent != 32 || b.Frequency != 50 {
+		t.Errorf("power quality came back as %v %v %v", b.THD, b.InrushCurrent, b.Frequency)
+	}
+
+	// Vendors write the unit after the value.
+	in := strings.Replace(string(data), "[_INRUSH] 32", "[_INRUSH] 32 A", 1)
+	back, err = NewIESParser().ParseReader(strings.NewReader(in), "power.ies")
+	if err != nil || back.Metadata.InrushCurrent != 32 || back.Extensions["ies:_INRUSH"] != "" {
+		t.Errorf("inrush with unit: %+v, %v", back.Metadata, err)
+	}
+}
+
+func TestIESExtensions(t *testing.T) {
+	src := "IESNA:LM-63-2002\n[MANUFAC] Acme\n[NEARFIELD] 1 0.5 0.5\n" +
+		"TILT=INCLUDE\n1\n3\n0 45 90\n1 0.985 0.95\n" +
+		"2 500 1 2 1 1 2 0 0 0.1\n0.95 1 20\n0 90\n0\n100 50\n"
+	lum, err := NewIESParser().ParseReader(strings.NewReader(src), "tilt.ies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := database.Extensions{
+		"ies:NEARFIELD":       "1 0.5 0.5",
+		"ies:tilt":            "1 3 0 45 90 1 0.985 0.95",
+		"ies:lamp_count":      "2",
+		"ies:lumens_per_lamp": "500",
+		"ies:ballast_factor":  "0.95",
+	}
+	if !reflect.DeepEqual(lum.Extensions, want) {
+		t.Fatalf("extensions = %v, want %v", lum.Extensions, want)
+	}
+	if h := lum.Metadata.LuminousHeight(); h != 0.1 {
+		t.Errorf("luminous height %g, want 0.1", h)
+	}
+
+	out := string(mustEncode(t, NewIESParser(), lum, WriteOptions{}))
+	for _, line := range []string{"[NEARFIELD] 1 0.5 0.5\n", "TILT=INCLUDE\n1\n3\n0 45 90\n1 0.985 0.95\n", "2 500 1 2 1 1 2 0 0 0.1\n", "0.95 1 20.00\n"} {
+		if !strings.Contains(out, line) {
+			t.Errorf("IES output lacks %q:\n%s", line, out)
+		}
+	}
+	if ldt := string(mustEncode(t, NewLDTParser(), lum, WriteOptions{})); strings.Contains(ldt, "NEARFIELD") {
+		t.Error("IES extensions leaked into EULUMDAT")
+	}
+
+	// Rated lumens that no longer add up to the flux are not restored.
+	lum.Metadata.LuminousFlux = 1500
+	if out := string(mustEncode(t, NewIESParser(), lum, WriteOptions{})); !strings.Contains(out, "\n2 -1 1 2 1 1 2 0 0 0.1\n") {
+		t.Errorf("stale rated lumens written:\n%s", out)
+	}
+}
+
+func mustEncode(t *testing.T, p Parser, lum *database.ParsedLuminaire, opts WriteOptions) []byte {
+	t.Helper()
+	out, err := Encode(p, lum, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestProvenance(t *testing.T) {
+	lum, err := synth.Generate(synth.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lum.Metadata.LuminaireDesc = "Downlight"
+	hash := "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	prov := &Provenance{SourceHash: hash, Converted: time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)}
+	opts := WriteOptions{LineEnding: LineEndingCRLF, Keywords: []Keyword{{"_ID", "7"}}, Provenance: prov}
+
+	for ext, want := range map[string]string{
+		".ies": "[_PROVENANCE] converter=illuminate dev; converted=2026-03-01T12:30:00Z; source=" + hash[:58],
+		".ldt": "\nilluminate dev 2026-03-01T12:30Z src:9f86d081884c opt:",
+		".cie": "Downlight [provenance=illuminate dev 2026-03-01T12:30Z src:9f86d081884c opt:",
+	} {
+		p, _ := GetParser("x" + ext)
+		out := strings.ReplaceAll(string(mustEncode(t, p, lum, opts)), "\r\n", "\n")
+		if !strings.Contains(out, want) {
+			t.Errorf("%s output lacks %q:\n%s", ext, want, out[:min(len(out), 600)])
+		}
+		if ext == ".ies" && !strings.Contains(out, "options=eol:crlf,keywords:1\n") {
+			t.Errorf("ies provenance lacks the options:\n%s", out[:600])
+		}
+
+		back, err := p.ParseReader(strings.NewReader(out), "x"+ext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if back.Metadata.LuminaireDesc != "Downlight" {
+			t.Errorf("%s: description read back as %q", ext, back.Metadata.LuminaireDesc)
+		}
+	}
+
+	if got := (WriteOptions{}).Summary(); got != "default" {
+		t.Errorf("summary of the zero options = %q", got)
+	}
+}
+
+// TestDeterministicOutput converts the same luminaire twice with each
+// writer, with keywords, extensions and uneven luminous heights to order,
+// and checks that the bytes and issues match and carry no time.
+func TestDeterministicOutput(t *testing.T) {
+	lum, err := synth.Generate(synth.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lum.Metadata.LuminousHeightC0, lum.Metadata.LuminousHeightC90 = 0.1, 0.2
+	lum.Extensions = database.Extensions{"ies:NEARFIELD": "1 0.5 0.5", "ies:_SERIAL": "7", "ies:_BATCH": "B2", "ldt:direct_ratios": "0.1 0.2 0.3 0.4 0.5 0.6 0.7 0.8 0.9 1"}
+	opts := WriteOptions{
+		Keywords:   []Keyword{{"_ID", "7"}, {"_ORDER", "12"}},
+		Provenance: Reproducible("9f86d081884c7d659a2f", time.Time{}),
+	}
+
+	for _, ext := range []string{".ies", ".ldt", ".cie"} {
+		p, _ := GetParser("x" + ext)
+		first, firstIssues, err := Convert(p, lum, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for range 20 {
+			again, issues, err := Convert(p, lum, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(again, first) {
+				t.Fatalf("%s output differs between runs", ext)
+			}
+			if !reflect.DeepEqual(issues, firstIssues) {
+				t.Fatalf("%s issues differ between runs: %v, then %v", ext, firstIssues, issues)
+			}
+		}
+		if strings.Contains(string(first), time.Now().UTC().Format("2006-01-02")) {
+			t.Errorf("%s output carries today's date:\n%s", ext, first)
+		}
+	}
+
+	pinned := Reproducible("", time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC))
+	out := string(mustEncode(t, NewIESParser(), lum, WriteOptions{Provenance: pinned}))
+	if !strings.Contains(out, "converted=2026-03-01T12:30:00Z") {
+		t.Errorf("pinned time not recorded:\n%s", out)
+	}
+}
+
+func TestNumberFormat(t *testing.T) {
+	for _, tc := range []struct {
+		format NumberFormat
+		v      float64
+		want   string
+	}{
+		{NumberFormat{}, 12.5, "12.5"},
+		{NumberFormat{Decimals: 3}, 12.5, "12.500"},
+		{NumberFormat{Decimals: 3, TrimZeros: true}, 12.5, "12.5"},
+		{NumberFormat{TrimZeros: true}, 100, "100"},
+		{NumberFormat{Style: NumberShortest}, 0.00125, "0.00125"},
+		{NumberFormat{Style: NumberShortest}, 100, "100"},
+		{NumberFormat{Width: 8}, 12.5, "    12.5"},
+		{NumberFormat{Width: 2}, 12.5, "12.5"},
+	} {
+		if got := tc.format.format(tc.v, 1); got != tc.want {
+			t.Errorf("%+v: %g written %q, want %q", tc.format, tc.v, got, tc.want)
+		}
+	}
+	if err := (NumberFormat{Style: "wide"}).Validate(); err == nil {
+		t.Error("unknown style accepted")
+	}
+
+	lum, err := synth.Generate(synth.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := WriteOptions{Numbers: NumberFormat{Decimals: 2, Width: 9}}
+	out := string(mustEncode(t, NewIESParser(), lum, opts))
+	if !strings.Contains(out, "\n     0.00      5.00     10.00") {
+		t.Errorf("IES angles not in 9-wide columns:\n%s", out[:min(len(out), 800)])
+	}
+	opts.UseCommaDecimal = true
+	ldt := string(mustEncode(t, NewLDTParser(), lum, opts))
+	if !strings.Contains(ldt, "\n     5,00\n") {
+		t.Errorf("LDT angles not padded with a decimal comma:\n%s", ldt[:min(len(ldt), 800)])
+	}
+
+	for ext, out := range map[string]string{".ies": out, ".ldt": ldt} {
+		back, err := mustParser(t, ext).ParseReader(strings.NewReader(out), "x"+ext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(back.CandelaMatrix) == 0 || math.Abs(back.CandelaMatrix[0][0]-lum.CandelaMatrix[0][0]) > 0.01*lum.CandelaMatrix[0][0] {
+			t.Errorf("%s: padded values read back wrong", ext)
+		}
+	}
+	if got := opts.Summary(); !strings.Contains(got, "numbers:fixed/2/w9") {
+		t.Errorf("summary %q lacks the number format", got)
+	}
+}
+
+func mustParser(t *testing.T, ext string) Parser {
+	t.Helper()
+	p, err := GetParser("x" + ext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
@@ -0,0 +1,288 @@
+package parser
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"illuminate/internal/database"
+	"illuminate/internal/logger"
+)
+
+// maxOXLSize caps an OXL document, zipped or not.
+const maxOXLSize = 64 << 20
+
+// OXLParser reads the XML container LITESTAR 4D exports luminaires in. It is
+// import-only, so it implements Reader but not Parser.
+//
+// Element names differ between LITESTAR versions, so lookups are
+// case-insensitive and try the known aliases: product metadata comes from
+// <Product> (or <Luminaire>), lamp data from <Lamp> and the intensities from
+// <Photometry>. Intensities in cd/klm are scaled to the stated flux like
+// EULUMDAT's.
+type OXLParser struct{}
+
+func NewOXLParser() *OXLParser {
+	return &OXLParser{}
+}
+
+func (p *OXLParser) Parse(filepath string) (*database.ParsedLuminaire, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("open file: %w", err)
+	}
+	defer file.Close()
+
+	return p.ParseReader(file, filepath)
+}
+
+func (p *OXLParser) ParseReader(r io.Reader, name string) (*database.ParsedLuminaire, error) {
+	logger.Default.Debugf("parsing OXL file: %s", name)
+
+	data, err := io.ReadAll(io.LimitReader(r, maxOXLSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("read file: %w", err)
+	}
+	if len(data) > maxOXLSize {
+		return nil, fmt.Errorf("invalid OXL file: %w", &LimitError{Limit: "file size", Value: float64(len(data)), Max: maxOXLSize})
+	}
+	fileHash := fmt.Sprintf("%x", sha256.Sum256(data))
+
+	// Some LITESTAR versions ship the document zipped.
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		if data, err = unzipOXL(data); err != nil {
+			return nil, fmt.Errorf("invalid OXL file: %w", err)
+		}
+	}
+
+	var root oxlNode
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("invalid OXL file: %w", err)
+	}
+
+	product := root.find("Product", "Luminaire")
+	if product == nil {
+		product = &root
+	}
+	lamp := root.find("Lamp", "LampData")
+	photometry := root.find("Photometry", "PhotometricData")
+	if photometry == nil {
+		return nil, fmt.Errorf("invalid OXL file: no photometry element")
+	}
+
+	metadata := database.Luminaire{
+		OriginalFilename: name,
+		FormatType:       "OXL",
+		FileHash:         fileHash,
+		Manufacturer:     product.value("Manufacturer", "Brand"),
+		Model:            product.value("Name", "Model", "ProductName"),
+		CatalogNumber:    product.value("Code", "CatalogNumber", "ProductCode", "Article"),
+		LuminaireDesc:    product.value("Description"),
+		TestLab:          root.find("Measurement").value("Laboratory", "TestLab"),
+		TestNumber:       root.find("Measurement").value("Code", "Number", "TestNumber", "ReportNumber"),
+		TestDate:         root.find("Measurement").value("Date", "TestDate"),
+		FormatVersion:    VersionOXL,
+		FormatConfidence: ConfidenceInferred,
+		PhotometricType:  database.PhotometricTypeC,
+		UnitsType:        database.UnitsMetric,
+		ConversionFactor: 1,
+	}
+	metadata.InputWatts = product.number("Power", "InputPower", "Watts")
+	if lamp != nil {
+		metadata.LampType = lamp.value("Type", "Name", "LampType")
+		metadata.LampCatalog = lamp.value("Code", "CatalogNumber")
+		metadata.LuminousFlux = lamp.number("LuminousFlux", "Flux")
+		metadata.ColorTemp = int(lamp.number("ColorTemperature", "CCT"))
+		metadata.CRI = int(lamp.number("CRI", "ColorRendering", "Ra"))
+		if metadata.InputWatts == 0 {
+			metadata.InputWatts = lamp.number("Power", "Watts")
+		}
+	}
+	if flux := product.number("LuminousFlux", "Flux"); flux > 0 {
+		metadata.LuminousFlux = flux
+	}
+	switch strings.ToUpper(photometry.value("Type", "PhotometricType")) {
+	case "A":
+		metadata.PhotometricType = database.PhotometricTypeA
+	case "B":
+		metadata.PhotometricType = database.PhotometricTypeB
+	}
+
+	limits := CurrentLimits()
+	horizontalAngles, err := oxlAngles(photometry.value("CPlanes", "CAngles", "HorizontalAngles"), "C-plane", limits)
+	if err != nil {
+		return nil, err
+	}
+	verticalAngles, err := oxlAngles(photometry.value("Gammas", "GammaAngles", "VerticalAngles"), "gamma", limits)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCandelaValues(len(horizontalAngles), len(verticalAngles), limits.MaxCandelaValues); err != nil {
+		return nil, fmt.Errorf("invalid OXL file: %w", err)
+	}
+
+	intensities := photometry.find("Intensities", "Candela", "Intensity")
+	if intensities == nil {
+		return nil, fmt.Errorf("invalid OXL file: no intensities")
+	}
+	scale := 1.0
+	if unit := strings.ToLower(intensities.attr("unit")); strings.Contains(unit, "klm") && metadata.LuminousFlux > 0 {
+		scale = metadata.LuminousFlux / 1000
+	}
+
+	// Values come either as one <Plane> per C-plane or as a single list in
+	// C-plane order.
+	var values []float64
+	if planes := intensities.children("Plane", "Row"); len(planes) > 0 {
+		for _, plane := range planes {
+			values = append(values, parseFloatLine(oxlList(plane.Text))...)
+		}
+	} else {
+		values = parseFloatLine(oxlList(intensities.Text))
+	}
+	if need := len(horizontalAngles) * len(verticalAngles); len(values) != need {
+		return nil, fmt.Errorf("invalid OXL file: expected %d intensities, found %d", need, len(values))
+	}
+
+	candelaMatrix := make([][]float64, len(horizontalAngles))
+	for i := range candelaMatrix {
+		row := values[i*len(verticalAngles) : (i+1)*len(verticalAngles)]
+		for j := range row {
+			row[j] *= scale
+		}
+		candelaMatrix[i] = row
+	}
+
+	logger.Default.Debugf("OXL parse complete: file_hash=%s, vertical_angles=%d, horizontal_angles=%d",
+		fileHash, len(verticalAngles), len(horizontalAngles))
+
+	return &database.ParsedLuminaire{
+		Metadata:         metadata,
+		VerticalAngles:   verticalAngles,
+		HorizontalAngles: horizontalAngles,
+		CandelaMatrix:    candelaMatrix,
+	}, nil
+}
+
+// unzipOXL returns the first XML document in a zipped OXL file.
+func unzipOXL(data []byte) ([]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range zr.File {
+		ext := strings.ToLower(f.Name[strings.LastIndex(f.Name, ".")+1:])
+		if ext != "xml" && ext != "oxl" {
+			continue
+		}
+		if f.UncompressedSize64 > maxOXLSize {
+			return nil, &LimitError{Limit: "document size", Value: float64(f.UncompressedSize64), Max: maxOXLSize}
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(io.LimitReader(rc, maxOXLSize))
+	}
+	return nil, fmt.Errorf("archive holds no XML document")
+}
+
+func oxlAngles(list, what string, limits Limits) ([]float64, error) {
+	angles := parseFloatLine(oxlList(list))
+	if len(angles) == 0 {
+		return nil, fmt.Errorf("invalid OXL file: no %s angles", what)
+	}
+	if max := limits.MaxAngles; max > 0 && len(angles) > max {
+		return nil, fmt.Errorf("invalid OXL file: %w", &LimitError{Limit: what + " angle count", Value: float64(len(angles)), Max: max})
+	}
+	return angles, nil
+}
+
+// oxlList turns the separators LITESTAR uses in number lists into spaces.
+func oxlList(s string) string {
+	return strings.NewReplacer(";", " ", ",", " ").Replace(s)
+}
+
+// oxlNode is a generic XML element, so that lookups can ignore case and
+// namespaces.
+type oxlNode struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Text    string     `xml:",chardata"`
+	Nodes   []oxlNode  `xml:",any"`
+}
+
+func (n *oxlNode) is(names ...string) bool {
+	for _, name := range names {
+		if strings.EqualFold(n.XMLName.Local, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// find returns the first descendant with one of the names, depth first.
+func (n *oxlNode) find(names ...string) *oxlNode {
+	if n == nil {
+		return nil
+	}
+	for i := range n.Nodes {
+		if n.Nodes[i].is(names...) {
+			return &n.Nodes[i]
+		}
+		if found := n.Nodes[i].find(names...); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func (n *oxlNode) children(names ...string) []oxlNode {
+	var out []oxlNode
+	for _, child := range n.Nodes {
+		if child.is(names...) {
+			out = append(out, child)
+		}
+	}
+	return out
+}
+
+func (n *oxlNode) attr(name string) string {
+	for _, a := range n.Attrs {
+		if strings.EqualFold(a.Name.Local, name) {
+			return strings.TrimSpace(a.Value)
+		}
+	}
+	return ""
+}
+
+// value returns the first of the names found as a child element or an
+// attribute of n.
+func (n *oxlNode) value(names ...string) string {
+	if n == nil {
+		return ""
+	}
+	for _, name := range names {
+		for _, child := range n.Nodes {
+			if child.is(name) {
+				return strings.Join(strings.Fields(child.Text), " ")
+			}
+		}
+		if v := n.attr(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func (n *oxlNode) number(names ...string) float64 {
+	v, _ := strconv.ParseFloat(strings.Replace(n.value(names...), ",", ".", 1), 64)
+	return v
+}
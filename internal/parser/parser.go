@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"io"
 	"path/filepath"
 	"strings"
 
@@ -10,7 +11,18 @@ import (
 
 type Parser interface {
 	Parse(filepath string) (*database.ParsedLuminaire, error)
+	ParseReader(r io.Reader, name string) (*database.ParsedLuminaire, error)
 	Write(lum *database.ParsedLuminaire, filepath string) error
+	Render(w io.Writer, lum *database.ParsedLuminaire, opts WriteOptions) error
+	// Compatibility lists what Render would drop or approximate when
+	// writing lum with the default field mapping.
+	Compatibility(lum *database.ParsedLuminaire) []CompatibilityIssue
+}
+
+// Reader is the read half of Parser, all an import-only format provides.
+type Reader interface {
+	Parse(filepath string) (*database.ParsedLuminaire, error)
+	ParseReader(r io.Reader, name string) (*database.ParsedLuminaire, error)
 }
 
 func GetParser(filename string) (Parser, error) {
@@ -28,6 +40,18 @@ func GetParser(filename string) (Parser, error) {
 	}
 }
 
+// GetReader is GetParser extended with the import-only formats.
+func GetReader(filename string) (Reader, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".oxl":
+		return NewOXLParser(), nil
+	case ".cib", ".tm14":
+		return NewTM14Parser(), nil
+	}
+	return GetParser(filename)
+}
+
+// GetSupportedExtensions lists the formats that can be read and written.
 func GetSupportedExtensions() []string {
 	return []string{".ies", ".cie", ".ldt"}
 }
@@ -41,6 +65,10 @@ func DetectFormat(filename string) string {
 		return "CIE (CIE 102)"
 	case ".ldt":
 		return "LDT (Eulumdat)"
+	case ".oxl":
+		return "OXL (LITESTAR)"
+	case ".cib", ".tm14":
+		return "TM14 (CIBSE)"
 	default:
 		return "Unknown"
 	}
@@ -0,0 +1,132 @@
+package parser
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Version is the converter version recorded in provenance. Release builds
+// set it with -ldflags "-X illuminate/internal/parser.Version=v1.2.3".
+var Version = "dev"
+
+// Provenance identifies the conversion that produced a file, so a file found
+// in the wild can be traced back to its source and the options used. Writers
+// record it where the format has room: the IES [_PROVENANCE] keyword, the
+// EULUMDAT file name field and a "[provenance=...]" block on the CIE
+// description line.
+type Provenance struct {
+	// SourceHash is the hash of the file the luminaire was imported from.
+	SourceHash string
+	// Converted is when the conversion was made. The zero time leaves it
+	// out, so that converting the same luminaire with the same options
+	// gives the same bytes; see Reproducible.
+	Converted time.Time
+	// Derived, when set, says the distribution was computed rather than
+	// measured, and how: "interpolated at 75% from dim50 (50%) and dim100
+	// (100%)".
+	Derived string
+}
+
+// NewProvenance records a conversion of the file with sourceHash made now.
+func NewProvenance(sourceHash string) *Provenance {
+	return &Provenance{SourceHash: sourceHash, Converted: time.Now().UTC()}
+}
+
+// Reproducible records a conversion of the file with sourceHash without
+// the time it was made, so that the output depends on its input alone.
+// A non-zero at is recorded instead, for callers that want a timestamp
+// they control.
+func Reproducible(sourceHash string, at time.Time) *Provenance {
+	return &Provenance{SourceHash: sourceHash, Converted: at.UTC()}
+}
+
+// provenanceKey is the embedded block key the CIE writer uses and readers
+// drop.
+const provenanceKey = "provenance"
+
+// text is the full record: source, time, converter and options.
+func (p *Provenance) text(opts WriteOptions) string {
+	parts := []string{"converter=illuminate " + Version}
+	if !p.Converted.IsZero() {
+		parts = append(parts, "converted="+p.Converted.UTC().Format(time.RFC3339))
+	}
+	if p.Derived != "" {
+		parts = append(parts, "derived="+p.Derived)
+	}
+	if p.SourceHash != "" {
+		parts = append(parts, "source="+p.SourceHash)
+	}
+	return strings.Join(append(parts, "options="+opts.Summary()), "; ")
+}
+
+// short fits fixed-width fields: the source hash is cut to 12 digits, the
+// options are replaced by a digest of their summary and a derived
+// distribution is only marked as such.
+func (p *Provenance) short(opts WriteOptions, limit int) string {
+	s := "illuminate " + Version
+	if !p.Converted.IsZero() {
+		s += " " + p.Converted.UTC().Format("2006-01-02T15:04Z")
+	}
+	if p.Derived != "" {
+		s += " DERIVED"
+	}
+	if p.SourceHash != "" {
+		s += " src:" + p.SourceHash[:min(12, len(p.SourceHash))]
+	}
+	sum := sha256.Sum256([]byte(opts.Summary()))
+	s += " opt:" + hex.EncodeToString(sum[:4])
+	if utf8.RuneCountInString(s) > limit {
+		s = runePrefix(s, limit)
+	}
+	return s
+}
+
+// Summary lists the options that differ from the defaults as
+// "key:value" pairs, or "default".
+func (o WriteOptions) Summary() string {
+	var parts []string
+	add := func(key, value string) {
+		if value != "" {
+			parts = append(parts, key+":"+value)
+		}
+	}
+	if o.LineEnding != LineEndingLF {
+		add("eol", string(o.LineEnding))
+	}
+	if o.Encoding != EncodingUTF8 {
+		add("encoding", string(o.Encoding))
+	}
+	if o.MaxLineLength > 0 {
+		add("line_length", fmt.Sprint(o.MaxLineLength))
+	}
+	if o.Mapping != nil {
+		data, _ := json.Marshal(o.Mapping)
+		sum := sha256.Sum256(data)
+		add("mapping", hex.EncodeToString(sum[:4]))
+	}
+	if len(o.Keywords) > 0 {
+		add("keywords", fmt.Sprint(len(o.Keywords)))
+	}
+	if o.UseCommaDecimal {
+		add("decimal", "comma")
+	}
+	if o.Downgrade != DowngradeWarn {
+		add("downgrade", string(o.Downgrade))
+	}
+	if o.Anonymize {
+		add("anonymize", "true")
+	}
+	add("numbers", o.Numbers.summary())
+	if o.LampSet != (LampSet{}) {
+		add("lamps", fmt.Sprintf("%d/%s/%s/%s", o.LampSet.Count, o.LampSet.Type, o.LampSet.ColorTemp, o.LampSet.CRIGroup))
+	}
+	if len(parts) == 0 {
+		return "default"
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,55 @@
+package parser
+
+import (
+	"errors"
+
+	"illuminate/internal/database"
+)
+
+// IncompleteError is returned by a reader that failed part way through a
+// file once its header had been read. Partial holds what was read by then:
+// the header metadata and, when the file is cut short, the angle lists and
+// the candela planes that are complete. It reads as the failure itself, so
+// callers that do not salvage see the same error as before.
+type IncompleteError struct {
+	Partial *database.ParsedLuminaire
+	// Planes is the number of candela planes the header declares;
+	// Partial.CandelaMatrix holds the first of them.
+	Planes int
+	Err    error
+}
+
+func (e *IncompleteError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *IncompleteError) Unwrap() error {
+	return e.Err
+}
+
+// Salvage returns what a failed parse recovered, or nil when it failed
+// before the header was read or went over a limit.
+func Salvage(err error) *IncompleteError {
+	var inc *IncompleteError
+	if !errors.As(err, &inc) || errors.Is(err, ErrLimitExceeded) {
+		return nil
+	}
+	return inc
+}
+
+// partialPlanes reads as many complete planes of n values as tokens still
+// hold, up to planes, scaled by multiplier.
+func partialPlanes(tokens *tokenReader, planes, n int, multiplier float64) [][]float64 {
+	var matrix [][]float64
+	for len(matrix) < planes && n > 0 {
+		row, err := tokens.floats(n)
+		if err != nil {
+			break
+		}
+		for j := range row {
+			row[j] *= multiplier
+		}
+		matrix = append(matrix, row)
+	}
+	return matrix
+}
@@ -0,0 +1,78 @@
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"illuminate/internal/synth"
+)
+
+// TestSalvageTruncated cuts an IES and an LDT file part way through the
+// candela values and checks that the header and the complete planes are
+// recovered.
+func TestSalvageTruncated(t *testing.T) {
+	lum, err := synth.Generate(synth.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lum.Metadata.Manufacturer = "Acme"
+	lum.Metadata.Model = "DL-100"
+
+	for name, p := range map[string]Parser{"ies": NewIESParser(), "ldt": NewLDTParser()} {
+		data, err := Encode(p, lum, WriteOptions{})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		lines := strings.SplitAfter(string(data), "\n")
+		cut := strings.Join(lines[:len(lines)*3/4], "")
+
+		_, err = p.ParseReader(strings.NewReader(cut), "cut."+name)
+		if err == nil {
+			t.Fatalf("%s: truncated file parsed", name)
+		}
+		inc := Salvage(err)
+		if inc == nil {
+			t.Fatalf("%s: nothing salvaged from %v", name, err)
+		}
+		partial := inc.Partial
+		if partial.Metadata.Manufacturer != "Acme" || partial.Metadata.FileHash == "" {
+			t.Errorf("%s: metadata = %+v", name, partial.Metadata)
+		}
+		if len(partial.VerticalAngles) != len(lum.VerticalAngles) {
+			t.Errorf("%s: %d vertical angles, want %d", name, len(partial.VerticalAngles), len(lum.VerticalAngles))
+		}
+		if n := len(partial.CandelaMatrix); n == 0 || n >= inc.Planes {
+			t.Errorf("%s: %d of %d planes read", name, n, inc.Planes)
+		}
+		for i, row := range partial.CandelaMatrix {
+			if len(row) != len(partial.VerticalAngles) {
+				t.Errorf("%s: plane %d has %d values", name, i, len(row))
+			}
+		}
+	}
+}
+
+// TestSalvageLimits checks that a file over a parser limit is not
+// salvaged.
+func TestSalvageLimits(t *testing.T) {
+	defer SetLimits(CurrentLimits())
+	SetLimits(Limits{MaxAngles: 2})
+
+	lum, err := synth.Generate(synth.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := Encode(NewIESParser(), lum, WriteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewIESParser().ParseReader(bytes.NewReader(data), "big.ies")
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("err = %v, want a limit error", err)
+	}
+	if Salvage(err) != nil {
+		t.Error("salvaged a file over the limits")
+	}
+}
@@ -0,0 +1,113 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FormatGuess is a format a file's content fits, with the confidence
+// scale of Luminaire.FormatConfidence: ConfidenceDeclared when the file
+// names the format, ConfidenceInferred when its layout is distinctive and
+// ConfidenceGuessed when it merely parses as the format, as bare numbers do.
+type FormatGuess struct {
+	Format     string  `json:"format"`
+	Confidence float64 `json:"confidence"`
+}
+
+// SniffFormat ranks the formats the start of a file could be, best first;
+// nil when it fits none.
+func SniffFormat(head []byte) []FormatGuess {
+	head = bytes.TrimPrefix(head, []byte("\xef\xbb\xbf"))
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(head))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimSpace(scanner.Text()))
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+
+	scores := map[string]float64{}
+	guess := func(format string, confidence float64) {
+		scores[format] = max(scores[format], confidence)
+	}
+	first := strings.ToUpper(lines[0])
+
+	switch {
+	case strings.HasPrefix(first, "IESNA"):
+		guess("ies", ConfidenceDeclared)
+	case strings.HasPrefix(first, "<"):
+		if bytes.Contains(head, []byte("<OXL")) {
+			guess("oxl", ConfidenceDeclared)
+		} else {
+			guess("oxl", ConfidenceGuessed)
+		}
+	case strings.Contains(first, "TM14") || strings.Contains(first, "CIBSE"):
+		guess("tm14", ConfidenceDeclared)
+	case strings.Contains(first, "EULUMDAT"):
+		guess("ldt", ConfidenceDeclared)
+	}
+
+	firstData := -1
+	for i, line := range lines {
+		if strings.HasPrefix(strings.ToUpper(line), "TILT=") {
+			guess("ies", ConfidenceInferred)
+		}
+		if firstData < 0 && isNumericLine(line) {
+			firstData = i
+		}
+	}
+
+	// EULUMDAT: a name line, then the type (0-3), symmetry (0-4) and plane
+	// counts one integer per line, over at least 42 header lines.
+	if len(lines) >= 42 {
+		ityp, err1 := strconv.Atoi(lines[1])
+		isym, err2 := strconv.Atoi(lines[2])
+		_, err3 := strconv.Atoi(lines[3])
+		if err1 == nil && err2 == nil && err3 == nil && ityp >= 0 && ityp <= 3 && isym >= 0 && isym <= 4 {
+			guess("ldt", ConfidenceInferred)
+		}
+	}
+
+	if firstData >= 0 {
+		data := lines[firstData]
+		// CIE i-table: symmetry, type and a flag before the name, then rows
+		// of intensities, ten or more to a row.
+		if firstData == 1 && cieHeaderRegex.MatchString(lines[0]) {
+			if len(strings.Fields(data)) >= 10 {
+				guess("cie", ConfidenceInferred)
+			} else {
+				guess("cie", ConfidenceGuessed)
+			}
+		}
+		// TM14: text lines, then plane count, gamma count, flux and watts.
+		if n := len(strings.Fields(data)); n == 4 {
+			confidence := ConfidenceGuessed
+			for _, line := range lines[:firstData] {
+				if m := tm14Label.FindStringSubmatch(line); m != nil && tm14Labels[strings.ToLower(strings.TrimSuffix(m[1], "."))] != nil {
+					confidence = ConfidenceInferred
+				}
+			}
+			guess("tm14", confidence)
+		}
+	}
+
+	guesses := make([]FormatGuess, 0, len(scores))
+	for format, confidence := range scores {
+		guesses = append(guesses, FormatGuess{Format: format, Confidence: confidence})
+	}
+	sort.Slice(guesses, func(i, j int) bool {
+		if guesses[i].Confidence != guesses[j].Confidence {
+			return guesses[i].Confidence > guesses[j].Confidence
+		}
+		return guesses[i].Format < guesses[j].Format
+	})
+	if len(guesses) == 0 {
+		return nil
+	}
+	return guesses
+}
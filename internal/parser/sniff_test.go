@@ -0,0 +1,40 @@
+package parser
+
+import (
+	"testing"
+
+	"illuminate/internal/synth"
+)
+
+// TestSniffFormat checks that each writer's output ranks its own format
+// first, and that bare numbers are no more than a guess.
+func TestSniffFormat(t *testing.T) {
+	lum, err := synth.Generate(synth.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for format, p := range map[string]Parser{
+		"ies": NewIESParser(),
+		"ldt": NewLDTParser(),
+		"cie": NewCIEParser(),
+	} {
+		data, err := Encode(p, lum, WriteOptions{})
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		guesses := SniffFormat(data)
+		if len(guesses) == 0 || guesses[0].Format != format {
+			t.Errorf("%s: guesses = %+v", format, guesses)
+		}
+	}
+
+	guesses := SniffFormat([]byte("1 2 3 4\n5 6 7 8\n"))
+	for _, g := range guesses {
+		if g.Confidence > ConfidenceGuessed {
+			t.Errorf("bare numbers: %s at %v", g.Format, g.Confidence)
+		}
+	}
+	if got := SniffFormat(nil); got != nil {
+		t.Errorf("empty: guesses = %+v", got)
+	}
+}
@@ -0,0 +1,28 @@
+package parser
+
+import (
+	"illuminate/internal/database"
+)
+
+// IsRotational reports whether lum stores a single C-plane that stands for
+// every plane around the luminaire.
+func IsRotational(lum *database.ParsedLuminaire) bool {
+	return len(lum.CandelaMatrix) == 1 && len(lum.HorizontalAngles) <= 1
+}
+
+// ExpandPlanes repeats the single plane of a rotationally symmetric luminaire
+// on every step degrees from 0 up to, but not including, 360, for formats
+// that cannot express the symmetry. Any other luminaire is returned as is.
+func ExpandPlanes(lum *database.ParsedLuminaire, step float64) *database.ParsedLuminaire {
+	if !IsRotational(lum) || step <= 0 {
+		return lum
+	}
+	out := *lum
+	out.HorizontalAngles = nil
+	out.CandelaMatrix = nil
+	for c := 0.0; c < 360; c += step {
+		out.HorizontalAngles = append(out.HorizontalAngles, c)
+		out.CandelaMatrix = append(out.CandelaMatrix, append([]float64(nil), lum.CandelaMatrix[0]...))
+	}
+	return &out
+}
@@ -0,0 +1,181 @@
+package parser
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"illuminate/internal/database"
+	"illuminate/internal/logger"
+)
+
+// TM14Parser reads CIBSE TM14 files (.cib, .tm14), the legacy UK format. It
+// is import-only, like OXL.
+//
+// A TM14 file opens with text lines, either free text or "Label: value"
+// pairs, up to the first line of numbers. The data block is then:
+//
+//	<C-plane count> <gamma count> <lamp flux> <input watts>
+//	<C-plane angles>
+//	<gamma angles>
+//	<intensities in cd/klm, one C-plane after another>
+//
+// wrapped across lines however the writer chose.
+type TM14Parser struct{}
+
+func NewTM14Parser() *TM14Parser {
+	return &TM14Parser{}
+}
+
+// tm14Label matches a labelled header line such as "Manufacturer: Acme".
+var tm14Label = regexp.MustCompile(`^([A-Za-z][A-Za-z .]*?)\s*[:=]\s*(.*)$`)
+
+// tm14Labels maps lower-case header labels to metadata fields.
+var tm14Labels = map[string]func(*database.Luminaire, string){
+	"manufacturer": func(m *database.Luminaire, v string) { m.Manufacturer = v },
+	"luminaire":    func(m *database.Luminaire, v string) { m.LuminaireDesc = v },
+	"description":  func(m *database.Luminaire, v string) { m.LuminaireDesc = v },
+	"reference":    func(m *database.Luminaire, v string) { m.Model = v },
+	"model":        func(m *database.Luminaire, v string) { m.Model = v },
+	"catalogue":    func(m *database.Luminaire, v string) { m.CatalogNumber = v },
+	"catalogue no": func(m *database.Luminaire, v string) { m.CatalogNumber = v },
+	"lamp":         func(m *database.Luminaire, v string) { m.LampType = v },
+	"lamps":        func(m *database.Luminaire, v string) { m.LampType = v },
+	"control gear": func(m *database.Luminaire, v string) { m.Ballast = v },
+	"test":         func(m *database.Luminaire, v string) { m.TestNumber = v },
+	"test no":      func(m *database.Luminaire, v string) { m.TestNumber = v },
+	"report":       func(m *database.Luminaire, v string) { m.TestNumber = v },
+	"laboratory":   func(m *database.Luminaire, v string) { m.TestLab = v },
+	"date":         func(m *database.Luminaire, v string) { m.TestDate = v },
+}
+
+func (p *TM14Parser) Parse(filepath string) (*database.ParsedLuminaire, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("open file: %w", err)
+	}
+	defer file.Close()
+
+	return p.ParseReader(file, filepath)
+}
+
+func (p *TM14Parser) ParseReader(r io.Reader, name string) (*database.ParsedLuminaire, error) {
+	logger.Default.Debugf("parsing TM14 file: %s", name)
+
+	hash := sha256.New()
+	reader := io.TeeReader(r, hash)
+
+	limits := CurrentLimits()
+	scanner := newLineScanner(reader, limits)
+
+	metadata := database.Luminaire{
+		OriginalFilename: name,
+		FormatType:       "TM14",
+		FormatVersion:    VersionTM14,
+		FormatConfidence: ConfidenceInferred,
+		PhotometricType:  database.PhotometricTypeC,
+		UnitsType:        database.UnitsMetric,
+		ConversionFactor: 1,
+	}
+
+	var labels []string
+	var dataTokens []string
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if dataTokens == nil && !isNumericLine(line) {
+			if m := tm14Label.FindStringSubmatch(line); m != nil {
+				if set, ok := tm14Labels[strings.ToLower(strings.TrimSuffix(m[1], "."))]; ok {
+					set(&metadata, strings.TrimSpace(m[2]))
+					continue
+				}
+			}
+			labels = append(labels, line)
+			continue
+		}
+		dataTokens = append(dataTokens, strings.Fields(line)...)
+		if max := maxDataValues(limits); max > 0 && len(dataTokens) > max {
+			return nil, fmt.Errorf("invalid TM14 file: %w", &LimitError{Limit: "value count", Value: float64(len(dataTokens)), Max: max})
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scan file: %w", err)
+	}
+	if len(dataTokens) == 0 {
+		return nil, fmt.Errorf("invalid TM14 file: no photometric data")
+	}
+
+	// Unlabelled text describes the luminaire.
+	if metadata.LuminaireDesc == "" && len(labels) > 0 {
+		metadata.LuminaireDesc = strings.Join(strings.Fields(strings.Join(labels, " ")), " ")
+	}
+
+	tokens := &tokenReader{tokens: dataTokens}
+	header, err := tokens.floats(4)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TM14 file: header: %w", err)
+	}
+	numC, err := checkCount("C-plane count", header[0], limits.MaxAngles)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TM14 file: %w", err)
+	}
+	numG, err := checkCount("gamma count", header[1], limits.MaxAngles)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TM14 file: %w", err)
+	}
+	if numC == 0 || numG == 0 {
+		return nil, fmt.Errorf("invalid TM14 file: angle counts %d x %d", numC, numG)
+	}
+	if err := checkCandelaValues(numC, numG, limits.MaxCandelaValues); err != nil {
+		return nil, fmt.Errorf("invalid TM14 file: %w", err)
+	}
+	if need := numC + numG + numC*numG; need > tokens.remaining() {
+		return nil, fmt.Errorf("invalid TM14 file: expected %d values, found %d", need, tokens.remaining())
+	}
+	metadata.LuminousFlux = header[2]
+	metadata.InputWatts = header[3]
+
+	horizontalAngles, err := tokens.floats(numC)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TM14 file: C-plane angles: %w", err)
+	}
+	verticalAngles, err := tokens.floats(numG)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TM14 file: gamma angles: %w", err)
+	}
+
+	scale := 1.0
+	if metadata.LuminousFlux > 0 {
+		scale = metadata.LuminousFlux / 1000
+	}
+	candelaMatrix := make([][]float64, numC)
+	for i := range candelaMatrix {
+		row, err := tokens.floats(numG)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TM14 file: intensities for plane %d: %w", i, err)
+		}
+		for j := range row {
+			row[j] *= scale
+		}
+		candelaMatrix[i] = row
+	}
+
+	fileHash := fmt.Sprintf("%x", hash.Sum(nil))
+	metadata.FileHash = fileHash
+
+	logger.Default.Debugf("TM14 parse complete: file_hash=%s, vertical_angles=%d, horizontal_angles=%d",
+		fileHash, len(verticalAngles), len(horizontalAngles))
+
+	return &database.ParsedLuminaire{
+		Metadata:         metadata,
+		VerticalAngles:   verticalAngles,
+		HorizontalAngles: horizontalAngles,
+		CandelaMatrix:    candelaMatrix,
+	}, nil
+}
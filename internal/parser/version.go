@@ -0,0 +1,85 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+
+	"illuminate/internal/database"
+)
+
+// How sure a reader is of the format version it records in
+// Luminaire.FormatVersion.
+const (
+	// ConfidenceDeclared means the file names its version.
+	ConfidenceDeclared = 1.0
+	// ConfidenceInferred means the version follows from the file's layout.
+	ConfidenceInferred = 0.7
+	// ConfidenceGuessed means the layout fits several versions.
+	ConfidenceGuessed = 0.4
+)
+
+// Format versions the readers detect.
+const (
+	VersionLM63_2019 = "LM-63-2019"
+	VersionLM63_2002 = "LM-63-2002"
+	VersionLM63_1995 = "LM-63-1995"
+	VersionLM63_1991 = "LM-63-1991"
+	VersionLM63_1986 = "LM-63-1986"
+	VersionEulumdat  = "EULUMDAT 1.0"
+	VersionCIEITable = "CIE i-table"
+	VersionTM14      = "CIBSE TM14"
+	VersionOXL       = "LITESTAR OXL"
+)
+
+var iesFormatLine = regexp.MustCompile(`^IESNA\s*:?\s*LM-63-(\d{4})`)
+
+// iesVersion reads the version from the IESNA line of an IES file. Files
+// without one predate LM-63-1991 unless they use its keywords.
+func iesVersion(formatLine string, hasKeywords bool) (string, float64) {
+	line := strings.ToUpper(strings.TrimSpace(formatLine))
+	if m := iesFormatLine.FindStringSubmatch(line); m != nil {
+		switch version := "LM-63-" + m[1]; version {
+		case VersionLM63_2019, VersionLM63_2002, VersionLM63_1995:
+			return version, ConfidenceDeclared
+		default:
+			return version, ConfidenceGuessed
+		}
+	}
+	if strings.HasPrefix(line, "IESNA91") {
+		return VersionLM63_1991, ConfidenceDeclared
+	}
+	if hasKeywords {
+		return VersionLM63_1991, ConfidenceGuessed
+	}
+	return VersionLM63_1986, ConfidenceInferred
+}
+
+// legacyIESVersions are the versions written by tools that expect 80-column
+// lines.
+var legacyIESVersions = map[string]bool{
+	VersionLM63_1995: true,
+	VersionLM63_1991: true,
+	VersionLM63_1986: true,
+}
+
+// SourceDefaults returns the write options an export of meta to format
+// should default to, following the file it was read from where its version
+// is known: IES from LM-63-1995 and older tools keeps to 80 columns and
+// EULUMDAT keeps its DOS line endings. Fields it has no opinion on are zero.
+func SourceDefaults(meta database.Luminaire, format string) WriteOptions {
+	var opts WriteOptions
+	if meta.FormatConfidence < ConfidenceInferred {
+		return opts
+	}
+	switch strings.ToLower(format) {
+	case "ies":
+		if legacyIESVersions[meta.FormatVersion] {
+			opts.MaxLineLength = LegacyLineLength
+		}
+	case "ldt":
+		if meta.FormatVersion == VersionEulumdat {
+			opts.LineEnding = LineEndingCRLF
+		}
+	}
+	return opts
+}
@@ -0,0 +1,49 @@
+package parser
+
+import (
+	"testing"
+
+	"illuminate/internal/database"
+)
+
+func TestIESVersion(t *testing.T) {
+	for _, tc := range []struct {
+		line       string
+		keywords   bool
+		version    string
+		confidence float64
+	}{
+		{"IESNA:LM-63-2002", true, VersionLM63_2002, ConfidenceDeclared},
+		{"iesna: lm-63-1995", true, VersionLM63_1995, ConfidenceDeclared},
+		{"IESNA:LM-63-2019", true, VersionLM63_2019, ConfidenceDeclared},
+		{"IESNA91", true, VersionLM63_1991, ConfidenceDeclared},
+		{"IESNA:LM-63-2008", true, "LM-63-2008", ConfidenceGuessed},
+		{"", true, VersionLM63_1991, ConfidenceGuessed},
+		{"", false, VersionLM63_1986, ConfidenceInferred},
+	} {
+		version, confidence := iesVersion(tc.line, tc.keywords)
+		if version != tc.version || confidence != tc.confidence {
+			t.Errorf("iesVersion(%q, %v) = %s %g, want %s %g", tc.line, tc.keywords, version, confidence, tc.version, tc.confidence)
+		}
+	}
+}
+
+func TestSourceDefaults(t *testing.T) {
+	legacy := database.Luminaire{FormatVersion: VersionLM63_1995, FormatConfidence: ConfidenceDeclared}
+	if got := SourceDefaults(legacy, "ies"); got.MaxLineLength != LegacyLineLength {
+		t.Errorf("LM-63-1995 line length = %d", got.MaxLineLength)
+	}
+	if got := SourceDefaults(legacy, "ldt"); got.MaxLineLength != 0 || got.LineEnding != "" {
+		t.Errorf("IES source set LDT defaults: %+v", got)
+	}
+
+	eulumdat := database.Luminaire{FormatVersion: VersionEulumdat, FormatConfidence: ConfidenceInferred}
+	if got := SourceDefaults(eulumdat, "LDT"); got.LineEnding != LineEndingCRLF {
+		t.Errorf("EULUMDAT line ending = %q", got.LineEnding)
+	}
+
+	guessed := database.Luminaire{FormatVersion: VersionLM63_1991, FormatConfidence: ConfidenceGuessed}
+	if got := SourceDefaults(guessed, "ies"); got.MaxLineLength != 0 {
+		t.Errorf("a guessed version set line length %d", got.MaxLineLength)
+	}
+}
@@ -0,0 +1,164 @@
+// Package pdf writes simple text-and-rule PDF documents (reports,
+// datasheets) without external dependencies. Text uses the standard
+// Helvetica fonts in WinAnsi encoding, so no fonts are embedded.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// A4 page size in points.
+const (
+	PageWidth  = 595.0
+	PageHeight = 842.0
+)
+
+// Document collects pages of drawing operators.
+type Document struct {
+	pages []*bytes.Buffer
+	// Margin is the distance from the page edges used by Writer.
+	Margin float64
+	// Width and Height are the page size in points.
+	Width, Height float64
+}
+
+// New starts an A4 document.
+func New() *Document {
+	return &Document{Margin: 50, Width: PageWidth, Height: PageHeight}
+}
+
+// NewPage starts a new page and makes it current.
+func (d *Document) NewPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+}
+
+func (d *Document) page() *bytes.Buffer {
+	if len(d.pages) == 0 {
+		d.NewPage()
+	}
+	return d.pages[len(d.pages)-1]
+}
+
+// Text draws s with its baseline at (x, y), measured in points from the
+// bottom-left corner. Characters outside Windows-1252 become "?".
+func (d *Document) Text(x, y, size float64, bold bool, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(d.page(), "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, y, escape(s))
+}
+
+// Line draws a 0.5 pt rule from (x1, y1) to (x2, y2).
+func (d *Document) Line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(d.page(), "0.5 w %.2f %.2f m %.2f %.2f l S\n", x1, y1, x2, y2)
+}
+
+// Rect fills a w by h rectangle with its bottom-left corner at (x, y).
+func (d *Document) Rect(x, y, w, h float64) {
+	fmt.Fprintf(d.page(), "%.2f %.2f %.2f %.2f re f\n", x, y, w, h)
+}
+
+var textEncoder = encoding.ReplaceUnsupported(charmap.Windows1252.NewEncoder())
+
+func escape(s string) string {
+	if encoded, err := textEncoder.String(s); err == nil {
+		s = encoded
+	}
+	return strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`, "\r", "", "\n", " ").Replace(s)
+}
+
+// WriteTo serialises the document.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	if len(d.pages) == 0 {
+		d.NewPage()
+	}
+
+	var buf bytes.Buffer
+	var offsets []int
+	obj := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	// Objects 1-4 are fixed; each page then adds a page and a content object.
+	var kids []string
+	for i := range d.pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 5+2*i))
+	}
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+	obj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, content := range d.pages {
+		obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %g %g] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			d.Width, d.Height, 6+2*i))
+		obj(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.Bytes()))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return buf.WriteTo(w)
+}
+
+// Writer lays out lines of text top to bottom, starting new pages as needed.
+type Writer struct {
+	doc *Document
+	y   float64
+}
+
+// NewWriter starts writing at the top margin of a new page.
+func NewWriter(d *Document) *Writer {
+	d.NewPage()
+	return &Writer{doc: d, y: d.Height - d.Margin}
+}
+
+// Line writes one line of text at the given size and advances.
+func (w *Writer) Line(size float64, bold bool, s string) {
+	w.ensure(size * 1.4)
+	w.y -= size * 1.4
+	w.doc.Text(w.doc.Margin, w.y, size, bold, s)
+}
+
+// Columns writes one line with each cell starting at the given x offsets
+// from the left margin.
+func (w *Writer) Columns(size float64, bold bool, offsets []float64, cells []string) {
+	w.ensure(size * 1.4)
+	w.y -= size * 1.4
+	for i, cell := range cells {
+		if i < len(offsets) {
+			w.doc.Text(w.doc.Margin+offsets[i], w.y, size, bold, cell)
+		}
+	}
+}
+
+// Rule draws a horizontal rule across the text area.
+func (w *Writer) Rule() {
+	w.ensure(6)
+	w.y -= 4
+	w.doc.Line(w.doc.Margin, w.y, w.doc.Width-w.doc.Margin, w.y)
+	w.y -= 2
+}
+
+// Space advances by points of empty space.
+func (w *Writer) Space(points float64) {
+	w.y -= points
+}
+
+func (w *Writer) ensure(height float64) {
+	if w.y-height < w.doc.Margin {
+		w.doc.NewPage()
+		w.y = w.doc.Height - w.doc.Margin
+	}
+}
@@ -0,0 +1,42 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestDocumentStructure(t *testing.T) {
+	doc := New()
+	w := NewWriter(doc)
+	for i := 0; i < 80; i++ {
+		w.Line(10, i == 0, "Lümen (test) \\ 100%")
+	}
+	var buf bytes.Buffer
+	if _, err := doc.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.Bytes()
+
+	if !bytes.Contains(out, []byte("/Count 2")) {
+		t.Error("80 lines should span two pages")
+	}
+	if !bytes.Contains(out, []byte("(L\xfcmen \\(test\\) \\\\ 100%)")) {
+		t.Error("text not escaped and encoded as WinAnsi")
+	}
+
+	// Every xref entry must point at its object, and startxref at the table.
+	offsets := regexp.MustCompile(`(\d{10}) 00000 n`).FindAllSubmatch(out, -1)
+	for i, m := range offsets {
+		off, _ := strconv.Atoi(string(m[1]))
+		if !bytes.HasPrefix(out[off:], []byte(fmt.Sprintf("%d 0 obj", i+1))) {
+			t.Errorf("xref entry %d points at %q", i+1, out[off:off+10])
+		}
+	}
+	start := regexp.MustCompile(`startxref\n(\d+)`).FindSubmatch(out)
+	if off, _ := strconv.Atoi(string(start[1])); !bytes.HasPrefix(out[off:], []byte("xref")) {
+		t.Error("startxref does not point at the xref table")
+	}
+}
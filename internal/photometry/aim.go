@@ -0,0 +1,41 @@
+package photometry
+
+import (
+	"math"
+
+	"illuminate/internal/database"
+)
+
+// Aim returns lum as installed: its optical axis tilted by tilt degrees from
+// nadir toward C0, then turned by rotation degrees about the vertical from C0
+// toward C90. The result is a full type C distribution on a grid as fine as
+// the source's, at most 5°; with no tilt or rotation lum is returned as is.
+func Aim(lum *database.ParsedLuminaire, tilt, rotation float64) *database.ParsedLuminaire {
+	if tilt == 0 && rotation == 0 {
+		return lum
+	}
+	from := photometricType(lum.Metadata.PhotometricType)
+	st, ct := math.Sincos(rad(tilt))
+	sr, cr := math.Sincos(rad(rotation))
+
+	step := resampleStep(lum)
+	out := *lum
+	out.Metadata.PhotometricType = database.PhotometricTypeC
+	out.Metadata.Symmetry = 0
+	out.HorizontalAngles = angleSteps(0, 360, step)
+	out.VerticalAngles = angleSteps(0, 180, step)
+	out.CandelaMatrix = make([][]float64, len(out.HorizontalAngles))
+	for i, c := range out.HorizontalAngles {
+		row := make([]float64, len(out.VerticalAngles))
+		for j, g := range out.VerticalAngles {
+			// Undo the rotation, then the tilt, to find the measured direction.
+			d := Direction(database.PhotometricTypeC, c, g)
+			x, y := d[0]*cr+d[1]*sr, -d[0]*sr+d[1]*cr
+			x, z := x*ct+d[2]*st, -x*st+d[2]*ct
+			h, v := Angles(from, Vector{x, y, z})
+			row[j] = intensityIn(lum, from, h, v)
+		}
+		out.CandelaMatrix[i] = row
+	}
+	return &out
+}
@@ -0,0 +1,164 @@
+package photometry
+
+import (
+	"math"
+	"sort"
+
+	"illuminate/internal/database"
+)
+
+// Vector is a unit direction in the luminaire frame of database.Goniometer:
+// x along C0, y along C90, z up, with the optical axis at -z.
+type Vector [3]float64
+
+// Direction returns the direction of the angle pair (horizontal, vertical)
+// in degrees of photometric type t.
+func Direction(t database.PhotometricType, horizontal, vertical float64) Vector {
+	h, v := rad(horizontal), rad(vertical)
+	switch t {
+	case database.PhotometricTypeB:
+		// The half-plane through the transverse axis tilts by v toward C0;
+		// h turns within it toward C90.
+		return Vector{math.Cos(h) * math.Sin(v), math.Sin(h), -math.Cos(h) * math.Cos(v)}
+	case database.PhotometricTypeA:
+		// The half-plane through the longitudinal axis turns by h toward
+		// C90; v rises within it toward C0.
+		return Vector{math.Sin(v), math.Cos(v) * math.Sin(h), -math.Cos(v) * math.Cos(h)}
+	default:
+		return Vector{math.Sin(v) * math.Cos(h), math.Sin(v) * math.Sin(h), -math.Cos(v)}
+	}
+}
+
+// Angles is the inverse of Direction. Type C planes run 0..360, type A and B
+// angles -180..180 for the plane and -90..90 within it.
+func Angles(t database.PhotometricType, d Vector) (horizontal, vertical float64) {
+	clamp := func(x float64) float64 { return math.Max(-1, math.Min(1, x)) }
+	switch t {
+	case database.PhotometricTypeB:
+		return deg(math.Asin(clamp(d[1]))), deg(math.Atan2(d[0], -d[2]))
+	case database.PhotometricTypeA:
+		return deg(math.Atan2(d[1], -d[2])), deg(math.Asin(clamp(d[0])))
+	default:
+		c := deg(math.Atan2(d[1], d[0]))
+		if c < 0 {
+			c += 360
+		}
+		return c, deg(math.Acos(clamp(-d[2])))
+	}
+}
+
+// Transform resamples lum onto the horizontal and vertical angles of
+// photometric type t, interpolating between the stored angles. A luminaire
+// already of type t is returned as is.
+func Transform(lum *database.ParsedLuminaire, t database.PhotometricType, horizontal, vertical []float64) *database.ParsedLuminaire {
+	from := photometricType(lum.Metadata.PhotometricType)
+	if from == photometricType(t) {
+		return lum
+	}
+	out := *lum
+	out.Metadata.PhotometricType = t
+	out.HorizontalAngles = horizontal
+	out.VerticalAngles = vertical
+	out.CandelaMatrix = make([][]float64, len(horizontal))
+	for i, h := range horizontal {
+		row := make([]float64, len(vertical))
+		for j, v := range vertical {
+			sh, sv := Angles(from, Direction(t, h, v))
+			row[j] = intensityIn(lum, from, sh, sv)
+		}
+		out.CandelaMatrix[i] = row
+	}
+	return &out
+}
+
+// ToTypeC converts type A and B photometry to a full type C distribution on
+// a grid as fine as the source's, at most 5°. Type C is returned as is.
+func ToTypeC(lum *database.ParsedLuminaire) *database.ParsedLuminaire {
+	if photometricType(lum.Metadata.PhotometricType) == database.PhotometricTypeC {
+		return lum
+	}
+	step := resampleStep(lum)
+	return Transform(lum, database.PhotometricTypeC, angleSteps(0, 360, step), angleSteps(0, 180, step))
+}
+
+// resampleStep is the grid step that keeps the detail of lum: its finest
+// angle step, between 0.5° and 5°.
+func resampleStep(lum *database.ParsedLuminaire) float64 {
+	return math.Min(5, math.Max(0.5, math.Min(finestStep(lum.HorizontalAngles), finestStep(lum.VerticalAngles))))
+}
+
+// photometricType reads anything but type A and B as type C.
+func photometricType(t database.PhotometricType) database.PhotometricType {
+	if t == database.PhotometricTypeA || t == database.PhotometricTypeB {
+		return t
+	}
+	return database.PhotometricTypeC
+}
+
+// intensityIn looks up the stored intensity at the angles of type t.
+func intensityIn(lum *database.ParsedLuminaire, t database.PhotometricType, horizontal, vertical float64) float64 {
+	if t == database.PhotometricTypeC {
+		return Intensity(lum, horizontal, vertical)
+	}
+	h := lum.HorizontalAngles
+	if len(lum.CandelaMatrix) == 0 || len(h) == 0 {
+		return 0
+	}
+	// Files symmetric about the optical axis list only 0..90.
+	if horizontal < 0 && h[0] >= 0 {
+		horizontal = -horizontal
+	}
+	if len(h) == 1 {
+		return measured(lum.VerticalAngles, lum.CandelaMatrix[0], vertical)
+	}
+	if horizontal < h[0] || horizontal > h[len(h)-1] {
+		return 0
+	}
+	i := sort.SearchFloat64s(h, horizontal)
+	b := measured(lum.VerticalAngles, lum.CandelaMatrix[min(i, len(lum.CandelaMatrix)-1)], vertical)
+	if i == 0 || h[i] == horizontal {
+		return b
+	}
+	a := measured(lum.VerticalAngles, lum.CandelaMatrix[i-1], vertical)
+	return a + (b-a)*(horizontal-h[i-1])/(h[i]-h[i-1])
+}
+
+// measured interpolates values over angles and is zero outside them, where
+// nothing was measured.
+func measured(angles, values []float64, x float64) float64 {
+	n := min(len(angles), len(values))
+	if n == 0 || x < angles[0] || x > angles[n-1] {
+		return 0
+	}
+	i := sort.SearchFloat64s(angles[:n], x)
+	if angles[i] == x {
+		return values[i]
+	}
+	t := (x - angles[i-1]) / (angles[i] - angles[i-1])
+	return values[i-1] + (values[i]-values[i-1])*t
+}
+
+func finestStep(angles []float64) float64 {
+	step := math.Inf(1)
+	for i := 1; i < len(angles); i++ {
+		if d := angles[i] - angles[i-1]; d > 0 {
+			step = math.Min(step, d)
+		}
+	}
+	return step
+}
+
+func angleSteps(from, to, step float64) []float64 {
+	var angles []float64
+	for i := 0; ; i++ {
+		a := from + float64(i)*step
+		if a > to+1e-9 {
+			return angles
+		}
+		angles = append(angles, a)
+	}
+}
+
+func deg(r float64) float64 {
+	return r * 180 / math.Pi
+}
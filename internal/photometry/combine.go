@@ -0,0 +1,60 @@
+package photometry
+
+import (
+	"errors"
+	"math"
+	"slices"
+
+	"illuminate/internal/database"
+)
+
+// Combine adds up the distributions of the components of a segmented
+// luminaire, such as the up and down light of a pendant, for formats that
+// describe one emitter. The offsets between the components are ignored, as
+// they are in the far field that photometry describes: at more than about
+// five times their spread. Components on the same type C grid are summed
+// as stored; otherwise each is resampled onto a full type C grid as fine as
+// the finest of them, at most 5°. Input watts and lumens add up; the
+// metadata is otherwise the first component's, and format extensions are
+// dropped.
+func Combine(parts ...*database.ParsedLuminaire) (*database.ParsedLuminaire, error) {
+	if len(parts) == 0 {
+		return nil, errors.New("no component has a distribution")
+	}
+	typeC := make([]*database.ParsedLuminaire, len(parts))
+	sameGrid := true
+	step := 5.0
+	for i, p := range parts {
+		typeC[i] = ToTypeC(p)
+		step = math.Min(step, resampleStep(typeC[i]))
+		sameGrid = sameGrid && slices.Equal(typeC[i].VerticalAngles, typeC[0].VerticalAngles) &&
+			slices.Equal(typeC[i].HorizontalAngles, typeC[0].HorizontalAngles) &&
+			typeC[i].CheckShape() == nil
+	}
+
+	out := *typeC[0]
+	out.Extensions = nil
+	out.Metadata.InputWatts, out.Metadata.LuminousFlux = 0, 0
+	if !sameGrid {
+		out.HorizontalAngles = angleSteps(0, 360, step)
+		out.VerticalAngles = angleSteps(0, 180, step)
+	}
+	out.CandelaMatrix = make([][]float64, len(out.HorizontalAngles))
+	for i := range out.CandelaMatrix {
+		out.CandelaMatrix[i] = make([]float64, len(out.VerticalAngles))
+	}
+	for _, p := range typeC {
+		out.Metadata.InputWatts += p.Metadata.InputWatts
+		out.Metadata.LuminousFlux += p.Metadata.LuminousFlux
+		for i, c := range out.HorizontalAngles {
+			for j, gamma := range out.VerticalAngles {
+				if sameGrid {
+					out.CandelaMatrix[i][j] += p.CandelaMatrix[i][j]
+				} else {
+					out.CandelaMatrix[i][j] += Intensity(p, c, gamma)
+				}
+			}
+		}
+	}
+	return &out, nil
+}
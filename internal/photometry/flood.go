@@ -0,0 +1,96 @@
+package photometry
+
+import (
+	"fmt"
+
+	"illuminate/internal/database"
+)
+
+// nemaLimits are the largest beam spreads, in degrees at 10% of peak, of
+// NEMA beam types 1 to 6; wider beams are type 7.
+var nemaLimits = []float64{18, 29, 46, 70, 100, 130}
+
+// NEMAType is the NEMA floodlight beam type, 1 to 7, of a beam spread in
+// degrees.
+func NEMAType(spread float64) int {
+	for i, limit := range nemaLimits {
+		if spread <= limit {
+			return i + 1
+		}
+	}
+	return len(nemaLimits) + 1
+}
+
+// Floodlight describes the beam of a type B floodlight, in its own angles:
+// horizontal about the vertical axis, vertical up from the horizontal.
+type Floodlight struct {
+	// HorizontalSpread and VerticalSpread are the full angles in degrees,
+	// through the peak, over which the intensity stays at or above 10% of
+	// the peak.
+	HorizontalSpread float64 `json:"horizontal_spread"`
+	VerticalSpread   float64 `json:"vertical_spread"`
+	// PeakHorizontal and PeakVertical aim the peak intensity, PeakIntensity
+	// in cd.
+	PeakHorizontal float64 `json:"peak_horizontal"`
+	PeakVertical   float64 `json:"peak_vertical"`
+	PeakIntensity  float64 `json:"peak_intensity"`
+	// NEMAType is the NEMA beam type of each spread, as "6H x 5V".
+	NEMAType string `json:"nema_type"`
+}
+
+// FloodlightOf describes the beam of type B photometry; nil for any other
+// type or an empty distribution.
+func FloodlightOf(lum *database.ParsedLuminaire) *Floodlight {
+	if lum.Metadata.PhotometricType != database.PhotometricTypeB {
+		return nil
+	}
+	f := &Floodlight{}
+	for i, row := range lum.CandelaMatrix {
+		if i >= len(lum.HorizontalAngles) {
+			break
+		}
+		for j, v := range row {
+			if j < len(lum.VerticalAngles) && v > f.PeakIntensity {
+				f.PeakIntensity = v
+				f.PeakHorizontal = lum.HorizontalAngles[i]
+				f.PeakVertical = lum.VerticalAngles[j]
+			}
+		}
+	}
+	if f.PeakIntensity <= 0 {
+		return nil
+	}
+
+	threshold := 0.1 * f.PeakIntensity
+	f.HorizontalSpread = spreadThrough(func(h float64) float64 {
+		return intensityIn(lum, database.PhotometricTypeB, h, f.PeakVertical)
+	}, f.PeakHorizontal, threshold)
+	f.VerticalSpread = spreadThrough(func(v float64) float64 {
+		return intensityIn(lum, database.PhotometricTypeB, f.PeakHorizontal, v)
+	}, f.PeakVertical, threshold)
+	f.NEMAType = fmt.Sprintf("%dH x %dV", NEMAType(f.HorizontalSpread), NEMAType(f.VerticalSpread))
+	return f
+}
+
+// spreadThrough is the width of the range around peak over which intensity
+// stays at or above threshold, walking out both ways in 0.1° steps and
+// interpolating the crossing. Either side ends at most 180° out.
+func spreadThrough(intensity func(angle float64) float64, peak, threshold float64) float64 {
+	const step = 0.1
+	width := 0.0
+	for _, dir := range []float64{-1, 1} {
+		prev := intensity(peak)
+		reach := 180.0
+		for i := 1; float64(i)*step <= 180; i++ {
+			d := float64(i) * step
+			cur := intensity(peak + dir*d)
+			if cur < threshold {
+				reach = d - step + step*(prev-threshold)/(prev-cur)
+				break
+			}
+			prev = cur
+		}
+		width += reach
+	}
+	return width
+}
@@ -0,0 +1,121 @@
+package photometry
+
+import (
+	"math"
+
+	"illuminate/internal/database"
+)
+
+// Coverage of a grid axis.
+const (
+	CoverageFull     = "full"     // the whole sphere along this axis
+	CoverageDownward = "downward" // gamma 0–90 of type C
+	CoverageUpward   = "upward"   // gamma 90–180 of type C
+	CoverageHalf     = "half"     // C0–C180, C90–C270 or 0–90 of types A and B
+	CoverageQuadrant = "quadrant" // C0–C90
+	CoverageSingle   = "single"   // one plane
+	CoveragePartial  = "partial"
+	CoverageNone     = "none"
+)
+
+// GridAxis describes the angles of one axis of the candela grid.
+type GridAxis struct {
+	Count int     `json:"count"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	// Step is the spacing of a regular axis; nil when the spacing varies.
+	Step *float64 `json:"step"`
+	// MinStep and MaxStep are the finest and coarsest spacing.
+	MinStep  float64 `json:"min_step"`
+	MaxStep  float64 `json:"max_step"`
+	Regular  bool    `json:"regular"`
+	Coverage string  `json:"coverage"`
+}
+
+// Grid describes how a distribution is sampled, so clients can pick a
+// rendering and check resolution requirements.
+type Grid struct {
+	Vertical   GridAxis `json:"vertical"`
+	Horizontal GridAxis `json:"horizontal"`
+	// StoredSymmetry is the symmetry the range of stored planes implies;
+	// Symmetry is the one found in the candela values (see Metrics).
+	StoredSymmetry string `json:"stored_symmetry"`
+	Symmetry       string `json:"symmetry"`
+}
+
+// GridOf describes the angle grid of lum.
+func GridOf(lum *database.ParsedLuminaire) Grid {
+	typeC := lum.Metadata.PhotometricType != database.PhotometricTypeA && lum.Metadata.PhotometricType != database.PhotometricTypeB
+	g := Grid{
+		Vertical:   gridAxis(lum.VerticalAngles),
+		Horizontal: gridAxis(lum.HorizontalAngles),
+		Symmetry:   symmetryOf(lum),
+	}
+	v, h := &g.Vertical, &g.Horizontal
+
+	switch {
+	case v.Count == 0:
+	case typeC && v.Min <= 0 && v.Max >= 180, !typeC && v.Min <= -90 && v.Max >= 90:
+		v.Coverage = CoverageFull
+	case typeC && v.Min <= 0 && v.Max >= 90:
+		v.Coverage = CoverageDownward
+	case typeC && v.Min <= 90 && v.Max >= 180:
+		v.Coverage = CoverageUpward
+	case !typeC && v.Min <= 0 && v.Max >= 90:
+		v.Coverage = CoverageHalf
+	default:
+		v.Coverage = CoveragePartial
+	}
+
+	span := h.Max - h.Min
+	switch {
+	case h.Count == 0:
+	case h.Count == 1:
+		h.Coverage = CoverageSingle
+	case typeC && (span >= 360 || h.Regular && span+h.MinStep >= 360):
+		h.Coverage = CoverageFull
+	case typeC && span == 180, !typeC && span == 90:
+		h.Coverage = CoverageHalf
+	case typeC && span == 90:
+		h.Coverage = CoverageQuadrant
+	case !typeC && span >= 180:
+		h.Coverage = CoverageFull
+	default:
+		h.Coverage = CoveragePartial
+	}
+
+	switch h.Coverage {
+	case CoverageSingle, CoverageNone:
+		g.StoredSymmetry = SymmetryRotational
+	case CoverageQuadrant:
+		g.StoredSymmetry = SymmetryQuadrant
+	case CoverageHalf:
+		g.StoredSymmetry = SymmetryBilateral
+	default:
+		g.StoredSymmetry = SymmetryNone
+	}
+	return g
+}
+
+// gridAxis measures the spacing of angles, which are ascending.
+func gridAxis(angles []float64) GridAxis {
+	a := GridAxis{Count: len(angles), Coverage: CoverageNone}
+	if len(angles) == 0 {
+		return a
+	}
+	a.Min, a.Max = angles[0], angles[len(angles)-1]
+	if len(angles) == 1 {
+		return a
+	}
+	a.MinStep, a.MaxStep = math.Inf(1), 0
+	for i := 1; i < len(angles); i++ {
+		step := angles[i] - angles[i-1]
+		a.MinStep, a.MaxStep = math.Min(a.MinStep, step), math.Max(a.MaxStep, step)
+	}
+	if a.MaxStep-a.MinStep <= 1e-6 {
+		a.Regular = true
+		step := a.MinStep
+		a.Step = &step
+	}
+	return a
+}
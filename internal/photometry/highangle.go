@@ -0,0 +1,52 @@
+package photometry
+
+import (
+	"math"
+
+	"illuminate/internal/database"
+)
+
+// HighAngle summarises the zone from 65° to 90° from nadir that EN 12464-1
+// limits the luminance of, so luminaires are not seen reflected in display
+// screens or as glare sources at a distance. Intensities are sampled every
+// 5° of gamma and in C-planes every 15°.
+type HighAngle struct {
+	// MeanIntensity and MaxIntensity are in cd, over 65° to 90°.
+	MeanIntensity float64 `json:"mean_intensity"`
+	MaxIntensity  float64 `json:"max_intensity"`
+	// AverageLuminance and MaxLuminance are in cd/m² over 65° to 85°, the
+	// intensity over the opening's projected area. 90° is left out, where a
+	// flat opening has no projected area. Nil when the luminous area is
+	// unknown.
+	AverageLuminance *float64 `json:"average_luminance"`
+	MaxLuminance     *float64 `json:"max_luminance"`
+}
+
+// HighAngleOf summarises the 65°–90° zone of type C photometry.
+func HighAngleOf(lum *database.ParsedLuminaire) HighAngle {
+	var z HighAngle
+	area := luminousArea(lum.Metadata)
+	var intensities, luminances, maxL float64
+	var n, nl int
+	for gamma := 65.0; gamma <= 90; gamma += 5 {
+		projected := area * math.Cos(rad(gamma))
+		for c := 0.0; c < 360; c += 15 {
+			i := Intensity(lum, c, gamma)
+			intensities += i
+			n++
+			z.MaxIntensity = math.Max(z.MaxIntensity, i)
+			if gamma < 90 && area > 0 {
+				l := i / projected
+				luminances += l
+				nl++
+				maxL = math.Max(maxL, l)
+			}
+		}
+	}
+	z.MeanIntensity = intensities / float64(n)
+	if nl > 0 {
+		avg := luminances / float64(nl)
+		z.AverageLuminance, z.MaxLuminance = &avg, &maxL
+	}
+	return z
+}
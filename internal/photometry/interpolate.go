@@ -0,0 +1,41 @@
+package photometry
+
+import (
+	"fmt"
+	"slices"
+
+	"illuminate/internal/database"
+)
+
+// Interpolate returns the distribution a fraction t (0..1) of the way from a
+// to b, such as a luminaire at 75% from its measurements at 50% and 100%:
+// every candela value, the input watts and the lumens are interpolated
+// linearly. Both must be on the same angle grid. The metadata is a's; the
+// format extensions are dropped, as they describe a's file.
+func Interpolate(a, b *database.ParsedLuminaire, t float64) (*database.ParsedLuminaire, error) {
+	if !slices.Equal(a.VerticalAngles, b.VerticalAngles) || !slices.Equal(a.HorizontalAngles, b.HorizontalAngles) {
+		return nil, fmt.Errorf("the measurements use different angle grids")
+	}
+	if len(a.CandelaMatrix) != len(b.CandelaMatrix) {
+		return nil, fmt.Errorf("the measurements have %d and %d candela rows", len(a.CandelaMatrix), len(b.CandelaMatrix))
+	}
+	for i := range a.CandelaMatrix {
+		if len(a.CandelaMatrix[i]) != len(b.CandelaMatrix[i]) {
+			return nil, fmt.Errorf("candela row %d has %d and %d values", i, len(a.CandelaMatrix[i]), len(b.CandelaMatrix[i]))
+		}
+	}
+
+	lerp := func(x, y float64) float64 { return x + (y-x)*t }
+	out := *a
+	out.Extensions = nil
+	out.Metadata.InputWatts = lerp(a.Metadata.InputWatts, b.Metadata.InputWatts)
+	out.Metadata.LuminousFlux = lerp(a.Metadata.LuminousFlux, b.Metadata.LuminousFlux)
+	out.CandelaMatrix = make([][]float64, len(a.CandelaMatrix))
+	for i, row := range a.CandelaMatrix {
+		out.CandelaMatrix[i] = make([]float64, len(row))
+		for j, v := range row {
+			out.CandelaMatrix[i][j] = lerp(v, b.CandelaMatrix[i][j])
+		}
+	}
+	return &out, nil
+}
@@ -0,0 +1,282 @@
+// Package photometry derives summary metrics (flux, beam angles, efficacy,
+// distribution class, symmetry, UGR, high-angle luminance and floodlight
+// beam types) from a luminous intensity distribution.
+package photometry
+
+import (
+	"math"
+	"sort"
+
+	"illuminate/internal/database"
+)
+
+// Distribution classes after CIE, by the share of flux emitted downwards.
+const (
+	Direct         = "direct"
+	SemiDirect     = "semi-direct"
+	GeneralDiffuse = "general-diffuse"
+	SemiIndirect   = "semi-indirect"
+	Indirect       = "indirect"
+)
+
+// Symmetry classes of a distribution, from most to least symmetric.
+const (
+	SymmetryRotational = "rotational"
+	SymmetryQuadrant   = "quadrant"
+	SymmetryBilateral  = "bilateral"
+	SymmetryNone       = "none"
+)
+
+// Metrics summarises a distribution.
+type Metrics struct {
+	// Flux is the luminaire flux integrated from the candela values, in lm.
+	Flux float64 `json:"flux"`
+	// DownwardFraction is the share of Flux below the horizontal, 0..1.
+	DownwardFraction float64 `json:"downward_fraction"`
+	// BeamAngle and FieldAngle are the full angles in degrees at which the
+	// intensity falls to 50% and 10% of its peak, averaged over the
+	// C0–C180 and C90–C270 planes.
+	BeamAngle  float64 `json:"beam_angle"`
+	FieldAngle float64 `json:"field_angle"`
+	// Efficacy is Flux per input watt; nil when the power is unknown.
+	Efficacy     *float64 `json:"efficacy"`
+	Distribution string   `json:"distribution"`
+	Symmetry     string   `json:"symmetry"`
+	// UGR is the standard-room glare rating (see UGR); nil when the
+	// luminous area is unknown.
+	UGR *float64 `json:"ugr"`
+	// HighAngle summarises the 65°–90° zone EN 12464-1 limits the
+	// luminance of.
+	HighAngle HighAngle `json:"high_angle"`
+	// Floodlight describes the beam of type B photometry in its own
+	// angles; nil for other types.
+	Floodlight *Floodlight `json:"floodlight,omitempty"`
+}
+
+// Compute derives every metric of lum. Type A and B photometry is converted
+// to type C first, so floodlight beams are measured around their optical
+// axis; the Floodlight metrics of type B are taken before the conversion.
+func Compute(lum *database.ParsedLuminaire) Metrics {
+	flood := FloodlightOf(lum)
+	lum = ToTypeC(lum)
+	flux, down := integrate(lum)
+	m := Metrics{
+		Flux:       flux,
+		BeamAngle:  (spread(lum, 0, 0.5) + spread(lum, 90, 0.5)) / 2,
+		FieldAngle: (spread(lum, 0, 0.1) + spread(lum, 90, 0.1)) / 2,
+		Symmetry:   symmetryOf(lum),
+		HighAngle:  HighAngleOf(lum),
+		Floodlight: flood,
+	}
+	if flux > 0 {
+		m.DownwardFraction = down / flux
+	}
+	m.Distribution = classify(m.DownwardFraction)
+	if watts := lum.Metadata.InputWatts; watts > 0 && flux > 0 {
+		efficacy := flux / watts
+		m.Efficacy = &efficacy
+	}
+	if ugr, ok := UGR(lum); ok {
+		m.UGR = &ugr
+	}
+	return m
+}
+
+// Intensity returns the candela value at (c, gamma) in degrees, unfolding
+// the stored symmetry and interpolating linearly between stored angles.
+func Intensity(lum *database.ParsedLuminaire, c, gamma float64) float64 {
+	if len(lum.CandelaMatrix) == 0 || len(lum.VerticalAngles) == 0 {
+		return 0
+	}
+	h := lum.HorizontalAngles
+	if len(h) <= 1 || len(lum.CandelaMatrix) == 1 {
+		return interpolate(lum.VerticalAngles, lum.CandelaMatrix[0], gamma)
+	}
+
+	c = foldPlane(h, c)
+	i := sort.SearchFloat64s(h, c)
+	switch {
+	case i == 0:
+		return interpolate(lum.VerticalAngles, lum.CandelaMatrix[0], gamma)
+	case i >= len(h) || i >= len(lum.CandelaMatrix):
+		// Past the last stored plane of a full distribution: wrap to C0.
+		last := min(len(h), len(lum.CandelaMatrix)) - 1
+		a := interpolate(lum.VerticalAngles, lum.CandelaMatrix[last], gamma)
+		b := interpolate(lum.VerticalAngles, lum.CandelaMatrix[0], gamma)
+		span := 360 - h[last]
+		if span <= 0 {
+			return a
+		}
+		return a + (b-a)*(c-h[last])/span
+	}
+	a := interpolate(lum.VerticalAngles, lum.CandelaMatrix[i-1], gamma)
+	b := interpolate(lum.VerticalAngles, lum.CandelaMatrix[i], gamma)
+	return a + (b-a)*(c-h[i-1])/(h[i]-h[i-1])
+}
+
+// foldPlane maps c into the stored plane range using the mirror planes a
+// reduced-symmetry file implies (0–90, 0–180 or 90–270).
+func foldPlane(h []float64, c float64) float64 {
+	c = math.Mod(math.Mod(c, 360)+360, 360)
+	lo, hi := h[0], h[len(h)-1]
+	if hi-lo >= 270 {
+		return c
+	}
+	for _, cand := range []float64{c, 360 - c, 180 - c, 180 + c, c - 180, 540 - c} {
+		cand = math.Mod(cand+360, 360)
+		if cand >= lo-1e-9 && cand <= hi+1e-9 {
+			return cand
+		}
+	}
+	return c
+}
+
+func interpolate(angles, values []float64, x float64) float64 {
+	n := min(len(angles), len(values))
+	if n == 0 {
+		return 0
+	}
+	if x <= angles[0] {
+		return values[0]
+	}
+	if x >= angles[n-1] {
+		// Outside the measured range (e.g. above 90° for a downlight).
+		if angles[n-1] < 180 {
+			return 0
+		}
+		return values[n-1]
+	}
+	i := sort.SearchFloat64s(angles[:n], x)
+	if angles[i] == x {
+		return values[i]
+	}
+	t := (x - angles[i-1]) / (angles[i] - angles[i-1])
+	return values[i-1] + (values[i]-values[i-1])*t
+}
+
+// integrate returns the total and the downward flux, sampling the solid
+// angle in 1° zones and 5° planes.
+func integrate(lum *database.ParsedLuminaire) (total, down float64) {
+	down = integrateTo(lum, 90)
+	return integrateTo(lum, 180), down
+}
+
+// Flux integrates the luminaire flux in lm from the candela values.
+func Flux(lum *database.ParsedLuminaire) float64 {
+	return integrateTo(lum, 180)
+}
+
+// ConeFraction is the share of the flux emitted within halfAngle degrees of
+// nadir. Regulation (EU) 2019/2020 calls a source directional when at least
+// 80% of its flux falls within a 120° cone (ConeFraction(lum, 60) >= 0.8).
+func ConeFraction(lum *database.ParsedLuminaire, halfAngle float64) float64 {
+	total, _ := integrate(lum)
+	if total <= 0 {
+		return 0
+	}
+	return integrateTo(lum, halfAngle) / total
+}
+
+func integrateTo(lum *database.ParsedLuminaire, maxGamma float64) float64 {
+	const dc = 5.0
+	flux := 0.0
+	for g := 0.0; g < maxGamma; g++ {
+		top := math.Min(g+1, maxGamma)
+		zone := 2 * math.Pi * (math.Cos(rad(g)) - math.Cos(rad(top)))
+		mean := 0.0
+		for c := 0.0; c < 360; c += dc {
+			mean += Intensity(lum, c, (g+top)/2)
+		}
+		flux += mean / (360 / dc) * zone
+	}
+	return flux
+}
+
+// spread is the full angle in the plane c/c+180 within which the intensity
+// stays above fraction of the plane's peak, measured outward from the peak
+// on either side of nadir.
+func spread(lum *database.ParsedLuminaire, c, fraction float64) float64 {
+	return halfSpread(lum, c, fraction) + halfSpread(lum, c+180, fraction)
+}
+
+func halfSpread(lum *database.ParsedLuminaire, c, fraction float64) float64 {
+	const step = 0.25
+	peak, peakAt := 0.0, 0.0
+	for g := 0.0; g <= 90; g += step {
+		if v := Intensity(lum, c, g); v > peak {
+			peak, peakAt = v, g
+		}
+	}
+	if peak <= 0 {
+		return 0
+	}
+	limit := peak * fraction
+	prev := peak
+	for g := peakAt + step; g <= 180; g += step {
+		v := Intensity(lum, c, g)
+		if v < limit {
+			// Interpolate the crossing within the last step.
+			return g - step + step*(prev-limit)/(prev-v)
+		}
+		prev = v
+	}
+	return 180
+}
+
+func classify(downward float64) string {
+	switch {
+	case downward >= 0.9:
+		return Direct
+	case downward >= 0.6:
+		return SemiDirect
+	case downward >= 0.4:
+		return GeneralDiffuse
+	case downward >= 0.1:
+		return SemiIndirect
+	default:
+		return Indirect
+	}
+}
+
+// symmetryOf compares mirrored samples of the distribution, tolerating 2% of
+// the peak as measurement noise.
+func symmetryOf(lum *database.ParsedLuminaire) string {
+	peak := 0.0
+	for _, row := range lum.CandelaMatrix {
+		for _, v := range row {
+			peak = math.Max(peak, v)
+		}
+	}
+	if peak == 0 {
+		return SymmetryRotational
+	}
+	tol := 0.02 * peak
+
+	same := func(mirror func(c float64) float64) bool {
+		for c := 0.0; c < 360; c += 15 {
+			for g := 0.0; g <= 180; g += 15 {
+				if math.Abs(Intensity(lum, c, g)-Intensity(lum, mirror(c), g)) > tol {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	rotational := same(func(c float64) float64 { return c + 45 }) && same(func(c float64) float64 { return c + 90 })
+	mirror0 := same(func(c float64) float64 { return 360 - c })
+	mirror90 := same(func(c float64) float64 { return 180 - c })
+	switch {
+	case rotational:
+		return SymmetryRotational
+	case mirror0 && mirror90:
+		return SymmetryQuadrant
+	case mirror0 || mirror90:
+		return SymmetryBilateral
+	default:
+		return SymmetryNone
+	}
+}
+
+func rad(deg float64) float64 {
+	return deg * math.Pi / 180
+}
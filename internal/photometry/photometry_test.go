@@ -0,0 +1,407 @@
+package photometry
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"illuminate/internal/database"
+	"illuminate/internal/synth"
+)
+
+func TestComputeSyntheticDistributions(t *testing.T) {
+	tests := []struct {
+		dist         synth.Distribution
+		beam         float64
+		distribution string
+		symmetry     string
+	}{
+		{synth.Lambertian, 120, Direct, SymmetryRotational},
+		{synth.NarrowBeam, 24, Direct, SymmetryRotational},
+		{synth.Street, 0, Direct, SymmetryBilateral},
+	}
+	for _, tc := range tests {
+		t.Run(string(tc.dist), func(t *testing.T) {
+			opts := synth.DefaultOptions()
+			opts.Distribution = tc.dist
+			opts.VerticalStep, opts.HorizontalStep = 1, 5
+			lum, err := synth.Generate(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			m := Compute(lum)
+			if math.Abs(m.Flux-opts.Flux)/opts.Flux > 0.02 {
+				t.Errorf("flux = %.0f, want %.0f", m.Flux, opts.Flux)
+			}
+			if tc.beam > 0 && math.Abs(m.BeamAngle-tc.beam) > 1 {
+				t.Errorf("beam angle = %.1f, want %.0f", m.BeamAngle, tc.beam)
+			}
+			if m.FieldAngle < m.BeamAngle {
+				t.Errorf("field angle %.1f below beam angle %.1f", m.FieldAngle, m.BeamAngle)
+			}
+			if m.Distribution != tc.distribution || m.Symmetry != tc.symmetry {
+				t.Errorf("class = %s/%s, want %s/%s", m.Distribution, m.Symmetry, tc.distribution, tc.symmetry)
+			}
+			if m.Efficacy == nil || math.Abs(*m.Efficacy-m.Flux/opts.InputWatts) > 1e-9 {
+				t.Errorf("efficacy = %v", m.Efficacy)
+			}
+			if m.UGR != nil {
+				t.Error("UGR without a luminous area")
+			}
+		})
+	}
+}
+
+func TestUGR(t *testing.T) {
+	opts := synth.DefaultOptions()
+	lum, err := synth.Generate(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lum.Metadata.LuminousLength, lum.Metadata.LuminousWidth = 0.6, 0.6
+
+	ugr, ok := UGR(lum)
+	if !ok || ugr < 10 || ugr > 35 {
+		t.Fatalf("UGR = %.1f, %v", ugr, ok)
+	}
+
+	// A smaller opening of the same intensity is brighter and glares more.
+	lum.Metadata.LuminousLength, lum.Metadata.LuminousWidth = 0.1, 0
+	if small, _ := UGR(lum); small <= ugr {
+		t.Errorf("UGR of a 100 mm disc (%.1f) should exceed a 600 mm panel (%.1f)", small, ugr)
+	}
+}
+
+func TestGridOf(t *testing.T) {
+	lum, err := synth.Generate(synth.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := GridOf(lum)
+	if v := g.Vertical; v.Count != 37 || v.Step == nil || *v.Step != 5 || !v.Regular || v.Coverage != CoverageFull {
+		t.Errorf("vertical = %+v", v)
+	}
+	if h := g.Horizontal; h.Count != 25 || h.Step == nil || *h.Step != 15 || h.Coverage != CoverageFull {
+		t.Errorf("horizontal = %+v", h)
+	}
+	if g.StoredSymmetry != SymmetryNone || g.Symmetry != SymmetryRotational {
+		t.Errorf("symmetry = %s stored, %s detected", g.StoredSymmetry, g.Symmetry)
+	}
+
+	// A downlight measured to 90° on a finer grid near nadir, in one quadrant.
+	lum.VerticalAngles = []float64{0, 2.5, 5, 10, 20, 45, 90}
+	lum.HorizontalAngles = []float64{0, 45, 90}
+	lum.CandelaMatrix = [][]float64{{100, 99, 98, 95, 80, 40, 0}, {100, 99, 98, 95, 80, 40, 0}, {100, 99, 98, 95, 80, 40, 0}}
+	g = GridOf(lum)
+	if v := g.Vertical; v.Regular || v.Step != nil || v.MinStep != 2.5 || v.MaxStep != 45 || v.Coverage != CoverageDownward {
+		t.Errorf("irregular vertical = %+v", v)
+	}
+	if g.Horizontal.Coverage != CoverageQuadrant || g.StoredSymmetry != SymmetryQuadrant {
+		t.Errorf("quadrant grid = %+v, %s", g.Horizontal, g.StoredSymmetry)
+	}
+
+	lum.HorizontalAngles, lum.CandelaMatrix = []float64{0}, lum.CandelaMatrix[:1]
+	if g := GridOf(lum); g.Horizontal.Coverage != CoverageSingle || g.StoredSymmetry != SymmetryRotational {
+		t.Errorf("single plane = %+v, %s", g.Horizontal, g.StoredSymmetry)
+	}
+}
+
+func TestAxes(t *testing.T) {
+	types := []database.PhotometricType{database.PhotometricTypeA, database.PhotometricTypeB, database.PhotometricTypeC}
+	for _, pt := range types {
+		if d := Direction(pt, 0, 0); pt != database.PhotometricTypeC && math.Abs(d[2]+1) > 1e-9 {
+			t.Errorf("type %d: zero angles point at %v, want the optical axis", pt, d)
+		}
+		for _, a := range [][2]float64{{30, 20}, {-45, 60}, {10, -70}} {
+			h, v := a[0], a[1]
+			if pt == database.PhotometricTypeC {
+				h, v = h+90, math.Abs(v)
+			}
+			gh, gv := Angles(pt, Direction(pt, h, v))
+			if math.Abs(gh-h) > 1e-9 || math.Abs(gv-v) > 1e-9 {
+				t.Errorf("type %d: (%g, %g) came back as (%g, %g)", pt, h, v, gh, gv)
+			}
+		}
+	}
+
+	// Both A and B put horizontal angles toward C90 and vertical ones
+	// toward C0.
+	for _, pt := range types[:2] {
+		if h, g := Angles(database.PhotometricTypeC, Direction(pt, 30, 0)); math.Abs(h-90) > 1e-9 || math.Abs(g-30) > 1e-9 {
+			t.Errorf("type %d horizontal 30 is C%g γ%g", pt, h, g)
+		}
+		if h, g := Angles(database.PhotometricTypeC, Direction(pt, 0, 30)); math.Abs(h) > 1e-9 || math.Abs(g-30) > 1e-9 {
+			t.Errorf("type %d vertical 30 is C%g γ%g", pt, h, g)
+		}
+	}
+}
+
+func TestTransformFloodlight(t *testing.T) {
+	opts := synth.DefaultOptions()
+	opts.Distribution = synth.NarrowBeam
+	opts.VerticalStep, opts.HorizontalStep = 1, 5
+	lum, err := synth.Generate(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Compute(lum)
+	peak := Intensity(lum, 0, 0)
+
+	// The same beam measured as a type B floodlight, front hemisphere only.
+	angles := angleSteps(-90, 90, 1)
+	flood := Transform(lum, database.PhotometricTypeB, angles, angles)
+	if flood.Metadata.PhotometricType != database.PhotometricTypeB || lum.Metadata.PhotometricType == database.PhotometricTypeB {
+		t.Fatal("Transform should set the target type on a copy")
+	}
+	if got := flood.CandelaMatrix[90][90]; math.Abs(got-peak) > 1e-6 {
+		t.Errorf("type B intensity on the optical axis = %g, want %g", got, peak)
+	}
+
+	back := ToTypeC(flood)
+	for _, a := range [][2]float64{{0, 0}, {0, 8}, {90, 12}, {215, 5}} {
+		if got, want := Intensity(back, a[0], a[1]), Intensity(lum, a[0], a[1]); math.Abs(got-want) > 0.01*peak {
+			t.Errorf("C%g γ%g = %.1f after B and back, want %.1f", a[0], a[1], got, want)
+		}
+	}
+
+	got := Compute(flood)
+	if math.Abs(got.BeamAngle-want.BeamAngle) > 1 || math.Abs(got.Flux-want.Flux)/want.Flux > 0.02 {
+		t.Errorf("type B metrics: beam %.1f flux %.0f, want beam %.1f flux %.0f", got.BeamAngle, got.Flux, want.BeamAngle, want.Flux)
+	}
+}
+
+func TestAim(t *testing.T) {
+	opts := synth.DefaultOptions()
+	opts.Distribution = synth.NarrowBeam
+	opts.VerticalStep, opts.HorizontalStep = 1, 5
+	lum, err := synth.Generate(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Aim(lum, 0, 0) != lum {
+		t.Error("an unaimed luminaire should be returned as is")
+	}
+	peak := Intensity(lum, 0, 0)
+
+	for _, tc := range []struct{ tilt, rotation, c, gamma float64 }{
+		{30, 0, 0, 30},
+		{30, 90, 90, 30},
+		{-20, 0, 180, 20},
+		{70, 45, 45, 70},
+	} {
+		aimed := Aim(lum, tc.tilt, tc.rotation)
+		if got := Intensity(aimed, tc.c, tc.gamma); math.Abs(got-peak) > 0.01*peak {
+			t.Errorf("tilt %g rotation %g: C%g γ%g = %.0f, want the peak %.0f", tc.tilt, tc.rotation, tc.c, tc.gamma, got, peak)
+		}
+		if got := Intensity(aimed, 0, 0); got > 0.5*peak {
+			t.Errorf("tilt %g rotation %g: nadir still at %.0f", tc.tilt, tc.rotation, got)
+		}
+		if flux := Flux(aimed); math.Abs(flux-opts.Flux)/opts.Flux > 0.02 {
+			t.Errorf("tilt %g rotation %g: flux %.0f, want %.0f", tc.tilt, tc.rotation, flux, opts.Flux)
+		}
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	full, err := synth.Generate(synth.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := synth.DefaultOptions()
+	opts.Flux, opts.InputWatts = 500, 6
+	half, err := synth.Generate(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mid, err := Interpolate(half, full, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mid.Metadata.InputWatts != 8 || mid.Metadata.LuminousFlux != 750 {
+		t.Errorf("watts %g, lumens %g; want 8 and 750", mid.Metadata.InputWatts, mid.Metadata.LuminousFlux)
+	}
+	if flux := Flux(mid); math.Abs(flux-750)/750 > 0.02 {
+		t.Errorf("computed flux %.0f, want 750", flux)
+	}
+
+	opts.VerticalStep = 10
+	coarse, err := synth.Generate(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Interpolate(coarse, full, 0.5); err == nil {
+		t.Error("measurements on different grids should not interpolate")
+	}
+}
+
+func TestCombine(t *testing.T) {
+	down, err := synth.Generate(synth.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := synth.DefaultOptions()
+	opts.Flux, opts.InputWatts = 400, 4
+	up, err := synth.Generate(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Turn the second one over into an uplight.
+	for _, row := range up.CandelaMatrix {
+		slices.Reverse(row)
+	}
+
+	both, err := Combine(down, up)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := down.Metadata.InputWatts + 4; both.Metadata.InputWatts != want {
+		t.Errorf("input watts %g, want %g", both.Metadata.InputWatts, want)
+	}
+	want := Flux(down) + Flux(up)
+	if flux := Flux(both); math.Abs(flux-want)/want > 0.001 {
+		t.Errorf("flux %.0f, want %.0f", flux, want)
+	}
+	if i := Intensity(both, 0, 180); math.Abs(i-Intensity(up, 0, 180)) > 1e-9 {
+		t.Errorf("zenith intensity %g, want the uplight's %g", i, Intensity(up, 0, 180))
+	}
+
+	// Components on different grids are resampled.
+	opts.VerticalStep = 10
+	coarse, err := synth.Generate(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mixed, err := Combine(down, coarse)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = Flux(down) + Flux(coarse)
+	if flux := Flux(mixed); math.Abs(flux-want)/want > 0.02 {
+		t.Errorf("resampled flux %.0f, want %.0f", flux, want)
+	}
+	if _, err := Combine(); err == nil {
+		t.Error("nothing to combine accepted")
+	}
+}
+
+// gaussianFlood is a type B floodlight whose intensity falls off as a
+// Gaussian of widths sh and sv degrees around (0°, peakV).
+func gaussianFlood(sh, sv, peakV float64) *database.ParsedLuminaire {
+	lum := &database.ParsedLuminaire{Metadata: database.Luminaire{PhotometricType: database.PhotometricTypeB}}
+	for a := -90.0; a <= 90; a += 2.5 {
+		lum.HorizontalAngles = append(lum.HorizontalAngles, a)
+		lum.VerticalAngles = append(lum.VerticalAngles, a)
+	}
+	for _, h := range lum.HorizontalAngles {
+		row := make([]float64, len(lum.VerticalAngles))
+		for j, v := range lum.VerticalAngles {
+			row[j] = 10000 * math.Exp(-h*h/(2*sh*sh)-(v-peakV)*(v-peakV)/(2*sv*sv))
+		}
+		lum.CandelaMatrix = append(lum.CandelaMatrix, row)
+	}
+	return lum
+}
+
+func TestFloodlight(t *testing.T) {
+	// A Gaussian falls to 10% at ±σ·√(2 ln 10): 85.8° wide for σ = 20°
+	// (NEMA 5) and 21.5° for σ = 5° (NEMA 2).
+	lum := gaussianFlood(20, 5, 10)
+	f := FloodlightOf(lum)
+	if f == nil {
+		t.Fatal("no floodlight metrics for type B")
+	}
+	width := func(sigma float64) float64 { return 2 * sigma * math.Sqrt(2*math.Ln10) }
+	if math.Abs(f.HorizontalSpread-width(20)) > 1 || math.Abs(f.VerticalSpread-width(5)) > 1 {
+		t.Errorf("spread %.1f° x %.1f°, want %.1f° x %.1f°", f.HorizontalSpread, f.VerticalSpread, width(20), width(5))
+	}
+	if f.PeakHorizontal != 0 || f.PeakVertical != 10 || f.PeakIntensity != 10000 {
+		t.Errorf("peak %.0f cd at H%g V%g", f.PeakIntensity, f.PeakHorizontal, f.PeakVertical)
+	}
+	if f.NEMAType != "5H x 2V" {
+		t.Errorf("NEMA type %q, want 5H x 2V", f.NEMAType)
+	}
+	if m := Compute(lum); m.Floodlight == nil || m.Floodlight.NEMAType != f.NEMAType {
+		t.Errorf("Compute floodlight = %+v", m.Floodlight)
+	}
+
+	for spread, want := range map[float64]int{5: 1, 18: 1, 18.5: 2, 46: 3, 70: 4, 100: 5, 130: 6, 131: 7} {
+		if got := NEMAType(spread); got != want {
+			t.Errorf("NEMAType(%g) = %d, want %d", spread, got, want)
+		}
+	}
+
+	typeC, err := synth.Generate(synth.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if FloodlightOf(typeC) != nil {
+		t.Error("type C photometry has no floodlight metrics")
+	}
+}
+
+func TestHighAngle(t *testing.T) {
+	lum, err := synth.Generate(synth.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A Lambertian source, I = I0 cos γ, has the same luminance I0/A in
+	// every direction.
+	i0 := lum.CandelaMatrix[0][0]
+	z := HighAngleOf(lum)
+	if z.AverageLuminance != nil {
+		t.Error("luminance without a luminous area")
+	}
+	mean := 0.0
+	for g := 65.0; g <= 90; g += 5 {
+		mean += i0 * math.Cos(g*math.Pi/180) / 6
+	}
+	if math.Abs(z.MeanIntensity-mean) > 0.01*mean {
+		t.Errorf("mean intensity %.2f cd, want %.2f", z.MeanIntensity, mean)
+	}
+	if max := i0 * math.Cos(65*math.Pi/180); math.Abs(z.MaxIntensity-max) > 0.01*max {
+		t.Errorf("max intensity %.2f cd, want %.2f", z.MaxIntensity, max)
+	}
+
+	lum.Metadata.LuminousLength, lum.Metadata.LuminousWidth = 0.6, 0.6
+	z = Compute(lum).HighAngle
+	want := i0 / 0.36
+	if z.AverageLuminance == nil || math.Abs(*z.AverageLuminance-want) > 0.02*want || math.Abs(*z.MaxLuminance-want) > 0.02*want {
+		t.Errorf("luminance %v / %v cd/m², want %.0f", z.AverageLuminance, z.MaxLuminance, want)
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	signature := func(opts synth.Options) []float64 {
+		lum, err := synth.Generate(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return Signature(lum)
+	}
+	base := synth.DefaultOptions()
+	sig := signature(base)
+	if got := Similarity(sig, sig); got != 1 {
+		t.Errorf("self similarity = %.4f", got)
+	}
+
+	// The same distribution on a finer grid still matches.
+	fine := base
+	fine.VerticalStep, fine.HorizontalStep = 1, 5
+	if got := Similarity(sig, signature(fine)); got < 0.99 {
+		t.Errorf("finer grid similarity = %.4f", got)
+	}
+
+	dimmed := base
+	dimmed.Flux = 0.9 * base.Flux
+	if got := Similarity(sig, signature(dimmed)); math.Abs(got-0.9) > 0.005 {
+		t.Errorf("dimmed similarity = %.4f, want 0.9", got)
+	}
+
+	narrow := base
+	narrow.Distribution = synth.NarrowBeam
+	if got := Similarity(sig, signature(narrow)); got > 0.8 {
+		t.Errorf("narrow beam similarity = %.4f", got)
+	}
+}
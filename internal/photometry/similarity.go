@@ -0,0 +1,44 @@
+package photometry
+
+import (
+	"math"
+
+	"illuminate/internal/database"
+)
+
+// Signature samples the distribution of lum every 5° of gamma and in
+// C-planes every 15°, each intensity weighted by the solid angle of its
+// zone, so that signatures of luminaires measured on different angle grids
+// compare. Type A and B photometry is converted to type C first.
+func Signature(lum *database.ParsedLuminaire) []float64 {
+	lum = ToTypeC(lum)
+	const dg, dc = 5.0, 15.0
+	sig := make([]float64, 0, int(180/dg+1)*int(360/dc))
+	for g := 0.0; g <= 180; g += dg {
+		lo, hi := math.Max(g-dg/2, 0), math.Min(g+dg/2, 180)
+		zone := 2 * math.Pi * (math.Cos(rad(lo)) - math.Cos(rad(hi))) / (360 / dc)
+		for c := 0.0; c < 360; c += dc {
+			sig = append(sig, Intensity(lum, c, g)*zone)
+		}
+	}
+	return sig
+}
+
+// Similarity compares two signatures: the sum of the smaller of each pair
+// of samples over the sum of the larger. It is 1 for the same distribution
+// and falls both with its shape and its flux: a copy dimmed to 90% scores
+// 0.9.
+func Similarity(a, b []float64) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var lo, hi float64
+	for i := range a {
+		lo += math.Min(a[i], b[i])
+		hi += math.Max(a[i], b[i])
+	}
+	if hi <= 0 {
+		return 0
+	}
+	return lo / hi
+}
@@ -0,0 +1,108 @@
+package photometry
+
+import (
+	"math"
+
+	"illuminate/internal/database"
+)
+
+// Standard room for the tabular UGR of CIE 117/190: 4H wide, 8H deep, with
+// luminaires on a 0.25H grid at H = 2 m above the eye and reflectances of
+// 0.7 (ceiling), 0.5 (walls) and 0.2 (floor), eye height 1.2 m.
+const (
+	ugrHeight    = 2.0
+	ugrEyeHeight = 1.2
+	ugrSpacing   = 0.25 * ugrHeight
+	ugrWidth     = 4 * ugrHeight
+	ugrDepth     = 8 * ugrHeight
+
+	reflCeiling = 0.7
+	reflWalls   = 0.5
+	reflFloor   = 0.2
+)
+
+// UGR rates discomfort glare for an observer at the middle of a short wall
+// of the standard room, looking along its length with the luminaires'
+// C0–C180 axis parallel to the line of sight:
+//
+//	UGR = 8 log10(0.25/Lb · Σ L²ω/p²)
+//
+// The background luminance Lb comes from a one-bounce integrating-sphere
+// estimate of the indirect illuminance, so values are an estimate to within
+// about one UGR step of a full radiosity calculation. It needs the luminous
+// area; ok is false when lum has none.
+func UGR(lum *database.ParsedLuminaire) (ugr float64, ok bool) {
+	area := luminousArea(lum.Metadata)
+	if area <= 0 {
+		return 0, false
+	}
+	flux, down := integrate(lum)
+	if flux <= 0 {
+		return 0, false
+	}
+
+	nx := int(math.Round(ugrWidth / ugrSpacing))
+	ny := int(math.Round(ugrDepth / ugrSpacing))
+	count := float64(nx * ny)
+
+	roomHeight := ugrHeight + ugrEyeHeight
+	floorArea := ugrWidth * ugrDepth
+	wallArea := 2 * (ugrWidth + ugrDepth) * roomHeight
+	totalArea := 2*floorArea + wallArea
+	meanRefl := (floorArea*(reflCeiling+reflFloor) + wallArea*reflWalls) / totalArea
+	firstBounce := count * (down*reflFloor + (flux-down)*reflCeiling)
+	background := firstBounce / (totalArea * (1 - meanRefl)) / math.Pi
+	if background <= 0 {
+		return 0, false
+	}
+
+	sum := 0.0
+	for i := 0; i < nx; i++ {
+		t := -ugrWidth/2 + ugrSpacing/2 + float64(i)*ugrSpacing
+		for j := 0; j < ny; j++ {
+			r := ugrSpacing/2 + float64(j)*ugrSpacing
+			d2 := t*t + r*r + ugrHeight*ugrHeight
+			cosGamma := ugrHeight / math.Sqrt(d2)
+			gamma := math.Acos(cosGamma) * 180 / math.Pi
+			c := math.Atan2(-t, -r) * 180 / math.Pi
+
+			intensity := Intensity(lum, c, gamma)
+			if intensity <= 0 {
+				continue
+			}
+			projected := area * cosGamma
+			luminance := intensity / projected
+			omega := projected / d2
+			p := guthIndex(t, r, ugrHeight)
+			sum += luminance * luminance * omega / (p * p)
+		}
+	}
+	if sum <= 0 {
+		return 0, false
+	}
+	return 8 * math.Log10(0.25/background*sum), true
+}
+
+// guthIndex is the Guth position index for a source at lateral offset t,
+// forward distance r and height h relative to a horizontal line of sight,
+// in the closed form of Kim and Kim (2010).
+func guthIndex(t, r, h float64) float64 {
+	tau := math.Atan2(math.Abs(t), h) * 180 / math.Pi
+	sigma := math.Acos(r/math.Sqrt(t*t+r*r+h*h)) * 180 / math.Pi
+	lnP := (35.2-0.31889*tau-1.22*math.Exp(-2*tau/9))*1e-3*sigma +
+		(21+0.26667*tau-0.002963*tau*tau)*1e-5*sigma*sigma
+	return math.Exp(lnP)
+}
+
+// luminousArea is the area of the light-emitting opening in m²: a rectangle
+// of length × width, or a disc of diameter length when width is zero.
+func luminousArea(meta database.Luminaire) float64 {
+	switch {
+	case meta.LuminousLength <= 0:
+		return 0
+	case meta.LuminousWidth <= 0:
+		return math.Pi * meta.LuminousLength * meta.LuminousLength / 4
+	default:
+		return meta.LuminousLength * meta.LuminousWidth
+	}
+}
@@ -0,0 +1,170 @@
+// Package polar renders luminous intensity distributions as polar diagrams.
+package polar
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"math"
+
+	"illuminate/internal/database"
+	"illuminate/internal/photometry"
+)
+
+// Options controls the rendered diagram.
+type Options struct {
+	// Size is the width and height of the SVG in pixels.
+	Size int
+	// Title is drawn above the diagram when set.
+	Title string
+}
+
+func DefaultOptions() Options {
+	return Options{Size: 400}
+}
+
+// SVG draws the C0–C180 plane as a solid curve and the C90–C270 plane as a
+// dashed one, nadir pointing down, scaled to the peak intensity. Type A and B
+// photometry is drawn converted to type C, its optical axis at nadir.
+func SVG(lum *database.ParsedLuminaire, opts Options) []byte {
+	lum = photometry.ToTypeC(lum)
+	if opts.Size <= 0 {
+		opts.Size = DefaultOptions().Size
+	}
+	size := float64(opts.Size)
+	cx, cy := size/2, size/2
+	r := size/2 - 24
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="10">`+"\n",
+		opts.Size, opts.Size, opts.Size, opts.Size)
+	fmt.Fprintf(&buf, `<rect width="100%%" height="100%%" fill="#fff"/>`+"\n")
+
+	for _, f := range []float64{0.25, 0.5, 0.75, 1} {
+		fmt.Fprintf(&buf, `<circle cx="%.1f" cy="%.1f" r="%.1f" fill="none" stroke="#ddd"/>`+"\n", cx, cy, r*f)
+	}
+	for a := 0.0; a < 180; a += 30 {
+		dx, dy := r*math.Sin(rad(a)), r*math.Cos(rad(a))
+		fmt.Fprintf(&buf, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#ddd"/>`+"\n", cx-dx, cy-dy, cx+dx, cy+dy)
+	}
+
+	peak := MaxIntensity(lum)
+	if peak > 0 && len(lum.VerticalAngles) > 0 {
+		curves := []struct {
+			plane float64
+			style string
+		}{
+			{0, `stroke="#d97706" stroke-width="2"`},
+			{90, `stroke="#2563eb" stroke-width="1.5" stroke-dasharray="5,3"`},
+		}
+		for _, c := range curves {
+			fmt.Fprintf(&buf, `<path d="%s" fill="none" %s/>`+"\n", curvePath(lum, c.plane, cx, cy, r/peak), c.style)
+		}
+	}
+
+	if opts.Title != "" {
+		fmt.Fprintf(&buf, `<text x="%.1f" y="14" text-anchor="middle" font-size="12">%s</text>`+"\n", cx, html.EscapeString(opts.Title))
+	}
+	fmt.Fprintf(&buf, `<text x="4" y="%.1f" fill="#666">max %.0f cd</text>`+"\n", size-6, peak)
+	buf.WriteString("</svg>\n")
+	return buf.Bytes()
+}
+
+// ThumbnailSize is the width and height in pixels of Thumbnail.
+const ThumbnailSize = 64
+
+// Thumbnail is a compact SVG of the distribution's shape for catalog
+// listings: the two curves of SVG with an outer ring, without labels or the
+// intensity scale.
+func Thumbnail(lum *database.ParsedLuminaire) []byte {
+	lum = photometry.ToTypeC(lum)
+	c := float64(ThumbnailSize) / 2
+	r := c - 2
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`,
+		ThumbnailSize, ThumbnailSize, ThumbnailSize, ThumbnailSize)
+	fmt.Fprintf(&buf, `<circle cx="%.0f" cy="%.0f" r="%.0f" fill="#fff" stroke="#ddd"/>`, c, c, r)
+	if peak := MaxIntensity(lum); peak > 0 && len(lum.VerticalAngles) > 0 {
+		fmt.Fprintf(&buf, `<path d="%s" fill="none" stroke="#d97706" stroke-width="1.5"/>`, curvePath(lum, 0, c, c, r/peak))
+		fmt.Fprintf(&buf, `<path d="%s" fill="none" stroke="#2563eb" stroke-dasharray="3,2"/>`, curvePath(lum, 90, c, c, r/peak))
+	}
+	buf.WriteString("</svg>")
+	return buf.Bytes()
+}
+
+// curvePath traces plane+180 from zenith down to nadir on the left, then plane
+// from nadir back up to zenith on the right.
+func curvePath(lum *database.ParsedLuminaire, plane, cx, cy, scale float64) string {
+	left := PlaneIntensities(lum, plane+180)
+	right := PlaneIntensities(lum, plane)
+	v := lum.VerticalAngles
+
+	var buf bytes.Buffer
+	cmd := "M"
+	point := func(gamma, cd, side float64) {
+		x := cx + side*cd*scale*math.Sin(rad(gamma))
+		y := cy + cd*scale*math.Cos(rad(gamma))
+		fmt.Fprintf(&buf, "%s%.1f,%.1f ", cmd, x, y)
+		cmd = "L"
+	}
+	for i := len(v) - 1; i >= 0; i-- {
+		point(v[i], left[i], -1)
+	}
+	for i := range v {
+		point(v[i], right[i], 1)
+	}
+	return buf.String()
+}
+
+// PlaneIntensities returns the vertical intensity profile of the C-plane
+// closest to c, unfolding the stored symmetry (single plane, quadrant,
+// half or the 90–270 half) as needed.
+func PlaneIntensities(lum *database.ParsedLuminaire, c float64) []float64 {
+	if len(lum.CandelaMatrix) == 0 {
+		return make([]float64, len(lum.VerticalAngles))
+	}
+	h := lum.HorizontalAngles
+	idx := 0
+	if len(h) > 1 {
+		lo, hi := h[0], h[len(h)-1]
+		c = math.Mod(math.Mod(c, 360)+360, 360)
+		target := c
+		for _, cand := range []float64{c, 360 - c, 180 - c, 180 + c, c - 180} {
+			cand = math.Mod(cand+360, 360)
+			if cand >= lo-1e-9 && cand <= hi+1e-9 {
+				target = cand
+				break
+			}
+		}
+		best := math.Inf(1)
+		for i, a := range h {
+			if d := math.Abs(a - target); d < best {
+				best, idx = d, i
+			}
+		}
+	}
+	if idx >= len(lum.CandelaMatrix) {
+		idx = len(lum.CandelaMatrix) - 1
+	}
+
+	row := lum.CandelaMatrix[idx]
+	out := make([]float64, len(lum.VerticalAngles))
+	copy(out, row)
+	return out
+}
+
+// MaxIntensity is the peak candela value of the distribution.
+func MaxIntensity(lum *database.ParsedLuminaire) float64 {
+	peak := 0.0
+	for _, row := range lum.CandelaMatrix {
+		for _, v := range row {
+			peak = math.Max(peak, v)
+		}
+	}
+	return peak
+}
+
+func rad(deg float64) float64 {
+	return deg * math.Pi / 180
+}
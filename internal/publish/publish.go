@@ -0,0 +1,200 @@
+// Package publish renders the catalog into a static, read-only site that can
+// be served from any CDN or object store.
+package publish
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"illuminate/internal/database"
+	"illuminate/internal/logger"
+	"illuminate/internal/parser"
+	"illuminate/internal/polar"
+)
+
+type Options struct {
+	// Title heads the index page.
+	Title string
+	// Formats lists the download formats as file extensions without the dot.
+	Formats []string
+	// Manufacturer limits the site to one manufacturer when set.
+	Manufacturer string
+	// MinState leaves out luminaires that have not come this far along the
+	// review workflow; empty publishes every luminaire.
+	MinState database.WorkflowState
+}
+
+func DefaultOptions() Options {
+	return Options{
+		Title:   "Luminaire catalog",
+		Formats: []string{"ies", "ldt", "cie"},
+	}
+}
+
+// Entry is one luminaire as listed in catalog.json.
+type Entry struct {
+	database.Luminaire
+	Page      string            `json:"page"`
+	Data      string            `json:"data"`
+	Polar     string            `json:"polar"`
+	Downloads map[string]string `json:"downloads"`
+}
+
+// Catalog is the top-level catalog.json document.
+type Catalog struct {
+	Title      string  `json:"title"`
+	Luminaires []Entry `json:"luminaires"`
+}
+
+type luminaireDocument struct {
+	Luminaire        database.Luminaire `json:"luminaire"`
+	VerticalAngles   []float64          `json:"vertical_angles"`
+	HorizontalAngles []float64          `json:"horizontal_angles"`
+	Candela          [][]float64        `json:"candela"`
+	Polar            string             `json:"polar"`
+	Downloads        map[string]string  `json:"downloads"`
+}
+
+// Build writes the site to dir:
+//
+//	index.html                  list page
+//	catalog.json                machine-readable index
+//	luminaires/<id>/index.html  detail page
+//	luminaires/<id>/luminaire.json
+//	luminaires/<id>/polar.svg
+//	luminaires/<id>/<name>.<ext> one download per format
+func Build(db *sql.DB, dir string, opts Options) (*Catalog, error) {
+	for _, f := range opts.Formats {
+		if _, err := parser.GetParser("x." + f); err != nil {
+			return nil, err
+		}
+	}
+
+	luminaires, err := database.ListLuminaires(db)
+	if err != nil {
+		return nil, fmt.Errorf("list luminaires: %w", err)
+	}
+
+	catalog := &Catalog{Title: opts.Title, Luminaires: []Entry{}}
+	for _, meta := range luminaires {
+		if opts.Manufacturer != "" && !strings.EqualFold(meta.Manufacturer, opts.Manufacturer) {
+			continue
+		}
+		if !meta.State.AtLeast(opts.MinState) {
+			continue
+		}
+		lum, err := database.LoadParsedLuminaire(db, meta.ID)
+		if err != nil {
+			return nil, fmt.Errorf("load luminaire %d: %w", meta.ID, err)
+		}
+		entry, err := writeLuminaire(dir, lum, opts)
+		if err != nil {
+			return nil, fmt.Errorf("luminaire %d: %w", meta.ID, err)
+		}
+		catalog.Luminaires = append(catalog.Luminaires, entry)
+	}
+
+	if err := writeJSON(filepath.Join(dir, "catalog.json"), catalog); err != nil {
+		return nil, err
+	}
+	if err := writeTemplate(filepath.Join(dir, "index.html"), indexTemplate, catalog); err != nil {
+		return nil, err
+	}
+
+	logger.Default.Infof("published %d luminaires to %s", len(catalog.Luminaires), dir)
+	return catalog, nil
+}
+
+func writeLuminaire(dir string, lum *database.ParsedLuminaire, opts Options) (Entry, error) {
+	rel := filepath.ToSlash(filepath.Join("luminaires", strconv.FormatInt(lum.Metadata.ID, 10)))
+	lumDir := filepath.Join(dir, rel)
+	if err := os.MkdirAll(lumDir, 0o755); err != nil {
+		return Entry{}, err
+	}
+
+	title := strings.TrimSpace(lum.Metadata.Manufacturer + " " + lum.Metadata.Model)
+	svg := polar.SVG(lum, polar.Options{Size: 400, Title: title})
+	if err := os.WriteFile(filepath.Join(lumDir, "polar.svg"), svg, 0o644); err != nil {
+		return Entry{}, err
+	}
+
+	base := fileStem(lum.Metadata)
+	downloads := map[string]string{}
+	for _, f := range opts.Formats {
+		name := base + "." + f
+		p, _ := parser.GetParser(name)
+		writeOpts := parser.DefaultWriteOptions()
+		writeOpts.Provenance = parser.NewProvenance(lum.Metadata.FileHash)
+		if err := parser.WriteFile(p, lum, filepath.Join(lumDir, name), writeOpts); err != nil {
+			return Entry{}, fmt.Errorf("write %s: %w", f, err)
+		}
+		downloads[f] = name
+	}
+
+	doc := luminaireDocument{
+		Luminaire:        lum.Metadata,
+		VerticalAngles:   lum.VerticalAngles,
+		HorizontalAngles: lum.HorizontalAngles,
+		Candela:          lum.CandelaMatrix,
+		Polar:            "polar.svg",
+		Downloads:        downloads,
+	}
+	if err := writeJSON(filepath.Join(lumDir, "luminaire.json"), doc); err != nil {
+		return Entry{}, err
+	}
+	if err := writeTemplate(filepath.Join(lumDir, "index.html"), detailTemplate, doc); err != nil {
+		return Entry{}, err
+	}
+
+	entry := Entry{
+		Luminaire: lum.Metadata,
+		Page:      rel + "/index.html",
+		Data:      rel + "/luminaire.json",
+		Polar:     rel + "/polar.svg",
+		Downloads: map[string]string{},
+	}
+	for f, name := range downloads {
+		entry.Downloads[f] = rel + "/" + name
+	}
+	return entry, nil
+}
+
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// fileStem names downloads after manufacturer and model, falling back to the
+// luminaire id.
+func fileStem(lum database.Luminaire) string {
+	stem := unsafeChars.ReplaceAllString(strings.TrimSpace(lum.Manufacturer+"_"+lum.Model), "-")
+	stem = strings.Trim(stem, "-_.")
+	if stem == "" {
+		stem = fmt.Sprintf("luminaire_%d", lum.ID)
+	}
+	return stem
+}
+
+func writeJSON(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+func writeTemplate(path string, t *template.Template, data any) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := t.Execute(f, data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
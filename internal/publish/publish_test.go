@@ -0,0 +1,107 @@
+package publish
+
+import (
+	"database/sql"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"illuminate/internal/database"
+)
+
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "catalog.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrations, _ := filepath.Glob("../database/migrations/*.sql")
+	for _, m := range migrations {
+		content, err := os.ReadFile(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(string(content)); err != nil {
+			t.Fatalf("%s: %v", m, err)
+		}
+	}
+	return db
+}
+
+func insertLuminaire(t *testing.T, db *sql.DB, manufacturer, model string) {
+	t.Helper()
+	res, err := db.Exec(`INSERT INTO luminaires (manufacturer, model, file_hash, luminous_flux)
+		VALUES (?, ?, ?, 1000)`, manufacturer, model, manufacturer+model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := res.LastInsertId()
+	_, err = db.Exec(`INSERT INTO photometric_data (luminaire_id, vertical_angles, horizontal_angles, candela_values)
+		VALUES (?, ?, ?, ?)`, id, database.EncodeAngles([]float64{0, 45, 90}), database.EncodeAngles([]float64{0}), "300.00,200.00,0.00")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBuildWritesStaticSite(t *testing.T) {
+	db := testDB(t)
+	insertLuminaire(t, db, "Acme", "Downlight 10")
+	insertLuminaire(t, db, "Other", "Panel")
+
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.Manufacturer = "acme"
+	catalog, err := Build(db, dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(catalog.Luminaires) != 1 {
+		t.Fatalf("published %d luminaires, want 1", len(catalog.Luminaires))
+	}
+
+	entry := catalog.Luminaires[0]
+	for _, rel := range []string{"index.html", "catalog.json", entry.Page, entry.Data, entry.Polar,
+		entry.Downloads["ies"], entry.Downloads["ldt"], entry.Downloads["cie"]} {
+		if _, err := os.Stat(filepath.Join(dir, rel)); err != nil {
+			t.Errorf("missing %s: %v", rel, err)
+		}
+	}
+	if entry.Downloads["ies"] != "luminaires/1/Acme_Downlight-10.ies" {
+		t.Errorf("ies download = %s", entry.Downloads["ies"])
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, entry.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc luminaireDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.VerticalAngles) != 3 || len(doc.Candela) != 1 || doc.Candela[0][0] != 300 {
+		t.Errorf("unexpected photometry in luminaire.json: %+v", doc)
+	}
+}
+
+func TestBuildMinState(t *testing.T) {
+	db := testDB(t)
+	insertLuminaire(t, db, "Acme", "Downlight 10")
+	insertLuminaire(t, db, "Acme", "Downlight 20")
+	if _, err := db.Exec(`UPDATE luminaires SET workflow_state = 'in_review' WHERE model = 'Downlight 20'`); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := DefaultOptions()
+	opts.MinState = database.StateApproved
+	catalog, err := Build(db, t.TempDir(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(catalog.Luminaires) != 1 || catalog.Luminaires[0].Model != "Downlight 10" {
+		t.Errorf("published %+v, want only Downlight 10", catalog.Luminaires)
+	}
+}
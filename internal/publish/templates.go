@@ -0,0 +1,82 @@
+package publish
+
+import "html/template"
+
+const pageStyle = `
+body { font-family: system-ui, sans-serif; margin: 2rem auto; max-width: 960px; color: #1f2937; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: .4rem .6rem; border-bottom: 1px solid #e5e7eb; }
+a { color: #b45309; }
+dl { display: grid; grid-template-columns: max-content 1fr; gap: .25rem 1rem; }
+dt { color: #6b7280; }
+`
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>` + pageStyle + `</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p><a href="catalog.json">catalog.json</a></p>
+<table>
+<thead><tr><th>Manufacturer</th><th>Model</th><th>Catalog number</th><th>Flux (lm)</th><th>Power (W)</th><th>Downloads</th></tr></thead>
+<tbody>
+{{- range .Luminaires}}
+<tr>
+<td>{{.Manufacturer}}</td>
+<td><a href="{{.Page}}">{{.Model}}</a></td>
+<td>{{.CatalogNumber}}</td>
+<td>{{printf "%.0f" .LuminousFlux}}</td>
+<td>{{printf "%.1f" .InputWatts}}</td>
+<td>{{range $f, $path := .Downloads}}<a href="{{$path}}" download>{{$f}}</a> {{end}}</td>
+</tr>
+{{- end}}
+</tbody>
+</table>
+</body>
+</html>
+`))
+
+var detailTemplate = template.Must(template.New("detail").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Luminaire.Manufacturer}} {{.Luminaire.Model}}</title>
+<style>` + pageStyle + `</style>
+</head>
+<body>
+<p><a href="../../index.html">&larr; Catalog</a></p>
+<h1>{{.Luminaire.Manufacturer}} {{.Luminaire.Model}}</h1>
+<img src="{{.Polar}}" alt="Polar intensity diagram" width="400" height="400">
+<dl>
+<dt>Catalog number</dt><dd>{{.Luminaire.CatalogNumber}}</dd>
+<dt>Description</dt><dd>{{.Luminaire.LuminaireDesc}}</dd>
+<dt>Lamp</dt><dd>{{.Luminaire.LampType}}</dd>
+<dt>Luminous flux</dt><dd>{{printf "%.0f" .Luminaire.LuminousFlux}} lm</dd>
+<dt>Input power</dt><dd>{{printf "%.1f" .Luminaire.InputWatts}} W</dd>
+{{- with .Luminaire.THD}}
+<dt>THD</dt><dd>{{printf "%g" .}} %</dd>
+{{- end}}
+{{- with .Luminaire.InrushCurrent}}
+<dt>Inrush current</dt><dd>{{printf "%g" .}} A</dd>
+{{- end}}
+{{- with .Luminaire.Frequency}}
+<dt>Frequency</dt><dd>{{printf "%g" .}} Hz</dd>
+{{- end}}
+<dt>Test lab</dt><dd>{{.Luminaire.TestLab}}</dd>
+<dt>Test number</dt><dd>{{.Luminaire.TestNumber}}</dd>
+<dt>Issue date</dt><dd>{{.Luminaire.IssueDate}}</dd>
+</dl>
+<h2>Downloads</h2>
+<ul>
+{{- range $f, $name := .Downloads}}
+<li><a href="{{$name}}" download>{{$name}}</a></li>
+{{- end}}
+<li><a href="luminaire.json">luminaire.json</a></li>
+</ul>
+</body>
+</html>
+`))
@@ -0,0 +1,166 @@
+package qr
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestReedSolomon(t *testing.T) {
+	// The 1-M "HELLO WORLD" example worked through in most QR references.
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if got := reedSolomon(data, 10); !bytes.Equal(got, want) {
+		t.Errorf("ec = %v, want %v", got, want)
+	}
+}
+
+func TestFormatAndVersionBits(t *testing.T) {
+	for mask, want := range map[int]int{0: 0b101010000010010, 1: 0b101000100100101, 6: 0b100111110010111, 7: 0b100101010100000} {
+		if got := formatBits(mask); got != want {
+			t.Errorf("format bits for M/%d = %015b, want %015b", mask, got, want)
+		}
+	}
+	if got := versionBits(7); got != 0x07C94 {
+		t.Errorf("version 7 bits = %#x", got)
+	}
+}
+
+// decode reads c back the way a scanner would once it has sampled the
+// grid: format, mask, codewords, error correction and the byte segment.
+func decode(t *testing.T, c *Code) []byte {
+	t.Helper()
+	var format int
+	for i := 14; i >= 9; i-- {
+		format = format<<1 | bit(c.Dark(14-i, 8))
+	}
+	format = format<<1 | bit(c.Dark(7, 8))
+	format = format<<1 | bit(c.Dark(8, 8))
+	format = format<<1 | bit(c.Dark(8, 7))
+	for i := 5; i >= 0; i-- {
+		format = format<<1 | bit(c.Dark(8, i))
+	}
+	mask := -1
+	for m := 0; m < 8; m++ {
+		if formatBits(m) == format {
+			mask = m
+		}
+	}
+	if mask < 0 {
+		t.Fatalf("format bits %015b are not level M", format)
+	}
+
+	c.applyMask(mask)
+	defer c.applyMask(mask)
+	var bits bitBuffer
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert
+				}
+				if !c.isFunc[y*c.Size+x] {
+					bits = append(bits, c.Dark(x, y))
+				}
+			}
+		}
+	}
+	raw := bits.bytes()
+
+	spec := levelM[c.Version]
+	n := spec.blocks1 + spec.blocks2
+	blocks := make([][]byte, n)
+	pos := 0
+	for i := 0; i < max(spec.data1, spec.data2); i++ {
+		for b := range blocks {
+			if i < spec.data1 || b >= spec.blocks1 {
+				blocks[b] = append(blocks[b], raw[pos])
+				pos++
+			}
+		}
+	}
+	var data []byte
+	for b, block := range blocks {
+		ec := make([]byte, spec.ec)
+		for i := range ec {
+			ec[i] = raw[pos+i*n+b]
+		}
+		if !bytes.Equal(reedSolomon(block, spec.ec), ec) {
+			t.Fatalf("block %d fails error correction", b)
+		}
+		data = append(data, block...)
+	}
+
+	if data[0]>>4 != 0b0100 {
+		t.Fatalf("mode %04b, want byte mode", data[0]>>4)
+	}
+	var length, start int
+	if c.Version < 10 {
+		length = int(data[0]&0xF)<<4 | int(data[1]>>4)
+		start = 1
+	} else {
+		length = int(data[0]&0xF)<<12 | int(data[1])<<4 | int(data[2]>>4)
+		start = 2
+	}
+	out := make([]byte, length)
+	for i := range out {
+		out[i] = data[start+i]<<4 | data[start+i+1]>>4
+	}
+	return out
+}
+
+func bit(dark bool) int {
+	if dark {
+		return 1
+	}
+	return 0
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	for _, s := range []string{
+		"https://lab.example.com/luminaires/1",
+		"https://lab.example.com/luminaires/123456?share=" + strings.Repeat("x", 90),
+		strings.Repeat("0123456789", 21),
+	} {
+		c, err := Encode([]byte(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Size != 17+4*c.Version {
+			t.Errorf("size %d for version %d", c.Size, c.Version)
+		}
+		if !c.Dark(0, 0) || !c.Dark(c.Size-1, 0) || !c.Dark(0, c.Size-1) || c.Dark(7, 7) || !c.Dark(8, c.Size-8) {
+			t.Errorf("version %d: finder patterns or dark module missing", c.Version)
+		}
+		if got := decode(t, c); string(got) != s {
+			t.Errorf("version %d decodes to %q", c.Version, got)
+		}
+	}
+
+	if _, err := Encode(make([]byte, 214)); err != ErrTooLong {
+		t.Errorf("214 bytes: %v", err)
+	}
+}
+
+func TestWritePNG(t *testing.T) {
+	c, err := Encode([]byte("https://lab.example.com/luminaires/1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := c.WritePNG(&buf, 4); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if side := (c.Size + 2*QuietZone) * 4; img.Bounds().Dx() != side {
+		t.Errorf("width %d, want %d", img.Bounds().Dx(), side)
+	}
+}
@@ -0,0 +1,103 @@
+// Package revit describes luminaires as Revit lighting fixture families take
+// them: type parameters in a type catalog, the comma separated TXT Revit
+// reads next to a family when it is loaded, and the same parameters as JSON
+// for scripts that set them through the Revit API.
+package revit
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strconv"
+
+	"illuminate/internal/database"
+)
+
+// Parameter is one type parameter. DataType and Unit are the ones the type
+// catalog header spells out, as in "Wattage##electrical_power##watts"; text
+// parameters have the data type other and no unit. Value is nil when the
+// luminaire does not say.
+type Parameter struct {
+	Name     string      `json:"name"`
+	DataType string      `json:"data_type"`
+	Unit     string      `json:"unit,omitempty"`
+	Value    interface{} `json:"value"`
+}
+
+// Type is one family type: its name and its parameters.
+type Type struct {
+	Name       string      `json:"type_name"`
+	Parameters []Parameter `json:"parameters"`
+}
+
+// NewType describes meta as a type called name whose distribution is the
+// photometric web file webFile, the IES file bundled next to the catalog.
+// Every luminaire yields the same parameters in the same order, so types
+// of one catalog line up.
+func NewType(name string, meta database.Luminaire, webFile string) Type {
+	text := func(name, v string) Parameter {
+		p := Parameter{Name: name, DataType: "other"}
+		if v != "" {
+			p.Value = v
+		}
+		return p
+	}
+	number := func(name, dataType, unit string, v float64) Parameter {
+		p := Parameter{Name: name, DataType: dataType, Unit: unit}
+		if v > 0 {
+			p.Value = v
+		}
+		return p
+	}
+	// Openings are stored in metres; families are drawn in millimetres.
+	mm := func(m float64) float64 { return m * 1000 }
+	return Type{Name: name, Parameters: []Parameter{
+		text("Manufacturer", meta.Manufacturer),
+		text("Model", meta.Model),
+		text("Catalog Number", meta.CatalogNumber),
+		text("Description", meta.LuminaireDesc),
+		text("Photometric Web File", webFile),
+		number("Wattage", "electrical_power", "watts", meta.InputWatts),
+		number("Luminous Flux", "electrical_luminous_flux", "lumens", meta.LuminousFlux),
+		number("Color Temperature", "color_temperature", "kelvin", float64(meta.ColorTemp)),
+		number("CRI", "other", "", float64(meta.CRI)),
+		number("Luminous Length", "length", "millimeters", mm(meta.LuminousLength)),
+		number("Luminous Width", "length", "millimeters", mm(meta.LuminousWidth)),
+		number("Luminous Height", "length", "millimeters", mm(meta.LuminousHeight())),
+	}}
+}
+
+// TypeCatalog writes types as a Revit type catalog: a header naming each
+// parameter with its data type and unit, then one row per type. Unknown
+// values are left empty, so the family keeps its own. Lines end in CRLF as
+// Revit writes them.
+func TypeCatalog(types []Type) []byte {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.UseCRLF = true
+	if len(types) > 0 {
+		header := []string{""}
+		for _, p := range types[0].Parameters {
+			header = append(header, p.Name+"##"+p.DataType+"##"+p.Unit)
+		}
+		w.Write(header)
+	}
+	for _, t := range types {
+		row := []string{t.Name}
+		for _, p := range t.Parameters {
+			row = append(row, format(p.Value))
+		}
+		w.Write(row)
+	}
+	w.Flush()
+	return buf.Bytes()
+}
+
+func format(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
@@ -0,0 +1,42 @@
+package revit
+
+import (
+	"strings"
+	"testing"
+
+	"illuminate/internal/database"
+)
+
+func TestTypeCatalog(t *testing.T) {
+	meta := database.Luminaire{
+		Manufacturer:   "Acme",
+		Model:          "Linear, 1.2 m",
+		InputWatts:     38,
+		LuminousFlux:   4200.5,
+		ColorTemp:      4000,
+		CRI:            90,
+		LuminousLength: 1.2,
+		LuminousWidth:  0.05,
+	}
+	types := []Type{NewType("L1200", meta, "l1200.ies"), NewType("Empty", database.Luminaire{}, "empty.ies")}
+	rows := strings.Split(string(TypeCatalog(types)), "\r\n")
+	want := []string{
+		",Manufacturer##other##,Model##other##,Catalog Number##other##,Description##other##,Photometric Web File##other##," +
+			"Wattage##electrical_power##watts,Luminous Flux##electrical_luminous_flux##lumens,Color Temperature##color_temperature##kelvin," +
+			"CRI##other##,Luminous Length##length##millimeters,Luminous Width##length##millimeters,Luminous Height##length##millimeters",
+		`L1200,Acme,"Linear, 1.2 m",,,l1200.ies,38,4200.5,4000,90,1200,50,`,
+		"Empty,,,,,empty.ies,,,,,,,",
+		"",
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("catalog = %q", rows)
+	}
+	for i := range want {
+		if rows[i] != want[i] {
+			t.Errorf("row %d = %q, want %q", i, rows[i], want[i])
+		}
+	}
+	if len(TypeCatalog(nil)) != 0 {
+		t.Error("an empty catalog has content")
+	}
+}
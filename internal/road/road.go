@@ -0,0 +1,263 @@
+// Package road estimates how a row of identical luminaires lights a straight
+// road and checks the result against the M (luminance) and P (illuminance)
+// classes of EN 13201-2. It is a feasibility check, not a design
+// calculation: see Check for what it simplifies.
+package road
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+
+	"illuminate/internal/database"
+	"illuminate/internal/photometry"
+)
+
+// Arrangements of the luminaires along the road.
+const (
+	// Single puts every luminaire on the near side.
+	Single = "single"
+	// Opposite pairs luminaires across the road.
+	Opposite = "opposite"
+	// Staggered alternates sides, each side at the full spacing.
+	Staggered = "staggered"
+)
+
+// Geometry is the road and the luminaire layout. Lengths are in metres.
+type Geometry struct {
+	Lanes     int     `json:"lanes"`
+	LaneWidth float64 `json:"lane_width"`
+	// Spacing is the distance between luminaires on the same side.
+	Spacing        float64 `json:"spacing"`
+	MountingHeight float64 `json:"mounting_height"`
+	// Overhang is how far the luminaire sits over the carriageway from
+	// the kerb; negative when it stands behind it.
+	Overhang    float64 `json:"overhang"`
+	Arrangement string  `json:"arrangement"`
+	// MaintenanceFactor scales the new installation down to the maintained
+	// values the classes prescribe.
+	MaintenanceFactor float64 `json:"maintenance_factor"`
+	// Q0 is the average luminance coefficient of the road surface in
+	// cd/m² per lx; 0.07 is the standard dry surface R3 of CIE 144.
+	Q0 float64 `json:"q0"`
+}
+
+// DefaultGeometry is a two-lane road lit from one side by 8 m poles 30 m
+// apart.
+func DefaultGeometry() Geometry {
+	return Geometry{
+		Lanes:             2,
+		LaneWidth:         3.5,
+		Spacing:           30,
+		MountingHeight:    8,
+		Arrangement:       Single,
+		MaintenanceFactor: 0.8,
+		Q0:                0.07,
+	}
+}
+
+// Validate rejects geometries Check cannot evaluate.
+func (g Geometry) Validate() error {
+	switch {
+	case g.Lanes < 1 || g.Lanes > 8:
+		return errors.New("lanes must be 1 to 8")
+	case !(g.LaneWidth > 0 && g.LaneWidth <= 10):
+		return errors.New("lane_width must be above 0 and up to 10 m")
+	case !(g.Spacing > 0 && g.Spacing <= 200):
+		return errors.New("spacing must be above 0 and up to 200 m")
+	case !(g.MountingHeight > 0 && g.MountingHeight <= 50):
+		return errors.New("mounting_height must be above 0 and up to 50 m")
+	case math.IsNaN(g.Overhang) || math.Abs(g.Overhang) > float64(g.Lanes)*g.LaneWidth:
+		return errors.New("overhang must not exceed the carriageway width")
+	case !(g.MaintenanceFactor > 0 && g.MaintenanceFactor <= 1):
+		return errors.New("maintenance_factor must be above 0 and up to 1")
+	case !(g.Q0 > 0 && g.Q0 <= 1):
+		return errors.New("q0 must be above 0 and up to 1")
+	}
+	if !slices.Contains([]string{Single, Opposite, Staggered}, g.Arrangement) {
+		return fmt.Errorf("arrangement must be %s, %s or %s", Single, Opposite, Staggered)
+	}
+	return nil
+}
+
+// Width is the carriageway width.
+func (g Geometry) Width() float64 {
+	return float64(g.Lanes) * g.LaneWidth
+}
+
+// Class is one lighting class of EN 13201-2 with its maintained minimums.
+type Class struct {
+	Name string
+	// Luminance, Uo and Ul are the minimum average luminance in cd/m²
+	// and overall and longitudinal uniformities of an M class.
+	Luminance, Uo, Ul float64
+	// Illuminance and MinIlluminance are the minimum average and point
+	// illuminance in lx of a P class.
+	Illuminance, MinIlluminance float64
+}
+
+// MClasses and PClasses are the classes of EN 13201-2:2015, strictest first.
+var (
+	MClasses = []Class{
+		{Name: "M1", Luminance: 2.0, Uo: 0.40, Ul: 0.70},
+		{Name: "M2", Luminance: 1.5, Uo: 0.40, Ul: 0.70},
+		{Name: "M3", Luminance: 1.0, Uo: 0.40, Ul: 0.60},
+		{Name: "M4", Luminance: 0.75, Uo: 0.40, Ul: 0.60},
+		{Name: "M5", Luminance: 0.5, Uo: 0.35, Ul: 0.40},
+		{Name: "M6", Luminance: 0.3, Uo: 0.35, Ul: 0.40},
+	}
+	PClasses = []Class{
+		{Name: "P1", Illuminance: 15, MinIlluminance: 3.0},
+		{Name: "P2", Illuminance: 10, MinIlluminance: 2.0},
+		{Name: "P3", Illuminance: 7.5, MinIlluminance: 1.5},
+		{Name: "P4", Illuminance: 5.0, MinIlluminance: 1.0},
+		{Name: "P5", Illuminance: 3.0, MinIlluminance: 0.6},
+		{Name: "P6", Illuminance: 2.0, MinIlluminance: 0.4},
+	}
+)
+
+// Result is the estimated maintained lighting of the road.
+type Result struct {
+	Geometry Geometry `json:"geometry"`
+	// AverageIlluminance and MinIlluminance are in lx over the
+	// carriageway between two luminaires of one side.
+	AverageIlluminance float64 `json:"average_illuminance"`
+	MinIlluminance     float64 `json:"min_illuminance"`
+	// AverageLuminance is estimated as Q0 × AverageIlluminance, in cd/m².
+	AverageLuminance float64 `json:"average_luminance"`
+	// OverallUniformity is Uo, minimum over average; LongitudinalUniformity
+	// is Ul, the least minimum over maximum along a lane centre line.
+	OverallUniformity      float64 `json:"overall_uniformity"`
+	LongitudinalUniformity float64 `json:"longitudinal_uniformity"`
+	// MClass and PClass are the strictest classes met, "" when none is.
+	MClass string `json:"m_class"`
+	PClass string `json:"p_class"`
+	// Met lists every class met.
+	Met []string `json:"met"`
+}
+
+// Check lays lum out along the road as g describes and computes the
+// horizontal illuminance on the grid of EN 13201-3: at least 10 points
+// along the road between two luminaires, at most 3 m apart, and at least 3
+// across it, at most 1.5 m apart, counting luminaires within five mounting
+// heights. The luminaires face the road with C90 across it and C0 along it.
+//
+// Luminance is not computed from road reflection tables: the surface is
+// taken as diffuse, so the average is Q0 times the illuminance and the
+// uniformities follow the illuminance. Specular surfaces and the threshold
+// increment (glare) that M classes also limit are not assessed, so an M
+// class met here still needs a full calculation.
+func Check(lum *database.ParsedLuminaire, g Geometry) (Result, error) {
+	if err := g.Validate(); err != nil {
+		return Result{}, err
+	}
+	lum = photometry.ToTypeC(lum)
+	width := g.Width()
+	sources := luminairePositions(g)
+
+	nx := max(10, int(math.Ceil(g.Spacing/3)))
+	ny := max(3, int(math.Ceil(width/1.5)))
+	sum, lowest := 0.0, math.Inf(1)
+	for i := 0; i < nx; i++ {
+		x := (float64(i) + 0.5) * g.Spacing / float64(nx)
+		for j := 0; j < ny; j++ {
+			y := (float64(j) + 0.5) * width / float64(ny)
+			e := illuminanceAt(lum, sources, x, y, g.MountingHeight) * g.MaintenanceFactor
+			sum += e
+			lowest = math.Min(lowest, e)
+		}
+	}
+
+	r := Result{
+		Geometry:               g,
+		AverageIlluminance:     sum / float64(nx*ny),
+		MinIlluminance:         lowest,
+		LongitudinalUniformity: 1,
+	}
+	r.AverageLuminance = g.Q0 * r.AverageIlluminance
+	if r.AverageIlluminance > 0 {
+		r.OverallUniformity = r.MinIlluminance / r.AverageIlluminance
+	}
+	for lane := 0; lane < g.Lanes; lane++ {
+		y := (float64(lane) + 0.5) * g.LaneWidth
+		lo, hi := math.Inf(1), 0.0
+		for i := 0; i < nx; i++ {
+			x := (float64(i) + 0.5) * g.Spacing / float64(nx)
+			e := illuminanceAt(lum, sources, x, y, g.MountingHeight)
+			lo, hi = math.Min(lo, e), math.Max(hi, e)
+		}
+		ul := 0.0
+		if hi > 0 {
+			ul = lo / hi
+		}
+		r.LongitudinalUniformity = math.Min(r.LongitudinalUniformity, ul)
+	}
+
+	r.Met = []string{}
+	for _, c := range MClasses {
+		if r.AverageLuminance >= c.Luminance && r.OverallUniformity >= c.Uo && r.LongitudinalUniformity >= c.Ul {
+			r.Met = append(r.Met, c.Name)
+			if r.MClass == "" {
+				r.MClass = c.Name
+			}
+		}
+	}
+	for _, c := range PClasses {
+		if r.AverageIlluminance >= c.Illuminance && r.MinIlluminance >= c.MinIlluminance {
+			r.Met = append(r.Met, c.Name)
+			if r.PClass == "" {
+				r.PClass = c.Name
+			}
+		}
+	}
+	return r, nil
+}
+
+// source is one luminaire: its position and which side it faces from,
+// +1 for the near side (facing +y) and -1 for the far side.
+type source struct {
+	x, y   float64
+	facing float64
+}
+
+// luminairePositions places every luminaire within five mounting heights of
+// the field between x = 0 and x = Spacing. The carriageway runs from y = 0
+// at the near kerb to y = Width.
+func luminairePositions(g Geometry) []source {
+	reach := int(math.Ceil(5*g.MountingHeight/g.Spacing)) + 1
+	var sources []source
+	for k := -reach; k <= reach+1; k++ {
+		x := float64(k) * g.Spacing
+		sources = append(sources, source{x, g.Overhang, 1})
+		switch g.Arrangement {
+		case Opposite:
+			sources = append(sources, source{x, g.Width() - g.Overhang, -1})
+		case Staggered:
+			sources = append(sources, source{x + g.Spacing/2, g.Width() - g.Overhang, -1})
+		}
+	}
+	return sources
+}
+
+// illuminanceAt sums the horizontal illuminance in lx at road point (x, y)
+// from every source at height h: E = I cos³γ / h².
+func illuminanceAt(lum *database.ParsedLuminaire, sources []source, x, y, h float64) float64 {
+	reach := 5 * h
+	e := 0.0
+	for _, s := range sources {
+		dx, dy := (x-s.x)*s.facing, (y-s.y)*s.facing
+		if math.Abs(x-s.x) > reach {
+			continue
+		}
+		d := math.Hypot(dx, dy)
+		gamma := math.Atan2(d, h)
+		c := math.Atan2(dy, dx) * 180 / math.Pi
+		if c < 0 {
+			c += 360
+		}
+		cos := math.Cos(gamma)
+		e += photometry.Intensity(lum, c, gamma*180/math.Pi) * cos * cos * cos / (h * h)
+	}
+	return e
+}
@@ -0,0 +1,82 @@
+package road
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"illuminate/internal/synth"
+)
+
+func TestIlluminanceAt(t *testing.T) {
+	lum, err := synth.Generate(synth.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A Lambertian source: I(γ) = I0 cos γ, so E = I0 cos⁴γ / h².
+	i0 := lum.CandelaMatrix[0][0]
+	sources := []source{{0, 0, 1}}
+	for _, d := range []float64{0, 4, 8} {
+		cos := 8 / math.Hypot(d, 8)
+		want := i0 * math.Pow(cos, 4) / 64
+		if got := illuminanceAt(lum, sources, d, 0, 8); math.Abs(got-want) > 0.01*want {
+			t.Errorf("%g m from the pole: E = %.3f lx, want %.3f", d, got, want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	opts := synth.DefaultOptions()
+	opts.Distribution = synth.Street
+	opts.Flux = 12000
+	lum, err := synth.Generate(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := DefaultGeometry()
+	single, err := Check(lum, g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if single.AverageIlluminance <= 0 || single.MinIlluminance > single.AverageIlluminance {
+		t.Fatalf("result = %+v", single)
+	}
+	if single.OverallUniformity <= 0 || single.OverallUniformity > 1 || single.LongitudinalUniformity <= 0 || single.LongitudinalUniformity > 1 {
+		t.Errorf("uniformities Uo %.2f, Ul %.2f", single.OverallUniformity, single.LongitudinalUniformity)
+	}
+	if math.Abs(single.AverageLuminance-0.07*single.AverageIlluminance) > 1e-9 {
+		t.Errorf("luminance %.3f for %.3f lx", single.AverageLuminance, single.AverageIlluminance)
+	}
+
+	g.Arrangement = Opposite
+	opposite, err := Check(lum, g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opposite.AverageIlluminance < 1.9*single.AverageIlluminance {
+		t.Errorf("opposite %.1f lx, single %.1f lx", opposite.AverageIlluminance, single.AverageIlluminance)
+	}
+
+	// Every class met implies the laxer ones of its kind.
+	for _, classes := range [][]Class{MClasses, PClasses} {
+		for i, c := range classes {
+			if slices.Contains(opposite.Met, c.Name) {
+				for _, laxer := range classes[i:] {
+					if !slices.Contains(opposite.Met, laxer.Name) {
+						t.Errorf("%s met but %s not: %v", c.Name, laxer.Name, opposite.Met)
+					}
+				}
+				break
+			}
+		}
+	}
+	if opposite.PClass == "" || !slices.Contains(opposite.Met, opposite.PClass) {
+		t.Errorf("P class %q of %v at %.1f lx, min %.1f", opposite.PClass, opposite.Met, opposite.AverageIlluminance, opposite.MinIlluminance)
+	}
+
+	g.Arrangement = "zigzag"
+	if _, err := Check(lum, g); err == nil {
+		t.Error("unknown arrangement accepted")
+	}
+}
@@ -0,0 +1,37 @@
+// Package samples bundles a small catalog of luminaires in every format the
+// catalog reads (IES, EULUMDAT, CIE, OXL and TM-14), with a manifest that
+// fills in what the files leave out, so demos and integration tests have
+// realistic data without uploading anything.
+package samples
+
+import (
+	"embed"
+	"fmt"
+
+	"illuminate/internal/manifest"
+)
+
+//go:embed data
+var files embed.FS
+
+// ManifestName is the manifest among the bundled files, in the layout
+// POST /api/v1/luminaires/import takes.
+const ManifestName = "manifest.csv"
+
+// Manifest returns the rows of the manifest, one per sample file.
+func Manifest() ([]manifest.Row, error) {
+	data, err := File(ManifestName)
+	if err != nil {
+		return nil, err
+	}
+	return manifest.Read(ManifestName, data)
+}
+
+// File returns the bundled file called name.
+func File(name string) ([]byte, error) {
+	data, err := files.ReadFile("data/" + name)
+	if err != nil {
+		return nil, fmt.Errorf("no sample file %s", name)
+	}
+	return data, nil
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+var startedAt = time.Now()
+
+// registerAdminRoutes mounts the diagnostics endpoints and catalog jobs behind
+// a bearer token. Without ADMIN_TOKEN they are not registered at all; pprof
+// additionally needs ENABLE_PPROF=true.
+func (s *Server) registerAdminRoutes(e *echo.Echo, lumHandler *LuminaireHandler) {
+	if s.adminToken == "" {
+		return
+	}
+
+	auth := middleware.KeyAuthWithConfig(middleware.KeyAuthConfig{
+		Validator: func(key string, c echo.Context) (bool, error) {
+			return subtle.ConstantTimeCompare([]byte(key), []byte(s.adminToken)) == 1, nil
+		},
+	})
+
+	admin := e.Group("/api/v1/admin", auth)
+	admin.GET("/stats", s.runtimeStatsHandler)
+	admin.GET("/recompute", lumHandler.ListRecomputeRuns)
+	admin.POST("/recompute", lumHandler.StartRecomputeRun)
+	admin.GET("/recompute/:id", lumHandler.GetRecomputeRun)
+	admin.GET("/features", lumHandler.ListFeatures)
+	admin.PUT("/features/:name", lumHandler.PutFeature)
+	admin.DELETE("/features/:name", lumHandler.DeleteFeature)
+
+	if s.enablePprof {
+		debug := e.Group("/debug/pprof", auth)
+		debug.GET("/cmdline", echo.WrapHandler(http.HandlerFunc(pprof.Cmdline)))
+		debug.GET("/profile", echo.WrapHandler(http.HandlerFunc(pprof.Profile)))
+		debug.GET("/symbol", echo.WrapHandler(http.HandlerFunc(pprof.Symbol)))
+		debug.POST("/symbol", echo.WrapHandler(http.HandlerFunc(pprof.Symbol)))
+		debug.GET("/trace", echo.WrapHandler(http.HandlerFunc(pprof.Trace)))
+		// Index also serves the named profiles: heap, goroutine, allocs, ...
+		debug.GET("/*", echo.WrapHandler(http.HandlerFunc(pprof.Index)))
+	}
+}
+
+func (s *Server) runtimeStatsHandler(c echo.Context) error {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	hits, misses := s.parseCache.Stats()
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"uptime_seconds": int64(time.Since(startedAt).Seconds()),
+		"goroutines":     runtime.NumGoroutine(),
+		"heap": map[string]interface{}{
+			"alloc_bytes":    mem.HeapAlloc,
+			"inuse_bytes":    mem.HeapInuse,
+			"sys_bytes":      mem.HeapSys,
+			"objects":        mem.HeapObjects,
+			"total_alloc":    mem.TotalAlloc,
+			"num_gc":         mem.NumGC,
+			"gc_pause_total": time.Duration(mem.PauseTotalNs).String(),
+		},
+		"jobs": map[string]interface{}{
+			"workers":     s.pool.Size(),
+			"queue_depth": s.pool.QueueDepth(),
+			"running":     s.pool.Running(),
+		},
+		"parse_cache": map[string]interface{}{
+			"hits":   hits,
+			"misses": misses,
+		},
+		"validation_cache": map[string]interface{}{
+			"hits":   validationCacheHits.Load(),
+			"misses": validationCacheMisses.Load(),
+		},
+	})
+}
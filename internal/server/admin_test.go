@@ -0,0 +1,61 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/worker"
+)
+
+func TestRuntimeStatsRequiresAdminToken(t *testing.T) {
+	pool := worker.NewPool(2, 4)
+	defer pool.Close()
+
+	e := echo.New()
+	s := &Server{adminToken: "secret", pool: pool}
+	s.registerAdminRoutes(e, nil)
+
+	for _, tc := range []struct {
+		auth string
+		want int
+	}{
+		{"", http.StatusBadRequest},
+		{"Bearer wrong", http.StatusUnauthorized},
+		{"Bearer secret", http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/stats", nil)
+		if tc.auth != "" {
+			req.Header.Set(echo.HeaderAuthorization, tc.auth)
+		}
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, req)
+		if resp.Code != tc.want {
+			t.Errorf("auth %q: status = %d, want %d", tc.auth, resp.Code, tc.want)
+		}
+		if resp.Code != http.StatusOK {
+			continue
+		}
+
+		var stats struct {
+			Goroutines int            `json:"goroutines"`
+			Jobs       map[string]int `json:"jobs"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+			t.Fatal(err)
+		}
+		if stats.Goroutines == 0 || stats.Jobs["workers"] != 2 {
+			t.Errorf("unexpected stats: %+v", stats)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/debug/pprof/heap", nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer secret")
+	resp := httptest.NewRecorder()
+	e.ServeHTTP(resp, req)
+	if resp.Code != http.StatusNotFound {
+		t.Errorf("pprof without ENABLE_PPROF: status = %d, want 404", resp.Code)
+	}
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/parser"
+)
+
+// saveSource keeps the file luminaire id was read from, so that it can be
+// shown annotated; the extension of sourceName says which reader read it.
+// Failing to keep it does not fail the upload.
+func (h *LuminaireHandler) saveSource(id int64, filename, sourceName string, data []byte) {
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(sourceName)), ".")
+	_, err := h.db.Exec(`
+		INSERT OR REPLACE INTO luminaire_sources (luminaire_id, filename, source_format, data)
+		VALUES (?, ?, ?, ?)`, id, filename, format, data)
+	if err != nil {
+		uploadLog.Warn("source not kept", "luminaire_id", id, "err", err)
+	}
+}
+
+// Annotated returns the file a luminaire was uploaded from with each line
+// tagged by the part its reader takes it to play (keyword, tilt, angles,
+// candela plane N): GET /api/v1/luminaires/:id/annotated. Luminaires
+// uploaded before sources were kept have nothing to show. The file is the
+// whole record, so it is gated like an export.
+func (h *LuminaireHandler) Annotated(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_id")
+	}
+	state, err := h.recordState(id)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && h.hiddenFrom(c, state)) {
+		return apiError(c, http.StatusNotFound, "luminaire_not_found")
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if h.exportBlocked(state) {
+		return exportBlockedResponse(c, state, h.exportState)
+	}
+	license, err := h.exportLicense(c, id)
+	if errors.Is(err, errLicenseNotAccepted) {
+		return licenseRequiredResponse(c, license)
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	var filename, format string
+	var data []byte
+	err = h.db.QueryRow(`SELECT filename, source_format, data FROM luminaire_sources WHERE luminaire_id = ?`, id).
+		Scan(&filename, &format, &data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apiError(c, http.StatusNotFound, "source_not_found")
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	sourceName := "source." + format
+	p, err := parser.GetReader(sourceName)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	annotator, ok := p.(parser.Annotator)
+	if !ok {
+		return apiErrorWith(c, http.StatusUnprocessableEntity, "annotation_unsupported", map[string]string{"format": parser.DetectFormat(sourceName)})
+	}
+	lines, err := annotator.Annotate(bytes.NewReader(data))
+	if err != nil {
+		return apiErrorWith(c, parseErrorStatus(err), "parse_error", map[string]string{"detail": err.Error()})
+	}
+
+	setLicenseLink(c, license)
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"luminaire_id": id,
+		"filename":     filename,
+		"format":       parser.DetectFormat(sourceName),
+		"lines":        lines,
+	})
+}
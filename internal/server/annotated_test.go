@@ -0,0 +1,132 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+	"illuminate/internal/parser"
+	"illuminate/internal/synth"
+)
+
+// TestAnnotated uploads an LDT file and reads it back annotated.
+func TestAnnotated(t *testing.T) {
+	h := newTestHandler(t)
+	e := echo.New()
+	e.POST("/api/v1/luminaires", h.Upload)
+	e.GET("/api/v1/luminaires/:id/annotated", h.Annotated)
+
+	lum, err := synth.Generate(synth.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lum.Metadata.Manufacturer = "Acme"
+	lum.Metadata.Model = "DL-100"
+	data, err := parser.Encode(parser.NewLDTParser(), lum, parser.WriteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, _ := w.CreateFormFile("file", "downlight.ldt")
+	part.Write(data)
+	w.Close()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/luminaires", &body)
+	req.Header.Set(echo.HeaderContentType, w.FormDataContentType())
+	resp := httptest.NewRecorder()
+	e.ServeHTTP(resp, req)
+	var uploaded struct {
+		LuminaireID int64 `json:"luminaire_id"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &uploaded)
+	if uploaded.LuminaireID == 0 {
+		t.Fatalf("upload: %s", resp.Body.String())
+	}
+
+	resp = httptest.NewRecorder()
+	e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/luminaires/%d/annotated", uploaded.LuminaireID), nil))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("annotated: status = %d: %s", resp.Code, resp.Body.String())
+	}
+	var result struct {
+		Filename string                 `json:"filename"`
+		Lines    []parser.AnnotatedLine `json:"lines"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &result)
+	if result.Filename != "downlight.ldt" || len(result.Lines) == 0 {
+		t.Fatalf("annotated: %s", resp.Body.String())
+	}
+	if first := result.Lines[0]; first.Role != parser.RoleHeader || first.Detail == "" {
+		t.Errorf("line 1 = %+v", first)
+	}
+
+	id, err := h.saveLuminaire(lum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp = httptest.NewRecorder()
+	e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/luminaires/%d/annotated", id), nil))
+	if resp.Code != http.StatusNotFound {
+		t.Errorf("without source: status = %d, want 404", resp.Code)
+	}
+}
+
+// TestAnnotatedGated reads the source of records the export gates hold back:
+// a draft anonymously and as an editor, a merged record and one whose
+// license is not accepted.
+func TestAnnotatedGated(t *testing.T) {
+	h := newTestHandler(t)
+	h.exportState = database.StateApproved
+	h.workflowTokens = map[string]database.Role{"ed": database.RoleEditor}
+	e := echo.New()
+	e.GET("/api/v1/luminaires/:id/annotated", h.Annotated)
+
+	lum, err := synth.Generate(synth.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := parser.Encode(parser.NewLDTParser(), lum, parser.WriteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := map[string]int64{}
+	for _, name := range []string{"draft", "merged", "licensed"} {
+		ids[name] = saveSynth(t, h, name)
+		h.saveSource(ids[name], name+".ldt", name+".ldt", data)
+	}
+	h.db.Exec(`UPDATE luminaires SET workflow_state = 'draft' WHERE id = ?`, ids["draft"])
+	h.db.Exec(`UPDATE luminaires SET workflow_state = 'approved', deleted_at = CURRENT_TIMESTAMP WHERE id = ?`, ids["merged"])
+	h.db.Exec(`UPDATE luminaires SET workflow_state = 'approved' WHERE id = ?`, ids["licensed"])
+	h.db.Exec(`INSERT INTO luminaire_licenses (luminaire_id, name, require_acceptance) VALUES (?, 'EULA', 1)`, ids["licensed"])
+
+	get := func(id int64, query, token string) int {
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/luminaires/%d/annotated%s", id, query), nil)
+		if token != "" {
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		}
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, req)
+		return resp.Code
+	}
+	for _, tc := range []struct {
+		name, record, query, token string
+		want                       int
+	}{
+		{"anonymous draft", "draft", "", "", http.StatusNotFound},
+		{"editor draft", "draft", "", "ed", http.StatusForbidden},
+		{"merged", "merged", "", "ed", http.StatusNotFound},
+		{"license not accepted", "licensed", "", "", http.StatusForbidden},
+		{"license accepted", "licensed", "?accept_license=true", "", http.StatusOK},
+	} {
+		if got := get(ids[tc.record], tc.query, tc.token); got != tc.want {
+			t.Errorf("%s: status %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
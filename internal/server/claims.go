@@ -0,0 +1,216 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+	"illuminate/internal/photometry"
+)
+
+// datasheetClaims are the values a datasheet states for a luminaire; nil
+// fields were not claimed.
+type datasheetClaims struct {
+	Flux  *float64 `json:"flux"`
+	Watts *float64 `json:"watts"`
+	CCT   *int     `json:"cct"`
+	// Source names the datasheet, e.g. a URL or document number.
+	Source string `json:"source"`
+}
+
+// claimTolerances bound how far measured values may stray from the claims:
+// flux and watts in percent, CCT in kelvin.
+type claimTolerances struct {
+	FluxPercent  float64 `json:"flux_percent"`
+	WattsPercent float64 `json:"watts_percent"`
+	CCTKelvin    float64 `json:"cct_kelvin"`
+}
+
+// claimTolerancesFromEnv reads CLAIM_TOLERANCE_FLUX, CLAIM_TOLERANCE_WATTS
+// and CLAIM_TOLERANCE_CCT. The defaults follow the ±10% flux and power
+// tolerance usual for LM-79 reports and roughly one ANSI C78.377 step.
+func claimTolerancesFromEnv() claimTolerances {
+	t := claimTolerances{FluxPercent: 10, WattsPercent: 10, CCTKelvin: 150}
+	for key, dst := range map[string]*float64{
+		"CLAIM_TOLERANCE_FLUX":  &t.FluxPercent,
+		"CLAIM_TOLERANCE_WATTS": &t.WattsPercent,
+		"CLAIM_TOLERANCE_CCT":   &t.CCTKelvin,
+	} {
+		if v, err := strconv.ParseFloat(os.Getenv(key), 64); err == nil && v >= 0 {
+			*dst = v
+		}
+	}
+	return t
+}
+
+// claimCheck compares one claimed value with the measured one.
+type claimCheck struct {
+	Field     string   `json:"field"`
+	Claimed   float64  `json:"claimed"`
+	Measured  *float64 `json:"measured"`
+	Deviation *float64 `json:"deviation"`
+	Tolerance float64  `json:"tolerance"`
+	Unit      string   `json:"unit"`
+	Status    string   `json:"status"`
+}
+
+// GetClaims returns the stored datasheet claims of a luminaire.
+func (h *LuminaireHandler) GetClaims(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_id")
+	}
+	claims, err := h.loadClaims(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apiError(c, http.StatusNotFound, "claims_not_found")
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, claims)
+}
+
+// PutClaims stores the datasheet claims of a luminaire from a JSON body such
+// as {"flux": 5000, "watts": 40, "cct": 3000, "source": "DS-123 rev B"}.
+func (h *LuminaireHandler) PutClaims(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_id")
+	}
+
+	var claims datasheetClaims
+	if err := json.NewDecoder(c.Request().Body).Decode(&claims); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid claims: %v", err)})
+	}
+	if (claims.Flux != nil && *claims.Flux <= 0) || (claims.Watts != nil && *claims.Watts <= 0) || (claims.CCT != nil && *claims.CCT <= 0) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "claimed values must be positive"})
+	}
+
+	var exists int
+	if err := h.db.QueryRow(`SELECT COUNT(*) FROM luminaires WHERE id = ? AND `+database.NotDeleted, id).Scan(&exists); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if exists == 0 {
+		return apiError(c, http.StatusNotFound, "luminaire_not_found")
+	}
+
+	_, err = h.db.Exec(`
+		INSERT OR REPLACE INTO luminaire_claims (luminaire_id, flux, watts, cct, source, updated_at)
+		VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`,
+		id, claims.Flux, claims.Watts, claims.CCT, claims.Source,
+	)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"status": "saved"})
+}
+
+// CheckClaims compares the stored claims with the measured data:
+// GET /api/v1/luminaires/:id/claims/check?tolerance_flux=5. Flux is
+// integrated from the candela values, watts and CCT come from the file.
+func (h *LuminaireHandler) CheckClaims(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_id")
+	}
+
+	tol := claimTolerancesFromEnv()
+	for key, dst := range map[string]*float64{
+		"tolerance_flux":  &tol.FluxPercent,
+		"tolerance_watts": &tol.WattsPercent,
+		"tolerance_cct":   &tol.CCTKelvin,
+	} {
+		if v := c.QueryParam(key); v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil || f < 0 {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid " + key})
+			}
+			*dst = f
+		}
+	}
+
+	claims, err := h.loadClaims(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apiError(c, http.StatusNotFound, "claims_not_found")
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	lum, err := database.LoadParsedLuminaire(h.db, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apiError(c, http.StatusNotFound, "luminaire_not_found")
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	flux := photometry.Flux(lum)
+	if flux <= 0 {
+		flux = lum.Metadata.LuminousFlux
+	}
+	checks := []claimCheck{}
+	if claims.Flux != nil {
+		checks = append(checks, relativeCheck("flux", *claims.Flux, flux, tol.FluxPercent))
+	}
+	if claims.Watts != nil {
+		checks = append(checks, relativeCheck("watts", *claims.Watts, lum.Metadata.InputWatts, tol.WattsPercent))
+	}
+	if claims.CCT != nil {
+		check := claimCheck{Field: "cct", Claimed: float64(*claims.CCT), Tolerance: tol.CCTKelvin, Unit: "K", Status: "unknown"}
+		if cct := float64(lum.Metadata.ColorTemp); cct > 0 {
+			deviation := cct - check.Claimed
+			check.Measured, check.Deviation = &cct, &deviation
+			check.Status = claimStatus(math.Abs(deviation) <= tol.CCTKelvin)
+		}
+		checks = append(checks, check)
+	}
+
+	discrepancies := 0
+	for _, check := range checks {
+		if check.Status == "discrepancy" {
+			discrepancies++
+		}
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"luminaire_id":  id,
+		"source":        claims.Source,
+		"tolerances":    tol,
+		"checks":        checks,
+		"discrepancies": discrepancies,
+	})
+}
+
+// relativeCheck compares values whose tolerance is a percentage of the claim.
+func relativeCheck(field string, claimed, measured, tolerance float64) claimCheck {
+	check := claimCheck{Field: field, Claimed: claimed, Tolerance: tolerance, Unit: "%", Status: "unknown"}
+	if measured > 0 {
+		deviation := (measured - claimed) / claimed * 100
+		check.Measured, check.Deviation = &measured, &deviation
+		check.Status = claimStatus(math.Abs(deviation) <= tolerance)
+	}
+	return check
+}
+
+func claimStatus(ok bool) string {
+	if ok {
+		return "ok"
+	}
+	return "discrepancy"
+}
+
+func (h *LuminaireHandler) loadClaims(id int64) (*datasheetClaims, error) {
+	var claims datasheetClaims
+	err := h.db.QueryRow(`SELECT flux, watts, cct, source FROM luminaire_claims WHERE luminaire_id = ?`, id).
+		Scan(&claims.Flux, &claims.Watts, &claims.CCT, &claims.Source)
+	if err != nil {
+		return nil, err
+	}
+	return &claims, nil
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+)
+
+func TestCheckClaims(t *testing.T) {
+	h := newTestHandler(t)
+	id := saveSynth(t, h, "claims", func(lum *database.ParsedLuminaire) {
+		lum.Metadata.ColorTemp = 3000
+	})
+
+	e := echo.New()
+	e.GET("/api/v1/luminaires/:id/claims", h.GetClaims)
+	e.PUT("/api/v1/luminaires/:id/claims", h.PutClaims)
+	e.GET("/api/v1/luminaires/:id/claims/check", h.CheckClaims)
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return resp
+	}
+	base := fmt.Sprintf("/api/v1/luminaires/%d/claims", id)
+
+	if resp := do(http.MethodGet, base+"/check", ""); resp.Code != http.StatusNotFound {
+		t.Errorf("check without claims: status = %d", resp.Code)
+	}
+	if resp := do(http.MethodPut, base, `{"flux": -1}`); resp.Code != http.StatusBadRequest {
+		t.Errorf("negative claim: status = %d", resp.Code)
+	}
+	if resp := do(http.MethodPut, "/api/v1/luminaires/999/claims", `{"flux": 1000}`); resp.Code != http.StatusNotFound {
+		t.Errorf("unknown luminaire: status = %d", resp.Code)
+	}
+
+	// The synthetic file measures 1000 lm at 10 W: a 1080 lm claim is 7.4%
+	// off, a 9 W claim 11% and a 3200 K claim 200 K.
+	resp := do(http.MethodPut, base, `{"flux": 1080, "watts": 9, "cct": 3200, "source": "DS-1"}`)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("put: status = %d: %s", resp.Code, resp.Body.String())
+	}
+	if resp := do(http.MethodGet, base, ""); !strings.Contains(resp.Body.String(), `"source":"DS-1"`) {
+		t.Errorf("get = %s", resp.Body.String())
+	}
+
+	check := func(query string) map[string]string {
+		t.Helper()
+		resp := do(http.MethodGet, base+"/check"+query, "")
+		if resp.Code != http.StatusOK {
+			t.Fatalf("check: status = %d: %s", resp.Code, resp.Body.String())
+		}
+		var body struct {
+			Checks []claimCheck `json:"checks"`
+		}
+		json.Unmarshal(resp.Body.Bytes(), &body)
+		status := map[string]string{}
+		for _, c := range body.Checks {
+			status[c.Field] = c.Status
+		}
+		return status
+	}
+	if got := check(""); got["flux"] != "ok" || got["watts"] != "discrepancy" || got["cct"] != "discrepancy" {
+		t.Errorf("default tolerances: %v", got)
+	}
+	if got := check("?tolerance_flux=5&tolerance_watts=12&tolerance_cct=250"); got["flux"] != "discrepancy" || got["watts"] != "ok" || got["cct"] != "ok" {
+		t.Errorf("query tolerances: %v", got)
+	}
+	if resp := do(http.MethodGet, base+"/check?tolerance_cct=x", ""); resp.Code != http.StatusBadRequest {
+		t.Errorf("bad tolerance: status = %d", resp.Code)
+	}
+}
@@ -0,0 +1,235 @@
+package server
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/bundle"
+	"illuminate/internal/database"
+	"illuminate/internal/parser"
+	"illuminate/internal/photometry"
+	"illuminate/internal/revit"
+)
+
+// ListCollections returns every smart collection without evaluating it.
+func (h *LuminaireHandler) ListCollections(c echo.Context) error {
+	rows, err := h.db.Query(`SELECT name, expression, description, updated_at FROM collections ORDER BY name`)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	defer rows.Close()
+
+	collections := []map[string]interface{}{}
+	for rows.Next() {
+		var name, expression, description, updatedAt string
+		if err := rows.Scan(&name, &expression, &description, &updatedAt); err != nil {
+			continue
+		}
+		collections = append(collections, map[string]interface{}{
+			"name":        name,
+			"expression":  expression,
+			"description": description,
+			"updated_at":  updatedAt,
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"collections": collections,
+	})
+}
+
+// GetCollection evaluates a smart collection and returns the luminaires that
+// currently match it.
+func (h *LuminaireHandler) GetCollection(c echo.Context) error {
+	name := c.Param("name")
+	filter, description, err := h.loadCollection(name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apiError(c, http.StatusNotFound, "collection_not_found")
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	luminaires, err := h.findLuminaires(c, h.readDB(), filter)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if luminaires == nil {
+		luminaires = []database.Luminaire{}
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"name":        name,
+		"expression":  filter.String(),
+		"description": description,
+		"luminaires":  luminaires,
+	})
+}
+
+// PutCollection creates or replaces a smart collection from a JSON body such
+// as {"expression": "LED, cct=3000, flux > 5000 lm", "description": "..."}.
+func (h *LuminaireHandler) PutCollection(c echo.Context) error {
+	name := c.Param("name")
+	if !profileNameRegex.MatchString(name) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid collection name"})
+	}
+
+	var body struct {
+		Expression  string `json:"expression"`
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(c.Request().Body).Decode(&body); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid collection: %v", err)})
+	}
+	filter, err := database.ParseFilter(body.Expression)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	_, err = h.db.Exec(`
+		INSERT INTO collections (name, expression, description) VALUES (?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET expression = excluded.expression,
+			description = excluded.description, updated_at = CURRENT_TIMESTAMP`,
+		name, filter.String(), body.Description,
+	)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"status": "saved"})
+}
+
+func (h *LuminaireHandler) DeleteCollection(c echo.Context) error {
+	_, err := h.db.Exec("DELETE FROM collections WHERE name = ?", c.Param("name"))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// ExportCollection writes every luminaire in a collection to one ZIP archive:
+// GET /api/v1/collections/:name/export?format=ldt. The encoding, line ending
+// and export options of /export apply to every file. Records below
+// EXPORT_REQUIRE_STATE are left out; licensed records carry their license as
+// on /export, and the whole export is refused while any license that needs
+// acceptance has not been accepted. The archive lists the SHA-256 checksum of
+// every file in SHA256SUMS, signed when EXPORT_SIGNING_KEY_FILE is set (see
+// package bundle). format=revit writes IES files plus a Revit type catalog of
+// the collection, <name>.txt and <name>.json.
+func (h *LuminaireHandler) ExportCollection(c echo.Context) error {
+	name := c.Param("name")
+	filter, _, err := h.loadCollection(name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apiError(c, http.StatusNotFound, "collection_not_found")
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	req, status, err := h.conversionRequest(c, "ies", parser.WriteOptions{})
+	if err != nil {
+		return c.JSON(status, map[string]string{"error": err.Error()})
+	}
+	// format=revit exports IES files with one Revit type catalog for the
+	// whole collection.
+	revitCatalog := req.format == "revit"
+	if revitCatalog {
+		req.format = "ies"
+	}
+	format := req.format
+	p, err := req.writer()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	luminaires, err := database.FindLuminaires(h.db, filter)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	var buf bytes.Buffer
+	zw := bundle.NewWriter(&buf, h.signingKey)
+	// Conversions are recorded once the whole ZIP has been built.
+	var exported []func()
+	var types []revit.Type
+	for _, meta := range luminaires {
+		if h.exportBlocked(meta.State) {
+			continue
+		}
+		license, err := h.exportLicense(c, meta.ID)
+		if errors.Is(err, errLicenseNotAccepted) {
+			return c.JSON(http.StatusForbidden, map[string]interface{}{
+				"error":        fmt.Sprintf("luminaire %d: %v", meta.ID, err),
+				"luminaire_id": meta.ID,
+				"license":      license,
+			})
+		}
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("luminaire %d: %v", meta.ID, err)})
+		}
+		lum, err := database.LoadParsedLuminaire(h.db, meta.ID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("luminaire %d: %v", meta.ID, err)})
+		}
+		if req.aimed {
+			lum = photometry.Aim(lum, meta.AimTilt, meta.AimRotation)
+		}
+		filename := downloadFilename(meta, format)
+		if req.opts.Anonymize {
+			lum = parser.Anonymize(lum)
+			filename = downloadFilename(lum.Metadata, format)
+		}
+		fileOpts, _ := embedLicense(license, format, req.opts)
+		fileOpts.Provenance = req.provenance(lum.Metadata.FileHash)
+		data, err := parser.Encode(p, lum, fileOpts)
+		if errors.Is(err, parser.ErrDowngrade) {
+			return c.JSON(http.StatusUnprocessableEntity, map[string]string{"error": fmt.Sprintf("luminaire %d: %v", meta.ID, err)})
+		}
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("luminaire %d: %v", meta.ID, err)})
+		}
+		// The id prefix keeps names unique when two records share a model.
+		filename = fmt.Sprintf("%d_%s", meta.ID, filename)
+		if err := zw.Add(filename, data); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		if revitCatalog {
+			types = append(types, revit.NewType(strings.TrimSuffix(filename, ".ies"), lum.Metadata, filename))
+		}
+		exported = append(exported, func() { h.recordConversion(c, meta.ID, format, fileOpts, data) })
+	}
+	if revitCatalog {
+		if err := addRevitFiles(zw, name, "", nil, types); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	for _, record := range exported {
+		record()
+	}
+
+	c.Response().Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, name))
+	return c.Blob(http.StatusOK, "application/zip", buf.Bytes())
+}
+
+// loadCollection returns the parsed filter and description of a collection.
+func (h *LuminaireHandler) loadCollection(name string) (*database.Filter, string, error) {
+	var expression, description string
+	err := h.db.QueryRow(`SELECT expression, description FROM collections WHERE name = ?`, name).Scan(&expression, &description)
+	if err != nil {
+		return nil, "", err
+	}
+	filter, err := database.ParseFilter(expression)
+	if err != nil {
+		return nil, "", fmt.Errorf("stored collection %s: %w", name, err)
+	}
+	return filter, description, nil
+}
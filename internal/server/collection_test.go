@@ -0,0 +1,128 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/bundle"
+	"illuminate/internal/database"
+)
+
+func TestSmartCollections(t *testing.T) {
+	h := newTestHandler(t)
+	for i, spec := range []struct {
+		lampType string
+		cct      int
+		flux     float64
+	}{
+		{"LED", 3000, 6000},
+		{"LED", 3000, 4000},
+		{"LED", 4000, 8000},
+		{"Fluorescent", 3000, 9000},
+	} {
+		saveSynth(t, h, fmt.Sprintf("collection-%d", i), func(lum *database.ParsedLuminaire) {
+			lum.Metadata.Model = fmt.Sprintf("M%d", i)
+			lum.Metadata.LampType = spec.lampType
+			lum.Metadata.ColorTemp = spec.cct
+			lum.Metadata.LuminousFlux = spec.flux
+		})
+	}
+
+	e := echo.New()
+	e.GET("/api/v1/luminaires", h.List)
+	e.GET("/api/v1/collections", h.ListCollections)
+	e.GET("/api/v1/collections/:name", h.GetCollection)
+	e.PUT("/api/v1/collections/:name", h.PutCollection)
+	e.DELETE("/api/v1/collections/:name", h.DeleteCollection)
+	e.GET("/api/v1/collections/:name/export", h.ExportCollection)
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, req)
+		return resp
+	}
+	models := func(resp *httptest.ResponseRecorder) []string {
+		var body struct {
+			Luminaires []struct {
+				Model string `json:"model"`
+			} `json:"luminaires"`
+		}
+		json.Unmarshal(resp.Body.Bytes(), &body)
+		var out []string
+		for _, l := range body.Luminaires {
+			out = append(out, l.Model)
+		}
+		sort.Strings(out)
+		return out
+	}
+
+	if resp := do(http.MethodPut, "/api/v1/collections/warm", `{"expression": "LED, cct=3000, flux > 5000 lm"}`); resp.Code != http.StatusOK {
+		t.Fatalf("save: status = %d: %s", resp.Code, resp.Body.String())
+	}
+	resp := do(http.MethodGet, "/api/v1/collections/warm", "")
+	if got := models(resp); fmt.Sprint(got) != "[M0]" {
+		t.Errorf("warm collection = %v, want [M0]", got)
+	}
+
+	// Collections are dynamic: a changed record moves in without re-saving.
+	h.db.Exec(`UPDATE luminaires SET luminous_flux = 5500 WHERE model = 'M1'`)
+	if got := models(do(http.MethodGet, "/api/v1/collections/warm", "")); fmt.Sprint(got) != "[M0 M1]" {
+		t.Errorf("after update = %v, want [M0 M1]", got)
+	}
+
+	if got := models(do(http.MethodGet, "/api/v1/luminaires?filter="+url.QueryEscape("lamp_type~fluor, cct<=3000"), "")); fmt.Sprint(got) != "[M3]" {
+		t.Errorf("list filter = %v, want [M3]", got)
+	}
+
+	pub, key, _ := ed25519.GenerateKey(nil)
+	h.signingKey = key
+	resp = do(http.MethodGet, "/api/v1/collections/warm/export?format=ldt", "")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("export: status = %d: %s", resp.Code, resp.Body.String())
+	}
+	zr, err := zip.NewReader(bytes.NewReader(resp.Body.Bytes()), int64(resp.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zr.File) != 4 || !strings.HasSuffix(zr.File[0].Name, ".ldt") || zr.File[2].Name != bundle.SumsName {
+		t.Errorf("archive has %d files", len(zr.File))
+	}
+	if names, err := bundle.Verify(bytes.NewReader(resp.Body.Bytes()), int64(resp.Body.Len()), pub); err != nil || len(names) != 2 {
+		t.Errorf("verify: %v %v", names, err)
+	}
+
+	resp = do(http.MethodGet, "/api/v1/collections/warm/export?format=revit", "")
+	zr, err = zip.NewReader(bytes.NewReader(resp.Body.Bytes()), int64(resp.Body.Len()))
+	if err != nil {
+		t.Fatalf("revit export: status = %d: %v", resp.Code, err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	if len(names) != 6 || !strings.HasSuffix(names[0], ".ies") || names[2] != "warm.txt" || names[3] != "warm.json" {
+		t.Errorf("revit archive = %v", names)
+	}
+
+	for _, bad := range []string{`{"expression": "glow>30"}`, `{"expression": "flux~big"}`, `{"expression": "cct=warm"}`} {
+		if resp := do(http.MethodPut, "/api/v1/collections/bad", bad); resp.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", bad, resp.Code)
+		}
+	}
+	do(http.MethodDelete, "/api/v1/collections/warm", "")
+	if resp := do(http.MethodGet, "/api/v1/collections/warm", ""); resp.Code != http.StatusNotFound {
+		t.Errorf("deleted collection: status = %d", resp.Code)
+	}
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+	"illuminate/internal/parser"
+)
+
+// Compatibility reports, before an export, which fields a target format
+// would lose or approximate: GET /api/v1/luminaires/:id/compatibility?target=cie.
+// The report is the same sorted by kind, see parser.ConversionResult.
+func (h *LuminaireHandler) Compatibility(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_id")
+	}
+	target := strings.ToLower(c.QueryParam("target"))
+	if target == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "target is required (ies, ldt or cie)"})
+	}
+	p, err := parser.GetParser("export." + target)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	lum, err := database.LoadParsedLuminaire(h.db, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apiError(c, http.StatusNotFound, "luminaire_not_found")
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	issues := p.Compatibility(lum)
+	if issues == nil {
+		issues = []parser.CompatibilityIssue{}
+	}
+	source := strings.TrimPrefix(path.Ext(lum.Metadata.OriginalFilename), ".")
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"luminaire_id": id,
+		"target":       target,
+		"compatible":   len(issues) == 0,
+		"issues":       issues,
+		"report":       parser.NewConversionResult(source, target, issues),
+	})
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+	"illuminate/internal/parser"
+)
+
+func TestCompatibility(t *testing.T) {
+	h := newTestHandler(t)
+	id := saveSynth(t, h, "compatibility", func(lum *database.ParsedLuminaire) {
+		lum.Metadata.CatalogNumber = "DL-200"
+	})
+
+	e := echo.New()
+	e.GET("/api/v1/luminaires/:id/compatibility", h.Compatibility)
+	get := func(target string) *httptest.ResponseRecorder {
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, target, nil))
+		return resp
+	}
+
+	resp := get(fmt.Sprintf("/api/v1/luminaires/%d/compatibility?target=CIE", id))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", resp.Code, resp.Body.String())
+	}
+	var body struct {
+		Compatible bool                        `json:"compatible"`
+		Issues     []parser.CompatibilityIssue `json:"issues"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &body)
+	lost := map[string]bool{}
+	for _, issue := range body.Issues {
+		if issue.Effect == parser.EffectLost {
+			lost[issue.Field] = true
+		}
+	}
+	if body.Compatible || !lost["manufacturer"] || !lost["catalog_number"] || !lost["input_watts"] {
+		t.Errorf("cie issues = %+v", body.Issues)
+	}
+
+	for target, want := range map[string]int{
+		fmt.Sprintf("/api/v1/luminaires/%d/compatibility", id):            http.StatusBadRequest,
+		fmt.Sprintf("/api/v1/luminaires/%d/compatibility?target=xyz", id): http.StatusBadRequest,
+		"/api/v1/luminaires/999/compatibility?target=ldt":                 http.StatusNotFound,
+	} {
+		if resp := get(target); resp.Code != want {
+			t.Errorf("%s: status = %d, want %d", target, resp.Code, want)
+		}
+	}
+}
+
+func TestExportDowngrade(t *testing.T) {
+	h := newTestHandler(t)
+	id := saveSynth(t, h, "downgrade", func(lum *database.ParsedLuminaire) {
+		lum.Metadata.CatalogNumber = "DL-200"
+	})
+
+	e := echo.New()
+	e.GET("/api/v1/luminaires/:id", h.Get)
+	e.GET("/api/v1/luminaires/:id/export", h.Export)
+	get := func(path string) *httptest.ResponseRecorder {
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, path, nil))
+		return resp
+	}
+
+	for _, base := range []string{fmt.Sprintf("/api/v1/luminaires/%d?format=cie", id), fmt.Sprintf("/api/v1/luminaires/%d/export?format=cie", id)} {
+		resp := get(base)
+		if resp.Code != http.StatusOK || !strings.Contains(resp.Header().Get("X-Export-Issues"), "catalog_number: lost") {
+			t.Errorf("%s: status = %d, issues = %q", base, resp.Code, resp.Header().Get("X-Export-Issues"))
+		}
+		if resp := get(base + "&downgrade=fail"); resp.Code != http.StatusUnprocessableEntity || !strings.Contains(resp.Body.String(), `"field":"catalog_number"`) {
+			t.Errorf("%s fail: status = %d: %s", base, resp.Code, resp.Body.String())
+		}
+		resp = get(base + "&downgrade=embed")
+		if resp.Code != http.StatusOK || !strings.Contains(resp.Body.String(), "catalog_number=DL-200") {
+			t.Errorf("%s embed: status = %d: %s", base, resp.Code, resp.Body.String())
+		}
+		if resp := get(base + "&downgrade=maybe"); resp.Code != http.StatusBadRequest {
+			t.Errorf("%s bad strategy: status = %d", base, resp.Code)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package server
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/compliance"
+	"illuminate/internal/database"
+	"illuminate/internal/pdf"
+	"illuminate/internal/photometry"
+)
+
+// complianceEntry is one luminaire of a compliance report.
+type complianceEntry struct {
+	ID           int64               `json:"id"`
+	Manufacturer string              `json:"manufacturer"`
+	Model        string              `json:"model"`
+	Flux         float64             `json:"flux"`
+	Watts        float64             `json:"input_watts"`
+	Efficacy     *float64            `json:"efficacy"`
+	CRI          int                 `json:"cri"`
+	Directional  bool                `json:"directional"`
+	Results      []compliance.Result `json:"results"`
+
+	// Power quality as stated for the luminaire, zero when unknown.
+	THD           float64 `json:"thd"`
+	InrushCurrent float64 `json:"inrush_current"`
+	Frequency     float64 `json:"frequency"`
+}
+
+type complianceReport struct {
+	GeneratedAt time.Time                 `json:"generated_at"`
+	Schemes     []string                  `json:"schemes"`
+	Thresholds  compliance.Thresholds     `json:"thresholds"`
+	Summary     map[string]map[string]int `json:"summary"`
+	Luminaires  []complianceEntry         `json:"luminaires"`
+}
+
+// Compliance reports one luminaire:
+// GET /api/v1/luminaires/:id/compliance?format=json|csv|pdf.
+func (h *LuminaireHandler) Compliance(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_id")
+	}
+	lum, err := database.LoadParsedLuminaire(h.db, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apiError(c, http.StatusNotFound, "luminaire_not_found")
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return h.sendComplianceReport(c, []database.Luminaire{lum.Metadata}, fmt.Sprintf("compliance_%d", id))
+}
+
+// ComplianceReport reports every luminaire matching ?filter= or
+// ?collection=: GET /api/v1/compliance?filter=LED&format=csv.
+func (h *LuminaireHandler) ComplianceReport(c echo.Context) error {
+	filter, err := database.ParseFilter(c.QueryParam("filter"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	name := "compliance"
+	if collection := c.QueryParam("collection"); collection != "" {
+		stored, _, err := h.loadCollection(collection)
+		if errors.Is(err, sql.ErrNoRows) {
+			return apiError(c, http.StatusNotFound, "collection_not_found")
+		}
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		filter.Terms = append(filter.Terms, stored.Terms...)
+		name = "compliance_" + collection
+	}
+
+	luminaires, err := h.findLuminaires(c, h.db, filter)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return h.sendComplianceReport(c, luminaires, name)
+}
+
+func (h *LuminaireHandler) sendComplianceReport(c echo.Context, luminaires []database.Luminaire, name string) error {
+	schemes, err := compliance.ParseSchemes(c.QueryParam("schemes"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	thresholds := compliance.ThresholdsFromEnv()
+	if v := c.QueryParam("min_efficacy"); v != "" {
+		if thresholds.MinEfficacy, err = strconv.ParseFloat(v, 64); err != nil || thresholds.MinEfficacy <= 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid min_efficacy"})
+		}
+	}
+	for _, s := range schemes {
+		if s == compliance.Custom && thresholds.MinEfficacy <= 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "the custom scheme needs min_efficacy"})
+		}
+	}
+	format := strings.ToLower(c.QueryParam("format"))
+	if format != "" && format != "json" && format != "csv" && format != "pdf" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "format must be json, csv or pdf"})
+	}
+
+	report := complianceReport{
+		GeneratedAt: time.Now().UTC(),
+		Schemes:     schemes,
+		Thresholds:  thresholds,
+		Summary:     map[string]map[string]int{},
+		Luminaires:  []complianceEntry{},
+	}
+	for _, meta := range luminaires {
+		lum, err := database.LoadParsedLuminaire(h.db, meta.ID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("luminaire %d: %v", meta.ID, err)})
+		}
+		in := compliance.Input{
+			Flux:        photometry.Flux(lum),
+			Watts:       meta.InputWatts,
+			CRI:         meta.CRI,
+			Directional: photometry.ConeFraction(lum, 60) >= 0.8,
+		}
+		if in.Flux <= 0 {
+			in.Flux = meta.LuminousFlux
+		}
+		entry := complianceEntry{
+			ID:           meta.ID,
+			Manufacturer: meta.Manufacturer,
+			Model:        meta.Model,
+			Flux:         in.Flux,
+			Watts:        in.Watts,
+			CRI:          in.CRI,
+			Directional:  in.Directional,
+			Results:      compliance.Check(in, thresholds, schemes),
+
+			THD:           meta.THD,
+			InrushCurrent: meta.InrushCurrent,
+			Frequency:     meta.Frequency,
+		}
+		if e := in.Efficacy(); e > 0 {
+			entry.Efficacy = &e
+		}
+		for _, r := range entry.Results {
+			if report.Summary[r.Scheme] == nil {
+				report.Summary[r.Scheme] = map[string]int{compliance.Pass: 0, compliance.Fail: 0, compliance.Unknown: 0}
+			}
+			report.Summary[r.Scheme][r.Status]++
+		}
+		report.Luminaires = append(report.Luminaires, entry)
+	}
+
+	switch format {
+	case "csv":
+		c.Response().Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, name))
+		return c.Blob(http.StatusOK, "text/csv; charset=utf-8", complianceCSV(report))
+	case "pdf":
+		var buf bytes.Buffer
+		complianceDocument(report).WriteTo(&buf)
+		c.Response().Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.pdf"`, name))
+		return c.Blob(http.StatusOK, "application/pdf", buf.Bytes())
+	}
+	return c.JSON(http.StatusOK, report)
+}
+
+func complianceCSV(report complianceReport) []byte {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	header := []string{"id", "manufacturer", "model", "flux_lm", "input_w", "thd_pct", "inrush_a", "frequency_hz",
+		"efficacy_lm_per_w", "cri", "directional"}
+	for _, s := range complianceColumns(report) {
+		header = append(header, s+"_status", s+"_limit", s+"_detail")
+	}
+	w.Write(header)
+	for _, e := range report.Luminaires {
+		efficacy := ""
+		if e.Efficacy != nil {
+			efficacy = strconv.FormatFloat(*e.Efficacy, 'f', 1, 64)
+		}
+		row := []string{
+			strconv.FormatInt(e.ID, 10), e.Manufacturer, e.Model,
+			strconv.FormatFloat(e.Flux, 'f', 0, 64), strconv.FormatFloat(e.Watts, 'f', 1, 64),
+			optionalNumber(e.THD), optionalNumber(e.InrushCurrent), optionalNumber(e.Frequency),
+			efficacy, strconv.Itoa(e.CRI), strconv.FormatBool(e.Directional),
+		}
+		for _, r := range e.Results {
+			row = append(row, r.Status, r.Limit, r.Detail)
+		}
+		w.Write(row)
+	}
+	w.Flush()
+	return buf.Bytes()
+}
+
+// optionalNumber leaves unknown (zero) values blank.
+func optionalNumber(v float64) string {
+	if v == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func complianceDocument(report complianceReport) *pdf.Document {
+	doc := pdf.New()
+	w := pdf.NewWriter(doc)
+	w.Line(16, true, "Energy compliance report")
+	w.Line(9, false, "Generated "+report.GeneratedAt.Format("2006-01-02 15:04 MST"))
+	w.Line(9, false, fmt.Sprintf("DLC: >= %g lm/W, CRI >= %d", report.Thresholds.DLCMinEfficacy, report.Thresholds.DLCMinCRI))
+	if report.Thresholds.MinEfficacy > 0 {
+		w.Line(9, false, fmt.Sprintf("Custom: >= %g lm/W", report.Thresholds.MinEfficacy))
+	}
+	w.Space(6)
+
+	columns := complianceColumns(report)
+	for _, s := range columns {
+		counts := report.Summary[s]
+		w.Line(10, false, fmt.Sprintf("%s: %d pass, %d fail, %d unknown",
+			strings.ToUpper(s), counts[compliance.Pass], counts[compliance.Fail], counts[compliance.Unknown]))
+	}
+	w.Space(8)
+
+	offsets := []float64{0, 40, 250, 300, 345}
+	cells := []string{"ID", "Luminaire", "lm", "W", "lm/W"}
+	for i, s := range columns {
+		offsets = append(offsets, 395+float64(i)*50)
+		cells = append(cells, strings.ToUpper(s))
+	}
+	w.Columns(9, true, offsets, cells)
+	w.Rule()
+	for _, e := range report.Luminaires {
+		name := strings.TrimSpace(e.Manufacturer + " " + e.Model)
+		if r := []rune(name); len(r) > 40 {
+			name = string(r[:39]) + "…"
+		}
+		efficacy := "-"
+		if e.Efficacy != nil {
+			efficacy = fmt.Sprintf("%.1f", *e.Efficacy)
+		}
+		cells := []string{strconv.FormatInt(e.ID, 10), name, fmt.Sprintf("%.0f", e.Flux), fmt.Sprintf("%.1f", e.Watts), efficacy}
+		for _, r := range e.Results {
+			cells = append(cells, r.Status)
+		}
+		w.Columns(9, false, offsets, cells)
+	}
+	return doc
+}
+
+// complianceColumns lists the schemes in the order results appear.
+func complianceColumns(report complianceReport) []string {
+	if len(report.Luminaires) > 0 {
+		var names []string
+		for _, r := range report.Luminaires[0].Results {
+			names = append(names, r.Scheme)
+		}
+		return names
+	}
+	names := append([]string{}, report.Schemes...)
+	if report.Thresholds.MinEfficacy > 0 && !slices.Contains(names, compliance.Custom) {
+		names = append(names, compliance.Custom)
+	}
+	return names
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/synth"
+)
+
+func TestComplianceReport(t *testing.T) {
+	h := newTestHandler(t)
+	for i, watts := range []float64{8, 12} {
+		opts := synth.DefaultOptions()
+		opts.InputWatts = watts
+		lum, err := synth.Generate(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lum.Metadata.FileHash = fmt.Sprintf("compliance-%d", i)
+		lum.Metadata.Model = fmt.Sprintf("W%.0f", watts)
+		lum.Metadata.CRI = 80
+		if _, err := h.saveLuminaire(lum); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	e := echo.New()
+	e.GET("/api/v1/luminaires/:id/compliance", h.Compliance)
+	e.GET("/api/v1/compliance", h.ComplianceReport)
+	get := func(target string) *httptest.ResponseRecorder {
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, target, nil))
+		return resp
+	}
+
+	// 1000 lm at 8 W is 125 lm/W: inside ErP (9.8 W) and DLC (110 lm/W);
+	// 12 W fails both.
+	resp := get("/api/v1/compliance?min_efficacy=120")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", resp.Code, resp.Body.String())
+	}
+	var report struct {
+		Summary map[string]map[string]int `json:"summary"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &report)
+	for _, scheme := range []string{"erp", "dlc", "custom"} {
+		if s := report.Summary[scheme]; s["pass"] != 1 || s["fail"] != 1 {
+			t.Errorf("%s summary = %v", scheme, s)
+		}
+	}
+
+	resp = get("/api/v1/compliance?filter=model%3DW12&schemes=erp&format=csv")
+	rows, err := csv.NewReader(resp.Body).ReadAll()
+	if err != nil || len(rows) != 2 || rows[1][2] != "W12" || rows[1][11] != "fail" {
+		t.Errorf("csv = %v, %v", rows, err)
+	}
+
+	resp = get("/api/v1/luminaires/1/compliance?format=pdf")
+	if resp.Code != http.StatusOK || !bytes.HasPrefix(resp.Body.Bytes(), []byte("%PDF-")) || !bytes.Contains(resp.Body.Bytes(), []byte("Energy compliance report")) {
+		t.Errorf("pdf: status %d, %d bytes", resp.Code, resp.Body.Len())
+	}
+
+	for _, target := range []string{
+		"/api/v1/compliance?schemes=energystar",
+		"/api/v1/compliance?schemes=custom",
+		"/api/v1/compliance?format=xlsx",
+	} {
+		if resp := get(target); resp.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", target, resp.Code)
+		}
+	}
+	if resp := get("/api/v1/luminaires/99/compliance"); resp.Code != http.StatusNotFound {
+		t.Errorf("missing luminaire: status = %d", resp.Code)
+	}
+}
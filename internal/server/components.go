@@ -0,0 +1,148 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+	"illuminate/internal/parser"
+	"illuminate/internal/photometry"
+)
+
+// combinedComponents is the component= value that exports the sum of every
+// component with a distribution.
+const combinedComponents = "combined"
+
+// ListComponents returns the emitters of a segmented luminaire.
+func (h *LuminaireHandler) ListComponents(c echo.Context) error {
+	return h.listVariants(c, "components", func(db *sql.DB, id int64) (interface{}, error) {
+		return database.ListComponents(db, id)
+	})
+}
+
+// PutComponent stores one emitter of a segmented luminaire, replacing any
+// earlier one of the same name: PUT /api/v1/luminaires/:id/components/up
+// with its position (offset_x, offset_y, offset_z in metres from the
+// photometric centre), opening (luminous_length, luminous_width,
+// luminous_height), input_watts and luminous_flux, and optionally its own
+// distribution as a multipart "file" of the luminaire's photometric type.
+// A file supplies the watts and lumens the form leaves out.
+func (h *LuminaireHandler) PutComponent(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_id")
+	}
+	name := c.Param("name")
+	if !validVariantName(name, combinedComponents) {
+		return apiError(c, http.StatusBadRequest, "invalid_component_name")
+	}
+	comp := database.Component{Name: name}
+	for key, field := range map[string]*float64{
+		"offset_x":        &comp.OffsetX,
+		"offset_y":        &comp.OffsetY,
+		"offset_z":        &comp.OffsetZ,
+		"luminous_length": &comp.LuminousLength,
+		"luminous_width":  &comp.LuminousWidth,
+		"luminous_height": &comp.LuminousHeight,
+		"input_watts":     &comp.InputWatts,
+		"luminous_flux":   &comp.LuminousFlux,
+	} {
+		min, max := 0.0, 10.0
+		switch {
+		case strings.HasPrefix(key, "offset_"):
+			min = -10
+		case key == "input_watts":
+			max = 10000
+		case key == "luminous_flux":
+			max = 1e7
+		}
+		v, err := formNumber(c, key, min, max)
+		if err != nil {
+			return errorResponse(c, http.StatusBadRequest, err)
+		}
+		if v != nil {
+			*field = *v
+		}
+	}
+
+	base, err := database.LoadParsedLuminaire(h.db, id)
+	if err != nil {
+		return loadErrorResponse(c, err)
+	}
+
+	var lum *database.ParsedLuminaire
+	if file, err := c.FormFile("file"); err == nil {
+		req, status, err := h.conversionRequest(c, "", parser.WriteOptions{})
+		if err != nil {
+			return c.JSON(status, map[string]string{"error": err.Error()})
+		}
+		lum, status, err = parseVariant(req, file)
+		if err != nil {
+			return errorResponse(c, status, err)
+		}
+		if err := typeMismatch(lum, base.Metadata.PhotometricType); err != nil {
+			return errorResponse(c, http.StatusUnprocessableEntity, err)
+		}
+		comp.OriginalFilename = file.Filename
+	}
+
+	if err := database.SaveComponent(h.db, id, &comp, lum); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"status":    "saved",
+		"component": comp,
+	})
+}
+
+// DeleteComponent removes one emitter.
+func (h *LuminaireHandler) DeleteComponent(c echo.Context) error {
+	return h.deleteVariant(c, database.DeleteComponent, database.ErrComponentNotFound, "component_not_found")
+}
+
+// errNoComponentDistributions refuses component=combined for a luminaire
+// none of whose components has a distribution.
+var errNoComponentDistributions = errors.New("no component has a distribution")
+
+// loadCombined adds up the components of luminaire id that have a
+// distribution, keeping the opening of the luminaire itself.
+func (r *conversionRequest) loadCombined(db *sql.DB, id int64) (*database.ParsedLuminaire, error) {
+	base, err := database.LoadParsedLuminaire(db, id)
+	if err != nil {
+		return nil, err
+	}
+	components, err := database.ListComponents(db, id)
+	if err != nil {
+		return nil, err
+	}
+	var parts []*database.ParsedLuminaire
+	var names []string
+	for _, comp := range components {
+		if !comp.HasDistribution {
+			continue
+		}
+		part, err := database.LoadComponent(db, id, comp.Name)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
+		names = append(names, comp.Name)
+	}
+	if len(parts) == 0 {
+		return nil, errNoComponentDistributions
+	}
+	lum, err := photometry.Combine(parts...)
+	if err != nil {
+		return nil, err
+	}
+	meta := base.Metadata
+	meta.PhotometricType = lum.Metadata.PhotometricType
+	meta.InputWatts, meta.LuminousFlux = lum.Metadata.InputWatts, lum.Metadata.LuminousFlux
+	lum.Metadata = meta
+	r.derived = "combined from components " + strings.Join(names, ", ")
+	return lum, nil
+}
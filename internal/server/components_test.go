@@ -0,0 +1,135 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/parser"
+	"illuminate/internal/synth"
+)
+
+// TestComponents stores the up and down light of a pendant and exports
+// each and their sum.
+func TestComponents(t *testing.T) {
+	h := newTestHandler(t)
+	e := echo.New()
+	e.GET("/api/v1/luminaires/:id/components", h.ListComponents)
+	e.PUT("/api/v1/luminaires/:id/components/:name", h.PutComponent)
+	e.DELETE("/api/v1/luminaires/:id/components/:name", h.DeleteComponent)
+	e.GET("/api/v1/luminaires/:id/export", h.Export)
+
+	id := saveSynth(t, h, "pendant")
+	get := func(path string) *httptest.ResponseRecorder {
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/luminaires/%d%s", id, path), nil))
+		return resp
+	}
+
+	if resp := putVariant(e, "components", id, "combined", nil, nil); resp.Code != http.StatusBadRequest {
+		t.Errorf("reserved name: status = %d, want 400", resp.Code)
+	}
+	if resp := putVariant(e, "components", id, "down", nil, map[string]string{"offset_z": "20"}); resp.Code != http.StatusBadRequest {
+		t.Errorf("offset 20 m: status = %d, want 400", resp.Code)
+	}
+	if resp := putVariant(e, "components", id, "down", nil, map[string]string{"luminous_length": "1.2", "luminous_width": "0.1"}); resp.Code != http.StatusOK {
+		t.Fatalf("put geometry: status = %d: %s", resp.Code, resp.Body.String())
+	}
+	if resp := get("/export?format=ies&component=combined"); resp.Code != http.StatusUnprocessableEntity {
+		t.Errorf("no distributions: status = %d, want 422", resp.Code)
+	}
+
+	for _, comp := range []struct {
+		name        string
+		flux, watts float64
+		up          bool
+	}{
+		{"down", 3000, 24, false},
+		{"up", 1000, 8, true},
+	} {
+		opts := synth.DefaultOptions()
+		opts.Flux, opts.InputWatts = comp.flux, comp.watts
+		lum, err := synth.Generate(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if comp.up {
+			for _, row := range lum.CandelaMatrix {
+				slices.Reverse(row)
+			}
+		}
+		data, err := parser.Encode(parser.NewIESParser(), lum, parser.WriteOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		fields := map[string]string{"offset_z": "0.03"}
+		if comp.up {
+			fields["offset_z"] = "-0.03"
+		}
+		if resp := putVariant(e, "components", id, comp.name, data, fields); resp.Code != http.StatusOK {
+			t.Fatalf("put %s: status = %d: %s", comp.name, resp.Code, resp.Body.String())
+		}
+	}
+
+	var list struct {
+		Components []struct {
+			Name            string  `json:"name"`
+			OffsetZ         float64 `json:"offset_z"`
+			InputWatts      float64 `json:"input_watts"`
+			HasDistribution bool    `json:"has_distribution"`
+		} `json:"components"`
+	}
+	json.Unmarshal(get("/components").Body.Bytes(), &list)
+	if len(list.Components) != 2 || list.Components[0].Name != "down" || list.Components[1].OffsetZ != -0.03 ||
+		list.Components[0].InputWatts != 24 || !list.Components[1].HasDistribution {
+		t.Fatalf("components = %+v", list.Components)
+	}
+
+	export := func(query string) *httptest.ResponseRecorder {
+		t.Helper()
+		resp := get("/export?format=ies" + query)
+		if resp.Code != http.StatusOK {
+			t.Fatalf("export%s: status = %d: %s", query, resp.Code, resp.Body.String())
+		}
+		return resp
+	}
+	watts := func(resp *httptest.ResponseRecorder) float64 {
+		t.Helper()
+		lum, err := parser.NewIESParser().ParseReader(strings.NewReader(resp.Body.String()), "export.ies")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return lum.Metadata.InputWatts
+	}
+	if w := watts(export("&component=up")); w != 8 {
+		t.Errorf("up export: input watts = %g, want 8", w)
+	}
+	resp := export("&component=combined")
+	if w := watts(resp); w != 32 {
+		t.Errorf("combined export: input watts = %g, want 32", w)
+	}
+	if got, want := resp.Header().Get("X-Derived"), "combined from components down, up"; got != want {
+		t.Errorf("X-Derived = %q, want %q", got, want)
+	}
+	if cd := resp.Header().Get("Content-Disposition"); !strings.Contains(cd, "_combined.ies") {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+	if resp := get("/export?component=side"); resp.Code != http.StatusNotFound {
+		t.Errorf("unknown component: status = %d, want 404", resp.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/api/v1/luminaires/%d/components/up", id), nil)
+	resp = httptest.NewRecorder()
+	e.ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Errorf("delete: status = %d", resp.Code)
+	}
+	if resp := get("/export?component=up"); resp.Code != http.StatusNotFound {
+		t.Errorf("deleted component: status = %d, want 404", resp.Code)
+	}
+}
@@ -0,0 +1,210 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+	"illuminate/internal/parser"
+	"illuminate/internal/photometry"
+)
+
+// ListConditions returns the operating conditions a luminaire has further
+// photometry for.
+func (h *LuminaireHandler) ListConditions(c echo.Context) error {
+	return h.listVariants(c, "conditions", func(db *sql.DB, id int64) (interface{}, error) {
+		return database.ListConditions(db, id)
+	})
+}
+
+// PutCondition stores the photometry of a luminaire under another operating
+// condition from a multipart "file", replacing any earlier file of the same
+// name: PUT /api/v1/luminaires/:id/conditions/40C with ambient_temp=40, or
+// .../conditions/dim50 with dim_level=50. The file must use the photometric
+// type of the luminaire; its other metadata is ignored.
+func (h *LuminaireHandler) PutCondition(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_id")
+	}
+	name := c.Param("name")
+	if !validVariantName(name) {
+		return apiError(c, http.StatusBadRequest, "invalid_condition_name")
+	}
+	ambientTemp, err := formNumber(c, "ambient_temp", -100, 200)
+	if err != nil {
+		return errorResponse(c, http.StatusBadRequest, err)
+	}
+	dimLevel, err := formNumber(c, "dim_level", 0, 100)
+	if err != nil {
+		return errorResponse(c, http.StatusBadRequest, err)
+	}
+	if dimLevel != nil && *dimLevel == 0 {
+		return apiError(c, http.StatusBadRequest, "dim_level_zero")
+	}
+
+	req, status, err := h.conversionRequest(c, "", parser.WriteOptions{})
+	if err != nil {
+		return c.JSON(status, map[string]string{"error": err.Error()})
+	}
+	file, err := c.FormFile("file")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "file_required")
+	}
+	base, err := database.LoadParsedLuminaire(h.db, id)
+	if err != nil {
+		return loadErrorResponse(c, err)
+	}
+	lum, status, err := parseVariant(req, file)
+	if err != nil {
+		return errorResponse(c, status, err)
+	}
+	if err := typeMismatch(lum, base.Metadata.PhotometricType); err != nil {
+		return errorResponse(c, http.StatusUnprocessableEntity, err)
+	}
+
+	cond := database.Condition{Name: name, AmbientTemp: ambientTemp, DimLevel: dimLevel, OriginalFilename: file.Filename}
+	if err := database.SaveCondition(h.db, id, &cond, lum); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"status":    "saved",
+		"condition": cond,
+	})
+}
+
+// DeleteCondition removes the photometry of one condition.
+func (h *LuminaireHandler) DeleteCondition(c echo.Context) error {
+	return h.deleteVariant(c, database.DeleteCondition, database.ErrConditionNotFound, "condition_not_found")
+}
+
+// load loads luminaire id as the export asks: the main measurement, a
+// stored orientation, the one of condition=name, one interpolated at
+// dimLevel, or the distribution of component=name or of all components
+// combined; anonymized when the export
+// asks for it, so that its file name does not give the source away either.
+func (r *conversionRequest) load(db *sql.DB, id int64) (*database.ParsedLuminaire, error) {
+	lum, err := r.loadDistribution(db, id)
+	if err != nil || !r.opts.Anonymize {
+		return lum, err
+	}
+	return parser.Anonymize(lum), nil
+}
+
+// loadDistribution is load before anonymization.
+func (r *conversionRequest) loadDistribution(db *sql.DB, id int64) (*database.ParsedLuminaire, error) {
+	switch {
+	case r.dimLevel > 0:
+		return r.loadDimmed(db, id)
+	case r.orientation != "":
+		return database.LoadOrientation(db, id, r.orientation)
+	case r.condition != "":
+		return database.LoadCondition(db, id, r.condition)
+	case r.component == combinedComponents:
+		return r.loadCombined(db, id)
+	case r.component != "":
+		return database.LoadComponent(db, id, r.component)
+	}
+	return database.LoadParsedLuminaire(db, id)
+}
+
+// errDimLevelNotCovered refuses a dim level no two stored conditions
+// enclose.
+var errDimLevelNotCovered = errors.New("dim level not covered by the stored conditions")
+
+// loadDimmed interpolates luminaire id at r.dimLevel between the two
+// conditions with the nearest dim levels below and above it. A condition at
+// exactly that level is returned as measured.
+func (r *conversionRequest) loadDimmed(db *sql.DB, id int64) (*database.ParsedLuminaire, error) {
+	if _, err := database.LoadParsedLuminaire(db, id); err != nil {
+		return nil, err
+	}
+	conditions, err := database.ListConditions(db, id)
+	if err != nil {
+		return nil, err
+	}
+	var below, above *database.Condition
+	var levels []string
+	for i := range conditions {
+		cond := &conditions[i]
+		if cond.DimLevel == nil {
+			continue
+		}
+		levels = append(levels, fmt.Sprintf("%g%%", *cond.DimLevel))
+		if *cond.DimLevel <= r.dimLevel && (below == nil || *cond.DimLevel > *below.DimLevel) {
+			below = cond
+		}
+		if *cond.DimLevel >= r.dimLevel && (above == nil || *cond.DimLevel < *above.DimLevel) {
+			above = cond
+		}
+	}
+	if below == nil || above == nil {
+		if len(levels) == 0 {
+			return nil, fmt.Errorf("%w: no condition has a dim_level", errDimLevelNotCovered)
+		}
+		return nil, fmt.Errorf("%w: %g%% is outside the measured levels %s", errDimLevelNotCovered, r.dimLevel, strings.Join(levels, ", "))
+	}
+
+	lo, err := database.LoadCondition(db, id, below.Name)
+	if err != nil {
+		return nil, err
+	}
+	if below.Name == above.Name {
+		return lo, nil
+	}
+	hi, err := database.LoadCondition(db, id, above.Name)
+	if err != nil {
+		return nil, err
+	}
+	t := (r.dimLevel - *below.DimLevel) / (*above.DimLevel - *below.DimLevel)
+	lum, err := photometry.Interpolate(lo, hi, t)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s and %s: %v", errDimLevelNotCovered, below.Name, above.Name, err)
+	}
+	r.derived = fmt.Sprintf("interpolated at %g%% from %s (%g%%) and %s (%g%%)",
+		r.dimLevel, below.Name, *below.DimLevel, above.Name, *above.DimLevel)
+	return lum, nil
+}
+
+// Dimmed serves a luminaire at a dim level between two measured ones:
+// GET /api/v1/luminaires/:id/dimmed?level=75&format=ldt interpolates the
+// conditions with the nearest dim_level below and above 75%. The file is
+// derived, not measured, and says so in its provenance and the X-Derived
+// header. The options of /export apply.
+func (h *LuminaireHandler) Dimmed(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_id")
+	}
+	level, err := strconv.ParseFloat(c.QueryParam("level"), 64)
+	if err != nil || !(level > 0 && level <= 100) {
+		return apiError(c, http.StatusBadRequest, "invalid_dim_level")
+	}
+
+	req, status, err := h.conversionRequest(c, "ies", parser.WriteOptions{})
+	if err != nil {
+		return c.JSON(status, map[string]string{"error": err.Error()})
+	}
+	req.dimLevel = level
+	return h.sendLuminaireFile(c, id, req)
+}
+
+// loadErrorResponse answers a failed conversionRequest.load.
+func loadErrorResponse(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return apiError(c, http.StatusNotFound, "luminaire_not_found")
+	case errors.Is(err, database.ErrConditionNotFound), errors.Is(err, database.ErrComponentNotFound),
+		errors.Is(err, database.ErrOrientationNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, errDimLevelNotCovered), errors.Is(err, database.ErrNoDistribution),
+		errors.Is(err, errNoComponentDistributions):
+		return c.JSON(http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+}
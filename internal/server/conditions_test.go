@@ -0,0 +1,204 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/parser"
+	"illuminate/internal/synth"
+)
+
+// TestConditions stores a dimmed measurement next to the main one, lists it
+// and exports each.
+func TestConditions(t *testing.T) {
+	h := newTestHandler(t)
+	e := echo.New()
+	e.GET("/api/v1/luminaires/:id/conditions", h.ListConditions)
+	e.PUT("/api/v1/luminaires/:id/conditions/:name", h.PutCondition)
+	e.DELETE("/api/v1/luminaires/:id/conditions/:name", h.DeleteCondition)
+	e.GET("/api/v1/luminaires/:id/export", h.Export)
+
+	id := saveSynth(t, h, "full")
+
+	opts := synth.DefaultOptions()
+	opts.Flux, opts.InputWatts = 520, 4.8
+	dimmed, err := synth.Generate(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := parser.Encode(parser.NewIESParser(), dimmed, parser.WriteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	put := func(name string, fields map[string]string) *httptest.ResponseRecorder {
+		return putVariant(e, "conditions", id, name, data, fields)
+	}
+	get := func(path string) *httptest.ResponseRecorder {
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/luminaires/%d%s", id, path), nil))
+		return resp
+	}
+
+	if resp := put("-dim50", nil); resp.Code != http.StatusBadRequest {
+		t.Errorf("bad name: status = %d, want 400", resp.Code)
+	}
+	if resp := put("dim50", map[string]string{"dim_level": "150"}); resp.Code != http.StatusBadRequest {
+		t.Errorf("dim_level 150: status = %d, want 400", resp.Code)
+	}
+	if resp := put("dim50", map[string]string{"dim_level": "50", "ambient_temp": "25"}); resp.Code != http.StatusOK {
+		t.Fatalf("put: status = %d: %s", resp.Code, resp.Body.String())
+	}
+
+	var list struct {
+		Conditions []struct {
+			Name        string   `json:"name"`
+			AmbientTemp *float64 `json:"ambient_temp"`
+			DimLevel    *float64 `json:"dim_level"`
+			InputWatts  float64  `json:"input_watts"`
+		} `json:"conditions"`
+	}
+	json.Unmarshal(get("/conditions").Body.Bytes(), &list)
+	if len(list.Conditions) != 1 {
+		t.Fatalf("conditions = %+v", list.Conditions)
+	}
+	cond := list.Conditions[0]
+	if cond.Name != "dim50" || cond.DimLevel == nil || *cond.DimLevel != 50 || cond.AmbientTemp == nil || *cond.AmbientTemp != 25 || cond.InputWatts != 4.8 {
+		t.Errorf("condition = %+v", cond)
+	}
+
+	exported := func(query string) float64 {
+		t.Helper()
+		resp := get("/export?format=ies" + query)
+		if resp.Code != http.StatusOK {
+			t.Fatalf("export%s: status = %d: %s", query, resp.Code, resp.Body.String())
+		}
+		lum, err := parser.NewIESParser().ParseReader(strings.NewReader(resp.Body.String()), "export.ies")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return lum.Metadata.InputWatts
+	}
+	if w := exported(""); w != 10 {
+		t.Errorf("main export: input watts = %g, want 10", w)
+	}
+	if w := exported("&condition=dim50"); w != 4.8 {
+		t.Errorf("dim50 export: input watts = %g, want 4.8", w)
+	}
+	if resp := get("/export?condition=40C"); resp.Code != http.StatusNotFound {
+		t.Errorf("unknown condition: status = %d, want 404", resp.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/api/v1/luminaires/%d/conditions/dim50", id), nil)
+	resp := httptest.NewRecorder()
+	e.ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Errorf("delete: status = %d", resp.Code)
+	}
+	if resp := get("/export?condition=dim50"); resp.Code != http.StatusNotFound {
+		t.Errorf("deleted condition: status = %d, want 404", resp.Code)
+	}
+}
+
+// TestDimmed interpolates between conditions measured at 50% and 100% and
+// checks that the result is flagged as derived.
+func TestDimmed(t *testing.T) {
+	h := newTestHandler(t)
+	e := echo.New()
+	e.PUT("/api/v1/luminaires/:id/conditions/:name", h.PutCondition)
+	e.GET("/api/v1/luminaires/:id/dimmed", h.Dimmed)
+
+	id := saveSynth(t, h, "full")
+	get := func(query string) *httptest.ResponseRecorder {
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/luminaires/%d/dimmed%s", id, query), nil))
+		return resp
+	}
+	if resp := get("?level=75"); resp.Code != http.StatusUnprocessableEntity {
+		t.Errorf("no dim levels: status = %d, want 422", resp.Code)
+	}
+
+	for _, cond := range []struct {
+		name        string
+		flux, watts float64
+		level       string
+	}{
+		{"dim50", 500, 6, "50"},
+		{"dim100", 1000, 10, "100"},
+	} {
+		opts := synth.DefaultOptions()
+		opts.Flux, opts.InputWatts = cond.flux, cond.watts
+		lum, err := synth.Generate(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := parser.Encode(parser.NewIESParser(), lum, parser.WriteOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp := putVariant(e, "conditions", id, cond.name, data, map[string]string{"dim_level": cond.level}); resp.Code != http.StatusOK {
+			t.Fatalf("put %s: %s", cond.name, resp.Body.String())
+		}
+	}
+
+	for _, query := range []string{"", "?level=0", "?level=abc"} {
+		if resp := get(query); resp.Code != http.StatusBadRequest {
+			t.Errorf("%q: status = %d, want 400", query, resp.Code)
+		}
+	}
+	if resp := get("?level=25"); resp.Code != http.StatusUnprocessableEntity {
+		t.Errorf("below the measured levels: status = %d, want 422", resp.Code)
+	}
+
+	resp := get("?level=75")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", resp.Code, resp.Body.String())
+	}
+	want := "interpolated at 75% from dim50 (50%) and dim100 (100%)"
+	if got := resp.Header().Get("X-Derived"); got != want {
+		t.Errorf("X-Derived = %q, want %q", got, want)
+	}
+	if !strings.Contains(resp.Body.String(), "derived="+want) {
+		t.Errorf("provenance does not flag the file as derived:\n%s", resp.Body.String()[:600])
+	}
+	if got := resp.Header().Get("Content-Disposition"); !strings.Contains(got, "_dim75.ies") {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+	lum, err := parser.NewIESParser().ParseReader(strings.NewReader(resp.Body.String()), "dimmed.ies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lum.Metadata.InputWatts != 8 {
+		t.Errorf("input watts = %g, want 8", lum.Metadata.InputWatts)
+	}
+
+	resp = get("?level=50")
+	if resp.Code != http.StatusOK || resp.Header().Get("X-Derived") != "" {
+		t.Errorf("a measured level: status = %d, X-Derived = %q", resp.Code, resp.Header().Get("X-Derived"))
+	}
+}
+
+// TestLuminaireListsDatabaseError expects a database failure while looking
+// up the luminaire answered as one, not as a missing luminaire.
+func TestLuminaireListsDatabaseError(t *testing.T) {
+	h := newTestHandler(t)
+	e := echo.New()
+	e.GET("/api/v1/luminaires/:id/components", h.ListComponents)
+	e.GET("/api/v1/luminaires/:id/conditions", h.ListConditions)
+	e.GET("/api/v1/luminaires/:id/orientations", h.ListOrientations)
+	id := saveSynth(t, h, "unreachable")
+	h.db.Close()
+
+	for _, list := range []string{"components", "conditions", "orientations"} {
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/luminaires/%d/%s", id, list), nil))
+		if resp.Code != http.StatusInternalServerError {
+			t.Errorf("%s: status %d, want 500", list, resp.Code)
+		}
+	}
+}
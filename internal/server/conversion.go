@@ -0,0 +1,110 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+	"illuminate/internal/logger"
+	"illuminate/internal/parser"
+)
+
+// conversion is one recorded export of a luminaire.
+type conversion struct {
+	ID     int64  `json:"id"`
+	Format string `json:"format"`
+	// Options summarises the non-default write options (see
+	// parser.WriteOptions.Summary).
+	Options string `json:"options"`
+	// Request is the path the export was made through.
+	Request     string `json:"request"`
+	RequestedBy string `json:"requested_by"`
+	ClientIP    string `json:"client_ip,omitempty"`
+	SourceHash  string `json:"source_hash,omitempty"`
+	// ResultHash is the SHA-256 of the file handed out.
+	ResultHash string `json:"result_hash"`
+	Size       int    `json:"size"`
+	CreatedAt  string `json:"created_at"`
+}
+
+// requestedBy names the caller for the conversion history: the workflow role
+// of their token, or "anonymous" without one.
+func (h *LuminaireHandler) requestedBy(c echo.Context) string {
+	if len(h.workflowTokens) == 0 {
+		return "anonymous"
+	}
+	if role := h.callerRole(c); role != database.RoleNone {
+		return role.String()
+	}
+	return "anonymous"
+}
+
+// recordConversion adds an export of luminaire id to its history. A failure
+// is logged rather than failing an export that has already been rendered.
+func (h *LuminaireHandler) recordConversion(c echo.Context, id int64, format string, opts parser.WriteOptions, data []byte) {
+	sum := sha256.Sum256(data)
+	var sourceHash string
+	converted := time.Now().UTC()
+	if opts.Provenance != nil {
+		sourceHash = opts.Provenance.SourceHash
+		if !opts.Provenance.Converted.IsZero() {
+			converted = opts.Provenance.Converted
+		}
+	}
+	_, err := h.db.Exec(`
+		INSERT INTO conversions (luminaire_id, format, options, request, requested_by, client_ip, source_hash, result_hash, size, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		id, strings.ToLower(format), opts.Summary(), c.Request().URL.Path, h.requestedBy(c), c.RealIP(),
+		sourceHash, hex.EncodeToString(sum[:]), len(data), converted.UTC().Format("2006-01-02 15:04:05"),
+	)
+	if err != nil {
+		logger.Default.Warnf("record conversion of luminaire %d: %v", id, err)
+	}
+}
+
+// Conversions lists the exports of a luminaire, newest first:
+// GET /api/v1/luminaires/:id/conversions. ?limit= caps the list at the given
+// number of entries, 100 by default. The history outlives the luminaire.
+func (h *LuminaireHandler) Conversions(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_id")
+	}
+	limit := 100
+	if v := c.QueryParam("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 {
+			return apiError(c, http.StatusBadRequest, "invalid_limit")
+		}
+	}
+
+	rows, err := h.db.Query(`
+		SELECT id, format, options, request, requested_by, client_ip, source_hash, result_hash, size, created_at
+		FROM conversions WHERE luminaire_id = ? ORDER BY id DESC LIMIT ?`, id, limit)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	defer rows.Close()
+
+	conversions := []conversion{}
+	for rows.Next() {
+		var cv conversion
+		if err := rows.Scan(&cv.ID, &cv.Format, &cv.Options, &cv.Request, &cv.RequestedBy, &cv.ClientIP,
+			&cv.SourceHash, &cv.ResultHash, &cv.Size, &cv.CreatedAt); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		conversions = append(conversions, cv)
+	}
+	if err := rows.Err(); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"id":          id,
+		"conversions": conversions,
+	})
+}
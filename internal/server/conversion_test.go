@@ -0,0 +1,77 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+)
+
+func TestConversionHistory(t *testing.T) {
+	h := newTestHandler(t)
+	h.workflowTokens = map[string]database.Role{"rev": database.RoleReviewer}
+	id := saveSynth(t, h, "history")
+
+	e := echo.New()
+	e.GET("/api/v1/luminaires/:id/export", h.Export)
+	e.GET("/api/v1/luminaires/:id/download/:app", h.Download)
+	e.GET("/api/v1/luminaires/:id/conversions", h.Conversions)
+	do := func(target, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if token != "" {
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		}
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, req)
+		return resp
+	}
+	base := fmt.Sprintf("/api/v1/luminaires/%d", id)
+
+	export := do(base+"/export?format=ies&line_length=80", "rev")
+	if export.Code != http.StatusOK {
+		t.Fatalf("export: %d %s", export.Code, export.Body.String())
+	}
+	if resp := do(base+"/download/dialux", ""); resp.Code != http.StatusOK {
+		t.Fatalf("download: %d %s", resp.Code, resp.Body.String())
+	}
+
+	resp := do(base+"/conversions", "")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("conversions: %d %s", resp.Code, resp.Body.String())
+	}
+	var body struct {
+		Conversions []conversion `json:"conversions"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Conversions) != 2 {
+		t.Fatalf("got %d conversions, want 2: %s", len(body.Conversions), resp.Body.String())
+	}
+
+	// Newest first.
+	download, first := body.Conversions[0], body.Conversions[1]
+	if download.Format != "ldt" || download.RequestedBy != "anonymous" || download.Request != base+"/download/dialux" {
+		t.Errorf("download = %+v", download)
+	}
+	sum := sha256.Sum256(export.Body.Bytes())
+	if first.Format != "ies" || first.RequestedBy != "reviewer" || first.Options != "line_length:80" ||
+		first.SourceHash != "history" || first.ResultHash != hex.EncodeToString(sum[:]) || first.Size != export.Body.Len() {
+		t.Errorf("export = %+v", first)
+	}
+
+	if resp := do(base+"/conversions?limit=1", ""); resp.Code != http.StatusOK {
+		t.Errorf("limit: %d", resp.Code)
+	} else if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil || len(body.Conversions) != 1 {
+		t.Errorf("limit=1 returned %d conversions", len(body.Conversions))
+	}
+	if resp := do(base+"/conversions?limit=0", ""); resp.Code != http.StatusBadRequest {
+		t.Errorf("limit=0: status %d", resp.Code)
+	}
+}
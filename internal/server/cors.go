@@ -0,0 +1,49 @@
+package server
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// corsConfigFromEnv builds the CORS policy from CORS_ALLOWED_ORIGINS,
+// CORS_ALLOWED_METHODS, CORS_ALLOWED_HEADERS, CORS_EXPOSE_HEADERS (all comma
+// separated), CORS_ALLOW_CREDENTIALS and CORS_MAX_AGE (seconds). Unset
+// variables keep the defaults below.
+func corsConfigFromEnv() middleware.CORSConfig {
+	cfg := middleware.CORSConfig{
+		AllowOrigins:     []string{"https://*", "http://*"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
+		AllowHeaders:     []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
+		AllowCredentials: true,
+		MaxAge:           300,
+	}
+
+	envList("CORS_ALLOWED_ORIGINS", &cfg.AllowOrigins)
+	envList("CORS_ALLOWED_METHODS", &cfg.AllowMethods)
+	envList("CORS_ALLOWED_HEADERS", &cfg.AllowHeaders)
+	envList("CORS_EXPOSE_HEADERS", &cfg.ExposeHeaders)
+	if v, err := strconv.ParseBool(os.Getenv("CORS_ALLOW_CREDENTIALS")); err == nil {
+		cfg.AllowCredentials = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("CORS_MAX_AGE")); err == nil {
+		cfg.MaxAge = v
+	}
+	return cfg
+}
+
+func envList(key string, dst *[]string) {
+	v := os.Getenv(key)
+	if v == "" {
+		return
+	}
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	*dst = items
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+func TestCORSConfigFromEnv(t *testing.T) {
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://app.example.com, https://admin.example.com")
+	t.Setenv("CORS_ALLOW_CREDENTIALS", "false")
+
+	cfg := corsConfigFromEnv()
+	if len(cfg.AllowOrigins) != 2 || cfg.AllowOrigins[1] != "https://admin.example.com" {
+		t.Errorf("AllowOrigins = %v", cfg.AllowOrigins)
+	}
+	if cfg.AllowCredentials {
+		t.Error("AllowCredentials should be false")
+	}
+	if cfg.MaxAge != 300 {
+		t.Errorf("MaxAge = %d, want default 300", cfg.MaxAge)
+	}
+
+	e := echo.New()
+	e.Use(middleware.CORSWithConfig(cfg))
+	e.GET("/", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+
+	for origin, allowed := range map[string]bool{
+		"https://app.example.com":  true,
+		"https://evil.example.com": false,
+	} {
+		req := httptest.NewRequest(http.MethodOptions, "/", nil)
+		req.Header.Set(echo.HeaderOrigin, origin)
+		req.Header.Set(echo.HeaderAccessControlRequestMethod, http.MethodGet)
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, req)
+
+		got := resp.Header().Get(echo.HeaderAccessControlAllowOrigin) == origin
+		if got != allowed {
+			t.Errorf("origin %s: allowed = %v, want %v", origin, got, allowed)
+		}
+	}
+}
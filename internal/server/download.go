@@ -0,0 +1,198 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+	"illuminate/internal/bundle"
+	"illuminate/internal/database"
+	"illuminate/internal/parser"
+	"illuminate/internal/photometry"
+	"illuminate/internal/revit"
+)
+
+// downloadProfile describes the exact file shape a lighting design
+// application imports without complaint.
+type downloadProfile struct {
+	defaultFormat string
+	encoding      parser.Encoding
+	lineEnding    parser.LineEnding
+	// revit wraps the IES file in a ZIP with a Revit type catalog; see
+	// sendRevitBundle.
+	revit bool
+}
+
+// Both DIALux and Relux are Windows applications that read EULUMDAT as ANSI
+// text; older versions reject UTF-8 names and bare LF line endings outright.
+var downloadProfiles = map[string]downloadProfile{
+	"dialux": {defaultFormat: "ldt", encoding: parser.EncodingWindows1252, lineEnding: parser.LineEndingCRLF},
+	"relux":  {defaultFormat: "ldt", encoding: parser.EncodingWindows1252, lineEnding: parser.LineEndingCRLF},
+	// Revit lighting families take their distribution from an IES
+	// photometric web file.
+	"revit": {defaultFormat: "ies", encoding: parser.EncodingWindows1252, lineEnding: parser.LineEndingCRLF, revit: true},
+}
+
+// formatMIMETypes are the media types photometric downloads are served with.
+var formatMIMETypes = map[string]string{
+	"ies": "application/x-ies-lm-63",
+	"ldt": "application/x-eulumdat",
+	"cie": "application/x-cie-102",
+}
+
+// Download serves a luminaire prepared for one design application:
+// GET /api/v1/luminaires/:id/download/:app?format=ldt. The query options of
+// /export apply on top of the application's encoding and line ending.
+func (h *LuminaireHandler) Download(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_id")
+	}
+
+	app := strings.ToLower(c.Param("app"))
+	profile, ok := downloadProfiles[app]
+	if !ok {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("unsupported application: %s", app)})
+	}
+
+	req, status, err := h.conversionRequest(c, profile.defaultFormat, parser.WriteOptions{
+		LineEnding: profile.lineEnding,
+		Encoding:   profile.encoding,
+	})
+	if err != nil {
+		return c.JSON(status, map[string]string{"error": err.Error()})
+	}
+	if profile.revit && req.format != "ies" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Revit takes IES photometric web files only"})
+	}
+	req.revit = profile.revit
+	return h.sendLuminaireFile(c, id, req)
+}
+
+// sendLuminaireFile renders a stored luminaire as an attachment as req asks.
+func (h *LuminaireHandler) sendLuminaireFile(c echo.Context, id int64, req *conversionRequest) error {
+	format := req.format
+	mimeType, ok := formatMIMETypes[format]
+	if !ok {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("unsupported format: %s", format)})
+	}
+	p, err := req.writer()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	lum, err := req.load(h.db, id)
+	if err != nil {
+		return loadErrorResponse(c, err)
+	}
+	if h.exportBlocked(lum.Metadata.State) {
+		return exportBlockedResponse(c, lum.Metadata.State, h.exportState)
+	}
+	license, err := h.exportLicense(c, id)
+	if errors.Is(err, errLicenseNotAccepted) {
+		return licenseRequiredResponse(c, license)
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	if req.aimed {
+		lum = photometry.Aim(lum, lum.Metadata.AimTilt, lum.Metadata.AimRotation)
+	}
+	opts, licenseIssues := embedLicense(license, format, req.optionsFor(lum.Metadata, format))
+	opts.Provenance = req.provenance(lum.Metadata.FileHash)
+	opts.Provenance.Derived = req.derived
+	data, issues, err := parser.Convert(p, lum, opts)
+	if errors.Is(err, parser.ErrDowngrade) {
+		return downgradeErrorResponse(c, err)
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	setExportIssues(c, append(issues, licenseIssues...))
+	setLicenseLink(c, license)
+	if req.derived != "" {
+		c.Response().Header().Set("X-Derived", req.derived)
+	}
+	h.recordConversion(c, id, format, opts, data)
+
+	encoding := opts.Encoding
+	if encoding == "" {
+		encoding = parser.EncodingUTF8
+	}
+	filename := downloadFilename(lum.Metadata, format)
+	if req.dimLevel > 0 {
+		filename = fmt.Sprintf("%s_dim%g.%s", strings.TrimSuffix(filename, "."+format), req.dimLevel, format)
+	} else if variant := req.component + req.orientation; variant != "" {
+		filename = fmt.Sprintf("%s_%s.%s", strings.TrimSuffix(filename, "."+format), unsafeFilenameChars.ReplaceAllString(variant, "-"), format)
+	}
+	if req.revit {
+		return h.sendRevitBundle(c, lum.Metadata, filename, data)
+	}
+	c.Response().Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	return c.Blob(http.StatusOK, fmt.Sprintf("%s; charset=%s", mimeType, encoding), data)
+}
+
+// sendRevitBundle answers /download/revit with a ZIP of the IES file called
+// filename and, under the same stem, its type parameters as a Revit type
+// catalog (.txt) and as JSON, so a BIM manager loads the fixture with its
+// metadata. The ZIP carries SHA256SUMS like collection exports.
+func (h *LuminaireHandler) sendRevitBundle(c echo.Context, meta database.Luminaire, filename string, data []byte) error {
+	stem := strings.TrimSuffix(filename, ".ies")
+	types := []revit.Type{revit.NewType(stem, meta, filename)}
+	var buf bytes.Buffer
+	zw := bundle.NewWriter(&buf, h.signingKey)
+	if err := addRevitFiles(zw, stem, filename, data, types); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if err := zw.Close(); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	c.Response().Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, stem))
+	return c.Blob(http.StatusOK, "application/zip", buf.Bytes())
+}
+
+// addRevitFiles adds the IES file called filename, unless it is empty, and
+// the type catalog and JSON of types as stem.txt and stem.json.
+func addRevitFiles(zw *bundle.Writer, stem, filename string, data []byte, types []revit.Type) error {
+	if filename != "" {
+		if err := zw.Add(filename, data); err != nil {
+			return err
+		}
+	}
+	sheet, err := json.MarshalIndent(map[string]interface{}{"types": types}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := zw.Add(stem+".txt", revit.TypeCatalog(types)); err != nil {
+		return err
+	}
+	return zw.Add(stem+".json", sheet)
+}
+
+var unsafeFilenameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// downloadFilename is an ASCII-only name, which every Windows file dialog and
+// import wizard accepts. Accents are folded ("Lümen" becomes "Lumen").
+func downloadFilename(lum database.Luminaire, format string) string {
+	name := strings.TrimSpace(lum.Manufacturer + "_" + lum.Model)
+	if folded, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn))), name); err == nil {
+		name = folded
+	}
+	stem := unsafeFilenameChars.ReplaceAllString(name, "-")
+	stem = strings.Trim(stem, "-_.")
+	if stem == "" {
+		stem = fmt.Sprintf("luminaire_%d", lum.ID)
+	}
+	return stem + "." + format
+}
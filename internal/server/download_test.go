@@ -0,0 +1,573 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	_ "github.com/mattn/go-sqlite3"
+	"illuminate/internal/database"
+	"illuminate/internal/parser"
+	"illuminate/internal/synth"
+)
+
+// newTestHandler returns a handler backed by a fresh, migrated SQLite file.
+func newTestHandler(t *testing.T) *LuminaireHandler {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrations, _ := filepath.Glob("../database/migrations/*.sql")
+	for _, m := range migrations {
+		content, err := os.ReadFile(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(string(content)); err != nil {
+			t.Fatalf("%s: %v", m, err)
+		}
+	}
+	return &LuminaireHandler{db: db}
+}
+
+// saveSynth stores a luminaire generated with the default options under the
+// file hash hash, after applying opts to it, and returns its id.
+func saveSynth(t *testing.T, h *LuminaireHandler, hash string, opts ...func(*database.ParsedLuminaire)) int64 {
+	t.Helper()
+	lum, err := synth.Generate(synth.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lum.Metadata.FileHash = hash
+	for _, opt := range opts {
+		opt(lum)
+	}
+	id, err := h.saveLuminaire(lum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// TestDownloadInteropMatrix checks every application profile against every
+// format: media type, ANSI encoding, CRLF line endings, provenance and that
+// the file still parses.
+func TestDownloadInteropMatrix(t *testing.T) {
+	h := newTestHandler(t)
+
+	id := saveSynth(t, h, "interop", func(lum *database.ParsedLuminaire) {
+		lum.Metadata.Manufacturer = "Lümen Licht"
+	})
+
+	e := echo.New()
+	e.GET("/api/v1/luminaires/:id/download/:app", h.Download)
+
+	for app, profile := range downloadProfiles {
+		if profile.revit {
+			continue // a ZIP, see TestDownloadRevit
+		}
+		for format, mimeType := range formatMIMETypes {
+			t.Run(app+"/"+format, func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/luminaires/%d/download/%s?format=%s", id, app, format), nil)
+				resp := httptest.NewRecorder()
+				e.ServeHTTP(resp, req)
+
+				if resp.Code != http.StatusOK {
+					t.Fatalf("status = %d: %s", resp.Code, resp.Body.String())
+				}
+				if got := resp.Header().Get(echo.HeaderContentType); got != mimeType+"; charset="+string(profile.encoding) {
+					t.Errorf("Content-Type = %q", got)
+				}
+				wantName := `filename="Lumen-Licht_SYNTH-lambertian.` + format + `"`
+				if got := resp.Header().Get("Content-Disposition"); !strings.Contains(got, wantName) {
+					t.Errorf("Content-Disposition = %q, want %s", got, wantName)
+				}
+
+				body := resp.Body.Bytes()
+				if n, crlf := bytes.Count(body, []byte("\n")), bytes.Count(body, []byte("\r\n")); n == 0 || n != crlf {
+					t.Errorf("%d line feeds, %d of them CRLF", n, crlf)
+				}
+				if format != "cie" {
+					if !bytes.Contains(body, []byte("L\xfcmen Licht")) {
+						t.Error("manufacturer is not Windows-1252 encoded")
+					}
+					if bytes.Contains(body, []byte("ü")) {
+						t.Error("output still contains UTF-8")
+					}
+				}
+				if !bytes.Contains(body, []byte("illuminate "+parser.Version)) || !bytes.Contains(body, []byte("interop")) {
+					t.Error("download carries no provenance")
+				}
+
+				path := filepath.Join(t.TempDir(), "download."+format)
+				if err := os.WriteFile(path, body, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				p, _ := parser.GetParser(path)
+				parsed, err := p.Parse(path)
+				if err != nil {
+					t.Fatalf("download does not parse back: %v", err)
+				}
+				if len(parsed.CandelaMatrix) == 0 {
+					t.Error("parsed download has no candela data")
+				}
+			})
+		}
+	}
+
+	for _, path := range []string{"/api/v1/luminaires/999/download/dialux", "/api/v1/luminaires/1/download/agi32"} {
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, path, nil))
+		if resp.Code == http.StatusOK {
+			t.Errorf("%s: status = 200", path)
+		}
+	}
+}
+
+// TestDownloadRevit checks the Revit bundle: the IES file, and a type
+// catalog and JSON naming it with the luminaire's parameters.
+func TestDownloadRevit(t *testing.T) {
+	h := newTestHandler(t)
+	lum, err := synth.Generate(synth.Options{Distribution: synth.Lambertian, Manufacturer: "Acme", Model: "DL-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lum.Metadata.CatalogNumber = "DL1-830"
+	lum.Metadata.InputWatts = 24.5
+	lum.Metadata.ColorTemp = 3000
+	lum.Metadata.CRI = 0
+	lum.Metadata.LuminousLength = 0.6
+	id, err := h.saveLuminaire(lum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := echo.New()
+	e.GET("/api/v1/luminaires/:id/download/:app", h.Download)
+	get := func(query string) *httptest.ResponseRecorder {
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/luminaires/%d/download/revit%s", id, query), nil))
+		return resp
+	}
+
+	if resp := get("?format=ldt"); resp.Code != http.StatusBadRequest {
+		t.Errorf("format=ldt: status = %d, want 400", resp.Code)
+	}
+	resp := get("")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", resp.Code, resp.Body.String())
+	}
+	if cd := resp.Header().Get("Content-Disposition"); !strings.Contains(cd, `filename="Acme_DL-1.zip"`) {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(resp.Body.Bytes()), int64(resp.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		r, _ := f.Open()
+		data, _ := io.ReadAll(r)
+		files[f.Name] = string(data)
+	}
+	if _, err := parser.NewIESParser().ParseReader(strings.NewReader(files["Acme_DL-1.ies"]), "Acme_DL-1.ies"); err != nil {
+		t.Errorf("bundled IES file: %v", err)
+	}
+	rows := strings.Split(files["Acme_DL-1.txt"], "\r\n")
+	if len(rows) != 3 || !strings.HasPrefix(rows[0], ",Manufacturer##other##,") ||
+		!strings.Contains(rows[0], "Wattage##electrical_power##watts") {
+		t.Fatalf("type catalog = %q", files["Acme_DL-1.txt"])
+	}
+	if !strings.HasPrefix(rows[1], "Acme_DL-1,Acme,DL-1,DL1-830,") ||
+		!strings.Contains(rows[1], ",Acme_DL-1.ies,24.5,") || !strings.Contains(rows[1], ",3000,,600,") {
+		t.Errorf("type row = %q", rows[1])
+	}
+	if !strings.Contains(files["Acme_DL-1.json"], `"type_name": "Acme_DL-1"`) {
+		t.Errorf("JSON = %s", files["Acme_DL-1.json"])
+	}
+}
+
+// TestDownloadRestoresExtensions checks that format-specific fields of the
+// source are stored and written back to a file of the same format.
+func TestDownloadRestoresExtensions(t *testing.T) {
+	h := newTestHandler(t)
+	extensions := database.Extensions{"ldt:light_output_ratio": "85.5", "ies:NEARFIELD": "1 0.5 0.5"}
+	id := saveSynth(t, h, "extensions", func(lum *database.ParsedLuminaire) {
+		lum.Extensions = extensions
+	})
+
+	stored, err := database.LoadParsedLuminaire(h.db, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(stored.Extensions, extensions) {
+		t.Errorf("stored extensions = %v", stored.Extensions)
+	}
+
+	e := echo.New()
+	e.GET("/api/v1/luminaires/:id/download/:app", h.Download)
+	for format, want := range map[string]string{"ldt": "\r\n85.5\r\n", "ies": "[NEARFIELD] 1 0.5 0.5\r\n"} {
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/luminaires/%d/download/dialux?format=%s", id, format), nil))
+		if resp.Code != http.StatusOK || !strings.Contains(resp.Body.String(), want) {
+			t.Errorf("%s download lacks %q: %d\n%s", format, want, resp.Code, resp.Body.String())
+		}
+	}
+}
+
+// TestExportKeepsOrientation exports an asymmetric distribution after a trip
+// through the database and checks every plane comes back where it was.
+func TestExportKeepsOrientation(t *testing.T) {
+	h := newTestHandler(t)
+	opts := synth.DefaultOptions()
+	opts.Distribution = synth.Street
+	opts.HorizontalStep = 30
+	lum, err := synth.Generate(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lum.Metadata.FileHash = "orientation"
+	id, err := h.saveLuminaire(lum)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := echo.New()
+	e.GET("/api/v1/luminaires/:id/export", h.Export)
+	export := func(format string) *httptest.ResponseRecorder {
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/luminaires/%d/export?format=%s", id, format), nil))
+		return resp
+	}
+
+	for _, format := range []string{"ies", "ldt"} {
+		resp := export(format)
+		if resp.Code != http.StatusOK {
+			t.Fatalf("%s export: %d %s", format, resp.Code, resp.Body.String())
+		}
+		p, _ := parser.GetParser("out." + format)
+		back, err := p.ParseReader(resp.Body, "out."+format)
+		if err != nil {
+			t.Fatalf("%s re-parse: %v", format, err)
+		}
+		if err := back.CheckShape(); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if !reflect.DeepEqual(back.VerticalAngles, lum.VerticalAngles) {
+			t.Errorf("%s vertical angles = %v", format, back.VerticalAngles)
+		}
+		// EULUMDAT leaves out the 360° plane that repeats C0.
+		for i, c := range back.HorizontalAngles {
+			if c != lum.HorizontalAngles[i] {
+				t.Fatalf("%s plane %d at C%g, want C%g", format, i, c, lum.HorizontalAngles[i])
+			}
+			for j, v := range back.CandelaMatrix[i] {
+				if want := lum.CandelaMatrix[i][j]; math.Abs(v-want) > 0.1 {
+					t.Fatalf("%s candela at C%g γ%g = %g, want %g", format, c, back.VerticalAngles[j], v, want)
+				}
+			}
+		}
+	}
+
+	if _, err := h.db.Exec(`UPDATE photometric_data SET candela_values = '1,2,3' WHERE luminaire_id = ?`, id); err != nil {
+		t.Fatal(err)
+	}
+	if resp := export("ies"); resp.Code != http.StatusUnprocessableEntity {
+		t.Errorf("malformed matrix exported: %d %s", resp.Code, resp.Body.String())
+	}
+}
+
+// TestExportRoundTripsCorpusAngles stores every corpus file that declares an
+// angle grid and checks the exported file carries the stored angles, not a
+// default set.
+func TestExportRoundTripsCorpusAngles(t *testing.T) {
+	h := newTestHandler(t)
+	e := echo.New()
+	e.GET("/api/v1/luminaires/:id/export", h.Export)
+
+	files, _ := filepath.Glob("../parser/testdata/corpus/*")
+	for _, path := range files {
+		src, err := parser.GetReader(path)
+		if err != nil || strings.HasSuffix(path, ".json") {
+			continue
+		}
+		lum, err := src.Parse(path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if len(lum.HorizontalAngles) == 0 {
+			continue
+		}
+		id, err := h.saveLuminaire(lum)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+
+		formats := []string{"ies"}
+		if ext := strings.TrimPrefix(filepath.Ext(path), "."); ext == "ldt" {
+			formats = append(formats, ext)
+		}
+		for _, format := range formats {
+			t.Run(filepath.Base(path)+"."+format, func(t *testing.T) {
+				resp := httptest.NewRecorder()
+				e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/luminaires/%d/export?format=%s", id, format), nil))
+				if resp.Code != http.StatusOK {
+					t.Fatalf("export: %d %s", resp.Code, resp.Body.String())
+				}
+				p, _ := parser.GetParser("out." + format)
+				back, err := p.ParseReader(resp.Body, "out."+format)
+				if err != nil {
+					t.Fatalf("re-parse: %v", err)
+				}
+				if !reflect.DeepEqual(back.VerticalAngles, lum.VerticalAngles) {
+					t.Errorf("vertical angles = %v, want %v", back.VerticalAngles, lum.VerticalAngles)
+				}
+				if !reflect.DeepEqual(back.HorizontalAngles, lum.HorizontalAngles) {
+					t.Errorf("horizontal angles = %v, want %v", back.HorizontalAngles, lum.HorizontalAngles)
+				}
+				if err := back.CheckShape(); err != nil {
+					t.Error(err)
+				}
+			})
+		}
+	}
+}
+
+// TestExportWritesStoredKeywords checks the stored luminaire columns reach
+// the keywords of an exported IES file and read back unchanged.
+func TestExportWritesStoredKeywords(t *testing.T) {
+	h := newTestHandler(t)
+	lum, err := synth.Generate(synth.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := &lum.Metadata
+	meta.FileHash = "keywords"
+	meta.TestLab = "Independent Testing Lab"
+	meta.TestNumber = "ITL-12345"
+	meta.TestDate = "2024-03-01"
+	meta.IssueDate = "2024-03-15"
+	meta.LampCatalog = "LED-830-18W"
+	meta.Ballast = "Driver DR-350"
+	meta.LuminaireDesc = "Recessed downlight"
+	meta.LuminaireCandela = "2450"
+	meta.ColorTemp = 3000
+	meta.CRI = 90
+	id, err := h.saveLuminaire(lum)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := echo.New()
+	e.GET("/api/v1/luminaires/:id/export", h.Export)
+	resp := httptest.NewRecorder()
+	e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/luminaires/%d/export?format=ies", id), nil))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("export: %d %s", resp.Code, resp.Body.String())
+	}
+	body := resp.Body.String()
+	for _, want := range []string{
+		"[TESTLAB] Independent Testing Lab", "[TEST] ITL-12345", "[TESTDATE] 2024-03-01",
+		"[ISSUEDATE] 2024-03-15", "[LAMPCAT] LED-830-18W", "[BALLAST] Driver DR-350",
+		"[LUMINAIRE] Recessed downlight", "[LUMINAIRE_CANDELA] 2450", "[_CCT] 3000", "[_CRI] 90",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("export lacks %q", want)
+		}
+	}
+
+	back, err := parser.NewIESParser().ParseReader(strings.NewReader(body), "out.ies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := back.Metadata
+	if got.TestLab != meta.TestLab || got.TestDate != meta.TestDate || got.LampCatalog != meta.LampCatalog ||
+		got.Ballast != meta.Ballast || got.LuminaireCandela != meta.LuminaireCandela ||
+		got.ColorTemp != meta.ColorTemp || got.CRI != meta.CRI {
+		t.Errorf("re-parsed metadata = %+v", got)
+	}
+	if len(back.Extensions) != 0 {
+		t.Errorf("model keywords kept as extensions: %v", back.Extensions)
+	}
+}
+
+// TestExportFollowsSourceVersion checks the detected source version is
+// stored, listed and picks the line length of an IES export.
+func TestExportFollowsSourceVersion(t *testing.T) {
+	h := newTestHandler(t)
+	lum, err := parser.NewIESParser().Parse("../parser/testdata/corpus/ies_dialect_no_tilt.ies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lum.Metadata.LuminaireDesc = strings.Repeat("Linear pendant with opal diffuser ", 4)
+	id, err := h.saveLuminaire(lum)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := echo.New()
+	e.GET("/api/v1/luminaires", h.List)
+	e.GET("/api/v1/luminaires/:id", h.Get)
+	e.GET("/api/v1/luminaires/:id/export", h.Export)
+	get := func(path string) string {
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, path, nil))
+		if resp.Code != http.StatusOK {
+			t.Fatalf("%s: %d %s", path, resp.Code, resp.Body.String())
+		}
+		return resp.Body.String()
+	}
+
+	for _, want := range []string{`"format_version":"LM-63-1995","format_confidence":1`, `"goniometer":{"system":"C-gamma"`} {
+		if body := get(fmt.Sprintf("/api/v1/luminaires/%d", id)); !strings.Contains(body, want) {
+			t.Errorf("Get lacks %s: %s", want, body)
+		}
+	}
+	if body := get("/api/v1/luminaires"); !strings.Contains(body, `"format_version":"LM-63-1995"`) {
+		t.Errorf("List lacks the format version: %s", body)
+	}
+
+	longest := func(body string) int {
+		n := 0
+		for _, line := range strings.Split(body, "\n") {
+			n = max(n, len(line))
+		}
+		return n
+	}
+	if n := longest(get(fmt.Sprintf("/api/v1/luminaires/%d/export?format=ies", id))); n > parser.LegacyLineLength {
+		t.Errorf("LM-63-1995 source exported with %d-column lines", n)
+	}
+	if n := longest(get(fmt.Sprintf("/api/v1/luminaires/%d/export?format=ies&line_length=256", id))); n <= parser.LegacyLineLength {
+		t.Errorf("line_length=256 still wrapped at %d columns", n)
+	}
+}
+
+// TestExportAimed stores an aiming through Update and checks the aimed export
+// moves the beam there while the default export stays as measured.
+func TestExportAimed(t *testing.T) {
+	h := newTestHandler(t)
+	opts := synth.DefaultOptions()
+	opts.Distribution = synth.NarrowBeam
+	lum, err := synth.Generate(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lum.Metadata.FileHash = "aimed"
+	id, err := h.saveLuminaire(lum)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := echo.New()
+	e.PUT("/api/v1/luminaires/:id", h.Update)
+	e.GET("/api/v1/luminaires/:id/export", h.Export)
+	do := func(method, path, form string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(form))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, req)
+		return resp
+	}
+
+	base := fmt.Sprintf("/api/v1/luminaires/%d", id)
+	if resp := do(http.MethodPut, base, "aim_tilt=sideways"); resp.Code != http.StatusBadRequest {
+		t.Errorf("invalid tilt: %d", resp.Code)
+	}
+	if resp := do(http.MethodPut, base, "aim_tilt=40&aim_rotation=90"); resp.Code != http.StatusOK {
+		t.Fatalf("update: %d %s", resp.Code, resp.Body.String())
+	}
+	stored, err := database.LoadParsedLuminaire(h.db, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Metadata.AimTilt != 40 || stored.Metadata.AimRotation != 90 {
+		t.Fatalf("stored aiming = %g/%g", stored.Metadata.AimTilt, stored.Metadata.AimRotation)
+	}
+
+	peakAt := func(orientation string) (float64, float64) {
+		resp := do(http.MethodGet, base+"/export?format=ies&orientation="+orientation, "")
+		if resp.Code != http.StatusOK {
+			t.Fatalf("%s export: %d %s", orientation, resp.Code, resp.Body.String())
+		}
+		back, err := parser.NewIESParser().ParseReader(resp.Body, "out.ies")
+		if err != nil {
+			t.Fatal(err)
+		}
+		best, c, g := -1.0, 0.0, 0.0
+		for i, row := range back.CandelaMatrix {
+			for j, v := range row {
+				if v > best {
+					best, c, g = v, back.HorizontalAngles[i], back.VerticalAngles[j]
+				}
+			}
+		}
+		return c, g
+	}
+	if _, g := peakAt("measured"); g != 0 {
+		t.Errorf("measured export peaks at γ%g, want nadir", g)
+	}
+	if c, g := peakAt("aimed"); c != 90 || math.Abs(g-40) > 1 {
+		t.Errorf("aimed export peaks at C%g γ%g, want C90 γ40", c, g)
+	}
+	if resp := do(http.MethodGet, base+"/export?orientation=upside-down", ""); resp.Code != http.StatusBadRequest {
+		t.Errorf("unknown orientation: %d", resp.Code)
+	}
+}
+
+// TestUpdateMaintenance stores lumen maintenance data through Update and
+// checks it reaches IES exports as user keywords.
+func TestUpdateMaintenance(t *testing.T) {
+	h := newTestHandler(t)
+	id := saveSynth(t, h, "maintenance")
+
+	e := echo.New()
+	e.PUT("/api/v1/luminaires/:id", h.Update)
+	e.GET("/api/v1/luminaires/:id/export", h.Export)
+	do := func(method, path, form string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(form))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, req)
+		return resp
+	}
+
+	base := fmt.Sprintf("/api/v1/luminaires/%d", id)
+	for _, form := range []string{"lamp_lumen_depreciation=1.2", "driver_maintenance_factor=0", "rated_life=long"} {
+		if resp := do(http.MethodPut, base, form); resp.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", form, resp.Code)
+		}
+	}
+	if resp := do(http.MethodPut, base, "lamp_lumen_depreciation=0.85&driver_maintenance_factor=0.95&rated_life=60000"); resp.Code != http.StatusOK {
+		t.Fatalf("update: %d %s", resp.Code, resp.Body.String())
+	}
+
+	resp := do(http.MethodGet, base+"/export?format=ies", "")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("export: %d %s", resp.Code, resp.Body.String())
+	}
+	for _, want := range []string{"[_LLD] 0.85", "[_DMF] 0.95", "[_RATEDLIFE] 60000"} {
+		if !strings.Contains(resp.Body.String(), want) {
+			t.Errorf("export lacks %q", want)
+		}
+	}
+	back, err := parser.NewIESParser().ParseReader(resp.Body, "out.ies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if llf := back.Metadata.LightLossFactor(); math.Abs(llf-0.85*0.95) > 1e-9 {
+		t.Errorf("light loss factor read back = %g", llf)
+	}
+}
@@ -0,0 +1,274 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+	"illuminate/internal/logger"
+)
+
+// driver is a driver or ballast shared by the luminaires that use it. Watts
+// is the input power of the driven luminaire and Efficiency the driver's
+// output over input power, 0..1; both are zero when unknown.
+type driver struct {
+	Name            string  `json:"name"`
+	Manufacturer    string  `json:"manufacturer"`
+	Model           string  `json:"model"`
+	Watts           float64 `json:"watts"`
+	DimmingProtocol string  `json:"dimming_protocol"`
+	Efficiency      float64 `json:"efficiency"`
+	UpdatedAt       string  `json:"updated_at,omitempty"`
+}
+
+// dimmingProtocols are the dimming protocols a driver may name, by their
+// lower-case form.
+var dimmingProtocols = map[string]string{
+	"none": "none", "0-10v": "0-10V", "1-10v": "1-10V", "dali": "DALI",
+	"dali-2": "DALI-2", "d4i": "D4i", "dmx": "DMX", "triac": "TRIAC",
+	"elv": "ELV", "pwm": "PWM", "zigbee": "Zigbee", "bluetooth": "Bluetooth",
+}
+
+// ListDrivers returns every driver with its number of luminaires.
+func (h *LuminaireHandler) ListDrivers(c echo.Context) error {
+	rows, err := h.db.Query(`
+		SELECT d.name, d.manufacturer, d.model, d.watts, d.dimming_protocol,
+			d.efficiency, d.updated_at, COUNT(l.luminaire_id)
+		FROM drivers d LEFT JOIN luminaire_drivers l ON l.driver_id = d.id
+		GROUP BY d.id ORDER BY d.name`)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	defer rows.Close()
+
+	type listedDriver struct {
+		driver
+		Luminaires int `json:"luminaires"`
+	}
+	drivers := []listedDriver{}
+	for rows.Next() {
+		var d listedDriver
+		if err := rows.Scan(&d.Name, &d.Manufacturer, &d.Model, &d.Watts, &d.DimmingProtocol,
+			&d.Efficiency, &d.UpdatedAt, &d.Luminaires); err != nil {
+			continue
+		}
+		drivers = append(drivers, d)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"drivers": drivers,
+	})
+}
+
+// GetDriver returns a driver and the luminaires using it.
+func (h *LuminaireHandler) GetDriver(c echo.Context) error {
+	d, _, err := loadDriver(h.db, c.Param("name"))
+	if errors.Is(err, sql.ErrNoRows) {
+		return apiError(c, http.StatusNotFound, "driver_not_found")
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	luminaires, err := h.findLuminaires(c, h.db, &database.Filter{
+		Terms: []database.FilterTerm{{Field: "driver", Op: "=", Value: d.Name}},
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if luminaires == nil {
+		luminaires = []database.Luminaire{}
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"driver":     d,
+		"luminaires": luminaires,
+	})
+}
+
+// PutDriver creates or replaces a driver from a JSON body such as
+// {"manufacturer": "Acme", "model": "LD-40", "watts": 41.5,
+// "dimming_protocol": "DALI-2", "efficiency": 0.9}. Luminaires using the
+// driver take its watts and model as their input watts and ballast.
+func (h *LuminaireHandler) PutDriver(c echo.Context) error {
+	name := c.Param("name")
+	if !profileNameRegex.MatchString(name) {
+		return apiError(c, http.StatusBadRequest, "invalid_driver_name")
+	}
+
+	var body driver
+	dec := json.NewDecoder(c.Request().Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&body); err != nil {
+		return apiErrorWith(c, http.StatusBadRequest, "invalid_driver", map[string]string{"detail": err.Error()})
+	}
+	if body.Watts < 0 {
+		return apiError(c, http.StatusBadRequest, "driver_watts_negative")
+	}
+	if body.Efficiency < 0 || body.Efficiency > 1 {
+		return apiError(c, http.StatusBadRequest, "driver_efficiency_out_of_range")
+	}
+	if body.DimmingProtocol != "" {
+		protocol, ok := dimmingProtocols[strings.ToLower(body.DimmingProtocol)]
+		if !ok {
+			return apiErrorWith(c, http.StatusBadRequest, "dimming_protocol_unknown", map[string]string{"protocol": body.DimmingProtocol})
+		}
+		body.DimmingProtocol = protocol
+	}
+
+	_, err := h.db.Exec(`
+		INSERT INTO drivers (name, manufacturer, model, watts, dimming_protocol, efficiency)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET manufacturer = excluded.manufacturer,
+			model = excluded.model, watts = excluded.watts,
+			dimming_protocol = excluded.dimming_protocol,
+			efficiency = excluded.efficiency, updated_at = CURRENT_TIMESTAMP`,
+		name, body.Manufacturer, body.Model, body.Watts, body.DimmingProtocol, body.Efficiency,
+	)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	updated, err := h.applyDriver(`driver_id = (SELECT id FROM drivers WHERE name = ?)`, name)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"status": "saved", "luminaires_updated": updated})
+}
+
+// DeleteDriver removes a driver and unlinks its luminaires, which keep the
+// values it gave them.
+func (h *LuminaireHandler) DeleteDriver(c echo.Context) error {
+	name := c.Param("name")
+	if _, err := h.db.Exec(`DELETE FROM luminaire_drivers WHERE driver_id IN (SELECT id FROM drivers WHERE name = ?)`, name); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if _, err := h.db.Exec("DELETE FROM drivers WHERE name = ?", name); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// LinkDriver makes a luminaire use a driver, replacing any driver it used,
+// and applies the driver's values to it: PUT /api/v1/drivers/:name/luminaires/:id.
+func (h *LuminaireHandler) LinkDriver(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_id")
+	}
+
+	_, driverID, err := loadDriver(h.db, c.Param("name"))
+	if errors.Is(err, sql.ErrNoRows) {
+		return apiError(c, http.StatusNotFound, "driver_not_found")
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	var exists int
+	if err := h.db.QueryRow(`SELECT COUNT(*) FROM luminaires WHERE id = ? AND `+database.NotDeleted, id).Scan(&exists); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if exists == 0 {
+		return apiError(c, http.StatusNotFound, "luminaire_not_found")
+	}
+
+	_, err = h.db.Exec(`INSERT OR REPLACE INTO luminaire_drivers (luminaire_id, driver_id) VALUES (?, ?)`, id, driverID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if _, err := h.applyDriver(`luminaire_id = ?`, id); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"status": "linked"})
+}
+
+// UnlinkDriver stops a luminaire using a driver; its values stay.
+func (h *LuminaireHandler) UnlinkDriver(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_id")
+	}
+
+	res, err := h.db.Exec(`
+		DELETE FROM luminaire_drivers WHERE luminaire_id = ?
+		AND driver_id = (SELECT id FROM drivers WHERE name = ?)`, id, c.Param("name"))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return apiError(c, http.StatusNotFound, "driver_not_linked")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"status": "unlinked"})
+}
+
+// applyDriver copies the driver's known watts and model onto the luminaires
+// whose luminaire_drivers rows match where, then refreshes what depends on
+// them. It returns how many luminaires were updated.
+func (h *LuminaireHandler) applyDriver(where string, args ...any) (int, error) {
+	rows, err := h.db.Query(`SELECT luminaire_id FROM luminaire_drivers WHERE `+where, args...)
+	if err != nil {
+		return 0, err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		_, err := h.db.Exec(`
+			UPDATE luminaires SET
+				input_watts = CASE WHEN d.watts > 0 THEN d.watts ELSE luminaires.input_watts END,
+				ballast = COALESCE(NULLIF(TRIM(d.manufacturer || ' ' || d.model), ''), luminaires.ballast),
+				updated_at = CURRENT_TIMESTAMP
+			FROM luminaire_drivers l JOIN drivers d ON d.id = l.driver_id
+			WHERE l.luminaire_id = luminaires.id AND luminaires.id = ?`, id)
+		if err != nil {
+			return 0, err
+		}
+		if err := refreshMetrics(h.db, id); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			logger.Default.Warnf("refresh metrics for luminaire %d: %v", id, err)
+		}
+		if err := refreshValidation(h.db, id, h.rules("")); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			logger.Default.Warnf("revalidate luminaire %d: %v", id, err)
+		}
+		h.events.publish(eventUpdated, id)
+	}
+	return len(ids), nil
+}
+
+// loadDriver returns a driver by name with its row id.
+func loadDriver(db *sql.DB, name string) (driver, int64, error) {
+	var d driver
+	var id int64
+	err := db.QueryRow(`
+		SELECT id, name, manufacturer, model, watts, dimming_protocol, efficiency, updated_at
+		FROM drivers WHERE name = ?`, name,
+	).Scan(&id, &d.Name, &d.Manufacturer, &d.Model, &d.Watts, &d.DimmingProtocol, &d.Efficiency, &d.UpdatedAt)
+	return d, id, err
+}
+
+// luminaireDriver returns the driver a luminaire uses, or nil.
+func luminaireDriver(db *sql.DB, id int64) *driver {
+	var name string
+	if err := db.QueryRow(`
+		SELECT d.name FROM luminaire_drivers l JOIN drivers d ON d.id = l.driver_id
+		WHERE l.luminaire_id = ?`, id).Scan(&name); err != nil {
+		return nil
+	}
+	d, _, err := loadDriver(db, name)
+	if err != nil {
+		return nil
+	}
+	return &d
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+)
+
+func TestDrivers(t *testing.T) {
+	h := newTestHandler(t)
+	var ids []int64
+	for i := range 3 {
+		id := saveSynth(t, h, fmt.Sprintf("driver-%d", i))
+		ids = append(ids, id)
+	}
+
+	e := echo.New()
+	e.GET("/api/v1/drivers", h.ListDrivers)
+	e.GET("/api/v1/drivers/:name", h.GetDriver)
+	e.PUT("/api/v1/drivers/:name", h.PutDriver)
+	e.DELETE("/api/v1/drivers/:name", h.DeleteDriver)
+	e.PUT("/api/v1/drivers/:name/luminaires/:id", h.LinkDriver)
+	e.DELETE("/api/v1/drivers/:name/luminaires/:id", h.UnlinkDriver)
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return resp
+	}
+	watts := func(id int64) (float64, string) {
+		t.Helper()
+		lum, err := database.LoadParsedLuminaire(h.db, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return lum.Metadata.InputWatts, lum.Metadata.Ballast
+	}
+
+	for _, body := range []string{`{"efficiency": 1.5}`, `{"dimming_protocol": "morse"}`, `{"volts": 230}`} {
+		if resp := do(http.MethodPut, "/api/v1/drivers/ld40", body); resp.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d", body, resp.Code)
+		}
+	}
+	if resp := do(http.MethodPut, "/api/v1/drivers/ld40/luminaires/1", ""); resp.Code != http.StatusNotFound {
+		t.Errorf("link to missing driver: status = %d", resp.Code)
+	}
+	resp := do(http.MethodPut, "/api/v1/drivers/ld40", `{"manufacturer": "Acme", "model": "LD-40", "watts": 41.5, "dimming_protocol": "dali-2", "efficiency": 0.9}`)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("put: status = %d: %s", resp.Code, resp.Body.String())
+	}
+	for _, id := range ids[:2] {
+		if resp := do(http.MethodPut, fmt.Sprintf("/api/v1/drivers/ld40/luminaires/%d", id), ""); resp.Code != http.StatusOK {
+			t.Fatalf("link %d: status = %d: %s", id, resp.Code, resp.Body.String())
+		}
+	}
+	if w, ballast := watts(ids[0]); w != 41.5 || ballast != "Acme LD-40" {
+		t.Errorf("linked luminaire has %v W, ballast %q", w, ballast)
+	}
+
+	// Editing the driver updates every luminaire using it, and no other.
+	resp = do(http.MethodPut, "/api/v1/drivers/ld40", `{"manufacturer": "Acme", "model": "LD-40", "watts": 38, "dimming_protocol": "DALI-2"}`)
+	if !strings.Contains(resp.Body.String(), `"luminaires_updated":2`) {
+		t.Errorf("re-put = %s", resp.Body.String())
+	}
+	if w, _ := watts(ids[1]); w != 38 {
+		t.Errorf("shared watts = %v", w)
+	}
+	if w, _ := watts(ids[2]); w == 38 {
+		t.Error("unlinked luminaire was updated")
+	}
+
+	var list struct {
+		Drivers []struct {
+			Name            string `json:"name"`
+			DimmingProtocol string `json:"dimming_protocol"`
+			Luminaires      int    `json:"luminaires"`
+		} `json:"drivers"`
+	}
+	json.Unmarshal(do(http.MethodGet, "/api/v1/drivers", "").Body.Bytes(), &list)
+	if len(list.Drivers) != 1 || list.Drivers[0].Luminaires != 2 || list.Drivers[0].DimmingProtocol != "DALI-2" {
+		t.Errorf("list = %+v", list)
+	}
+
+	if resp := do(http.MethodDelete, fmt.Sprintf("/api/v1/drivers/ld40/luminaires/%d", ids[0]), ""); resp.Code != http.StatusOK {
+		t.Errorf("unlink: status = %d", resp.Code)
+	}
+	var got struct {
+		Luminaires []database.Luminaire `json:"luminaires"`
+	}
+	json.Unmarshal(do(http.MethodGet, "/api/v1/drivers/ld40", "").Body.Bytes(), &got)
+	if len(got.Luminaires) != 1 || got.Luminaires[0].ID != ids[1] {
+		t.Errorf("driver luminaires = %+v", got.Luminaires)
+	}
+
+	do(http.MethodDelete, "/api/v1/drivers/ld40", "")
+	if resp := do(http.MethodGet, "/api/v1/drivers/ld40", ""); resp.Code != http.StatusNotFound {
+		t.Errorf("get after delete: status = %d", resp.Code)
+	}
+	if w, _ := watts(ids[1]); w != 38 {
+		t.Errorf("deleting the driver changed watts to %v", w)
+	}
+}
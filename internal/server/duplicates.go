@@ -0,0 +1,450 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+	"illuminate/internal/logger"
+	"illuminate/internal/photometry"
+)
+
+// Reasons two luminaires are reported as duplicates.
+// Uploads already reject a file whose hash is on record, so the same
+// source file never appears twice.
+const (
+	duplicateSameMetadata = "same_metadata"
+	duplicateSimilar      = "similar_photometry"
+)
+
+// defaultDuplicateThreshold is the least photometry.Similarity of two
+// distributions reported as near duplicates.
+const defaultDuplicateThreshold = 0.97
+
+// mergeFields are the descriptive fields a merge unions, by JSON name and
+// column. The fields that describe the distribution itself (flux, opening,
+// symmetry, file hash and format) come with the chosen photometry.
+var mergeFields = []struct{ field, column string }{
+	{"manufacturer", "manufacturer"},
+	{"model", "model"},
+	{"catalog_number", "catalog_number"},
+	{"luminaire_description", "luminare_description"},
+	{"lamp_type", "lamp_type"},
+	{"lamp_catalog", "lamp_catalog"},
+	{"ballast", "ballast"},
+	{"test_lab", "test_lab"},
+	{"test_number", "test_number"},
+	{"issue_date", "issue_date"},
+	{"test_date", "test_date"},
+	{"input_watts", "input_watts"},
+	{"color_temp", "color_temp"},
+	{"cri", "cri"},
+	{"lamp_lumen_depreciation", "lamp_lumen_depreciation"},
+	{"driver_maintenance_factor", "driver_maintenance_factor"},
+	{"rated_life", "rated_life"},
+	{"thd", "thd"},
+	{"inrush_current", "inrush_current"},
+	{"frequency", "frequency"},
+}
+
+// photometryColumns are the luminaire columns that travel with the
+// photometry a merge keeps, with the file hash.
+const photometryColumns = `photometric_type, symmetry, symmetry_flag, units_type,
+	luminaire_candela, lamp_position,
+	conversion_factor, luminous_flux, luminous_length, luminous_width,
+	luminous_height_c0, luminous_height_c90, luminous_height_c180,
+	luminous_height_c270, original_filename, format_type, format_version,
+	format_confidence, parser_override`
+
+// luminaireFieldIndex maps the JSON names of database.Luminaire to their
+// field index.
+var luminaireFieldIndex = func() map[string]int {
+	index := map[string]int{}
+	t := reflect.TypeOf(database.Luminaire{})
+	for i := 0; i < t.NumField(); i++ {
+		if name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ","); name != "" && name != "-" {
+			index[name] = i
+		}
+	}
+	return index
+}()
+
+// duplicatePair is one reason two luminaires belong to a cluster.
+type duplicatePair struct {
+	A          int64    `json:"a"`
+	B          int64    `json:"b"`
+	Reason     string   `json:"reason"`
+	Similarity *float64 `json:"similarity,omitempty"`
+}
+
+// mergePlan is the merge a cluster suggests, in the form
+// POST /api/v1/duplicates/merge takes, with the metadata it would produce.
+type mergePlan struct {
+	Primary        int64   `json:"primary"`
+	Duplicates     []int64 `json:"duplicates"`
+	PhotometryFrom int64   `json:"photometry_from"`
+	// Metadata is the union of the descriptive fields; Conflicts lists
+	// the fields the records disagree on, where the primary's value wins.
+	Metadata  map[string]interface{}   `json:"metadata,omitempty"`
+	Conflicts map[string][]interface{} `json:"conflicts,omitempty"`
+}
+
+type duplicateCluster struct {
+	LuminaireIDs []int64         `json:"luminaire_ids"`
+	Reasons      []string        `json:"reasons"`
+	Pairs        []duplicatePair `json:"pairs"`
+	Merge        mergePlan       `json:"merge"`
+}
+
+// Duplicates clusters near-duplicate luminaires: records of the same
+// manufacturer, model and catalog number, or distributions at least
+// ?threshold= similar (default 0.97, see photometry.Similarity). Every cluster suggests a merge into its
+// oldest record: GET /api/v1/duplicates.
+func (h *LuminaireHandler) Duplicates(c echo.Context) error {
+	threshold := defaultDuplicateThreshold
+	if v := c.QueryParam("threshold"); v != "" {
+		t, err := strconv.ParseFloat(v, 64)
+		if err != nil || t <= 0 || t > 1 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "threshold must be a number above 0 and at most 1"})
+		}
+		threshold = t
+	}
+
+	luminaires, err := h.findLuminaires(c, h.db, nil)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	sort.Slice(luminaires, func(i, j int) bool { return luminaires[i].ID < luminaires[j].ID })
+
+	var pairs []duplicatePair
+	byIdentity := map[string][]int{}
+	for i, lum := range luminaires {
+		if key := identityKey(lum); key != "" {
+			byIdentity[key] = append(byIdentity[key], i)
+		}
+	}
+	for _, group := range byIdentity {
+		for _, j := range group[1:] {
+			pairs = append(pairs, duplicatePair{A: luminaires[group[0]].ID, B: luminaires[j].ID, Reason: duplicateSameMetadata})
+		}
+	}
+	similar, err := h.similarPairs(luminaires, threshold)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	pairs = append(pairs, similar...)
+
+	clusters := clusterPairs(luminaires, pairs)
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"threshold": threshold,
+		"clusters":  clusters,
+	})
+}
+
+// identityKey is what makes two records the same product: manufacturer,
+// model and catalog number, ignoring case and spacing. Records without a
+// model have none.
+func identityKey(lum database.Luminaire) string {
+	if strings.TrimSpace(lum.Model) == "" {
+		return ""
+	}
+	norm := func(s string) string { return strings.ToLower(strings.Join(strings.Fields(s), " ")) }
+	return norm(lum.Manufacturer) + "\x00" + norm(lum.Model) + "\x00" + norm(lum.CatalogNumber)
+}
+
+// similarPairs compares the distributions of luminaires pairwise. Only
+// records whose signatures sum to within threshold of each other are
+// compared, since Similarity never exceeds the ratio of the sums.
+func (h *LuminaireHandler) similarPairs(luminaires []database.Luminaire, threshold float64) ([]duplicatePair, error) {
+	type signed struct {
+		id  int64
+		sig []float64
+		sum float64
+	}
+	var all []signed
+	for _, meta := range luminaires {
+		lum, err := database.LoadParsedLuminaire(h.db, meta.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("luminaire %d: %w", meta.ID, err)
+		}
+		if lum.CheckShape() != nil {
+			continue
+		}
+		s := signed{id: meta.ID, sig: photometry.Signature(lum)}
+		for _, v := range s.sig {
+			s.sum += v
+		}
+		if s.sum > 0 {
+			all = append(all, s)
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].sum < all[j].sum })
+
+	var pairs []duplicatePair
+	for i := range all {
+		for j := i + 1; j < len(all) && all[i].sum >= threshold*all[j].sum; j++ {
+			if sim := photometry.Similarity(all[i].sig, all[j].sig); sim >= threshold {
+				a, b := min(all[i].id, all[j].id), max(all[i].id, all[j].id)
+				sim = math.Round(sim*10000) / 10000
+				pairs = append(pairs, duplicatePair{A: a, B: b, Reason: duplicateSimilar, Similarity: &sim})
+			}
+		}
+	}
+	return pairs, nil
+}
+
+// clusterPairs joins luminaires linked by any pair into clusters, each with
+// the merge it suggests, ordered by their oldest record.
+func clusterPairs(luminaires []database.Luminaire, pairs []duplicatePair) []duplicateCluster {
+	parent := map[int64]int64{}
+	var find func(id int64) int64
+	find = func(id int64) int64 {
+		if p, ok := parent[id]; ok && p != id {
+			parent[id] = find(p)
+			return parent[id]
+		}
+		return id
+	}
+	for _, p := range pairs {
+		a, b := find(p.A), find(p.B)
+		if a != b {
+			parent[max(a, b)] = min(a, b)
+		}
+	}
+
+	byID := map[int64]database.Luminaire{}
+	for _, lum := range luminaires {
+		byID[lum.ID] = lum
+	}
+	grouped := map[int64]*duplicateCluster{}
+	for _, p := range pairs {
+		root := find(p.A)
+		cluster := grouped[root]
+		if cluster == nil {
+			cluster = &duplicateCluster{}
+			grouped[root] = cluster
+		}
+		cluster.Pairs = append(cluster.Pairs, p)
+		for _, id := range []int64{p.A, p.B} {
+			if !slices.Contains(cluster.LuminaireIDs, id) {
+				cluster.LuminaireIDs = append(cluster.LuminaireIDs, id)
+			}
+		}
+		if !slices.Contains(cluster.Reasons, p.Reason) {
+			cluster.Reasons = append(cluster.Reasons, p.Reason)
+		}
+	}
+
+	clusters := []duplicateCluster{}
+	for _, cluster := range grouped {
+		slices.Sort(cluster.LuminaireIDs)
+		slices.Sort(cluster.Reasons)
+		sort.Slice(cluster.Pairs, func(i, j int) bool {
+			pi, pj := cluster.Pairs[i], cluster.Pairs[j]
+			if pi.A != pj.A {
+				return pi.A < pj.A
+			}
+			if pi.B != pj.B {
+				return pi.B < pj.B
+			}
+			return pi.Reason < pj.Reason
+		})
+		primary := cluster.LuminaireIDs[0]
+		others := make([]database.Luminaire, 0, len(cluster.LuminaireIDs)-1)
+		for _, id := range cluster.LuminaireIDs[1:] {
+			others = append(others, byID[id])
+		}
+		metadata, conflicts := unionMetadata(byID[primary], others)
+		cluster.Merge = mergePlan{
+			Primary:        primary,
+			Duplicates:     cluster.LuminaireIDs[1:],
+			PhotometryFrom: primary,
+			Metadata:       metadata,
+			Conflicts:      conflicts,
+		}
+		clusters = append(clusters, *cluster)
+	}
+	sort.Slice(clusters, func(i, j int) bool { return clusters[i].LuminaireIDs[0] < clusters[j].LuminaireIDs[0] })
+	return clusters
+}
+
+// unionMetadata merges the mergeFields of others into primary: a field the
+// primary leaves empty takes the first value another record has, and a
+// field they fill differently is a conflict the primary wins.
+func unionMetadata(primary database.Luminaire, others []database.Luminaire) (map[string]interface{}, map[string][]interface{}) {
+	metadata := map[string]interface{}{}
+	conflicts := map[string][]interface{}{}
+	for _, f := range mergeFields {
+		i := luminaireFieldIndex[f.field]
+		value := reflect.ValueOf(primary).Field(i)
+		values := []interface{}{}
+		if !value.IsZero() {
+			values = append(values, value.Interface())
+		}
+		for _, other := range others {
+			v := reflect.ValueOf(other).Field(i)
+			if v.IsZero() {
+				continue
+			}
+			if value.IsZero() {
+				value = v
+			}
+			if !slices.Contains(values, v.Interface()) {
+				values = append(values, v.Interface())
+			}
+		}
+		if !value.IsZero() {
+			metadata[f.field] = value.Interface()
+		}
+		if len(values) > 1 {
+			conflicts[f.field] = values
+		}
+	}
+	return metadata, conflicts
+}
+
+// MergeDuplicates merges a cluster of duplicates into one record from a
+// JSON body such as {"primary": 12, "duplicates": [31, 47],
+// "photometry_from": 31}: the primary keeps its id and takes the
+// photometry of photometry_from (default the primary) and the union of the
+// descriptive metadata, then the duplicates are merged into it as Merge
+// does, all in one transaction. The photometry the primary gives up stays
+// with photometry_from's merged record. POST /api/v1/duplicates/merge.
+func (h *LuminaireHandler) MergeDuplicates(c echo.Context) error {
+	var plan mergePlan
+	if err := json.NewDecoder(c.Request().Body).Decode(&plan); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid merge: %v", err)})
+	}
+	if plan.PhotometryFrom == 0 {
+		plan.PhotometryFrom = plan.Primary
+	}
+	slices.Sort(plan.Duplicates)
+	plan.Duplicates = slices.Compact(plan.Duplicates)
+	if status, err := h.checkMerge(plan.Primary, plan.Duplicates); err != nil {
+		return c.JSON(status, map[string]string{"error": err.Error()})
+	}
+	if plan.PhotometryFrom != plan.Primary && !slices.Contains(plan.Duplicates, plan.PhotometryFrom) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "photometry_from must be the primary or one of the duplicates"})
+	}
+
+	var primary database.Luminaire
+	var others []database.Luminaire
+	for _, id := range append([]int64{plan.Primary}, plan.Duplicates...) {
+		lum, err := database.LoadParsedLuminaire(h.db, id)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		if id == plan.Primary {
+			primary = lum.Metadata
+		} else {
+			others = append(others, lum.Metadata)
+		}
+	}
+	metadata, conflicts := unionMetadata(primary, others)
+
+	moved, err := h.merge(plan, metadata)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if err := refreshMetrics(h.db, plan.Primary); err != nil {
+		logger.Default.Warnf("refresh metrics for luminaire %d: %v", plan.Primary, err)
+	}
+	if err := refreshValidation(h.db, plan.Primary, h.rules("")); err != nil {
+		logger.Default.Warnf("revalidate luminaire %d: %v", plan.Primary, err)
+	}
+	h.events.publish(eventUpdated, plan.Primary)
+	for _, id := range plan.Duplicates {
+		h.events.publish(eventDeleted, id)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"status":          "merged",
+		"luminaire_id":    plan.Primary,
+		"photometry_from": plan.PhotometryFrom,
+		"merged":          plan.Duplicates,
+		"moved":           moved,
+		"conflicts":       conflicts,
+	})
+}
+
+// merge applies plan with the unioned metadata in one transaction.
+func (h *LuminaireHandler) merge(plan mergePlan, metadata map[string]interface{}) (map[string]int64, error) {
+	tx, err := h.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var sets []string
+	var args []interface{}
+	for _, f := range mergeFields {
+		if v, ok := metadata[f.field]; ok {
+			sets = append(sets, f.column+" = ?")
+			args = append(args, v)
+		}
+	}
+	if len(sets) > 0 {
+		args = append(args, plan.Primary)
+		if _, err := tx.Exec(`UPDATE luminaires SET `+strings.Join(sets, ", ")+`, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, args...); err != nil {
+			return nil, err
+		}
+	}
+	if plan.PhotometryFrom != plan.Primary {
+		if err := swapPhotometry(tx, plan.Primary, plan.PhotometryFrom); err != nil {
+			return nil, err
+		}
+	}
+
+	moved, err := mergeLuminaires(tx, plan.Primary, plan.Duplicates)
+	if err != nil {
+		return nil, err
+	}
+	return moved, tx.Commit()
+}
+
+// swapPhotometry exchanges the photometry of luminaires a and b: their
+// photometryColumns, file hashes, photometric data and source files. The
+// hashes are unique, so b's is parked while a takes it.
+func swapPhotometry(tx *sql.Tx, a, b int64) error {
+	cols := photometryColumns + `, file_hash`
+	if _, err := tx.Exec(`CREATE TEMP TABLE swap_photometry AS SELECT id, `+cols+` FROM luminaires WHERE id IN (?, ?)`, a, b); err != nil {
+		return err
+	}
+	defer tx.Exec(`DROP TABLE temp.swap_photometry`)
+	if _, err := tx.Exec(`UPDATE luminaires SET file_hash = 'swap:' || file_hash WHERE id = ?`, b); err != nil {
+		return err
+	}
+	for _, pair := range [][2]int64{{a, b}, {b, a}} {
+		if _, err := tx.Exec(`
+			UPDATE luminaires SET (`+cols+`) = (SELECT `+cols+` FROM temp.swap_photometry WHERE id = ?),
+				updated_at = CURRENT_TIMESTAMP
+			WHERE id = ?`, pair[1], pair[0]); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`
+		UPDATE photometric_data SET luminaire_id = CASE luminaire_id WHEN ? THEN ? ELSE ? END
+		WHERE luminaire_id IN (?, ?)`, a, b, a, a, b); err != nil {
+		return err
+	}
+	// Source files are keyed by luminaire, so a's waits at -a.
+	for _, move := range [][2]int64{{a, -a}, {b, a}, {-a, b}} {
+		if _, err := tx.Exec(`UPDATE luminaire_sources SET luminaire_id = ? WHERE luminaire_id = ?`, move[1], move[0]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
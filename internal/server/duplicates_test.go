@@ -0,0 +1,114 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+	"illuminate/internal/synth"
+)
+
+// TestDuplicates stores a record, a copy of its photometry without a model,
+// a narrow beam version under the same model and an unrelated batwing, then
+// merges the first three keeping the narrow beam photometry.
+func TestDuplicates(t *testing.T) {
+	h := newTestHandler(t)
+	save := func(dist synth.Distribution, model, lab string) int64 {
+		opts := synth.DefaultOptions()
+		opts.Distribution = dist
+		opts.Model = model
+		lum, err := synth.Generate(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lum.Metadata.FileHash = fmt.Sprintf("dup-%s-%s-%s", dist, model, lab)
+		lum.Metadata.TestLab = lab
+		id, err := h.saveLuminaire(lum)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	primary := save(synth.Lambertian, "X100", "")
+	copied := save(synth.Lambertian, "", "Lab B")
+	narrow := save(synth.NarrowBeam, "x100 ", "Lab C")
+	other := save(synth.Batwing, "Y200", "")
+
+	e := echo.New()
+	e.GET("/api/v1/duplicates", h.Duplicates)
+	e.POST("/api/v1/duplicates/merge", h.MergeDuplicates)
+
+	resp := httptest.NewRecorder()
+	e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/api/v1/duplicates", nil))
+	var body struct {
+		Clusters []duplicateCluster `json:"clusters"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Clusters) != 1 {
+		t.Fatalf("clusters: %s", resp.Body.String())
+	}
+	cluster := body.Clusters[0]
+	if !slices.Equal(cluster.LuminaireIDs, []int64{primary, copied, narrow}) || slices.Contains(cluster.LuminaireIDs, other) {
+		t.Errorf("cluster = %v", cluster.LuminaireIDs)
+	}
+	if !slices.Equal(cluster.Reasons, []string{duplicateSameMetadata, duplicateSimilar}) {
+		t.Errorf("reasons = %v", cluster.Reasons)
+	}
+	plan := cluster.Merge
+	if plan.Primary != primary || plan.Metadata["test_lab"] != "Lab B" || len(plan.Conflicts["test_lab"]) != 2 {
+		t.Errorf("merge = %+v", plan)
+	}
+
+	resp = httptest.NewRecorder()
+	e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/api/v1/duplicates?threshold=2", nil))
+	if resp.Code != http.StatusBadRequest {
+		t.Errorf("threshold=2: %d", resp.Code)
+	}
+
+	merge := func(plan mergePlan) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(plan)
+		resp := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/duplicates/merge", bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+		e.ServeHTTP(resp, req)
+		return resp
+	}
+	if resp := merge(mergePlan{Primary: primary, Duplicates: []int64{copied}, PhotometryFrom: other}); resp.Code != http.StatusBadRequest {
+		t.Errorf("photometry from outside the merge: %d", resp.Code)
+	}
+	plan.PhotometryFrom = narrow
+	if resp := merge(plan); resp.Code != http.StatusOK {
+		t.Fatalf("merge: %d %s", resp.Code, resp.Body.String())
+	}
+
+	lum, err := database.LoadParsedLuminaire(h.db, primary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := synth.Generate(synth.Options{Distribution: synth.NarrowBeam})
+	if lum.CandelaMatrix[0][0] != want.CandelaMatrix[0][0] || lum.Metadata.FileHash != "dup-narrow-x100 -Lab C" {
+		t.Errorf("kept photometry %v, hash %q", lum.CandelaMatrix[0][0], lum.Metadata.FileHash)
+	}
+	if lum.Metadata.TestLab != "Lab B" || lum.Metadata.Model != "X100" {
+		t.Errorf("merged metadata %+v", lum.Metadata)
+	}
+	// The photometry the primary gave up stays with the merged record.
+	var hash string
+	h.db.QueryRow(`SELECT file_hash FROM luminaires WHERE id = ?`, narrow).Scan(&hash)
+	if hash != "dup-lambertian-X100-" {
+		t.Errorf("merged record hash %q", hash)
+	}
+	for _, id := range []int64{copied, narrow} {
+		if _, err := database.LoadParsedLuminaire(h.db, id); err == nil {
+			t.Errorf("luminaire %d survived the merge", id)
+		}
+	}
+}
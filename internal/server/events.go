@@ -0,0 +1,166 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/cache"
+	"illuminate/internal/logger"
+)
+
+// Types of catalogEvent.
+const (
+	eventCreated = "created"
+	eventUpdated = "updated"
+	eventDeleted = "deleted"
+)
+
+// catalogEvent tells the clients watching the catalog that a luminaire was
+// created, updated or deleted; they fetch it again for the details.
+type catalogEvent struct {
+	Type string    `json:"type"`
+	ID   int64     `json:"id"`
+	At   time.Time `json:"at"`
+}
+
+// eventBuffer is how many events a subscriber may fall behind before it is
+// disconnected, so one slow client does not hold up the others.
+const eventBuffer = 64
+
+// eventChannel is the Redis channel the instances of a deployment send
+// each other their events on.
+const eventChannel = "illuminate:events"
+
+// eventHub fans catalog events out to every subscriber. With a shared
+// Redis an event goes through it to the subscribers of every instance. A
+// nil hub drops them, for handlers built without one.
+type eventHub struct {
+	mu   sync.Mutex
+	subs map[chan catalogEvent]struct{}
+
+	// shared carries events between instances once listening; nil keeps
+	// them on this one.
+	shared    *cache.Redis
+	listening atomic.Bool
+}
+
+func newEventHub(shared *cache.Redis) *eventHub {
+	return &eventHub{subs: map[chan catalogEvent]struct{}{}, shared: shared}
+}
+
+// listen delivers the events every instance publishes on the shared Redis
+// until ctx is done. Until it is called, or without a shared Redis, events
+// stay on this instance.
+func (h *eventHub) listen(ctx context.Context) error {
+	if h.shared == nil {
+		return nil
+	}
+	err := h.shared.Subscribe(ctx, eventChannel, func(message []byte) {
+		var ev catalogEvent
+		if err := json.Unmarshal(message, &ev); err != nil {
+			logger.Default.Warnf("events: %v", err)
+			return
+		}
+		h.deliver(ev)
+	})
+	if err != nil {
+		return err
+	}
+	h.listening.Store(true)
+	return nil
+}
+
+// subscribe returns a channel of the events published from now on, closed
+// by unsubscribe or when the subscriber falls eventBuffer events behind.
+func (h *eventHub) subscribe() chan catalogEvent {
+	ch := make(chan catalogEvent, eventBuffer)
+	h.mu.Lock()
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch
+}
+
+func (h *eventHub) unsubscribe(ch chan catalogEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[ch]; ok {
+		delete(h.subs, ch)
+		close(ch)
+	}
+}
+
+// publish sends an event of type typ for luminaire id to every subscriber,
+// of every instance when listening. When the shared Redis fails the
+// subscribers of this instance still get it.
+func (h *eventHub) publish(typ string, id int64) {
+	if h == nil {
+		return
+	}
+	ev := catalogEvent{Type: typ, ID: id, At: time.Now().UTC()}
+	if h.listening.Load() {
+		data, _ := json.Marshal(ev)
+		err := h.shared.Publish(context.Background(), eventChannel, data)
+		if err == nil {
+			return
+		}
+		logger.Default.Warnf("events: %v", err)
+	}
+	h.deliver(ev)
+}
+
+// deliver hands ev to the subscribers of this instance.
+func (h *eventHub) deliver(ev catalogEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- ev:
+		default:
+			delete(h.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// Events streams catalog changes over a WebSocket at
+// /api/v1/luminaires/events: one JSON text message per change, such as
+// {"type":"updated","id":42,"at":"..."}. A client that falls behind is
+// closed with StatusTryAgainLater and should reconnect and reload.
+func (h *LuminaireHandler) Events(c echo.Context) error {
+	if h.events == nil {
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": "live updates are not enabled"})
+	}
+	// Subscribed before the handshake completes, the client gets every
+	// event from the moment its socket is open.
+	ch := h.events.subscribe()
+	defer h.events.unsubscribe(ch)
+	socket, err := websocket.Accept(c.Response().Writer, c.Request(), nil)
+	if err != nil {
+		logger.Default.Warnf("events: could not open websocket: %v", err)
+		return nil
+	}
+	defer socket.CloseNow()
+
+	ctx := socket.CloseRead(c.Request().Context())
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-ch:
+			if !ok {
+				socket.Close(websocket.StatusTryAgainLater, "too far behind; reconnect and reload")
+				return nil
+			}
+			data, _ := json.Marshal(ev)
+			if err := socket.Write(ctx, websocket.MessageText, data); err != nil {
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/labstack/echo/v4"
+)
+
+// TestEvents watches the catalog over a WebSocket while a luminaire is
+// created, updated and deleted.
+func TestEvents(t *testing.T) {
+	h := newTestHandler(t)
+	h.events = newEventHub(nil)
+	e := echo.New()
+	e.GET("/api/v1/luminaires/events", h.Events)
+	e.PUT("/api/v1/luminaires/:id", h.Update)
+	e.DELETE("/api/v1/luminaires/:id", h.Delete)
+	srv := httptest.NewServer(e)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	socket, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(srv.URL, "http")+"/api/v1/luminaires/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer socket.CloseNow()
+	// The subscription starts once the handler runs; wait for it.
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		h.events.mu.Lock()
+		n := len(h.events.subs)
+		h.events.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no subscriber")
+		}
+	}
+
+	id := saveSynth(t, h, "events")
+	for _, method := range []string{http.MethodPut, http.MethodDelete} {
+		req, _ := http.NewRequest(method, fmt.Sprintf("%s/api/v1/luminaires/%d", srv.URL, id), strings.NewReader("model=Renamed"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	for _, want := range []string{eventCreated, eventUpdated, eventDeleted} {
+		_, data, err := socket.Read(ctx)
+		if err != nil {
+			t.Fatalf("waiting for %s: %v", want, err)
+		}
+		var ev catalogEvent
+		if err := json.Unmarshal(data, &ev); err != nil || ev.Type != want || ev.ID != id || ev.At.IsZero() {
+			t.Errorf("event %s, want %s of %d", data, want, id)
+		}
+	}
+}
+
+// TestEventHubDropsSlowSubscribers checks that a subscriber that stops
+// reading is cut off instead of blocking publishers.
+func TestEventHubDropsSlowSubscribers(t *testing.T) {
+	hub := newEventHub(nil)
+	slow := hub.subscribe()
+	for i := 0; i <= eventBuffer; i++ {
+		hub.publish(eventUpdated, int64(i))
+	}
+	n := 0
+	for range slow {
+		n++
+	}
+	if n != eventBuffer {
+		t.Errorf("%d events buffered before the cut-off, want %d", n, eventBuffer)
+	}
+	hub.unsubscribe(slow) // already gone: must not panic
+	var nilHub *eventHub
+	nilHub.publish(eventCreated, 1)
+}
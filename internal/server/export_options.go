@@ -0,0 +1,377 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+	"illuminate/internal/features"
+	"illuminate/internal/parser"
+)
+
+// conversionRequest is what one request asks of a conversion: the reader for
+// an upload, and the target format and write options of an export. Upload,
+// export and download endpoints all build it with conversionRequest, so the
+// parameters mean the same everywhere.
+type conversionRequest struct {
+	// organization is the caller's (see organization), whose feature
+	// flags decide which readers are enabled.
+	organization string
+	flags        *features.Flags
+	// sourceFormat picks the reader for a file whose name does not say
+	// (source_format=ldt), or forces it for one that is misdetected
+	// (parser=ldt); empty goes by the file extension. See readerOverride.
+	sourceFormat string
+	// salvage answers an upload that fails part way with what could be
+	// read (salvage=true), marked incomplete and not stored.
+	salvage bool
+	// trashed says what an upload of a file whose luminaire is in the
+	// trash does (trashed=restore or trashed=insert); see storedUpload.
+	trashed string
+	// format is the target format, lower case; /export also takes a
+	// comma-separated list (see formats).
+	format string
+	opts   parser.WriteOptions
+	// aimed writes the as-aimed distribution (orientation=aimed) rather
+	// than the measured one.
+	aimed bool
+	// revit, set by Download for /download/revit, bundles the IES file
+	// with its Revit type parameters.
+	revit bool
+	// orientation exports a stored orientation variant (orientation=tilt30)
+	// instead of the base file; see PutOrientation.
+	orientation string
+	// condition exports the photometry of a named operating condition
+	// (condition=40C) instead of the main measurement; see PutCondition.
+	condition string
+	// component exports one emitter of a segmented luminaire
+	// (component=up), or all of them added up (component=combined); see
+	// PutComponent.
+	component string
+	// dimLevel, set by Dimmed, asks load for a distribution interpolated
+	// between the conditions measured at the nearest dim levels; load then
+	// describes what it did in derived.
+	dimLevel float64
+	derived  string
+	// deterministic leaves the conversion time out of the provenance
+	// (deterministic=true), or records converted=TIME instead of now, so
+	// that exporting the same luminaire with the same options gives the
+	// same bytes.
+	deterministic bool
+	converted     time.Time
+	// eolSet and lineLengthSet record whether the request chose these, so
+	// that optionsFor does not override it.
+	eolSet        bool
+	lineLengthSet bool
+}
+
+// conversionRequest reads "parser" or "source_format", "salvage", "trashed", "format"
+// (defaultFormat when absent), "eol", "encoding", "line_length", "orientation", "condition",
+// "component", "deterministic", "converted" and the options of exportOptions
+// on top of base.
+func (h *LuminaireHandler) conversionRequest(c echo.Context, defaultFormat string, base parser.WriteOptions) (*conversionRequest, int, error) {
+	sourceFormat, err := readerOverride(c)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	req := &conversionRequest{
+		organization: organization(c),
+		flags:        h.flags,
+		sourceFormat: sourceFormat,
+		format:       strings.ToLower(c.QueryParam("format")),
+		condition:    strings.TrimSpace(c.QueryParam("condition")),
+		component:    strings.TrimSpace(c.QueryParam("component")),
+		opts:         base,
+	}
+	if req.format == "" {
+		req.format = defaultFormat
+	}
+	req.salvage, _ = strconv.ParseBool(c.FormValue("salvage"))
+	if req.trashed, err = trashedChoice(c); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	if v := c.QueryParam("deterministic"); v != "" {
+		if req.deterministic, err = strconv.ParseBool(v); err != nil {
+			return nil, http.StatusBadRequest, fmt.Errorf("deterministic must be true or false, not %q", v)
+		}
+	}
+	if v := c.QueryParam("converted"); v != "" {
+		if req.converted, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, http.StatusBadRequest, fmt.Errorf("converted must be an RFC 3339 time such as 2026-03-01T12:00:00Z, not %q", v)
+		}
+		req.deterministic = true
+	}
+
+	if v := c.QueryParam("eol"); v != "" {
+		if req.opts.LineEnding, err = parser.ParseLineEnding(v); err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		req.eolSet = true
+	}
+	if v := c.QueryParam("encoding"); v != "" {
+		if req.opts.Encoding, err = parser.ParseEncoding(v); err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+	}
+	if v := c.QueryParam("line_length"); v != "" {
+		if req.opts.MaxLineLength, err = strconv.Atoi(v); err != nil || req.opts.MaxLineLength < 1 {
+			return nil, http.StatusBadRequest, errors.New("invalid line_length")
+		}
+		req.lineLengthSet = true
+	}
+	if status, err := h.exportOptions(c, &req.opts); err != nil {
+		return nil, status, err
+	}
+
+	switch orientation := strings.TrimSpace(c.QueryParam("orientation")); orientation {
+	case "", "measured":
+	case "aimed":
+		req.aimed = true
+	default:
+		// A stored orientation belongs to the luminaire in the path.
+		var stored int
+		err := h.db.QueryRow(`SELECT COUNT(*) FROM luminaire_orientations WHERE luminaire_id = ? AND name = ?`,
+			c.Param("id"), orientation).Scan(&stored)
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		if stored == 0 {
+			return nil, http.StatusBadRequest, errors.New("orientation must be measured, aimed or the name of a stored orientation")
+		}
+		if req.condition != "" || req.component != "" {
+			return nil, http.StatusBadRequest, errors.New("a stored orientation cannot be combined with condition or component")
+		}
+		req.orientation = orientation
+	}
+	return req, http.StatusOK, nil
+}
+
+// readerOverride is the reader format a request names with "parser" or its
+// older spelling "source_format", lower case, to bypass detection by file
+// extension; empty when it names none. The uploaded luminaire records it as
+// ParserOverride.
+func readerOverride(c echo.Context) (string, error) {
+	format := strings.ToLower(strings.TrimSpace(c.FormValue("parser")))
+	if source := strings.ToLower(strings.TrimSpace(c.FormValue("source_format"))); source != "" {
+		if format != "" && format != source {
+			return "", fmt.Errorf("parser=%s and source_format=%s disagree", format, source)
+		}
+		format = source
+	}
+	if format == "" {
+		return "", nil
+	}
+	if _, err := parser.GetReader("source." + format); err != nil {
+		return "", err
+	}
+	return format, nil
+}
+
+// sourceName is name with the extension parser or source_format asks for,
+// if any.
+func (r *conversionRequest) sourceName(name string) string {
+	if r.sourceFormat == "" {
+		return name
+	}
+	return strings.TrimSuffix(name, filepath.Ext(name)) + "." + r.sourceFormat
+}
+
+// reader returns the reader for the file called name, unless a feature flag
+// switches it off.
+func (r *conversionRequest) reader(name string) (parser.Reader, error) {
+	source := r.sourceName(name)
+	p, err := parser.GetReader(source)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkReader(r.flags, r.organization, source); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// provenance records the conversion of the file with sourceHash: made now,
+// or when the request asks for reproducible output, at the time it names,
+// if any.
+func (r *conversionRequest) provenance(sourceHash string) *parser.Provenance {
+	if !r.deterministic {
+		return parser.NewProvenance(sourceHash)
+	}
+	return parser.Reproducible(sourceHash, r.converted)
+}
+
+// writer returns the parser of the target format.
+func (r *conversionRequest) writer() (parser.Parser, error) {
+	return parser.GetParser("export." + r.format)
+}
+
+// optionsFor is the write options for format, with what the request leaves
+// open filled from the file the luminaire came from; see
+// parser.SourceDefaults.
+func (r *conversionRequest) optionsFor(meta database.Luminaire, format string) parser.WriteOptions {
+	opts := r.opts
+	defaults := parser.SourceDefaults(meta, format)
+	if !r.lineLengthSet && defaults.MaxLineLength > 0 {
+		opts.MaxLineLength = defaults.MaxLineLength
+	}
+	if !r.eolSet && defaults.LineEnding != "" {
+		opts.LineEnding = defaults.LineEnding
+	}
+	return opts
+}
+
+// formats splits a format list such as "ies,ldt" into its distinct entries,
+// lower-cased, in order.
+func (r *conversionRequest) formats() []string {
+	var formats []string
+	seen := map[string]bool{}
+	for _, f := range strings.Split(r.format, ",") {
+		if f = strings.ToLower(strings.TrimSpace(f)); f != "" && !seen[f] {
+			seen[f] = true
+			formats = append(formats, f)
+		}
+	}
+	return formats
+}
+
+// exportOptions reads the query parameters shared by export endpoints into
+// opts: "profile", repeated "keyword=KEY:value", "downgrade=warn|fail|embed",
+// the LDT lamp set ("lamp_count", "lamp_type", "lamp_cct", "lamp_cri"),
+// "anonymize=true", the layout of angle and candela values
+// ("numbers=fixed|shortest", "decimals", "trim_zeros", "column_width"), and
+// "decimal=comma|point" or, failing that, a "locale" such as de-DE that
+// selects the separator.
+func (h *LuminaireHandler) exportOptions(c echo.Context, opts *parser.WriteOptions) (int, error) {
+	if d := c.QueryParam("downgrade"); d != "" {
+		downgrade, err := parser.ParseDowngrade(d)
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+		opts.Downgrade = downgrade
+	}
+
+	if name := c.QueryParam("profile"); name != "" {
+		profile, err := h.loadExportProfile(name)
+		if errors.Is(err, sql.ErrNoRows) {
+			return http.StatusBadRequest, fmt.Errorf("unknown export profile: %s", name)
+		}
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		opts.Mapping = profile
+	}
+
+	for _, raw := range c.QueryParams()["keyword"] {
+		key, value, ok := strings.Cut(raw, ":")
+		if !ok {
+			return http.StatusBadRequest, fmt.Errorf("keyword %q: expected KEY:value", raw)
+		}
+		kw := parser.Keyword{Key: strings.ToUpper(strings.Trim(strings.TrimSpace(key), "[]")), Value: strings.TrimSpace(value)}
+		if err := kw.Validate(); err != nil {
+			return http.StatusBadRequest, err
+		}
+		opts.Keywords = append(opts.Keywords, kw)
+	}
+
+	if count := c.QueryParam("lamp_count"); count != "" {
+		n, err := strconv.Atoi(count)
+		if err != nil {
+			return http.StatusBadRequest, fmt.Errorf("lamp_count must be a number, not %q", count)
+		}
+		opts.LampSet.Count = n
+	}
+	opts.LampSet.Type = strings.TrimSpace(c.QueryParam("lamp_type"))
+	opts.LampSet.ColorTemp = strings.TrimSpace(c.QueryParam("lamp_cct"))
+	opts.LampSet.CRIGroup = strings.TrimSpace(c.QueryParam("lamp_cri"))
+	if err := opts.LampSet.Validate(); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	if status, err := numberFormat(c, &opts.Numbers); err != nil {
+		return status, err
+	}
+
+	if v := c.QueryParam("anonymize"); v != "" {
+		anonymize, err := strconv.ParseBool(v)
+		if err != nil {
+			return http.StatusBadRequest, fmt.Errorf("anonymize must be true or false, not %q", v)
+		}
+		opts.Anonymize = anonymize
+	}
+
+	switch decimal := strings.ToLower(c.QueryParam("decimal")); decimal {
+	case "comma":
+		opts.UseCommaDecimal = true
+	case "point":
+		opts.UseCommaDecimal = false
+	case "":
+		if locale := c.QueryParam("locale"); locale != "" {
+			opts.UseCommaDecimal = parser.CommaDecimalForLocale(locale)
+		}
+	default:
+		return http.StatusBadRequest, fmt.Errorf("decimal must be comma or point, not %q", decimal)
+	}
+	return http.StatusOK, nil
+}
+
+// numberFormat reads "numbers", "decimals", "trim_zeros" and
+// "column_width" into f.
+func numberFormat(c echo.Context, f *parser.NumberFormat) (int, error) {
+	if v := c.QueryParam("numbers"); v != "" {
+		style, err := parser.ParseNumberStyle(v)
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+		f.Style = style
+	}
+	for key, dst := range map[string]*int{"decimals": &f.Decimals, "column_width": &f.Width} {
+		if v := c.QueryParam(key); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return http.StatusBadRequest, fmt.Errorf("%s must be a number, not %q", key, v)
+			}
+			*dst = n
+		}
+	}
+	if v := c.QueryParam("trim_zeros"); v != "" {
+		trim, err := strconv.ParseBool(v)
+		if err != nil {
+			return http.StatusBadRequest, fmt.Errorf("trim_zeros must be true or false, not %q", v)
+		}
+		f.TrimZeros = trim
+	}
+	if err := f.Validate(); err != nil {
+		return http.StatusBadRequest, err
+	}
+	return http.StatusOK, nil
+}
+
+// setExportIssues reports what an export dropped, approximated or embedded
+// in the X-Export-Issues header as "field: effect" pairs.
+func setExportIssues(c echo.Context, issues []parser.CompatibilityIssue) {
+	if len(issues) == 0 {
+		return
+	}
+	pairs := make([]string, len(issues))
+	for i, issue := range issues {
+		pairs[i] = issue.Field + ": " + issue.Effect
+	}
+	c.Response().Header().Set("X-Export-Issues", strings.Join(pairs, ", "))
+}
+
+// downgradeErrorResponse answers an export refused by downgrade=fail.
+func downgradeErrorResponse(c echo.Context, err error) error {
+	var dErr *parser.DowngradeError
+	errors.As(err, &dErr)
+	return c.JSON(http.StatusUnprocessableEntity, map[string]interface{}{
+		"error":  err.Error(),
+		"issues": dErr.Issues,
+	})
+}
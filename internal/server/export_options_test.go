@@ -0,0 +1,364 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/bundle"
+	"illuminate/internal/database"
+	"illuminate/internal/parser"
+	"illuminate/internal/synth"
+)
+
+// TestConversionRequestSourceFormat uploads an IES file under a name that
+// does not say so, with and without the source_format hint.
+func TestConversionRequestSourceFormat(t *testing.T) {
+	h := newTestHandler(t)
+	e := echo.New()
+	e.POST("/api/v1/luminaires", h.Upload)
+
+	lum, err := synth.Generate(synth.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := parser.Encode(parser.NewIESParser(), lum, parser.WriteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upload := func(query string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		w := multipart.NewWriter(&body)
+		part, _ := w.CreateFormFile("file", "downlight.txt")
+		part.Write(data)
+		w.Close()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/luminaires"+query, &body)
+		req.Header.Set(echo.HeaderContentType, w.FormDataContentType())
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := upload("")
+	if resp.Code != http.StatusBadRequest {
+		t.Errorf("no hint: status = %d, want 400", resp.Code)
+	}
+	var rejected struct {
+		Alternatives []parser.FormatGuess `json:"alternatives"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &rejected)
+	if len(rejected.Alternatives) == 0 || rejected.Alternatives[0].Format != "ies" {
+		t.Errorf("no hint: alternatives = %+v", rejected.Alternatives)
+	}
+	if resp := upload("?source_format=xyz"); resp.Code != http.StatusBadRequest {
+		t.Errorf("unknown hint: status = %d, want 400", resp.Code)
+	}
+	if resp := upload("?parser=ies&source_format=ldt"); resp.Code != http.StatusBadRequest {
+		t.Errorf("conflicting hints: status = %d, want 400", resp.Code)
+	}
+
+	resp = upload("?source_format=IES")
+	var result struct {
+		LuminaireID int64 `json:"luminaire_id"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &result)
+	if result.LuminaireID == 0 {
+		t.Fatalf("upload: %s", resp.Body.String())
+	}
+	stored, err := database.LoadParsedLuminaire(h.db, result.LuminaireID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := stored.Metadata.FormatType; got != parser.DetectFormat("x.ies") {
+		t.Errorf("format type = %q", got)
+	}
+	if stored.Metadata.OriginalFilename != "downlight.txt" {
+		t.Errorf("original filename = %q", stored.Metadata.OriginalFilename)
+	}
+	if stored.Metadata.ParserOverride != "ies" {
+		t.Errorf("parser override = %q", stored.Metadata.ParserOverride)
+	}
+}
+
+// TestUploadParserOverride forces the LDT reader on an IES file, which
+// fails, and the IES reader on a misnamed one, which is recorded.
+func TestUploadParserOverride(t *testing.T) {
+	h := newTestHandler(t)
+	e := echo.New()
+	e.POST("/api/v1/luminaires", h.Upload)
+
+	lum, err := synth.Generate(synth.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := parser.Encode(parser.NewIESParser(), lum, parser.WriteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upload := func(name, query string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		w := multipart.NewWriter(&body)
+		part, _ := w.CreateFormFile("file", name)
+		part.Write(data)
+		w.Close()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/luminaires"+query, &body)
+		req.Header.Set(echo.HeaderContentType, w.FormDataContentType())
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, req)
+		return resp
+	}
+
+	if resp := upload("downlight.ies", "?parser=ldt"); resp.Code != http.StatusBadRequest {
+		t.Errorf("wrong parser: status = %d, want 400", resp.Code)
+	}
+
+	resp := upload("downlight.ldt", "?parser=IES")
+	var result struct {
+		LuminaireID int64 `json:"luminaire_id"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &result)
+	if result.LuminaireID == 0 {
+		t.Fatalf("upload: %s", resp.Body.String())
+	}
+	stored, err := database.LoadParsedLuminaire(h.db, result.LuminaireID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Metadata.ParserOverride != "ies" || stored.Metadata.FormatType != parser.DetectFormat("x.ies") {
+		t.Errorf("parser override = %q, format type = %q", stored.Metadata.ParserOverride, stored.Metadata.FormatType)
+	}
+}
+
+// TestConversionRequestSharedOptions checks that download and export reject
+// the same bad options.
+func TestConversionRequestSharedOptions(t *testing.T) {
+	h := newTestHandler(t)
+	e := echo.New()
+	e.GET("/api/v1/luminaires/:id/export", h.Export)
+	e.GET("/api/v1/luminaires/:id/download/:app", h.Download)
+
+	for _, path := range []string{"/api/v1/luminaires/1/export", "/api/v1/luminaires/1/download/dialux"} {
+		for _, query := range []string{"?eol=cr", "?encoding=ebcdic", "?line_length=0", "?orientation=sideways", "?anonymize=maybe", "?converted=yesterday", "?numbers=wide", "?column_width=99"} {
+			resp := httptest.NewRecorder()
+			e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, path+query, nil))
+			if resp.Code != http.StatusBadRequest {
+				t.Errorf("%s%s: status = %d, want 400", path, query, resp.Code)
+			}
+		}
+	}
+}
+
+// TestExportRenditions asks /export for several formats at once and checks
+// that each lands in the ZIP once and reads back.
+func TestExportRenditions(t *testing.T) {
+	h := newTestHandler(t)
+	e := echo.New()
+	e.GET("/api/v1/luminaires/:id/export", h.Export)
+
+	id := saveSynth(t, h, "renditions")
+
+	resp := httptest.NewRecorder()
+	e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/luminaires/%d/export?format=IES,ldt,cie,ies,LDT", id), nil))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", resp.Code, resp.Body.String())
+	}
+	if got := resp.Header().Get(echo.HeaderContentType); got != "application/zip" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(resp.Body.Bytes()), int64(resp.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+		if f.Name == bundle.SumsName {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := parser.GetParser(f.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := p.ParseReader(rc, f.Name); err != nil {
+			t.Errorf("%s: %v", f.Name, err)
+		}
+		rc.Close()
+	}
+	if len(names) != 4 || !strings.HasSuffix(names[0], ".ies") || names[3] != bundle.SumsName {
+		t.Errorf("entries = %v", names)
+	}
+
+	resp = httptest.NewRecorder()
+	e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/luminaires/%d/export?format=ies,xyz", id), nil))
+	if resp.Code != http.StatusBadRequest {
+		t.Errorf("unknown format: status = %d, want 400", resp.Code)
+	}
+}
+
+// TestExportFilename exports a luminaire whose maker and model hold spaces
+// and an umlaut in one format: the name is quoted and ASCII, as for
+// several formats.
+func TestExportFilename(t *testing.T) {
+	h := newTestHandler(t)
+	e := echo.New()
+	e.GET("/api/v1/luminaires/:id/export", h.Export)
+
+	id := saveSynth(t, h, "filename", func(lum *database.ParsedLuminaire) {
+		lum.Metadata.Manufacturer = "Lümen Licht"
+		lum.Metadata.Model = "Down Light 2"
+	})
+	for format, want := range map[string]string{
+		"ldt":     `attachment; filename="Lumen-Licht_Down-Light-2.ldt"`,
+		"ies,ldt": `attachment; filename="Lumen-Licht_Down-Light-2.zip"`,
+	} {
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/luminaires/%d/export?format=%s", id, format), nil))
+		if resp.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d: %s", format, resp.Code, resp.Body.String())
+		}
+		if got := resp.Header().Get("Content-Disposition"); got != want {
+			t.Errorf("%s: Content-Disposition = %q, want %q", format, got, want)
+		}
+	}
+}
+
+// TestExportAnonymize exports a luminaire with anonymize=true: the file
+// and its name carry no maker, model or lab.
+func TestExportAnonymize(t *testing.T) {
+	h := newTestHandler(t)
+	e := echo.New()
+	e.GET("/api/v1/luminaires/:id/export", h.Export)
+
+	var planes int
+	id := saveSynth(t, h, "anonymize", func(lum *database.ParsedLuminaire) {
+		planes = len(lum.CandelaMatrix)
+		lum.Metadata.Manufacturer = "Acme"
+		lum.Metadata.Model = "DL-100"
+		lum.Metadata.TestLab = "Photolab"
+	})
+
+	resp := httptest.NewRecorder()
+	e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/luminaires/%d/export?format=ies&anonymize=true", id), nil))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", resp.Code, resp.Body.String())
+	}
+	if got, want := resp.Header().Get("Content-Disposition"), fmt.Sprintf("luminaire_%d.ies", id); !strings.Contains(got, want) {
+		t.Errorf("Content-Disposition = %q, want %s", got, want)
+	}
+	for _, leak := range []string{"Acme", "DL-100", "Photolab"} {
+		if strings.Contains(resp.Body.String(), leak) {
+			t.Errorf("export contains %q:\n%s", leak, resp.Body.String())
+		}
+	}
+	back, err := parser.NewIESParser().ParseReader(strings.NewReader(resp.Body.String()), "back.ies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(back.CandelaMatrix) != planes {
+		t.Errorf("%d planes exported, want %d", len(back.CandelaMatrix), planes)
+	}
+}
+
+// TestExportDeterministic exports a luminaire twice with deterministic=true,
+// as one file and as a ZIP of renditions, and expects the same bytes.
+func TestExportDeterministic(t *testing.T) {
+	h := newTestHandler(t)
+	e := echo.New()
+	e.GET("/api/v1/luminaires/:id/export", h.Export)
+
+	id := saveSynth(t, h, "deterministic")
+
+	export := func(query string) string {
+		t.Helper()
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/luminaires/%d/export?%s", id, query), nil))
+		if resp.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d: %s", query, resp.Code, resp.Body.String())
+		}
+		return resp.Body.String()
+	}
+	for _, query := range []string{"format=ies&deterministic=true", "format=ies,ldt,cie&deterministic=true"} {
+		first := export(query)
+		time.Sleep(1100 * time.Millisecond)
+		if export(query) != first {
+			t.Errorf("%s: exports differ", query)
+		}
+	}
+	if out := export("format=ies&converted=2026-03-01T12:30:00Z"); !strings.Contains(out, "converted=2026-03-01T12:30:00Z") {
+		t.Errorf("pinned time not recorded:\n%s", out)
+	}
+}
+
+// TestUploadSalvage uploads a truncated IES file: it is rejected, and with
+// salvage=true answered with its header and complete planes, unstored.
+func TestUploadSalvage(t *testing.T) {
+	h := newTestHandler(t)
+	e := echo.New()
+	e.POST("/api/v1/luminaires", h.Upload)
+
+	lum, err := synth.Generate(synth.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lum.Metadata.Manufacturer = "Acme"
+	data, err := parser.Encode(parser.NewIESParser(), lum, parser.WriteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = data[:len(data)*3/4]
+
+	upload := func(query string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		w := multipart.NewWriter(&body)
+		part, _ := w.CreateFormFile("file", "cut.ies")
+		part.Write(data)
+		w.Close()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/luminaires"+query, &body)
+		req.Header.Set(echo.HeaderContentType, w.FormDataContentType())
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, req)
+		return resp
+	}
+
+	if resp := upload(""); resp.Code != http.StatusBadRequest {
+		t.Errorf("no salvage: status = %d, want 400", resp.Code)
+	}
+
+	resp := upload("?salvage=true")
+	if resp.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("salvage: status = %d, want 422: %s", resp.Code, resp.Body.String())
+	}
+	var result struct {
+		Status         string             `json:"status"`
+		Luminaire      database.Luminaire `json:"luminaire"`
+		CandelaValues  [][]float64        `json:"candela_values"`
+		PlanesRead     int                `json:"planes_read"`
+		PlanesExpected int                `json:"planes_expected"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &result)
+	if result.Status != "incomplete" || result.Luminaire.Manufacturer != "Acme" {
+		t.Errorf("salvage: status = %q, manufacturer = %q", result.Status, result.Luminaire.Manufacturer)
+	}
+	if result.PlanesRead != len(result.CandelaValues) || result.PlanesRead >= result.PlanesExpected {
+		t.Errorf("salvage: %d planes read of %d, %d returned", result.PlanesRead, result.PlanesExpected, len(result.CandelaValues))
+	}
+	var n int
+	h.db.QueryRow(`SELECT COUNT(*) FROM luminaires`).Scan(&n)
+	if n != 0 {
+		t.Errorf("%d luminaires stored", n)
+	}
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/parser"
+)
+
+var profileNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ListExportProfiles returns every stored mapping profile.
+func (h *LuminaireHandler) ListExportProfiles(c echo.Context) error {
+	rows, err := h.db.Query(`SELECT name, definition, updated_at FROM export_profiles ORDER BY name`)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	defer rows.Close()
+
+	profiles := []map[string]interface{}{}
+	for rows.Next() {
+		var name, definition, updatedAt string
+		if err := rows.Scan(&name, &definition, &updatedAt); err != nil {
+			continue
+		}
+		profiles = append(profiles, map[string]interface{}{
+			"name":       name,
+			"profile":    json.RawMessage(definition),
+			"updated_at": updatedAt,
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"profiles": profiles,
+	})
+}
+
+// GetExportProfile returns one mapping profile by name.
+func (h *LuminaireHandler) GetExportProfile(c echo.Context) error {
+	profile, err := h.loadExportProfile(c.Param("name"))
+	if errors.Is(err, sql.ErrNoRows) {
+		return apiError(c, http.StatusNotFound, "profile_not_found")
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"name":    c.Param("name"),
+		"profile": profile,
+	})
+}
+
+// PutExportProfile creates or replaces a mapping profile from a JSON body
+// such as {"ies": {"LUMCAT": "{catalog_number}"}, "keywords": [...]}.
+func (h *LuminaireHandler) PutExportProfile(c echo.Context) error {
+	name := c.Param("name")
+	if !profileNameRegex.MatchString(name) {
+		return apiError(c, http.StatusBadRequest, "invalid_profile_name")
+	}
+
+	var profile parser.MappingProfile
+	if err := json.NewDecoder(c.Request().Body).Decode(&profile); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid profile: %v", err)})
+	}
+	if err := profile.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	definition, _ := json.Marshal(profile)
+	_, err := h.db.Exec(`
+		INSERT INTO export_profiles (name, definition) VALUES (?, ?)
+		ON CONFLICT(name) DO UPDATE SET definition = excluded.definition, updated_at = CURRENT_TIMESTAMP`,
+		name, string(definition),
+	)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"status": "saved"})
+}
+
+func (h *LuminaireHandler) DeleteExportProfile(c echo.Context) error {
+	_, err := h.db.Exec("DELETE FROM export_profiles WHERE name = ?", c.Param("name"))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+func (h *LuminaireHandler) loadExportProfile(name string) (*parser.MappingProfile, error) {
+	var definition string
+	err := h.db.QueryRow(`SELECT definition FROM export_profiles WHERE name = ?`, name).Scan(&definition)
+	if err != nil {
+		return nil, err
+	}
+	var profile parser.MappingProfile
+	if err := json.Unmarshal([]byte(definition), &profile); err != nil {
+		return nil, fmt.Errorf("stored profile %s: %w", name, err)
+	}
+	return &profile, nil
+}
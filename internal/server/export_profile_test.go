@@ -0,0 +1,59 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+)
+
+func TestExportWithProfileAndKeywords(t *testing.T) {
+	h := newTestHandler(t)
+	id := saveSynth(t, h, "profile", func(lum *database.ParsedLuminaire) {
+		lum.Metadata.CatalogNumber = "DL-10"
+	})
+
+	e := echo.New()
+	e.PUT("/api/v1/export-profiles/:name", h.PutExportProfile)
+	e.GET("/api/v1/luminaires/:id/export", h.Export)
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, req)
+		return resp
+	}
+
+	if resp := do(http.MethodPut, "/api/v1/export-profiles/acme", `{"ies": {"LUMCAT": "{nope}"}}`); resp.Code != http.StatusBadRequest {
+		t.Errorf("invalid profile: status = %d", resp.Code)
+	}
+	if resp := do(http.MethodPut, "/api/v1/export-profiles/acme", `{"ies": {"LUMCAT": "{catalog_number}"}}`); resp.Code != http.StatusOK {
+		t.Fatalf("save profile: status = %d: %s", resp.Code, resp.Body.String())
+	}
+
+	resp := do(http.MethodGet, fmt.Sprintf("/api/v1/luminaires/%d/export?format=ies&profile=acme&keyword=OTHER:ERP-1&keyword=%%5BSEARCH%%5D:downlight", id), "")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("export: status = %d: %s", resp.Code, resp.Body.String())
+	}
+	for _, want := range []string{"[LUMCAT] DL-10", "[OTHER] ERP-1", "[SEARCH] downlight"} {
+		if !strings.Contains(resp.Body.String(), want) {
+			t.Errorf("export lacks %q", want)
+		}
+	}
+
+	resp = do(http.MethodGet, fmt.Sprintf("/api/v1/luminaires/%d/export?format=ldt&locale=de-DE", id), "")
+	if resp.Code != http.StatusOK || !strings.Contains(resp.Body.String(), "\n100,0\n") {
+		t.Errorf("locale de-DE should select comma decimals: status %d", resp.Code)
+	}
+
+	for _, query := range []string{"profile=missing", "keyword=novalue", "keyword=MORE:x", "decimal=dot"} {
+		if resp := do(http.MethodGet, fmt.Sprintf("/api/v1/luminaires/%d/export?%s", id, query), ""); resp.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, resp.Code)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+	"illuminate/internal/photometry"
+)
+
+// familyVariant is one member of a family with its photometry.
+type familyVariant struct {
+	database.Luminaire
+	Metrics *photometry.Metrics `json:"metrics"`
+}
+
+// familyIdentityFields differ between any two records and are never shared.
+var familyIdentityFields = map[string]bool{
+	"id": true, "file_hash": true, "original_filename": true,
+	"created_at": true, "updated_at": true,
+}
+
+// ListFamilies returns every family with its number of variants.
+func (h *LuminaireHandler) ListFamilies(c echo.Context) error {
+	rows, err := h.db.Query(`
+		SELECT f.name, f.description, f.updated_at, COUNT(v.luminaire_id)
+		FROM families f LEFT JOIN family_variants v ON v.family_id = f.id
+		GROUP BY f.id ORDER BY f.name`)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	defer rows.Close()
+
+	families := []map[string]interface{}{}
+	for rows.Next() {
+		var name, description, updatedAt string
+		var variants int
+		if err := rows.Scan(&name, &description, &updatedAt, &variants); err != nil {
+			continue
+		}
+		families = append(families, map[string]interface{}{
+			"name":        name,
+			"description": description,
+			"variants":    variants,
+			"updated_at":  updatedAt,
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"families": families,
+	})
+}
+
+// GetFamily returns a family view: the metadata every variant shares, the
+// names of the fields that vary, and each variant with its metrics.
+func (h *LuminaireHandler) GetFamily(c echo.Context) error {
+	name := c.Param("name")
+	var familyID int64
+	var description string
+	err := h.db.QueryRow(`SELECT id, description FROM families WHERE name = ?`, name).Scan(&familyID, &description)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apiError(c, http.StatusNotFound, "family_not_found")
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	luminaires, err := h.findLuminaires(c, h.db, &database.Filter{
+		Terms: []database.FilterTerm{{Field: "family", Op: "=", Value: name}},
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	variants := make([]familyVariant, 0, len(luminaires))
+	for _, lum := range luminaires {
+		v := familyVariant{Luminaire: lum}
+		if m, err := loadMetrics(h.db, lum.ID); err == nil {
+			v.Metrics = &m
+		}
+		variants = append(variants, v)
+	}
+	shared, varying := sharedMetadata(luminaires)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"name":        name,
+		"description": description,
+		"shared":      shared,
+		"varying":     varying,
+		"variants":    variants,
+	})
+}
+
+// sharedMetadata splits the metadata fields of luminaires into those with the
+// same value in every record and the names of those that differ.
+func sharedMetadata(luminaires []database.Luminaire) (map[string]interface{}, []string) {
+	shared := map[string]interface{}{}
+	varying := []string{}
+	if len(luminaires) == 0 {
+		return shared, varying
+	}
+	records := make([]map[string]interface{}, len(luminaires))
+	for i, lum := range luminaires {
+		data, _ := json.Marshal(lum)
+		json.Unmarshal(data, &records[i])
+	}
+
+	t := reflect.TypeOf(database.Luminaire{})
+	for i := 0; i < t.NumField(); i++ {
+		key, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if familyIdentityFields[key] {
+			continue
+		}
+		value := records[0][key]
+		same := true
+		for _, r := range records[1:] {
+			if !reflect.DeepEqual(r[key], value) {
+				same = false
+				break
+			}
+		}
+		if same {
+			shared[key] = value
+		} else {
+			varying = append(varying, key)
+		}
+	}
+	return shared, varying
+}
+
+// PutFamily creates a family or updates its description from a JSON body
+// such as {"description": "Downlight 200, 15–40 W"}.
+func (h *LuminaireHandler) PutFamily(c echo.Context) error {
+	name := c.Param("name")
+	if !profileNameRegex.MatchString(name) {
+		return apiError(c, http.StatusBadRequest, "invalid_family_name")
+	}
+
+	var body struct {
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(c.Request().Body).Decode(&body); err != nil {
+		return apiErrorWith(c, http.StatusBadRequest, "invalid_family", map[string]string{"detail": err.Error()})
+	}
+
+	_, err := h.db.Exec(`
+		INSERT INTO families (name, description) VALUES (?, ?)
+		ON CONFLICT(name) DO UPDATE SET description = excluded.description,
+			updated_at = CURRENT_TIMESTAMP`,
+		name, body.Description,
+	)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"status": "saved"})
+}
+
+// DeleteFamily removes a family and unlinks its variants; the luminaires
+// themselves are kept.
+func (h *LuminaireHandler) DeleteFamily(c echo.Context) error {
+	name := c.Param("name")
+	if _, err := h.db.Exec(`DELETE FROM family_variants WHERE family_id IN (SELECT id FROM families WHERE name = ?)`, name); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if _, err := h.db.Exec("DELETE FROM families WHERE name = ?", name); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// LinkVariant adds a luminaire to a family, moving it out of any family it
+// belonged to: PUT /api/v1/families/:name/variants/:id.
+func (h *LuminaireHandler) LinkVariant(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_id")
+	}
+
+	var familyID int64
+	err = h.db.QueryRow(`SELECT id FROM families WHERE name = ?`, c.Param("name")).Scan(&familyID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apiError(c, http.StatusNotFound, "family_not_found")
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	var exists int
+	if err := h.db.QueryRow(`SELECT COUNT(*) FROM luminaires WHERE id = ? AND `+database.NotDeleted, id).Scan(&exists); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if exists == 0 {
+		return apiError(c, http.StatusNotFound, "luminaire_not_found")
+	}
+
+	_, err = h.db.Exec(`INSERT OR REPLACE INTO family_variants (luminaire_id, family_id) VALUES (?, ?)`, id, familyID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	h.db.Exec(`UPDATE families SET updated_at = CURRENT_TIMESTAMP WHERE id = ?`, familyID)
+
+	return c.JSON(http.StatusOK, map[string]string{"status": "linked"})
+}
+
+// UnlinkVariant removes a luminaire from a family.
+func (h *LuminaireHandler) UnlinkVariant(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_id")
+	}
+
+	res, err := h.db.Exec(`
+		DELETE FROM family_variants WHERE luminaire_id = ?
+		AND family_id = (SELECT id FROM families WHERE name = ?)`, id, c.Param("name"))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return apiError(c, http.StatusNotFound, "family_variant_not_found")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"status": "unlinked"})
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/synth"
+)
+
+func TestFamilies(t *testing.T) {
+	h := newTestHandler(t)
+	var ids []int64
+	for i, watts := range []float64{10, 20, 30} {
+		opts := synth.DefaultOptions()
+		opts.InputWatts = watts
+		lum, err := synth.Generate(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lum.Metadata.FileHash = fmt.Sprintf("family-%d", i)
+		id, err := h.saveLuminaire(lum)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	e := echo.New()
+	e.GET("/api/v1/families", h.ListFamilies)
+	e.GET("/api/v1/families/:name", h.GetFamily)
+	e.PUT("/api/v1/families/:name", h.PutFamily)
+	e.DELETE("/api/v1/families/:name", h.DeleteFamily)
+	e.PUT("/api/v1/families/:name/variants/:id", h.LinkVariant)
+	e.DELETE("/api/v1/families/:name/variants/:id", h.UnlinkVariant)
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return resp
+	}
+
+	if resp := do(http.MethodPut, "/api/v1/families/downlight/variants/1", ""); resp.Code != http.StatusNotFound {
+		t.Errorf("link to missing family: status = %d", resp.Code)
+	}
+	if resp := do(http.MethodPut, "/api/v1/families/downlight", `{"description": "Downlight 200"}`); resp.Code != http.StatusOK {
+		t.Fatalf("put: status = %d: %s", resp.Code, resp.Body.String())
+	}
+	for _, id := range ids[:2] {
+		if resp := do(http.MethodPut, fmt.Sprintf("/api/v1/families/downlight/variants/%d", id), ""); resp.Code != http.StatusOK {
+			t.Fatalf("link %d: status = %d: %s", id, resp.Code, resp.Body.String())
+		}
+	}
+	if resp := do(http.MethodPut, "/api/v1/families/downlight/variants/999", ""); resp.Code != http.StatusNotFound {
+		t.Errorf("link missing luminaire: status = %d", resp.Code)
+	}
+
+	var family struct {
+		Shared   map[string]interface{} `json:"shared"`
+		Varying  []string               `json:"varying"`
+		Variants []struct {
+			ID      int64           `json:"id"`
+			Metrics json.RawMessage `json:"metrics"`
+		} `json:"variants"`
+	}
+	resp := do(http.MethodGet, "/api/v1/families/downlight", "")
+	json.Unmarshal(resp.Body.Bytes(), &family)
+	if len(family.Variants) != 2 || string(family.Variants[0].Metrics) == "null" {
+		t.Fatalf("variants = %s", resp.Body.String())
+	}
+	if !slices.Contains(family.Varying, "input_watts") || family.Shared["manufacturer"] == nil {
+		t.Errorf("shared = %v, varying = %v", family.Shared, family.Varying)
+	}
+	if _, ok := family.Shared["file_hash"]; ok || slices.Contains(family.Varying, "file_hash") {
+		t.Error("identity fields reported")
+	}
+
+	if resp := do(http.MethodDelete, fmt.Sprintf("/api/v1/families/downlight/variants/%d", ids[2]), ""); resp.Code != http.StatusNotFound {
+		t.Errorf("unlink non-member: status = %d", resp.Code)
+	}
+	do(http.MethodDelete, fmt.Sprintf("/api/v1/families/downlight/variants/%d", ids[0]), "")
+	if resp := do(http.MethodGet, "/api/v1/families", ""); !strings.Contains(resp.Body.String(), `"variants":1`) {
+		t.Errorf("list = %s", resp.Body.String())
+	}
+
+	do(http.MethodDelete, "/api/v1/families/downlight", "")
+	if resp := do(http.MethodGet, "/api/v1/families/downlight", ""); resp.Code != http.StatusNotFound {
+		t.Errorf("deleted family: status = %d", resp.Code)
+	}
+	var orphans int
+	h.db.QueryRow(`SELECT COUNT(*) FROM family_variants`).Scan(&orphans)
+	if orphans != 0 {
+		t.Errorf("%d variant links left after delete", orphans)
+	}
+}
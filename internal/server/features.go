@@ -0,0 +1,112 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/features"
+	"illuminate/internal/validate"
+)
+
+// organization is the caller's organization from the X-Organization header,
+// which the gateway in front of the API sets; "" is the deployment.
+func organization(c echo.Context) string {
+	return strings.TrimSpace(c.Request().Header.Get("X-Organization"))
+}
+
+// readerFlags are the flags gating the import-only readers, by extension.
+var readerFlags = map[string]string{
+	".oxl":  features.ParserOXL,
+	".cib":  features.ParserTM14,
+	".tm14": features.ParserTM14,
+}
+
+// checkReader refuses a file called name whose reader is switched off for
+// organization.
+func checkReader(flags *features.Flags, organization, name string) error {
+	ext := strings.ToLower(filepath.Ext(name))
+	if flag, ok := readerFlags[ext]; ok && !flags.Enabled(organization, flag) {
+		return fmt.Errorf("reading %s files is not enabled (feature flag %s)", ext, flag)
+	}
+	return nil
+}
+
+// ruleEnabled switches off the validation rules whose validator.<rule> flag
+// is off for organization; rules without a flag always run.
+func ruleEnabled(flags *features.Flags, organization string) validate.RuleFilter {
+	return func(rule string) bool {
+		name := "validator." + rule
+		if _, ok := features.Lookup(name); !ok {
+			return true
+		}
+		return flags.Enabled(organization, name)
+	}
+}
+
+// rules are the validation rules that run for organization; "" is the
+// deployment's, which stored results and catalog runs use.
+func (h *LuminaireHandler) rules(organization string) validate.RuleFilter {
+	return ruleEnabled(h.flags, organization)
+}
+
+// Features returns the feature flags as they apply to the caller's
+// organization.
+func (h *LuminaireHandler) Features(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"organization": organization(c),
+		"features":     h.flags.States(organization(c)),
+	})
+}
+
+// ListFeatures returns the feature flags as they resolve for the deployment,
+// or for ?organization=, with where each value comes from.
+func (h *LuminaireHandler) ListFeatures(c echo.Context) error {
+	org := strings.TrimSpace(c.QueryParam("organization"))
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"organization": org,
+		"features":     h.flags.States(org),
+	})
+}
+
+// PutFeature overrides a flag from a JSON body such as {"enabled": false},
+// for the whole deployment or, with "organization": "acme", for one
+// organization.
+func (h *LuminaireHandler) PutFeature(c echo.Context) error {
+	var req struct {
+		Enabled      *bool  `json:"enabled"`
+		Organization string `json:"organization"`
+	}
+	if err := json.NewDecoder(c.Request().Body).Decode(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid feature flag: %v", err)})
+	}
+	if req.Enabled == nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "enabled is required"})
+	}
+	org := strings.TrimSpace(req.Organization)
+	if err := h.flags.Set(org, c.Param("name"), *req.Enabled); err != nil {
+		return featureError(c, err)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"status": "saved", "features": h.flags.States(org)})
+}
+
+// DeleteFeature removes an override of the deployment, or of
+// ?organization=, so the next source in line decides.
+func (h *LuminaireHandler) DeleteFeature(c echo.Context) error {
+	org := strings.TrimSpace(c.QueryParam("organization"))
+	if err := h.flags.Reset(org, c.Param("name")); err != nil {
+		return featureError(c, err)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"status": "deleted", "features": h.flags.States(org)})
+}
+
+func featureError(c echo.Context, err error) error {
+	if errors.Is(err, features.ErrUnknown) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+}
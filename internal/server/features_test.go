@@ -0,0 +1,104 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/features"
+	"illuminate/internal/validate"
+)
+
+// TestFeatureFlags switches the TM-14 reader off for one organization
+// through the admin endpoint and checks that only its uploads are refused.
+func TestFeatureFlags(t *testing.T) {
+	h := newTestHandler(t)
+	h.flags = features.New(h.db, nil, 0)
+	e := echo.New()
+	e.PUT("/api/v1/admin/features/:name", h.PutFeature)
+	e.GET("/api/v1/features", h.Features)
+	e.POST("/api/v1/luminaires", h.Upload)
+
+	put := func(name, body string) int {
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, httptest.NewRequest(http.MethodPut, "/api/v1/admin/features/"+name, strings.NewReader(body)))
+		return resp.Code
+	}
+	if code := put("parser.gldf", `{"enabled": false}`); code != http.StatusNotFound {
+		t.Errorf("unknown flag: status %d", code)
+	}
+	if code := put(features.ParserTM14, `{"organization": "acme"}`); code != http.StatusBadRequest {
+		t.Errorf("override without enabled: status %d", code)
+	}
+	if code := put(features.ParserTM14, `{"enabled": false, "organization": "acme"}`); code != http.StatusOK {
+		t.Fatalf("put: status %d", code)
+	}
+
+	data, err := os.ReadFile("../parser/testdata/corpus/tm14_uk_recessed.cib")
+	if err != nil {
+		t.Fatal(err)
+	}
+	upload := func(org string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		w := multipart.NewWriter(&body)
+		part, _ := w.CreateFormFile("file", "recessed.cib")
+		part.Write(data)
+		w.Close()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/luminaires", &body)
+		req.Header.Set(echo.HeaderContentType, w.FormDataContentType())
+		req.Header.Set("X-Organization", org)
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, req)
+		return resp
+	}
+	if resp := upload("acme"); resp.Code != http.StatusBadRequest || !strings.Contains(resp.Body.String(), features.ParserTM14) {
+		t.Errorf("acme upload = %d %s, want it refused by the flag", resp.Code, resp.Body)
+	}
+	if resp := upload("other"); resp.Code != http.StatusOK {
+		t.Errorf("other upload = %d %s", resp.Code, resp.Body)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/features", nil)
+	req.Header.Set("X-Organization", "acme")
+	resp := httptest.NewRecorder()
+	e.ServeHTTP(resp, req)
+	var got struct {
+		Features []features.State `json:"features"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &got)
+	for _, s := range got.Features {
+		if s.Name == features.ParserTM14 && (s.Enabled || s.Source != features.SourceOrganization) {
+			t.Errorf("acme sees %+v", s)
+		}
+	}
+}
+
+// TestValidatorFlag checks that switching a rule off drops its issues and
+// changes the rules version, so cached results are checked again, and that
+// an organization's override only applies to it.
+func TestValidatorFlag(t *testing.T) {
+	h := newTestHandler(t)
+	flags := features.New(h.db, nil, 0)
+
+	before := validate.RulesVersion(ruleEnabled(flags, ""))
+	if err := flags.Set("acme", features.ValidatorConsistency, false); err != nil {
+		t.Fatal(err)
+	}
+	if validate.RulesVersion(ruleEnabled(flags, "")) != before {
+		t.Error("an organization's override changed the deployment's rules")
+	}
+	if validate.RulesVersion(ruleEnabled(flags, "acme")) == before {
+		t.Error("the rules version should change with the active rules")
+	}
+	for _, r := range validate.Active(ruleEnabled(flags, "acme")) {
+		if r.Name == "consistency" {
+			t.Error("the consistency rule is still active")
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/cache"
+	"illuminate/internal/database"
+	"illuminate/internal/logger"
+	"illuminate/internal/manifest"
+	"illuminate/internal/parser"
+)
+
+// maxFetchURLs caps how many URLs one FetchURLs request may name. The files
+// are fetched while the request waits, one per FETCH_HOST_INTERVAL on a
+// host, so a large batch would outlast the server's write timeout.
+const maxFetchURLs = 20
+
+// urlFetcher downloads the photometric files FetchURLs is given. It only
+// reaches the hosts FETCH_ALLOWED_HOSTS names, and waits
+// FETCH_HOST_INTERVAL between two requests to the same host, so a crawler
+// feeding it cannot make the catalog hammer a manufacturer's site.
+type urlFetcher struct {
+	client   *http.Client
+	allowed  []string
+	interval time.Duration
+	// slots holds a key per host for interval after each request; it is
+	// the job lock store, shared by every instance when Redis is.
+	slots cache.Store
+}
+
+// urlFetcherFromEnv reads FETCH_ALLOWED_HOSTS, a comma-separated list of
+// host names that also admits their subdomains, and FETCH_HOST_INTERVAL
+// (default 1s). Without allowed hosts nothing is fetched.
+func urlFetcherFromEnv(slots cache.Store) *urlFetcher {
+	interval, err := time.ParseDuration(os.Getenv("FETCH_HOST_INTERVAL"))
+	if err != nil || interval < 0 {
+		interval = time.Second
+	}
+	var allowed []string
+	for _, host := range strings.Split(os.Getenv("FETCH_ALLOWED_HOSTS"), ",") {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			allowed = append(allowed, host)
+		}
+	}
+	return newURLFetcher(allowed, interval, slots)
+}
+
+func newURLFetcher(allowed []string, interval time.Duration, slots cache.Store) *urlFetcher {
+	f := &urlFetcher{allowed: allowed, interval: interval, slots: slots}
+	f.client = &http.Client{
+		Timeout: 10 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("too many redirects")
+			}
+			if !f.allows(req.URL) {
+				return fmt.Errorf("redirect to %s: host not allowed", req.URL.Hostname())
+			}
+			return nil
+		},
+	}
+	return f
+}
+
+// allows reports whether u is an http or https URL on an allowed host.
+func (f *urlFetcher) allows(u *url.URL) bool {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, a := range f.allowed {
+		if host == a || strings.HasSuffix(host, "."+a) {
+			return true
+		}
+	}
+	return false
+}
+
+// wait blocks until host may be requested again and claims the slot.
+func (f *urlFetcher) wait(ctx context.Context, host string) error {
+	if f.interval <= 0 || f.slots == nil {
+		return nil
+	}
+	poll := max(f.interval/10, 10*time.Millisecond)
+	for {
+		ok, err := f.slots.Add(ctx, "fetch:host:"+host, []byte("1"), f.interval)
+		if err != nil {
+			logger.Default.Warnf("fetch throttle: %v", err)
+			return nil
+		}
+		if ok {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(poll):
+		}
+	}
+}
+
+// fetch downloads u and returns its content with the file name the server
+// gave it, or the last segment of its path.
+func (f *urlFetcher) fetch(ctx context.Context, u *url.URL) (string, []byte, error) {
+	if err := f.wait(ctx, strings.ToLower(u.Hostname())); err != nil {
+		return "", nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", nil, err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("%s answered %s", u.Hostname(), resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxArchiveEntrySize+1))
+	if err != nil {
+		return "", nil, err
+	}
+	if len(data) > maxArchiveEntrySize {
+		return "", nil, fmt.Errorf("file is larger than %d bytes", maxArchiveEntrySize)
+	}
+	name := path.Base(resp.Request.URL.Path)
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		name = path.Base(params["filename"])
+	}
+	return name, data, nil
+}
+
+// FetchURLs imports photometric files from the URLs a crawler discovered,
+// so the crawler needs no database access of its own: POST
+// /api/v1/luminaires/fetch {"urls": ["https://example.com/ies/dl200.ies"]}.
+// Each file is imported like a catalog archive entry; one whose name does not
+// say its format is read as its content clearly shows. A URL imported before
+// is answered as "seen" without fetching it again, and a file already on
+// record as "duplicate", or "trashed" when its record is in the trash;
+// either way luminaire_id is the record. Only hosts
+// in FETCH_ALLOWED_HOSTS are fetched, one request per FETCH_HOST_INTERVAL
+// each, so the answer can take a while for many URLs on one site.
+func (h *LuminaireHandler) FetchURLs(c echo.Context) error {
+	if h.fetcher == nil || len(h.fetcher.allowed) == 0 {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "fetching from URLs is not enabled (FETCH_ALLOWED_HOSTS)"})
+	}
+	var body struct {
+		URLs []string `json:"urls"`
+	}
+	if err := c.Bind(&body); err != nil || len(body.URLs) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": `a JSON body {"urls": [...]} is required`})
+	}
+	if len(body.URLs) > maxFetchURLs {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("at most %d URLs per request", maxFetchURLs)})
+	}
+
+	ctx := c.Request().Context()
+	org := organization(c)
+	results := make([]map[string]interface{}, len(body.URLs))
+	counts := map[string]int{}
+	done := map[string]map[string]interface{}{}
+	for i, raw := range body.URLs {
+		raw = strings.TrimSpace(raw)
+		r, ok := done[raw]
+		if !ok {
+			r = h.fetchURL(ctx, org, raw)
+			done[raw] = r
+		} else if id, imported := r["luminaire_id"]; imported {
+			r = map[string]interface{}{"status": "seen", "luminaire_id": id}
+		}
+		results[i] = map[string]interface{}{"url": raw}
+		for k, v := range r {
+			results[i][k] = v
+		}
+		counts[results[i]["status"].(string)]++
+	}
+
+	uploadLog.Info("fetch complete", "urls", len(body.URLs), "summary", counts)
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"results": results,
+		"summary": counts,
+	})
+}
+
+// fetchURL imports the file at raw unless an earlier request did.
+func (h *LuminaireHandler) fetchURL(ctx context.Context, organization, raw string) map[string]interface{} {
+	failed := func(err error) map[string]interface{} {
+		return map[string]interface{}{"status": "failed", "error": err.Error()}
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return failed(err)
+	}
+	if !h.fetcher.allows(u) {
+		return failed(fmt.Errorf("host %q is not allowed", u.Hostname()))
+	}
+
+	// Only a URL whose record is still in the catalog counts as seen.
+	var seen int64
+	err = h.db.QueryRowContext(ctx, `
+		SELECT luminaire_id FROM fetched_urls JOIN luminaires ON luminaires.id = fetched_urls.luminaire_id
+		WHERE url = ? AND `+database.NotDeleted, u.String()).Scan(&seen)
+	if err == nil {
+		return map[string]interface{}{"status": "seen", "luminaire_id": seen}
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return failed(err)
+	}
+
+	name, data, err := h.fetcher.fetch(ctx, u)
+	if err != nil {
+		return failed(err)
+	}
+	sourceFormat := ""
+	if _, err := parser.GetReader(name); err != nil {
+		if guesses := sniff(data); len(guesses) > 0 && guesses[0].Confidence >= parser.ConfidenceInferred {
+			sourceFormat = guesses[0].Format
+		} else {
+			return failed(fmt.Errorf("%s is not a photometric file", name))
+		}
+	}
+	res := h.importFile(ctx, organization, name, sourceFormat, data, manifest.Row{})
+	if _, ok := res["error"]; ok {
+		res["status"] = "failed"
+		return res
+	}
+	if _, err := h.db.ExecContext(ctx, `INSERT OR REPLACE INTO fetched_urls (url, luminaire_id) VALUES (?, ?)`, u.String(), res["luminaire_id"]); err != nil {
+		logger.Default.Warnf("record fetched URL %s: %v", u, err)
+	}
+	return res
+}
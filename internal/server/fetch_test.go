@@ -0,0 +1,128 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/cache"
+	"illuminate/internal/parser"
+	"illuminate/internal/synth"
+)
+
+// TestFetchURLs imports files from a manufacturer's site: by name, by
+// content, seen before, duplicated, again after deletion, and refused.
+func TestFetchURLs(t *testing.T) {
+	lum, err := synth.Generate(synth.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ies, _ := parser.Encode(parser.NewIESParser(), lum, parser.WriteOptions{})
+	lum.Metadata.Model = "DL-2"
+	ldt, _ := parser.Encode(parser.NewLDTParser(), lum, parser.WriteOptions{})
+
+	var mu sync.Mutex
+	var hits []time.Time
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits = append(hits, time.Now())
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/ies/dl1.ies", "/mirror/dl1.ies":
+			w.Write(ies)
+		case "/download":
+			w.Write(ldt)
+		case "/away":
+			http.Redirect(w, r, "http://example.com/dl1.ies", http.StatusFound)
+		case "/brochure.pdf":
+			w.Write([]byte("%PDF-1.4"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer site.Close()
+
+	h := newTestHandler(t)
+	h.fetcher = newURLFetcher([]string{"127.0.0.1"}, 20*time.Millisecond, cache.NewLRU(0))
+	e := echo.New()
+	e.POST("/api/v1/luminaires/fetch", h.FetchURLs)
+	fetch := func(urls ...string) (int, map[string]int, []map[string]interface{}) {
+		body, _ := json.Marshal(map[string][]string{"urls": urls})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/luminaires/fetch", strings.NewReader(string(body)))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, req)
+		var out struct {
+			Results []map[string]interface{} `json:"results"`
+			Summary map[string]int           `json:"summary"`
+		}
+		json.Unmarshal(resp.Body.Bytes(), &out)
+		return resp.Code, out.Summary, out.Results
+	}
+
+	code, summary, results := fetch(
+		site.URL+"/ies/dl1.ies",
+		site.URL+"/download",
+		site.URL+"/ies/dl1.ies",
+		site.URL+"/mirror/dl1.ies",
+		site.URL+"/missing.ies",
+		site.URL+"/away",
+		site.URL+"/brochure.pdf",
+		"http://example.com/dl1.ies",
+		"file:///etc/passwd",
+	)
+	if code != http.StatusOK {
+		t.Fatalf("status = %d", code)
+	}
+	if summary["uploaded"] != 2 || summary["seen"] != 1 || summary["duplicate"] != 1 || summary["failed"] != 5 {
+		t.Errorf("summary = %v: %v", summary, results)
+	}
+	if results[0]["status"] != "uploaded" || results[1]["status"] != "uploaded" ||
+		results[2]["status"] != "seen" || results[2]["luminaire_id"] != results[0]["luminaire_id"] {
+		t.Errorf("results = %v", results)
+	}
+	if results[3]["status"] != "duplicate" || results[3]["luminaire_id"] != results[0]["luminaire_id"] {
+		t.Errorf("mirrored file = %v", results[3])
+	}
+	for _, r := range results[4:] {
+		if r["status"] != "failed" || r["error"] == "" {
+			t.Errorf("%v", r)
+		}
+	}
+
+	// The site was asked one file at a time, and each URL once.
+	mu.Lock()
+	n := len(hits)
+	for i := 1; i < len(hits); i++ {
+		if gap := hits[i].Sub(hits[i-1]); gap < 15*time.Millisecond {
+			t.Errorf("request %d followed %v after the last", i, gap)
+		}
+	}
+	mu.Unlock()
+	if _, summary, _ := fetch(site.URL + "/download"); summary["seen"] != 1 {
+		t.Errorf("second request = %v", summary)
+	}
+	mu.Lock()
+	if len(hits) != n {
+		t.Error("a URL seen before was fetched again")
+	}
+	mu.Unlock()
+
+	if err := deleteLuminaire(h.db, int64(results[1]["luminaire_id"].(float64))); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, again := fetch(site.URL + "/download"); len(again) != 1 || again[0]["status"] != "uploaded" ||
+		again[0]["luminaire_id"] == results[1]["luminaire_id"] {
+		t.Errorf("after deletion = %v", again)
+	}
+
+	h.fetcher = newURLFetcher(nil, 0, nil)
+	if code, _, _ := fetch(site.URL + "/ies/dl1.ies"); code != http.StatusForbidden {
+		t.Errorf("no allowed hosts: status = %d, want 403", code)
+	}
+}
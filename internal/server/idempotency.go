@@ -0,0 +1,135 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/cache"
+	"illuminate/internal/logger"
+)
+
+// idempotency replays the response of a POST retried with the same
+// Idempotency-Key header instead of running it again, so a client that lost
+// the answer to an upload or merge can safely resend it. Keys are kept in
+// store for ttl; instances that share a Redis share them.
+type idempotency struct {
+	store cache.Store
+	ttl   time.Duration
+}
+
+// idempotencyPending marks a key whose first request is still running. The
+// mark expires after idempotencyPendingTTL, so a key whose instance died
+// mid-request does not stay claimed for the full ttl.
+var idempotencyPending = []byte("pending")
+
+const idempotencyPendingTTL = 10 * time.Minute
+
+// storedResponse is a response kept for replay.
+type storedResponse struct {
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+}
+
+// idempotencyFromEnv keeps keys for IDEMPOTENCY_TTL (default 24h), in shared
+// when it is not nil and in memory otherwise.
+func idempotencyFromEnv(shared *cache.Redis) idempotency {
+	ttl, err := time.ParseDuration(os.Getenv("IDEMPOTENCY_TTL"))
+	if err != nil || ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	i := idempotency{ttl: ttl}
+	if shared != nil {
+		i.store = shared
+	} else {
+		i.store = cache.NewLRU(10000)
+	}
+	return i
+}
+
+// captureWriter copies what a handler writes, for storing.
+type captureWriter struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// middleware claims the key of a POST carrying Idempotency-Key before
+// running it. A retry of a finished request gets the stored response with
+// Idempotent-Replayed: true; a retry while the first is still running gets
+// 409. Server errors are not stored, so those requests can be retried.
+func (i idempotency) middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			key := req.Header.Get("Idempotency-Key")
+			if i.store == nil || req.Method != http.MethodPost || key == "" {
+				return next(c)
+			}
+			if len(key) > 255 {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "Idempotency-Key must be at most 255 characters"})
+			}
+
+			// The outcome is stored even when the client has gone away.
+			ctx := context.WithoutCancel(req.Context())
+			storeKey := fmt.Sprintf("idempotency:%x", sha256.Sum256([]byte(req.URL.Path+"\n"+key)))
+			claimed, err := i.store.Add(ctx, storeKey, idempotencyPending, min(i.ttl, idempotencyPendingTTL))
+			if err != nil {
+				logger.Default.Warnf("idempotency: %v", err)
+				return next(c)
+			}
+			if !claimed {
+				return i.replay(c, storeKey)
+			}
+
+			w := &captureWriter{ResponseWriter: c.Response().Writer}
+			c.Response().Writer = w
+			err = next(c)
+			c.Response().Writer = w.ResponseWriter
+
+			status := c.Response().Status
+			if err != nil || status >= http.StatusInternalServerError {
+				if derr := i.store.Delete(ctx, storeKey); derr != nil {
+					logger.Default.Warnf("idempotency: %v", derr)
+				}
+				return err
+			}
+			stored, _ := json.Marshal(storedResponse{
+				Status:      status,
+				ContentType: c.Response().Header().Get(echo.HeaderContentType),
+				Body:        w.body.Bytes(),
+			})
+			if err := i.store.Set(ctx, storeKey, stored, i.ttl); err != nil {
+				logger.Default.Warnf("idempotency: %v", err)
+			}
+			return nil
+		}
+	}
+}
+
+func (i idempotency) replay(c echo.Context, storeKey string) error {
+	b, ok, err := i.store.Get(c.Request().Context(), storeKey)
+	if err != nil {
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+	}
+	if !ok || bytes.Equal(b, idempotencyPending) {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "a request with this Idempotency-Key is still in progress"})
+	}
+	var resp storedResponse
+	if err := json.Unmarshal(b, &resp); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	c.Response().Header().Set("Idempotent-Replayed", "true")
+	return c.Blob(resp.Status, resp.ContentType, resp.Body)
+}
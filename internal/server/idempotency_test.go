@@ -0,0 +1,78 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/cache"
+	"illuminate/internal/cache/redistest"
+)
+
+// TestIdempotency runs two instances sharing one Redis and checks that a
+// POST retried on the other instance is answered from the first one's
+// response instead of running again.
+func TestIdempotency(t *testing.T) {
+	srv, err := redistest.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	runs := 0
+	var instances []*echo.Echo
+	for range 2 {
+		r, err := cache.NewRedis(srv.URL())
+		if err != nil {
+			t.Fatal(err)
+		}
+		e := echo.New()
+		e.Use(idempotency{store: r, ttl: time.Hour}.middleware())
+		e.POST("/api/v1/luminaires", func(c echo.Context) error {
+			runs++
+			return c.JSON(http.StatusCreated, map[string]string{"run": strconv.Itoa(runs)})
+		})
+		e.POST("/api/v1/fail", func(c echo.Context) error {
+			runs++
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "boom"})
+		})
+		instances = append(instances, e)
+	}
+
+	post := func(e *echo.Echo, path, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := post(instances[0], "/api/v1/luminaires", "upload-1")
+	retry := post(instances[1], "/api/v1/luminaires", "upload-1")
+	if runs != 1 {
+		t.Fatalf("handler ran %d times, want once", runs)
+	}
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() {
+		t.Errorf("retry = %d %s, want the first response %s", retry.Code, retry.Body, first.Body)
+	}
+	if retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("a replay should be marked as one")
+	}
+
+	post(instances[0], "/api/v1/luminaires", "upload-2")
+	post(instances[0], "/api/v1/luminaires", "")
+	if runs != 3 {
+		t.Errorf("handler ran %d times, want 3: new keys and requests without one run", runs)
+	}
+
+	post(instances[0], "/api/v1/fail", "upload-3")
+	post(instances[1], "/api/v1/fail", "upload-3")
+	if runs != 5 {
+		t.Errorf("handler ran %d times, want 5: server errors are not replayed", runs)
+	}
+}
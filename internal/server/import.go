@@ -0,0 +1,320 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+	"illuminate/internal/logger"
+	"illuminate/internal/manifest"
+	"illuminate/internal/parser"
+)
+
+// importLog traces catalog imports; LOG_LEVEL_IMPORT sets its level.
+var importLog = logger.For("import")
+
+// maxArchiveEntrySize caps how much of one photometric file in an import
+// archive is decompressed.
+const maxArchiveEntrySize = 16 << 20
+
+// manifestTextColumns map manifest columns to text metadata fields.
+var manifestTextColumns = map[string]func(*database.Luminaire) *string{
+	"manufacturer":          func(m *database.Luminaire) *string { return &m.Manufacturer },
+	"model":                 func(m *database.Luminaire) *string { return &m.Model },
+	"catalog_number":        func(m *database.Luminaire) *string { return &m.CatalogNumber },
+	"luminaire_description": func(m *database.Luminaire) *string { return &m.LuminaireDesc },
+	"description":           func(m *database.Luminaire) *string { return &m.LuminaireDesc },
+	"lamp_type":             func(m *database.Luminaire) *string { return &m.LampType },
+	"lamp_catalog":          func(m *database.Luminaire) *string { return &m.LampCatalog },
+	"ballast":               func(m *database.Luminaire) *string { return &m.Ballast },
+	"test_lab":              func(m *database.Luminaire) *string { return &m.TestLab },
+	"test_number":           func(m *database.Luminaire) *string { return &m.TestNumber },
+	"issue_date":            func(m *database.Luminaire) *string { return &m.IssueDate },
+}
+
+// manifestNumberColumns map manifest columns to numeric metadata fields,
+// with the same short aliases as filter expressions.
+var manifestNumberColumns = map[string]func(*database.Luminaire, float64){
+	"input_watts":     func(m *database.Luminaire, v float64) { m.InputWatts = v },
+	"watts":           func(m *database.Luminaire, v float64) { m.InputWatts = v },
+	"luminous_flux":   func(m *database.Luminaire, v float64) { m.LuminousFlux = v },
+	"flux":            func(m *database.Luminaire, v float64) { m.LuminousFlux = v },
+	"color_temp":      func(m *database.Luminaire, v float64) { m.ColorTemp = int(v) },
+	"cct":             func(m *database.Luminaire, v float64) { m.ColorTemp = int(v) },
+	"cri":             func(m *database.Luminaire, v float64) { m.CRI = int(v) },
+	"luminous_length": func(m *database.Luminaire, v float64) { m.LuminousLength = v },
+	"luminous_width":  func(m *database.Luminaire, v float64) { m.LuminousWidth = v },
+
+	"lamp_lumen_depreciation":   func(m *database.Luminaire, v float64) { m.LampLumenDepreciation = v },
+	"driver_maintenance_factor": func(m *database.Luminaire, v float64) { m.DriverMaintenanceFactor = v },
+	"rated_life":                func(m *database.Luminaire, v float64) { m.RatedLife = int(v) },
+
+	"luminous_height_c0":   func(m *database.Luminaire, v float64) { m.LuminousHeightC0 = v },
+	"luminous_height_c90":  func(m *database.Luminaire, v float64) { m.LuminousHeightC90 = v },
+	"luminous_height_c180": func(m *database.Luminaire, v float64) { m.LuminousHeightC180 = v },
+	"luminous_height_c270": func(m *database.Luminaire, v float64) { m.LuminousHeightC270 = v },
+
+	"thd":            func(m *database.Luminaire, v float64) { m.THD = v },
+	"inrush_current": func(m *database.Luminaire, v float64) { m.InrushCurrent = v },
+	"frequency":      func(m *database.Luminaire, v float64) { m.Frequency = v },
+}
+
+// ImportCatalog migrates a catalog in one request: a "manifest" spreadsheet
+// (CSV or XLSX) with a filename column plus metadata columns, and an
+// "archive" ZIP of the photometric files. Rows are matched to archive
+// entries by base name, case-insensitively; non-empty cells override the
+// parsed metadata. Each row is reported like a batch upload, and archive
+// entries no row names are listed as unmatched.
+func (h *LuminaireHandler) ImportCatalog(c echo.Context) error {
+	manifestFile, err := c.FormFile("manifest")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "manifest is required"})
+	}
+	archiveFile, err := c.FormFile("archive")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "archive_required")
+	}
+
+	data, err := readFormFile(manifestFile)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	rows, err := manifest.Read(manifestFile.Filename, data)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if len(rows) > 0 {
+		if _, ok := rows[0].Fields["filename"]; !ok {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "manifest has no filename column"})
+		}
+	}
+
+	archive, err := readFormFile(archiveFile)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid archive: %v", err)})
+	}
+	entries := map[string]*zip.File{}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || strings.HasPrefix(path.Base(f.Name), ".") {
+			continue
+		}
+		entries[strings.ToLower(path.Base(f.Name))] = f
+	}
+
+	importLog.Info("catalog import start", "rows", len(rows), "files", len(entries))
+
+	ctx := c.Request().Context()
+	org := organization(c)
+	results := make([]map[string]interface{}, len(rows))
+	used := map[string]bool{}
+	group := h.pool.Group(h.batchConcurrency)
+	for i, row := range rows {
+		filename := row.Get("filename")
+		key := strings.ToLower(path.Base(filename))
+		entry, ok := entries[key]
+		if filename == "" || !ok {
+			results[i] = map[string]interface{}{"error": "file not found in archive"}
+			continue
+		}
+		used[key] = true
+		err := group.Go(ctx, func() {
+			results[i] = h.importManifestRow(ctx, org, entry, row)
+		})
+		if err != nil {
+			results[i] = map[string]interface{}{"error": err.Error()}
+		}
+	}
+	group.Wait()
+
+	counts := map[string]int{}
+	for i, r := range results {
+		r["line"] = rows[i].Line
+		r["filename"] = rows[i].Get("filename")
+		if _, failed := r["error"]; failed {
+			r["status"] = "failed"
+		}
+		counts[r["status"].(string)]++
+	}
+	unmatched := []string{}
+	for _, f := range zr.File {
+		if key := strings.ToLower(path.Base(f.Name)); entries[key] == f && !used[key] {
+			unmatched = append(unmatched, f.Name)
+		}
+	}
+
+	importLog.Info("catalog import complete", "summary", counts, "unmatched", len(unmatched))
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"results":   results,
+		"summary":   counts,
+		"unmatched": unmatched,
+	})
+}
+
+// importManifestRow imports one archive entry with its manifest row.
+func (h *LuminaireHandler) importManifestRow(ctx context.Context, organization string, entry *zip.File, row manifest.Row) map[string]interface{} {
+	data, err := readArchiveEntry(entry)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}
+	}
+	return h.importFile(ctx, organization, path.Base(entry.Name), "", data, row)
+}
+
+// importFile parses a file, applies the import profile and then the
+// manifest row, and stores the result. The file is read as sourceFormat
+// when it is set, and as its name says otherwise. A file already on record
+// is not stored again but reported as a duplicate of that record. The
+// feature flags of organization apply.
+func (h *LuminaireHandler) importFile(ctx context.Context, organization, name, sourceFormat string, data []byte, row manifest.Row) map[string]interface{} {
+	readerName := name
+	if sourceFormat != "" {
+		readerName = "source." + sourceFormat
+	}
+	p, err := parser.GetReader(readerName)
+	if err == nil {
+		err = checkReader(h.flags, organization, readerName)
+	}
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}
+	}
+
+	lum, err := h.cache.Parse(ctx, p, data, name)
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("parse error: %v", err)}
+	}
+	lum.Metadata.OriginalFilename = name
+	lum.Metadata.FormatType = parser.DetectFormat(readerName)
+	lum.Metadata.ParserOverride = sourceFormat
+	parsed := lum.Metadata
+	h.applyImportProfile(organization, &lum.Metadata)
+	profiled := lum.Metadata
+	if err := applyManifestRow(&lum.Metadata, row); err != nil {
+		return map[string]interface{}{"error": err.Error()}
+	}
+
+	missing := []string{}
+	if lum.Metadata.Manufacturer == "" {
+		missing = append(missing, "manufacturer")
+	}
+	if lum.Metadata.Model == "" {
+		missing = append(missing, "model")
+	}
+	if len(missing) > 0 {
+		return map[string]interface{}{"error": "missing " + strings.Join(missing, " and ")}
+	}
+
+	existing, trashed, ok, err := recordByHash(h.db, lum.Metadata.FileHash)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}
+	}
+	if ok {
+		status := "duplicate"
+		if trashed {
+			status = "trashed"
+		}
+		return map[string]interface{}{
+			"status":       status,
+			"luminaire_id": existing,
+		}
+	}
+
+	id, err := h.saveLuminaire(lum)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}
+	}
+	h.saveSource(id, name, name, data)
+	saveFieldSources(h.db, id, fieldSources(parsed, profiled, lum.Metadata))
+	return map[string]interface{}{
+		"status":       "uploaded",
+		"luminaire_id": id,
+	}
+}
+
+// ImportResult is what ImportFile did with one file: Status is uploaded,
+// duplicate or trashed, with LuminaireID the record already holding the
+// file, live or in the trash, or failed, with Err saying why.
+type ImportResult struct {
+	Status      string
+	LuminaireID int64
+	Err         error
+}
+
+// ImportFile stores one photometric file outside of a request, as the
+// illuminate import command does for legacy archives. It is imported like a
+// catalog archive entry without a manifest row, read as sourceFormat or, when
+// that is empty, as its name says.
+func ImportFile(ctx context.Context, db *sql.DB, name, sourceFormat string, data []byte) ImportResult {
+	h := &LuminaireHandler{db: db}
+	res := h.importFile(ctx, "", name, sourceFormat, data, manifest.Row{})
+	if msg, failed := res["error"]; failed {
+		return ImportResult{Status: "failed", Err: errors.New(msg.(string))}
+	}
+	return ImportResult{Status: res["status"].(string), LuminaireID: res["luminaire_id"].(int64)}
+}
+
+// readArchiveEntry decompresses one archive entry of at most
+// maxArchiveEntrySize bytes.
+func readArchiveEntry(entry *zip.File) ([]byte, error) {
+	rc, err := entry.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, maxArchiveEntrySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxArchiveEntrySize {
+		return nil, errors.New("file too large")
+	}
+	return data, nil
+}
+
+// applyManifestRow copies the non-empty metadata cells of a row into meta.
+// Numbers may use a decimal comma.
+func applyManifestRow(meta *database.Luminaire, row manifest.Row) error {
+	for column := range row.Fields {
+		value := row.Get(column)
+		if value == "" {
+			continue
+		}
+		if field, ok := manifestTextColumns[column]; ok {
+			*field(meta) = value
+			continue
+		}
+		if set, ok := manifestNumberColumns[column]; ok {
+			if !strings.Contains(value, ".") {
+				value = strings.Replace(value, ",", ".", 1)
+			}
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil || v < 0 {
+				return fmt.Errorf("column %s: invalid number %q", column, row.Get(column))
+			}
+			set(meta, v)
+		}
+	}
+	return nil
+}
+
+func readFormFile(file *multipart.FileHeader) ([]byte, error) {
+	src, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s", file.Filename)
+	}
+	defer src.Close()
+	return io.ReadAll(src)
+}
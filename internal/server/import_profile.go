@@ -0,0 +1,129 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+	"illuminate/internal/features"
+	"illuminate/internal/logger"
+	"illuminate/internal/parser"
+)
+
+// ListImportProfiles returns every stored import profile.
+func (h *LuminaireHandler) ListImportProfiles(c echo.Context) error {
+	rows, err := h.db.Query(`SELECT name, definition, updated_at FROM import_profiles ORDER BY name`)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	defer rows.Close()
+
+	profiles := []map[string]interface{}{}
+	for rows.Next() {
+		var name, definition, updatedAt string
+		if err := rows.Scan(&name, &definition, &updatedAt); err != nil {
+			continue
+		}
+		profiles = append(profiles, map[string]interface{}{
+			"name":       name,
+			"profile":    json.RawMessage(definition),
+			"updated_at": updatedAt,
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"profiles": profiles,
+	})
+}
+
+// GetImportProfile returns one import profile by name.
+func (h *LuminaireHandler) GetImportProfile(c echo.Context) error {
+	var definition string
+	err := h.db.QueryRow(`SELECT definition FROM import_profiles WHERE name = ?`, c.Param("name")).Scan(&definition)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apiError(c, http.StatusNotFound, "profile_not_found")
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"name":    c.Param("name"),
+		"profile": json.RawMessage(definition),
+	})
+}
+
+// PutImportProfile creates or replaces an import profile from a JSON body
+// such as {"manufacturer": "Acme", "fields": {"model": {"source": "{luminaire_description}"}}}.
+func (h *LuminaireHandler) PutImportProfile(c echo.Context) error {
+	name := c.Param("name")
+	if !profileNameRegex.MatchString(name) {
+		return apiError(c, http.StatusBadRequest, "invalid_profile_name")
+	}
+
+	var profile parser.ImportProfile
+	if err := json.NewDecoder(c.Request().Body).Decode(&profile); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid profile: %v", err)})
+	}
+	if err := profile.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	profile.Manufacturer = strings.TrimSpace(profile.Manufacturer)
+
+	definition, _ := json.Marshal(profile)
+	_, err := h.db.Exec(`
+		INSERT INTO import_profiles (name, manufacturer, definition) VALUES (?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET manufacturer = excluded.manufacturer,
+			definition = excluded.definition, updated_at = CURRENT_TIMESTAMP`,
+		name, profile.Manufacturer, string(definition),
+	)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"status": "saved"})
+}
+
+func (h *LuminaireHandler) DeleteImportProfile(c echo.Context) error {
+	_, err := h.db.Exec("DELETE FROM import_profiles WHERE name = ?", c.Param("name"))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// applyImportProfile applies the import profile of the detected manufacturer
+// to freshly parsed metadata and returns its name, or "" when none matches
+// or the normalize flag is off for organization. When several profiles name
+// the same manufacturer the first by name wins.
+func (h *LuminaireHandler) applyImportProfile(organization string, meta *database.Luminaire) string {
+	manufacturer := strings.TrimSpace(meta.Manufacturer)
+	if manufacturer == "" || !h.flags.Enabled(organization, features.Normalize) {
+		return ""
+	}
+
+	var name, definition string
+	err := h.db.QueryRow(`
+		SELECT name, definition FROM import_profiles
+		WHERE manufacturer = ? COLLATE NOCASE ORDER BY name LIMIT 1`, manufacturer,
+	).Scan(&name, &definition)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			logger.Default.Warnf("import profile lookup: %v", err)
+		}
+		return ""
+	}
+
+	var profile parser.ImportProfile
+	if err := json.Unmarshal([]byte(definition), &profile); err != nil {
+		logger.Default.Warnf("stored import profile %s: %v", name, err)
+		return ""
+	}
+	profile.Apply(meta)
+	uploadLog.Debug("applied import profile", "profile", name)
+	return name
+}
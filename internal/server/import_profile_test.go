@@ -0,0 +1,89 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+	"illuminate/internal/parser"
+	"illuminate/internal/synth"
+)
+
+func TestImportProfileAppliedOnUpload(t *testing.T) {
+	h := newTestHandler(t)
+	e := echo.New()
+	e.PUT("/api/v1/import-profiles/:name", h.PutImportProfile)
+	e.POST("/api/v1/luminaires/upload", h.Upload)
+
+	put := func(body string) int {
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, httptest.NewRequest(http.MethodPut, "/api/v1/import-profiles/acme", strings.NewReader(body)))
+		return resp.Code
+	}
+	if code := put(`{"manufacturer": "Acme", "fields": {"lamp_type": {"source": "{model}"}}}`); code != http.StatusBadRequest {
+		t.Errorf("unknown target: status = %d", code)
+	}
+	// Acme puts the catalog number in LUMCAT and the model only in the
+	// filename.
+	if code := put(`{"manufacturer": "ACME", "fields": {
+		"catalog_number": {"source": "{model}"},
+		"model": {"source": "{original_filename}", "pattern": "^ACME_([^.]+)"}}}`); code != http.StatusOK {
+		t.Fatalf("put: status = %d", code)
+	}
+
+	upload := func(manufacturer string) int64 {
+		t.Helper()
+		opts := synth.DefaultOptions()
+		opts.Manufacturer = manufacturer
+		lum, err := synth.Generate(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lum.Metadata.Model = "AC-4711-840"
+		data, err := parser.Encode(parser.NewIESParser(), lum, parser.WriteOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var body bytes.Buffer
+		w := multipart.NewWriter(&body)
+		part, _ := w.CreateFormFile("file", "ACME_Downlight200.ies")
+		part.Write(data)
+		w.Close()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/luminaires/upload", &body)
+		req.Header.Set(echo.HeaderContentType, w.FormDataContentType())
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, req)
+
+		var result struct {
+			LuminaireID int64 `json:"luminaire_id"`
+		}
+		json.Unmarshal(resp.Body.Bytes(), &result)
+		if result.LuminaireID == 0 {
+			t.Fatalf("upload: %s", resp.Body.String())
+		}
+		return result.LuminaireID
+	}
+
+	lum, err := database.LoadParsedLuminaire(h.db, upload("acme"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lum.Metadata.Model != "Downlight200" || lum.Metadata.CatalogNumber != "AC-4711-840" {
+		t.Errorf("mapped model = %q, catalog number = %q", lum.Metadata.Model, lum.Metadata.CatalogNumber)
+	}
+
+	lum, err = database.LoadParsedLuminaire(h.db, upload("Other Co"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lum.Metadata.Model != "AC-4711-840" || lum.Metadata.CatalogNumber != "" {
+		t.Errorf("unmatched manufacturer was remapped: %q / %q", lum.Metadata.Model, lum.Metadata.CatalogNumber)
+	}
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+	"illuminate/internal/parser"
+	"illuminate/internal/synth"
+	"illuminate/internal/worker"
+)
+
+func TestImportCatalog(t *testing.T) {
+	h := newTestHandler(t)
+	h.pool = worker.NewPool(2, 4)
+	defer h.pool.Close()
+
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	for i, name := range []string{"legacy/DL200.ies", "legacy/DL300.LDT", "legacy/spare.ies"} {
+		opts := synth.DefaultOptions()
+		opts.Flux = float64(1000 * (i + 1))
+		lum, err := synth.Generate(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, _ := parser.GetParser(name)
+		data, err := parser.Encode(p, lum, parser.WriteOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		w, _ := zw.Create(name)
+		w.Write(data)
+	}
+	zw.Close()
+
+	manifestCSV := "Filename;Model;Catalog Number;Input Watts;CCT\n" +
+		"dl200.ies;Downlight 200;DL-200-830;12,5;3000\n" +
+		"DL300.LDT;Downlight 300;DL-300-840;18;4000\n" +
+		"missing.ies;Ghost;;;\n" +
+		"DL200.ies;Downlight 200b;;x;\n"
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile("manifest", "catalog.csv")
+	part.Write([]byte(manifestCSV))
+	part, _ = mw.CreateFormFile("archive", "catalog.zip")
+	part.Write(archive.Bytes())
+	mw.Close()
+
+	e := echo.New()
+	e.POST("/api/v1/luminaires/import", h.ImportCatalog)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/luminaires/import", &body)
+	req.Header.Set(echo.HeaderContentType, mw.FormDataContentType())
+	resp := httptest.NewRecorder()
+	e.ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", resp.Code, resp.Body.String())
+	}
+
+	var result struct {
+		Results []struct {
+			Line        int    `json:"line"`
+			Status      string `json:"status"`
+			LuminaireID int64  `json:"luminaire_id"`
+			Error       string `json:"error"`
+		} `json:"results"`
+		Summary   map[string]int `json:"summary"`
+		Unmatched []string       `json:"unmatched"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &result)
+	if result.Summary["uploaded"] != 2 || result.Summary["failed"] != 2 {
+		t.Errorf("summary = %v: %s", result.Summary, resp.Body.String())
+	}
+	if len(result.Unmatched) != 1 || result.Unmatched[0] != "legacy/spare.ies" {
+		t.Errorf("unmatched = %v", result.Unmatched)
+	}
+	if r := result.Results[3]; r.Line != 5 || r.Error == "" {
+		t.Errorf("bad number row = %+v", r)
+	}
+
+	lum, err := database.LoadParsedLuminaire(h.db, result.Results[0].LuminaireID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := lum.Metadata
+	if m.Model != "Downlight 200" || m.CatalogNumber != "DL-200-830" || m.InputWatts != 12.5 || m.ColorTemp != 3000 || m.OriginalFilename != "DL200.ies" {
+		t.Errorf("imported metadata = %+v", m)
+	}
+}
+
+// TestImportFile imports files as the import command does: a file read by
+// content, and the same file again as a duplicate.
+func TestImportFile(t *testing.T) {
+	h := newTestHandler(t)
+	lum, err := synth.Generate(synth.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := parser.Encode(parser.NewLDTParser(), lum, parser.WriteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	first := ImportFile(ctx, h.db, "DL200.TXT", "ldt", data)
+	if first.Status != "uploaded" || first.Err != nil {
+		t.Fatalf("import = %+v", first)
+	}
+	stored, err := database.LoadParsedLuminaire(h.db, first.LuminaireID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := stored.Metadata; m.OriginalFilename != "DL200.TXT" || m.ParserOverride != "ldt" || m.FormatType != parser.DetectFormat("x.ldt") {
+		t.Errorf("stored metadata = %+v", m)
+	}
+	if again := ImportFile(ctx, h.db, "copy/DL200.ldt", "", data); again.Status != "duplicate" || again.LuminaireID != first.LuminaireID {
+		t.Errorf("second import = %+v", again)
+	}
+	if bad := ImportFile(ctx, h.db, "notes.ies", "", []byte("not a photometric file")); bad.Status != "failed" || bad.Err == nil {
+		t.Errorf("unreadable file = %+v", bad)
+	}
+}
@@ -0,0 +1,236 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"illuminate/internal/cache"
+	"illuminate/internal/logger"
+	"illuminate/internal/validate"
+)
+
+// jobQueue runs the catalog-wide validation and recompute runs. With a
+// shared Redis a run is queued there and taken by whichever instance is
+// free, and only one run of a kind goes at a time across the deployment;
+// without one it runs in a goroutine of the instance that started it.
+//
+// The lock of a kind holds a token only its owner knows, so a run cannot
+// release or keep another's lock. A running job keeps its lock alive; a job
+// left on the processing list while nobody holds its lock belonged to an
+// instance that died, and is queued again.
+type jobQueue struct {
+	queue cache.Queue // nil runs jobs in-process
+	locks cache.Store
+	// rules are the deployment's validation rules, which revalidation
+	// runs check with.
+	rules validate.RuleFilter
+}
+
+// job is one queued run.
+type job struct {
+	Kind      string  `json:"kind"`
+	RunID     int64   `json:"run_id"`
+	Threshold float64 `json:"threshold,omitempty"`
+	// Owner is the token of the lock of Kind, which the run keeps and
+	// releases.
+	Owner string `json:"owner"`
+}
+
+const (
+	jobRevalidate = "revalidate"
+	jobRecompute  = "recompute"
+
+	jobQueueName = "illuminate:jobs"
+	// jobLockTTL bounds how long a run that died with its instance keeps
+	// the next one of its kind from starting, and is queued again. A live
+	// run renews its lock three times as often.
+	jobLockTTL = time.Minute
+)
+
+// localJobs runs the jobs of handlers built without a queue.
+var localJobs = newJobQueue(nil, nil)
+
+// newJobQueue queues jobs and keeps their locks in shared, or in-process
+// when shared is nil, and revalidates with rules.
+func newJobQueue(shared *cache.Redis, rules validate.RuleFilter) *jobQueue {
+	if shared == nil {
+		return &jobQueue{locks: cache.NewLRU(0), rules: rules}
+	}
+	return &jobQueue{queue: shared, locks: shared, rules: rules}
+}
+
+func jobLockKey(kind string) string {
+	return "lock:job:" + kind
+}
+
+// lock claims kind for one run and returns the owner token that releases
+// it; ok is false while another run of kind holds it.
+func (q *jobQueue) lock(ctx context.Context, kind string) (owner string, ok bool, err error) {
+	owner = rand.Text()
+	ok, err = q.locks.Add(ctx, jobLockKey(kind), []byte(owner), jobLockTTL)
+	return owner, ok, err
+}
+
+// unlock releases the lock of kind if owner still holds it.
+func (q *jobQueue) unlock(kind, owner string) {
+	if _, err := q.locks.DeleteIf(context.Background(), jobLockKey(kind), []byte(owner)); err != nil {
+		logger.Default.Errorf("release %s lock: %v", kind, err)
+	}
+}
+
+// keepLocked renews the lock of j until the returned function is called. A
+// job whose lock lapsed while it waited in the queue takes it again, and
+// reports false when another run has it by then.
+func (q *jobQueue) keepLocked(j job) (stop func(), ok bool) {
+	ctx := context.Background()
+	key, owner := jobLockKey(j.Kind), []byte(j.Owner)
+	held, err := q.locks.ExpireIf(ctx, key, owner, jobLockTTL)
+	if err == nil && !held {
+		held, err = q.locks.Add(ctx, key, owner, jobLockTTL)
+	}
+	if err != nil || !held {
+		return func() {}, false
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(jobLockTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if held, err := q.locks.ExpireIf(ctx, key, owner, jobLockTTL); err != nil || !held {
+					logger.Default.Warnf("%s run %d: lock lost: %v", j.Kind, j.RunID, err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done) }, true
+}
+
+// enqueue hands over j, whose kind the caller has locked as j.Owner. The
+// lock is released when the run ends.
+func (q *jobQueue) enqueue(ctx context.Context, db *sql.DB, j job) error {
+	if q.queue == nil {
+		go func() {
+			q.do(db, j)
+			q.unlock(j.Kind, j.Owner)
+		}()
+		return nil
+	}
+	b, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+	return q.queue.Push(ctx, jobQueueName, b)
+}
+
+// do runs j while keeping its lock, which the caller releases.
+func (q *jobQueue) do(db *sql.DB, j job) {
+	stop, ok := q.keepLocked(j)
+	if !ok {
+		logger.Default.Warnf("%s run %d: skipped, another run holds the lock", j.Kind, j.RunID)
+		return
+	}
+	defer stop()
+	var err error
+	switch j.Kind {
+	case jobRevalidate:
+		err = revalidateCatalog(db, j.RunID, q.rules)
+	case jobRecompute:
+		err = recomputeCatalog(db, j.RunID, j.Threshold)
+	default:
+		err = fmt.Errorf("unknown job kind %q", j.Kind)
+	}
+	if err != nil {
+		logger.Default.Errorf("%s run %d: %v", j.Kind, j.RunID, err)
+	}
+}
+
+// consume takes jobs off the shared queue and runs them one at a time until
+// ctx is done, and every jobLockTTL queues again the jobs of instances that
+// died running them. Without a shared queue it returns at once.
+func (q *jobQueue) consume(ctx context.Context, db *sql.DB) {
+	if q.queue == nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(jobLockTTL)
+		defer ticker.Stop()
+		for {
+			q.requeueOrphans(ctx)
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	for {
+		b, err := q.queue.Pop(ctx, jobQueueName)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logger.Default.Warnf("job queue: %v", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		var j job
+		if err := json.Unmarshal(b, &j); err != nil {
+			logger.Default.Errorf("job queue: %v", err)
+		} else {
+			q.do(db, j)
+		}
+		// The job leaves the processing list before its lock goes, so it
+		// is never taken for one an instance left behind.
+		if _, err := q.queue.Done(context.Background(), jobQueueName, b); err != nil {
+			logger.Default.Errorf("job queue: %v", err)
+		}
+		if j.Owner != "" {
+			q.unlock(j.Kind, j.Owner)
+		}
+	}
+}
+
+// requeueOrphans queues again the jobs on the processing list whose kind
+// nobody holds the lock of: their instance died running them. The lock is
+// taken for the job again first, so only one instance queues it.
+func (q *jobQueue) requeueOrphans(ctx context.Context) {
+	jobs, err := q.queue.Processing(ctx, jobQueueName)
+	if err != nil {
+		logger.Default.Warnf("job queue: %v", err)
+		return
+	}
+	for _, b := range jobs {
+		var j job
+		if json.Unmarshal(b, &j) != nil {
+			continue
+		}
+		owner, ok, err := q.lock(ctx, j.Kind)
+		if err != nil || !ok {
+			continue
+		}
+		if taken, err := q.queue.Done(ctx, jobQueueName, b); err != nil || !taken {
+			q.unlock(j.Kind, owner)
+			continue
+		}
+		j.Owner = owner
+		if err := q.enqueue(ctx, nil, j); err != nil {
+			logger.Default.Errorf("requeue %s run %d: %v", j.Kind, j.RunID, err)
+			q.unlock(j.Kind, owner)
+			continue
+		}
+		logger.Default.Warnf("requeued %s run %d left by a stopped instance", j.Kind, j.RunID)
+	}
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"illuminate/internal/cache"
+	"illuminate/internal/cache/redistest"
+)
+
+// TestSharedJobQueue starts a validation run on one instance and checks
+// that another instance sharing the Redis takes the job, and that no
+// instance starts a second run or releases the lock while it goes.
+func TestSharedJobQueue(t *testing.T) {
+	srv, err := redistest.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	h := newTestHandler(t)
+
+	var queues []*jobQueue
+	for range 2 {
+		r, err := cache.NewRedis(srv.URL())
+		if err != nil {
+			t.Fatal(err)
+		}
+		queues = append(queues, newJobQueue(r, nil))
+	}
+
+	ctx := context.Background()
+	owner, ok, err := queues[0].lock(ctx, jobRevalidate)
+	if err != nil || !ok {
+		t.Fatalf("lock = %v, %v", ok, err)
+	}
+	other, ok, _ := queues[1].lock(ctx, jobRevalidate)
+	if ok {
+		t.Fatal("the other instance took a lock that is held")
+	}
+	queues[1].unlock(jobRevalidate, other)
+	if _, ok, _ := queues[1].lock(ctx, jobRevalidate); ok {
+		t.Fatal("the other instance released a lock it does not own")
+	}
+	id, err := startValidationRun(h.db, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := queues[0].enqueue(ctx, h.db, job{Kind: jobRevalidate, RunID: id, Owner: owner}); err != nil {
+		t.Fatal(err)
+	}
+
+	consumeCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	go queues[1].consume(consumeCtx, h.db)
+
+	waitForRun(t, h, id)
+	// The lock goes right after the run records its end.
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, ok, _ := queues[0].lock(ctx, jobRevalidate); ok {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("the lock was not released after the run")
+}
+
+// TestOrphanedJob takes a job off the queue as an instance that then dies
+// would, and checks that another instance queues it again once the lock
+// has lapsed, and runs it.
+func TestOrphanedJob(t *testing.T) {
+	srv, err := redistest.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	h := newTestHandler(t)
+	r, err := cache.NewRedis(srv.URL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := newJobQueue(r, nil)
+
+	ctx := context.Background()
+	owner, ok, err := q.lock(ctx, jobRevalidate)
+	if err != nil || !ok {
+		t.Fatalf("lock = %v, %v", ok, err)
+	}
+	id, err := startValidationRun(h.db, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.enqueue(ctx, h.db, job{Kind: jobRevalidate, RunID: id, Owner: owner}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Pop(ctx, jobQueueName); err != nil {
+		t.Fatal(err)
+	}
+
+	// While the lock is held the job is someone's.
+	q.requeueOrphans(ctx)
+	if jobs, _ := r.Processing(ctx, jobQueueName); len(jobs) != 1 {
+		t.Fatalf("processing = %q, want the taken job", jobs)
+	}
+	// The lock lapses with its instance.
+	if ok, _ := r.DeleteIf(ctx, jobLockKey(jobRevalidate), []byte(owner)); !ok {
+		t.Fatal("the lock was not the owner's")
+	}
+	q.requeueOrphans(ctx)
+	if jobs, _ := r.Processing(ctx, jobQueueName); len(jobs) != 0 {
+		t.Fatalf("processing = %q, want none", jobs)
+	}
+
+	consumeCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	go q.consume(consumeCtx, h.db)
+	waitForRun(t, h, id)
+}
+
+// waitForRun waits for validation run id to record its end.
+func waitForRun(t *testing.T, h *LuminaireHandler, id int64) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var finished bool
+		if err := h.db.QueryRow(`SELECT finished_at IS NOT NULL FROM validation_runs WHERE id = ?`, id).Scan(&finished); err != nil {
+			t.Fatal(err)
+		}
+		if finished {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the queued run never finished")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,180 @@
+package server
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+	"illuminate/internal/label"
+	"illuminate/internal/qr"
+)
+
+// errNoPublicURL answers QR code and label requests while PUBLIC_URL is
+// unset: the host of the request is the client's to choose, so codes are
+// never made to point at it.
+var errNoPublicURL = errors.New("QR codes need PUBLIC_URL to be set")
+
+// luminaireLink is what QR codes point at: the luminaire page under
+// PUBLIC_URL. A "link" query parameter, such as a share link, replaces it
+// when it is that page of this catalog, with any query it carries; a code
+// printed on a tag cannot be made to point anywhere else.
+func luminaireLink(c echo.Context, id int64) (string, error) {
+	base := strings.TrimSuffix(os.Getenv("PUBLIC_URL"), "/")
+	if base == "" {
+		return "", errNoPublicURL
+	}
+	page := fmt.Sprintf("%s/luminaires/%d", base, id)
+	link := c.QueryParam("link")
+	if link == "" {
+		return page, nil
+	}
+	u, err := url.Parse(link)
+	want, _ := url.Parse(page)
+	if err != nil || want == nil || u.User != nil || !strings.EqualFold(u.Scheme, want.Scheme) ||
+		!strings.EqualFold(u.Host, want.Host) || strings.TrimSuffix(u.Path, "/") != want.Path {
+		return "", fmt.Errorf("link must be the luminaire's page under %s", base)
+	}
+	return link, nil
+}
+
+// linkErrorStatus is the status a luminaireLink error is answered with.
+func linkErrorStatus(err error) int {
+	if errors.Is(err, errNoPublicURL) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadRequest
+}
+
+// QRCode returns a PNG QR code linking to the luminaire:
+// GET /api/v1/luminaires/:id/qr?scale=8.
+func (h *LuminaireHandler) QRCode(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_id")
+	}
+	scale := 8
+	if s := c.QueryParam("scale"); s != "" {
+		if scale, err = strconv.Atoi(s); err != nil || scale < 1 || scale > 32 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "scale must be between 1 and 32"})
+		}
+	}
+	state, err := h.recordState(id)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && h.hiddenFrom(c, state)) {
+		return apiError(c, http.StatusNotFound, "luminaire_not_found")
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	link, err := luminaireLink(c, id)
+	if err != nil {
+		return c.JSON(linkErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+	code, err := qr.Encode([]byte(link))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	var buf bytes.Buffer
+	if err := code.WritePNG(&buf, scale); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.Blob(http.StatusOK, "image/png", buf.Bytes())
+}
+
+// Label returns a printable tag for a sample fixture with a QR code and the
+// key metrics: GET /api/v1/luminaires/:id/label?format=png|pdf.
+func (h *LuminaireHandler) Label(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_id")
+	}
+	format := strings.ToLower(c.QueryParam("format"))
+	if format == "" {
+		format = "png"
+	}
+	if format != "png" && format != "pdf" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "format must be png or pdf"})
+	}
+
+	lum, err := database.LoadParsedLuminaire(h.db, id)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && h.hiddenFrom(c, lum.Metadata.State)) {
+		return apiError(c, http.StatusNotFound, "luminaire_not_found")
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	m, err := loadMetrics(h.db, id)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	link, err := luminaireLink(c, id)
+	if err != nil {
+		return c.JSON(linkErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+
+	meta := lum.Metadata
+	l := label.Label{
+		Title: strings.TrimSpace(meta.Manufacturer + " " + meta.Model),
+		Link:  link,
+	}
+	if meta.CatalogNumber != "" {
+		l.Lines = append(l.Lines, "Cat. "+meta.CatalogNumber)
+	}
+	output := fmt.Sprintf("%.0f lm", m.Flux)
+	if meta.InputWatts > 0 {
+		output += fmt.Sprintf(", %g W", meta.InputWatts)
+	}
+	if m.Efficacy != nil {
+		output += fmt.Sprintf(", %.0f lm/W", *m.Efficacy)
+	}
+	l.Lines = append(l.Lines, output)
+	var details []string
+	if meta.ColorTemp > 0 {
+		details = append(details, fmt.Sprintf("%dK", meta.ColorTemp))
+	}
+	if meta.CRI > 0 {
+		details = append(details, fmt.Sprintf("CRI %d", meta.CRI))
+	}
+	if m.BeamAngle > 0 {
+		details = append(details, fmt.Sprintf("beam %.0f deg", m.BeamAngle))
+	}
+	if len(details) > 0 {
+		l.Lines = append(l.Lines, strings.Join(details, ", "))
+	}
+	var maintenance []string
+	if llf := meta.LightLossFactor(); llf > 0 {
+		maintenance = append(maintenance, fmt.Sprintf("LLF %.2f", llf))
+	}
+	if meta.RatedLife > 0 {
+		maintenance = append(maintenance, fmt.Sprintf("rated life %d h", meta.RatedLife))
+	}
+	if len(maintenance) > 0 {
+		l.Lines = append(l.Lines, strings.Join(maintenance, ", "))
+	}
+	l.Lines = append(l.Lines, fmt.Sprintf("ID %d", id))
+
+	var buf bytes.Buffer
+	contentType := "image/png"
+	if format == "pdf" {
+		contentType = "application/pdf"
+		err = l.WritePDF(&buf)
+	} else {
+		err = l.WritePNG(&buf)
+	}
+	if errors.Is(err, qr.ErrTooLong) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`inline; filename="label-%d.%s"`, id, format))
+	return c.Blob(http.StatusOK, contentType, buf.Bytes())
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+)
+
+func TestQRCodeAndLabel(t *testing.T) {
+	t.Setenv("PUBLIC_URL", "https://catalog.example.com/")
+	h := newTestHandler(t)
+	id := saveSynth(t, h, "label", func(lum *database.ParsedLuminaire) {
+		lum.Metadata.Model = "DL-200"
+		lum.Metadata.CatalogNumber = "DL-200-830"
+		lum.Metadata.LampLumenDepreciation, lum.Metadata.DriverMaintenanceFactor = 0.9, 0.9
+		lum.Metadata.RatedLife = 50000
+	})
+
+	e := echo.New()
+	e.GET("/api/v1/luminaires/:id/qr", h.QRCode)
+	e.GET("/api/v1/luminaires/:id/label", h.Label)
+	get := func(target string) *httptest.ResponseRecorder {
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, target, nil))
+		return resp
+	}
+
+	resp := get(fmt.Sprintf("/api/v1/luminaires/%d/qr?scale=2", id))
+	if resp.Code != http.StatusOK || resp.Header().Get(echo.HeaderContentType) != "image/png" {
+		t.Fatalf("qr: %d %s", resp.Code, resp.Body.String())
+	}
+	if _, err := png.Decode(bytes.NewReader(resp.Body.Bytes())); err != nil {
+		t.Errorf("qr: %v", err)
+	}
+
+	resp = get(fmt.Sprintf("/api/v1/luminaires/%d/label", id))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("png label: %d %s", resp.Code, resp.Body.String())
+	}
+	if _, err := png.Decode(bytes.NewReader(resp.Body.Bytes())); err != nil {
+		t.Errorf("png label: %v", err)
+	}
+
+	resp = get(fmt.Sprintf("/api/v1/luminaires/%d/label?format=pdf&link=https://catalog.example.com/luminaires/%[1]d%%3Fshare%%3Dabc", id))
+	if resp.Code != http.StatusOK || !strings.HasPrefix(resp.Body.String(), "%PDF-") {
+		t.Fatalf("pdf label: %d", resp.Code)
+	}
+	for _, want := range []string{"(Illuminate DL-200) Tj", "(Cat. DL-200-830) Tj", " lm, 10 W, 100 lm/W) Tj", "(LLF 0.81, rated life 50000 h) Tj"} {
+		if !strings.Contains(resp.Body.String(), want) {
+			t.Errorf("pdf label lacks %q", want)
+		}
+	}
+
+	for target, want := range map[string]int{
+		"/api/v1/luminaires/999/qr":                                                                            http.StatusNotFound,
+		"/api/v1/luminaires/999/label":                                                                         http.StatusNotFound,
+		fmt.Sprintf("/api/v1/luminaires/%d/qr?scale=100", id):                                                  http.StatusBadRequest,
+		fmt.Sprintf("/api/v1/luminaires/%d/label?format=svg", id):                                              http.StatusBadRequest,
+		fmt.Sprintf("/api/v1/luminaires/%d/label?link=javascript:x()", id):                                     http.StatusBadRequest,
+		fmt.Sprintf("/api/v1/luminaires/%d/qr?link=https://evil.example.com/luminaires/%[1]d", id):             http.StatusBadRequest,
+		fmt.Sprintf("/api/v1/luminaires/%d/qr?link=https://catalog.example.com.evil.com/luminaires/%[1]d", id): http.StatusBadRequest,
+		fmt.Sprintf("/api/v1/luminaires/%d/qr?link=https://catalog.example.com/luminaires/%d", id, id+1):       http.StatusBadRequest,
+		fmt.Sprintf("/api/v1/luminaires/%d/qr?link=https://user@catalog.example.com/luminaires/%[1]d", id):     http.StatusBadRequest,
+	} {
+		if resp := get(target); resp.Code != want {
+			t.Errorf("%s: status %d, want %d", target, resp.Code, want)
+		}
+	}
+
+	// Without PUBLIC_URL the request's own host would end up in the code.
+	t.Setenv("PUBLIC_URL", "")
+	if resp := get(fmt.Sprintf("/api/v1/luminaires/%d/qr", id)); resp.Code != http.StatusServiceUnavailable {
+		t.Errorf("qr without PUBLIC_URL: status %d, want 503", resp.Code)
+	}
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"errors"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/i18n"
+	"illuminate/internal/validate"
+)
+
+// language picks the language of the response from the Accept-Language
+// header and announces it in Content-Language.
+func language(c echo.Context) string {
+	lang := i18n.Negotiate(c.Request().Header.Get("Accept-Language"))
+	header := c.Response().Header()
+	header.Add(echo.HeaderVary, "Accept-Language")
+	header.Set("Content-Language", lang)
+	return lang
+}
+
+// apiError responds with the message of code in the caller's language, next
+// to the code itself, which is the same in every language.
+func apiError(c echo.Context, status int, code string) error {
+	return apiErrorWith(c, status, code, nil)
+}
+
+// apiErrorWith is apiError for a message with {name} placeholders.
+func apiErrorWith(c echo.Context, status int, code string, params map[string]string) error {
+	return c.JSON(status, errorBody(language(c), code, params))
+}
+
+// errorBody is the body apiError sends, for responses that carry more
+// than the error.
+func errorBody(lang, code string, params map[string]string) map[string]interface{} {
+	msg, ok := i18n.Message(lang, code, params)
+	if !ok {
+		msg = code
+	}
+	return map[string]interface{}{"error": msg, "code": code}
+}
+
+// codedError is a client error with a catalog code, for helpers that
+// return errors rather than answer the request themselves.
+type codedError struct {
+	code   string
+	params map[string]string
+}
+
+func (e *codedError) Error() string {
+	msg, _ := i18n.Message(i18n.Default, e.code, e.params)
+	return msg
+}
+
+// errorBodyFor is errorBody for err when it is a codedError; any other
+// error is sent as it reads.
+func errorBodyFor(lang string, err error) map[string]interface{} {
+	var coded *codedError
+	if errors.As(err, &coded) {
+		return errorBody(lang, coded.code, coded.params)
+	}
+	return map[string]interface{}{"error": err.Error()}
+}
+
+// errorResponse answers err with status, in the caller's language when it
+// is a codedError.
+func errorResponse(c echo.Context, status int, err error) error {
+	return c.JSON(status, errorBodyFor(language(c), err))
+}
+
+// localizeIssues returns issues with their messages in lang. Issues stored
+// before they had codes keep their English message.
+func localizeIssues(lang string, issues []validate.Issue) []validate.Issue {
+	if lang == i18n.Default {
+		return issues
+	}
+	localized := make([]validate.Issue, len(issues))
+	for i, issue := range issues {
+		if msg, ok := i18n.Message(lang, issue.Code, issue.Params); ok {
+			issue.Message = msg
+		}
+		localized[i] = issue
+	}
+	return localized
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+	"illuminate/internal/validate"
+)
+
+// TestLocalizedMessages checks that errors and validation issues come back
+// in the language asked for, while their codes stay the same.
+func TestLocalizedMessages(t *testing.T) {
+	h := newTestHandler(t)
+	id := saveSynth(t, h, "localized", func(lum *database.ParsedLuminaire) {
+		lum.Metadata.CatalogNumber = ""
+	})
+
+	e := echo.New()
+	e.GET("/api/v1/luminaires/:id/validation", h.Validation)
+	get := func(path, lang string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Language", lang)
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, req)
+		return resp
+	}
+
+	for lang, want := range map[string]string{"": "luminaire not found", "de-DE": "Leuchte nicht gefunden", "fr": "luminaire introuvable"} {
+		resp := get("/api/v1/luminaires/999/validation", lang)
+		var body map[string]string
+		json.Unmarshal(resp.Body.Bytes(), &body)
+		if resp.Code != http.StatusNotFound || body["code"] != "luminaire_not_found" || body["error"] != want {
+			t.Errorf("Accept-Language %q: %d %v", lang, resp.Code, body)
+		}
+	}
+
+	issue := func(lang string) validate.Issue {
+		resp := get(fmt.Sprintf("/api/v1/luminaires/%d/validation", id), lang)
+		var body struct {
+			Validation validate.Result `json:"validation"`
+		}
+		json.Unmarshal(resp.Body.Bytes(), &body)
+		for _, issue := range body.Validation.Issues {
+			if issue.Code == validate.CodeCatalogNumberMissing {
+				return issue
+			}
+		}
+		t.Fatalf("Accept-Language %q: no %s issue in %s", lang, validate.CodeCatalogNumberMissing, resp.Body)
+		return validate.Issue{}
+	}
+	if got := issue("en").Message; got != "catalog number is missing" {
+		t.Errorf("en: %q", got)
+	}
+	if got := issue("fr, en;q=0.5").Message; got != "référence catalogue manquante" {
+		t.Errorf("fr: %q", got)
+	}
+	if got := get(fmt.Sprintf("/api/v1/luminaires/%d/validation", id), "de").Header().Get("Content-Language"); got != "de" {
+		t.Errorf("Content-Language = %q", got)
+	}
+}
+
+// TestLocalizedHandlerErrors checks that request errors of the variant
+// handlers come back in the language asked for, placeholders filled in.
+func TestLocalizedHandlerErrors(t *testing.T) {
+	h := newTestHandler(t)
+	id := saveSynth(t, h, "localized-errors")
+
+	e := echo.New()
+	e.PUT("/api/v1/luminaires/:id/conditions/:name", h.PutCondition)
+	e.DELETE("/api/v1/luminaires/:id/components/:name", h.DeleteComponent)
+	send := func(method, path, form, lang string) (int, map[string]string) {
+		req := httptest.NewRequest(method, path, strings.NewReader(form))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+		req.Header.Set("Accept-Language", lang)
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, req)
+		var body map[string]string
+		json.Unmarshal(resp.Body.Bytes(), &body)
+		return resp.Code, body
+	}
+
+	for _, tc := range []struct {
+		method, path, form, lang string
+		status                   int
+		code, want               string
+	}{
+		{http.MethodDelete, fmt.Sprintf("/api/v1/luminaires/%d/components/up", id), "", "de", http.StatusNotFound,
+			"component_not_found", "Komponente nicht gefunden"},
+		{http.MethodPut, fmt.Sprintf("/api/v1/luminaires/%d/conditions/hot", id), "ambient_temp=500", "fr", http.StatusBadRequest,
+			"value_out_of_range", "ambient_temp doit être un nombre de -100 à 200"},
+		{http.MethodPut, fmt.Sprintf("/api/v1/luminaires/%d/conditions/hot", id), "ambient_temp=500", "en", http.StatusBadRequest,
+			"value_out_of_range", "ambient_temp must be a number from -100 to 200"},
+	} {
+		status, body := send(tc.method, tc.path, tc.form, tc.lang)
+		if status != tc.status || body["code"] != tc.code || body["error"] != tc.want {
+			t.Errorf("%s %s (%s): %d %v", tc.method, tc.path, tc.lang, status, body)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+	"illuminate/internal/parser"
+)
+
+// luminaireLicense is the license or usage terms every export of a luminaire
+// carries.
+type luminaireLicense struct {
+	// Name is a short identifier such as "CC-BY-4.0" or "Acme EULA 2024".
+	Name string `json:"name"`
+	Text string `json:"text"`
+	URL  string `json:"url,omitempty"`
+	// RequireAcceptance refuses exports without accept_license=true.
+	RequireAcceptance bool `json:"require_acceptance"`
+}
+
+// GetLicense returns the license of a luminaire.
+func (h *LuminaireHandler) GetLicense(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_id")
+	}
+	license, err := h.loadLicense(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apiError(c, http.StatusNotFound, "license_not_found")
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, license)
+}
+
+// PutLicense attaches license terms to a luminaire from a JSON body such as
+// {"name": "CC-BY-4.0", "text": "...", "url": "https://...", "require_acceptance": true}.
+func (h *LuminaireHandler) PutLicense(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_id")
+	}
+
+	var license luminaireLicense
+	if err := json.NewDecoder(c.Request().Body).Decode(&license); err != nil {
+		return apiErrorWith(c, http.StatusBadRequest, "invalid_license", map[string]string{"detail": err.Error()})
+	}
+	license.Name = strings.TrimSpace(license.Name)
+	license.Text = strings.TrimSpace(license.Text)
+	if license.Name == "" && license.Text == "" {
+		return apiError(c, http.StatusBadRequest, "license_incomplete")
+	}
+	if strings.ContainsAny(license.Name, "\r\n") {
+		return apiError(c, http.StatusBadRequest, "license_name_multiline")
+	}
+	if license.URL != "" {
+		if u, err := url.Parse(license.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return apiError(c, http.StatusBadRequest, "license_url_invalid")
+		}
+	}
+
+	var exists int
+	if err := h.db.QueryRow(`SELECT COUNT(*) FROM luminaires WHERE id = ? AND `+database.NotDeleted, id).Scan(&exists); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if exists == 0 {
+		return apiError(c, http.StatusNotFound, "luminaire_not_found")
+	}
+
+	_, err = h.db.Exec(`
+		INSERT OR REPLACE INTO luminaire_licenses (luminaire_id, name, text, url, require_acceptance, updated_at)
+		VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`,
+		id, license.Name, license.Text, license.URL, license.RequireAcceptance,
+	)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"status": "saved"})
+}
+
+// DeleteLicense removes the license of a luminaire.
+func (h *LuminaireHandler) DeleteLicense(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_id")
+	}
+	if _, err := h.db.Exec(`DELETE FROM luminaire_licenses WHERE luminaire_id = ?`, id); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+func (h *LuminaireHandler) loadLicense(id int64) (*luminaireLicense, error) {
+	var license luminaireLicense
+	err := h.db.QueryRow(`SELECT name, text, url, require_acceptance FROM luminaire_licenses WHERE luminaire_id = ?`, id).
+		Scan(&license.Name, &license.Text, &license.URL, &license.RequireAcceptance)
+	if err != nil {
+		return nil, err
+	}
+	return &license, nil
+}
+
+// errLicenseNotAccepted refuses an export whose license has to be accepted
+// first.
+var errLicenseNotAccepted = errors.New("the license of this luminaire must be accepted: add accept_license=true")
+
+// exportLicense loads the license an export of luminaire id carries, or nil
+// when none is stored. With the license it returns errLicenseNotAccepted when
+// the license, or REQUIRE_LICENSE_ACCEPTANCE, asks for acceptance and the
+// request lacks accept_license=true.
+func (h *LuminaireHandler) exportLicense(c echo.Context, id int64) (*luminaireLicense, error) {
+	license, err := h.loadLicense(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if license.RequireAcceptance || h.requireLicenseAcceptance {
+		if accepted, _ := strconv.ParseBool(c.QueryParam("accept_license")); !accepted {
+			return license, errLicenseNotAccepted
+		}
+	}
+	return license, nil
+}
+
+// licenseRequiredResponse answers an export refused for want of acceptance
+// with the terms to accept.
+func licenseRequiredResponse(c echo.Context, license *luminaireLicense) error {
+	body := errorBody(language(c), "license_not_accepted", nil)
+	body["license"] = license
+	return c.JSON(http.StatusForbidden, body)
+}
+
+// embedLicense writes the license into the header of an IES export as the
+// [_LICENSE] keyword, continued on [MORE] lines. EULUMDAT and CIE headers have
+// no free text, so for them the license is reported lost. It returns the
+// options to render with; opts itself is not modified.
+func embedLicense(license *luminaireLicense, format string, opts parser.WriteOptions) (parser.WriteOptions, []parser.CompatibilityIssue) {
+	if license == nil {
+		return opts, nil
+	}
+	if !strings.EqualFold(format, "ies") {
+		return opts, []parser.CompatibilityIssue{{
+			Field:  "license",
+			Effect: "lost",
+			Code:   parser.CodeUnsupportedField,
+			Detail: "the format has no free-text header field for it",
+		}}
+	}
+	text := strings.Join(strings.Fields(strings.Join([]string{license.Name, license.Text, license.URL}, " ")), " ")
+	opts.Keywords = append(opts.Keywords[:len(opts.Keywords):len(opts.Keywords)], parser.Keyword{Key: "_LICENSE", Value: text})
+	return opts, nil
+}
+
+// setLicenseLink points at the license terms with a Link header.
+func setLicenseLink(c echo.Context, license *luminaireLicense) {
+	if license != nil && license.URL != "" {
+		c.Response().Header().Add("Link", fmt.Sprintf(`<%s>; rel="license"`, license.URL))
+	}
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+)
+
+func TestExportLicense(t *testing.T) {
+	h := newTestHandler(t)
+	id := saveSynth(t, h, "license")
+
+	e := echo.New()
+	e.PUT("/api/v1/luminaires/:id/license", h.PutLicense)
+	e.GET("/api/v1/luminaires/:id/license", h.GetLicense)
+	e.GET("/api/v1/luminaires/:id/export", h.Export)
+	e.GET("/api/v1/luminaires/:id/download/:app", h.Download)
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return resp
+	}
+	base := fmt.Sprintf("/api/v1/luminaires/%d", id)
+
+	if resp := do(http.MethodGet, base+"/license", ""); resp.Code != http.StatusNotFound {
+		t.Errorf("license before put: status %d", resp.Code)
+	}
+	if resp := do(http.MethodPut, base+"/license", `{"name": "x", "url": "file:///etc"}`); resp.Code != http.StatusBadRequest {
+		t.Errorf("bad url: status %d", resp.Code)
+	}
+	body := `{"name": "Acme Data License", "text": "Use only for\nAcme projects.", "url": "https://acme.example.com/terms", "require_acceptance": true}`
+	if resp := do(http.MethodPut, base+"/license", body); resp.Code != http.StatusOK {
+		t.Fatalf("put license: %d %s", resp.Code, resp.Body.String())
+	}
+
+	resp := do(http.MethodGet, base+"/export?format=ies", "")
+	if resp.Code != http.StatusForbidden || !strings.Contains(resp.Body.String(), "Acme Data License") {
+		t.Fatalf("export without acceptance: %d %s", resp.Code, resp.Body.String())
+	}
+	resp = do(http.MethodGet, base+"/export?format=ies&accept_license=true", "")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("accepted export: %d %s", resp.Code, resp.Body.String())
+	}
+	if want := "[_LICENSE] Acme Data License Use only for Acme projects. https://acme.example.com/terms\n"; !strings.Contains(resp.Body.String(), want) {
+		t.Errorf("ies export lacks %q:\n%s", want, resp.Body.String())
+	}
+	if link := resp.Header().Get("Link"); link != `<https://acme.example.com/terms>; rel="license"` {
+		t.Errorf("Link = %q", link)
+	}
+
+	resp = do(http.MethodGet, base+"/download/dialux?accept_license=1", "")
+	if resp.Code != http.StatusOK || !strings.Contains(resp.Header().Get("X-Export-Issues"), "license: lost") {
+		t.Errorf("ldt download: %d, issues %q", resp.Code, resp.Header().Get("X-Export-Issues"))
+	}
+
+	// Gating on the workflow state comes before the license.
+	h.exportState = database.StatePublished
+	if resp := do(http.MethodGet, base+"/export?format=ies&accept_license=true", ""); resp.Code != http.StatusForbidden || !strings.Contains(resp.Body.String(), "exports need published") {
+		t.Errorf("draft export: %d %s", resp.Code, resp.Body.String())
+	}
+}
@@ -1,71 +1,236 @@
 package server
 
 import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
 	"database/sql"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
+	"math"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
+	"illuminate/internal/bundle"
+	"illuminate/internal/cache"
 	"illuminate/internal/database"
+	"illuminate/internal/features"
+	"illuminate/internal/i18n"
 	"illuminate/internal/logger"
 	"illuminate/internal/parser"
+	"illuminate/internal/photometry"
+	"illuminate/internal/validate"
+	"illuminate/internal/worker"
 )
 
+// uploadLog traces uploads; LOG_LEVEL_UPLOAD=debug shows every step.
+var uploadLog = logger.For("upload")
+
 type LuminaireHandler struct {
-	db *sql.DB
+	db    *sql.DB
+	pool  *worker.Pool
+	cache *cache.ParseCache
+
+	// reader serves browsing (List, Get, Stream and collections) and may
+	// lag behind db; nil reads from db.
+	reader *sql.DB
+
+	// batchConcurrency caps how many files of one batch run at once; zero
+	// allows the whole pool.
+	batchConcurrency int
+
+	// workflowTokens map bearer tokens to workflow roles; empty leaves the
+	// workflow open. exportState is the least workflow state a record needs
+	// to be exported; empty allows every record.
+	workflowTokens map[string]database.Role
+	exportState    database.WorkflowState
+
+	// requireLicenseAcceptance makes every licensed record need
+	// accept_license=true on export, not only those whose license asks.
+	requireLicenseAcceptance bool
+
+	// signingKey signs the checksum manifest of ZIP exports; nil leaves
+	// them unsigned.
+	signingKey ed25519.PrivateKey
+
+	// events broadcasts catalog changes to Events subscribers; nil drops
+	// them.
+	events *eventHub
+
+	// jobs runs validation and recompute runs; nil runs them in-process.
+	jobs *jobQueue
+
+	// flags gate experimental readers and the normalization pass; nil
+	// leaves every flag at its default.
+	flags *features.Flags
+
+	// fetcher downloads the files of FetchURLs; nil fetches nothing.
+	fetcher *urlFetcher
+}
+
+func NewLuminaireHandler(db database.Service, pool *worker.Pool, parseCache *cache.ParseCache, jobs *jobQueue, events *eventHub, flags *features.Flags) *LuminaireHandler {
+	batchConcurrency, _ := strconv.Atoi(os.Getenv("BATCH_CONCURRENCY"))
+	if events == nil {
+		events = newEventHub(nil)
+	}
+	h := &LuminaireHandler{
+		db:               db.GetDB(),
+		reader:           db.ReadDB(),
+		pool:             pool,
+		cache:            parseCache,
+		batchConcurrency: batchConcurrency,
+		workflowTokens:   workflowTokensFromEnv(),
+		exportState:      exportStateFromEnv(),
+
+		requireLicenseAcceptance: os.Getenv("REQUIRE_LICENSE_ACCEPTANCE") == "true",
+		signingKey:               signingKeyFromEnv(),
+		events:                   events,
+		jobs:                     jobs,
+		flags:                    flags,
+	}
+	h.fetcher = urlFetcherFromEnv(h.jobQueue().locks)
+	return h
 }
 
-func NewLuminaireHandler(db database.Service) *LuminaireHandler {
-	return &LuminaireHandler{db: db.GetDB()}
+// jobQueue is where catalog runs go.
+func (h *LuminaireHandler) jobQueue() *jobQueue {
+	if h.jobs != nil {
+		return h.jobs
+	}
+	return localJobs
+}
+
+// readDB is the pool browsing reads from.
+func (h *LuminaireHandler) readDB() *sql.DB {
+	if h.reader != nil {
+		return h.reader
+	}
+	return h.db
 }
 
 func (h *LuminaireHandler) Upload(c echo.Context) error {
 	file, err := c.FormFile("file")
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "file is required"})
+		return apiError(c, http.StatusBadRequest, "file_required")
+	}
+	req, status, err := h.conversionRequest(c, "", parser.WriteOptions{})
+	if err != nil {
+		return c.JSON(status, map[string]string{"error": err.Error()})
 	}
 
-	logger.Default.Infof("=== UPLOAD START: filename=%s ===", file.Filename)
+	status, body := h.processUpload(c.Request().Context(), language(c), req, file)
+	return c.JSON(status, body)
+}
 
-	src, err := file.Open()
+// UploadBatch ingests every file in the "files" form field on the shared
+// worker pool and reports a result per file.
+func (h *LuminaireHandler) UploadBatch(c echo.Context) error {
+	form, err := c.MultipartForm()
+	if err != nil || len(form.File["files"]) == 0 {
+		return apiError(c, http.StatusBadRequest, "files_required")
+	}
+	files := form.File["files"]
+	req, status, err := h.conversionRequest(c, "", parser.WriteOptions{})
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to open file"})
+		return c.JSON(status, map[string]string{"error": err.Error()})
 	}
-	defer src.Close()
 
-	tmpDir := os.TempDir()
-	tmpPath := filepath.Join(tmpDir, "tmp_"+file.Filename)
-	dst, err := os.Create(tmpPath)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to create temp file"})
+	uploadLog.Debug("batch upload start", "files", len(files))
+
+	ctx := c.Request().Context()
+	lang := language(c)
+	results := make([]map[string]interface{}, len(files))
+	group := h.pool.Group(h.batchConcurrency)
+	for i, file := range files {
+		err := group.Go(ctx, func() {
+			_, body := h.processUpload(ctx, lang, req, file)
+			results[i] = body
+		})
+		if err != nil {
+			results[i] = map[string]interface{}{"error": err.Error()}
+		}
 	}
-	defer dst.Close()
+	group.Wait()
 
-	if _, err := io.Copy(dst, src); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to save file"})
+	counts := map[string]int{}
+	for i, r := range results {
+		r["filename"] = files[i].Filename
+		if _, failed := r["error"]; failed && r["status"] == nil {
+			r["status"] = "failed"
+		}
+		counts[r["status"].(string)]++
 	}
 
-	p, err := parser.GetParser(file.Filename)
+	uploadLog.Info("batch upload complete", "files", len(files), "summary", counts)
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"results": results,
+		"summary": counts,
+	})
+}
+
+// processUpload parses one uploaded file with the reader req selects and
+// stores it, or parks it in pending_uploads when manufacturer or model still
+// have to be supplied. The file is read in memory, so nothing is left on the
+// instance that took it. With req.salvage a file that fails part way is
+// answered with what could be read, see addSalvaged. Errors are in lang.
+func (h *LuminaireHandler) processUpload(ctx context.Context, lang string, req *conversionRequest, file *multipart.FileHeader) (int, map[string]interface{}) {
+	uploadLog.Debug("upload start", "filename", file.Filename, "size", file.Size)
+
+	src, err := file.Open()
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return http.StatusInternalServerError, errorBody(lang, "file_unreadable", nil)
+	}
+	defer src.Close()
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return http.StatusInternalServerError, errorBody(lang, "file_read_failed", nil)
 	}
 
-	logger.Default.Infof("parsing file: %s", tmpPath)
-	lum, err := p.Parse(tmpPath)
+	// When the name says no format, or the content does not parse as the
+	// one it says, the formats the content could be are listed so the
+	// client can retry with one as source_format.
+	sourceName := req.sourceName(file.Filename)
+	p, err := req.reader(file.Filename)
 	if err != nil {
-		logger.Default.Errorf("parse failed: filename=%s, error=%v", file.Filename, err)
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("parse error: %v", err)})
+		return http.StatusBadRequest, formatErrorBody(lang, errorBodyFor(lang, err), sniff(data))
 	}
 
-	logger.Default.Infof("parsed: manufacturer=%s, model=%s, format=%s", lum.Metadata.Manufacturer, lum.Metadata.Model, lum.Metadata.FormatType)
+	uploadLog.Debug("parsing", "filename", file.Filename)
+	lum, err := h.cache.Parse(ctx, p, data, file.Filename)
+	if err != nil {
+		alternatives := sniff(data)
+		uploadLog.Warn("parse failed", "filename", file.Filename, "err", err)
+		tried := strings.TrimPrefix(strings.ToLower(filepath.Ext(sourceName)), ".")
+		alternatives = slices.DeleteFunc(alternatives, func(g parser.FormatGuess) bool { return g.Format == tried })
+		body := formatErrorBody(lang, errorBody(lang, "parse_error", map[string]string{"detail": err.Error()}), alternatives)
+		if inc := parser.Salvage(err); req.salvage && inc != nil {
+			uploadLog.Info("salvaged", "filename", file.Filename, "planes_read", len(inc.Partial.CandelaMatrix), "planes_expected", inc.Planes)
+			inc.Partial.Metadata.OriginalFilename = file.Filename
+			inc.Partial.Metadata.FormatType = parser.DetectFormat(sourceName)
+			addSalvaged(body, inc)
+			return http.StatusUnprocessableEntity, body
+		}
+		return parseErrorStatus(err), body
+	}
 
 	lum.Metadata.OriginalFilename = file.Filename
-	lum.Metadata.FormatType = parser.DetectFormat(file.Filename)
+	lum.Metadata.FormatType = parser.DetectFormat(sourceName)
+	lum.Metadata.ParserOverride = req.sourceFormat
+	parsed := lum.Metadata
+	h.applyImportProfile(req.organization, &lum.Metadata)
+
+	if status, body, done := h.storedUpload(lang, lum.Metadata.FileHash, req.trashed); done {
+		return status, body
+	}
 
 	missingFields := []string{}
 	if lum.Metadata.Manufacturer == "" {
@@ -76,30 +241,93 @@ func (h *LuminaireHandler) Upload(c echo.Context) error {
 	}
 
 	if len(missingFields) > 0 {
-		newTmpPath := filepath.Join(tmpDir, lum.Metadata.FileHash+"_"+file.Filename)
-		os.Rename(tmpPath, newTmpPath)
-		logger.Default.Infof("METADATA REQUIRED: filename=%s, hash=%s, missing=%v", file.Filename, lum.Metadata.FileHash, missingFields)
-		logger.Default.Infof("temp file saved as: %s", newTmpPath)
-		return c.JSON(http.StatusOK, map[string]interface{}{
+		if err := h.parkUpload(lum.Metadata.FileHash, file.Filename, data); err != nil {
+			return http.StatusInternalServerError, map[string]interface{}{"error": err.Error()}
+		}
+		uploadLog.Info("metadata required", "filename", file.Filename, "file_hash", lum.Metadata.FileHash, "missing", missingFields)
+		return http.StatusOK, map[string]interface{}{
 			"status":    "metadata_required",
 			"missing":   missingFields,
 			"luminaire": lum.Metadata,
 			"file_hash": lum.Metadata.FileHash,
-		})
+		}
 	}
 
-	os.Remove(tmpPath)
-	logger.Default.Infof("saving directly: manufacturer=%s, model=%s", lum.Metadata.Manufacturer, lum.Metadata.Model)
 	lumID, err := h.saveLuminaire(lum)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return http.StatusInternalServerError, map[string]interface{}{"error": err.Error()}
 	}
+	h.saveSource(lumID, file.Filename, sourceName, data)
+	saveFieldSources(h.db, lumID, fieldSources(parsed, lum.Metadata, lum.Metadata))
 
-	logger.Default.Infof("=== UPLOAD COMPLETE: filename=%s, luminaire_id=%d ===", file.Filename, lumID)
-	return c.JSON(http.StatusOK, map[string]interface{}{
+	uploadLog.Info("uploaded", "filename", file.Filename, "luminaire_id", lumID)
+	body := map[string]interface{}{
 		"status":       "uploaded",
 		"luminaire_id": lumID,
-	})
+	}
+	// The stored validation has the same issues unless the organization
+	// switched rules off that the deployment runs.
+	if issues := validate.CheckConsistency(lum, h.rules(req.organization)); len(issues) > 0 {
+		body["inconsistencies"] = issues
+	}
+	return http.StatusOK, body
+}
+
+// duplicateBody answers an upload of a file already on record, with the
+// live luminaire that holds it.
+func duplicateBody(lang string, id int64) map[string]interface{} {
+	body := errorBody(lang, "duplicate_upload", nil)
+	body["status"] = "duplicate"
+	body["luminaire_id"] = id
+	return body
+}
+
+// pendingUploadTTL is how long a parked upload waits for its metadata.
+const pendingUploadTTL = 24 * time.Hour
+
+// parkUpload keeps an upload that lacks metadata until UploadWithMetadata
+// supplies it, dropping uploads parked longer than pendingUploadTTL ago.
+func (h *LuminaireHandler) parkUpload(fileHash, filename string, data []byte) error {
+	cutoff := time.Now().Add(-pendingUploadTTL).UTC().Format("2006-01-02 15:04:05")
+	if _, err := h.db.Exec(`DELETE FROM pending_uploads WHERE created_at < ?`, cutoff); err != nil {
+		return err
+	}
+	_, err := h.db.Exec(`
+		INSERT OR REPLACE INTO pending_uploads (file_hash, filename, data)
+		VALUES (?, ?, ?)`, fileHash, filename, data)
+	return err
+}
+
+// sniffHeadSize is how much of an upload SniffFormat looks at.
+const sniffHeadSize = 64 << 10
+
+// sniff ranks the formats data could be.
+func sniff(data []byte) []parser.FormatGuess {
+	return parser.SniffFormat(data[:min(len(data), sniffHeadSize)])
+}
+
+// addSalvaged adds what a failed parse recovered to its error body: the
+// header metadata and whatever angle lists and complete candela planes were
+// read, with "status" incomplete.
+func addSalvaged(body map[string]interface{}, inc *parser.IncompleteError) {
+	body["status"] = "incomplete"
+	body["luminaire"] = inc.Partial.Metadata
+	body["vertical_angles"] = inc.Partial.VerticalAngles
+	body["horizontal_angles"] = inc.Partial.HorizontalAngles
+	body["candela_values"] = inc.Partial.CandelaMatrix
+	body["extensions"] = inc.Partial.Extensions
+	body["planes_read"] = len(inc.Partial.CandelaMatrix)
+	body["planes_expected"] = inc.Planes
+}
+
+// formatErrorBody adds to the error body of an upload the formats the
+// content could be, to retry with one as source_format.
+func formatErrorBody(lang string, body map[string]interface{}, alternatives []parser.FormatGuess) map[string]interface{} {
+	if len(alternatives) > 0 {
+		body["alternatives"] = alternatives
+		body["hint"], _ = i18n.Message(lang, "source_format_hint", nil)
+	}
+	return body
 }
 
 func (h *LuminaireHandler) UploadWithMetadata(c echo.Context) error {
@@ -116,58 +344,44 @@ func (h *LuminaireHandler) UploadWithMetadata(c echo.Context) error {
 	inputWatts := c.FormValue("input_watts")
 	luminousFlux := c.FormValue("luminous_flux")
 
-	logger.Default.Infof("=== UPLOAD WITH METADATA START ===")
-	logger.Default.Infof("file_hash=%s, original_filename=%s", fileHash, originalFilename)
-	logger.Default.Infof("manufacturer=%s, model=%s", manufacturer, model)
-	logger.Default.Infof("catalog_number=%s, test_lab=%s, test_number=%s", catalogNumber, testLab, testNumber)
-	logger.Default.Infof("input_watts=%s, luminous_flux=%s", inputWatts, luminousFlux)
+	uploadLog.Debug("upload with metadata start", "file_hash", fileHash, "filename", originalFilename)
 
 	if fileHash == "" || originalFilename == "" {
-		logger.Default.Error("file_hash or original_filename is empty")
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "file_hash and original_filename are required"})
+		return apiError(c, http.StatusBadRequest, "upload_fields_required")
 	}
 
-	tmpDir := os.TempDir()
-	tmpPath := filepath.Join(tmpDir, fileHash+"_"+originalFilename)
-
-	logger.Default.Infof("looking for temp file: %s", tmpPath)
-
-	if _, err := os.Stat(tmpPath); os.IsNotExist(err) {
-		logger.Default.Infof("exact path not found, searching in temp dir...")
-		tmpFiles, _ := os.ReadDir(tmpDir)
-		for _, f := range tmpFiles {
-			if f.IsDir() {
-				continue
-			}
-			name := f.Name()
-			logger.Default.Infof("checking temp file: %s", name)
-			if strings.HasPrefix(name, fileHash) {
-				tmpPath = filepath.Join(tmpDir, name)
-				logger.Default.Infof("found matching temp file: %s", tmpPath)
-				break
-			}
-		}
+	var data []byte
+	err := h.db.QueryRow(`SELECT data FROM pending_uploads WHERE file_hash = ?`, fileHash).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		uploadLog.Warn("parked file not found", "file_hash", fileHash, "filename", originalFilename)
+		return apiError(c, http.StatusBadRequest, "pending_upload_not_found")
 	}
-
-	if _, err := os.Stat(tmpPath); os.IsNotExist(err) {
-		logger.Default.Errorf("temp file NOT FOUND: hash=%s, filename=%s, searched=%s", fileHash, originalFilename, tmpPath)
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "file not found, please upload again"})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 
-	logger.Default.Infof("temp file found, parsing: %s", tmpPath)
-	p, err := parser.GetParser(tmpPath)
+	req, status, err := h.conversionRequest(c, "", parser.WriteOptions{})
+	if err != nil {
+		return c.JSON(status, map[string]string{"error": err.Error()})
+	}
+	uploadLog.Debug("parsing", "filename", originalFilename)
+	p, err := req.reader(originalFilename)
 	if err != nil {
-		logger.Default.Errorf("GetParser failed: %v", err)
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
 
-	lum, err := p.Parse(tmpPath)
+	lum, err := h.cache.Parse(c.Request().Context(), p, data, originalFilename)
 	if err != nil {
-		logger.Default.Errorf("Parse failed: %v", err)
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("parse error: %v", err)})
+		uploadLog.Warn("parse failed", "filename", originalFilename, "err", err)
+		return apiErrorWith(c, parseErrorStatus(err), "parse_error", map[string]string{"detail": err.Error()})
 	}
 
-	logger.Default.Infof("parse successful, format_type=%s", lum.Metadata.FormatType)
+	lum.Metadata.OriginalFilename = originalFilename
+	lum.Metadata.FormatType = parser.DetectFormat(req.sourceName(originalFilename))
+	lum.Metadata.ParserOverride = req.sourceFormat
+	parsed := lum.Metadata
+	h.applyImportProfile(req.organization, &lum.Metadata)
+	profiled := lum.Metadata
 
 	// Only overwrite with user input if provided
 	if manufacturer != "" {
@@ -202,21 +416,35 @@ func (h *LuminaireHandler) UploadWithMetadata(c echo.Context) error {
 		lum.Metadata.LuminousFlux = f
 	}
 
-	logger.Default.Infof("saving luminaire to database: manufacturer=%s, model=%s", lum.Metadata.Manufacturer, lum.Metadata.Model)
+	if status, body, done := h.storedUpload(language(c), lum.Metadata.FileHash, req.trashed); done {
+		return c.JSON(status, body)
+	}
 	lumID, err := h.saveLuminaire(lum)
 	if err != nil {
-		logger.Default.Errorf("saveLuminaire failed: %v", err)
+		uploadLog.Error("save failed", "filename", originalFilename, "err", err)
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
-
-	os.Remove(tmpPath)
-	logger.Default.Infof("=== UPLOAD COMPLETE: luminaire_id=%d ===", lumID)
+	h.saveSource(lumID, originalFilename, req.sourceName(originalFilename), data)
+	saveFieldSources(h.db, lumID, fieldSources(parsed, profiled, lum.Metadata))
+	if _, err := h.db.Exec(`DELETE FROM pending_uploads WHERE file_hash = ?`, fileHash); err != nil {
+		uploadLog.Warn("parked file not removed", "file_hash", fileHash, "err", err)
+	}
+	uploadLog.Info("uploaded", "filename", originalFilename, "luminaire_id", lumID)
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"status":       "uploaded",
 		"luminaire_id": lumID,
 	})
 }
 
+// parseErrorStatus maps parser failures to a response code: files that go
+// over the configured parser limits are rejected as too large.
+func parseErrorStatus(err error) int {
+	if errors.Is(err, parser.ErrLimitExceeded) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
 func (h *LuminaireHandler) saveLuminaire(lum *database.ParsedLuminaire) (int64, error) {
 	db := h.db
 
@@ -232,8 +460,12 @@ func (h *LuminaireHandler) saveLuminaire(lum *database.ParsedLuminaire) (int64,
 			lamp_catalog, ballast, test_lab, test_number, issue_date, test_date,
 			luminaire_candela, lamp_position, symmetry, photometric_type, units_type,
 			conversion_factor, input_watts, luminous_flux, color_temp, cri,
-			format_type, symmetry_flag, file_hash, original_filename
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			format_type, format_version, format_confidence, symmetry_flag,
+			luminous_length, luminous_width, file_hash, original_filename, workflow_state,
+			lamp_lumen_depreciation, driver_maintenance_factor, rated_life,
+			luminous_height_c0, luminous_height_c90, luminous_height_c180, luminous_height_c270,
+			thd, inrush_current, frequency, parser_override
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		lum.Metadata.Manufacturer, lum.Metadata.Model, lum.Metadata.CatalogNumber,
 		lum.Metadata.LuminaireDesc, lum.Metadata.LampType, lum.Metadata.LampCatalog,
 		lum.Metadata.Ballast, lum.Metadata.TestLab, lum.Metadata.TestNumber,
@@ -241,8 +473,15 @@ func (h *LuminaireHandler) saveLuminaire(lum *database.ParsedLuminaire) (int64,
 		lum.Metadata.LampPosition, lum.Metadata.Symmetry, lum.Metadata.PhotometricType,
 		lum.Metadata.UnitsType, lum.Metadata.ConversionFactor, lum.Metadata.InputWatts,
 		lum.Metadata.LuminousFlux, lum.Metadata.ColorTemp, lum.Metadata.CRI,
-		lum.Metadata.FormatType, lum.Metadata.SymmetryFlag, lum.Metadata.FileHash,
-		lum.Metadata.OriginalFilename,
+		lum.Metadata.FormatType, lum.Metadata.FormatVersion, lum.Metadata.FormatConfidence,
+		lum.Metadata.SymmetryFlag, lum.Metadata.LuminousLength, lum.Metadata.LuminousWidth,
+		lum.Metadata.FileHash, lum.Metadata.OriginalFilename,
+		database.StateDraft, lum.Metadata.LampLumenDepreciation,
+		lum.Metadata.DriverMaintenanceFactor, lum.Metadata.RatedLife,
+		lum.Metadata.LuminousHeightC0, lum.Metadata.LuminousHeightC90,
+		lum.Metadata.LuminousHeightC180, lum.Metadata.LuminousHeightC270,
+		lum.Metadata.THD, lum.Metadata.InrushCurrent, lum.Metadata.Frequency,
+		lum.Metadata.ParserOverride,
 	)
 	if err != nil {
 		return 0, err
@@ -253,66 +492,179 @@ func (h *LuminaireHandler) saveLuminaire(lum *database.ParsedLuminaire) (int64,
 		return 0, err
 	}
 
-	vertAngles := fmt.Sprintf("%v", lum.VerticalAngles)
-	horzAngles := fmt.Sprintf("%v", lum.HorizontalAngles)
-	candelaVals := ""
-	for i, row := range lum.CandelaMatrix {
-		if i > 0 {
-			candelaVals += ";"
-		}
-		for j, v := range row {
-			if j > 0 {
-				candelaVals += ","
-			}
-			candelaVals += fmt.Sprintf("%.2f", v)
-		}
-	}
+	vertAngles := database.EncodeAngles(lum.VerticalAngles)
+	horzAngles := database.EncodeAngles(lum.HorizontalAngles)
+	candelaVals := database.EncodeCandela(lum.CandelaMatrix)
 
 	_, err = tx.Exec(`
-		INSERT INTO photometric_data (luminaire_id, vertical_angles, horizontal_angles, candela_values, num_vertical_angles, num_horizontal_angles)
-		VALUES (?, ?, ?, ?, ?, ?)`,
+		INSERT INTO photometric_data (luminaire_id, vertical_angles, horizontal_angles, candela_values, num_vertical_angles, num_horizontal_angles, extensions)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
 		lumID, vertAngles, horzAngles, candelaVals, len(lum.VerticalAngles), len(lum.HorizontalAngles),
+		database.EncodeExtensions(lum.Extensions),
 	)
 	if err != nil {
 		return 0, err
 	}
 
+	if _, err := saveMetrics(tx, lumID, lum); err != nil {
+		return 0, err
+	}
+	if _, err := saveValidation(tx, lumID, lum, h.rules("")); err != nil {
+		return 0, err
+	}
+
 	if err := tx.Commit(); err != nil {
 		return 0, err
 	}
+	h.events.publish(eventCreated, lumID)
 
 	return lumID, nil
 }
 
+// List returns luminaires newest first, optionally narrowed by a filter
+// expression (see database.Filter), a workflow state, a least quality
+// grade (?quality=B is A or B) and whether declared and computed values
+// disagree (?inconsistent=true, see validate.ConsistencyRules). Without
+// limit the whole catalog is returned. With limit, pages are selected
+// either by offset or, for deep pages that stay stable while uploads
+// arrive, by the cursor returned as next_cursor on the previous page.
 func (h *LuminaireHandler) List(c echo.Context) error {
-	db := h.db
+	db := h.readDB()
 
-	rows, err := db.Query(`
-		SELECT id, manufacturer, model, catalog_number, luminare_description,
-			lamp_type, test_lab, test_number, input_watts, luminous_flux,
-			format_type, original_filename, created_at
-		FROM luminaires ORDER BY created_at DESC
-	`)
+	limit := 0
+	if v := c.QueryParam("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageSize {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("limit must be between 1 and %d", maxPageSize)})
+		}
+		limit = n
+	}
+	offset := 0
+	if v := c.QueryParam("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid offset"})
+		}
+		offset = n
+	}
+
+	// NULLs, as databases an older build created may hold, read as the
+	// schema defaults, like database.LoadLuminaire reads them.
+	query := `
+		SELECT id, COALESCE(manufacturer, ''), COALESCE(model, ''),
+			COALESCE(catalog_number, ''), COALESCE(luminare_description, ''),
+			COALESCE(lamp_type, ''), COALESCE(test_lab, ''), COALESCE(test_number, ''),
+			COALESCE(input_watts, 0), COALESCE(luminous_flux, 0),
+			COALESCE(format_type, ''), COALESCE(format_version, ''),
+			COALESCE(format_confidence, 0), COALESCE(original_filename, ''),
+			COALESCE(workflow_state, 'published'), created_at, CAST(created_at AS TEXT),
+			(SELECT quality_score FROM luminaire_validation WHERE luminaire_id = luminaires.id),
+			` + database.QualityGradeColumn + `,
+			COALESCE((SELECT thumbnail FROM luminaire_metrics WHERE luminaire_id = luminaires.id), '')
+		FROM luminaires`
+	conds := []string{database.NotDeleted}
+	var args []interface{}
+	if cond, condArgs := h.visibleCondition(c); cond != "" {
+		conds = append(conds, cond)
+		args = append(args, condArgs...)
+	}
+	if v := c.QueryParam("filter"); v != "" {
+		filter, err := database.ParseFilter(v)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		if where, whereArgs := filter.Where(); where != "" {
+			conds = append(conds, where)
+			args = append(args, whereArgs...)
+		}
+	}
+	if v := c.QueryParam("state"); v != "" {
+		state, err := database.ParseWorkflowState(v)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		conds = append(conds, `workflow_state = ?`)
+		args = append(args, state)
+	}
+	if v := c.QueryParam("quality"); v != "" {
+		grade, err := validate.ParseGrade(v)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		conds = append(conds, database.QualityGradeColumn+` <= ?`)
+		args = append(args, grade)
+	}
+	if v := c.QueryParam("inconsistent"); v != "" {
+		inconsistent, err := strconv.ParseBool(v)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("inconsistent must be true or false, not %q", v)})
+		}
+		cond, condArgs := inconsistentCondition()
+		if !inconsistent {
+			cond = "NOT " + cond
+		}
+		conds = append(conds, cond)
+		args = append(args, condArgs...)
+	}
+	if v := c.QueryParam("cursor"); v != "" {
+		if offset > 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "use either cursor or offset"})
+		}
+		cursor, err := decodeListCursor(v)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		conds = append(conds, `(created_at < ? OR (created_at = ? AND id < ?))`)
+		args = append(args, cursor.createdAt, cursor.createdAt, cursor.id)
+		if limit == 0 {
+			limit = defaultPageSize
+		}
+	}
+	query += ` WHERE ` + strings.Join(conds, " AND ")
+	query += ` ORDER BY created_at DESC, id DESC`
+	if limit > 0 {
+		// One extra row tells whether another page follows.
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, limit+1, offset)
+	} else if offset > 0 {
+		query += ` LIMIT -1 OFFSET ?`
+		args = append(args, offset)
+	}
+
+	rows, err := db.Query(query, args...)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 	defer rows.Close()
 
 	luminaires := []map[string]interface{}{}
+	var last listCursor
+	hasMore := false
 	for rows.Next() {
+		if limit > 0 && len(luminaires) == limit {
+			hasMore = true
+			break
+		}
+
 		var id int64
 		var manufacturer, model, catalogNumber, lumDesc, lampType, testLab, testNumber string
-		var inputWatts, luminousFlux float64
-		var formatType, originalFilename, createdAt string
+		var inputWatts, luminousFlux, formatConfidence float64
+		var formatType, formatVersion, originalFilename, state, createdAt, createdAtRaw string
+		var qualityScore sql.NullInt64
+		var qualityGrade sql.NullString
+		var thumbnail string
 
 		err := rows.Scan(&id, &manufacturer, &model, &catalogNumber, &lumDesc,
 			&lampType, &testLab, &testNumber, &inputWatts, &luminousFlux,
-			&formatType, &originalFilename, &createdAt)
+			&formatType, &formatVersion, &formatConfidence, &originalFilename,
+			&state, &createdAt, &createdAtRaw,
+			&qualityScore, &qualityGrade, &thumbnail)
 		if err != nil {
-			continue
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("luminaire %d: %v", id, err)})
 		}
+		last = listCursor{createdAt: createdAtRaw, id: id}
 
-		luminaires = append(luminaires, map[string]interface{}{
+		row := map[string]interface{}{
 			"id":                id,
 			"manufacturer":      manufacturer,
 			"model":             model,
@@ -324,68 +676,118 @@ func (h *LuminaireHandler) List(c echo.Context) error {
 			"input_watts":       inputWatts,
 			"luminous_flux":     luminousFlux,
 			"format_type":       formatType,
+			"format_version":    formatVersion,
+			"format_confidence": formatConfidence,
 			"original_filename": originalFilename,
+			"state":             state,
 			"created_at":        createdAt,
-		})
+		}
+		// Records not validated yet have no score.
+		if qualityGrade.Valid {
+			row["quality_score"] = qualityScore.Int64
+			row["quality_grade"] = qualityGrade.String
+		}
+		// Thumbnails are drawn with the metrics, so records the backfill has
+		// not reached yet have none.
+		if thumbnail != "" {
+			row["thumbnail"] = "data:image/svg+xml;base64," + base64.StdEncoding.EncodeToString([]byte(thumbnail))
+		}
+		luminaires = append(luminaires, row)
 	}
 
-	return c.JSON(http.StatusOK, map[string]interface{}{
+	if err := rows.Err(); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	resp := map[string]interface{}{
 		"luminaires": luminaires,
-	})
+	}
+	if hasMore {
+		resp["next_cursor"] = last.encode()
+	}
+	return c.JSON(http.StatusOK, resp)
 }
 
+// Get returns a luminaire as JSON, or as a photometric file when the format
+// query parameter or the Accept header asks for IES, LDT or CIE.
 func (h *LuminaireHandler) Get(c echo.Context) error {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return apiError(c, http.StatusBadRequest, "invalid_id")
 	}
 
-	db := h.db
+	c.Response().Header().Add(echo.HeaderVary, echo.HeaderAccept)
+	format := strings.ToLower(c.QueryParam("format"))
+	if format == "" {
+		var ok bool
+		if format, ok = negotiateFormat(c.Request().Header.Get(echo.HeaderAccept)); !ok {
+			return c.JSON(http.StatusNotAcceptable, map[string]string{"error": "supported types: application/json, application/x-ies, application/x-ldt, application/x-cie"})
+		}
+	}
+	if format != "json" {
+		req, status, err := h.conversionRequest(c, format, parser.DefaultWriteOptions())
+		if err != nil {
+			return c.JSON(status, map[string]string{"error": err.Error()})
+		}
+		return h.sendLuminaireFile(c, id, req)
+	}
 
-	var lum database.Luminaire
-	err = db.QueryRow(`
-		SELECT id, manufacturer, model, catalog_number, luminare_description,
-			lamp_type, lamp_catalog, ballast, test_lab, test_number, issue_date,
-			test_date, luminaire_candela, lamp_position, symmetry, photometric_type,
-			units_type, conversion_factor, input_watts, luminous_flux, color_temp,
-			cri, format_type, symmetry_flag, file_hash, original_filename, created_at
-		FROM luminaires WHERE id = ?`, id,
-	).Scan(
-		&lum.ID, &lum.Manufacturer, &lum.Model, &lum.CatalogNumber, &lum.LuminaireDesc,
-		&lum.LampType, &lum.LampCatalog, &lum.Ballast, &lum.TestLab, &lum.TestNumber,
-		&lum.IssueDate, &lum.TestDate, &lum.LuminaireCandela, &lum.LampPosition,
-		&lum.Symmetry, &lum.PhotometricType, &lum.UnitsType, &lum.ConversionFactor,
-		&lum.InputWatts, &lum.LuminousFlux, &lum.ColorTemp, &lum.CRI, &lum.FormatType,
-		&lum.SymmetryFlag, &lum.FileHash, &lum.OriginalFilename, &lum.CreatedAt,
-	)
+	db := h.readDB()
+
+	lum, err := database.LoadLuminaire(db, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		var mergedInto int64
+		if db.QueryRow(`SELECT merged_into FROM luminaires WHERE id = ? AND merged_into IS NOT NULL`, id).Scan(&mergedInto) == nil {
+			return c.JSON(http.StatusGone, map[string]interface{}{"error": "luminaire was merged", "merged_into": mergedInto})
+		}
+		return apiError(c, http.StatusNotFound, "luminaire_not_found")
+	}
 	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{"error": "luminaire not found"})
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if h.hiddenFrom(c, lum.State) {
+		return apiError(c, http.StatusNotFound, "luminaire_not_found")
 	}
 
 	var photoData database.PhotometricData
+	var vertAngles, horzAngles, extensions string
 	err = db.QueryRow(`
 		SELECT id, luminaire_id, vertical_angles, horizontal_angles, candela_values,
-			num_vertical_angles, num_horizontal_angles
+			num_vertical_angles, num_horizontal_angles, extensions
 		FROM photometric_data WHERE luminaire_id = ?`, id,
 	).Scan(
-		&photoData.ID, &photoData.LuminaireID, &photoData.VerticalAngles,
-		&photoData.HorizontalAngles, &photoData.CandelaValues,
-		&photoData.NumVerticalAngles, &photoData.NumHorizontalAngles,
+		&photoData.ID, &photoData.LuminaireID, &vertAngles,
+		&horzAngles, &photoData.CandelaValues,
+		&photoData.NumVerticalAngles, &photoData.NumHorizontalAngles, &extensions,
 	)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to get photometric data"})
 	}
+	photoData.VerticalAngles = database.DecodeAngles(vertAngles)
+	photoData.HorizontalAngles = database.DecodeAngles(horzAngles)
+	photoData.Extensions = database.DecodeExtensions(extensions)
+	grid := photometry.GridOf(&database.ParsedLuminaire{
+		Metadata:         lum,
+		VerticalAngles:   photoData.VerticalAngles,
+		HorizontalAngles: photoData.HorizontalAngles,
+		CandelaMatrix:    database.DecodeCandela(photoData.CandelaValues),
+	})
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"luminaire":        lum,
 		"photometric_data": photoData,
+		"grid":             grid,
+		"goniometer":       lum.PhotometricType.Goniometer(),
+		"luminous_shape":   lum.LuminousShape(),
+		"driver":           luminaireDriver(db, id),
+		"provenance":       loadFieldSources(db, id),
 	})
 }
 
 func (h *LuminaireHandler) Update(c echo.Context) error {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return apiError(c, http.StatusBadRequest, "invalid_id")
 	}
 
 	db := h.db
@@ -400,6 +802,42 @@ func (h *LuminaireHandler) Update(c echo.Context) error {
 	issueDate := c.FormValue("issue_date")
 	inputWatts := c.FormValue("input_watts")
 	luminousFlux := c.FormValue("luminous_flux")
+	luminousLength := c.FormValue("luminous_length")
+	luminousWidth := c.FormValue("luminous_width")
+	aimTilt := c.FormValue("aim_tilt")
+	aimRotation := c.FormValue("aim_rotation")
+	for name, v := range map[string]string{"aim_tilt": aimTilt, "aim_rotation": aimRotation} {
+		if f, err := strconv.ParseFloat(v, 64); v != "" && (err != nil || math.Abs(f) > 360) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid " + name})
+		}
+	}
+	lampLumenDepreciation := c.FormValue("lamp_lumen_depreciation")
+	driverMaintenanceFactor := c.FormValue("driver_maintenance_factor")
+	for name, v := range map[string]string{"lamp_lumen_depreciation": lampLumenDepreciation, "driver_maintenance_factor": driverMaintenanceFactor} {
+		if f, err := strconv.ParseFloat(v, 64); v != "" && (err != nil || f <= 0 || f > 1) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": name + " must be a factor above 0 and at most 1"})
+		}
+	}
+	ratedLife := c.FormValue("rated_life")
+	if n, err := strconv.Atoi(ratedLife); ratedLife != "" && (err != nil || n <= 0) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "rated_life must be a whole number of hours"})
+	}
+	heights := make([]any, 4)
+	for i, name := range []string{"luminous_height_c0", "luminous_height_c90", "luminous_height_c180", "luminous_height_c270"} {
+		v := c.FormValue(name)
+		if f, err := strconv.ParseFloat(v, 64); v != "" && (err != nil || f < 0 || f > 10) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": name + " must be 0 to 10 m"})
+		}
+		heights[i] = v
+	}
+	power := make([]any, 3)
+	for i, f := range database.PowerQualityFields {
+		v := c.FormValue(f.Name)
+		if n, err := strconv.ParseFloat(v, 64); v != "" && (err != nil || !f.Plausible(n)) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("%s must be %s", f.Name, f.Range())})
+		}
+		power[i] = v
+	}
 
 	_, err = db.Exec(`
 		UPDATE luminaires SET
@@ -413,121 +851,250 @@ func (h *LuminaireHandler) Update(c echo.Context) error {
 			issue_date = COALESCE(NULLIF(?, ''), issue_date),
 			input_watts = COALESCE(NULLIF(?, ''), input_watts),
 			luminous_flux = COALESCE(NULLIF(?, ''), luminous_flux),
+			luminous_length = COALESCE(NULLIF(?, ''), luminous_length),
+			luminous_width = COALESCE(NULLIF(?, ''), luminous_width),
+			aim_tilt = COALESCE(NULLIF(?, ''), aim_tilt),
+			aim_rotation = COALESCE(NULLIF(?, ''), aim_rotation),
+			lamp_lumen_depreciation = COALESCE(NULLIF(?, ''), lamp_lumen_depreciation),
+			driver_maintenance_factor = COALESCE(NULLIF(?, ''), driver_maintenance_factor),
+			rated_life = COALESCE(NULLIF(?, ''), rated_life),
+			luminous_height_c0 = COALESCE(NULLIF(?, ''), luminous_height_c0),
+			luminous_height_c90 = COALESCE(NULLIF(?, ''), luminous_height_c90),
+			luminous_height_c180 = COALESCE(NULLIF(?, ''), luminous_height_c180),
+			luminous_height_c270 = COALESCE(NULLIF(?, ''), luminous_height_c270),
+			thd = COALESCE(NULLIF(?, ''), thd),
+			inrush_current = COALESCE(NULLIF(?, ''), inrush_current),
+			frequency = COALESCE(NULLIF(?, ''), frequency),
 			updated_at = CURRENT_TIMESTAMP
-		WHERE id = ?`,
+		WHERE id = ? AND `+database.NotDeleted,
 		manufacturer, model, catalogNumber, luminaireDesc, lampType,
-		testLab, testNumber, issueDate, inputWatts, luminousFlux, id,
+		testLab, testNumber, issueDate, inputWatts, luminousFlux,
+		luminousLength, luminousWidth, aimTilt, aimRotation,
+		lampLumenDepreciation, driverMaintenanceFactor, ratedLife,
+		heights[0], heights[1], heights[2], heights[3],
+		power[0], power[1], power[2], id,
 	)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
+	edited := map[string]string{}
+	for _, f := range provenanceFields {
+		switch f.name {
+		case "lamp_catalog", "ballast", "color_temp", "cri":
+			// Only files and manifests set these.
+		default:
+			if c.FormValue(f.name) != "" {
+				edited[f.name] = sourceUser
+			}
+		}
+	}
+	saveFieldSources(db, id, edited)
+	if err := refreshMetrics(db, id); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		logger.Default.Warnf("refresh metrics for luminaire %d: %v", id, err)
+	}
+	if err := refreshValidation(db, id, h.rules("")); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		logger.Default.Warnf("revalidate luminaire %d: %v", id, err)
+	}
+	h.events.publish(eventUpdated, id)
 
 	return c.JSON(http.StatusOK, map[string]string{"status": "updated"})
 }
 
+// Delete moves a luminaire to the trash: it leaves lists, filters and
+// exports, but keeps its photometry, history and file hash until it is
+// restored (see Restore). With ?purge=true it is removed for good instead,
+// whether live or already in the trash.
 func (h *LuminaireHandler) Delete(c echo.Context) error {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return apiError(c, http.StatusBadRequest, "invalid_id")
 	}
 
-	db := h.db
-
-	_, err = db.Exec("DELETE FROM luminaires WHERE id = ?", id)
-	if err != nil {
+	if purge, _ := strconv.ParseBool(c.QueryParam("purge")); purge {
+		if err := deleteLuminaire(h.db, id); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+	} else if trashed, err := trashLuminaire(h.db, id); err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	} else if !trashed {
+		return apiError(c, http.StatusNotFound, "luminaire_not_found")
 	}
+	h.events.publish(eventDeleted, id)
 
 	return c.JSON(http.StatusOK, map[string]string{"status": "deleted"})
 }
 
-func (h *LuminaireHandler) Export(c echo.Context) error {
-	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+// deleteLuminaire removes luminaire id and everything stored about it,
+// with the records merged into it: their 410 would point at nothing, and
+// their file hashes would keep the files from being uploaded again.
+func deleteLuminaire(db *sql.DB, id int64) error {
+	rows, err := db.Query(`SELECT id FROM luminaires WHERE merged_into = ?`, id)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return err
 	}
-
-	format := c.QueryParam("format")
-	if format == "" {
-		format = "ies"
+	ids := []int64{id}
+	for rows.Next() {
+		var merged int64
+		if rows.Scan(&merged) == nil {
+			ids = append(ids, merged)
+		}
 	}
+	rows.Close()
+	for _, id := range ids {
+		if err := deleteLuminaireRows(db, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	db := h.db
-
-	var lum database.Luminaire
-	var vertAngles, horzAngles, candelaVals string
+// deleteLuminaireRows removes one luminaire and everything stored about it.
+func deleteLuminaireRows(db execer, id int64) error {
+	if _, err := db.Exec("DELETE FROM luminaires WHERE id = ?", id); err != nil {
+		return err
+	}
+	db.Exec("DELETE FROM photometric_data WHERE luminaire_id = ?", id)
+	db.Exec("DELETE FROM luminaire_metrics WHERE luminaire_id = ?", id)
+	db.Exec("DELETE FROM luminaire_claims WHERE luminaire_id = ?", id)
+	db.Exec("DELETE FROM luminaire_test_reports WHERE luminaire_id = ?", id)
+	db.Exec("DELETE FROM family_variants WHERE luminaire_id = ?", id)
+	db.Exec("DELETE FROM luminaire_drivers WHERE luminaire_id = ?", id)
+	db.Exec("DELETE FROM luminaire_validation WHERE luminaire_id = ?", id)
+	db.Exec("DELETE FROM workflow_events WHERE luminaire_id = ?", id)
+	db.Exec("DELETE FROM luminaire_licenses WHERE luminaire_id = ?", id)
+	db.Exec("DELETE FROM photometric_conditions WHERE luminaire_id = ?", id)
+	db.Exec("DELETE FROM luminaire_components WHERE luminaire_id = ?", id)
+	db.Exec("DELETE FROM luminaire_orientations WHERE luminaire_id = ?", id)
+	db.Exec("DELETE FROM luminaire_sources WHERE luminaire_id = ?", id)
+	db.Exec("DELETE FROM luminaire_field_sources WHERE luminaire_id = ?", id)
+	db.Exec("DELETE FROM fetched_urls WHERE luminaire_id = ?", id)
+	return nil
+}
 
-	err = db.QueryRow(`
-		SELECT id, manufacturer, model, catalog_number, luminare_description,
-			lamp_type, lamp_catalog, ballast, test_lab, test_number, issue_date,
-			test_date, luminaire_candela, lamp_position, symmetry, photometric_type,
-			units_type, conversion_factor, input_watts, luminous_flux, color_temp,
-			cri, format_type, symmetry_flag, file_hash, original_filename
-		FROM luminaires WHERE id = ?`, id,
-	).Scan(
-		&lum.ID, &lum.Manufacturer, &lum.Model, &lum.CatalogNumber, &lum.LuminaireDesc,
-		&lum.LampType, &lum.LampCatalog, &lum.Ballast, &lum.TestLab, &lum.TestNumber,
-		&lum.IssueDate, &lum.TestDate, &lum.LuminaireCandela, &lum.LampPosition,
-		&lum.Symmetry, &lum.PhotometricType, &lum.UnitsType, &lum.ConversionFactor,
-		&lum.InputWatts, &lum.LuminousFlux, &lum.ColorTemp, &lum.CRI, &lum.FormatType,
-		&lum.SymmetryFlag, &lum.FileHash, &lum.OriginalFilename,
-	)
+func (h *LuminaireHandler) Export(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{"error": "luminaire not found"})
+		return apiError(c, http.StatusBadRequest, "invalid_id")
 	}
 
-	err = db.QueryRow(`
-		SELECT vertical_angles, horizontal_angles, candela_values
-		FROM photometric_data WHERE luminaire_id = ?`, id,
-	).Scan(&vertAngles, &horzAngles, &candelaVals)
+	req, status, err := h.conversionRequest(c, "ies", parser.WriteOptions{})
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to get photometric data"})
+		return c.JSON(status, map[string]string{"error": err.Error()})
+	}
+	formats := req.formats()
+	for _, f := range formats {
+		if _, err := parser.GetParser("export." + f); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
 	}
+	if len(formats) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "format is required"})
+	}
+	req.format = formats[0]
+	format := req.format
 
-	p, err := parser.GetParser("test." + format)
+	parsedLum, err := req.load(h.db, id)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return loadErrorResponse(c, err)
+	}
+	lum := parsedLum.Metadata
+	if h.exportBlocked(lum.State) {
+		return exportBlockedResponse(c, lum.State, h.exportState)
+	}
+	license, err := h.exportLicense(c, id)
+	if errors.Is(err, errLicenseNotAccepted) {
+		return licenseRequiredResponse(c, license)
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	// CIE i-tables list no C-planes; every other source is written plane by
+	// plane, so a matrix that does not match its angles cannot be exported.
+	if len(parsedLum.HorizontalAngles) > 0 {
+		if err := parsedLum.CheckShape(); err != nil {
+			return c.JSON(http.StatusUnprocessableEntity, map[string]string{"error": "stored photometric data is malformed: " + err.Error()})
+		}
 	}
 
-	parsedLum := &database.ParsedLuminaire{
-		Metadata: lum,
+	if req.aimed {
+		parsedLum = photometry.Aim(parsedLum, lum.AimTilt, lum.AimRotation)
 	}
 
-	candelaRows := [][]float64{}
-	rows := candelaVals
-	if rows != "" {
-		for _, rowStr := range strings.Split(rows, ";") {
-			row := []float64{}
-			for _, v := range strings.Split(rowStr, ",") {
-				if f, err := strconv.ParseFloat(v, 64); err == nil {
-					row = append(row, f)
-				}
-			}
-			if len(row) > 0 {
-				candelaRows = append(candelaRows, row)
-			}
-		}
+	if len(formats) > 1 {
+		return h.exportRenditions(c, id, parsedLum, license, req, formats)
+	}
+	p, err := req.writer()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
-	parsedLum.CandelaMatrix = candelaRows
 
-	filename := fmt.Sprintf("%s_%s.%s", lum.Manufacturer, lum.Model, format)
-	if filename == "_."+format || filename == " ."+format {
-		filename = fmt.Sprintf("luminaire_%d.%s", id, format)
+	filename := downloadFilename(lum, format)
+	if variant := req.component + req.orientation; variant != "" {
+		filename = fmt.Sprintf("%s_%s.%s", strings.TrimSuffix(filename, "."+format), unsafeFilenameChars.ReplaceAllString(variant, "-"), format)
 	}
 
-	c.Response().Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	c.Response().Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
 	c.Response().Header().Set("Content-Type", "application/octet-stream")
 
-	tmpPath := filepath.Join(os.TempDir(), filename)
-	if err := p.Write(parsedLum, tmpPath); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	opts, licenseIssues := embedLicense(license, format, req.optionsFor(lum, format))
+	opts.Provenance = req.provenance(parsedLum.Metadata.FileHash)
+	opts.Provenance.Derived = req.derived
+	data, issues, err := parser.Convert(p, parsedLum, opts)
+	if errors.Is(err, parser.ErrDowngrade) {
+		return downgradeErrorResponse(c, err)
 	}
-	defer os.Remove(tmpPath)
-
-	data, err := os.ReadFile(tmpPath)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
+	setExportIssues(c, append(issues, licenseIssues...))
+	setLicenseLink(c, license)
+	if req.derived != "" {
+		c.Response().Header().Set("X-Derived", req.derived)
+	}
+	h.recordConversion(c, id, format, opts, data)
 
 	return c.Blob(http.StatusOK, "application/octet-stream", data)
 }
+
+// exportRenditions answers /export?format=ies,ldt,cie with a ZIP holding one
+// file per format, rendered from the one loaded luminaire. Issues are
+// reported per format as "ldt.field: effect"; the archive carries a
+// SHA256SUMS manifest like collection exports.
+func (h *LuminaireHandler) exportRenditions(c echo.Context, id int64, lum *database.ParsedLuminaire, license *luminaireLicense, req *conversionRequest, formats []string) error {
+	var buf bytes.Buffer
+	zw := bundle.NewWriter(&buf, h.signingKey)
+	var issues []parser.CompatibilityIssue
+	var exported []func()
+	for _, format := range formats {
+		p, _ := parser.GetParser("export." + format)
+		opts, licenseIssues := embedLicense(license, format, req.optionsFor(lum.Metadata, format))
+		opts.Provenance = req.provenance(lum.Metadata.FileHash)
+		opts.Provenance.Derived = req.derived
+		data, formatIssues, err := parser.Convert(p, lum, opts)
+		if errors.Is(err, parser.ErrDowngrade) {
+			return downgradeErrorResponse(c, err)
+		}
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("%s: %v", format, err)})
+		}
+		for _, issue := range append(formatIssues, licenseIssues...) {
+			issue.Field = format + "." + issue.Field
+			issues = append(issues, issue)
+		}
+		if err := zw.Add(downloadFilename(lum.Metadata, format), data); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		exported = append(exported, func() { h.recordConversion(c, id, format, opts, data) })
+	}
+	if err := zw.Close(); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	for _, record := range exported {
+		record()
+	}
+
+	setExportIssues(c, issues)
+	setLicenseLink(c, license)
+	c.Response().Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, downloadFilename(lum.Metadata, "zip")))
+	return c.Blob(http.StatusOK, "application/zip", buf.Bytes())
+}
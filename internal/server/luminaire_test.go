@@ -0,0 +1,97 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+)
+
+// legacySchema is the luminaires table as early builds created it, without
+// NOT NULL on the metadata columns.
+const legacySchema = `
+	CREATE TABLE luminaires (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		manufacturer TEXT, model TEXT, catalog_number TEXT,
+		luminare_description TEXT, lamp_type TEXT, lamp_catalog TEXT,
+		ballast TEXT, test_lab TEXT, test_number TEXT, issue_date TEXT,
+		test_date TEXT, luminaire_candela TEXT, lamp_position TEXT,
+		symmetry INTEGER, photometric_type INTEGER, units_type TEXT,
+		conversion_factor REAL, input_watts REAL, luminous_flux REAL,
+		color_temp INTEGER, cri INTEGER, format_type TEXT, symmetry_flag INTEGER,
+		file_hash TEXT NOT NULL UNIQUE, original_filename TEXT,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`
+
+// TestLegacyNulls lists and reads a record an early build stored with NULL
+// metadata, before the newer columns existed, next to a current one.
+func TestLegacyNulls(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	old, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := old.Exec(legacySchema); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := old.Exec(`INSERT INTO luminaires (model, file_hash, created_at) VALUES ('Old', 'legacy', '2020-01-01 00:00:00')`); err != nil {
+		t.Fatal(err)
+	}
+	old.Close()
+
+	s, err := database.Open(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	h := &LuminaireHandler{db: s.GetDB()}
+	if _, err := h.db.Exec(`
+		INSERT INTO photometric_data (luminaire_id, vertical_angles, horizontal_angles, candela_values, num_vertical_angles, num_horizontal_angles)
+		VALUES (1, '[0,90]', '[0]', '', 2, 1)`); err != nil {
+		t.Fatal(err)
+	}
+	h.db.Exec(`INSERT INTO luminaires (manufacturer, model, file_hash) VALUES ('Acme', 'New', 'current')`)
+
+	e := echo.New()
+	e.GET("/api/v1/luminaires", h.List)
+	e.GET("/api/v1/luminaires/:id", h.Get)
+
+	resp := httptest.NewRecorder()
+	e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/api/v1/luminaires", nil))
+	var list struct {
+		Luminaires []map[string]interface{} `json:"luminaires"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &list)
+	if resp.Code != http.StatusOK || len(list.Luminaires) != 2 {
+		t.Fatalf("list: %d %s", resp.Code, resp.Body.String())
+	}
+	if legacy := list.Luminaires[1]; legacy["model"] != "Old" || legacy["manufacturer"] != "" || legacy["input_watts"] != 0.0 {
+		t.Errorf("legacy row = %v", legacy)
+	}
+
+	resp = httptest.NewRecorder()
+	e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/api/v1/luminaires/1", nil))
+	var got struct {
+		Luminaire database.Luminaire `json:"luminaire"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &got)
+	if resp.Code != http.StatusOK || got.Luminaire.Model != "Old" ||
+		got.Luminaire.PhotometricType != database.PhotometricTypeC || got.Luminaire.ConversionFactor != 1 {
+		t.Fatalf("get: %d %s", resp.Code, resp.Body.String())
+	}
+
+	all, err := database.ListLuminaires(h.db)
+	if err != nil || len(all) != 2 {
+		t.Errorf("ListLuminaires = %d records, %v", len(all), err)
+	}
+	if lum, err := database.LoadParsedLuminaire(h.db, 1); err != nil || fmt.Sprint(lum.VerticalAngles) != "[0 90]" {
+		t.Errorf("LoadParsedLuminaire = %v, %v", lum, err)
+	}
+}
@@ -0,0 +1,159 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+)
+
+// mergedRelations are the tables a merge moves to the primary, by the name
+// the merge response counts them under. History and fetched URLs move
+// whole; a duplicate's claims, report, license, family, driver link,
+// conditions, components and orientations move where the primary has none
+// of its own (by name for conditions, components and orientations) and
+// otherwise stay with the merged record.
+var mergedRelations = []struct{ name, table string }{
+	{"workflow_events", "workflow_events"},
+	{"conversions", "conversions"},
+	{"fetched_urls", "fetched_urls"},
+	{"claims", "luminaire_claims"},
+	{"test_reports", "luminaire_test_reports"},
+	{"licenses", "luminaire_licenses"},
+	{"families", "family_variants"},
+	{"drivers", "luminaire_drivers"},
+	{"conditions", "photometric_conditions"},
+	{"components", "luminaire_components"},
+	{"orientations", "luminaire_orientations"},
+}
+
+// recordByHash returns the luminaire holding the file with hash: the
+// record stored from it or, once that was merged, the record it was merged
+// into. ok is false when the file is not on record, and trashed is true
+// when the luminaire is in the trash (see Delete). Merged and trashed
+// records keep their file hash, so a file cannot be stored twice either
+// way.
+func recordByHash(db *sql.DB, hash string) (id int64, trashed, ok bool, err error) {
+	var mergedInto sql.NullInt64
+	err = db.QueryRow(`SELECT id, merged_into FROM luminaires WHERE file_hash = ?`, hash).Scan(&id, &mergedInto)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, false, nil
+	}
+	if err != nil {
+		return 0, false, false, err
+	}
+	if mergedInto.Valid {
+		id = mergedInto.Int64
+	}
+	if err := db.QueryRow(`SELECT deleted_at IS NOT NULL FROM luminaires WHERE id = ?`, id).Scan(&trashed); err != nil {
+		return 0, false, false, err
+	}
+	return id, trashed, true, nil
+}
+
+// mergeLuminaires folds duplicates into primary within tx: their relations
+// move to primary as mergedRelations says, then they are marked deleted
+// with merged_into set to primary. The photometry and source of a merged
+// record stay with it; its metrics and validation, which only describe it,
+// are dropped. It returns how many rows moved per relation.
+func mergeLuminaires(tx *sql.Tx, primary int64, duplicates []int64) (map[string]int64, error) {
+	moved := map[string]int64{}
+	for _, id := range duplicates {
+		for _, r := range mergedRelations {
+			res, err := tx.Exec(`UPDATE OR IGNORE `+r.table+` SET luminaire_id = ? WHERE luminaire_id = ?`, primary, id)
+			if err != nil {
+				return nil, fmt.Errorf("move %s of luminaire %d: %w", r.name, id, err)
+			}
+			n, _ := res.RowsAffected()
+			moved[r.name] += n
+		}
+		res, err := tx.Exec(`
+			UPDATE luminaires SET deleted_at = CURRENT_TIMESTAMP, merged_into = ?, updated_at = CURRENT_TIMESTAMP
+			WHERE id = ? AND `+database.NotDeleted, primary, id)
+		if err != nil {
+			return nil, err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return nil, fmt.Errorf("luminaire %d: %w", id, sql.ErrNoRows)
+		}
+		// Records merged into the duplicate earlier now point at the primary.
+		if _, err := tx.Exec(`UPDATE luminaires SET merged_into = ? WHERE merged_into = ?`, primary, id); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(`DELETE FROM luminaire_metrics WHERE luminaire_id = ?`, id); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(`DELETE FROM luminaire_validation WHERE luminaire_id = ?`, id); err != nil {
+			return nil, err
+		}
+	}
+	return moved, nil
+}
+
+// checkMerge validates a primary and its duplicates: at least one
+// duplicate, none of them the primary, all existing.
+func (h *LuminaireHandler) checkMerge(primary int64, duplicates []int64) (int, error) {
+	if len(duplicates) == 0 || slices.Contains(duplicates, primary) {
+		return http.StatusBadRequest, errors.New("name a primary and at least one other duplicate")
+	}
+	for _, id := range append([]int64{primary}, duplicates...) {
+		var exists int
+		if err := h.db.QueryRow(`SELECT COUNT(*) FROM luminaires WHERE id = ? AND `+database.NotDeleted, id).Scan(&exists); err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if exists == 0 {
+			return http.StatusNotFound, fmt.Errorf("luminaire %d not found", id)
+		}
+	}
+	return http.StatusOK, nil
+}
+
+// Merge folds duplicate records into a primary one from a JSON body such as
+// {"primary": 12, "duplicates": [31, 47]}: their history and attachments
+// move to the primary and they are soft-deleted, in one transaction. The
+// primary's own metadata and photometry are left as they are; see
+// MergeDuplicates to combine them. POST /api/v1/luminaires/merge.
+func (h *LuminaireHandler) Merge(c echo.Context) error {
+	var req struct {
+		Primary    int64   `json:"primary"`
+		Duplicates []int64 `json:"duplicates"`
+	}
+	if err := json.NewDecoder(c.Request().Body).Decode(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid merge: %v", err)})
+	}
+	slices.Sort(req.Duplicates)
+	req.Duplicates = slices.Compact(req.Duplicates)
+	if status, err := h.checkMerge(req.Primary, req.Duplicates); err != nil {
+		return c.JSON(status, map[string]string{"error": err.Error()})
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	defer tx.Rollback()
+	moved, err := mergeLuminaires(tx, req.Primary, req.Duplicates)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	h.events.publish(eventUpdated, req.Primary)
+	for _, id := range req.Duplicates {
+		h.events.publish(eventDeleted, id)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"status":       "merged",
+		"luminaire_id": req.Primary,
+		"merged":       req.Duplicates,
+		"moved":        moved,
+	})
+}
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/manifest"
+	"illuminate/internal/parser"
+	"illuminate/internal/synth"
+)
+
+// TestMerge merges one record into another that already merged a third:
+// the history and the attachments the primary lacks move over, every merged
+// record points at the primary and only the primary is still listed.
+func TestMerge(t *testing.T) {
+	h := newTestHandler(t)
+	var ids []int64
+	for i := range 3 {
+		id := saveSynth(t, h, fmt.Sprintf("merge-%d", i))
+		ids = append(ids, id)
+		h.db.Exec(`INSERT INTO workflow_events (luminaire_id, from_state, to_state, role) VALUES (?, 'draft', 'in_review', 'editor')`, id)
+		h.db.Exec(`INSERT INTO luminaire_licenses (luminaire_id, name) VALUES (?, ?)`, id, fmt.Sprintf("license %d", i))
+	}
+	primary, dup, older := ids[0], ids[1], ids[2]
+	h.db.Exec(`INSERT INTO luminaire_claims (luminaire_id, flux) VALUES (?, 900)`, older)
+
+	e := echo.New()
+	e.POST("/api/v1/luminaires/merge", h.Merge)
+	e.GET("/api/v1/luminaires/:id", h.Get)
+	e.GET("/api/v1/luminaires", h.List)
+	merge := func(body string) (*httptest.ResponseRecorder, map[string]int64) {
+		resp := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/luminaires/merge", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		e.ServeHTTP(resp, req)
+		var result struct {
+			Moved map[string]int64 `json:"moved"`
+		}
+		json.Unmarshal(resp.Body.Bytes(), &result)
+		return resp, result.Moved
+	}
+
+	if resp, moved := merge(fmt.Sprintf(`{"primary": %d, "duplicates": [%d]}`, dup, older)); resp.Code != http.StatusOK || moved["claims"] != 1 || moved["licenses"] != 0 {
+		t.Fatalf("first merge: %d %s", resp.Code, resp.Body.String())
+	}
+	resp, moved := merge(fmt.Sprintf(`{"primary": %d, "duplicates": [%d]}`, primary, dup))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("second merge: %d %s", resp.Code, resp.Body.String())
+	}
+	if moved["workflow_events"] != 2 || moved["claims"] != 1 || moved["licenses"] != 0 {
+		t.Errorf("moved %v", moved)
+	}
+	if resp, _ := merge(fmt.Sprintf(`{"primary": %d, "duplicates": [%d]}`, primary, dup)); resp.Code != http.StatusNotFound {
+		t.Errorf("merging a merged record: %d", resp.Code)
+	}
+	if resp, _ := merge(fmt.Sprintf(`{"primary": %d, "duplicates": [%d]}`, primary, primary)); resp.Code != http.StatusBadRequest {
+		t.Errorf("merging a record into itself: %d", resp.Code)
+	}
+
+	var license string
+	h.db.QueryRow(`SELECT name FROM luminaire_licenses WHERE luminaire_id = ?`, primary).Scan(&license)
+	if license != "license 0" {
+		t.Errorf("primary license = %q", license)
+	}
+	for _, id := range []int64{dup, older} {
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/luminaires/%d", id), nil))
+		var body struct {
+			MergedInto int64 `json:"merged_into"`
+		}
+		json.Unmarshal(resp.Body.Bytes(), &body)
+		if resp.Code != http.StatusGone || body.MergedInto != primary {
+			t.Errorf("luminaire %d: %d %s", id, resp.Code, resp.Body.String())
+		}
+	}
+
+	resp = httptest.NewRecorder()
+	e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/api/v1/luminaires", nil))
+	var list struct {
+		Luminaires []map[string]interface{} `json:"luminaires"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &list)
+	if len(list.Luminaires) != 1 || list.Luminaires[0]["id"] != float64(primary) {
+		t.Errorf("list: %s", resp.Body.String())
+	}
+}
+
+// TestUploadMergedFile uploads two files, merges the second into the first
+// and uploads the second again: it is answered as a duplicate of the
+// primary, and once the primary is deleted it can be stored anew.
+func TestUploadMergedFile(t *testing.T) {
+	h := newTestHandler(t)
+	e := echo.New()
+	e.POST("/api/v1/luminaires", h.Upload)
+	e.POST("/api/v1/luminaires/merge", h.Merge)
+	e.DELETE("/api/v1/luminaires/:id", h.Delete)
+
+	files := map[string][]byte{}
+	for _, model := range []string{"A", "B"} {
+		lum, err := synth.Generate(synth.Options{Distribution: synth.Lambertian, Manufacturer: "Acme", Model: model})
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[model], _ = parser.Encode(parser.NewIESParser(), lum, parser.WriteOptions{})
+	}
+	upload := func(model string) (int, int64) {
+		var body bytes.Buffer
+		w := multipart.NewWriter(&body)
+		part, _ := w.CreateFormFile("file", model+".ies")
+		part.Write(files[model])
+		w.Close()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/luminaires", &body)
+		req.Header.Set(echo.HeaderContentType, w.FormDataContentType())
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, req)
+		var out struct {
+			LuminaireID int64 `json:"luminaire_id"`
+		}
+		json.Unmarshal(resp.Body.Bytes(), &out)
+		return resp.Code, out.LuminaireID
+	}
+
+	_, a := upload("A")
+	_, b := upload("B")
+	if a == 0 || b == 0 {
+		t.Fatalf("uploads: %d, %d", a, b)
+	}
+	if code, id := upload("B"); code != http.StatusConflict || id != b {
+		t.Errorf("upload again: %d, luminaire %d", code, id)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/luminaires/merge", bytes.NewBufferString(fmt.Sprintf(`{"primary": %d, "duplicates": [%d]}`, a, b)))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	e.ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("merge: %d %s", resp.Code, resp.Body.String())
+	}
+	if code, id := upload("B"); code != http.StatusConflict || id != a {
+		t.Errorf("upload after merge: %d, luminaire %d, want 409 and %d", code, id, a)
+	}
+	if res := h.importFile(context.Background(), "", "B.ies", "", files["B"], manifest.Row{}); res["status"] != "duplicate" || res["luminaire_id"] != a {
+		t.Errorf("import after merge: %v, want a duplicate of %d", res, a)
+	}
+
+	resp = httptest.NewRecorder()
+	e.ServeHTTP(resp, httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/api/v1/luminaires/%d", a), nil))
+	if code, id := upload("B"); code != http.StatusConflict || id != a {
+		t.Errorf("upload after trashing the primary: %d, luminaire %d, want 409 and %d", code, id, a)
+	}
+	resp = httptest.NewRecorder()
+	e.ServeHTTP(resp, httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/api/v1/luminaires/%d?purge=true", a), nil))
+	if code, id := upload("B"); code != http.StatusOK || id == 0 || id == a || id == b {
+		t.Errorf("upload after purging the primary: %d, luminaire %d", code, id)
+	}
+}
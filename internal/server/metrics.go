@@ -0,0 +1,151 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+	"illuminate/internal/logger"
+	"illuminate/internal/photometry"
+	"illuminate/internal/polar"
+)
+
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// saveMetrics computes the photometric metrics of lum and caches them in
+// luminaire_metrics, where filters can reach them, with the polar thumbnail
+// List serves.
+func saveMetrics(db execer, id int64, lum *database.ParsedLuminaire) (photometry.Metrics, error) {
+	m := photometry.Compute(lum)
+	var hSpread, vSpread, peakH, peakV, peakI *float64
+	var nema *string
+	if f := m.Floodlight; f != nil {
+		hSpread, vSpread = &f.HorizontalSpread, &f.VerticalSpread
+		peakH, peakV, peakI = &f.PeakHorizontal, &f.PeakVertical, &f.PeakIntensity
+		nema = &f.NEMAType
+	}
+	_, err := db.Exec(`
+		INSERT OR REPLACE INTO luminaire_metrics (
+			luminaire_id, flux, downward_fraction, beam_angle, field_angle,
+			efficacy, distribution, symmetry, ugr, horizontal_spread,
+			vertical_spread, peak_horizontal, peak_vertical, peak_intensity,
+			nema_type, high_angle_mean_intensity, high_angle_max_intensity,
+			high_angle_luminance, high_angle_max_luminance, thumbnail, computed_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`,
+		id, m.Flux, m.DownwardFraction, m.BeamAngle, m.FieldAngle,
+		m.Efficacy, m.Distribution, m.Symmetry, m.UGR, hSpread,
+		vSpread, peakH, peakV, peakI, nema,
+		m.HighAngle.MeanIntensity, m.HighAngle.MaxIntensity,
+		m.HighAngle.AverageLuminance, m.HighAngle.MaxLuminance, string(polar.Thumbnail(lum)),
+	)
+	return m, err
+}
+
+// refreshMetrics recomputes the cached metrics of a stored luminaire after
+// its metadata changed.
+func refreshMetrics(db *sql.DB, id int64) error {
+	lum, err := database.LoadParsedLuminaire(db, id)
+	if err != nil {
+		return err
+	}
+	_, err = saveMetrics(db, id, lum)
+	return err
+}
+
+// backfillMetrics computes metrics for luminaires stored before the metrics
+// table existed.
+func backfillMetrics(db *sql.DB) {
+	rows, err := db.Query(`SELECT id FROM luminaires WHERE `+database.NotDeleted+` AND id NOT IN (SELECT luminaire_id FROM luminaire_metrics)`)
+	if err != nil {
+		logger.Default.Errorf("metrics backfill: %v", err)
+		return
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if rows.Scan(&id) == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		if err := refreshMetrics(db, id); err != nil {
+			logger.Default.Warnf("metrics backfill: luminaire %d: %v", id, err)
+		}
+	}
+	if len(ids) > 0 {
+		logger.Default.Infof("metrics backfill: computed %d luminaires", len(ids))
+	}
+}
+
+// Metrics returns the cached photometric metrics of a luminaire, computing
+// them on first use, and the angle grid they were computed from.
+func (h *LuminaireHandler) Metrics(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_id")
+	}
+
+	m, err := loadMetrics(h.db, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apiError(c, http.StatusNotFound, "luminaire_not_found")
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	lum, err := database.LoadParsedLuminaire(h.db, id)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"luminaire_id": id,
+		"metrics":      m,
+		"grid":         photometry.GridOf(lum),
+	})
+}
+
+// loadMetrics returns the cached metrics of a luminaire, computing and
+// caching them on a miss. It returns sql.ErrNoRows when the luminaire does
+// not exist.
+func loadMetrics(db *sql.DB, id int64) (photometry.Metrics, error) {
+	var m photometry.Metrics
+	var hSpread, vSpread, peakH, peakV, peakI sql.NullFloat64
+	var nema sql.NullString
+	err := db.QueryRow(`
+		SELECT flux, downward_fraction, beam_angle, field_angle, efficacy,
+			distribution, symmetry, ugr, horizontal_spread, vertical_spread,
+			peak_horizontal, peak_vertical, peak_intensity, nema_type,
+			high_angle_mean_intensity, high_angle_max_intensity,
+			high_angle_luminance, high_angle_max_luminance
+		FROM luminaire_metrics WHERE luminaire_id = ?`, id,
+	).Scan(&m.Flux, &m.DownwardFraction, &m.BeamAngle, &m.FieldAngle, &m.Efficacy,
+		&m.Distribution, &m.Symmetry, &m.UGR, &hSpread, &vSpread,
+		&peakH, &peakV, &peakI, &nema,
+		&m.HighAngle.MeanIntensity, &m.HighAngle.MaxIntensity,
+		&m.HighAngle.AverageLuminance, &m.HighAngle.MaxLuminance)
+	if nema.Valid {
+		m.Floodlight = &photometry.Floodlight{
+			HorizontalSpread: hSpread.Float64,
+			VerticalSpread:   vSpread.Float64,
+			PeakHorizontal:   peakH.Float64,
+			PeakVertical:     peakV.Float64,
+			PeakIntensity:    peakI.Float64,
+			NEMAType:         nema.String,
+		}
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return m, err
+	}
+	lum, err := database.LoadParsedLuminaire(db, id)
+	if err != nil {
+		return m, err
+	}
+	return saveMetrics(db, id, lum)
+}
@@ -0,0 +1,201 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+	"illuminate/internal/photometry"
+	"illuminate/internal/synth"
+)
+
+func TestMetricFilters(t *testing.T) {
+	h := newTestHandler(t)
+	for i, dist := range []synth.Distribution{synth.Lambertian, synth.NarrowBeam, synth.Batwing} {
+		opts := synth.DefaultOptions()
+		opts.Distribution = dist
+		lum, err := synth.Generate(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lum.Metadata.FileHash = fmt.Sprintf("metrics-%d", i)
+		lum.Metadata.Model = string(dist)
+		if dist != synth.Batwing {
+			lum.Metadata.LuminousLength, lum.Metadata.LuminousWidth = 0.6, 0.6
+		}
+		if _, err := h.saveLuminaire(lum); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	e := echo.New()
+	e.GET("/api/v1/luminaires", h.List)
+	e.GET("/api/v1/luminaires/:id/metrics", h.Metrics)
+
+	list := func(filter string) []string {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/luminaires?filter="+url.QueryEscape(filter), nil)
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, req)
+		if resp.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d: %s", filter, resp.Code, resp.Body.String())
+		}
+		var body struct {
+			Luminaires []struct {
+				Model string `json:"model"`
+			} `json:"luminaires"`
+		}
+		json.Unmarshal(resp.Body.Bytes(), &body)
+		var models []string
+		for _, l := range body.Luminaires {
+			models = append(models, l.Model)
+		}
+		sort.Strings(models)
+		return models
+	}
+
+	tests := map[string]string{
+		"beam=20..30":                        "[narrow]",
+		"beam_angle > 60, field_angle < 160": "[batwing]",
+		"ugr <= 19":                          "[lambertian narrow]",
+		"distribution=direct, symmetry=rotational": "[batwing lambertian narrow]",
+		"efficacy=95..110 lm/W, cct=0..3000K":      "[batwing lambertian narrow]",
+		"efficacy>200":                             "[]",
+		"high_angle_luminance >= 0":                "[lambertian narrow]",
+	}
+	for filter, want := range tests {
+		if got := fmt.Sprint(list(filter)); got != want {
+			t.Errorf("%s: got %s, want %s", filter, got, want)
+		}
+	}
+
+	// Records saved before the metrics table existed are picked up by the
+	// backfill.
+	h.db.Exec(`DELETE FROM luminaire_metrics`)
+	if got := fmt.Sprint(list("beam=20..30")); got != "[]" {
+		t.Fatalf("cleared metrics still match: %s", got)
+	}
+	backfillMetrics(h.db)
+	if got := fmt.Sprint(list("beam=20..30")); got != "[narrow]" {
+		t.Errorf("after backfill: %s", got)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/luminaires/1/metrics", nil)
+	resp := httptest.NewRecorder()
+	e.ServeHTTP(resp, req)
+	var body struct {
+		Metrics struct {
+			BeamAngle float64  `json:"beam_angle"`
+			UGR       *float64 `json:"ugr"`
+		} `json:"metrics"`
+		Grid photometry.Grid `json:"grid"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &body)
+	if resp.Code != http.StatusOK || body.Metrics.BeamAngle < 119 || body.Metrics.UGR == nil {
+		t.Errorf("metrics endpoint: %d %s", resp.Code, resp.Body.String())
+	}
+	if g := body.Grid; g.Vertical.Step == nil || *g.Vertical.Step != 5 || g.Horizontal.Coverage != photometry.CoverageFull {
+		t.Errorf("metrics grid = %+v", g)
+	}
+}
+
+// TestFloodlightMetrics caches the beam of a type B floodlight and filters
+// on it.
+func TestFloodlightMetrics(t *testing.T) {
+	h := newTestHandler(t)
+	lum := &database.ParsedLuminaire{Metadata: database.Luminaire{
+		PhotometricType: database.PhotometricTypeB,
+		Model:           "flood",
+		FileHash:        "flood",
+	}}
+	for a := -90.0; a <= 90; a += 5 {
+		lum.HorizontalAngles = append(lum.HorizontalAngles, a)
+		lum.VerticalAngles = append(lum.VerticalAngles, a)
+	}
+	for _, ha := range lum.HorizontalAngles {
+		row := make([]float64, len(lum.VerticalAngles))
+		for j, va := range lum.VerticalAngles {
+			row[j] = 5000 * math.Exp(-ha*ha/800-va*va/50)
+		}
+		lum.CandelaMatrix = append(lum.CandelaMatrix, row)
+	}
+	id, err := h.saveLuminaire(lum)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := loadMetrics(h.db, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := photometry.FloodlightOf(lum)
+	if m.Floodlight == nil || *m.Floodlight != *want {
+		t.Fatalf("cached floodlight = %+v, want %+v", m.Floodlight, want)
+	}
+
+	for filter, n := range map[string]int{
+		"nema_type=" + want.NEMAType: 1,
+		"horizontal_spread > 80":     1,
+		"vertical_spread > 80":       0,
+	} {
+		f, err := database.ParseFilter(filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found, err := database.FindLuminaires(h.db, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(found) != n {
+			t.Errorf("%s: %d records, want %d", filter, len(found), n)
+		}
+	}
+}
+
+// TestListThumbnails checks that uploads get a cached polar thumbnail the
+// catalog list serves as an image, and records without one list without.
+func TestListThumbnails(t *testing.T) {
+	h := newTestHandler(t)
+	for _, hash := range []string{"drawn", "pending"} {
+		saveSynth(t, h, hash)
+	}
+	// The backfill has not reached the second record yet.
+	if _, err := h.db.Exec(`DELETE FROM luminaire_metrics WHERE luminaire_id = 2`); err != nil {
+		t.Fatal(err)
+	}
+
+	e := echo.New()
+	e.GET("/api/v1/luminaires", h.List)
+	resp := httptest.NewRecorder()
+	e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/api/v1/luminaires", nil))
+	var list struct {
+		Luminaires []struct {
+			ID        int64  `json:"id"`
+			Thumbnail string `json:"thumbnail"`
+		} `json:"luminaires"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &list); err != nil || len(list.Luminaires) != 2 {
+		t.Fatalf("list: %s", resp.Body.String())
+	}
+	for _, l := range list.Luminaires {
+		encoded, ok := strings.CutPrefix(l.Thumbnail, "data:image/svg+xml;base64,")
+		if l.ID == 2 {
+			if l.Thumbnail != "" {
+				t.Errorf("luminaire 2 has a thumbnail before the backfill")
+			}
+			continue
+		}
+		svg, err := base64.StdEncoding.DecodeString(encoded)
+		if !ok || err != nil || !strings.HasPrefix(string(svg), "<svg") || !strings.Contains(string(svg), "<path") {
+			t.Errorf("luminaire %d thumbnail = %q", l.ID, l.Thumbnail)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+)
+
+// acceptFormats maps the media types accepted on GET /luminaires/:id to the
+// representation served. Short aliases are accepted next to the canonical
+// types in formatMIMETypes.
+var acceptFormats = map[string]string{
+	"application/json":        "json",
+	"application/*":           "json",
+	"*/*":                     "json",
+	"application/x-ies":       "ies",
+	"application/x-ies-lm-63": "ies",
+	"application/x-ldt":       "ldt",
+	"application/x-eulumdat":  "ldt",
+	"application/x-cie":       "cie",
+	"application/x-cie-102":   "cie",
+}
+
+// negotiateFormat picks the representation with the highest q-value in an
+// Accept header, preferring earlier entries on ties. An empty header means
+// JSON; ok is false when nothing acceptable is offered.
+func negotiateFormat(accept string) (format string, ok bool) {
+	if strings.TrimSpace(accept) == "" {
+		return "json", true
+	}
+
+	bestQ := 0.0
+	for _, entry := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(entry, ";")
+		f, known := acceptFormats[strings.ToLower(strings.TrimSpace(mediaType))]
+		if !known {
+			continue
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if k, v, found := strings.Cut(strings.TrimSpace(param), "="); found && strings.EqualFold(k, "q") {
+				if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q > bestQ {
+			format, bestQ = f, q
+		}
+	}
+	return format, bestQ > 0
+}
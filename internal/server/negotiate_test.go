@@ -0,0 +1,54 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestGetNegotiatesFormat(t *testing.T) {
+	h := newTestHandler(t)
+	id := saveSynth(t, h, "negotiate")
+
+	e := echo.New()
+	e.GET("/api/v1/luminaires/:id", h.Get)
+
+	tests := []struct {
+		accept, query string
+		status        int
+		contentType   string
+		prefix        string
+	}{
+		{"", "", http.StatusOK, "application/json", "{"},
+		{"application/json", "", http.StatusOK, "application/json", "{"},
+		{"application/x-ies", "", http.StatusOK, "application/x-ies-lm-63", "IESNA:LM-63-2002"},
+		{"application/x-ldt;q=0.9, application/json;q=0.5", "", http.StatusOK, "application/x-eulumdat", "Illuminate"},
+		{"text/html, */*;q=0.1", "", http.StatusOK, "application/json", "{"},
+		{"application/json", "format=cie", http.StatusOK, "application/x-cie-102", "   1"},
+		{"image/png", "", http.StatusNotAcceptable, "application/json", "{"},
+		{"", "format=pdf", http.StatusBadRequest, "application/json", "{"},
+	}
+	for _, tc := range tests {
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/luminaires/%d?%s", id, tc.query), nil)
+		if tc.accept != "" {
+			req.Header.Set(echo.HeaderAccept, tc.accept)
+		}
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, req)
+
+		if resp.Code != tc.status {
+			t.Errorf("Accept %q %s: status = %d, want %d", tc.accept, tc.query, resp.Code, tc.status)
+			continue
+		}
+		if ct := resp.Header().Get(echo.HeaderContentType); !strings.HasPrefix(ct, tc.contentType) {
+			t.Errorf("Accept %q %s: Content-Type = %q, want %s", tc.accept, tc.query, ct, tc.contentType)
+		}
+		if !strings.HasPrefix(resp.Body.String(), tc.prefix) {
+			t.Errorf("Accept %q %s: body starts %.30q", tc.accept, tc.query, resp.Body.String())
+		}
+	}
+}
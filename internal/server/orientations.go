@@ -0,0 +1,184 @@
+package server
+
+import (
+	"database/sql"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+	"illuminate/internal/parser"
+)
+
+// orientationPrefix starts the form field of each variant file in
+// UploadOriented: orientation.tilt30 is the variant called tilt30.
+const orientationPrefix = "orientation."
+
+// reservedOrientations cannot name a stored orientation: exports select
+// one with orientation=name, where they already have a meaning.
+var reservedOrientations = []string{"measured", "aimed"}
+
+// orientationValues reads the optional tilt (degrees) and mirrored form
+// values of an orientation, each key prefixed with prefix.
+func orientationValues(c echo.Context, prefix string) (*float64, bool, error) {
+	tilt, err := formNumber(c, prefix+"tilt", -180, 180)
+	if err != nil {
+		return nil, false, err
+	}
+	var mirrored bool
+	if v := strings.TrimSpace(c.FormValue(prefix + "mirrored")); v != "" {
+		if mirrored, err = strconv.ParseBool(v); err != nil {
+			return nil, false, &codedError{"value_not_boolean", map[string]string{"field": prefix + "mirrored", "value": v}}
+		}
+	}
+	return tilt, mirrored, nil
+}
+
+// ListOrientations returns the orientation variants stored with a
+// luminaire.
+func (h *LuminaireHandler) ListOrientations(c echo.Context) error {
+	return h.listVariants(c, "orientations", func(db *sql.DB, id int64) (interface{}, error) {
+		return database.ListOrientations(db, id)
+	})
+}
+
+// PutOrientation stores one orientation variant of a luminaire from a
+// multipart "file", replacing any earlier file of the same name:
+// PUT /api/v1/luminaires/:id/orientations/tilt30 with tilt=30, or
+// .../orientations/mirrored with mirrored=true. The file must use the
+// photometric type of the luminaire; its other metadata is ignored.
+func (h *LuminaireHandler) PutOrientation(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_id")
+	}
+	name := c.Param("name")
+	if !validVariantName(name, reservedOrientations...) {
+		return apiError(c, http.StatusBadRequest, "invalid_orientation_name")
+	}
+	tilt, mirrored, err := orientationValues(c, "")
+	if err != nil {
+		return errorResponse(c, http.StatusBadRequest, err)
+	}
+
+	req, status, err := h.conversionRequest(c, "", parser.WriteOptions{})
+	if err != nil {
+		return c.JSON(status, map[string]string{"error": err.Error()})
+	}
+	file, err := c.FormFile("file")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "file_required")
+	}
+
+	base, err := database.LoadParsedLuminaire(h.db, id)
+	if err != nil {
+		return loadErrorResponse(c, err)
+	}
+	lum, status, err := parseVariant(req, file)
+	if err != nil {
+		return errorResponse(c, status, err)
+	}
+	if err := typeMismatch(lum, base.Metadata.PhotometricType); err != nil {
+		return errorResponse(c, http.StatusUnprocessableEntity, err)
+	}
+
+	o := database.Orientation{Name: name, Tilt: tilt, Mirrored: mirrored, OriginalFilename: file.Filename}
+	if err := database.SaveOrientation(h.db, id, &o, lum); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"status":      "saved",
+		"orientation": o,
+	})
+}
+
+// DeleteOrientation removes one orientation variant.
+func (h *LuminaireHandler) DeleteOrientation(c echo.Context) error {
+	return h.deleteVariant(c, database.DeleteOrientation, database.ErrOrientationNotFound, "orientation_not_found")
+}
+
+// UploadOriented uploads a base file together with the orientation variants
+// the lab delivered with it, as one luminaire rather than unrelated
+// records: the base as multipart "file", each variant as
+// "orientation.<name>" (orientation.tilt30, orientation.mirrored) with
+// optional "<name>.tilt" and "<name>.mirrored" values. Every variant must
+// read before anything is stored. The base is then stored as Upload would;
+// when it still needs its manufacturer or model it is parked, the variants
+// are not kept and "orientations_pending" lists them to PUT once the base
+// is saved.
+func (h *LuminaireHandler) UploadOriented(c echo.Context) error {
+	form, err := c.MultipartForm()
+	if err != nil || len(form.File["file"]) == 0 {
+		return apiError(c, http.StatusBadRequest, "file_required")
+	}
+	base := form.File["file"][0]
+	var names []string
+	for field, files := range form.File {
+		name, ok := strings.CutPrefix(field, orientationPrefix)
+		if !ok || len(files) == 0 {
+			continue
+		}
+		if !validVariantName(name, reservedOrientations...) {
+			body := errorBody(language(c), "invalid_orientation_name", nil)
+			body["field"] = field
+			return c.JSON(http.StatusBadRequest, body)
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return apiError(c, http.StatusBadRequest, "orientation_file_required")
+	}
+	slices.Sort(names)
+
+	req, status, err := h.conversionRequest(c, "", parser.WriteOptions{})
+	if err != nil {
+		return c.JSON(status, map[string]string{"error": err.Error()})
+	}
+	// The variants are checked against the base before anything is stored,
+	// so a bad variant leaves no record behind. A base that does not read
+	// is answered as Upload answers it.
+	baseLum, _, err := parseVariant(req, base)
+	if err != nil {
+		status, body := h.processUpload(c.Request().Context(), language(c), req, base)
+		return c.JSON(status, body)
+	}
+
+	orientations := make([]database.Orientation, len(names))
+	parsed := make([]*database.ParsedLuminaire, len(names))
+	for i, name := range names {
+		file := form.File[orientationPrefix+name][0]
+		tilt, mirrored, err := orientationValues(c, name+".")
+		if err != nil {
+			return errorResponse(c, http.StatusBadRequest, err)
+		}
+		lum, status, err := parseVariant(req, file)
+		if err == nil {
+			status, err = http.StatusUnprocessableEntity, typeMismatch(lum, baseLum.Metadata.PhotometricType)
+		}
+		if err != nil {
+			body := errorBodyFor(language(c), err)
+			body["field"] = orientationPrefix + name
+			return c.JSON(status, body)
+		}
+		orientations[i] = database.Orientation{Name: name, Tilt: tilt, Mirrored: mirrored, OriginalFilename: file.Filename}
+		parsed[i] = lum
+	}
+
+	status, body := h.processUpload(c.Request().Context(), language(c), req, base)
+	if status != http.StatusOK || body["status"] != "uploaded" {
+		if status == http.StatusOK {
+			body["orientations_pending"] = names
+		}
+		return c.JSON(status, body)
+	}
+	id := body["luminaire_id"].(int64)
+	for i := range orientations {
+		if err := database.SaveOrientation(h.db, id, &orientations[i], parsed[i]); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+	}
+	body["orientations"] = orientations
+	return c.JSON(status, body)
+}
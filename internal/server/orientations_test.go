@@ -0,0 +1,241 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+	"illuminate/internal/parser"
+	"illuminate/internal/synth"
+)
+
+// orientedFile renders a synthetic street light as IES, mirrored across the
+// C0-C180 plane when mirrored is set.
+func orientedFile(t *testing.T, mirrored bool, photometricType database.PhotometricType) []byte {
+	t.Helper()
+	lum, err := synth.Generate(synth.Options{Distribution: synth.Street, Manufacturer: "Acme", Model: "SL-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mirrored {
+		n := len(lum.CandelaMatrix)
+		for i := 0; i < n/2; i++ {
+			lum.CandelaMatrix[i], lum.CandelaMatrix[n-1-i] = lum.CandelaMatrix[n-1-i], lum.CandelaMatrix[i]
+		}
+	}
+	lum.Metadata.PhotometricType = photometricType
+	data, err := parser.Encode(parser.NewIESParser(), lum, parser.WriteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// TestOrientations uploads a base file with a tilted and a mirrored variant
+// as one luminaire and exports each.
+func TestOrientations(t *testing.T) {
+	h := newTestHandler(t)
+	e := echo.New()
+	e.POST("/api/v1/luminaires/oriented", h.UploadOriented)
+	e.GET("/api/v1/luminaires/:id/orientations", h.ListOrientations)
+	e.PUT("/api/v1/luminaires/:id/orientations/:name", h.PutOrientation)
+	e.DELETE("/api/v1/luminaires/:id/orientations/:name", h.DeleteOrientation)
+	e.GET("/api/v1/luminaires/:id/export", h.Export)
+
+	base := orientedFile(t, false, database.PhotometricTypeC)
+	mirrored := orientedFile(t, true, database.PhotometricTypeC)
+	upload := func(files map[string][]byte, fields map[string]string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		w := multipart.NewWriter(&body)
+		for k, v := range fields {
+			w.WriteField(k, v)
+		}
+		for field, data := range files {
+			part, _ := w.CreateFormFile(field, strings.TrimPrefix(field, "orientation.")+".ies")
+			part.Write(data)
+		}
+		w.Close()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/luminaires/oriented", &body)
+		req.Header.Set(echo.HeaderContentType, w.FormDataContentType())
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, req)
+		return resp
+	}
+	count := func() int {
+		var n int
+		h.db.QueryRow(`SELECT COUNT(*) FROM luminaires`).Scan(&n)
+		return n
+	}
+
+	if resp := upload(map[string][]byte{"file": base}, nil); resp.Code != http.StatusBadRequest {
+		t.Errorf("no variants: status = %d, want 400", resp.Code)
+	}
+	if resp := upload(map[string][]byte{"file": base, "orientation.aimed": mirrored}, nil); resp.Code != http.StatusBadRequest {
+		t.Errorf("reserved name: status = %d, want 400", resp.Code)
+	}
+	typeB := orientedFile(t, false, database.PhotometricTypeB)
+	if resp := upload(map[string][]byte{"file": base, "orientation.tilt30": typeB}, nil); resp.Code != http.StatusUnprocessableEntity || count() != 0 {
+		t.Errorf("type B variant: status = %d, %d records, want 422 and none", resp.Code, count())
+	}
+
+	resp := upload(map[string][]byte{
+		"file":                 base,
+		"orientation.tilt30":   base,
+		"orientation.mirrored": mirrored,
+	}, map[string]string{"tilt30.tilt": "30", "mirrored.mirrored": "true"})
+	var uploaded struct {
+		Status       string                 `json:"status"`
+		ID           int64                  `json:"luminaire_id"`
+		Orientations []database.Orientation `json:"orientations"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &uploaded)
+	if resp.Code != http.StatusOK || uploaded.Status != "uploaded" || count() != 1 {
+		t.Fatalf("upload: status = %d, %d records: %s", resp.Code, count(), resp.Body.String())
+	}
+	get := func(path string) *httptest.ResponseRecorder {
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/luminaires/%d%s", uploaded.ID, path), nil))
+		return resp
+	}
+
+	var list struct {
+		Orientations []database.Orientation `json:"orientations"`
+	}
+	json.Unmarshal(get("/orientations").Body.Bytes(), &list)
+	if len(list.Orientations) != 2 {
+		t.Fatalf("orientations = %+v", list.Orientations)
+	}
+	if o := list.Orientations[0]; o.Name != "mirrored" || !o.Mirrored || o.Tilt != nil {
+		t.Errorf("mirrored = %+v", o)
+	}
+	if o := list.Orientations[1]; o.Name != "tilt30" || o.Mirrored || o.Tilt == nil || *o.Tilt != 30 {
+		t.Errorf("tilt30 = %+v", o)
+	}
+
+	// The street light throws further to its street side, C0 to C180;
+	// mirrored, its C30 plane is the measured C330 one.
+	exported := func(query string) *database.ParsedLuminaire {
+		t.Helper()
+		resp := get("/export?format=ies" + query)
+		if resp.Code != http.StatusOK {
+			t.Fatalf("export%s: status = %d: %s", query, resp.Code, resp.Body.String())
+		}
+		lum, err := parser.NewIESParser().ParseReader(strings.NewReader(resp.Body.String()), "export.ies")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return lum
+	}
+	measured, flipped := exported(""), exported("&orientation=mirrored")
+	n := len(measured.CandelaMatrix)
+	if fmt.Sprint(flipped.CandelaMatrix[2]) != fmt.Sprint(measured.CandelaMatrix[n-3]) ||
+		fmt.Sprint(flipped.CandelaMatrix[2]) == fmt.Sprint(measured.CandelaMatrix[2]) {
+		t.Error("the mirrored export is not the mirrored file")
+	}
+	if flipped.Metadata.Manufacturer != "Acme" {
+		t.Errorf("mirrored export manufacturer = %q, want the luminaire's", flipped.Metadata.Manufacturer)
+	}
+	if cd := get("/export?orientation=mirrored").Header().Get("Content-Disposition"); !strings.Contains(cd, "_mirrored.ies") {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+	// Like every variant, the export names the luminaire's file as its
+	// source; the variant's own hash is listed with the variant.
+	var baseHash string
+	h.db.QueryRow(`SELECT file_hash FROM luminaires WHERE id = ?`, uploaded.ID).Scan(&baseHash)
+	if body := get("/export?orientation=mirrored").Body.String(); !strings.Contains(body, "source="+baseHash) {
+		t.Errorf("mirrored export does not name %s as its source", baseHash)
+	}
+	if list.Orientations[0].FileHash == baseHash {
+		t.Errorf("mirrored lists the hash of the base file")
+	}
+	if resp := get("/export?orientation=tilt45"); resp.Code != http.StatusBadRequest {
+		t.Errorf("unknown orientation: status = %d, want 400", resp.Code)
+	}
+	if resp := get("/export?orientation=mirrored&condition=dim50"); resp.Code != http.StatusBadRequest {
+		t.Errorf("orientation with condition: status = %d, want 400", resp.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/api/v1/luminaires/%d/orientations/tilt30", uploaded.ID), nil)
+	resp = httptest.NewRecorder()
+	e.ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Errorf("delete: status = %d", resp.Code)
+	}
+	if resp := get("/export?orientation=tilt30"); resp.Code != http.StatusBadRequest {
+		t.Errorf("deleted orientation: status = %d, want 400", resp.Code)
+	}
+
+	if resp := putVariant(e, "orientations", uploaded.ID, "aimed", base, nil); resp.Code != http.StatusBadRequest {
+		t.Errorf("put reserved name: status = %d, want 400", resp.Code)
+	}
+	if resp := putVariant(e, "orientations", uploaded.ID, "tilt30", typeB, nil); resp.Code != http.StatusUnprocessableEntity {
+		t.Errorf("put type B: status = %d, want 422", resp.Code)
+	}
+	if resp := putVariant(e, "orientations", uploaded.ID, "tilt30", base, map[string]string{"tilt": "30"}); resp.Code != http.StatusOK {
+		t.Errorf("put: status = %d: %s", resp.Code, resp.Body.String())
+	}
+	if resp := get("/export?orientation=tilt30"); resp.Code != http.StatusOK {
+		t.Errorf("orientation put again: status = %d", resp.Code)
+	}
+}
+
+// TestOrientedUploadParked parks a base that lacks its manufacturer and
+// reports the variants it did not keep.
+func TestOrientedUploadParked(t *testing.T) {
+	h := newTestHandler(t)
+	lum, err := synth.Generate(synth.Options{Distribution: synth.Street, Model: "SL-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lum.Metadata.Manufacturer = ""
+	data, err := parser.Encode(parser.NewIESParser(), lum, parser.WriteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for _, field := range []string{"file", "orientation.mirrored"} {
+		part, _ := w.CreateFormFile(field, "sl1.ies")
+		part.Write(data)
+	}
+	w.Close()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/luminaires/oriented", &body)
+	req.Header.Set(echo.HeaderContentType, w.FormDataContentType())
+	resp := httptest.NewRecorder()
+	if err := h.UploadOriented(echo.New().NewContext(req, resp)); err != nil {
+		t.Fatal(err)
+	}
+	var parked struct {
+		Status  string   `json:"status"`
+		Pending []string `json:"orientations_pending"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &parked)
+	if parked.Status != "metadata_required" || len(parked.Pending) != 1 || parked.Pending[0] != "mirrored" {
+		t.Errorf("response = %s", resp.Body.String())
+	}
+}
+
+// TestExportOrientationLookupError answers 500, not 400, when the stored
+// orientation an export names cannot be looked up.
+func TestExportOrientationLookupError(t *testing.T) {
+	h := newTestHandler(t)
+	e := echo.New()
+	e.GET("/api/v1/luminaires/:id/export", h.Export)
+	id := saveSynth(t, h, "orientation-lookup")
+	if _, err := h.db.Exec(`DROP TABLE luminaire_orientations`); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := httptest.NewRecorder()
+	e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/luminaires/%d/export?orientation=tilt30", id), nil))
+	if resp.Code != http.StatusInternalServerError {
+		t.Errorf("status %d, want 500: %s", resp.Code, resp.Body.String())
+	}
+}
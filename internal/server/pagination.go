@@ -0,0 +1,38 @@
+package server
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 1000
+)
+
+// listCursor points at the last row of a page in the (created_at, id) order
+// of List. createdAt is the raw stored text, so comparisons in SQL see
+// exactly what was written.
+type listCursor struct {
+	createdAt string
+	id        int64
+}
+
+func (c listCursor) encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(c.createdAt + "|" + strconv.FormatInt(c.id, 10)))
+}
+
+func decodeListCursor(s string) (listCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return listCursor{}, fmt.Errorf("invalid cursor")
+	}
+	createdAt, idStr, ok := strings.Cut(string(raw), "|")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if !ok || err != nil || createdAt == "" {
+		return listCursor{}, fmt.Errorf("invalid cursor")
+	}
+	return listCursor{createdAt: createdAt, id: id}, nil
+}
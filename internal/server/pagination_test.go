@@ -0,0 +1,139 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+)
+
+func TestListCursorPagination(t *testing.T) {
+	h := newTestHandler(t)
+	// Rows share timestamps so the id tie-breaker is exercised.
+	for i := 0; i < 7; i++ {
+		saveSynth(t, h, fmt.Sprintf("page-%d", i))
+	}
+	if _, err := h.db.Exec(`UPDATE luminaires SET created_at = CASE WHEN id <= 3 THEN '2024-01-01 00:00:00' ELSE '2024-01-02 00:00:00' END`); err != nil {
+		t.Fatal(err)
+	}
+
+	e := echo.New()
+	e.GET("/api/v1/luminaires", h.List)
+
+	type page struct {
+		Luminaires []struct {
+			ID int64 `json:"id"`
+		} `json:"luminaires"`
+		NextCursor string `json:"next_cursor"`
+	}
+	get := func(query string, status int) page {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/luminaires?"+query, nil)
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, req)
+		if resp.Code != status {
+			t.Fatalf("%s: status = %d, want %d: %s", query, resp.Code, status, resp.Body.String())
+		}
+		var p page
+		json.Unmarshal(resp.Body.Bytes(), &p)
+		return p
+	}
+
+	first := get("limit=3", http.StatusOK)
+	if len(first.Luminaires) != 3 || first.NextCursor == "" {
+		t.Fatalf("first page: %+v", first)
+	}
+
+	// A new upload must not shift the pages that follow.
+	saveSynth(t, h, "page-new")
+
+	var ids []int64
+	for _, l := range first.Luminaires {
+		ids = append(ids, l.ID)
+	}
+	for cursor := first.NextCursor; cursor != ""; {
+		p := get("limit=3&cursor="+cursor, http.StatusOK)
+		for _, l := range p.Luminaires {
+			ids = append(ids, l.ID)
+		}
+		cursor = p.NextCursor
+	}
+	want := []int64{7, 6, 5, 4, 3, 2, 1}
+	if fmt.Sprint(ids) != fmt.Sprint(want) {
+		t.Errorf("paged ids = %v, want %v", ids, want)
+	}
+
+	if p := get("limit=2&offset=1", http.StatusOK); len(p.Luminaires) != 2 || p.Luminaires[0].ID == 8 {
+		t.Errorf("offset page: %+v", p)
+	}
+	if p := get("", http.StatusOK); len(p.Luminaires) != 8 || p.NextCursor != "" {
+		t.Errorf("unpaged list returned %d rows", len(p.Luminaires))
+	}
+	get("cursor=bogus", http.StatusBadRequest)
+	get("cursor="+first.NextCursor+"&offset=2", http.StatusBadRequest)
+	get("limit=0", http.StatusBadRequest)
+}
+
+// TestReadReplica points browsing at another database: List and Get answer
+// from it while writes go to the primary. A read-only connection to the
+// primary's own file serves the same way and refuses writes.
+func TestReadReplica(t *testing.T) {
+	h, replica := newTestHandler(t), newTestHandler(t)
+	save := func(h *LuminaireHandler, model string) int64 {
+		return saveSynth(t, h, model, func(lum *database.ParsedLuminaire) {
+			lum.Metadata.Model = model
+		})
+	}
+	save(h, "primary")
+	id := save(replica, "replica")
+	h.reader = replica.db
+
+	e := echo.New()
+	e.GET("/api/v1/luminaires", h.List)
+	e.GET("/api/v1/luminaires/:id", h.Get)
+	models := func() []interface{} {
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/api/v1/luminaires", nil))
+		var body struct {
+			Luminaires []map[string]interface{} `json:"luminaires"`
+		}
+		json.Unmarshal(resp.Body.Bytes(), &body)
+		var models []interface{}
+		for _, row := range body.Luminaires {
+			models = append(models, row["model"])
+		}
+		return models
+	}
+	if got := models(); len(got) != 1 || got[0] != "replica" {
+		t.Errorf("listed %v from the replica", got)
+	}
+	resp := httptest.NewRecorder()
+	e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/luminaires/%d", id), nil))
+	if resp.Code != http.StatusOK || !strings.Contains(resp.Body.String(), `"model":"replica"`) {
+		t.Errorf("get from the replica: %d %s", resp.Code, resp.Body.String())
+	}
+
+	var seq int
+	var name, file string
+	if err := h.db.QueryRow(`PRAGMA database_list`).Scan(&seq, &name, &file); err != nil {
+		t.Fatal(err)
+	}
+	readOnly, err := sql.Open("sqlite3", "file:"+file+"?mode=ro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { readOnly.Close() })
+	h.reader = readOnly
+	save(h, "later")
+	if got := models(); len(got) != 2 {
+		t.Errorf("listed %v from the primary's file", got)
+	}
+	if _, err := readOnly.Exec(`DELETE FROM luminaires`); err == nil {
+		t.Error("the read-only pool wrote")
+	}
+}
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+	"illuminate/internal/logger"
+	"illuminate/internal/parser"
+)
+
+// Where a metadata field got its value: read from the file, entered by a
+// user (UploadWithMetadata, Update or a manifest row), or derived by the
+// manufacturer's import profile.
+const (
+	sourceFile      = "file"
+	sourceUser      = "user"
+	sourceHeuristic = "heuristic"
+)
+
+// provenanceFields are the metadata fields whose source is tracked, by JSON
+// name with their luminaires column: those a user can set.
+var provenanceFields = []struct{ name, column string }{
+	{"manufacturer", "manufacturer"},
+	{"model", "model"},
+	{"catalog_number", "catalog_number"},
+	{"luminaire_description", "luminare_description"},
+	{"lamp_type", "lamp_type"},
+	{"lamp_catalog", "lamp_catalog"},
+	{"ballast", "ballast"},
+	{"test_lab", "test_lab"},
+	{"test_number", "test_number"},
+	{"issue_date", "issue_date"},
+	{"input_watts", "input_watts"},
+	{"luminous_flux", "luminous_flux"},
+	{"color_temp", "color_temp"},
+	{"cri", "cri"},
+	{"luminous_length", "luminous_length"},
+	{"luminous_width", "luminous_width"},
+	{"aim_tilt", "aim_tilt"},
+	{"aim_rotation", "aim_rotation"},
+	{"lamp_lumen_depreciation", "lamp_lumen_depreciation"},
+	{"driver_maintenance_factor", "driver_maintenance_factor"},
+	{"rated_life", "rated_life"},
+	{"luminous_height_c0", "luminous_height_c0"},
+	{"luminous_height_c90", "luminous_height_c90"},
+	{"luminous_height_c180", "luminous_height_c180"},
+	{"luminous_height_c270", "luminous_height_c270"},
+	{"thd", "thd"},
+	{"inrush_current", "inrush_current"},
+	{"frequency", "frequency"},
+}
+
+// metadataValue returns the field of meta called name in JSON.
+func metadataValue(meta database.Luminaire, name string) interface{} {
+	return reflect.ValueOf(meta).Field(luminaireFieldIndex[name]).Interface()
+}
+
+// fieldSources names the source of each set field, given the metadata as
+// the file had it, after the import profile and as finally stored: a value
+// the user stage changed is the user's, one the profile changed a
+// heuristic's and any other the file's.
+func fieldSources(file, profiled, final database.Luminaire) map[string]string {
+	sources := map[string]string{}
+	for _, f := range provenanceFields {
+		v := metadataValue(final, f.name)
+		switch {
+		case reflect.ValueOf(v).IsZero():
+		case v != metadataValue(profiled, f.name):
+			sources[f.name] = sourceUser
+		case v != metadataValue(file, f.name):
+			sources[f.name] = sourceHeuristic
+		default:
+			sources[f.name] = sourceFile
+		}
+	}
+	return sources
+}
+
+// saveFieldSources records where the fields of luminaire id came from,
+// replacing what was recorded for those fields. Failing to record them does
+// not fail the upload or edit.
+func saveFieldSources(db execer, id int64, sources map[string]string) {
+	for field, source := range sources {
+		if _, err := db.Exec(`
+			INSERT OR REPLACE INTO luminaire_field_sources (luminaire_id, field, source)
+			VALUES (?, ?, ?)`, id, field, source); err != nil {
+			logger.Default.Warnf("field source of luminaire %d: %v", id, err)
+			return
+		}
+	}
+}
+
+// loadFieldSources returns where the set fields of luminaire id came from,
+// by JSON name. Luminaires stored before sources were recorded have none.
+func loadFieldSources(db *sql.DB, id int64) map[string]string {
+	sources := map[string]string{}
+	rows, err := db.Query(`SELECT field, source FROM luminaire_field_sources WHERE luminaire_id = ?`, id)
+	if err != nil {
+		return sources
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var field, source string
+		if rows.Scan(&field, &source) == nil {
+			sources[field] = source
+		}
+	}
+	return sources
+}
+
+// Reparse reads a luminaire's metadata again from its stored source file, as
+// after a reader was fixed: POST /api/v1/luminaires/:id/reparse. The file's
+// values, with the import profile applied, replace the stored ones, except
+// that a field a user set keeps the user's value. It answers with the fields
+// that changed. Luminaires uploaded before sources were kept cannot be
+// re-parsed.
+func (h *LuminaireHandler) Reparse(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_id")
+	}
+
+	stored, err := database.LoadParsedLuminaire(h.db, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apiError(c, http.StatusNotFound, "luminaire_not_found")
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	var filename, format string
+	var data []byte
+	err = h.db.QueryRow(`SELECT filename, source_format, data FROM luminaire_sources WHERE luminaire_id = ?`, id).
+		Scan(&filename, &format, &data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "no source file stored"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	p, err := parser.GetReader("source." + format)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	lum, err := h.cache.Parse(c.Request().Context(), p, data, filename)
+	if err != nil {
+		return c.JSON(parseErrorStatus(err), map[string]string{"error": err.Error()})
+	}
+	parsed := lum.Metadata
+	h.applyImportProfile(organization(c), &lum.Metadata)
+	fresh := fieldSources(parsed, lum.Metadata, lum.Metadata)
+	recorded := loadFieldSources(h.db, id)
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	defer tx.Rollback()
+	changed := []string{}
+	for _, f := range provenanceFields {
+		if recorded[f.name] == sourceUser {
+			delete(fresh, f.name)
+			continue
+		}
+		v := metadataValue(lum.Metadata, f.name)
+		if v != metadataValue(stored.Metadata, f.name) {
+			if _, err := tx.Exec(`UPDATE luminaires SET `+f.column+` = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, v, id); err != nil {
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			}
+			changed = append(changed, f.name)
+		}
+		if _, err := tx.Exec(`DELETE FROM luminaire_field_sources WHERE luminaire_id = ? AND field = ?`, id, f.name); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+	}
+	saveFieldSources(tx, id, fresh)
+	if err := tx.Commit(); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	if len(changed) > 0 {
+		if err := refreshMetrics(h.db, id); err != nil {
+			logger.Default.Warnf("refresh metrics for luminaire %d: %v", id, err)
+		}
+		if err := refreshValidation(h.db, id, h.rules("")); err != nil {
+			logger.Default.Warnf("revalidate luminaire %d: %v", id, err)
+		}
+		h.events.publish(eventUpdated, id)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"status":  "reparsed",
+		"changed": changed,
+	})
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/parser"
+	"illuminate/internal/synth"
+)
+
+// TestProvenance follows the sources of fields through an upload, an edit
+// and a re-parse that restores what the file says but keeps the edit.
+func TestProvenance(t *testing.T) {
+	h := newTestHandler(t)
+	e := echo.New()
+	e.POST("/api/v1/luminaires", h.Upload)
+	e.GET("/api/v1/luminaires/:id", h.Get)
+	e.PUT("/api/v1/luminaires/:id", h.Update)
+	e.POST("/api/v1/luminaires/:id/reparse", h.Reparse)
+
+	if _, err := h.db.Exec(`INSERT INTO import_profiles (name, manufacturer, definition) VALUES ('acme', 'Acme', ?)`,
+		`{"manufacturer": "Acme", "fields": {"catalog_number": {"source": "ACME {model}"}}}`); err != nil {
+		t.Fatal(err)
+	}
+	lum, err := synth.Generate(synth.Options{Distribution: synth.Lambertian, Manufacturer: "Acme", Model: "DL-100"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lum.Metadata.LampType = "LED"
+	data, err := parser.Encode(parser.NewIESParser(), lum, parser.WriteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, _ := w.CreateFormFile("file", "dl100.ies")
+	part.Write(data)
+	w.Close()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/luminaires", &body)
+	req.Header.Set(echo.HeaderContentType, w.FormDataContentType())
+	resp := httptest.NewRecorder()
+	e.ServeHTTP(resp, req)
+	var uploaded struct {
+		LuminaireID int64 `json:"luminaire_id"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &uploaded)
+	if uploaded.LuminaireID == 0 {
+		t.Fatalf("upload: %s", resp.Body.String())
+	}
+	path := fmt.Sprintf("/api/v1/luminaires/%d", uploaded.LuminaireID)
+
+	get := func() (map[string]string, map[string]interface{}) {
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, path, nil))
+		var out struct {
+			Luminaire  map[string]interface{} `json:"luminaire"`
+			Provenance map[string]string      `json:"provenance"`
+		}
+		json.Unmarshal(resp.Body.Bytes(), &out)
+		return out.Provenance, out.Luminaire
+	}
+	sources, _ := get()
+	if sources["manufacturer"] != sourceFile || sources["lamp_type"] != sourceFile ||
+		sources["catalog_number"] != sourceHeuristic || sources["rated_life"] != "" {
+		t.Errorf("after upload: %v", sources)
+	}
+
+	form := url.Values{"model": {"DL-100/B"}}
+	req = httptest.NewRequest(http.MethodPut, path, strings.NewReader(form.Encode()))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	resp = httptest.NewRecorder()
+	e.ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("update: %s", resp.Body.String())
+	}
+	if sources, _ := get(); sources["model"] != sourceUser || sources["manufacturer"] != sourceFile {
+		t.Errorf("after update: %v", sources)
+	}
+
+	// A stored value the file does not say, as an older reader left it.
+	h.db.Exec(`UPDATE luminaires SET lamp_type = 'HID' WHERE id = ?`, uploaded.LuminaireID)
+	resp = httptest.NewRecorder()
+	e.ServeHTTP(resp, httptest.NewRequest(http.MethodPost, path+"/reparse", nil))
+	var reparsed struct {
+		Changed []string `json:"changed"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &reparsed)
+	if resp.Code != http.StatusOK || fmt.Sprint(reparsed.Changed) != "[lamp_type]" {
+		t.Fatalf("reparse: %d %s", resp.Code, resp.Body.String())
+	}
+	sources, meta := get()
+	if meta["lamp_type"] != "LED" || meta["model"] != "DL-100/B" || sources["model"] != sourceUser || sources["lamp_type"] != sourceFile {
+		t.Errorf("after reparse: %v %v", sources, meta)
+	}
+}
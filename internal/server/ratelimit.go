@@ -0,0 +1,99 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/cache"
+	"illuminate/internal/logger"
+)
+
+// rateLimit caps the API requests of each client address to requests per
+// window. Windows are fixed, counted in store, so instances that share a
+// Redis share one budget per client.
+type rateLimit struct {
+	requests int
+	window   time.Duration
+	store    cache.Store
+}
+
+// rateLimitFromEnv allows RATE_LIMIT_REQUESTS per RATE_LIMIT_WINDOW
+// (default 1m) per client; unset or 0 disables the limit. Counters live in
+// shared when it is not nil and in memory otherwise.
+func rateLimitFromEnv(shared *cache.Redis) rateLimit {
+	requests, _ := strconv.Atoi(os.Getenv("RATE_LIMIT_REQUESTS"))
+	window, err := time.ParseDuration(os.Getenv("RATE_LIMIT_WINDOW"))
+	if err != nil || window <= 0 {
+		window = time.Minute
+	}
+	l := rateLimit{requests: requests, window: window}
+	if shared != nil {
+		l.store = shared
+	} else {
+		l.store = cache.NewLRU(100000)
+	}
+	return l
+}
+
+// ipExtractorFromEnv decides which address a request comes from, for the
+// rate limit and logs. Without TRUSTED_PROXIES, a comma-separated list of
+// CIDR ranges, it is the peer address: X-Forwarded-For and X-Real-IP are
+// set by the client and would let it pick a fresh budget per request. With
+// it, X-Forwarded-For is followed back through those proxies only.
+func ipExtractorFromEnv() echo.IPExtractor {
+	var trust []echo.TrustOption
+	for _, cidr := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if cidr = strings.TrimSpace(cidr); cidr == "" {
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			logger.Default.Warnf("TRUSTED_PROXIES: %v", err)
+			continue
+		}
+		trust = append(trust, echo.TrustIPRange(ipNet))
+	}
+	if len(trust) == 0 {
+		return echo.ExtractIPDirect()
+	}
+	trust = append(trust, echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false))
+	return echo.ExtractIPFromXFFHeader(trust...)
+}
+
+// middleware counts every /api/ request against its client and answers 429
+// with Retry-After once the window's budget is spent. When the counter
+// cannot be reached the request is let through.
+func (l rateLimit) middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if l.requests <= 0 || l.store == nil || !strings.HasPrefix(c.Request().URL.Path, "/api/") {
+				return next(c)
+			}
+
+			now := time.Now()
+			window := now.UnixNano() / int64(l.window)
+			key := fmt.Sprintf("ratelimit:%s:%d", c.RealIP(), window)
+			n, err := l.store.Incr(c.Request().Context(), key, l.window)
+			if err != nil {
+				logger.Default.Warnf("rate limit: %v", err)
+				return next(c)
+			}
+
+			h := c.Response().Header()
+			h.Set("X-RateLimit-Limit", strconv.Itoa(l.requests))
+			h.Set("X-RateLimit-Remaining", strconv.FormatInt(max(int64(l.requests)-n, 0), 10))
+			if n > int64(l.requests) {
+				reset := time.Unix(0, (window+1)*int64(l.window))
+				h.Set("Retry-After", strconv.Itoa(int(reset.Sub(now).Seconds())+1))
+				return c.JSON(http.StatusTooManyRequests, map[string]string{"error": "rate limit exceeded"})
+			}
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/cache"
+)
+
+func TestRateLimit(t *testing.T) {
+	e := echo.New()
+	e.Use(rateLimit{requests: 2, window: time.Minute, store: cache.NewLRU(0)}.middleware())
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+	e.GET("/api/v1/luminaires", ok)
+	e.GET("/", ok)
+
+	get := func(path, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = ip + ":1234"
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		rec := get("/api/v1/luminaires", "10.0.0.1")
+		if rec.Code != want {
+			t.Fatalf("request %d: status %d, want %d", i+1, rec.Code, want)
+		}
+		if want == http.StatusTooManyRequests && rec.Header().Get("Retry-After") == "" {
+			t.Error("a limited request should say when to retry")
+		}
+	}
+	if rec := get("/api/v1/luminaires", "10.0.0.2"); rec.Code != http.StatusOK {
+		t.Errorf("another client got %d; budgets are per client", rec.Code)
+	}
+	if rec := get("/", "10.0.0.1"); rec.Code != http.StatusOK {
+		t.Errorf("pages outside /api/ got %d; only the API is limited", rec.Code)
+	}
+}
+
+// TestRateLimitForwardedFor changes X-Forwarded-For on every request: only
+// a trusted proxy's header names the client.
+func TestRateLimitForwardedFor(t *testing.T) {
+	for _, tc := range []struct {
+		proxies string
+		want    int
+	}{
+		{"", http.StatusTooManyRequests},
+		{"10.0.0.0/8", http.StatusOK},
+	} {
+		t.Setenv("TRUSTED_PROXIES", tc.proxies)
+		e := echo.New()
+		e.IPExtractor = ipExtractorFromEnv()
+		e.Use(rateLimit{requests: 2, window: time.Minute, store: cache.NewLRU(0)}.middleware())
+		e.GET("/api/v1/luminaires", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+
+		var rec *httptest.ResponseRecorder
+		for i := range 3 {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/luminaires", nil)
+			req.RemoteAddr = "10.0.0.1:1234"
+			req.Header.Set(echo.HeaderXForwardedFor, fmt.Sprintf("203.0.113.%d", i))
+			req.Header.Set(echo.HeaderXRealIP, fmt.Sprintf("203.0.113.%d", i))
+			rec = httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+		}
+		if rec.Code != tc.want {
+			t.Errorf("TRUSTED_PROXIES=%q: third request got %d, want %d", tc.proxies, rec.Code, tc.want)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+	"illuminate/internal/logger"
+)
+
+// defaultRecomputeThreshold is the relative change, in percent, above which
+// a recompute run reports a record.
+const defaultRecomputeThreshold = 1.0
+
+// recomputeChange is one record whose flux or efficacy moved by more than
+// the threshold of a recompute run. Previous or Current is nil where the
+// efficacy was or is no longer known.
+type recomputeChange struct {
+	LuminaireID  int64    `json:"luminaire_id"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model"`
+	Field        string   `json:"field"`
+	Previous     *float64 `json:"previous"`
+	Current      *float64 `json:"current"`
+	// Change is the relative change in percent; nil when either side is
+	// missing or the previous value was zero.
+	Change *float64 `json:"change"`
+}
+
+// recomputeCatalog recomputes the cached metrics of every luminaire with the
+// current photometry code and records in run runID the flux and efficacy
+// values that moved by more than threshold percent. The stated luminous flux
+// and input watts come from the file and are left as they are.
+func recomputeCatalog(db *sql.DB, runID int64, threshold float64) error {
+	type cached struct{ flux, efficacy *float64 }
+	previous := map[int64]cached{}
+	rows, err := db.Query(`SELECT luminaire_id, flux, efficacy FROM luminaire_metrics`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var id int64
+		var flux, efficacy sql.NullFloat64
+		if rows.Scan(&id, &flux, &efficacy) == nil {
+			previous[id] = cached{nullFloat(flux), nullFloat(efficacy)}
+		}
+	}
+	rows.Close()
+
+	luminaires, err := database.ListLuminaires(db)
+	if err != nil {
+		return err
+	}
+
+	changes := []recomputeChange{}
+	checked := 0
+	for _, meta := range luminaires {
+		lum, err := database.LoadParsedLuminaire(db, meta.ID)
+		if err != nil {
+			logger.Default.Warnf("recompute: luminaire %d: %v", meta.ID, err)
+			continue
+		}
+		m, err := saveMetrics(db, meta.ID, lum)
+		if err != nil {
+			return err
+		}
+		checked++
+
+		old, ok := previous[meta.ID]
+		if !ok {
+			continue
+		}
+		flux := m.Flux
+		for _, field := range []struct {
+			name      string
+			old, curr *float64
+		}{
+			{"flux", old.flux, &flux},
+			{"efficacy", old.efficacy, m.Efficacy},
+		} {
+			change, moved := relativeChange(field.old, field.curr, threshold)
+			if moved {
+				changes = append(changes, recomputeChange{
+					LuminaireID:  meta.ID,
+					Manufacturer: meta.Manufacturer,
+					Model:        meta.Model,
+					Field:        field.name,
+					Previous:     field.old,
+					Current:      field.curr,
+					Change:       change,
+				})
+			}
+		}
+	}
+
+	report, _ := json.Marshal(changes)
+	_, err = db.Exec(`
+		UPDATE recompute_runs SET finished_at = CURRENT_TIMESTAMP, checked = ?, changed = ?, report = ?
+		WHERE id = ?`, checked, len(changes), string(report), runID)
+	if err == nil {
+		logger.Default.Infof("recompute run %d: checked %d, %d values moved more than %g%%", runID, checked, len(changes), threshold)
+	}
+	return err
+}
+
+// relativeChange compares a cached value with its recomputed one. A value
+// that appears or disappears always counts as moved.
+func relativeChange(old, curr *float64, threshold float64) (*float64, bool) {
+	switch {
+	case old == nil && curr == nil:
+		return nil, false
+	case old == nil || curr == nil:
+		return nil, true
+	case *old == 0:
+		return nil, *curr != 0
+	}
+	change := (*curr - *old) / math.Abs(*old) * 100
+	return &change, math.Abs(change) > threshold
+}
+
+func nullFloat(v sql.NullFloat64) *float64 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Float64
+}
+
+// StartRecomputeRun recomputes the cached metrics of the whole catalog in
+// the background and returns the run to poll:
+// POST /api/v1/admin/recompute?threshold=0.5 reports values that moved by
+// more than 0.5% (default 1). 409 while another run is in progress.
+func (h *LuminaireHandler) StartRecomputeRun(c echo.Context) error {
+	threshold := defaultRecomputeThreshold
+	if v := c.QueryParam("threshold"); v != "" {
+		t, err := strconv.ParseFloat(v, 64)
+		if err != nil || t < 0 || math.IsNaN(t) || math.IsInf(t, 0) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "threshold must be a percentage of 0 or more"})
+		}
+		threshold = t
+	}
+
+	jobs := h.jobQueue()
+	ctx := c.Request().Context()
+	owner, locked, err := jobs.lock(ctx, jobRecompute)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if !locked {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "a recompute run is already in progress"})
+	}
+	res, err := h.db.Exec(`INSERT INTO recompute_runs (threshold) VALUES (?)`, threshold)
+	var id int64
+	if err == nil {
+		id, err = res.LastInsertId()
+	}
+	if err == nil {
+		err = jobs.enqueue(ctx, h.db, job{Kind: jobRecompute, RunID: id, Threshold: threshold, Owner: owner})
+	}
+	if err != nil {
+		jobs.unlock(jobRecompute, owner)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusAccepted, map[string]interface{}{
+		"status": "started",
+		"run_id": id,
+	})
+}
+
+// ListRecomputeRuns returns the most recent recompute runs without their
+// reports.
+func (h *LuminaireHandler) ListRecomputeRuns(c echo.Context) error {
+	rows, err := h.db.Query(`
+		SELECT id, threshold, started_at, COALESCE(CAST(finished_at AS TEXT), ''), checked, changed
+		FROM recompute_runs ORDER BY id DESC LIMIT 100`)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	defer rows.Close()
+
+	runs := []map[string]interface{}{}
+	for rows.Next() {
+		var id int64
+		var threshold float64
+		var startedAt, finishedAt string
+		var checked, changed int
+		if err := rows.Scan(&id, &threshold, &startedAt, &finishedAt, &checked, &changed); err != nil {
+			continue
+		}
+		runs = append(runs, map[string]interface{}{
+			"id":          id,
+			"threshold":   threshold,
+			"started_at":  startedAt,
+			"finished_at": finishedAt,
+			"checked":     checked,
+			"changed":     changed,
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"runs": runs,
+	})
+}
+
+// GetRecomputeRun returns one recompute run with the values that moved.
+func (h *LuminaireHandler) GetRecomputeRun(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_id")
+	}
+
+	var threshold float64
+	var startedAt, finishedAt, report string
+	var checked, changed int
+	err = h.db.QueryRow(`
+		SELECT threshold, started_at, COALESCE(CAST(finished_at AS TEXT), ''), checked, changed, report
+		FROM recompute_runs WHERE id = ?`, id,
+	).Scan(&threshold, &startedAt, &finishedAt, &checked, &changed, &report)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apiError(c, http.StatusNotFound, "run_not_found")
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"id":          id,
+		"threshold":   threshold,
+		"started_at":  startedAt,
+		"finished_at": finishedAt,
+		"checked":     checked,
+		"changed":     changed,
+		"changes":     json.RawMessage(report),
+	})
+}
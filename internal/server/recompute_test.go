@@ -0,0 +1,92 @@
+package server
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+)
+
+// TestRecomputeCatalog stores two luminaires, corrupts the cached flux of
+// one as an older integration might have, and checks that a run restores it
+// and reports only that record.
+func TestRecomputeCatalog(t *testing.T) {
+	h := newTestHandler(t)
+
+	var ids []int64
+	for _, hash := range []string{"stale", "fresh"} {
+		id := saveSynth(t, h, hash, func(lum *database.ParsedLuminaire) {
+			lum.Metadata.InputWatts = 20
+		})
+		ids = append(ids, id)
+	}
+	want, err := loadMetrics(h.db, ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.db.Exec(`UPDATE luminaire_metrics SET flux = flux / 2, efficacy = efficacy * 1.005 WHERE luminaire_id = ?`, ids[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	e := echo.New()
+	e.GET("/api/v1/admin/recompute/:id", h.GetRecomputeRun)
+	res, err := h.db.Exec(`INSERT INTO recompute_runs (threshold) VALUES (1)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runID, _ := res.LastInsertId()
+	if err := recomputeCatalog(h.db, runID, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := loadMetrics(h.db, ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(got.Flux-want.Flux) > 1e-9 {
+		t.Errorf("flux = %v, want %v", got.Flux, want.Flux)
+	}
+
+	resp := httptest.NewRecorder()
+	e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/api/v1/admin/recompute/1", nil))
+	var run struct {
+		Checked int               `json:"checked"`
+		Changed int               `json:"changed"`
+		Changes []recomputeChange `json:"changes"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &run); err != nil {
+		t.Fatalf("%v: %s", err, resp.Body.String())
+	}
+	// The efficacy moved by 0.5%, under the threshold.
+	if run.Checked != 2 || run.Changed != 1 || len(run.Changes) != 1 {
+		t.Fatalf("run = %+v", run)
+	}
+	change := run.Changes[0]
+	if change.LuminaireID != ids[0] || change.Field != "flux" || change.Change == nil || math.Abs(*change.Change-100) > 1e-6 {
+		t.Errorf("change = %+v", change)
+	}
+}
+
+func TestRelativeChange(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	for _, tc := range []struct {
+		old, curr *float64
+		moved     bool
+	}{
+		{nil, nil, false},
+		{nil, f(90), true},
+		{f(90), nil, true},
+		{f(0), f(0), false},
+		{f(0), f(1), true},
+		{f(100), f(100.5), false},
+		{f(100), f(98), true},
+	} {
+		if _, moved := relativeChange(tc.old, tc.curr, 1); moved != tc.moved {
+			t.Errorf("relativeChange(%v, %v) moved = %v, want %v", tc.old, tc.curr, moved, tc.moved)
+		}
+	}
+}
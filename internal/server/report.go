@@ -0,0 +1,302 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+	"illuminate/internal/photometry"
+)
+
+// testReport is what an LM-79 test report states about a luminaire, with
+// the TM-30 fidelity and gamut indices when the lab measured them; nil
+// fields were not reported.
+type testReport struct {
+	ReportNumber string   `json:"report_number"`
+	Lab          string   `json:"lab"`
+	InputWatts   *float64 `json:"input_watts"`
+	PowerFactor  *float64 `json:"power_factor"`
+	THD          *float64 `json:"thd"` // current THD in percent
+	Flux         *float64 `json:"flux"`
+	CIEX         *float64 `json:"cie_x"` // CIE 1931 chromaticity
+	CIEY         *float64 `json:"cie_y"`
+	CCT          *int     `json:"cct"`
+	CRI          *int     `json:"cri"`
+	TM30Rf       *float64 `json:"tm30_rf"`
+	TM30Rg       *float64 `json:"tm30_rg"`
+	// Source names the report document.
+	Source string `json:"source"`
+}
+
+// reportCRITolerance is how many points the file's CRI may differ from the
+// report's: one, for rounding.
+const reportCRITolerance = 1
+
+// validate rejects values no LM-79 report can state.
+func (r *testReport) validate() error {
+	for _, f := range []struct {
+		name     string
+		v        *float64
+		min, max float64
+	}{
+		{"input_watts", r.InputWatts, 0, math.Inf(1)},
+		{"flux", r.Flux, 0, math.Inf(1)},
+		{"thd", r.THD, -1, 1000},
+		{"cie_x", r.CIEX, 0, 1},
+		{"cie_y", r.CIEY, 0, 1},
+		{"tm30_rf", r.TM30Rf, -1, 100},
+		{"tm30_rg", r.TM30Rg, 0, 200},
+	} {
+		if f.v != nil && !(*f.v > f.min && *f.v <= f.max) {
+			return fmt.Errorf("%s out of range", f.name)
+		}
+	}
+	if r.PowerFactor != nil && !(*r.PowerFactor > 0 && *r.PowerFactor <= 1) {
+		return errors.New("power_factor must be above 0 and at most 1")
+	}
+	if (r.CIEX == nil) != (r.CIEY == nil) {
+		return errors.New("cie_x and cie_y go together")
+	}
+	if (r.CCT != nil && *r.CCT <= 0) || (r.CRI != nil && (*r.CRI <= 0 || *r.CRI > 100)) {
+		return errors.New("cct must be positive and cri 1 to 100")
+	}
+	return nil
+}
+
+// reportedCCT is the stated CCT, or McCamy's estimate from the chromaticity;
+// zero when the report has neither.
+func (r *testReport) reportedCCT() float64 {
+	if r.CCT != nil {
+		return float64(*r.CCT)
+	}
+	if r.CIEX != nil && r.CIEY != nil && *r.CIEY != 0.1858 {
+		n := (*r.CIEX - 0.3320) / (0.1858 - *r.CIEY)
+		return 449*n*n*n + 3525*n*n + 6823.3*n + 5520.33
+	}
+	return 0
+}
+
+// parseReportCSV reads a report as "field,value" rows using the JSON names,
+// such as "input_watts,41.2"; a "field,value" header row is skipped.
+func parseReportCSV(r io.Reader) (*testReport, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]any{}
+	for i, row := range rows {
+		if len(row) != 2 {
+			return nil, fmt.Errorf("row %d: want field,value", i+1)
+		}
+		key, value := strings.ToLower(strings.TrimSpace(row[0])), strings.TrimSpace(row[1])
+		switch {
+		case i == 0 && key == "field":
+			continue
+		case value == "":
+			continue
+		case key == "report_number" || key == "lab" || key == "source":
+			fields[key] = value
+		default:
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("row %d: %s is not a number", i+1, key)
+			}
+			fields[key] = f
+		}
+	}
+	// Round-trip through JSON so both forms share names and unknown
+	// fields are refused alike.
+	data, _ := json.Marshal(fields)
+	return decodeReportJSON(strings.NewReader(string(data)))
+}
+
+func decodeReportJSON(r io.Reader) (*testReport, error) {
+	var report testReport
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// GetTestReport returns the stored LM-79 report of a luminaire.
+func (h *LuminaireHandler) GetTestReport(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_id")
+	}
+	report, err := h.loadTestReport(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apiError(c, http.StatusNotFound, "test_report_not_found")
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, report)
+}
+
+// PutTestReport stores the LM-79 report of a luminaire from a JSON body such
+// as {"report_number": "R-1", "input_watts": 41.2, "power_factor": 0.97,
+// "thd": 8, "flux": 5020, "cie_x": 0.4369, "cie_y": 0.4041, "cri": 82},
+// or the same fields as text/csv "field,value" rows.
+func (h *LuminaireHandler) PutTestReport(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_id")
+	}
+
+	var report *testReport
+	if strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), "text/csv") {
+		report, err = parseReportCSV(c.Request().Body)
+	} else {
+		report, err = decodeReportJSON(c.Request().Body)
+	}
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid test report: %v", err)})
+	}
+	if err := report.validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	var exists int
+	if err := h.db.QueryRow(`SELECT COUNT(*) FROM luminaires WHERE id = ? AND `+database.NotDeleted, id).Scan(&exists); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if exists == 0 {
+		return apiError(c, http.StatusNotFound, "luminaire_not_found")
+	}
+
+	_, err = h.db.Exec(`
+		INSERT OR REPLACE INTO luminaire_test_reports (
+			luminaire_id, report_number, lab, input_watts, power_factor, thd, flux,
+			cie_x, cie_y, cct, cri, tm30_rf, tm30_rg, source, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`,
+		id, report.ReportNumber, report.Lab, report.InputWatts, report.PowerFactor, report.THD, report.Flux,
+		report.CIEX, report.CIEY, report.CCT, report.CRI, report.TM30Rf, report.TM30Rg, report.Source,
+	)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"status": "saved"})
+}
+
+// CheckTestReport cross-checks the stored LM-79 report with the photometric
+// file: GET /api/v1/luminaires/:id/report/check, with the tolerances of
+// CheckClaims. Flux is integrated from the candela values; watts, CCT, CRI
+// and the test number come from the file. A CCT the report gives only as
+// chromaticity is estimated with McCamy's formula. Power factor, THD and
+// TM-30 have nothing to compare with and are only returned.
+func (h *LuminaireHandler) CheckTestReport(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_id")
+	}
+
+	tol := claimTolerancesFromEnv()
+	for key, dst := range map[string]*float64{
+		"tolerance_flux":  &tol.FluxPercent,
+		"tolerance_watts": &tol.WattsPercent,
+		"tolerance_cct":   &tol.CCTKelvin,
+	} {
+		if v := c.QueryParam(key); v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil || f < 0 {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid " + key})
+			}
+			*dst = f
+		}
+	}
+
+	report, err := h.loadTestReport(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apiError(c, http.StatusNotFound, "test_report_not_found")
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	lum, err := database.LoadParsedLuminaire(h.db, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apiError(c, http.StatusNotFound, "luminaire_not_found")
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	// Here "claimed" is the reported value and "measured" the file's.
+	checks := []claimCheck{}
+	if report.Flux != nil {
+		flux := photometry.Flux(lum)
+		if flux <= 0 {
+			flux = lum.Metadata.LuminousFlux
+		}
+		checks = append(checks, relativeCheck("flux", *report.Flux, flux, tol.FluxPercent))
+	}
+	if report.InputWatts != nil {
+		checks = append(checks, relativeCheck("input_watts", *report.InputWatts, lum.Metadata.InputWatts, tol.WattsPercent))
+	}
+	if cct := report.reportedCCT(); cct > 0 {
+		checks = append(checks, absoluteCheck("cct", math.Round(cct), float64(lum.Metadata.ColorTemp), tol.CCTKelvin, "K"))
+	}
+	if report.CRI != nil {
+		checks = append(checks, absoluteCheck("cri", float64(*report.CRI), float64(lum.Metadata.CRI), reportCRITolerance, "Ra"))
+	}
+
+	discrepancies := 0
+	for _, check := range checks {
+		if check.Status == "discrepancy" {
+			discrepancies++
+		}
+	}
+	// The report should be the one the file names, when both say.
+	var testNumber *bool
+	if report.ReportNumber != "" && lum.Metadata.TestNumber != "" {
+		match := strings.EqualFold(strings.TrimSpace(report.ReportNumber), strings.TrimSpace(lum.Metadata.TestNumber))
+		testNumber = &match
+		if !match {
+			discrepancies++
+		}
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"luminaire_id":        id,
+		"report":              report,
+		"tolerances":          tol,
+		"checks":              checks,
+		"test_number_matches": testNumber,
+		"discrepancies":       discrepancies,
+	})
+}
+
+// absoluteCheck compares values whose tolerance is in their own unit.
+func absoluteCheck(field string, claimed, measured, tolerance float64, unit string) claimCheck {
+	check := claimCheck{Field: field, Claimed: claimed, Tolerance: tolerance, Unit: unit, Status: "unknown"}
+	if measured > 0 {
+		deviation := measured - claimed
+		check.Measured, check.Deviation = &measured, &deviation
+		check.Status = claimStatus(math.Abs(deviation) <= tolerance)
+	}
+	return check
+}
+
+func (h *LuminaireHandler) loadTestReport(id int64) (*testReport, error) {
+	var r testReport
+	err := h.db.QueryRow(`
+		SELECT report_number, lab, input_watts, power_factor, thd, flux, cie_x, cie_y,
+			cct, cri, tm30_rf, tm30_rg, source
+		FROM luminaire_test_reports WHERE luminaire_id = ?`, id,
+	).Scan(&r.ReportNumber, &r.Lab, &r.InputWatts, &r.PowerFactor, &r.THD, &r.Flux, &r.CIEX, &r.CIEY,
+		&r.CCT, &r.CRI, &r.TM30Rf, &r.TM30Rg, &r.Source)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+)
+
+func TestTestReport(t *testing.T) {
+	h := newTestHandler(t)
+	id := saveSynth(t, h, "report", func(lum *database.ParsedLuminaire) {
+		lum.Metadata.ColorTemp = 3000
+		lum.Metadata.TestNumber = "R-1"
+	})
+
+	e := echo.New()
+	e.GET("/api/v1/luminaires/:id/report", h.GetTestReport)
+	e.PUT("/api/v1/luminaires/:id/report", h.PutTestReport)
+	e.GET("/api/v1/luminaires/:id/report/check", h.CheckTestReport)
+	do := func(method, target, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set(echo.HeaderContentType, contentType)
+		}
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, req)
+		return resp
+	}
+	base := fmt.Sprintf("/api/v1/luminaires/%d/report", id)
+
+	if resp := do(http.MethodGet, base+"/check", "", ""); resp.Code != http.StatusNotFound {
+		t.Errorf("check without report: status = %d", resp.Code)
+	}
+	for _, body := range []string{`{"power_factor": 1.2}`, `{"cie_x": 0.4}`, `{"lumens": 1000}`} {
+		if resp := do(http.MethodPut, base, "", body); resp.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d", body, resp.Code)
+		}
+	}
+
+	check := func() (map[string]string, int) {
+		t.Helper()
+		resp := do(http.MethodGet, base+"/check", "", "")
+		if resp.Code != http.StatusOK {
+			t.Fatalf("check: status = %d: %s", resp.Code, resp.Body.String())
+		}
+		var body struct {
+			Checks        []claimCheck `json:"checks"`
+			Discrepancies int          `json:"discrepancies"`
+		}
+		json.Unmarshal(resp.Body.Bytes(), &body)
+		status := map[string]string{}
+		for _, c := range body.Checks {
+			status[c.Field] = c.Status
+		}
+		return status, body.Discrepancies
+	}
+
+	// The synthetic file measures 1000 lm at 10 W; the chromaticity is
+	// about 3006 K.
+	resp := do(http.MethodPut, base, "text/csv",
+		"field,value\nreport_number,R-1\ninput_watts,12\npower_factor,0.95\nthd,9\nflux,1010\ncie_x,0.4369\ncie_y,0.4041\n")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("put csv: status = %d: %s", resp.Code, resp.Body.String())
+	}
+	if got, n := check(); got["flux"] != "ok" || got["input_watts"] != "discrepancy" || got["cct"] != "ok" || n != 1 {
+		t.Errorf("csv report: %v, %d discrepancies", got, n)
+	}
+
+	resp = do(http.MethodPut, base, "", `{"report_number": "R-2", "input_watts": 10, "flux": 1000, "cct": 4000}`)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("put json: status = %d: %s", resp.Code, resp.Body.String())
+	}
+	if resp := do(http.MethodGet, base, "", ""); !strings.Contains(resp.Body.String(), `"report_number":"R-2"`) {
+		t.Errorf("get = %s", resp.Body.String())
+	}
+	if got, n := check(); got["cct"] != "discrepancy" || got["input_watts"] != "ok" || n != 2 {
+		t.Errorf("json report: %v, %d discrepancies", got, n)
+	}
+}
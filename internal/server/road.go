@@ -0,0 +1,73 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+	"illuminate/internal/photometry"
+	"illuminate/internal/road"
+)
+
+// RoadCheck estimates the road lighting classes a luminaire can reach:
+// GET /api/v1/luminaires/:id/road?lanes=2&lane_width=3.5&spacing=30&mounting_height=8.
+// The other parameters are overhang, arrangement (single, opposite or
+// staggered), maintenance_factor (the luminaire's light loss factor when
+// known, else 0.8), q0 and orientation=aimed; see road.Check.
+func (h *LuminaireHandler) RoadCheck(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_id")
+	}
+	lum, err := database.LoadParsedLuminaire(h.db, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apiError(c, http.StatusNotFound, "luminaire_not_found")
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	g := road.DefaultGeometry()
+	if llf := lum.Metadata.LightLossFactor(); llf > 0 {
+		g.MaintenanceFactor = llf
+	}
+	if v := c.QueryParam("lanes"); v != "" {
+		if g.Lanes, err = strconv.Atoi(v); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "lanes must be a whole number"})
+		}
+	}
+	for key, field := range map[string]*float64{
+		"lane_width":         &g.LaneWidth,
+		"spacing":            &g.Spacing,
+		"mounting_height":    &g.MountingHeight,
+		"overhang":           &g.Overhang,
+		"maintenance_factor": &g.MaintenanceFactor,
+		"q0":                 &g.Q0,
+	} {
+		if v := c.QueryParam(key); v != "" {
+			if *field, err = strconv.ParseFloat(v, 64); err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("%s must be a number", key)})
+			}
+		}
+	}
+	if v := c.QueryParam("arrangement"); v != "" {
+		g.Arrangement = v
+	}
+	switch orientation := c.QueryParam("orientation"); orientation {
+	case "", "measured":
+	case "aimed":
+		lum = photometry.Aim(lum, lum.Metadata.AimTilt, lum.Metadata.AimRotation)
+	default:
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "orientation must be measured or aimed"})
+	}
+
+	result, err := road.Check(lum, g)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/road"
+	"illuminate/internal/synth"
+)
+
+func TestRoadCheck(t *testing.T) {
+	h := newTestHandler(t)
+	e := echo.New()
+	e.GET("/api/v1/luminaires/:id/road", h.RoadCheck)
+
+	opts := synth.DefaultOptions()
+	opts.Distribution = synth.Street
+	opts.Flux = 12000
+	lum, err := synth.Generate(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lum.Metadata.FileHash = "street"
+	lum.Metadata.LampLumenDepreciation = 0.9
+	id, err := h.saveLuminaire(lum)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(query string) *httptest.ResponseRecorder {
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/luminaires/%d/road%s", id, query), nil))
+		return resp
+	}
+
+	resp := get("?lanes=3&spacing=35&arrangement=opposite")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", resp.Code, resp.Body.String())
+	}
+	var result road.Result
+	json.Unmarshal(resp.Body.Bytes(), &result)
+	if result.Geometry.Lanes != 3 || result.Geometry.Spacing != 35 || result.Geometry.Arrangement != road.Opposite {
+		t.Errorf("geometry = %+v", result.Geometry)
+	}
+	if result.Geometry.MaintenanceFactor != 0.9 {
+		t.Errorf("maintenance factor = %g, want the luminaire's 0.9", result.Geometry.MaintenanceFactor)
+	}
+	if result.AverageIlluminance <= 0 || result.PClass == "" {
+		t.Errorf("result = %+v", result)
+	}
+
+	for _, query := range []string{"?lanes=two", "?spacing=0", "?arrangement=zigzag", "?maintenance_factor=1.5", "?orientation=up"} {
+		if resp := get(query); resp.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, resp.Code)
+		}
+	}
+}
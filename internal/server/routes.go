@@ -15,16 +15,16 @@ import (
 
 func (s *Server) RegisterRoutes() http.Handler {
 	e := echo.New()
+	e.IPExtractor = s.ipExtractor
 	e.Use(middleware.Logger())
 	e.Use(middleware.Recover())
+	if s.maxUploadSize != "" {
+		e.Use(middleware.BodyLimit(s.maxUploadSize))
+	}
 
-	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
-		AllowOrigins:     []string{"https://*", "http://*"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
-		AllowHeaders:     []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		AllowCredentials: true,
-		MaxAge:           300,
-	}))
+	e.Use(middleware.CORSWithConfig(s.cors))
+	e.Use(s.rateLimit.middleware())
+	e.Use(s.idempotency.middleware())
 
 	fileServer := http.FileServer(http.FS(web.Files))
 	e.GET("/assets/*", echo.WrapHandler(fileServer))
@@ -32,7 +32,7 @@ func (s *Server) RegisterRoutes() http.Handler {
 	e.GET("/web", echo.WrapHandler(templ.Handler(web.HelloForm())))
 	e.POST("/hello", echo.WrapHandler(http.HandlerFunc(web.HelloWebHandler)))
 
-	lumHandler := NewLuminaireHandler(s.db)
+	lumHandler := NewLuminaireHandler(s.db, s.pool, s.parseCache, s.jobs, s.events, s.flags)
 
 	e.GET("/upload", web.UploadPageHandler)
 	e.GET("/", web.ListPageHandler)
@@ -41,14 +41,97 @@ func (s *Server) RegisterRoutes() http.Handler {
 
 	e.POST("/api/v1/luminaires", lumHandler.Upload)
 	e.POST("/api/v1/luminaires/with-metadata", lumHandler.UploadWithMetadata)
+	e.POST("/api/v1/luminaires/batch", lumHandler.UploadBatch)
+	e.POST("/api/v1/luminaires/oriented", lumHandler.UploadOriented)
+	e.POST("/api/v1/luminaires/import", lumHandler.ImportCatalog)
+	e.POST("/api/v1/luminaires/merge", lumHandler.Merge)
+	e.POST("/api/v1/luminaires/fetch", lumHandler.FetchURLs)
 	e.GET("/api/v1/luminaires", lumHandler.List)
+	e.GET("/api/v1/luminaires/stream", lumHandler.Stream)
+	e.GET("/api/v1/luminaires/events", lumHandler.Events)
 	e.GET("/api/v1/luminaires/:id", lumHandler.Get)
 	e.PUT("/api/v1/luminaires/:id", lumHandler.Update)
 	e.DELETE("/api/v1/luminaires/:id", lumHandler.Delete)
+	e.POST("/api/v1/luminaires/:id/restore", lumHandler.Restore)
+	e.GET("/api/v1/luminaires/:id/metrics", lumHandler.Metrics)
+	e.GET("/api/v1/luminaires/:id/compliance", lumHandler.Compliance)
+	e.GET("/api/v1/luminaires/:id/road", lumHandler.RoadCheck)
+	e.GET("/api/v1/luminaires/:id/claims", lumHandler.GetClaims)
+	e.PUT("/api/v1/luminaires/:id/claims", lumHandler.PutClaims)
+	e.GET("/api/v1/luminaires/:id/claims/check", lumHandler.CheckClaims)
+	e.GET("/api/v1/luminaires/:id/report", lumHandler.GetTestReport)
+	e.PUT("/api/v1/luminaires/:id/report", lumHandler.PutTestReport)
+	e.GET("/api/v1/luminaires/:id/report/check", lumHandler.CheckTestReport)
+	e.GET("/api/v1/luminaires/:id/validation", lumHandler.Validation)
+	e.GET("/api/v1/luminaires/:id/compatibility", lumHandler.Compatibility)
+	e.GET("/api/v1/luminaires/:id/annotated", lumHandler.Annotated)
+	e.POST("/api/v1/luminaires/:id/reparse", lumHandler.Reparse)
+	e.GET("/api/v1/luminaires/:id/state", lumHandler.GetState)
+	e.POST("/api/v1/luminaires/:id/state", lumHandler.Transition)
+	e.GET("/api/v1/luminaires/:id/license", lumHandler.GetLicense)
+	e.PUT("/api/v1/luminaires/:id/license", lumHandler.PutLicense)
+	e.DELETE("/api/v1/luminaires/:id/license", lumHandler.DeleteLicense)
+	e.GET("/api/v1/luminaires/:id/qr", lumHandler.QRCode)
+	e.GET("/api/v1/luminaires/:id/label", lumHandler.Label)
 	e.GET("/api/v1/luminaires/:id/export", lumHandler.Export)
+	e.GET("/api/v1/luminaires/:id/download/:app", lumHandler.Download)
+	e.GET("/api/v1/luminaires/:id/conversions", lumHandler.Conversions)
+	e.GET("/api/v1/luminaires/:id/conditions", lumHandler.ListConditions)
+	e.PUT("/api/v1/luminaires/:id/conditions/:name", lumHandler.PutCondition)
+	e.DELETE("/api/v1/luminaires/:id/conditions/:name", lumHandler.DeleteCondition)
+	e.GET("/api/v1/luminaires/:id/components", lumHandler.ListComponents)
+	e.PUT("/api/v1/luminaires/:id/components/:name", lumHandler.PutComponent)
+	e.DELETE("/api/v1/luminaires/:id/components/:name", lumHandler.DeleteComponent)
+	e.GET("/api/v1/luminaires/:id/orientations", lumHandler.ListOrientations)
+	e.PUT("/api/v1/luminaires/:id/orientations/:name", lumHandler.PutOrientation)
+	e.DELETE("/api/v1/luminaires/:id/orientations/:name", lumHandler.DeleteOrientation)
+	e.GET("/api/v1/luminaires/:id/dimmed", lumHandler.Dimmed)
+
+	e.GET("/api/v1/export-profiles", lumHandler.ListExportProfiles)
+	e.GET("/api/v1/export-profiles/:name", lumHandler.GetExportProfile)
+	e.PUT("/api/v1/export-profiles/:name", lumHandler.PutExportProfile)
+	e.DELETE("/api/v1/export-profiles/:name", lumHandler.DeleteExportProfile)
+
+	e.GET("/api/v1/import-profiles", lumHandler.ListImportProfiles)
+	e.GET("/api/v1/import-profiles/:name", lumHandler.GetImportProfile)
+	e.PUT("/api/v1/import-profiles/:name", lumHandler.PutImportProfile)
+	e.DELETE("/api/v1/import-profiles/:name", lumHandler.DeleteImportProfile)
+
+	e.GET("/api/v1/collections", lumHandler.ListCollections)
+	e.GET("/api/v1/collections/:name", lumHandler.GetCollection)
+	e.PUT("/api/v1/collections/:name", lumHandler.PutCollection)
+	e.DELETE("/api/v1/collections/:name", lumHandler.DeleteCollection)
+	e.GET("/api/v1/collections/:name/export", lumHandler.ExportCollection)
+	e.GET("/api/v1/export-signing-key", lumHandler.SigningKey)
+
+	e.GET("/api/v1/families", lumHandler.ListFamilies)
+	e.GET("/api/v1/families/:name", lumHandler.GetFamily)
+	e.PUT("/api/v1/families/:name", lumHandler.PutFamily)
+	e.DELETE("/api/v1/families/:name", lumHandler.DeleteFamily)
+	e.PUT("/api/v1/families/:name/variants/:id", lumHandler.LinkVariant)
+	e.DELETE("/api/v1/families/:name/variants/:id", lumHandler.UnlinkVariant)
+	e.GET("/api/v1/drivers", lumHandler.ListDrivers)
+	e.GET("/api/v1/drivers/:name", lumHandler.GetDriver)
+	e.PUT("/api/v1/drivers/:name", lumHandler.PutDriver)
+	e.DELETE("/api/v1/drivers/:name", lumHandler.DeleteDriver)
+	e.PUT("/api/v1/drivers/:name/luminaires/:id", lumHandler.LinkDriver)
+	e.DELETE("/api/v1/drivers/:name/luminaires/:id", lumHandler.UnlinkDriver)
+
+	e.GET("/api/v1/compliance", lumHandler.ComplianceReport)
+	e.GET("/api/v1/duplicates", lumHandler.Duplicates)
+	e.POST("/api/v1/duplicates/merge", lumHandler.MergeDuplicates)
+
+	e.GET("/api/v1/validation/runs", lumHandler.ListValidationRuns)
+	e.POST("/api/v1/validation/runs", lumHandler.StartValidationRun)
+	e.GET("/api/v1/validation/runs/:id", lumHandler.GetValidationRun)
+	e.POST("/api/v1/validate/batch", lumHandler.ValidateBatch)
+
+	e.GET("/api/v1/features", lumHandler.Features)
 
 	e.GET("/health", s.healthHandler)
 
+	s.registerAdminRoutes(e, lumHandler)
+
 	e.GET("/websocket", s.websocketHandler)
 
 	return e
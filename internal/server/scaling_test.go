@@ -0,0 +1,198 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"illuminate/internal/cache"
+	"illuminate/internal/cache/redistest"
+	"illuminate/internal/database"
+	"illuminate/internal/parser"
+	"illuminate/internal/synth"
+	"illuminate/internal/worker"
+)
+
+// newInstance starts one API instance on the database at dbPath, sharing
+// state through the Redis at redisURL, as NewServer would with
+// BLUEPRINT_DB_URL and REDIS_URL set.
+func newInstance(t *testing.T, dbPath, redisURL string) *url.URL {
+	t.Helper()
+	db, err := database.Open(dbPath, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared, err := cache.NewRedis(redisURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{
+		db:          db,
+		pool:        worker.NewPool(2, 8),
+		shared:      shared,
+		idempotency: idempotency{store: shared, ttl: time.Hour},
+		jobs:        newJobQueue(shared, nil),
+		events:      newEventHub(shared),
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	go s.jobs.consume(ctx, db.GetDB())
+	if err := s.events.listen(ctx); err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(s.RegisterRoutes())
+	t.Cleanup(func() {
+		ts.Close()
+		stop()
+		s.pool.Close()
+		db.Close()
+	})
+	u, _ := url.Parse(ts.URL)
+	return u
+}
+
+// TestTwoInstances runs two instances behind a round-robin proxy, so every
+// follow-up request lands on the other instance than the one before, and
+// checks that the upload handshake, idempotent retries, background runs
+// and live events still work.
+func TestTwoInstances(t *testing.T) {
+	srv, err := redistest.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	dbPath := filepath.Join(t.TempDir(), "catalog.db")
+	backends := []*url.URL{newInstance(t, dbPath, srv.URL()), newInstance(t, dbPath, srv.URL())}
+
+	var next atomic.Int64
+	proxy := httptest.NewServer(&httputil.ReverseProxy{Rewrite: func(r *httputil.ProxyRequest) {
+		r.SetURL(backends[next.Add(1)%int64(len(backends))])
+	}})
+	defer proxy.Close()
+
+	do := func(req *http.Request) (int, map[string]interface{}, http.Header) {
+		t.Helper()
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		var body map[string]interface{}
+		if err := json.Unmarshal(b, &body); err != nil {
+			t.Fatalf("%s %s: %s", req.Method, req.URL.Path, b)
+		}
+		return resp.StatusCode, body, resp.Header
+	}
+	upload := func(name, manufacturer, key string) (int, map[string]interface{}, http.Header) {
+		t.Helper()
+		lum, err := synth.Generate(synth.DefaultOptions())
+		if err != nil {
+			t.Fatal(err)
+		}
+		lum.Metadata.Manufacturer = manufacturer
+		lum.Metadata.Model = name
+		data, err := parser.Encode(parser.NewIESParser(), lum, parser.WriteOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body bytes.Buffer
+		w := multipart.NewWriter(&body)
+		part, _ := w.CreateFormFile("file", name+".ies")
+		part.Write(data)
+		w.Close()
+		req, _ := http.NewRequest(http.MethodPost, proxy.URL+"/api/v1/luminaires", &body)
+		req.Header.Set("Content-Type", w.FormDataContentType())
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		return do(req)
+	}
+
+	// The upload parks on one instance; its metadata arrives at the other.
+	_, parked, _ := upload("anonymous", "", "")
+	if parked["status"] != "metadata_required" {
+		t.Fatalf("upload without a manufacturer: %v", parked)
+	}
+	form := url.Values{
+		"file_hash":         {parked["file_hash"].(string)},
+		"original_filename": {"anonymous.ies"},
+		"manufacturer":      {"Acme"},
+	}
+	req, _ := http.NewRequest(http.MethodPost, proxy.URL+"/api/v1/luminaires/with-metadata", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if status, body, _ := do(req); status != http.StatusOK || body["status"] != "uploaded" {
+		t.Fatalf("metadata on the other instance: %d %v", status, body)
+	}
+
+	// Both instances see a record either of them stored.
+	_, first, _ := upload("DL-100", "Acme", "retry-1")
+	id := first["luminaire_id"]
+	for range backends {
+		req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/api/v1/luminaires/%v", proxy.URL, id), nil)
+		if status, body, _ := do(req); status != http.StatusOK {
+			t.Errorf("GET %v: %d %v", id, status, body)
+		}
+	}
+
+	// A retry on the other instance replays the first answer.
+	status, retry, header := upload("DL-100", "Acme", "retry-1")
+	if status != http.StatusOK || retry["luminaire_id"] != id || header.Get("Idempotent-Replayed") != "true" {
+		t.Errorf("retry = %d %v, want the replayed upload of %v", status, retry, id)
+	}
+
+	// A run started through one instance is taken from the shared queue.
+	req, _ = http.NewRequest(http.MethodPost, proxy.URL+"/api/v1/validation/runs", nil)
+	status, started, _ := do(req)
+	if status != http.StatusAccepted {
+		t.Fatalf("start run: %d %v", status, started)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/api/v1/validation/runs/%v", proxy.URL, started["run_id"]), nil)
+		_, run, _ := do(req)
+		if run["finished_at"] != "" {
+			if run["checked"] != float64(2) {
+				t.Errorf("run checked %v records, want 2", run["checked"])
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the run never finished")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// A client watching one instance hears of a deletion on the other.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	socket, _, err := websocket.Dial(ctx, "ws://"+backends[0].Host+"/api/v1/luminaires/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer socket.CloseNow()
+	req, _ = http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/api/v1/luminaires/%v", backends[1], id), nil)
+	if status, body, _ := do(req); status != http.StatusOK {
+		t.Fatalf("delete: %d %v", status, body)
+	}
+	_, data, err := socket.Read(ctx)
+	if err != nil {
+		t.Fatalf("waiting for the deletion: %v", err)
+	}
+	var ev catalogEvent
+	if err := json.Unmarshal(data, &ev); err != nil || ev.Type != eventDeleted || float64(ev.ID) != id {
+		t.Errorf("event %s, want the deletion of %v", data, id)
+	}
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+
+	"illuminate/internal/database"
+	"illuminate/internal/logger"
+	"illuminate/internal/samples"
+)
+
+// ErrCatalogNotEmpty is returned by Seed for a catalog that already has
+// luminaires, whose records the samples would mix with.
+var ErrCatalogNotEmpty = errors.New("the catalog already has luminaires")
+
+// Seed loads the bundled sample luminaires (see package samples) into an
+// empty catalog the way POST /api/v1/luminaires/import would, and returns
+// how many it stored.
+func Seed(ctx context.Context, db *sql.DB) (int, error) {
+	var n int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM luminaires WHERE `+database.NotDeleted).Scan(&n); err != nil {
+		return 0, err
+	}
+	if n > 0 {
+		return 0, ErrCatalogNotEmpty
+	}
+	rows, err := samples.Manifest()
+	if err != nil {
+		return 0, err
+	}
+
+	h := &LuminaireHandler{db: db}
+	seeded := 0
+	for _, row := range rows {
+		name := row.Get("filename")
+		data, err := samples.File(name)
+		if err != nil {
+			return seeded, err
+		}
+		if res := h.importFile(ctx, "", name, "", data, row); res["error"] != nil {
+			return seeded, fmt.Errorf("%s: %v", name, res["error"])
+		}
+		seeded++
+	}
+	return seeded, nil
+}
+
+// seedFromEnv seeds an empty catalog when SEED_SAMPLES is true, for demo
+// deployments; a catalog with luminaires is left as it is.
+func seedFromEnv(db *sql.DB) {
+	if os.Getenv("SEED_SAMPLES") != "true" {
+		return
+	}
+	n, err := Seed(context.Background(), db)
+	switch {
+	case errors.Is(err, ErrCatalogNotEmpty):
+		logger.Default.Info("SEED_SAMPLES: the catalog has luminaires, not seeding")
+	case err != nil:
+		logger.Default.Errorf("SEED_SAMPLES: %v", err)
+	default:
+		logger.Default.Infof("seeded %d sample luminaires", n)
+	}
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"illuminate/internal/samples"
+)
+
+func TestSeed(t *testing.T) {
+	h := newTestHandler(t)
+	rows, err := samples.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := Seed(context.Background(), h.db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(rows) {
+		t.Errorf("seeded %d luminaires, want %d", n, len(rows))
+	}
+
+	var formats, unnamed int
+	h.db.QueryRow(`SELECT COUNT(DISTINCT format_type) FROM luminaires`).Scan(&formats)
+	h.db.QueryRow(`SELECT COUNT(*) FROM luminaires WHERE manufacturer = '' OR model = '' OR catalog_number = ''`).Scan(&unnamed)
+	if formats != 5 {
+		t.Errorf("samples cover %d formats, want 5", formats)
+	}
+	if unnamed != 0 {
+		t.Errorf("%d samples lack a manufacturer, model or catalog number", unnamed)
+	}
+
+	if _, err := Seed(context.Background(), h.db); !errors.Is(err, ErrCatalogNotEmpty) {
+		t.Errorf("seeding again: err = %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
@@ -8,22 +9,81 @@ import (
 	"time"
 
 	_ "github.com/joho/godotenv/autoload"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
 
+	"illuminate/internal/cache"
 	"illuminate/internal/database"
+	"illuminate/internal/features"
+	"illuminate/internal/logger"
+	"illuminate/internal/worker"
 )
 
 type Server struct {
 	port int
 
-	db database.Service
+	// maxUploadSize is an echo body limit such as "32M"; empty disables it.
+	maxUploadSize string
+
+	// adminToken guards the diagnostics endpoints; empty disables them.
+	adminToken  string
+	enablePprof bool
+
+	cors middleware.CORSConfig
+
+	// ipExtractor finds the client address behind TRUSTED_PROXIES.
+	ipExtractor echo.IPExtractor
+
+	db         database.Service
+	pool       *worker.Pool
+	parseCache *cache.ParseCache
+
+	// shared is the Redis every instance uses for idempotency keys, rate
+	// limit counters, the job queue and catalog events; nil keeps them in
+	// memory.
+	shared      *cache.Redis
+	rateLimit   rateLimit
+	idempotency idempotency
+	jobs        *jobQueue
+	events      *eventHub
+
+	flags *features.Flags
 }
 
 func NewServer() *http.Server {
+	return NewServerWithDatabase(database.New())
+}
+
+// NewServerWithDatabase is NewServer on a database the caller opened, such
+// as the temporary one of an end-to-end test. The rest of the configuration
+// still comes from the environment.
+func NewServerWithDatabase(db database.Service) *http.Server {
 	port, _ := strconv.Atoi(os.Getenv("PORT"))
+	maxUploadSize := os.Getenv("MAX_UPLOAD_SIZE")
+	if maxUploadSize == "" {
+		maxUploadSize = "32M"
+	}
+	shared := cache.NewSharedFromEnv()
+	flags := features.NewFromEnv(db.GetDB())
 	NewServer := &Server{
-		port: port,
+		port:          port,
+		maxUploadSize: maxUploadSize,
+		adminToken:    os.Getenv("ADMIN_TOKEN"),
+		enablePprof:   os.Getenv("ENABLE_PPROF") == "true",
+		cors:          corsConfigFromEnv(),
+		ipExtractor:   ipExtractorFromEnv(),
+
+		db:         db,
+		pool:       worker.NewPoolFromEnv(),
+		parseCache: cache.NewParseCacheFromEnv(),
+
+		shared:      shared,
+		rateLimit:   rateLimitFromEnv(shared),
+		idempotency: idempotencyFromEnv(shared),
+		jobs:        newJobQueue(shared, ruleEnabled(flags, "")),
+		events:      newEventHub(shared),
 
-		db: database.New(),
+		flags: flags,
 	}
 
 	// Declare Server config
@@ -34,6 +94,18 @@ func NewServer() *http.Server {
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 30 * time.Second,
 	}
+	server.RegisterOnShutdown(NewServer.pool.Close)
+
+	seedFromEnv(NewServer.db.GetDB())
+	go backfillMetrics(NewServer.db.GetDB())
+	server.RegisterOnShutdown(scheduleRevalidation(NewServer.db.GetDB(), NewServer.jobs, revalidationIntervalFromEnv()))
+
+	ctx, stopJobs := context.WithCancel(context.Background())
+	go NewServer.jobs.consume(ctx, NewServer.db.GetDB())
+	if err := NewServer.events.listen(ctx); err != nil {
+		logger.Default.Errorf("events stay on this instance: %v", err)
+	}
+	server.RegisterOnShutdown(stopJobs)
 
 	return server
 }
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"crypto/ed25519"
+	"net/http"
+	"os"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/bundle"
+	"illuminate/internal/logger"
+)
+
+// signingKeyFromEnv loads the Ed25519 key in EXPORT_SIGNING_KEY_FILE. A key
+// that cannot be read is logged and exports stay unsigned.
+func signingKeyFromEnv() ed25519.PrivateKey {
+	path := os.Getenv("EXPORT_SIGNING_KEY_FILE")
+	if path == "" {
+		return nil
+	}
+	key, err := bundle.LoadKey(path)
+	if err != nil {
+		logger.Default.Errorf("EXPORT_SIGNING_KEY_FILE: %v; exports are not signed", err)
+		return nil
+	}
+	return key
+}
+
+// SigningKey returns the PEM public key that verifies signed exports:
+// GET /api/v1/export-signing-key.
+func (h *LuminaireHandler) SigningKey(c echo.Context) error {
+	if h.signingKey == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "exports are not signed"})
+	}
+	pub, err := bundle.PublicKeyPEM(h.signingKey)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.Blob(http.StatusOK, "application/x-pem-file", pub)
+}
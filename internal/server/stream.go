@@ -0,0 +1,75 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+	"illuminate/internal/logger"
+)
+
+// streamPageSize is how many rows Stream reads from the database at a time.
+const streamPageSize = 500
+
+// Stream writes luminaire metadata as newline-delimited JSON in id order,
+// reading the table one page at a time so memory stays flat however large the
+// catalog is. Clients resume an interrupted export with ?after=<last id> and
+// may cap the number of records with ?limit=. While exports are gated,
+// callers without a workflow role only see the records exports are open to.
+func (h *LuminaireHandler) Stream(c echo.Context) error {
+	var after int64
+	if v := c.QueryParam("after"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid after"})
+		}
+		after = n
+	}
+	limit := 0
+	if v := c.QueryParam("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return apiError(c, http.StatusBadRequest, "invalid_limit")
+		}
+		limit = n
+	}
+
+	cond, condArgs := h.visibleCondition(c)
+
+	resp := c.Response()
+	resp.Header().Set(echo.HeaderContentType, "application/x-ndjson")
+	resp.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(resp)
+
+	sent := 0
+	for limit == 0 || sent < limit {
+		pageSize := streamPageSize
+		if limit > 0 && limit-sent < pageSize {
+			pageSize = limit - sent
+		}
+		page, err := database.ListLuminairesAfter(h.readDB(), after, pageSize, cond, condArgs...)
+		if err != nil {
+			// The status line is already out; all that is left is to stop.
+			logger.Default.Errorf("stream luminaires after %d: %v", after, err)
+			return nil
+		}
+		for _, lum := range page {
+			if err := enc.Encode(lum); err != nil {
+				return nil
+			}
+		}
+		resp.Flush()
+		if err := c.Request().Context().Err(); err != nil {
+			return nil
+		}
+
+		sent += len(page)
+		if len(page) < pageSize {
+			break
+		}
+		after = page[len(page)-1].ID
+	}
+	return nil
+}
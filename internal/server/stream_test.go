@@ -0,0 +1,106 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+)
+
+func TestStreamKeysetPagination(t *testing.T) {
+	h := newTestHandler(t)
+	total := streamPageSize + 3
+	for i := 0; i < total; i++ {
+		saveSynth(t, h, fmt.Sprintf("stream-%d", i))
+	}
+
+	e := echo.New()
+	e.GET("/api/v1/luminaires/stream", h.Stream)
+
+	read := func(query string) []database.Luminaire {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/luminaires/stream?"+query, nil)
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, req)
+		if resp.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d", query, resp.Code)
+		}
+		if ct := resp.Header().Get(echo.HeaderContentType); ct != "application/x-ndjson" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		var out []database.Luminaire
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			var lum database.Luminaire
+			if err := json.Unmarshal(scanner.Bytes(), &lum); err != nil {
+				t.Fatalf("bad line %q: %v", scanner.Text(), err)
+			}
+			out = append(out, lum)
+		}
+		return out
+	}
+
+	all := read("")
+	if len(all) != total {
+		t.Fatalf("streamed %d records, want %d", len(all), total)
+	}
+	for i := 1; i < len(all); i++ {
+		if all[i].ID <= all[i-1].ID {
+			t.Fatalf("records out of id order at %d", i)
+		}
+	}
+
+	resumed := read(fmt.Sprintf("after=%d&limit=2", all[streamPageSize-1].ID))
+	if len(resumed) != 2 || resumed[0].ID != all[streamPageSize].ID {
+		t.Errorf("resume after %d returned %d records", all[streamPageSize-1].ID, len(resumed))
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/luminaires/stream?limit=0", nil)
+	resp := httptest.NewRecorder()
+	e.ServeHTTP(resp, req)
+	if resp.Code != http.StatusBadRequest {
+		t.Errorf("limit=0: status = %d, want 400", resp.Code)
+	}
+}
+
+func TestStreamHidesDrafts(t *testing.T) {
+	h := newTestHandler(t)
+	h.exportState = database.StateApproved
+	h.workflowTokens = map[string]database.Role{"ed": database.RoleEditor}
+	draft := saveSynth(t, h, "stream-draft")
+	published := saveSynth(t, h, "stream-published")
+	h.db.Exec(`UPDATE luminaires SET workflow_state = 'draft' WHERE id = ?`, draft)
+	h.db.Exec(`UPDATE luminaires SET workflow_state = 'published' WHERE id = ?`, published)
+
+	e := echo.New()
+	e.GET("/api/v1/luminaires/stream", h.Stream)
+	streamed := func(token string) []int64 {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/luminaires/stream", nil)
+		if token != "" {
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		}
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, req)
+		var ids []int64
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			var lum database.Luminaire
+			if err := json.Unmarshal(scanner.Bytes(), &lum); err != nil {
+				t.Fatalf("bad line %q: %v", scanner.Text(), err)
+			}
+			ids = append(ids, lum.ID)
+		}
+		return ids
+	}
+
+	if ids := streamed(""); len(ids) != 1 || ids[0] != published {
+		t.Errorf("anonymous stream = %v, want only %d", ids, published)
+	}
+	if ids := streamed("ed"); len(ids) != 2 {
+		t.Errorf("editor stream = %v, want both records", ids)
+	}
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// What an upload of a file whose luminaire is in the trash does, chosen
+// with trashed= once the upload has been answered 409 with status
+// "trashed": restore the luminaire, or remove it for good and store the
+// file as a new one.
+const (
+	trashedRestore = "restore"
+	trashedInsert  = "insert"
+)
+
+// trashLuminaire moves live luminaire id to the trash. It reports whether
+// there was one to move.
+func trashLuminaire(db *sql.DB, id int64) (bool, error) {
+	res, err := db.Exec(`
+		UPDATE luminaires SET deleted_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND deleted_at IS NULL`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// restoreLuminaire takes luminaire id out of the trash. It reports whether
+// it was there; records merged into another are not.
+func restoreLuminaire(db *sql.DB, id int64) (bool, error) {
+	res, err := db.Exec(`
+		UPDATE luminaires SET deleted_at = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND deleted_at IS NOT NULL AND merged_into IS NULL`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Restore takes a deleted luminaire out of the trash, with the records
+// merged into it. POST /api/v1/luminaires/:id/restore.
+func (h *LuminaireHandler) Restore(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_id")
+	}
+	restored, err := restoreLuminaire(h.db, id)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if !restored {
+		return apiError(c, http.StatusNotFound, "luminaire_not_in_trash")
+	}
+	h.events.publish(eventCreated, id)
+	return c.JSON(http.StatusOK, map[string]interface{}{"status": "restored", "luminaire_id": id})
+}
+
+// storedUpload answers an upload of the file with hash when it is already
+// on record: 409 with the luminaire holding it, or, when that luminaire is
+// in the trash, what choice says. Without a choice the client is asked to
+// make one. done is false when the upload is to be stored, because the
+// file is not on record or its trashed luminaire was removed to make way
+// for it.
+func (h *LuminaireHandler) storedUpload(lang, hash, choice string) (status int, body map[string]interface{}, done bool) {
+	id, trashed, ok, err := recordByHash(h.db, hash)
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{"error": err.Error()}, true
+	}
+	if !ok {
+		return 0, nil, false
+	}
+	if !trashed {
+		return http.StatusConflict, duplicateBody(lang, id), true
+	}
+
+	switch choice {
+	case trashedRestore:
+		if _, err := restoreLuminaire(h.db, id); err != nil {
+			return http.StatusInternalServerError, map[string]interface{}{"error": err.Error()}, true
+		}
+		uploadLog.Info("restored", "file_hash", hash, "luminaire_id", id)
+		h.events.publish(eventCreated, id)
+		return http.StatusOK, map[string]interface{}{"status": "restored", "luminaire_id": id}, true
+	case trashedInsert:
+		if err := deleteLuminaire(h.db, id); err != nil {
+			return http.StatusInternalServerError, map[string]interface{}{"error": err.Error()}, true
+		}
+		uploadLog.Info("trashed luminaire removed", "file_hash", hash, "luminaire_id", id)
+		return 0, nil, false
+	}
+	body = errorBody(lang, "upload_trashed", nil)
+	body["status"] = "trashed"
+	body["luminaire_id"] = id
+	body["choices"] = []string{trashedRestore, trashedInsert}
+	return http.StatusConflict, body, true
+}
+
+// trashedChoice reads trashed=, which must be empty, restore or insert.
+func trashedChoice(c echo.Context) (string, error) {
+	switch v := c.FormValue("trashed"); v {
+	case "", trashedRestore, trashedInsert:
+		return v, nil
+	default:
+		return "", errors.New("trashed must be restore or insert")
+	}
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/manifest"
+	"illuminate/internal/parser"
+	"illuminate/internal/synth"
+)
+
+// TestTrash deletes a luminaire into the trash and uploads its file again:
+// the client is asked to choose, and then restores the record or stores the
+// file as a new one.
+func TestTrash(t *testing.T) {
+	h := newTestHandler(t)
+	e := echo.New()
+	e.POST("/api/v1/luminaires", h.Upload)
+	e.GET("/api/v1/luminaires/:id", h.Get)
+	e.DELETE("/api/v1/luminaires/:id", h.Delete)
+	e.POST("/api/v1/luminaires/:id/restore", h.Restore)
+
+	lum, err := synth.Generate(synth.Options{Distribution: synth.Lambertian, Manufacturer: "Acme", Model: "Trash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := parser.Encode(parser.NewIESParser(), lum, parser.WriteOptions{})
+	type answer struct {
+		Status      string   `json:"status"`
+		LuminaireID int64    `json:"luminaire_id"`
+		Choices     []string `json:"choices"`
+	}
+	upload := func(trashed string) (int, answer) {
+		var body bytes.Buffer
+		w := multipart.NewWriter(&body)
+		part, _ := w.CreateFormFile("file", "trash.ies")
+		part.Write(data)
+		if trashed != "" {
+			w.WriteField("trashed", trashed)
+		}
+		w.Close()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/luminaires", &body)
+		req.Header.Set(echo.HeaderContentType, w.FormDataContentType())
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, req)
+		var out answer
+		json.Unmarshal(resp.Body.Bytes(), &out)
+		return resp.Code, out
+	}
+	do := func(method, target string) int {
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, httptest.NewRequest(method, target, nil))
+		return resp.Code
+	}
+
+	_, first := upload("")
+	id := first.LuminaireID
+	path := fmt.Sprintf("/api/v1/luminaires/%d", id)
+	if code := do(http.MethodDelete, path); code != http.StatusOK {
+		t.Fatalf("delete: %d", code)
+	}
+	if code := do(http.MethodGet, path); code != http.StatusNotFound {
+		t.Errorf("get trashed: %d, want 404", code)
+	}
+	if code := do(http.MethodDelete, path); code != http.StatusNotFound {
+		t.Errorf("delete trashed: %d, want 404", code)
+	}
+
+	if code, got := upload(""); code != http.StatusConflict || got.Status != "trashed" || got.LuminaireID != id || len(got.Choices) != 2 {
+		t.Errorf("upload trashed file: %d %+v", code, got)
+	}
+	if code, _ := upload("overwrite"); code != http.StatusBadRequest {
+		t.Errorf("trashed=overwrite: %d, want 400", code)
+	}
+	if res := h.importFile(context.Background(), "", "trash.ies", "", data, manifest.Row{}); res["status"] != "trashed" || res["luminaire_id"] != id {
+		t.Errorf("import trashed file: %v", res)
+	}
+
+	if code, got := upload(trashedRestore); code != http.StatusOK || got.Status != "restored" || got.LuminaireID != id {
+		t.Errorf("upload with trashed=restore: %d %+v", code, got)
+	}
+	if code := do(http.MethodGet, path); code != http.StatusOK {
+		t.Errorf("get restored: %d", code)
+	}
+	if code, got := upload(trashedRestore); code != http.StatusConflict || got.Status != "duplicate" {
+		t.Errorf("upload live file with trashed=restore: %d %+v", code, got)
+	}
+
+	do(http.MethodDelete, path)
+	if code := do(http.MethodPost, path+"/restore"); code != http.StatusOK {
+		t.Errorf("restore: %d", code)
+	}
+	if code := do(http.MethodPost, path+"/restore"); code != http.StatusNotFound {
+		t.Errorf("restore live luminaire: %d, want 404", code)
+	}
+
+	do(http.MethodDelete, path)
+	code, got := upload(trashedInsert)
+	if code != http.StatusOK || got.Status != "uploaded" || got.LuminaireID == id {
+		t.Errorf("upload with trashed=insert: %d %+v", code, got)
+	}
+	if code := do(http.MethodPost, path+"/restore"); code != http.StatusNotFound {
+		t.Errorf("restore replaced luminaire: %d, want 404", code)
+	}
+}
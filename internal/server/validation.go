@@ -0,0 +1,585 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+	"illuminate/internal/logger"
+	"illuminate/internal/parser"
+	"illuminate/internal/validate"
+)
+
+// validationChange is one record whose status changed in a validation run.
+type validationChange struct {
+	LuminaireID  int64            `json:"luminaire_id"`
+	Manufacturer string           `json:"manufacturer"`
+	Model        string           `json:"model"`
+	Previous     string           `json:"previous"`
+	Status       string           `json:"status"`
+	Issues       []validate.Issue `json:"issues"`
+}
+
+type queryExecer interface {
+	execer
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// Validation cache counters, reported by the admin stats.
+var validationCacheHits, validationCacheMisses atomic.Int64
+
+// saveValidation checks lum with rules and stores the result as its current
+// status.
+func saveValidation(db queryExecer, id int64, lum *database.ParsedLuminaire, rules validate.RuleFilter) (validate.Result, error) {
+	res, err := validateCached(db, lum.Metadata, rules, func() (*database.ParsedLuminaire, error) { return lum, nil })
+	if err != nil {
+		return res, err
+	}
+	return res, storeValidation(db, id, res)
+}
+
+func storeValidation(db execer, id int64, res validate.Result) error {
+	issues, _ := json.Marshal(res.Issues)
+	_, err := db.Exec(`
+		INSERT OR REPLACE INTO luminaire_validation (luminaire_id, status, issues, rules_version, quality_score, quality_grade, validated_at)
+		VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`,
+		id, res.Status, string(issues), res.RulesVersion, res.Score, res.Grade,
+	)
+	return err
+}
+
+// validateCached returns the cached result for this file, metadata and rule
+// set, or loads the luminaire, checks it with rules and caches the result.
+// The rules version in the key tells rule sets apart. Records without a file
+// hash are always checked.
+func validateCached(db queryExecer, meta database.Luminaire, rules validate.RuleFilter, load func() (*database.ParsedLuminaire, error)) (validate.Result, error) {
+	res := validate.Result{RulesVersion: validate.RulesVersion(rules)}
+	digest := validate.InputDigest(meta)
+	if meta.FileHash != "" {
+		var issues string
+		err := db.QueryRow(`
+			SELECT status, issues FROM validation_cache
+			WHERE file_hash = ? AND rules_version = ? AND input_digest = ?`,
+			meta.FileHash, res.RulesVersion, digest,
+		).Scan(&res.Status, &issues)
+		if err == nil && json.Unmarshal([]byte(issues), &res.Issues) == nil {
+			res.Score, res.Grade = validate.Quality(res.Issues)
+			validationCacheHits.Add(1)
+			return res, nil
+		}
+	}
+	validationCacheMisses.Add(1)
+
+	lum, err := load()
+	if err != nil {
+		return res, err
+	}
+	res = validate.Check(lum, rules)
+	if meta.FileHash != "" {
+		issues, _ := json.Marshal(res.Issues)
+		_, err = db.Exec(`
+			INSERT OR REPLACE INTO validation_cache (file_hash, rules_version, input_digest, status, issues)
+			VALUES (?, ?, ?, ?, ?)`,
+			meta.FileHash, res.RulesVersion, digest, res.Status, string(issues),
+		)
+	}
+	return res, err
+}
+
+// refreshValidation re-checks a stored luminaire with rules after its
+// metadata changed.
+func refreshValidation(db *sql.DB, id int64, rules validate.RuleFilter) error {
+	lum, err := database.LoadParsedLuminaire(db, id)
+	if err != nil {
+		return err
+	}
+	_, err = saveValidation(db, id, lum, rules)
+	return err
+}
+
+// revalidationIntervalFromEnv reads REVALIDATE_INTERVAL, a duration such as
+// "6h" (default 24h); zero disables the scheduled runs.
+func revalidationIntervalFromEnv() time.Duration {
+	interval, err := time.ParseDuration(os.Getenv("REVALIDATE_INTERVAL"))
+	if err != nil {
+		return 24 * time.Hour
+	}
+	return interval
+}
+
+// scheduleRevalidation re-validates the whole catalog every interval until
+// the returned stop function is called. A tick is skipped while another run
+// holds the lock in jobs, on this instance or any other sharing it.
+func scheduleRevalidation(db *sql.DB, jobs *jobQueue, interval time.Duration) (stop func()) {
+	if interval <= 0 {
+		return func() {}
+	}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				owner, ok, err := jobs.lock(context.Background(), jobRevalidate)
+				if err != nil || !ok {
+					continue
+				}
+				id, err := startValidationRun(db, jobs.rules)
+				if err == nil {
+					jobs.do(db, job{Kind: jobRevalidate, RunID: id, Owner: owner})
+				} else {
+					logger.Default.Errorf("scheduled revalidation: %v", err)
+				}
+				jobs.unlock(jobRevalidate, owner)
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+func startValidationRun(db *sql.DB, rules validate.RuleFilter) (int64, error) {
+	res, err := db.Exec(`INSERT INTO validation_runs (rules_version) VALUES (?)`, validate.RulesVersion(rules))
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// revalidateCatalog re-checks every luminaire with rules and
+// records in run runID the ones whose status changed. Records validated for
+// the first time are stored but not reported as changes. Cached results of
+// earlier rule sets are dropped; unchanged files under unchanged rules are
+// served from the cache without loading their photometric data.
+func revalidateCatalog(db *sql.DB, runID int64, rules validate.RuleFilter) error {
+	if _, err := db.Exec(`DELETE FROM validation_cache WHERE rules_version != ?`, validate.RulesVersion(rules)); err != nil {
+		return err
+	}
+
+	previous := map[int64]string{}
+	rows, err := db.Query(`SELECT luminaire_id, status FROM luminaire_validation`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var id int64
+		var status string
+		if rows.Scan(&id, &status) == nil {
+			previous[id] = status
+		}
+	}
+	rows.Close()
+
+	luminaires, err := database.ListLuminaires(db)
+	if err != nil {
+		return err
+	}
+
+	changes := []validationChange{}
+	checked := 0
+	for _, meta := range luminaires {
+		res, err := validateCached(db, meta, rules, func() (*database.ParsedLuminaire, error) {
+			return database.LoadParsedLuminaire(db, meta.ID)
+		})
+		if err != nil {
+			logger.Default.Warnf("revalidation: luminaire %d: %v", meta.ID, err)
+			continue
+		}
+		if err := storeValidation(db, meta.ID, res); err != nil {
+			return err
+		}
+		checked++
+		if p := previous[meta.ID]; p != "" && p != res.Status {
+			changes = append(changes, validationChange{
+				LuminaireID:  meta.ID,
+				Manufacturer: meta.Manufacturer,
+				Model:        meta.Model,
+				Previous:     p,
+				Status:       res.Status,
+				Issues:       res.Issues,
+			})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].LuminaireID < changes[j].LuminaireID })
+
+	report, _ := json.Marshal(changes)
+	_, err = db.Exec(`
+		UPDATE validation_runs SET finished_at = CURRENT_TIMESTAMP, checked = ?, changed = ?, report = ?
+		WHERE id = ?`, checked, len(changes), string(report), runID)
+	if err == nil {
+		logger.Default.Infof("revalidation run %d: checked %d, %d changed status", runID, checked, len(changes))
+	}
+	return err
+}
+
+// Validation returns the validation result of a luminaire under the rules
+// of the caller's organization, re-checking it when it was last validated
+// with other rules; ?format=sarif or junit returns it as a CI report on the
+// original file. Only a result under the deployment's rules is stored.
+func (h *LuminaireHandler) Validation(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_id")
+	}
+	format := c.QueryParam("format")
+	if !validReportFormat(format) {
+		return apiError(c, http.StatusBadRequest, "invalid_report_format")
+	}
+
+	rules := h.rules(organization(c))
+	var res validate.Result
+	var issues, validatedAt string
+	err = h.db.QueryRow(`
+		SELECT status, issues, rules_version, validated_at
+		FROM luminaire_validation WHERE luminaire_id = ?`, id,
+	).Scan(&res.Status, &issues, &res.RulesVersion, &validatedAt)
+	if err == nil && res.RulesVersion == validate.RulesVersion(rules) {
+		json.Unmarshal([]byte(issues), &res.Issues)
+		res.Score, res.Grade = validate.Quality(res.Issues)
+	} else {
+		lum, loadErr := database.LoadParsedLuminaire(h.db, id)
+		if errors.Is(loadErr, sql.ErrNoRows) {
+			return apiError(c, http.StatusNotFound, "luminaire_not_found")
+		}
+		if loadErr != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": loadErr.Error()})
+		}
+		if validate.RulesVersion(rules) == validate.RulesVersion(h.rules("")) {
+			res, err = saveValidation(h.db, id, lum, rules)
+		} else {
+			res, err = validateCached(h.db, lum.Metadata, rules, func() (*database.ParsedLuminaire, error) { return lum, nil })
+		}
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+	}
+	res.Issues = localizeIssues(language(c), res.Issues)
+
+	if format == "sarif" || format == "junit" {
+		var filename string
+		h.db.QueryRow(`SELECT original_filename FROM luminaires WHERE id = ? AND `+database.NotDeleted, id).Scan(&filename)
+		if filename == "" {
+			filename = fmt.Sprintf("luminaire-%d", id)
+		}
+		return validationReport(c, format, []validate.FileResult{{File: filename, Result: res}})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"luminaire_id": id,
+		"validation":   res,
+	})
+}
+
+func validReportFormat(format string) bool {
+	return format == "" || format == "json" || format == "sarif" || format == "junit"
+}
+
+// validationReport writes results as a SARIF or JUnit XML report for CI.
+func validationReport(c echo.Context, format string, results []validate.FileResult) error {
+	var buf bytes.Buffer
+	write, contentType := validate.WriteSARIF, "application/sarif+json"
+	if format == "junit" {
+		write, contentType = validate.WriteJUnit, echo.MIMEApplicationXMLCharsetUTF8
+	}
+	if err := write(&buf, results); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.Blob(http.StatusOK, contentType, buf.Bytes())
+}
+
+// StartValidationRun re-validates the catalog in the background and returns
+// the run to poll; 409 while another run is in progress.
+func (h *LuminaireHandler) StartValidationRun(c echo.Context) error {
+	jobs := h.jobQueue()
+	ctx := c.Request().Context()
+	owner, locked, err := jobs.lock(ctx, jobRevalidate)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if !locked {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "a validation run is already in progress"})
+	}
+	id, err := startValidationRun(h.db, jobs.rules)
+	if err == nil {
+		err = jobs.enqueue(ctx, h.db, job{Kind: jobRevalidate, RunID: id, Owner: owner})
+	}
+	if err != nil {
+		jobs.unlock(jobRevalidate, owner)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusAccepted, map[string]interface{}{
+		"status": "started",
+		"run_id": id,
+	})
+}
+
+// ListValidationRuns returns the most recent runs without their reports.
+func (h *LuminaireHandler) ListValidationRuns(c echo.Context) error {
+	rows, err := h.db.Query(`
+		SELECT id, rules_version, started_at, COALESCE(CAST(finished_at AS TEXT), ''), checked, changed
+		FROM validation_runs ORDER BY id DESC LIMIT 100`)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	defer rows.Close()
+
+	runs := []map[string]interface{}{}
+	for rows.Next() {
+		var id int64
+		var version, startedAt, finishedAt string
+		var checked, changed int
+		if err := rows.Scan(&id, &version, &startedAt, &finishedAt, &checked, &changed); err != nil {
+			continue
+		}
+		runs = append(runs, map[string]interface{}{
+			"id":            id,
+			"rules_version": version,
+			"started_at":    startedAt,
+			"finished_at":   finishedAt,
+			"checked":       checked,
+			"changed":       changed,
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"runs": runs,
+	})
+}
+
+// GetValidationRun returns one run with the records whose status changed.
+func (h *LuminaireHandler) GetValidationRun(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_id")
+	}
+
+	var version, startedAt, finishedAt, report string
+	var checked, changed int
+	err = h.db.QueryRow(`
+		SELECT rules_version, started_at, COALESCE(CAST(finished_at AS TEXT), ''), checked, changed, report
+		FROM validation_runs WHERE id = ?`, id,
+	).Scan(&version, &startedAt, &finishedAt, &checked, &changed, &report)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apiError(c, http.StatusNotFound, "run_not_found")
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"id":            id,
+		"rules_version": version,
+		"started_at":    startedAt,
+		"finished_at":   finishedAt,
+		"checked":       checked,
+		"changed":       changed,
+		"changes":       json.RawMessage(report),
+	})
+}
+
+// batchValidation is the validation report of one file in a batch.
+type batchValidation struct {
+	Filename     string `json:"filename"`
+	Format       string `json:"format,omitempty"`
+	Manufacturer string `json:"manufacturer,omitempty"`
+	Model        string `json:"model,omitempty"`
+	validate.Result
+	// Error is set, and Status invalid, when the file could not be read.
+	Error string `json:"error,omitempty"`
+}
+
+// batchIssueCount is how often a rule raised issues of a severity.
+type batchIssueCount struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Files    int    `json:"files"`
+}
+
+// batchRuleFormat reports a batch file in no supported format; files that
+// fail to parse are reported under validate.RuleParse.
+const batchRuleFormat = "format"
+
+// ValidateBatch checks every photometric file of an uploaded "archive" ZIP
+// without storing anything: POST /api/v1/validate/batch. Files are parsed
+// and given the import profile as an upload would be. The summary has the
+// count per status, the pass rate (files without errors over all files) and
+// the rules that failed most files, with unreadable files counted under the
+// "format" and "parse" rules. ?format=sarif or junit returns the per-file
+// results as a CI report instead. ?parser=ldt reads every file as that
+// format, whatever its extension.
+func (h *LuminaireHandler) ValidateBatch(c echo.Context) error {
+	format := c.QueryParam("format")
+	if !validReportFormat(format) {
+		return apiError(c, http.StatusBadRequest, "invalid_report_format")
+	}
+	sourceFormat, err := readerOverride(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	archiveFile, err := c.FormFile("archive")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "archive_required")
+	}
+	archive, err := readFormFile(archiveFile)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid archive: %v", err)})
+	}
+	var entries []*zip.File
+	for _, f := range zr.File {
+		if !f.FileInfo().IsDir() && !strings.HasPrefix(path.Base(f.Name), ".") {
+			entries = append(entries, f)
+		}
+	}
+
+	ctx := c.Request().Context()
+	org := organization(c)
+	rules := h.rules(org)
+	reports := make([]batchValidation, len(entries))
+	group := h.pool.Group(h.batchConcurrency)
+	for i, entry := range entries {
+		err := group.Go(ctx, func() {
+			reports[i] = h.validateArchiveEntry(ctx, org, entry, sourceFormat)
+		})
+		if err != nil {
+			reports[i] = failedBatchValidation(entry.Name, validate.RuleParse, err, rules)
+		}
+	}
+	group.Wait()
+	lang := language(c)
+	for i := range reports {
+		reports[i].Issues = localizeIssues(lang, reports[i].Issues)
+	}
+
+	statuses := map[string]int{validate.StatusValid: 0, validate.StatusWarning: 0, validate.StatusInvalid: 0}
+	type ruleSeverity struct{ rule, severity string }
+	files := map[ruleSeverity]int{}
+	for _, r := range reports {
+		statuses[r.Status]++
+		seen := map[ruleSeverity]bool{}
+		for _, issue := range r.Issues {
+			key := ruleSeverity{issue.Rule, issue.Severity}
+			if !seen[key] {
+				seen[key] = true
+				files[key]++
+			}
+		}
+	}
+	common := []batchIssueCount{}
+	for key, n := range files {
+		common = append(common, batchIssueCount{Rule: key.rule, Severity: key.severity, Files: n})
+	}
+	sort.Slice(common, func(i, j int) bool {
+		a, b := common[i], common[j]
+		if a.Files != b.Files {
+			return a.Files > b.Files
+		}
+		if a.Severity != b.Severity {
+			return a.Severity == validate.SeverityError
+		}
+		return a.Rule < b.Rule
+	})
+	passRate := 0.0
+	if len(reports) > 0 {
+		passRate = float64(len(reports)-statuses[validate.StatusInvalid]) / float64(len(reports))
+	}
+
+	if format == "sarif" || format == "junit" {
+		results := make([]validate.FileResult, len(reports))
+		for i, r := range reports {
+			results[i] = validate.FileResult{File: r.Filename, Result: r.Result}
+		}
+		return validationReport(c, format, results)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"files": reports,
+		"summary": map[string]interface{}{
+			"files":         len(reports),
+			"statuses":      statuses,
+			"pass_rate":     passRate,
+			"common_issues": common,
+			"rules_version": validate.RulesVersion(rules),
+		},
+	})
+}
+
+// validateArchiveEntry parses and checks one file of a batch, as
+// sourceFormat when set, with the feature flags and rules of organization.
+func (h *LuminaireHandler) validateArchiveEntry(ctx context.Context, organization string, entry *zip.File, sourceFormat string) batchValidation {
+	rules := h.rules(organization)
+	name := path.Base(entry.Name)
+	sourceName := name
+	if sourceFormat != "" {
+		sourceName = strings.TrimSuffix(name, path.Ext(name)) + "." + sourceFormat
+	}
+	p, err := parser.GetReader(sourceName)
+	if err == nil {
+		err = checkReader(h.flags, organization, sourceName)
+	}
+	if err != nil {
+		return failedBatchValidation(entry.Name, batchRuleFormat, err, rules)
+	}
+	data, err := readArchiveEntry(entry)
+	if err != nil {
+		return failedBatchValidation(entry.Name, validate.RuleParse, err, rules)
+	}
+	lum, err := h.cache.Parse(ctx, p, data, name)
+	if err != nil {
+		return failedBatchValidation(entry.Name, validate.RuleParse, err, rules)
+	}
+	lum.Metadata.OriginalFilename = name
+	lum.Metadata.FormatType = parser.DetectFormat(sourceName)
+	h.applyImportProfile(organization, &lum.Metadata)
+
+	return batchValidation{
+		Filename:     entry.Name,
+		Format:       lum.Metadata.FormatType,
+		Manufacturer: lum.Metadata.Manufacturer,
+		Model:        lum.Metadata.Model,
+		Result:       validate.Check(lum, rules),
+	}
+}
+
+// failedBatchValidation reports a file that could not be checked as invalid,
+// with the failure as an error of rule.
+func failedBatchValidation(name, rule string, err error, rules validate.RuleFilter) batchValidation {
+	res := validate.ParseFailure(err, rules)
+	res.Issues[0].Rule = rule
+	return batchValidation{Filename: name, Result: res, Error: err.Error()}
+}
+
+// inconsistentCondition matches luminaires whose stored validation has an
+// issue from one of validate.ConsistencyRules.
+func inconsistentCondition() (string, []interface{}) {
+	args := make([]interface{}, len(validate.ConsistencyRules))
+	for i, rule := range validate.ConsistencyRules {
+		args[i] = rule
+	}
+	marks := strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ")
+	return `EXISTS (
+		SELECT 1 FROM luminaire_validation v, json_each(v.issues) i
+		WHERE v.luminaire_id = luminaires.id AND json_extract(i.value, '$.rule') IN (` + marks + `))`, args
+}
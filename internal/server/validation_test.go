@@ -0,0 +1,317 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+	"illuminate/internal/parser"
+	"illuminate/internal/synth"
+	"illuminate/internal/validate"
+	"illuminate/internal/worker"
+)
+
+func TestRevalidationReportsChangedRecords(t *testing.T) {
+	h := newTestHandler(t)
+	var ids []int64
+	for i, watts := range []float64{10, 40} {
+		opts := synth.DefaultOptions()
+		opts.InputWatts = watts
+		lum, err := synth.Generate(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lum.Metadata.FileHash = fmt.Sprintf("validation-%d", i)
+		lum.Metadata.CatalogNumber = "SYN"
+		id, err := h.saveLuminaire(lum)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	e := echo.New()
+	e.GET("/api/v1/luminaires/:id/validation", h.Validation)
+	e.GET("/api/v1/validation/runs/:id", h.GetValidationRun)
+	status := func(id int64) string {
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/luminaires/%d/validation", id), nil))
+		var body struct {
+			Validation validate.Result `json:"validation"`
+		}
+		json.Unmarshal(resp.Body.Bytes(), &body)
+		return body.Validation.Status
+	}
+	if got := status(ids[1]); got != validate.StatusValid {
+		t.Fatalf("status before the rule change = %q", got)
+	}
+
+	// A new rule rejecting anything above 30 W turns the second record invalid.
+	saved := validate.Rules
+	t.Cleanup(func() { validate.Rules = saved })
+	validate.Rules = append(append([]validate.Rule(nil), saved...), validate.Rule{
+		Name: "max_watts", Version: 1,
+		Check: func(lum *database.ParsedLuminaire) []validate.Issue {
+			if lum.Metadata.InputWatts > 30 {
+				return []validate.Issue{{Severity: validate.SeverityError, Message: "over 30 W"}}
+			}
+			return nil
+		},
+	})
+
+	runID, err := startValidationRun(h.db, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := revalidateCatalog(h.db, runID, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := httptest.NewRecorder()
+	e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/validation/runs/%d", runID), nil))
+	var run struct {
+		RulesVersion string             `json:"rules_version"`
+		Checked      int                `json:"checked"`
+		Changes      []validationChange `json:"changes"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &run)
+	if run.Checked != 2 || len(run.Changes) != 1 || run.RulesVersion != validate.RulesVersion(nil) {
+		t.Fatalf("run = %s", resp.Body.String())
+	}
+	if c := run.Changes[0]; c.LuminaireID != ids[1] || c.Previous != validate.StatusValid || c.Status != validate.StatusInvalid {
+		t.Errorf("change = %+v", c)
+	}
+	if got := status(ids[1]); got != validate.StatusInvalid {
+		t.Errorf("status after the run = %q", got)
+	}
+
+	// The stored grade follows the run: one error is a C.
+	e.GET("/api/v1/luminaires", h.List)
+	list := func(query string) []map[string]interface{} {
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/api/v1/luminaires?"+query, nil))
+		if resp.Code != http.StatusOK {
+			t.Fatalf("list %s: %d %s", query, resp.Code, resp.Body.String())
+		}
+		var body struct {
+			Luminaires []map[string]interface{} `json:"luminaires"`
+		}
+		json.Unmarshal(resp.Body.Bytes(), &body)
+		return body.Luminaires
+	}
+	for query, want := range map[string]int64{
+		"quality=a":                          ids[0],
+		"filter=quality%3DC":                 ids[1],
+		"filter=quality_score%3D60..75":      ids[1],
+		"quality=B&filter=quality_score%3E0": ids[0],
+	} {
+		rows := list(query)
+		if len(rows) != 1 || int64(rows[0]["id"].(float64)) != want {
+			t.Errorf("%s = %v, want luminaire %d", query, rows, want)
+		}
+	}
+	if rows := list("quality=C"); len(rows) != 2 || rows[0]["quality_grade"] == nil {
+		t.Errorf("quality=C = %v", rows)
+	}
+}
+
+func TestValidationCache(t *testing.T) {
+	h := newTestHandler(t)
+	saveSynth(t, h, "validation-cache")
+
+	run := func() (hits, misses int64) {
+		t.Helper()
+		h0, m0 := validationCacheHits.Load(), validationCacheMisses.Load()
+		id, err := startValidationRun(h.db, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := revalidateCatalog(h.db, id, nil); err != nil {
+			t.Fatal(err)
+		}
+		return validationCacheHits.Load() - h0, validationCacheMisses.Load() - m0
+	}
+
+	if hits, misses := run(); hits != 1 || misses != 0 {
+		t.Errorf("unchanged file and rules: %d hits, %d misses", hits, misses)
+	}
+
+	saved := validate.Rules
+	t.Cleanup(func() { validate.Rules = saved })
+	validate.Rules = append([]validate.Rule(nil), saved...)
+	validate.Rules[0].Version++
+	if hits, misses := run(); hits != 0 || misses != 1 {
+		t.Errorf("after a rule change: %d hits, %d misses", hits, misses)
+	}
+	var stale int
+	h.db.QueryRow(`SELECT COUNT(*) FROM validation_cache WHERE rules_version != ?`, validate.RulesVersion(nil)).Scan(&stale)
+	if stale != 0 {
+		t.Errorf("%d results of the old rules left in the cache", stale)
+	}
+
+	h.db.Exec(`UPDATE luminaires SET model = 'renamed'`)
+	if hits, misses := run(); hits != 0 || misses != 1 {
+		t.Errorf("after a metadata edit: %d hits, %d misses", hits, misses)
+	}
+}
+
+func TestValidateBatch(t *testing.T) {
+	h := newTestHandler(t)
+	h.pool = worker.NewPool(2, 4)
+	defer h.pool.Close()
+
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	for _, name := range []string{"vendor/DL200.ies", "vendor/DL300.ldt"} {
+		lum, err := synth.Generate(synth.DefaultOptions())
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, _ := parser.GetParser(name)
+		data, err := parser.Encode(p, lum, parser.WriteOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		w, _ := zw.Create(name)
+		w.Write(data)
+	}
+	w, _ := zw.Create("vendor/broken.ies")
+	w.Write([]byte("IESNA:LM-63-2002\nno data\n"))
+	w, _ = zw.Create("vendor/readme.txt")
+	w.Write([]byte("photometry for DL200 and DL300"))
+	zw.Close()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile("archive", "submission.zip")
+	part.Write(archive.Bytes())
+	mw.Close()
+
+	e := echo.New()
+	e.POST("/api/v1/validate/batch", h.ValidateBatch)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/validate/batch", &body)
+	req.Header.Set(echo.HeaderContentType, mw.FormDataContentType())
+	resp := httptest.NewRecorder()
+	e.ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", resp.Code, resp.Body.String())
+	}
+
+	var result struct {
+		Files   []batchValidation `json:"files"`
+		Summary struct {
+			Files        int               `json:"files"`
+			Statuses     map[string]int    `json:"statuses"`
+			PassRate     float64           `json:"pass_rate"`
+			CommonIssues []batchIssueCount `json:"common_issues"`
+		} `json:"summary"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &result)
+	if s := result.Summary; s.Files != 4 || s.Statuses[validate.StatusInvalid] != 2 || s.PassRate != 0.5 {
+		t.Errorf("summary = %+v", s)
+	}
+	rules := map[string]int{}
+	for _, c := range result.Summary.CommonIssues {
+		rules[c.Rule] = c.Files
+	}
+	// Both synthetic files lack a catalog number.
+	if rules["identity"] != 2 || rules["parse"] != 1 || rules["format"] != 1 || result.Summary.CommonIssues[0].Rule != "identity" {
+		t.Errorf("common issues = %+v", result.Summary.CommonIssues)
+	}
+	for _, f := range result.Files {
+		if f.Filename == "vendor/DL300.ldt" && (f.Format == "" || f.Status == validate.StatusInvalid) {
+			t.Errorf("DL300 = %+v", f)
+		}
+	}
+
+	body.Reset()
+	mw = multipart.NewWriter(&body)
+	part, _ = mw.CreateFormFile("archive", "submission.zip")
+	part.Write(archive.Bytes())
+	mw.Close()
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/validate/batch?format=junit", &body)
+	req.Header.Set(echo.HeaderContentType, mw.FormDataContentType())
+	resp = httptest.NewRecorder()
+	e.ServeHTTP(resp, req)
+	if !strings.Contains(resp.Header().Get(echo.HeaderContentType), "xml") || !strings.Contains(resp.Body.String(), `tests="4" failures="0" errors="2"`) {
+		t.Errorf("junit = %s", resp.Body.String())
+	}
+
+	var stored int
+	h.db.QueryRow(`SELECT COUNT(*) FROM luminaires`).Scan(&stored)
+	if stored != 0 {
+		t.Errorf("batch validation stored %d luminaires", stored)
+	}
+}
+
+// TestUploadInconsistencies uploads an EULUMDAT file whose light output
+// ratio does not match its intensities, and a consistent IES file, and
+// finds only the first with ?inconsistent=true.
+func TestUploadInconsistencies(t *testing.T) {
+	h := newTestHandler(t)
+	e := echo.New()
+	e.POST("/api/v1/luminaires", h.Upload)
+	e.GET("/api/v1/luminaires", h.List)
+
+	upload := func(name string, p parser.Parser, lum *database.ParsedLuminaire) map[string]json.RawMessage {
+		t.Helper()
+		data, err := parser.Encode(p, lum, parser.WriteOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body bytes.Buffer
+		w := multipart.NewWriter(&body)
+		part, _ := w.CreateFormFile("file", name)
+		part.Write(data)
+		w.Close()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/luminaires", &body)
+		req.Header.Set(echo.HeaderContentType, w.FormDataContentType())
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, req)
+		var result map[string]json.RawMessage
+		if err := json.Unmarshal(resp.Body.Bytes(), &result); err != nil || resp.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d: %s", name, resp.Code, resp.Body.String())
+		}
+		return result
+	}
+
+	lum, err := synth.Generate(synth.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := upload("consistent.ies", parser.NewIESParser(), lum); result["inconsistencies"] != nil {
+		t.Errorf("consistent file: %s", result["inconsistencies"])
+	}
+	lum.Metadata.Model = "DL-200"
+	lum.Extensions = database.Extensions{"ldt:light_output_ratio": "60"}
+	result := upload("lor.ldt", parser.NewLDTParser(), lum)
+	var issues []validate.Issue
+	json.Unmarshal(result["inconsistencies"], &issues)
+	if len(issues) != 1 || !strings.Contains(issues[0].Message, "light output ratio of 60%") {
+		t.Errorf("inconsistencies = %s", result["inconsistencies"])
+	}
+
+	for query, want := range map[string]string{"true": "DL-200", "false": lum.Metadata.Manufacturer} {
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/api/v1/luminaires?inconsistent="+query, nil))
+		var list struct {
+			Luminaires []map[string]interface{} `json:"luminaires"`
+		}
+		json.Unmarshal(resp.Body.Bytes(), &list)
+		if len(list.Luminaires) != 1 {
+			t.Errorf("inconsistent=%s: %s", query, resp.Body.String())
+			continue
+		}
+		if row := list.Luminaires[0]; row["model"] != want && row["manufacturer"] != want {
+			t.Errorf("inconsistent=%s listed %v", query, row)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+)
+
+// Conditions, components and orientations are variants of a luminaire:
+// further photometry stored with it under a name, see database.variantStore.
+// Their handlers share what follows.
+
+// variantName is the form of a variant name: it appears in URLs and query
+// strings, so it is kept to a short token such as "40C", "up" or "tilt30".
+var variantName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,39}$`)
+
+// validVariantName reports whether name can name a variant, other than
+// the reserved names, which exports give a meaning of their own.
+func validVariantName(name string, reserved ...string) bool {
+	return variantName.MatchString(name) && !slices.Contains(reserved, name)
+}
+
+// formNumber reads the optional form value key as a number within
+// [min, max]; nil when absent.
+func formNumber(c echo.Context, key string, min, max float64) (*float64, error) {
+	v := strings.TrimSpace(c.FormValue(key))
+	if v == "" {
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || math.IsNaN(f) || f < min || f > max {
+		return nil, &codedError{"value_out_of_range", map[string]string{
+			"field": key, "min": strconv.FormatFloat(min, 'g', -1, 64), "max": strconv.FormatFloat(max, 'g', -1, 64),
+		}}
+	}
+	return &f, nil
+}
+
+// listVariants answers the variants of luminaire :id that list returns,
+// under key.
+func (h *LuminaireHandler) listVariants(c echo.Context, key string, list func(db *sql.DB, id int64) (interface{}, error)) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_id")
+	}
+	var exists int
+	if err := h.db.QueryRow(`SELECT COUNT(*) FROM luminaires WHERE id = ? AND `+database.NotDeleted, id).Scan(&exists); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if exists == 0 {
+		return apiError(c, http.StatusNotFound, "luminaire_not_found")
+	}
+
+	variants, err := list(h.db, id)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		key: variants,
+	})
+}
+
+// deleteVariant removes variant :name of luminaire :id with remove, which
+// returns notFound when there is none; code is the message for that.
+func (h *LuminaireHandler) deleteVariant(c echo.Context, remove func(db *sql.DB, id int64, name string) error, notFound error, code string) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_id")
+	}
+	err = remove(h.db, id, c.Param("name"))
+	if errors.Is(err, notFound) {
+		return apiError(c, http.StatusNotFound, code)
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// parseVariant reads a variant file with the reader req selects; its
+// candela values must match its angles.
+func parseVariant(req *conversionRequest, file *multipart.FileHeader) (*database.ParsedLuminaire, int, error) {
+	p, err := req.reader(file.Filename)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	src, err := file.Open()
+	if err != nil {
+		return nil, http.StatusInternalServerError, &codedError{code: "file_unreadable"}
+	}
+	defer src.Close()
+	lum, err := p.ParseReader(src, file.Filename)
+	if err != nil {
+		return nil, parseErrorStatus(err), &codedError{"parse_error", map[string]string{"detail": err.Error()}}
+	}
+	if len(lum.HorizontalAngles) > 0 {
+		if err := lum.CheckShape(); err != nil {
+			return nil, http.StatusUnprocessableEntity, &codedError{"malformed_photometry", map[string]string{"detail": err.Error()}}
+		}
+	}
+	return lum, http.StatusOK, nil
+}
+
+// typeMismatch refuses a variant whose photometric type is not the
+// luminaire's.
+func typeMismatch(lum *database.ParsedLuminaire, photometricType database.PhotometricType) error {
+	if lum.Metadata.PhotometricType == photometricType {
+		return nil
+	}
+	return &codedError{"photometric_type_mismatch", map[string]string{
+		"file": strconv.Itoa(int(lum.Metadata.PhotometricType)), "luminaire": strconv.Itoa(int(photometricType)),
+	}}
+}
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/labstack/echo/v4"
+)
+
+// putVariant stores variant name of luminaire id under kind (conditions,
+// components or orientations), with the IES file data unless data is nil.
+func putVariant(e *echo.Echo, kind string, id int64, name string, data []byte, fields map[string]string) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for k, v := range fields {
+		w.WriteField(k, v)
+	}
+	if data != nil {
+		part, _ := w.CreateFormFile("file", name+".ies")
+		part.Write(data)
+	}
+	w.Close()
+	req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/api/v1/luminaires/%d/%s/%s", id, kind, name), &body)
+	req.Header.Set(echo.HeaderContentType, w.FormDataContentType())
+	resp := httptest.NewRecorder()
+	e.ServeHTTP(resp, req)
+	return resp
+}
@@ -0,0 +1,251 @@
+package server
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+	"illuminate/internal/logger"
+)
+
+// workflowTokensFromEnv maps the bearer tokens in EDITOR_TOKEN,
+// REVIEWER_TOKEN and PUBLISHER_TOKEN to their roles; ADMIN_TOKEN acts as a
+// publisher. With none set the workflow is open and every caller is a
+// publisher.
+func workflowTokensFromEnv() map[string]database.Role {
+	tokens := map[string]database.Role{}
+	for _, env := range []struct {
+		key  string
+		role database.Role
+	}{
+		{"EDITOR_TOKEN", database.RoleEditor},
+		{"REVIEWER_TOKEN", database.RoleReviewer},
+		{"PUBLISHER_TOKEN", database.RolePublisher},
+		{"ADMIN_TOKEN", database.RolePublisher},
+	} {
+		if token := os.Getenv(env.key); token != "" {
+			tokens[token] = env.role
+		}
+	}
+	return tokens
+}
+
+// callerRole returns the workflow role of the request's bearer token.
+func (h *LuminaireHandler) callerRole(c echo.Context) database.Role {
+	if len(h.workflowTokens) == 0 {
+		return database.RolePublisher
+	}
+	key, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+	if !ok {
+		return database.RoleNone
+	}
+	role := database.RoleNone
+	for token, r := range h.workflowTokens {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(token)) == 1 {
+			role = r
+		}
+	}
+	return role
+}
+
+// exportStateFromEnv reads EXPORT_REQUIRE_STATE, the least workflow state a
+// record needs to be exported, usually approved or published.
+// REQUIRE_APPROVAL=true is short for approved. Unset leaves exports open; an
+// invalid value is logged and only published records are exported.
+func exportStateFromEnv() database.WorkflowState {
+	if v := os.Getenv("EXPORT_REQUIRE_STATE"); v != "" {
+		state, err := database.ParseWorkflowState(v)
+		if err != nil {
+			logger.Default.Warnf("EXPORT_REQUIRE_STATE: %v", err)
+			return database.StatePublished
+		}
+		return state
+	}
+	if os.Getenv("REQUIRE_APPROVAL") == "true" {
+		return database.StateApproved
+	}
+	return ""
+}
+
+// recordState returns the workflow state of luminaire id, or sql.ErrNoRows
+// when there is no such record or it was merged away.
+func (h *LuminaireHandler) recordState(id int64) (database.WorkflowState, error) {
+	var state database.WorkflowState
+	err := h.db.QueryRow(`SELECT COALESCE(workflow_state, 'published') FROM luminaires WHERE id = ? AND `+database.NotDeleted, id).Scan(&state)
+	return state, err
+}
+
+// exportBlocked reports whether a record in state is kept out of exports.
+func (h *LuminaireHandler) exportBlocked(state database.WorkflowState) bool {
+	return !state.AtLeast(h.exportState)
+}
+
+// hiddenFrom reports whether a record in state is kept from the caller of
+// c. While exports are gated, callers without a workflow role only read the
+// records exports are open to.
+func (h *LuminaireHandler) hiddenFrom(c echo.Context, state database.WorkflowState) bool {
+	return h.exportBlocked(state) && h.callerRole(c) == database.RoleNone
+}
+
+// visibleCondition narrows a luminaires query to the records the caller of
+// c may read; "" when it may read all of them.
+func (h *LuminaireHandler) visibleCondition(c echo.Context) (string, []interface{}) {
+	if h.exportState == "" || h.callerRole(c) != database.RoleNone {
+		return "", nil
+	}
+	var args []interface{}
+	for _, state := range []database.WorkflowState{database.StateDraft, database.StateInReview, database.StateApproved, database.StatePublished} {
+		if !h.exportBlocked(state) {
+			args = append(args, state)
+		}
+	}
+	marks := strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ")
+	return `COALESCE(workflow_state, 'published') IN (` + marks + `)`, args
+}
+
+// findLuminaires is database.FindLuminaires over the records the caller of c
+// may read.
+func (h *LuminaireHandler) findLuminaires(c echo.Context, db *sql.DB, f *database.Filter) ([]database.Luminaire, error) {
+	cond, args := h.visibleCondition(c)
+	return database.FindLuminairesWhere(db, f, cond, args...)
+}
+
+func exportBlockedResponse(c echo.Context, state, need database.WorkflowState) error {
+	return c.JSON(http.StatusForbidden, map[string]string{"error": fmt.Sprintf("luminaire is %s; exports need %s", state, need)})
+}
+
+// workflowEvent is one recorded state change.
+type workflowEvent struct {
+	From      database.WorkflowState `json:"from"`
+	To        database.WorkflowState `json:"to"`
+	Role      string                 `json:"role"`
+	Comment   string                 `json:"comment,omitempty"`
+	CreatedAt string                 `json:"created_at"`
+}
+
+// GetState returns the workflow state of a luminaire and its history,
+// oldest first: GET /api/v1/luminaires/:id/state.
+func (h *LuminaireHandler) GetState(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_id")
+	}
+
+	var state database.WorkflowState
+	err = h.db.QueryRow(`SELECT workflow_state FROM luminaires WHERE id = ? AND `+database.NotDeleted, id).Scan(&state)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apiError(c, http.StatusNotFound, "luminaire_not_found")
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	rows, err := h.db.Query(`
+		SELECT from_state, to_state, role, comment, created_at
+		FROM workflow_events WHERE luminaire_id = ? ORDER BY id`, id)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	defer rows.Close()
+
+	history := []workflowEvent{}
+	for rows.Next() {
+		var e workflowEvent
+		if err := rows.Scan(&e.From, &e.To, &e.Role, &e.Comment, &e.CreatedAt); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		history = append(history, e)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"id":      id,
+		"state":   state,
+		"history": history,
+	})
+}
+
+// Transition moves a luminaire to another workflow state from a JSON body
+// such as {"state": "approved", "comment": "checked against LM-79 report"}:
+// POST /api/v1/luminaires/:id/state. Editors submit drafts for review,
+// reviewers approve or reject them and publishers publish approved records.
+func (h *LuminaireHandler) Transition(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_id")
+	}
+
+	var body struct {
+		State   string `json:"state"`
+		Comment string `json:"comment"`
+	}
+	if err := json.NewDecoder(c.Request().Body).Decode(&body); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid transition: %v", err)})
+	}
+	to, err := database.ParseWorkflowState(body.State)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	role := h.callerRole(c)
+	if role == database.RoleNone {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "a workflow token is required"})
+	}
+
+	var from database.WorkflowState
+	err = h.db.QueryRow(`SELECT workflow_state FROM luminaires WHERE id = ? AND `+database.NotDeleted, id).Scan(&from)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apiError(c, http.StatusNotFound, "luminaire_not_found")
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	need, err := database.TransitionRole(from, to)
+	if err != nil {
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	}
+	if role < need {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": fmt.Sprintf("moving from %s to %s needs the %s role", from, to, need)})
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	defer tx.Rollback()
+
+	// The state is checked again so that concurrent transitions cannot both
+	// start from the same state.
+	res, err := tx.Exec(`UPDATE luminaires SET workflow_state = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND workflow_state = ?`, to, id, from)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "state changed concurrently; reload and retry"})
+	}
+	_, err = tx.Exec(`
+		INSERT INTO workflow_events (luminaire_id, from_state, to_state, role, comment)
+		VALUES (?, ?, ?, ?, ?)`,
+		id, from, to, role.String(), strings.TrimSpace(body.Comment),
+	)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if err := tx.Commit(); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	h.events.publish(eventUpdated, id)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"id":    id,
+		"from":  from,
+		"state": to,
+	})
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"illuminate/internal/database"
+)
+
+func TestWorkflow(t *testing.T) {
+	t.Setenv("PUBLIC_URL", "https://catalog.example.com")
+	h := newTestHandler(t)
+	h.exportState = database.StateApproved
+	h.workflowTokens = map[string]database.Role{
+		"ed":  database.RoleEditor,
+		"rev": database.RoleReviewer,
+		"pub": database.RolePublisher,
+	}
+	id := saveSynth(t, h, "workflow")
+
+	e := echo.New()
+	e.GET("/api/v1/luminaires", h.List)
+	e.GET("/api/v1/luminaires/:id", h.Get)
+	e.GET("/api/v1/luminaires/:id/qr", h.QRCode)
+	e.GET("/api/v1/luminaires/:id/state", h.GetState)
+	e.POST("/api/v1/luminaires/:id/state", h.Transition)
+	e.GET("/api/v1/luminaires/:id/export", h.Export)
+	e.GET("/api/v1/luminaires/:id/download/:app", h.Download)
+
+	move := func(token, state string) int {
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v1/luminaires/%d/state", id),
+			strings.NewReader(fmt.Sprintf(`{"state": %q, "comment": "by %s"}`, state, token)))
+		if token != "" {
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		}
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, req)
+		return resp.Code
+	}
+	getAs := func(token, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if token != "" {
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		}
+		resp := httptest.NewRecorder()
+		e.ServeHTTP(resp, req)
+		return resp
+	}
+	get := func(target string) *httptest.ResponseRecorder { return getAs("", target) }
+	listed := func(token string) int {
+		var list struct {
+			Luminaires []map[string]interface{} `json:"luminaires"`
+		}
+		json.Unmarshal(getAs(token, "/api/v1/luminaires").Body.Bytes(), &list)
+		return len(list.Luminaires)
+	}
+	record := fmt.Sprintf("/api/v1/luminaires/%d", id)
+	export := fmt.Sprintf("/api/v1/luminaires/%d/export?format=ldt", id)
+	download := fmt.Sprintf("/api/v1/luminaires/%d/download/dialux", id)
+
+	if code := get(export).Code; code != http.StatusForbidden {
+		t.Errorf("draft export: status %d, want 403", code)
+	}
+	if n := listed(""); n != 0 {
+		t.Errorf("draft listed to the public: %d luminaires", n)
+	}
+	for _, target := range []string{record, record + "/qr"} {
+		if code := get(target).Code; code != http.StatusNotFound {
+			t.Errorf("draft %s read by the public: status %d, want 404", target, code)
+		}
+	}
+	if n, code := listed("ed"), getAs("ed", record).Code; n != 1 || code != http.StatusOK {
+		t.Errorf("draft read by an editor: %d listed, status %d", n, code)
+	}
+	for _, step := range []struct {
+		token, state string
+		want         int
+	}{
+		{"", "in_review", http.StatusUnauthorized},
+		{"wrong", "in_review", http.StatusUnauthorized},
+		{"ed", "approved", http.StatusConflict},
+		{"ed", "in_review", http.StatusOK},
+		{"ed", "approved", http.StatusForbidden},
+		{"rev", "approved", http.StatusOK},
+		{"rev", "published", http.StatusForbidden},
+		{"pub", "published", http.StatusOK},
+		{"pub", "unknown", http.StatusBadRequest},
+	} {
+		if code := move(step.token, step.state); code != step.want {
+			t.Errorf("%s -> %s: status %d, want %d", step.token, step.state, code, step.want)
+		}
+	}
+	for _, target := range []string{record, record + "/qr", export, download} {
+		if code := get(target).Code; code != http.StatusOK {
+			t.Errorf("published %s: status %d", target, code)
+		}
+	}
+	if n := listed(""); n != 1 {
+		t.Errorf("published: %d luminaires listed", n)
+	}
+
+	var state struct {
+		State   database.WorkflowState `json:"state"`
+		History []workflowEvent        `json:"history"`
+	}
+	if err := json.Unmarshal(get(fmt.Sprintf("/api/v1/luminaires/%d/state", id)).Body.Bytes(), &state); err != nil {
+		t.Fatal(err)
+	}
+	if state.State != database.StatePublished || len(state.History) != 3 {
+		t.Fatalf("state %+v", state)
+	}
+	if h := state.History[1]; h.From != database.StateInReview || h.To != database.StateApproved || h.Role != "reviewer" || h.Comment != "by rev" {
+		t.Errorf("history[1] = %+v", h)
+	}
+
+	var list struct {
+		Luminaires []map[string]interface{} `json:"luminaires"`
+	}
+	for filter, want := range map[string]int{"state=published": 1, "state=draft": 0, "filter=state%3Dpublished": 1} {
+		if err := json.Unmarshal(get("/api/v1/luminaires?"+filter).Body.Bytes(), &list); err != nil {
+			t.Fatal(err)
+		}
+		if len(list.Luminaires) != want {
+			t.Errorf("%s: %d luminaires, want %d", filter, len(list.Luminaires), want)
+		}
+	}
+	if code := get("/api/v1/luminaires?state=done").Code; code != http.StatusBadRequest {
+		t.Errorf("unknown state filter: status %d", code)
+	}
+}
@@ -0,0 +1,212 @@
+package synth
+
+import (
+	"fmt"
+	"math"
+
+	"illuminate/internal/database"
+)
+
+type Distribution string
+
+const (
+	Lambertian Distribution = "lambertian"
+	NarrowBeam Distribution = "narrow"
+	Batwing    Distribution = "batwing"
+	Street     Distribution = "street"
+)
+
+// Options controls the shape and resolution of a generated luminaire.
+// Zero values are replaced with the defaults from DefaultOptions.
+type Options struct {
+	Distribution   Distribution
+	VerticalStep   float64
+	HorizontalStep float64
+	Flux           float64
+	InputWatts     float64
+	BeamAngle      float64
+	Manufacturer   string
+	Model          string
+}
+
+func DefaultOptions() Options {
+	return Options{
+		Distribution:   Lambertian,
+		VerticalStep:   5,
+		HorizontalStep: 15,
+		Flux:           1000,
+		InputWatts:     10,
+		BeamAngle:      24,
+		Manufacturer:   "Illuminate",
+	}
+}
+
+func Distributions() []Distribution {
+	return []Distribution{Lambertian, NarrowBeam, Batwing, Street}
+}
+
+// Generate builds a type C luminaire covering 0-180 vertical and 0-360
+// horizontal degrees. The candela matrix is indexed [horizontal][vertical]
+// and scaled so that the integrated flux matches opts.Flux.
+func Generate(opts Options) (*database.ParsedLuminaire, error) {
+	def := DefaultOptions()
+	if opts.Distribution == "" {
+		opts.Distribution = def.Distribution
+	}
+	if opts.VerticalStep == 0 {
+		opts.VerticalStep = def.VerticalStep
+	}
+	if opts.HorizontalStep == 0 {
+		opts.HorizontalStep = def.HorizontalStep
+	}
+	if opts.Flux == 0 {
+		opts.Flux = def.Flux
+	}
+	if opts.InputWatts == 0 {
+		opts.InputWatts = def.InputWatts
+	}
+	if opts.BeamAngle == 0 {
+		opts.BeamAngle = def.BeamAngle
+	}
+	if opts.Manufacturer == "" {
+		opts.Manufacturer = def.Manufacturer
+	}
+	if opts.Model == "" {
+		opts.Model = fmt.Sprintf("SYNTH-%s", opts.Distribution)
+	}
+
+	if opts.VerticalStep < 0 || opts.VerticalStep > 90 || math.Mod(180, opts.VerticalStep) != 0 {
+		return nil, fmt.Errorf("vertical step must divide 180: %g", opts.VerticalStep)
+	}
+	if opts.HorizontalStep < 0 || opts.HorizontalStep > 180 || math.Mod(360, opts.HorizontalStep) != 0 {
+		return nil, fmt.Errorf("horizontal step must divide 360: %g", opts.HorizontalStep)
+	}
+	if opts.Flux < 0 {
+		return nil, fmt.Errorf("flux must be positive: %g", opts.Flux)
+	}
+
+	intensity, err := intensityFunc(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	vertical := angleRange(180, opts.VerticalStep)
+	horizontal := angleRange(360, opts.HorizontalStep)
+
+	matrix := make([][]float64, len(horizontal))
+	for i, c := range horizontal {
+		matrix[i] = make([]float64, len(vertical))
+		for j, g := range vertical {
+			matrix[i][j] = intensity(g, c)
+		}
+	}
+
+	if raw := integrateFlux(vertical, horizontal, matrix); raw > 0 {
+		scale := opts.Flux / raw
+		for i := range matrix {
+			for j := range matrix[i] {
+				matrix[i][j] = math.Round(matrix[i][j]*scale*100) / 100
+			}
+		}
+	}
+
+	return &database.ParsedLuminaire{
+		Metadata: database.Luminaire{
+			Manufacturer:     opts.Manufacturer,
+			Model:            opts.Model,
+			LuminaireDesc:    fmt.Sprintf("Synthetic %s distribution", opts.Distribution),
+			LampType:         "LED",
+			TestLab:          "synthetic",
+			PhotometricType:  database.PhotometricTypeC,
+			UnitsType:        database.UnitsMetric,
+			ConversionFactor: 1,
+			InputWatts:       opts.InputWatts,
+			LuminousFlux:     opts.Flux,
+			FormatType:       "Synthetic",
+		},
+		VerticalAngles:   vertical,
+		HorizontalAngles: horizontal,
+		CandelaMatrix:    matrix,
+	}, nil
+}
+
+func intensityFunc(opts Options) (func(gamma, c float64) float64, error) {
+	switch opts.Distribution {
+	case Lambertian:
+		return func(gamma, _ float64) float64 {
+			return downward(gamma)
+		}, nil
+	case NarrowBeam:
+		// cos^n falls to 50% at half the beam angle.
+		half := rad(opts.BeamAngle / 2)
+		n := math.Log(0.5) / math.Log(math.Cos(half))
+		return func(gamma, _ float64) float64 {
+			return math.Pow(downward(gamma), n)
+		}, nil
+	case Batwing:
+		return func(gamma, _ float64) float64 {
+			return downward(gamma) * (0.3 + gaussian(gamma, 40, 15))
+		}, nil
+	case Street:
+		// Peak throw along the road (C0/C180) at 65°, biased to the street side (C90).
+		return func(gamma, c float64) float64 {
+			along := math.Pow(math.Abs(math.Cos(rad(c))), 2)
+			side := 0.6
+			if c > 0 && c < 180 {
+				side = 1
+			}
+			return 0.25*downward(gamma) + side*along*gaussian(gamma, 65, 10)*boolToFloat(gamma <= 90)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown distribution: %s", opts.Distribution)
+	}
+}
+
+// integrateFlux sums I·sin(γ)·dγ·dC over the grid using the trapezoidal rule.
+func integrateFlux(vertical, horizontal []float64, matrix [][]float64) float64 {
+	total := 0.0
+	for i := 0; i+1 < len(horizontal); i++ {
+		dc := rad(horizontal[i+1] - horizontal[i])
+		for j := 0; j+1 < len(vertical); j++ {
+			dg := rad(vertical[j+1] - vertical[j])
+			a := matrix[i][j] * math.Sin(rad(vertical[j]))
+			b := matrix[i][j+1] * math.Sin(rad(vertical[j+1]))
+			c := matrix[i+1][j] * math.Sin(rad(vertical[j]))
+			d := matrix[i+1][j+1] * math.Sin(rad(vertical[j+1]))
+			total += (a + b + c + d) / 4 * dg * dc
+		}
+	}
+	return total
+}
+
+func angleRange(max, step float64) []float64 {
+	n := int(math.Round(max/step)) + 1
+	angles := make([]float64, n)
+	for i := range angles {
+		angles[i] = float64(i) * step
+	}
+	return angles
+}
+
+func downward(gamma float64) float64 {
+	if gamma >= 90 {
+		return 0
+	}
+	return math.Cos(rad(gamma))
+}
+
+func gaussian(x, mean, width float64) float64 {
+	d := (x - mean) / width
+	return math.Exp(-d * d)
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func rad(deg float64) float64 {
+	return deg * math.Pi / 180
+}
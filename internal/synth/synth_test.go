@@ -0,0 +1,107 @@
+package synth
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestGenerateGrid(t *testing.T) {
+	for _, step := range []struct{ vertical, horizontal float64 }{{5, 15}, {2.5, 10}, {90, 180}} {
+		opts := DefaultOptions()
+		opts.VerticalStep, opts.HorizontalStep = step.vertical, step.horizontal
+		lum, err := Generate(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nv, nh := int(180/step.vertical)+1, int(360/step.horizontal)+1
+		if len(lum.VerticalAngles) != nv || len(lum.HorizontalAngles) != nh || len(lum.CandelaMatrix) != nh {
+			t.Fatalf("steps %g/%g: %d vertical, %d horizontal angles, %d planes", step.vertical, step.horizontal,
+				len(lum.VerticalAngles), len(lum.HorizontalAngles), len(lum.CandelaMatrix))
+		}
+		if lum.VerticalAngles[nv-1] != 180 || lum.HorizontalAngles[nh-1] != 360 || lum.VerticalAngles[1] != step.vertical {
+			t.Errorf("steps %g/%g: angles %v, %v", step.vertical, step.horizontal, lum.VerticalAngles, lum.HorizontalAngles)
+		}
+		for i, plane := range lum.CandelaMatrix {
+			if len(plane) != nv {
+				t.Errorf("steps %g/%g: plane %d has %d values", step.vertical, step.horizontal, i, len(plane))
+			}
+		}
+	}
+
+	for _, bad := range []Options{{VerticalStep: 7}, {HorizontalStep: 25}, {VerticalStep: -5}, {Flux: -1}, {Distribution: "spot"}} {
+		if _, err := Generate(bad); err == nil {
+			t.Errorf("Generate(%+v) succeeded", bad)
+		}
+	}
+}
+
+// TestGenerateSymmetry checks the rotationally symmetric distributions have
+// the same values in every plane, and the street distribution mirrors about
+// the C90-C270 plane. None sends light above the horizontal.
+func TestGenerateSymmetry(t *testing.T) {
+	for _, dist := range Distributions() {
+		opts := DefaultOptions()
+		opts.Distribution = dist
+		lum, err := Generate(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, c := range lum.HorizontalAngles {
+			mirror := 0
+			if dist == Street {
+				// C180-c, taken modulo 360.
+				mirror = int(math.Mod(540-c, 360) / opts.HorizontalStep)
+			}
+			if !reflect.DeepEqual(lum.CandelaMatrix[i], lum.CandelaMatrix[mirror]) {
+				t.Errorf("%s: C%g differs from C%g", dist, c, lum.HorizontalAngles[mirror])
+			}
+		}
+		if dist == Street && reflect.DeepEqual(lum.CandelaMatrix[0], lum.CandelaMatrix[len(lum.CandelaMatrix)/4]) {
+			t.Errorf("street: C0 and C90 are the same")
+		}
+		for j, g := range lum.VerticalAngles {
+			if g > 90 && lum.CandelaMatrix[0][j] != 0 {
+				t.Errorf("%s: %g cd at γ=%g", dist, lum.CandelaMatrix[0][j], g)
+			}
+		}
+	}
+}
+
+func TestGenerateFlux(t *testing.T) {
+	for _, dist := range Distributions() {
+		for _, flux := range []float64{1000, 12000} {
+			opts := DefaultOptions()
+			opts.Distribution, opts.Flux = dist, flux
+			lum, err := Generate(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := integrateFlux(lum.VerticalAngles, lum.HorizontalAngles, lum.CandelaMatrix)
+			if math.Abs(got-flux) > 0.001*flux {
+				t.Errorf("%s: integrated flux %.2f lm, want %g", dist, got, flux)
+			}
+			if lum.Metadata.LuminousFlux != flux {
+				t.Errorf("%s: stated flux %g lm, want %g", dist, lum.Metadata.LuminousFlux, flux)
+			}
+		}
+	}
+}
+
+func TestGenerateDeterministic(t *testing.T) {
+	for _, dist := range Distributions() {
+		opts := DefaultOptions()
+		opts.Distribution = dist
+		first, err := Generate(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, err := Generate(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(first, second) {
+			t.Errorf("%s: two runs differ", dist)
+		}
+	}
+}
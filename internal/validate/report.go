@@ -0,0 +1,189 @@
+package validate
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// FileResult is the result of checking one named file, the unit of the
+// CI reports below.
+type FileResult struct {
+	File string `json:"file"`
+	Result
+}
+
+// RuleParse reports a file that could not be read, so never reached the
+// rules.
+const RuleParse = "parse"
+
+// ruleDescriptions describe the rules in CI reports.
+var ruleDescriptions = map[string]string{
+	RuleParse:       "The file could not be read as photometric data",
+	"identity":      "Manufacturer, model and catalog number are stated",
+	"angles":        "Angles are ordered and within range",
+	"candela":       "Candela values are present and not negative",
+	"flux":          "Stated flux matches the integrated flux",
+	"electrical":    "Input power is stated and efficacy plausible",
+	"color":         "CCT and CRI are within range",
+	"power_quality": "THD, inrush current and frequency are plausible",
+}
+
+// ParseFailure is the result of a file that could not be read, under the
+// rules enabled lets run.
+func ParseFailure(err error, enabled RuleFilter) Result {
+	issue := errorIssue(CodeUnreadable, "detail", err.Error())
+	issue.Rule = RuleParse
+	issues := []Issue{issue}
+	score, grade := Quality(issues)
+	return Result{Status: StatusInvalid, Issues: issues, RulesVersion: RulesVersion(enabled), Score: score, Grade: grade}
+}
+
+// WriteSARIF writes results as SARIF 2.1.0, which GitHub and GitLab show as
+// annotations on the files. Photometric issues have no line, so they point
+// at the whole file. The tool version is the rules version the results
+// were checked under.
+func WriteSARIF(w io.Writer, results []FileResult) error {
+	type message struct {
+		Text string `json:"text"`
+	}
+	type location struct {
+		PhysicalLocation struct {
+			ArtifactLocation struct {
+				URI string `json:"uri"`
+			} `json:"artifactLocation"`
+		} `json:"physicalLocation"`
+	}
+	type result struct {
+		RuleID    string     `json:"ruleId"`
+		Level     string     `json:"level"`
+		Message   message    `json:"message"`
+		Locations []location `json:"locations"`
+	}
+	type rule struct {
+		ID               string  `json:"id"`
+		ShortDescription message `json:"shortDescription"`
+	}
+
+	rules := []rule{{ID: RuleParse, ShortDescription: message{ruleDescriptions[RuleParse]}}}
+	for _, r := range Rules {
+		rules = append(rules, rule{ID: r.Name, ShortDescription: message{ruleDescription(r.Name)}})
+	}
+	version := RulesVersion(nil)
+	out := []result{}
+	for _, r := range results {
+		version = r.RulesVersion
+		for _, issue := range r.Issues {
+			var loc location
+			loc.PhysicalLocation.ArtifactLocation.URI = r.File
+			out = append(out, result{
+				RuleID:    issue.Rule,
+				Level:     issue.Severity,
+				Message:   message{issue.Message},
+				Locations: []location{loc},
+			})
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(map[string]interface{}{
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"version": "2.1.0",
+		"runs": []interface{}{map[string]interface{}{
+			"tool": map[string]interface{}{
+				"driver": map[string]interface{}{
+					"name":    "illuminate",
+					"version": version,
+					"rules":   rules,
+				},
+			},
+			"results": out,
+		}},
+	})
+}
+
+// WriteJUnit writes results as JUnit XML, one test case per file, for CI
+// test report views. Errors fail the case, a file that never reached the
+// rules (see ParseFailure) is an error, and warnings go to the case's
+// output.
+func WriteJUnit(w io.Writer, results []FileResult) error {
+	type problem struct {
+		Message string `xml:"message,attr"`
+		Type    string `xml:"type,attr"`
+		Text    string `xml:",chardata"`
+	}
+	type testCase struct {
+		Name      string   `xml:"name,attr"`
+		ClassName string   `xml:"classname,attr"`
+		Failure   *problem `xml:"failure,omitempty"`
+		Error     *problem `xml:"error,omitempty"`
+		SystemOut string   `xml:"system-out,omitempty"`
+	}
+	type testSuite struct {
+		XMLName  xml.Name   `xml:"testsuite"`
+		Name     string     `xml:"name,attr"`
+		Tests    int        `xml:"tests,attr"`
+		Failures int        `xml:"failures,attr"`
+		Errors   int        `xml:"errors,attr"`
+		Cases    []testCase `xml:"testcase"`
+	}
+
+	suite := testSuite{Name: "illuminate validation", Tests: len(results)}
+	for _, r := range results {
+		tc := testCase{Name: r.File, ClassName: "photometry"}
+		var failures, warnings string
+		first := ""
+		for _, issue := range r.Issues {
+			line := fmt.Sprintf("%s: %s\n", issue.Rule, issue.Message)
+			switch {
+			case !isRule(issue.Rule):
+				tc.Error = &problem{Message: issue.Message, Type: issue.Rule, Text: line}
+			case issue.Severity == SeverityError:
+				if first == "" {
+					first = issue.Rule
+				}
+				failures += line
+			default:
+				warnings += line
+			}
+		}
+		switch {
+		case tc.Error != nil:
+			suite.Errors++
+		case failures != "":
+			tc.Failure = &problem{Message: "validation errors", Type: first, Text: failures}
+			suite.Failures++
+		}
+		tc.SystemOut = warnings
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suite); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func isRule(name string) bool {
+	for _, r := range Rules {
+		if r.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func ruleDescription(name string) string {
+	if d, ok := ruleDescriptions[name]; ok {
+		return d
+	}
+	return "Photometric " + name + " check"
+}
@@ -0,0 +1,99 @@
+package validate
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func reportResults() []FileResult {
+	clean := Result{Status: StatusValid}
+	warned := Result{Status: StatusWarning, Issues: []Issue{{Rule: "identity", Severity: SeverityWarning, Message: "catalog number is missing"}}}
+	failed := Result{Status: StatusInvalid, Issues: []Issue{
+		{Rule: "candela", Severity: SeverityError, Message: "negative candela"},
+		{Rule: "identity", Severity: SeverityWarning, Message: "catalog number is missing"},
+	}}
+	return []FileResult{
+		{File: "a.ies", Result: clean},
+		{File: "b.ies", Result: warned},
+		{File: "c.ldt", Result: failed},
+		{File: "d.ies", Result: ParseFailure(errors.New("too few lines"), nil)},
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteSARIF(&buf, reportResults()); err != nil {
+		t.Fatal(err)
+	}
+	var log struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Rules []struct {
+						ID string `json:"id"`
+					} `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+			Results []struct {
+				RuleID    string `json:"ruleId"`
+				Level     string `json:"level"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							URI string `json:"uri"`
+						} `json:"artifactLocation"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil || log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("sarif = %s, %v", buf.String(), err)
+	}
+	run := log.Runs[0]
+	if len(run.Tool.Driver.Rules) != len(Rules)+1 {
+		t.Errorf("%d rules", len(run.Tool.Driver.Rules))
+	}
+	if len(run.Results) != 4 {
+		t.Fatalf("%d results", len(run.Results))
+	}
+	if r := run.Results[1]; r.RuleID != "candela" || r.Level != "error" || r.Locations[0].PhysicalLocation.ArtifactLocation.URI != "c.ldt" {
+		t.Errorf("result = %+v", r)
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJUnit(&buf, reportResults()); err != nil {
+		t.Fatal(err)
+	}
+	var suite struct {
+		Tests    int `xml:"tests,attr"`
+		Failures int `xml:"failures,attr"`
+		Errors   int `xml:"errors,attr"`
+		Cases    []struct {
+			Name    string `xml:"name,attr"`
+			Failure *struct {
+				Type string `xml:"type,attr"`
+			} `xml:"failure"`
+			SystemOut string `xml:"system-out"`
+		} `xml:"testcase"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &suite); err != nil {
+		t.Fatal(err)
+	}
+	if suite.Tests != 4 || suite.Failures != 1 || suite.Errors != 1 {
+		t.Errorf("suite = %+v", suite)
+	}
+	if c := suite.Cases[2]; c.Name != "c.ldt" || c.Failure == nil || c.Failure.Type != "candela" {
+		t.Errorf("failed case = %+v", c)
+	}
+	if c := suite.Cases[1]; c.Failure != nil || !strings.Contains(c.SystemOut, "catalog number") {
+		t.Errorf("warned case = %+v", c)
+	}
+}
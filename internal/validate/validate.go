@@ -0,0 +1,409 @@
+// Package validate checks stored luminaires against the catalog's data
+// rules. Every rule carries a version; bump it whenever the rule's logic
+// changes so that RulesVersion changes and earlier results are re-checked.
+package validate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"illuminate/internal/database"
+	"illuminate/internal/i18n"
+	"illuminate/internal/photometry"
+)
+
+// Severities of an Issue.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Statuses of a Result, from best to worst.
+const (
+	StatusValid   = "valid"
+	StatusWarning = "warning"
+	StatusInvalid = "invalid"
+)
+
+// Issue is one finding of a rule. Code says what was found, stable for
+// programs to match on, and Params are the values in Message, which is in
+// English; i18n translates Code and Params into other languages. Results
+// stored before issues had codes have none.
+type Issue struct {
+	Rule     string            `json:"rule"`
+	Severity string            `json:"severity"`
+	Code     string            `json:"code,omitempty"`
+	Params   map[string]string `json:"params,omitempty"`
+	Message  string            `json:"message"`
+}
+
+// Codes of an Issue, with their templates in internal/i18n/messages. New
+// codes may be added; existing ones keep their meaning.
+const (
+	CodeManufacturerMissing        = "manufacturer_missing"
+	CodeModelMissing               = "model_missing"
+	CodeCatalogNumberMissing       = "catalog_number_missing"
+	CodeVerticalAnglesMissing      = "vertical_angles_missing"
+	CodeHorizontalAnglesMissing    = "horizontal_angles_missing"
+	CodeVerticalAngleOutOfRange    = "vertical_angle_out_of_range"
+	CodeHorizontalAngleOutOfRange  = "horizontal_angle_out_of_range"
+	CodeVerticalAnglesUnordered    = "vertical_angles_unordered"
+	CodeHorizontalAnglesUnordered  = "horizontal_angles_unordered"
+	CodeCandelaShape               = "candela_shape"
+	CodeCandelaValue               = "candela_value"
+	CodeCandelaZero                = "candela_zero"
+	CodeFluxMissing                = "flux_missing"
+	CodeFluxDiffers                = "flux_differs"
+	CodeFluxExceedsLamps           = "flux_exceeds_lamps"
+	CodeFluxDiffersFromLamps       = "flux_differs_from_lamps"
+	CodeDownwardFractionDiffers    = "downward_fraction_differs"
+	CodeWattsMissing               = "watts_missing"
+	CodeEfficacyImplausible        = "efficacy_implausible"
+	CodeColorTemperatureOutOfRange = "color_temperature_out_of_range"
+	CodeCRIOutOfRange              = "cri_out_of_range"
+	CodePowerQualityOutOfRange     = "power_quality_out_of_range"
+	CodeUnreadable                 = "unreadable"
+)
+
+// Result is the outcome of Check.
+type Result struct {
+	Status       string  `json:"status"`
+	Issues       []Issue `json:"issues"`
+	RulesVersion string  `json:"rules_version"`
+	// Score and Grade rate the data quality, see Quality.
+	Score int    `json:"score"`
+	Grade string `json:"grade"`
+}
+
+// Quality grades, from best to worst, with the least score of each.
+var grades = []struct {
+	grade string
+	min   int
+}{{"A", 90}, {"B", 75}, {"C", 60}, {"D", 40}, {"E", 0}}
+
+// Quality scores issues out of 100, taking 30 points per error and 10 per
+// warning, and grades the score A (90 and up) to E (below 40). A record with
+// an error never grades better than C. The luminaire_validation migration
+// that added the score repeats the formula for rows validated before it.
+func Quality(issues []Issue) (score int, grade string) {
+	score = 100
+	for _, issue := range issues {
+		if issue.Severity == SeverityError {
+			score -= 30
+		} else {
+			score -= 10
+		}
+	}
+	score = max(score, 0)
+	for _, g := range grades {
+		if score >= g.min {
+			return score, g.grade
+		}
+	}
+	return score, "E"
+}
+
+// ParseGrade validates a quality grade, accepting lower case.
+func ParseGrade(s string) (string, error) {
+	grade := strings.ToUpper(strings.TrimSpace(s))
+	for _, g := range grades {
+		if grade == g.grade {
+			return grade, nil
+		}
+	}
+	return "", fmt.Errorf("unknown quality grade %q (want A to E)", s)
+}
+
+// Rule is one named check.
+type Rule struct {
+	Name    string
+	Version int
+	Check   func(lum *database.ParsedLuminaire) []Issue
+}
+
+// Rules are the checks Check runs, in report order.
+var Rules = []Rule{
+	{"identity", 1, checkIdentity},
+	{"angles", 1, checkAngles},
+	{"candela", 1, checkCandela},
+	{"flux", 2, checkFlux},
+	{"electrical", 1, checkElectrical},
+	{"color", 1, checkColor},
+	{"power_quality", 1, checkPowerQuality},
+	{"consistency", 1, checkConsistency},
+}
+
+// RuleFilter reports whether the rule called name runs; a nil RuleFilter
+// runs every rule. A deployment or organization that switches rules off
+// gets another RulesVersion, as if they were removed.
+type RuleFilter func(name string) bool
+
+// Active returns the Rules enabled lets run, in report order.
+func Active(enabled RuleFilter) []Rule {
+	active := make([]Rule, 0, len(Rules))
+	for _, r := range Rules {
+		if enabled == nil || enabled(r.Name) {
+			active = append(active, r)
+		}
+	}
+	return active
+}
+
+// ConsistencyRules are the rules that compare declared values with those
+// computed from the candela values and lamp sets. A record with an issue
+// from one of them is inconsistent.
+var ConsistencyRules = []string{"consistency"}
+
+// CheckConsistency runs the ConsistencyRules enabled lets run against lum
+// and returns what they found.
+func CheckConsistency(lum *database.ParsedLuminaire, enabled RuleFilter) []Issue {
+	issues := []Issue{}
+	for _, rule := range Active(enabled) {
+		if !slices.Contains(ConsistencyRules, rule.Name) {
+			continue
+		}
+		for _, issue := range rule.Check(lum) {
+			issue.Rule = rule.Name
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+// RulesVersion identifies the rule set enabled lets run: it changes whenever
+// a rule is added, removed, switched off or has its version bumped.
+func RulesVersion(enabled RuleFilter) string {
+	active := Active(enabled)
+	names := make([]string, len(active))
+	for i, r := range active {
+		names[i] = fmt.Sprintf("%s@%d", r.Name, r.Version)
+	}
+	sort.Strings(names)
+	h := fnv.New64a()
+	h.Write([]byte(strings.Join(names, ",")))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// InputDigest fingerprints the metadata a validation depends on, leaving out
+// the record's id, timestamps, filename and workflow state. Together with the file hash,
+// which covers the photometric data, and RulesVersion it identifies a result.
+func InputDigest(meta database.Luminaire) string {
+	meta.ID, meta.OriginalFilename, meta.State = 0, "", ""
+	meta.CreatedAt, meta.UpdatedAt = time.Time{}, time.Time{}
+	data, _ := json.Marshal(meta)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16])
+}
+
+// Check runs the rules enabled lets run against lum.
+func Check(lum *database.ParsedLuminaire, enabled RuleFilter) Result {
+	res := Result{Status: StatusValid, Issues: []Issue{}, RulesVersion: RulesVersion(enabled)}
+	for _, rule := range Active(enabled) {
+		for _, issue := range rule.Check(lum) {
+			issue.Rule = rule.Name
+			res.Issues = append(res.Issues, issue)
+			switch {
+			case issue.Severity == SeverityError:
+				res.Status = StatusInvalid
+			case res.Status == StatusValid:
+				res.Status = StatusWarning
+			}
+		}
+	}
+	res.Score, res.Grade = Quality(res.Issues)
+	return res
+}
+
+// newIssue builds an issue of code whose parameters are given as name,
+// value pairs, with its message in English.
+func newIssue(severity, code string, params ...string) Issue {
+	issue := Issue{Severity: severity, Code: code}
+	if len(params) > 0 {
+		issue.Params = make(map[string]string, len(params)/2)
+		for i := 0; i+1 < len(params); i += 2 {
+			issue.Params[params[i]] = params[i+1]
+		}
+	}
+	issue.Message, _ = i18n.Message(i18n.Default, code, issue.Params)
+	return issue
+}
+
+func errorIssue(code string, params ...string) Issue {
+	return newIssue(SeverityError, code, params...)
+}
+
+func warnIssue(code string, params ...string) Issue {
+	return newIssue(SeverityWarning, code, params...)
+}
+
+// num formats v for a message the way %g does; the checks that round pass
+// their own format.
+func num(v float64) string { return strconv.FormatFloat(v, 'g', -1, 64) }
+
+func checkIdentity(lum *database.ParsedLuminaire) []Issue {
+	var issues []Issue
+	if strings.TrimSpace(lum.Metadata.Manufacturer) == "" {
+		issues = append(issues, errorIssue(CodeManufacturerMissing))
+	}
+	if strings.TrimSpace(lum.Metadata.Model) == "" {
+		issues = append(issues, errorIssue(CodeModelMissing))
+	}
+	if strings.TrimSpace(lum.Metadata.CatalogNumber) == "" {
+		issues = append(issues, warnIssue(CodeCatalogNumberMissing))
+	}
+	return issues
+}
+
+func checkAngles(lum *database.ParsedLuminaire) []Issue {
+	var issues []Issue
+	check := func(codes [3]string, angles []float64, lo, hi float64) {
+		missing, outOfRange, unordered := codes[0], codes[1], codes[2]
+		if len(angles) == 0 {
+			issues = append(issues, errorIssue(missing))
+			return
+		}
+		for i, a := range angles {
+			if a < lo || a > hi {
+				issues = append(issues, errorIssue(outOfRange, "angle", num(a), "min", num(lo), "max", num(hi)))
+				return
+			}
+			if i > 0 && a <= angles[i-1] {
+				issues = append(issues, errorIssue(unordered, "angle", num(a)))
+				return
+			}
+		}
+	}
+	check([3]string{CodeVerticalAnglesMissing, CodeVerticalAngleOutOfRange, CodeVerticalAnglesUnordered},
+		lum.VerticalAngles, -90, 180)
+	check([3]string{CodeHorizontalAnglesMissing, CodeHorizontalAngleOutOfRange, CodeHorizontalAnglesUnordered},
+		lum.HorizontalAngles, -90, 360)
+	return issues
+}
+
+func checkCandela(lum *database.ParsedLuminaire) []Issue {
+	if err := lum.CheckShape(); err != nil {
+		return []Issue{errorIssue(CodeCandelaShape, "detail", err.Error())}
+	}
+	peak := 0.0
+	for i, row := range lum.CandelaMatrix {
+		for _, v := range row {
+			if v < 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+				return []Issue{errorIssue(CodeCandelaValue, "value", num(v), "row", strconv.Itoa(i))}
+			}
+			peak = math.Max(peak, v)
+		}
+	}
+	if peak == 0 {
+		return []Issue{errorIssue(CodeCandelaZero)}
+	}
+	return nil
+}
+
+// checkFlux asks for a stated flux; checkConsistency compares it with the
+// candela values.
+func checkFlux(lum *database.ParsedLuminaire) []Issue {
+	if lum.Metadata.LuminousFlux <= 0 {
+		return []Issue{warnIssue(CodeFluxMissing)}
+	}
+	return nil
+}
+
+// checkConsistency compares the declared flux with the flux integrated from
+// the candela values, which should agree within 10%, and the lamp sets of
+// the source with them: the rated lamp lumens of relative IES photometry
+// bound the integrated flux, and an EULUMDAT file's lamp flux times its
+// light output ratio, and its downward flux fraction, should match what its
+// intensities integrate to.
+func checkConsistency(lum *database.ParsedLuminaire) []Issue {
+	measured := photometry.Flux(lum)
+	if measured <= 0 {
+		return nil
+	}
+	var issues []Issue
+	// EULUMDAT declares lamp flux, which the light output ratio relates to
+	// the luminaire's.
+	isLDT := strings.HasPrefix(lum.Metadata.FormatType, "LDT")
+	if stated := lum.Metadata.LuminousFlux; stated > 0 && !isLDT {
+		if dev := (measured - stated) / stated; math.Abs(dev) > 0.1 {
+			issues = append(issues, warnIssue(CodeFluxDiffers, "stated", fmt.Sprintf("%.0f", stated),
+				"measured", fmt.Sprintf("%.0f", measured), "deviation", fmt.Sprintf("%+.0f", dev*100)))
+		}
+	}
+	if ies := lum.Extensions.Format("ies"); ies["lumens_per_lamp"] != "" {
+		lamps := extensionNumber(ies, "lamp_count", 1)
+		if rated := lamps * extensionNumber(ies, "lumens_per_lamp", 0); rated > 0 && measured > rated*1.05 {
+			issues = append(issues, warnIssue(CodeFluxExceedsLamps, "measured", fmt.Sprintf("%.0f", measured),
+				"rated", fmt.Sprintf("%.0f", rated), "lamps", num(lamps), "lamp_flux", num(rated/lamps),
+				"ratio", fmt.Sprintf("%.0f", measured/rated*100)))
+		}
+	}
+	if isLDT && lum.Metadata.LuminousFlux > 0 {
+		ldt := lum.Extensions.Format("ldt")
+		lor := extensionNumber(ldt, "light_output_ratio", 100)
+		if expected := lum.Metadata.LuminousFlux * lor / 100; expected > 0 {
+			if dev := (measured - expected) / expected; math.Abs(dev) > 0.1 {
+				issues = append(issues, warnIssue(CodeFluxDiffersFromLamps, "measured", fmt.Sprintf("%.0f", measured),
+					"lamp_flux", fmt.Sprintf("%.0f", lum.Metadata.LuminousFlux), "ratio", num(lor),
+					"deviation", fmt.Sprintf("%+.0f", dev*100)))
+			}
+		}
+		declared := extensionNumber(ldt, "downward_flux_fraction", 100)
+		if computed := photometry.ConeFraction(lum, 90) * 100; math.Abs(computed-declared) > 5 {
+			issues = append(issues, warnIssue(CodeDownwardFractionDiffers, "declared", num(declared),
+				"computed", fmt.Sprintf("%.0f", computed)))
+		}
+	}
+	return issues
+}
+
+// extensionNumber reads a numeric extension field, or def.
+func extensionNumber(fields map[string]string, key string, def float64) float64 {
+	v, err := strconv.ParseFloat(fields[key], 64)
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+func checkElectrical(lum *database.ParsedLuminaire) []Issue {
+	watts := lum.Metadata.InputWatts
+	if watts <= 0 {
+		return []Issue{warnIssue(CodeWattsMissing)}
+	}
+	// Beyond the practical limit for white LED luminaires.
+	if flux := photometry.Flux(lum); flux/watts > 250 {
+		return []Issue{errorIssue(CodeEfficacyImplausible, "efficacy", fmt.Sprintf("%.0f", flux/watts))}
+	}
+	return nil
+}
+
+func checkColor(lum *database.ParsedLuminaire) []Issue {
+	var issues []Issue
+	if cct := lum.Metadata.ColorTemp; cct != 0 && (cct < 1000 || cct > 20000) {
+		issues = append(issues, errorIssue(CodeColorTemperatureOutOfRange, "cct", strconv.Itoa(cct)))
+	}
+	if cri := lum.Metadata.CRI; cri < 0 || cri > 100 {
+		issues = append(issues, errorIssue(CodeCRIOutOfRange, "cri", strconv.Itoa(cri)))
+	}
+	return issues
+}
+
+func checkPowerQuality(lum *database.ParsedLuminaire) []Issue {
+	var issues []Issue
+	for _, f := range database.PowerQualityFields {
+		if v := f.Value(lum.Metadata); !f.Plausible(v) {
+			issues = append(issues, errorIssue(CodePowerQualityOutOfRange, "field", f.Name, "value", num(v),
+				"min", num(f.Min), "max", num(f.Max), "unit", f.Unit))
+		}
+	}
+	return issues
+}
@@ -0,0 +1,146 @@
+package validate
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"illuminate/internal/database"
+	"illuminate/internal/photometry"
+	"illuminate/internal/synth"
+)
+
+func TestCheck(t *testing.T) {
+	lum, err := synth.Generate(synth.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lum.Metadata.CatalogNumber = "SYN-1"
+	if res := Check(lum, nil); res.Status != StatusValid {
+		t.Fatalf("synthetic luminaire: %+v", res)
+	}
+
+	lum.Metadata.CatalogNumber = ""
+	if res := Check(lum, nil); res.Status != StatusWarning || res.Issues[0].Rule != "identity" {
+		t.Errorf("missing catalog number: %+v", res)
+	}
+
+	lum.Metadata.InputWatts = 1
+	lum.CandelaMatrix[0][0] = -1
+	res := Check(lum, nil)
+	if res.Status != StatusInvalid {
+		t.Errorf("negative candela: %+v", res)
+	}
+	rules := map[string]bool{}
+	for _, issue := range res.Issues {
+		rules[issue.Rule] = true
+	}
+	if !rules["candela"] || !rules["electrical"] {
+		t.Errorf("issues = %+v", res.Issues)
+	}
+}
+
+func TestPowerQuality(t *testing.T) {
+	lum, err := synth.Generate(synth.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lum.Metadata.CatalogNumber = "SYN-1"
+	lum.Metadata.THD, lum.Metadata.InrushCurrent, lum.Metadata.Frequency = 8, 30, 50
+	if res := Check(lum, nil); res.Status != StatusValid {
+		t.Fatalf("plausible power quality: %+v", res)
+	}
+	lum.Metadata.Frequency = 400
+	if res := Check(lum, nil); res.Status != StatusInvalid || res.Issues[0].Rule != "power_quality" {
+		t.Errorf("400 Hz mains: %+v", res)
+	}
+}
+
+func TestQuality(t *testing.T) {
+	warning, failure := Issue{Severity: SeverityWarning}, Issue{Severity: SeverityError}
+	for _, tc := range []struct {
+		issues []Issue
+		score  int
+		grade  string
+	}{
+		{nil, 100, "A"},
+		{[]Issue{warning}, 90, "A"},
+		{[]Issue{warning, warning}, 80, "B"},
+		{[]Issue{failure}, 70, "C"},
+		{[]Issue{failure, warning, warning}, 50, "D"},
+		{[]Issue{failure, failure, failure, failure}, 0, "E"},
+	} {
+		if score, grade := Quality(tc.issues); score != tc.score || grade != tc.grade {
+			t.Errorf("%d issues: %d %s, want %d %s", len(tc.issues), score, grade, tc.score, tc.grade)
+		}
+	}
+	if g, err := ParseGrade("b"); err != nil || g != "B" {
+		t.Errorf("ParseGrade(b) = %q, %v", g, err)
+	}
+	if _, err := ParseGrade("F"); err == nil {
+		t.Error("ParseGrade(F) succeeded")
+	}
+}
+
+func TestRulesVersionTracksRules(t *testing.T) {
+	before := RulesVersion(nil)
+	saved := Rules
+	defer func() { Rules = saved }()
+
+	Rules = append([]Rule(nil), saved...)
+	Rules[0].Version++
+	if RulesVersion(nil) == before {
+		t.Error("bumping a rule version left RulesVersion unchanged")
+	}
+}
+
+func TestInputDigest(t *testing.T) {
+	lum, err := synth.Generate(synth.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := InputDigest(lum.Metadata)
+
+	stored := lum.Metadata
+	stored.ID, stored.OriginalFilename = 42, "stored.ies"
+	if InputDigest(stored) != before {
+		t.Error("record identity changed the digest")
+	}
+	stored.Model = "renamed"
+	if InputDigest(stored) == before {
+		t.Error("metadata edit left the digest unchanged")
+	}
+}
+
+func TestConsistency(t *testing.T) {
+	lum, err := synth.Generate(synth.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issues := CheckConsistency(lum, nil); len(issues) != 0 {
+		t.Fatalf("synthetic luminaire: %+v", issues)
+	}
+
+	// An EULUMDAT file whose intensities integrate to its lamp flux but
+	// declare a light output ratio of 60% and all flux upward.
+	ldt := *lum
+	ldt.Metadata.FormatType = "LDT"
+	ldt.Metadata.LuminousFlux = photometry.Flux(lum)
+	ldt.Extensions = database.Extensions{"ldt:light_output_ratio": "60", "ldt:downward_flux_fraction": "0"}
+	issues := CheckConsistency(&ldt, nil)
+	if len(issues) != 2 || issues[0].Rule != "consistency" {
+		t.Errorf("LDT with a wrong LOR and DFF: %+v", issues)
+	}
+
+	// Relative IES photometry rated at half the flux it emits.
+	ies := *lum
+	ies.Extensions = database.Extensions{"ies:lamp_count": "2", "ies:lumens_per_lamp": fmt.Sprint(photometry.Flux(lum) / 4)}
+	issues = CheckConsistency(&ies, nil)
+	if len(issues) != 1 || !strings.Contains(issues[0].Message, "exceeds the rated lamp flux") {
+		t.Errorf("IES rated below its flux: %+v", issues)
+	}
+	ies.Extensions["ies:lumens_per_lamp"] = fmt.Sprint(photometry.Flux(lum))
+	if issues := CheckConsistency(&ies, nil); len(issues) != 0 {
+		t.Errorf("IES with a 50%% LOR: %+v", issues)
+	}
+}
@@ -0,0 +1,157 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+var ErrPoolClosed = errors.New("worker pool closed")
+
+// Pool runs tasks on a fixed set of goroutines shared by every batch job.
+// Submit blocks while the queue is full, which pushes back on callers instead
+// of letting a large batch spawn unbounded goroutines.
+type Pool struct {
+	size    int
+	tasks   chan func()
+	queued  atomic.Int64
+	running atomic.Int64
+
+	mu     sync.RWMutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+func NewPool(size, queue int) *Pool {
+	if size < 1 {
+		size = 1
+	}
+	if queue < 0 {
+		queue = 0
+	}
+	p := &Pool{
+		size:  size,
+		tasks: make(chan func(), queue),
+	}
+	p.wg.Add(size)
+	for i := 0; i < size; i++ {
+		go p.work()
+	}
+	return p
+}
+
+// NewPoolFromEnv sizes the pool from WORKER_POOL_SIZE and WORKER_QUEUE_SIZE,
+// defaulting to one worker per CPU and four queued tasks per worker.
+func NewPoolFromEnv() *Pool {
+	size, err := strconv.Atoi(os.Getenv("WORKER_POOL_SIZE"))
+	if err != nil || size < 1 {
+		size = runtime.NumCPU()
+	}
+	queue, err := strconv.Atoi(os.Getenv("WORKER_QUEUE_SIZE"))
+	if err != nil || queue < 0 {
+		queue = size * 4
+	}
+	return NewPool(size, queue)
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+	for task := range p.tasks {
+		p.queued.Add(-1)
+		p.running.Add(1)
+		task()
+		p.running.Add(-1)
+	}
+}
+
+// Submit queues fn, blocking until there is room or ctx is done.
+func (p *Pool) Submit(ctx context.Context, fn func()) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return ErrPoolClosed
+	}
+
+	p.queued.Add(1)
+	select {
+	case p.tasks <- fn:
+		return nil
+	case <-ctx.Done():
+		p.queued.Add(-1)
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting tasks and waits for queued ones to finish.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.tasks)
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+}
+
+func (p *Pool) Size() int {
+	return p.size
+}
+
+// QueueDepth is the number of submitted tasks not yet picked up by a worker.
+func (p *Pool) QueueDepth() int {
+	return int(p.queued.Load())
+}
+
+// Running is the number of tasks currently executing.
+func (p *Pool) Running() int {
+	return int(p.running.Load())
+}
+
+// Group tracks the tasks of one job and caps how many of them may occupy the
+// pool at once, so a single large batch cannot starve the others.
+type Group struct {
+	pool *Pool
+	sem  chan struct{}
+	wg   sync.WaitGroup
+}
+
+// Group starts a job limited to limit concurrent tasks; limit <= 0 means the
+// pool size.
+func (p *Pool) Group(limit int) *Group {
+	if limit <= 0 || limit > p.size {
+		limit = p.size
+	}
+	return &Group{pool: p, sem: make(chan struct{}, limit)}
+}
+
+// Go submits fn to the pool once the group is below its limit.
+func (g *Group) Go(ctx context.Context, fn func()) error {
+	select {
+	case g.sem <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	g.wg.Add(1)
+	err := g.pool.Submit(ctx, func() {
+		defer func() {
+			<-g.sem
+			g.wg.Done()
+		}()
+		fn()
+	})
+	if err != nil {
+		<-g.sem
+		g.wg.Done()
+	}
+	return err
+}
+
+// Wait blocks until every task submitted through the group has finished.
+func (g *Group) Wait() {
+	g.wg.Wait()
+}
@@ -0,0 +1,62 @@
+package worker
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroupLimitsConcurrency(t *testing.T) {
+	pool := NewPool(4, 0)
+	defer pool.Close()
+
+	var running, peak, done atomic.Int64
+	group := pool.Group(2)
+	for i := 0; i < 10; i++ {
+		err := group.Go(context.Background(), func() {
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			running.Add(-1)
+			done.Add(1)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	group.Wait()
+
+	if done.Load() != 10 {
+		t.Fatalf("ran %d tasks, want 10", done.Load())
+	}
+	if peak.Load() > 2 {
+		t.Fatalf("peak concurrency %d, want at most 2", peak.Load())
+	}
+}
+
+func TestSubmitHonoursContext(t *testing.T) {
+	pool := NewPool(1, 0)
+	defer pool.Close()
+
+	block := make(chan struct{})
+	if err := pool.Submit(context.Background(), func() { <-block }); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := pool.Submit(ctx, func() {}); err != context.DeadlineExceeded {
+		t.Fatalf("got %v, want deadline exceeded", err)
+	}
+	close(block)
+
+	if d := pool.QueueDepth(); d != 0 {
+		t.Fatalf("queue depth %d after cancelled submit, want 0", d)
+	}
+}